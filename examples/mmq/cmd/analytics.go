@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+var analyticsCmd = &cobra.Command{
+	Use:   "analytics",
+	Short: "Show query log analytics",
+	Long: `Summarize the query log: top queries, zero-result queries, slow queries
+and documents that never appear in results.
+
+Queries are only recorded when commands run with --log-queries.
+
+Examples:
+  mmq --log-queries query "deploy steps"   # Record a query
+  mmq analytics --since 168h               # Last 7 days
+  mmq analytics --slow 200ms --limit 20`,
+	RunE: runAnalytics,
+}
+
+var (
+	analyticsSince time.Duration
+	analyticsLimit int
+	analyticsSlow  time.Duration
+)
+
+func init() {
+	analyticsCmd.Flags().DurationVar(&analyticsSince, "since", 0, "Time window, e.g. 24h (default: whole retention period)")
+	analyticsCmd.Flags().IntVarP(&analyticsLimit, "limit", "n", 10, "Entries per section")
+	analyticsCmd.Flags().DurationVar(&analyticsSlow, "slow", 500*time.Millisecond, "Slow query threshold")
+}
+
+func runAnalytics(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	report, err := m.QueryAnalytics(mmq.AnalyticsOptions{
+		Since:         analyticsSince,
+		Limit:         analyticsLimit,
+		SlowThreshold: analyticsSlow,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to analyze query log: %w", err)
+	}
+
+	if outputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	fmt.Printf("Queries since %s: %d\n", report.Since.Local().Format("2006-01-02 15:04"), report.TotalQueries)
+	if report.TotalQueries == 0 {
+		fmt.Println("No queries logged. Run searches with --log-queries to record them.")
+	} else {
+		fmt.Printf("Zero-result queries: %d (%.1f%%)\n", report.ZeroResultCount,
+			100*float64(report.ZeroResultCount)/float64(report.TotalQueries))
+		fmt.Printf("Latency: avg %v, p95 %v\n", report.AvgLatency.Round(time.Microsecond), report.P95Latency.Round(time.Microsecond))
+		kinds := make([]string, 0, len(report.ByKind))
+		for kind := range report.ByKind {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			fmt.Printf("  %-8s %d\n", kind, report.ByKind[kind])
+		}
+	}
+
+	if len(report.TopQueries) > 0 {
+		fmt.Println("\nTop queries:")
+		for _, q := range report.TopQueries {
+			fmt.Printf("  %4d  %s  (avg %.1f results, %v)\n", q.Count, q.Query, q.AvgResults, q.AvgLatency.Round(time.Microsecond))
+		}
+	}
+
+	if len(report.ZeroResults) > 0 {
+		fmt.Println("\nZero-result queries:")
+		for _, q := range report.ZeroResults {
+			fmt.Printf("  %4d  %s  (last %s)\n", q.Count, q.Query, q.LastSeen.Local().Format("2006-01-02 15:04"))
+		}
+	}
+
+	if len(report.SlowQueries) > 0 {
+		fmt.Printf("\nSlow queries (>= %v):\n", analyticsSlow)
+		for _, q := range report.SlowQueries {
+			fmt.Printf("  %10v  [%s] %s\n", q.Latency.Round(time.Millisecond), q.Kind, q.Query)
+		}
+	}
+
+	if report.NeverRetrievedCount > 0 {
+		fmt.Printf("\nNever retrieved documents: %d\n", report.NeverRetrievedCount)
+		for _, path := range report.NeverRetrieved {
+			fmt.Printf("  - %s\n", path)
+		}
+		if more := report.NeverRetrievedCount - len(report.NeverRetrieved); more > 0 {
+			fmt.Printf("  ... and %d more\n", more)
+		}
+	}
+
+	return nil
+}
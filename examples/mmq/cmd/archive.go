@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+
+	"github.com/crosszan/modu/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+// export 命令
+var exportCmd = &cobra.Command{
+	Use:   "export <file>",
+	Short: "Export the database to a portable archive",
+	Long: `Export collections, contexts, documents, embeddings and memories to a tar archive
+of JSONL files. Use a .tar.gz or .tgz extension for gzip compression.
+
+Examples:
+  mmq export backup.tar.gz
+  mmq export memories.tar --collections=false --contexts=false --documents=false
+  mmq export full.tar --embeddings`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExport,
+}
+
+// import 命令
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import an archive created by 'mmq export'",
+	Long: `Import an archive created by 'mmq export'. Existing collections, contexts,
+documents and memories with the same identity are overwritten.
+
+Run 'mmq embed' afterwards if embeddings were not included.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImport,
+}
+
+// backup 命令
+var backupCmd = &cobra.Command{
+	Use:   "backup <file>",
+	Short: "Create a consistent snapshot of the live database",
+	Long:  "Copy the database using SQLite's online backup API. Safe to run while other processes use the database.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBackup,
+}
+
+var (
+	exportOpts mmq.ArchiveOptions
+	importOpts mmq.ArchiveOptions
+)
+
+func init() {
+	addArchiveFlags(exportCmd, &exportOpts)
+	exportCmd.Flags().BoolVar(&exportOpts.Embeddings, "embeddings", false, "Include vector embeddings")
+
+	addArchiveFlags(importCmd, &importOpts)
+	importCmd.Flags().BoolVar(&importOpts.Embeddings, "embeddings", true, "Import vector embeddings if present")
+}
+
+// addArchiveFlags 注册各数据部分的包含/排除标志
+func addArchiveFlags(c *cobra.Command, opts *mmq.ArchiveOptions) {
+	c.Flags().BoolVar(&opts.Collections, "collections", true, "Include collections")
+	c.Flags().BoolVar(&opts.Contexts, "contexts", true, "Include contexts")
+	c.Flags().BoolVar(&opts.Documents, "documents", true, "Include documents and content")
+	c.Flags().BoolVar(&opts.Memories, "memories", true, "Include memories")
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	stats, err := m.Export(args[0], exportOpts)
+	if err != nil {
+		return fmt.Errorf("failed to export: %w", err)
+	}
+
+	fmt.Printf("✓ Exported to %s\n", args[0])
+	printArchiveStats(stats)
+
+	return nil
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	stats, err := m.Import(args[0], importOpts)
+	if err != nil {
+		return fmt.Errorf("failed to import: %w", err)
+	}
+
+	fmt.Printf("✓ Imported from %s\n", args[0])
+	printArchiveStats(stats)
+
+	return nil
+}
+
+func runBackup(cmd *cobra.Command, args []string) error {
+	if _, err := os.Stat(args[0]); err == nil {
+		return fmt.Errorf("backup destination already exists: %s", args[0])
+	}
+
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	// Ctrl+C 中止备份
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := m.Backup(ctx, args[0]); err != nil {
+		return fmt.Errorf("failed to backup: %w", err)
+	}
+
+	fmt.Printf("✓ Backup written to %s\n", args[0])
+	return nil
+}
+
+// printArchiveStats 按文件名顺序打印各部分记录数
+func printArchiveStats(stats mmq.ArchiveStats) {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Printf("  %-20s %d\n", name, stats[name])
+	}
+}
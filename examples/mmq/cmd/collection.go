@@ -63,13 +63,7 @@ func init() {
 }
 
 func runCollectionAdd(cmd *cobra.Command, args []string) error {
-	path := args[0]
-
-	// 展开路径
-	if path[:2] == "~/" {
-		homeDir, _ := os.UserHomeDir()
-		path = homeDir + path[1:]
-	}
+	path := expandPath(args[0])
 
 	// 检查路径是否存在
 	if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 
 	"github.com/crosszan/modu/examples/mmq/format"
+	"github.com/crosszan/modu/pkg/mmq"
 	"github.com/spf13/cobra"
 )
 
@@ -46,11 +49,43 @@ var contextRmCmd = &cobra.Command{
 	RunE:  runContextRm,
 }
 
+var contextGenerateCmd = &cobra.Command{
+	Use:   "generate [path]",
+	Short: "Generate contexts with the LLM",
+	Long: `Generate collection or folder descriptions by summarizing a sample of documents.
+
+Collections that already have a context are skipped unless --overwrite is given.
+
+Examples:
+  mmq context generate                          # All collections missing a context
+  mmq context generate -c docs --folders        # Collection and its top-level folders
+  mmq context generate -c docs api              # Only qmd://docs/api
+  mmq context generate --summaries              # Also summarize documents for search`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runContextGenerate,
+}
+
+var (
+	contextSampleSize int
+	contextFolders    bool
+	contextOverwrite  bool
+	contextDryRun     bool
+	contextSummaries  bool
+)
+
 func init() {
 	contextCmd.AddCommand(contextAddCmd)
 	contextCmd.AddCommand(contextListCmd)
 	contextCmd.AddCommand(contextCheckCmd)
 	contextCmd.AddCommand(contextRmCmd)
+	contextCmd.AddCommand(contextGenerateCmd)
+
+	// context generate 标志
+	contextGenerateCmd.Flags().IntVar(&contextSampleSize, "sample", 5, "Documents sampled per collection or folder")
+	contextGenerateCmd.Flags().BoolVar(&contextFolders, "folders", false, "Also generate contexts for top-level folders")
+	contextGenerateCmd.Flags().BoolVar(&contextOverwrite, "overwrite", false, "Regenerate existing contexts")
+	contextGenerateCmd.Flags().BoolVar(&contextDryRun, "dry-run", false, "Print generated contexts without saving")
+	contextGenerateCmd.Flags().BoolVar(&contextSummaries, "summaries", false, "Also generate per-document summaries (indexed for search)")
 }
 
 func runContextAdd(cmd *cobra.Command, args []string) error {
@@ -145,3 +180,60 @@ func runContextRm(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Removed context for '%s'\n", path)
 	return nil
 }
+
+func runContextGenerate(cmd *cobra.Command, args []string) error {
+	opts := mmq.GenerateContextsOptions{
+		Collection: collectionFlag,
+		Folders:    contextFolders,
+		SampleSize: contextSampleSize,
+		Overwrite:  contextOverwrite,
+		DryRun:     contextDryRun,
+	}
+	if len(args) == 1 {
+		opts.Path = args[0]
+	}
+
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	generated, err := m.GenerateContexts(opts)
+	if err != nil {
+		return fmt.Errorf("failed to generate contexts: %w", err)
+	}
+
+	summarized := 0
+	if contextSummaries && !contextDryRun {
+		summarized, err = m.GenerateSummaries(mmq.SummarizeOptions{Collection: collectionFlag})
+		if err != nil {
+			return fmt.Errorf("failed to generate summaries: %w", err)
+		}
+	}
+
+	if outputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(generated)
+	}
+
+	if len(generated) == 0 {
+		fmt.Println("No collections with documents found")
+	}
+	for _, g := range generated {
+		if g.Skipped {
+			fmt.Printf("- %s (exists, use --overwrite to regenerate)\n", g.Path)
+			continue
+		}
+		fmt.Printf("✓ %s (%d documents sampled)\n  %s\n", g.Path, g.Sampled, g.Content)
+	}
+	if contextDryRun {
+		fmt.Println("\nDry run: nothing saved")
+	}
+	if contextSummaries && !contextDryRun {
+		fmt.Printf("\nSummarized %d documents\n", summarized)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/crosszan/modu/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+var feedbackCmd = &cobra.Command{
+	Use:   "feedback <query-id> <doc> <up|down|used>",
+	Short: "Record relevance feedback for a search result",
+	Long: `Mark a result of a logged query as relevant (up), irrelevant (down) or
+used in an answer (used). <doc> is a result ID or collection/path.
+
+Feedback only affects ranking for profiles with --feedback-weight set.
+
+Examples:
+  mmq --log-queries query "deploy steps"        # Prints a query ID
+  mmq feedback 5f0c... docs/deploy.md used
+  mmq profile set learned --feedback-weight 0.5`,
+	Args: cobra.ExactArgs(3),
+	RunE: runFeedback,
+}
+
+func runFeedback(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.RecordFeedback(args[0], args[1], mmq.FeedbackSignal(args[2])); err != nil {
+		return fmt.Errorf("failed to record feedback: %w", err)
+	}
+
+	fmt.Printf("Recorded %s feedback for %s\n", args[2], args[1])
+	return nil
+}
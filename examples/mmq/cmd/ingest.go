@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/crosszan/modu/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+var ingestCmd = &cobra.Command{
+	Use:   "ingest <file>",
+	Short: "Index a CSV/TSV/JSON/JSONL file row by row",
+	Long: `Index each CSV/TSV row or JSON(L) record as its own document.
+
+Rows are rendered as "field: value" text for search and embeddings, and their
+fields can be used as filters with --field. Row documents are named
+<file>#<row>. Files matched by a collection mask are ingested the same way.
+
+Examples:
+  mmq ingest faq.csv -c support
+  mmq ingest products.json -c catalog --title-field name
+  mmq search refund -c support --field category=billing`,
+	Args: cobra.ExactArgs(1),
+	RunE: runIngest,
+}
+
+var (
+	ingestFormat     string
+	ingestPath       string
+	ingestTitleField string
+)
+
+func init() {
+	ingestCmd.Flags().StringVar(&ingestFormat, "format", "", "csv, tsv, json or jsonl (default: by extension)")
+	ingestCmd.Flags().StringVar(&ingestPath, "path", "", "Path of the file inside the collection (default: file name)")
+	ingestCmd.Flags().StringVar(&ingestTitleField, "title-field", "", "Field used as row title (default: auto)")
+}
+
+func runIngest(cmd *cobra.Command, args []string) error {
+	if collectionFlag == "" {
+		return fmt.Errorf("--collection is required")
+	}
+
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	rows, err := m.IndexStructuredFile(args[0], mmq.StructuredOptions{
+		Collection: collectionFlag,
+		Path:       ingestPath,
+		Format:     ingestFormat,
+		TitleField: ingestTitleField,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to ingest %s: %w", args[0], err)
+	}
+
+	fmt.Printf("Indexed %d row(s) from %s into %s\n", rows, args[0], collectionFlag)
+	fmt.Println("Run 'mmq embed' to generate embeddings")
+	return nil
+}
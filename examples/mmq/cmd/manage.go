@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 
 	"github.com/crosszan/modu/examples/mmq/format"
 	"github.com/crosszan/modu/pkg/mmq"
@@ -27,16 +29,29 @@ var updateCmd = &cobra.Command{
 var embedCmd = &cobra.Command{
 	Use:   "embed",
 	Short: "Generate vector embeddings",
-	Long:  "Generate vector embeddings for all documents that need them",
-	RunE:  runEmbed,
+	Long: `Generate vector embeddings for all documents that need them.
+
+With --quantize, set the vector quantization of a collection (-c) or of all
+collections, then convert existing embeddings:
+  none    full-precision float32 vectors
+  int8    int8 scalar codes replace float32 (about 1/4 of the size)
+  binary  sign-bit codes prefilter candidates; float32 kept for rescoring
+
+Quantized collections prefilter candidates by Hamming distance and rescore them
+on float32 (dequantized int8) vectors.`,
+	RunE: runEmbed,
 }
 
 var (
-	gitPull bool
+	gitPull         bool
+	updateSummaries bool
+	embedQuantize   string
 )
 
 func init() {
 	updateCmd.Flags().BoolVar(&gitPull, "pull", false, "Git pull before indexing")
+	updateCmd.Flags().BoolVar(&updateSummaries, "summaries", false, "Generate summaries for new or changed documents")
+	embedCmd.Flags().StringVar(&embedQuantize, "quantize", "", "Set quantization and convert embeddings (none, int8, binary)")
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
@@ -86,6 +101,7 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 			Collection: coll.Name,
 			Mask:       coll.Mask,
 			Recursive:  true,
+			Summarize:  updateSummaries,
 		})
 
 		if err != nil {
@@ -127,20 +143,70 @@ func runEmbed(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get status: %w", err)
 	}
 
+	if cmd.Flags().Changed("quantize") {
+		if err := setQuantization(m, embedQuantize); err != nil {
+			return err
+		}
+	}
+
 	if status.NeedsEmbedding == 0 {
 		fmt.Println("All documents already have embeddings")
-		return nil
+	} else {
+		fmt.Printf("Generating embeddings for %d documents...\n", status.NeedsEmbedding)
+		fmt.Println("This may take a while...")
+		fmt.Println()
+
+		err = m.GenerateEmbeddings()
+		if err != nil {
+			return fmt.Errorf("failed to generate embeddings: %w", err)
+		}
+
+		fmt.Println("\n✓ Embeddings generated successfully")
 	}
 
-	fmt.Printf("Generating embeddings for %d documents...\n", status.NeedsEmbedding)
-	fmt.Println("This may take a while...")
-	fmt.Println()
+	if !cmd.Flags().Changed("quantize") {
+		return nil
+	}
 
-	err = m.GenerateEmbeddings()
+	stats, err := m.QuantizeEmbeddings()
 	if err != nil {
-		return fmt.Errorf("failed to generate embeddings: %w", err)
+		return fmt.Errorf("failed to quantize embeddings: %w", err)
 	}
 
-	fmt.Println("\n✓ Embeddings generated successfully")
+	if outputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stats)
+	}
+
+	fmt.Printf("Quantized %d vectors (float32: %d, int8: %d, binary: %d)\n",
+		stats.Vectors, stats.Float32, stats.Int8, stats.Binary)
+	fmt.Printf("Vector storage: %d -> %d bytes\n", stats.BytesBefore, stats.BytesAfter)
+	return nil
+}
+
+// setQuantization 设置指定集合（未指定时为所有集合）的量化方式
+func setQuantization(m *mmq.MMQ, mode string) error {
+	if mode == "none" {
+		mode = ""
+	}
+
+	names := []string{collectionFlag}
+	if collectionFlag == "" {
+		collections, err := m.ListCollections()
+		if err != nil {
+			return fmt.Errorf("failed to list collections: %w", err)
+		}
+		names = names[:0]
+		for _, c := range collections {
+			names = append(names, c.Name)
+		}
+	}
+
+	for _, name := range names {
+		if err := m.SetCollectionQuantization(name, mmq.Quantization(mode)); err != nil {
+			return err
+		}
+	}
 	return nil
 }
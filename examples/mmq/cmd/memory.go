@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+var memoryCmd = &cobra.Command{
+	Use:   "memory",
+	Short: "Maintain stored memories",
+	Long: `Maintain the memories stored by agents.
+
+--tenant, --user, --agent and --session restrict a command to one namespace;
+empty fields match any value.`,
+}
+
+var memoryDedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Merge near-duplicate memories",
+	Long: `Merge memories of the same namespace and type whose embeddings are at least
+--threshold similar. The oldest memory is kept: its importance is raised, its
+timestamp refreshed and tags unioned, and the merged memories are recorded in
+its "provenance" metadata. Conversation turns and episodic events are never
+merged.
+
+Examples:
+  mmq memory dedupe --dry-run
+  mmq memory dedupe --threshold 0.9 --tenant acme --user alice`,
+	RunE: runMemoryDedupe,
+}
+
+var memoryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List memories with filters",
+	Long: `List memories newest first, filtered by type, tags, metadata, time range,
+importance and expiry state.
+
+--meta values are parsed as JSON when possible (2, true, "2"), otherwise as strings.
+
+Examples:
+  mmq memory list --type conversation --tag billing --since 168h
+  mmq memory list --all-tags billing,refund --meta channel=chat
+  mmq memory list --expiry expired --limit 50 --offset 50`,
+	RunE: runMemoryList,
+}
+
+var memoryReflectCmd = &cobra.Command{
+	Use:   "reflect",
+	Short: "Synthesize higher-level insights from recent memories",
+	Long: `Ask the LLM for higher-level insights about the memories written since the
+last reflection of the namespace, and store them as "reflection" memories with
+raised importance. Each insight links to the IDs of the memories it came from.
+
+At most --max-memories memories are considered per run, oldest first; the rest
+are left for the next run. Nothing is generated when fewer than --min-memories
+new memories exist.
+
+Examples:
+  mmq memory reflect --tenant acme --user alice
+  mmq memory reflect --user alice --window 168h --max-insights 3 --dry-run`,
+	RunE: runMemoryReflect,
+}
+
+var (
+	memoryTenant  string
+	memoryUser    string
+	memoryAgent   string
+	memorySession string
+
+	dedupeThreshold float64
+	dedupeBoost     float64
+	dedupeDryRun    bool
+
+	listTypes         []string
+	listAnyTags       []string
+	listAllTags       []string
+	listMetadata      map[string]string
+	listSince         time.Duration
+	listUntil         time.Duration
+	listMinImportance float64
+	listMaxImportance float64
+	listExpiry        string
+	listLimit         int
+	listOffset        int
+
+	reflectTypes       []string
+	reflectWindow      time.Duration
+	reflectMaxMemories int
+	reflectMinMemories int
+	reflectMaxInsights int
+	reflectDryRun      bool
+)
+
+func init() {
+	memoryCmd.PersistentFlags().StringVar(&memoryTenant, "tenant", "", "Namespace tenant")
+	memoryCmd.PersistentFlags().StringVar(&memoryUser, "user", "", "Namespace user")
+	memoryCmd.PersistentFlags().StringVar(&memoryAgent, "agent", "", "Namespace agent")
+	memoryCmd.PersistentFlags().StringVar(&memorySession, "session", "", "Namespace session")
+
+	memoryDedupeCmd.Flags().Float64Var(&dedupeThreshold, "threshold", 0.95, "Cosine similarity at which memories count as duplicates")
+	memoryDedupeCmd.Flags().Float64Var(&dedupeBoost, "boost", 0, "Importance added per merged memory (default 0.1)")
+	memoryDedupeCmd.Flags().BoolVar(&dedupeDryRun, "dry-run", false, "Only report what would be merged")
+
+	memoryListCmd.Flags().StringSliceVar(&listTypes, "type", nil, "Memory types (conversation, fact, preference, episodic, reflection)")
+	memoryListCmd.Flags().StringSliceVar(&listAnyTags, "tag", nil, "Match memories with any of these tags")
+	memoryListCmd.Flags().StringSliceVar(&listAllTags, "all-tags", nil, "Match memories with all of these tags")
+	memoryListCmd.Flags().StringToStringVar(&listMetadata, "meta", nil, "Metadata key=value to match")
+	memoryListCmd.Flags().DurationVar(&listSince, "since", 0, "Only memories newer than this, e.g. 168h")
+	memoryListCmd.Flags().DurationVar(&listUntil, "until", 0, "Only memories older than this, e.g. 24h")
+	memoryListCmd.Flags().Float64Var(&listMinImportance, "min-importance", 0, "Minimum importance")
+	memoryListCmd.Flags().Float64Var(&listMaxImportance, "max-importance", 0, "Maximum importance")
+	memoryListCmd.Flags().StringVar(&listExpiry, "expiry", "", "Expiry state: active or expired")
+	memoryListCmd.Flags().IntVarP(&listLimit, "limit", "n", 20, "Memories per page (0 for all)")
+	memoryListCmd.Flags().IntVar(&listOffset, "offset", 0, "Memories to skip")
+
+	memoryReflectCmd.Flags().StringSliceVar(&reflectTypes, "type", nil, "Memory types to reflect on (default conversation, fact, preference, episodic)")
+	memoryReflectCmd.Flags().DurationVar(&reflectWindow, "window", 0, "Only consider memories newer than this, e.g. 168h")
+	memoryReflectCmd.Flags().IntVar(&reflectMaxMemories, "max-memories", 50, "Maximum memories considered per run")
+	memoryReflectCmd.Flags().IntVar(&reflectMinMemories, "min-memories", 5, "Skip when fewer new memories exist")
+	memoryReflectCmd.Flags().IntVar(&reflectMaxInsights, "max-insights", 5, "Maximum insights to generate")
+	memoryReflectCmd.Flags().BoolVar(&reflectDryRun, "dry-run", false, "Only print the insights, do not store them")
+
+	memoryCmd.AddCommand(memoryDedupeCmd)
+	memoryCmd.AddCommand(memoryListCmd)
+	memoryCmd.AddCommand(memoryReflectCmd)
+}
+
+// memoryNamespace 命令行指定的记忆命名空间
+func memoryNamespace() mmq.Namespace {
+	return mmq.Namespace{
+		Tenant:  memoryTenant,
+		User:    memoryUser,
+		Agent:   memoryAgent,
+		Session: memorySession,
+	}
+}
+
+func runMemoryDedupe(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	stats, err := m.DedupeMemories(mmq.DedupOptions{
+		Threshold:       dedupeThreshold,
+		ImportanceBoost: dedupeBoost,
+		DryRun:          dedupeDryRun,
+		Namespace:       memoryNamespace(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to dedupe memories: %w", err)
+	}
+
+	if outputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stats)
+	}
+
+	verb := "Merged"
+	if dedupeDryRun {
+		verb = "Would merge"
+	}
+	fmt.Printf("Scanned %d memories\n", stats.Scanned)
+	fmt.Printf("%s %d duplicates into %d memories\n", verb, stats.Merged, stats.Kept)
+	return nil
+}
+
+func runMemoryList(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	filter := mmq.MemoryFilter{
+		AnyTags:       listAnyTags,
+		AllTags:       listAllTags,
+		MinImportance: listMinImportance,
+		MaxImportance: listMaxImportance,
+		Expiry:        mmq.ExpiryState(listExpiry),
+	}
+	now := time.Now()
+	if listSince > 0 {
+		filter.Since = now.Add(-listSince)
+	}
+	if listUntil > 0 {
+		filter.Until = now.Add(-listUntil)
+	}
+	if len(listMetadata) > 0 {
+		filter.Metadata = make(map[string]interface{}, len(listMetadata))
+		for key, raw := range listMetadata {
+			var value interface{}
+			if err := json.Unmarshal([]byte(raw), &value); err != nil {
+				value = raw
+			}
+			filter.Metadata[key] = value
+		}
+	}
+
+	var types []mmq.MemoryType
+	for _, t := range listTypes {
+		types = append(types, mmq.MemoryType(t))
+	}
+
+	opts := mmq.ListMemoriesOptions{
+		MemoryTypes: types,
+		Filter:      filter,
+		Limit:       listLimit,
+		Offset:      listOffset,
+	}
+	if ns := memoryNamespace(); ns != (mmq.Namespace{}) {
+		opts.Scopes = []mmq.Namespace{ns}
+	}
+
+	page, err := m.ListMemories(opts)
+	if err != nil {
+		return fmt.Errorf("failed to list memories: %w", err)
+	}
+
+	if outputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(page)
+	}
+
+	if page.Total == 0 {
+		fmt.Println("No memories found")
+		return nil
+	}
+
+	fmt.Printf("Showing %d-%d of %d memories\n\n", page.Offset+1, page.Offset+len(page.Memories), page.Total)
+	for _, mem := range page.Memories {
+		fmt.Printf("%s  [%s] %.2f  %s\n", mem.Timestamp.Local().Format("2006-01-02 15:04"), mem.Type, mem.Importance, mem.Content)
+		if len(mem.Tags) > 0 {
+			fmt.Printf("    tags: %s\n", strings.Join(mem.Tags, ", "))
+		}
+		fmt.Printf("    id: %s\n", mem.ID)
+	}
+	if page.HasMore() {
+		fmt.Printf("\nMore with --offset %d\n", page.Offset+len(page.Memories))
+	}
+	return nil
+}
+
+func runMemoryReflect(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	var types []mmq.MemoryType
+	for _, t := range reflectTypes {
+		types = append(types, mmq.MemoryType(t))
+	}
+
+	result, err := m.ReflectMemories(mmq.ReflectOptions{
+		Namespace:   memoryNamespace(),
+		MemoryTypes: types,
+		Window:      reflectWindow,
+		MaxMemories: reflectMaxMemories,
+		MinMemories: reflectMinMemories,
+		MaxInsights: reflectMaxInsights,
+		DryRun:      reflectDryRun,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reflect on memories: %w", err)
+	}
+
+	if outputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+
+	if result.Skipped {
+		fmt.Printf("Skipped: %d new memories, need at least %d\n", result.Considered, reflectMinMemories)
+		return nil
+	}
+
+	verb := "Stored"
+	if reflectDryRun {
+		verb = "Would store"
+	}
+	fmt.Printf("Considered %d memories\n", result.Considered)
+	fmt.Printf("%s %d insights\n", verb, len(result.Insights))
+	for _, insight := range result.Insights {
+		fmt.Printf("\n  %.2f  %s\n", insight.Importance, insight.Content)
+		fmt.Printf("    sources: %s\n", strings.Join(insight.Sources, ", "))
+	}
+	for _, reason := range result.Rejected {
+		fmt.Printf("  rejected: %s\n", reason)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq"
+	"github.com/spf13/cobra"
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage ranking profiles",
+	Long:  "Create, list, and remove ranking profiles, and bind them to collections",
+}
+
+var profileSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Create or update a ranking profile",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runProfileSet,
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List ranking profiles (builtin and saved)",
+	RunE:  runProfileList,
+}
+
+var profileRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a saved ranking profile",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runProfileRemove,
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use <collection> [name]",
+	Short: "Bind a ranking profile to a collection (omit name to unbind)",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE:  runProfileUse,
+}
+
+var (
+	profileFieldWeights  string
+	profileRRFWeights    string
+	profileRRFK          int
+	profileHalfLife      time.Duration
+	profileRecencyWeight float64
+	profileBoosts        []string
+	profileContextWeight float64
+	profileFeedback      float64
+	profileFeedbackHL    time.Duration
+)
+
+func init() {
+	// profile set 标志
+	profileSetCmd.Flags().StringVar(&profileFieldWeights, "field-weights", "", "BM25 weights filepath,title,body (default 10,1,1)")
+	profileSetCmd.Flags().StringVar(&profileRRFWeights, "rrf-weights", "", "RRF weights fts,vector (default 1,1)")
+	profileSetCmd.Flags().IntVar(&profileRRFK, "rrf-k", 0, "RRF k parameter (default 60)")
+	profileSetCmd.Flags().DurationVar(&profileHalfLife, "half-life", 0, "Recency half-life on modified time, e.g. 720h (0 disables)")
+	profileSetCmd.Flags().Float64Var(&profileRecencyWeight, "recency-weight", 0, "Recency factor weight (default 1.0)")
+	profileSetCmd.Flags().StringArrayVar(&profileBoosts, "boost", nil, "Collection score multiplier, e.g. --boost notes=1.5 (repeatable)")
+	profileSetCmd.Flags().Float64Var(&profileContextWeight, "path-context-weight", 0, "Boost for query terms found in inherited path contexts (0 disables)")
+	profileSetCmd.Flags().Float64Var(&profileFeedback, "feedback-weight", 0, "Boost learned from 'mmq feedback' (0 disables)")
+	profileSetCmd.Flags().DurationVar(&profileFeedbackHL, "feedback-half-life", 0, "Feedback decay half-life (default 720h)")
+
+	// 添加子命令
+	profileCmd.AddCommand(profileSetCmd)
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileRemoveCmd)
+	profileCmd.AddCommand(profileUseCmd)
+}
+
+func runProfileSet(cmd *cobra.Command, args []string) error {
+	profile := mmq.RankingProfile{
+		Name:            args[0],
+		RRFK:            profileRRFK,
+		RecencyHalfLife: profileHalfLife,
+		RecencyWeight:   profileRecencyWeight,
+
+		PathContextWeight: profileContextWeight,
+
+		FeedbackWeight:   profileFeedback,
+		FeedbackHalfLife: profileFeedbackHL,
+	}
+
+	if profileFieldWeights != "" {
+		weights, err := parseFloatList(profileFieldWeights, 3)
+		if err != nil {
+			return fmt.Errorf("invalid --field-weights: %w", err)
+		}
+		profile.FieldWeights = mmq.FieldWeights{Filepath: weights[0], Title: weights[1], Body: weights[2]}
+	}
+
+	if profileRRFWeights != "" {
+		weights, err := parseFloatList(profileRRFWeights, 2)
+		if err != nil {
+			return fmt.Errorf("invalid --rrf-weights: %w", err)
+		}
+		profile.RRFWeights = weights
+	}
+
+	for _, boost := range profileBoosts {
+		name, value, ok := strings.Cut(boost, "=")
+		if !ok {
+			return fmt.Errorf("invalid --boost %q: expected collection=factor", boost)
+		}
+		factor, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid --boost %q: %w", boost, err)
+		}
+		if profile.CollectionBoosts == nil {
+			profile.CollectionBoosts = make(map[string]float64)
+		}
+		profile.CollectionBoosts[name] = factor
+	}
+
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.SaveRankingProfile(profile); err != nil {
+		return fmt.Errorf("failed to save profile: %w", err)
+	}
+
+	fmt.Printf("Saved ranking profile '%s'\n", profile.Name)
+	return nil
+}
+
+func runProfileList(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	profiles, err := m.ListRankingProfiles()
+	if err != nil {
+		return fmt.Errorf("failed to list profiles: %w", err)
+	}
+
+	if outputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(profiles)
+	}
+
+	for _, p := range profiles {
+		fmt.Printf("Profile: %s\n", p.Name)
+		if p.FieldWeights != (mmq.FieldWeights{}) {
+			fmt.Printf("  Field weights: filepath=%g title=%g body=%g\n",
+				p.FieldWeights.Filepath, p.FieldWeights.Title, p.FieldWeights.Body)
+		}
+		if len(p.RRFWeights) > 0 || p.RRFK > 0 {
+			fmt.Printf("  RRF: weights=%v k=%d\n", p.RRFWeights, p.RRFK)
+		}
+		if p.RecencyHalfLife > 0 {
+			fmt.Printf("  Recency: half-life=%s weight=%g\n", p.RecencyHalfLife, p.RecencyWeight)
+		}
+		for name, factor := range p.CollectionBoosts {
+			fmt.Printf("  Boost: %s x%g\n", name, factor)
+		}
+		if p.PathContextWeight > 0 {
+			fmt.Printf("  Path context weight: %g\n", p.PathContextWeight)
+		}
+		if p.FeedbackWeight > 0 {
+			halfLife := p.FeedbackHalfLife
+			if halfLife <= 0 {
+				halfLife = mmq.DefaultFeedbackHalfLife
+			}
+			fmt.Printf("  Feedback: weight=%g half-life=%s\n", p.FeedbackWeight, halfLife)
+		}
+		fmt.Println()
+	}
+
+	return nil
+}
+
+func runProfileRemove(cmd *cobra.Command, args []string) error {
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.DeleteRankingProfile(args[0]); err != nil {
+		return fmt.Errorf("failed to remove profile: %w", err)
+	}
+
+	fmt.Printf("Removed ranking profile '%s'\n", args[0])
+	return nil
+}
+
+func runProfileUse(cmd *cobra.Command, args []string) error {
+	collection := args[0]
+	name := ""
+	if len(args) > 1 {
+		name = args[1]
+	}
+
+	m, err := getMMQ()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.SetCollectionProfile(collection, name); err != nil {
+		return fmt.Errorf("failed to bind profile: %w", err)
+	}
+
+	if name == "" {
+		fmt.Printf("Collection '%s' now uses default ranking\n", collection)
+	} else {
+		fmt.Printf("Collection '%s' now uses ranking profile '%s'\n", collection, name)
+	}
+	return nil
+}
+
+// parseFloatList 解析逗号分隔的浮点数列表
+func parseFloatList(s string, n int) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d comma-separated numbers, got %d", n, len(parts))
+	}
+
+	values := make([]float64, n)
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+
+	return values, nil
+}
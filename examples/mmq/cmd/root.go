@@ -3,6 +3,8 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/crosszan/modu/pkg/mmq"
 	"github.com/spf13/cobra"
@@ -19,9 +21,10 @@ var (
 	BuildTime string
 
 	// 全局标志
-	dbPath         string
+	dbPaths        []string
 	collectionFlag string
 	outputFormat   string
+	logQueries     bool
 )
 
 // rootCmd represents the base command
@@ -40,9 +43,10 @@ func Execute() error {
 
 func init() {
 	// 全局标志
-	rootCmd.PersistentFlags().StringVarP(&dbPath, "db", "d", DefaultDBPath, "Database path")
+	rootCmd.PersistentFlags().StringSliceVarP(&dbPaths, "db", "d", []string{DefaultDBPath}, "Database path (repeat or comma-separate to search several indexes)")
 	rootCmd.PersistentFlags().StringVarP(&collectionFlag, "collection", "c", "", "Collection filter")
 	rootCmd.PersistentFlags().StringVarP(&outputFormat, "format", "f", "text", "Output format (text|json|csv|md|xml)")
+	rootCmd.PersistentFlags().BoolVar(&logQueries, "log-queries", false, "Record searches in the query log (see 'mmq analytics')")
 
 	// 添加子命令
 	rootCmd.AddCommand(collectionCmd)
@@ -56,16 +60,29 @@ func init() {
 	rootCmd.AddCommand(searchCmd)
 	rootCmd.AddCommand(vsearchCmd)
 	rootCmd.AddCommand(queryCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(profileCmd)
+	rootCmd.AddCommand(analyticsCmd)
+	rootCmd.AddCommand(feedbackCmd)
+	rootCmd.AddCommand(ingestCmd)
+	rootCmd.AddCommand(memoryCmd)
 
 	// 版本模板
 	rootCmd.SetVersionTemplate(fmt.Sprintf("mmq version %s (built %s)\n", Version, BuildTime))
 }
 
 // getMMQ 获取MMQ实例（辅助函数）
+// 只有搜索命令支持多个--db，其余命令要求单个数据库
 func getMMQ() (*mmq.MMQ, error) {
+	if len(dbPaths) != 1 {
+		return nil, fmt.Errorf("this command accepts a single --db path, got %d", len(dbPaths))
+	}
+	dbPath := expandPath(dbPaths[0])
+
 	// 确保数据库目录存在
-	dbDir := dbPath[:len(dbPath)-len("/index.db")]
-	if err := os.MkdirAll(dbDir, 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
 		return nil, fmt.Errorf("failed to create db directory: %w", err)
 	}
 
@@ -73,6 +90,44 @@ func getMMQ() (*mmq.MMQ, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	m.SetQueryLogging(logQueries)
 
 	return m, nil
 }
+
+// getFederation 打开--db指定的所有数据库组成联邦（辅助函数）
+func getFederation() (*mmq.Federation, error) {
+	paths := make([]string, len(dbPaths))
+	for i, path := range dbPaths {
+		paths[i] = expandPath(path)
+		if _, err := os.Stat(paths[i]); err != nil {
+			return nil, fmt.Errorf("failed to open database %s: %w", paths[i], err)
+		}
+	}
+
+	fed, err := mmq.NewFederation(paths...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open databases: %w", err)
+	}
+	for _, idx := range fed.Indexes() {
+		idx.MMQ.SetQueryLogging(logQueries)
+	}
+
+	return fed, nil
+}
+
+// expandPath 展开路径中的环境变量和开头的~
+func expandPath(path string) string {
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			path = homeDir + path[1:]
+		}
+	}
+	return path
+}
+
+// isFederated 是否指定了多个数据库
+func isFederated() bool {
+	return len(dbPaths) > 1
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/crosszan/modu/examples/mmq/format"
 	"github.com/crosszan/modu/pkg/mmq"
@@ -36,9 +37,13 @@ var queryCmd = &cobra.Command{
 }
 
 var (
-	numResults int
-	minScore   float64
-	showAll    bool
+	numResults  int
+	minScore    float64
+	showAll     bool
+	profileName string
+	fieldFlags  []string
+	langFilter  string
+	preferLang  string
 )
 
 func init() {
@@ -47,23 +52,39 @@ func init() {
 	searchCmd.Flags().Float64Var(&minScore, "min-score", 0.0, "Minimum score threshold")
 	searchCmd.Flags().BoolVar(&showAll, "all", false, "Return all matches")
 	searchCmd.Flags().BoolVar(&fullContent, "full", false, "Show full content")
+	searchCmd.Flags().StringVar(&profileName, "profile", "", "Ranking profile (see 'mmq profile list')")
+	searchCmd.Flags().StringArrayVar(&fieldFlags, "field", nil, "Filter structured rows by field, e.g. --field category=billing (repeatable)")
+	searchCmd.Flags().StringVar(&langFilter, "lang", "", "Only return documents in this language (e.g. zh, en)")
+	searchCmd.Flags().StringVar(&preferLang, "prefer-lang", "", "Rank documents in this language first ('auto' uses the query language)")
 
 	// vsearch 标志
 	vsearchCmd.Flags().IntVarP(&numResults, "num", "n", 10, "Number of results")
 	vsearchCmd.Flags().Float64Var(&minScore, "min-score", 0.0, "Minimum score threshold")
 	vsearchCmd.Flags().BoolVar(&showAll, "all", false, "Return all matches")
 	vsearchCmd.Flags().BoolVar(&fullContent, "full", false, "Show full content")
+	vsearchCmd.Flags().StringVar(&profileName, "profile", "", "Ranking profile (see 'mmq profile list')")
+	vsearchCmd.Flags().StringArrayVar(&fieldFlags, "field", nil, "Filter structured rows by field, e.g. --field category=billing (repeatable)")
+	vsearchCmd.Flags().StringVar(&langFilter, "lang", "", "Only return documents in this language (e.g. zh, en)")
+	vsearchCmd.Flags().StringVar(&preferLang, "prefer-lang", "", "Rank documents in this language first ('auto' uses the query language)")
 
 	// query 标志
 	queryCmd.Flags().IntVarP(&numResults, "num", "n", 10, "Number of results")
 	queryCmd.Flags().Float64Var(&minScore, "min-score", 0.0, "Minimum score threshold")
 	queryCmd.Flags().BoolVar(&showAll, "all", false, "Return all matches")
 	queryCmd.Flags().BoolVar(&fullContent, "full", false, "Show full content")
+	queryCmd.Flags().StringVar(&profileName, "profile", "", "Ranking profile (see 'mmq profile list')")
+	queryCmd.Flags().StringArrayVar(&fieldFlags, "field", nil, "Filter structured rows by field, e.g. --field category=billing (repeatable)")
+	queryCmd.Flags().StringVar(&langFilter, "lang", "", "Only return documents in this language (e.g. zh, en)")
+	queryCmd.Flags().StringVar(&preferLang, "prefer-lang", "", "Rank documents in this language first ('auto' uses the query language)")
 }
 
 func runSearch(cmd *cobra.Command, args []string) error {
 	query := args[0]
 
+	if isFederated() {
+		return runFederatedSearch(query, "search")
+	}
+
 	m, err := getMMQ()
 	if err != nil {
 		return err
@@ -75,10 +96,20 @@ func runSearch(cmd *cobra.Command, args []string) error {
 		limit = 0 // 0 表示不限制
 	}
 
+	fields, err := parseFieldFilters(fieldFlags)
+	if err != nil {
+		return err
+	}
+
 	results, err := m.Search(query, mmq.SearchOptions{
 		Limit:      limit,
 		MinScore:   minScore,
 		Collection: collectionFlag,
+		Profile:    profileName,
+		Fields:     fields,
+
+		Language:       langFilter,
+		PreferLanguage: preferLang,
 	})
 
 	if err != nil {
@@ -91,12 +122,17 @@ func runSearch(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Printf("Found %d result(s)\n\n", len(results))
+	printQueryID(results)
 	return format.OutputSearchResults(results, format.Format(outputFormat), fullContent)
 }
 
 func runVSearch(cmd *cobra.Command, args []string) error {
 	query := args[0]
 
+	if isFederated() {
+		return runFederatedSearch(query, "vsearch")
+	}
+
 	m, err := getMMQ()
 	if err != nil {
 		return err
@@ -114,10 +150,20 @@ func runVSearch(cmd *cobra.Command, args []string) error {
 		limit = 0
 	}
 
+	fields, err := parseFieldFilters(fieldFlags)
+	if err != nil {
+		return err
+	}
+
 	results, err := m.VectorSearch(query, mmq.SearchOptions{
 		Limit:      limit,
 		MinScore:   minScore,
 		Collection: collectionFlag,
+		Profile:    profileName,
+		Fields:     fields,
+
+		Language:       langFilter,
+		PreferLanguage: preferLang,
 	})
 
 	if err != nil {
@@ -131,12 +177,17 @@ func runVSearch(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Printf("Found %d result(s)\n\n", len(results))
+	printQueryID(results)
 	return format.OutputSearchResults(results, format.Format(outputFormat), fullContent)
 }
 
 func runQuery(cmd *cobra.Command, args []string) error {
 	query := args[0]
 
+	if isFederated() {
+		return runFederatedSearch(query, "query")
+	}
+
 	m, err := getMMQ()
 	if err != nil {
 		return err
@@ -154,6 +205,11 @@ func runQuery(cmd *cobra.Command, args []string) error {
 		limit = 0
 	}
 
+	fields, err := parseFieldFilters(fieldFlags)
+	if err != nil {
+		return err
+	}
+
 	// 使用混合检索策略
 	results, err := m.RetrieveContext(query, mmq.RetrieveOptions{
 		Limit:      limit,
@@ -161,6 +217,11 @@ func runQuery(cmd *cobra.Command, args []string) error {
 		Collection: collectionFlag,
 		Strategy:   mmq.StrategyHybrid,
 		Rerank:     false, // MockLLM 不支持重排
+		Profile:    profileName,
+		Fields:     fields,
+
+		Language:       langFilter,
+		PreferLanguage: preferLang,
 	})
 
 	if err != nil {
@@ -171,6 +232,8 @@ func runQuery(cmd *cobra.Command, args []string) error {
 	searchResults := make([]mmq.SearchResult, len(results))
 	for i, ctx := range results {
 		searchResults[i] = mmq.SearchResult{
+			ID:         getMetadata(ctx.Metadata, "id"),
+			QueryID:    ctx.QueryID,
 			Score:      ctx.Relevance,
 			Title:      getMetadata(ctx.Metadata, "title"),
 			Content:    ctx.Text,
@@ -187,9 +250,86 @@ func runQuery(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Printf("Found %d result(s) using hybrid search\n\n", len(searchResults))
+	printQueryID(searchResults)
 	return format.OutputSearchResults(searchResults, format.Format(outputFormat), fullContent)
 }
 
+// runFederatedSearch 在多个--db上执行搜索并按RRF融合结果
+func runFederatedSearch(query, mode string) error {
+	fed, err := getFederation()
+	if err != nil {
+		return err
+	}
+	defer fed.Close()
+
+	limit := numResults
+	if showAll {
+		limit = 0
+	}
+
+	fields, err := parseFieldFilters(fieldFlags)
+	if err != nil {
+		return err
+	}
+
+	opts := mmq.SearchOptions{
+		Limit:      limit,
+		MinScore:   minScore,
+		Collection: collectionFlag,
+		Profile:    profileName,
+		Fields:     fields,
+
+		Language:       langFilter,
+		PreferLanguage: preferLang,
+	}
+
+	var results []mmq.SearchResult
+	switch mode {
+	case "vsearch":
+		results, err = fed.VectorSearch(query, opts)
+	case "query":
+		results, err = fed.HybridSearch(query, opts)
+	default:
+		results, err = fed.Search(query, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("federated %s failed: %w", mode, err)
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No results found")
+		return nil
+	}
+
+	fmt.Printf("Found %d result(s) across %d indexes\n\n", len(results), len(fed.Indexes()))
+	return format.OutputSearchResults(results, format.Format(outputFormat), fullContent)
+}
+
+// printQueryID 记录查询日志时输出查询ID（用于 mmq feedback）
+func printQueryID(results []mmq.SearchResult) {
+	if outputFormat != "text" || len(results) == 0 || results[0].QueryID == "" {
+		return
+	}
+	fmt.Printf("Query ID: %s\n\n", results[0].QueryID)
+}
+
+// parseFieldFilters 解析 --field key=value
+func parseFieldFilters(flags []string) (map[string]string, error) {
+	if len(flags) == 0 {
+		return nil, nil
+	}
+
+	fields := make(map[string]string, len(flags))
+	for _, flag := range flags {
+		key, value, ok := strings.Cut(flag, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --field %q: expected key=value", flag)
+		}
+		fields[key] = value
+	}
+	return fields, nil
+}
+
 // getMetadata 从元数据中获取字符串值
 func getMetadata(metadata map[string]interface{}, key string) string {
 	if val, ok := metadata[key]; ok {
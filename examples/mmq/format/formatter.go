@@ -301,6 +301,9 @@ func outputSearchText(results []mmq.SearchResult, full bool) error {
 	for i, r := range results {
 		fmt.Printf("[%d] Score: %.4f | %s/%s\n", i+1, r.Score, r.Collection, r.Path)
 		fmt.Printf("    Title: %s\n", r.Title)
+		if r.Index != "" {
+			fmt.Printf("    Index: %s\n", r.Index)
+		}
 
 		if full {
 			fmt.Printf("    Content:\n")
@@ -323,6 +326,9 @@ func outputSearchMarkdown(results []mmq.SearchResult, full bool) error {
 	for i, r := range results {
 		fmt.Printf("## %d. %s (%.4f)\n\n", i+1, r.Title, r.Score)
 		fmt.Printf("**Path:** %s/%s  \n", r.Collection, r.Path)
+		if r.Index != "" {
+			fmt.Printf("**Index:** %s  \n", r.Index)
+		}
 		fmt.Printf("**Source:** %s\n\n", r.Source)
 
 		if full {
@@ -341,7 +347,7 @@ func outputSearchCSV(results []mmq.SearchResult) error {
 	w := csv.NewWriter(os.Stdout)
 	defer w.Flush()
 
-	w.Write([]string{"Rank", "Score", "Collection", "Path", "Title", "Source", "Snippet"})
+	w.Write([]string{"Rank", "Score", "Collection", "Path", "Title", "Source", "Index", "Snippet"})
 
 	for i, r := range results {
 		w.Write([]string{
@@ -351,6 +357,7 @@ func outputSearchCSV(results []mmq.SearchResult) error {
 			r.Path,
 			r.Title,
 			r.Source,
+			r.Index,
 			r.Snippet,
 		})
 	}
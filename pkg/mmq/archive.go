@@ -0,0 +1,122 @@
+package mmq
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+// Export 将数据库导出为可移植的tar归档
+// 归档包含manifest.json以及每部分一个JSONL文件；路径以.gz/.tgz结尾时使用gzip压缩
+func (m *MMQ) Export(path string, opts ArchiveOptions) (ArchiveStats, error) {
+	archiver, err := m.archiver()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(expandPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	var gz *gzip.Writer
+	if isGzipPath(path) {
+		gz = gzip.NewWriter(f)
+		w = gz
+	}
+
+	stats, err := archiver.Export(w, toArchiveParts(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return nil, fmt.Errorf("failed to finalize archive: %w", err)
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close archive: %w", err)
+	}
+
+	return ArchiveStats(stats), nil
+}
+
+// Import 从Export生成的归档导入数据（自动识别gzip压缩）
+// 已存在的集合、上下文、文档和同ID记忆会被覆盖；
+// 不导入向量时，记忆的嵌入使用当前模型重新生成，文档需再次运行GenerateEmbeddings
+func (m *MMQ) Import(path string, opts ArchiveOptions) (ArchiveStats, error) {
+	archiver, err := m.archiver()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(expandPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip archive: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	stats, err := archiver.Import(r, store.ImportOptions{
+		ArchiveParts: toArchiveParts(opts),
+		EmbedFunc: func(text string) ([]float32, error) {
+			return m.embedding.Generate(text, false)
+		},
+	})
+
+	return ArchiveStats(stats), err
+}
+
+// Backup 使用SQLite在线备份API生成数据库快照
+// 备份期间其他goroutine和进程可以继续读写
+func (m *MMQ) Backup(ctx context.Context, path string) error {
+	archiver, err := m.archiver()
+	if err != nil {
+		return err
+	}
+	return archiver.Backup(ctx, expandPath(path))
+}
+
+// archiver 返回支持归档的存储后端（目前为SQLite后端）
+func (m *MMQ) archiver() (store.Archiver, error) {
+	archiver, ok := m.store.(store.Archiver)
+	if !ok {
+		return nil, fmt.Errorf("archive: %w", store.ErrNotSupported)
+	}
+	return archiver, nil
+}
+
+func toArchiveParts(opts ArchiveOptions) store.ArchiveParts {
+	return store.ArchiveParts{
+		Collections: opts.Collections,
+		Contexts:    opts.Contexts,
+		Documents:   opts.Documents,
+		Embeddings:  opts.Embeddings,
+		Memories:    opts.Memories,
+	}
+}
+
+func isGzipPath(path string) bool {
+	return strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".tgz")
+}
@@ -0,0 +1,540 @@
+package mmq
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/memory"
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+// newArchiveSource 创建包含集合、上下文、文档、嵌入和记忆的测试库
+func newArchiveSource(t *testing.T, dbPath string) *MMQ {
+	t.Helper()
+
+	m, err := NewWithDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.CreateCollection("notes", t.TempDir(), CollectionOptions{Mask: "**/*.md"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddContext("qmd://notes", "Personal engineering notes"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		err := m.IndexDocument(Document{
+			Collection: "notes",
+			Path:       fmt.Sprintf("doc-%d.md", i),
+			Title:      fmt.Sprintf("Doc %d", i),
+			Content:    fmt.Sprintf("Document %d explains SQLite backups and archives.", i),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.StoreMemory(Memory{
+		Type:       MemoryTypePreference,
+		Content:    "User prefers dark mode",
+		Tags:       []string{"ui"},
+		Timestamp:  time.Now(),
+		Importance: 0.9,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.StoreMemory(Memory{
+		Type:      MemoryTypeFact,
+		Content:   "The project uses SQLite",
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+// memoryIDOfType 返回指定类型的第一条记忆ID
+func memoryIDOfType(t *testing.T, m *MMQ, memType MemoryType) string {
+	t.Helper()
+
+	memories, err := m.GetMemoryManager().GetByType(memory.MemoryType(memType))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(memories) == 0 {
+		t.Fatalf("No memory of type %s", memType)
+	}
+	return memories[0].ID
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	src := newArchiveSource(t, filepath.Join(tmpDir, "src.db"))
+	defer src.Close()
+
+	prefID := memoryIDOfType(t, src, MemoryTypePreference)
+	if err := src.store.TouchMemories([]string{prefID}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"export.tar", "export.tar.gz"} {
+		t.Run(name, func(t *testing.T) {
+			archivePath := filepath.Join(tmpDir, name)
+
+			opts := DefaultArchiveOptions()
+			opts.Embeddings = true
+
+			stats, err := src.Export(archivePath, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Logf("Exported: %v", stats)
+
+			if stats["documents.jsonl"] != 3 || stats["memories.jsonl"] != 2 {
+				t.Errorf("Unexpected export stats: %v", stats)
+			}
+
+			dst, err := NewWithDB(filepath.Join(t.TempDir(), "dst.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dst.Close()
+
+			imported, err := dst.Import(archivePath, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Logf("Imported: %v", imported)
+
+			status, err := dst.Status()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.TotalDocuments != 3 {
+				t.Errorf("Expected 3 documents, got %d", status.TotalDocuments)
+			}
+			if status.NeedsEmbedding != 0 {
+				t.Errorf("Expected embeddings to be imported, %d documents need embedding", status.NeedsEmbedding)
+			}
+
+			coll, err := dst.GetCollection("notes")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if coll.Mask != "**/*.md" {
+				t.Errorf("Expected mask **/*.md, got %s", coll.Mask)
+			}
+
+			ctx, err := dst.GetContext("qmd://notes")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ctx.Content != "Personal engineering notes" {
+				t.Errorf("Unexpected context: %s", ctx.Content)
+			}
+
+			mem, err := dst.GetMemoryByID(prefID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mem.Content != "User prefers dark mode" || len(mem.Tags) != 1 || mem.Importance != 0.9 {
+				t.Errorf("Memory not preserved: %+v", mem)
+			}
+			if mem.AccessCount != 1 || mem.LastAccessed == nil {
+				t.Errorf("Access statistics not preserved: %+v", mem)
+			}
+
+			// 导入的文档可被全文和向量搜索
+			results, err := dst.Search("backups", SearchOptions{Limit: 5})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 3 {
+				t.Errorf("Expected 3 search results, got %d", len(results))
+			}
+
+			vresults, err := dst.VectorSearch("SQLite archives", SearchOptions{Limit: 5})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(vresults) == 0 {
+				t.Error("Expected vector search results from imported embeddings")
+			}
+
+			// 重复导入是幂等的
+			if _, err := dst.Import(archivePath, opts); err != nil {
+				t.Fatal(err)
+			}
+			count, err := dst.CountMemories()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 2 {
+				t.Errorf("Expected 2 memories after re-import, got %d", count)
+			}
+		})
+	}
+}
+
+func TestExportSelectedParts(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	src := newArchiveSource(t, filepath.Join(tmpDir, "src.db"))
+	defer src.Close()
+
+	archivePath := filepath.Join(tmpDir, "memories.tar")
+	stats, err := src.Export(archivePath, ArchiveOptions{Memories: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := stats["documents.jsonl"]; ok {
+		t.Error("Documents should not be exported")
+	}
+
+	dst, err := NewWithDB(filepath.Join(tmpDir, "dst.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	// 未导出向量，导入时重新生成记忆嵌入
+	if _, err := dst.Import(archivePath, DefaultArchiveOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := dst.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.TotalDocuments != 0 {
+		t.Errorf("Expected no documents, got %d", status.TotalDocuments)
+	}
+
+	memories, err := dst.RecallMemories("dark mode", RecallOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(memories) == 0 {
+		t.Error("Expected imported memories to be recallable")
+	}
+
+	// 导入时排除记忆
+	dst2, err := NewWithDB(filepath.Join(tmpDir, "dst2.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst2.Close()
+
+	if _, err := dst2.Import(archivePath, ArchiveOptions{Collections: true}); err != nil {
+		t.Fatal(err)
+	}
+	count, err := dst2.CountMemories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("Expected memories to be excluded, got %d", count)
+	}
+}
+
+func TestBackupWhileWriting(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	src := newArchiveSource(t, filepath.Join(tmpDir, "src.db"))
+	defer src.Close()
+
+	factID := memoryIDOfType(t, src, MemoryTypeFact)
+
+	// 备份期间持续写入
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			src.IndexDocument(Document{
+				Collection: "live",
+				Path:       fmt.Sprintf("live-%d.md", i),
+				Title:      "Live",
+				Content:    fmt.Sprintf("Live document %d", i),
+			})
+		}
+	}()
+
+	backupPath := filepath.Join(tmpDir, "backup.db")
+	if err := src.Backup(context.Background(), backupPath); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	snap, err := NewWithDB(backupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Close()
+
+	status, err := snap.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Backup contains %d documents", status.TotalDocuments)
+
+	if status.TotalDocuments < 3 {
+		t.Errorf("Expected at least 3 documents in backup, got %d", status.TotalDocuments)
+	}
+
+	mem, err := snap.GetMemoryByID(factID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mem.Content != "The project uses SQLite" {
+		t.Errorf("Unexpected memory content: %s", mem.Content)
+	}
+
+	// 已取消的ctx应中止备份
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := src.Backup(ctx, filepath.Join(tmpDir, "cancelled.db")); err == nil {
+		t.Error("Expected error for cancelled context")
+	}
+}
+
+// TestExportImportFeatureTables 各功能新增的表随所属部分一起导出和导入
+func TestExportImportFeatureTables(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	src := newArchiveSource(t, filepath.Join(tmpDir, "src.db"))
+	defer src.Close()
+
+	if err := src.SaveRankingProfile(RankingProfile{Name: "fresh", RecencyHalfLife: 24 * time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SetCollectionProfile("notes", "fresh"); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SetCollectionQuantization("notes", QuantizationInt8); err != nil {
+		t.Fatal(err)
+	}
+
+	detail, err := src.GetDocumentByPath("notes/doc-0.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.GetBackend().SaveFeedback(store.Feedback{QueryID: "q1", Query: "zookeeper", DocPath: "notes/doc-0.md", Signal: "used", Weight: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.GetBackend().SaveDocumentSummary(detail.Hash, "Mentions zookeeper quorum recovery.", "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := src.IndexStructured([]byte(faqCSV), StructuredOptions{Collection: "notes", Path: "faq.csv"}); err != nil {
+		t.Fatal(err)
+	}
+
+	chat := store.Namespace{User: "alice", Session: "chat"}
+	err = src.GetBackend().SaveConversationSummary(store.ConversationSummary{
+		Namespace: chat, Summary: "Alice asked about the weather.", ThroughID: "m-3", Covered: 3, Tokens: 7, UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facts := memory.NewFactMemory(src.GetMemoryManager())
+	if err := facts.SetFunctional("lives_in"); err != nil {
+		t.Fatal(err)
+	}
+	for i, city := range []string{"Berlin", "Paris"} {
+		err := facts.StoreFact(memory.Fact{Subject: "alice", Predicate: "lives_in", Object: city, Source: "chat",
+			Timestamp: time.Now().Add(time.Duration(i-2) * time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cursorAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := src.GetBackend().SaveReflectionCursor(store.ReflectionCursor{Namespace: chat, Through: cursorAt, ThroughIDs: []string{"m-1", "m-2"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 被遗忘策略归档的记忆（保留嵌入、召回统计和归档时间）
+	manager := src.GetMemoryManager()
+	if err := manager.Store(memory.Memory{Type: memory.MemoryTypeEpisodic, Content: "a forgotten standup", Timestamp: time.Now(), Importance: 0.1}); err != nil {
+		t.Fatal(err)
+	}
+	page, err := manager.List(memory.ListOptions{MemoryTypes: []memory.MemoryType{memory.MemoryTypeEpisodic}})
+	if err != nil || len(page.Memories) != 1 {
+		t.Fatalf("Expected one episode, got %+v, %v", page, err)
+	}
+	forgotten := page.Memories[0].ID
+	archivedAt := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	if err := src.GetBackend().TouchMemories([]string{forgotten}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.GetBackend().ArchiveMemories([]string{forgotten}, archivedAt); err != nil {
+		t.Fatal(err)
+	}
+
+	archivePath := filepath.Join(tmpDir, "full.tar")
+	stats, err := src.Export(archivePath, DefaultArchiveOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Exported: %v", stats)
+
+	dst, err := NewWithDB(filepath.Join(tmpDir, "dst.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	if _, err := dst.Import(archivePath, DefaultArchiveOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := dst.GetRankingProfile("fresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.RecencyHalfLife != 24*time.Hour {
+		t.Errorf("Ranking profile not preserved: %+v", profile)
+	}
+	if bound, _ := dst.GetCollectionProfile("notes"); bound != "fresh" {
+		t.Errorf("Expected notes to stay bound to fresh, got %q", bound)
+	}
+
+	if mode, _ := dst.GetCollectionQuantization("notes"); mode != QuantizationInt8 {
+		t.Errorf("Expected notes to stay int8, got %q", mode)
+	}
+
+	// 相关性反馈
+	if feedback, _ := dst.GetBackend().ListFeedback(time.Time{}); len(feedback) != 1 || feedback[0].DocPath != "notes/doc-0.md" || feedback[0].Weight != 2 {
+		t.Errorf("Feedback not preserved: %+v", feedback)
+	}
+
+	// 结构化行记录及字段过滤
+	if record, err := dst.GetRecord("notes/faq.csv#3"); err != nil || record.Fields["category"] != "billing" {
+		t.Errorf("Record not preserved: %+v, %v", record, err)
+	}
+	if results, _ := dst.Search("refund", SearchOptions{Limit: 5, Fields: map[string]string{"category": "billing"}}); len(results) != 1 {
+		t.Errorf("Expected the imported record fields to filter search, got %d results", len(results))
+	}
+
+	// 摘要及其全文索引
+	if summary, _ := dst.GetDocumentSummary("notes/doc-0.md"); summary == nil || summary.Summary != "Mentions zookeeper quorum recovery." {
+		t.Errorf("Document summary not preserved: %+v", summary)
+	}
+	if results, _ := dst.Search("zookeeper", SearchOptions{Limit: 5}); len(results) != 1 {
+		t.Errorf("Expected the imported summary to be searchable, got %d results", len(results))
+	}
+
+	// 会话摘要
+	conv, err := dst.GetBackend().GetConversationSummary(chat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv == nil || conv.Summary != "Alice asked about the weather." || conv.ThroughID != "m-3" || conv.Covered != 3 || conv.Tokens != 7 {
+		t.Errorf("Conversation summary not preserved: %+v", conv)
+	}
+
+	// 反思进度
+	cursor, err := dst.GetBackend().GetReflectionCursor(chat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor == nil || !cursor.Through.Equal(cursorAt) || len(cursor.ThroughIDs) != 2 {
+		t.Errorf("Reflection cursor not preserved: %+v", cursor)
+	}
+
+	// 归档记忆
+	archived, total, err := dst.GetMemoryManager().ListArchived(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || archived[0].ID != forgotten || !archived[0].ArchivedAt.Equal(archivedAt) || archived[0].AccessCount != 1 {
+		t.Errorf("Archived memory not preserved: %+v", archived)
+	}
+	var hasEmbedding bool
+	if err := dst.GetStore().DB().QueryRow(
+		"SELECT embedding IS NOT NULL FROM archived_memories WHERE id = ?", forgotten).Scan(&hasEmbedding); err != nil || !hasEmbedding {
+		t.Errorf("Expected the archived memory to keep an embedding: %v", err)
+	}
+	if err := dst.GetMemoryManager().RestoreArchived(forgotten); err != nil {
+		t.Fatal(err)
+	}
+
+	// 事实历史与函数型谓词
+	dstFacts := memory.NewFactMemory(dst.GetMemoryManager())
+	history, err := dstFacts.History("alice", "lives_in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Current() || history[0].Source != "chat" || !history[1].Current() {
+		t.Errorf("Fact history not preserved: %+v", history)
+	}
+	if err := dstFacts.StoreFact(memory.Fact{Subject: "alice", Predicate: "lives_in", Object: "Rome"}); err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := dstFacts.Match(memory.FactPattern{Subject: "alice", Predicate: "lives_in"}); len(current) != 1 || current[0].Object != "Rome" {
+		t.Errorf("Expected lives_in to stay functional after import, got %+v", current)
+	}
+}
+
+func TestExportImportQuantizedVectors(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	src := newArchiveSource(t, filepath.Join(tmpDir, "src.db"))
+	defer src.Close()
+	if err := src.SetCollectionQuantization("notes", QuantizationInt8); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultArchiveOptions()
+	opts.Embeddings = true
+	archivePath := filepath.Join(tmpDir, "quantized.tar")
+	if _, err := src.Export(archivePath, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// 导入到全新的数据库：量化方式先于向量导入，向量直接按int8编码
+	dst, err := NewWithDB(filepath.Join(tmpDir, "dst.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if _, err := dst.Import(archivePath, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	var total, int8Coded, float32Kept int
+	err = dst.GetStore().DB().QueryRow(`
+		SELECT COUNT(*), COUNT(q.int8), COUNT(cv.embedding)
+		FROM content_vectors cv
+		LEFT JOIN vector_codes q ON q.hash = cv.hash AND q.seq = cv.seq
+	`).Scan(&total, &int8Coded, &float32Kept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total == 0 || int8Coded != total || float32Kept != 0 {
+		t.Errorf("Expected all %d imported vectors coded as int8, got int8=%d float32=%d", total, int8Coded, float32Kept)
+	}
+
+	results, err := dst.VectorSearch("SQLite backups", SearchOptions{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 {
+		t.Error("Expected vector search over the imported int8 vectors")
+	}
+}
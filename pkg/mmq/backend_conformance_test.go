@@ -0,0 +1,1389 @@
+package mmq
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/memory"
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+// 存储后端一致性测试：同一组用例在每个后端上运行，保证行为一致
+//
+// 新增后端时在conformanceBackends中注册即可。
+
+type backendFactory struct {
+	name string
+	open func(t *testing.T) (*MMQ, error)
+}
+
+func conformanceBackends() []backendFactory {
+	return []backendFactory{
+		{"sqlite-file", func(t *testing.T) (*MMQ, error) {
+			return NewWithDB(filepath.Join(t.TempDir(), "test.db"))
+		}},
+		{"sqlite-memory", func(t *testing.T) (*MMQ, error) {
+			return NewWithDB(":memory:")
+		}},
+		{"in-memory", func(t *testing.T) (*MMQ, error) {
+			return NewInMemory()
+		}},
+	}
+}
+
+var conformanceCases = []struct {
+	name string
+	run  func(t *testing.T, m *MMQ)
+}{
+	{"Documents", conformDocuments},
+	{"DocumentQueries", conformDocumentQueries},
+	{"FullTextSearch", conformFullTextSearch},
+	{"VectorAndHybridSearch", conformVectorSearch},
+	{"Collections", conformCollections},
+	{"RankingProfiles", conformRankingProfiles},
+	{"Contexts", conformContexts},
+	{"DocumentSummaries", conformDocumentSummaries},
+	{"QueryLog", conformQueryLog},
+	{"Feedback", conformFeedback},
+	{"StructuredRecords", conformStructuredRecords},
+	{"Quantization", conformQuantization},
+	{"Languages", conformLanguages},
+	{"Memories", conformMemories},
+	{"ConversationSessions", conformConversationSessions},
+	{"MemoryNamespaces", conformMemoryNamespaces},
+	{"MemoryFullText", conformMemoryFullText},
+	{"MemoryFilters", conformMemoryFilters},
+}
+
+func TestBackendConformance(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			for _, tc := range conformanceCases {
+				t.Run(tc.name, func(t *testing.T) {
+					m, err := backend.open(t)
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer m.Close()
+
+					tc.run(t, m)
+				})
+			}
+		})
+	}
+}
+
+func TestInMemoryBackendUnsupported(t *testing.T) {
+	m, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	_, err = m.Export(filepath.Join(t.TempDir(), "out.tar"), DefaultArchiveOptions())
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestSQLiteMemoryIsEphemeral(t *testing.T) {
+	m1, err := NewWithDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m1.Close()
+
+	if err := m1.IndexDocument(Document{Collection: "c", Path: "a.md", Content: "ephemeral"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 每个实例都是独立的临时库
+	m2, err := NewWithDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m2.Close()
+
+	status, err := m2.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.TotalDocuments != 0 {
+		t.Errorf("Expected empty database, got %d documents", status.TotalDocuments)
+	}
+}
+
+func indexDocs(t *testing.T, m *MMQ, docs ...Document) {
+	t.Helper()
+	for _, doc := range docs {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func conformDocuments(t *testing.T, m *MMQ) {
+	indexDocs(t, m,
+		Document{Collection: "notes", Path: "a.md", Title: "A", Content: "shared content"},
+		Document{Collection: "notes", Path: "b.md", Title: "B", Content: "shared content"},
+		Document{Collection: "notes", Path: "c.md", Title: "C", Content: "unique content"},
+	)
+
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.TotalDocuments != 3 {
+		t.Errorf("Expected 3 documents, got %d", status.TotalDocuments)
+	}
+	// 相同内容只需嵌入一次
+	if status.NeedsEmbedding != 2 {
+		t.Errorf("Expected 2 distinct contents needing embedding, got %d", status.NeedsEmbedding)
+	}
+	if len(status.Collections) != 1 || status.Collections[0] != "notes" {
+		t.Errorf("Unexpected collections: %v", status.Collections)
+	}
+
+	doc, err := m.GetDocument("a.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Content != "shared content" || doc.Title != "A" {
+		t.Errorf("Unexpected document: %+v", doc)
+	}
+
+	// 同路径重新索引更新文档
+	indexDocs(t, m, Document{Collection: "notes", Path: "a.md", Title: "A2", Content: "updated content"})
+	doc, err = m.GetDocument("a.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title != "A2" || doc.Content != "updated content" {
+		t.Errorf("Document not updated: %+v", doc)
+	}
+
+	// 软删除
+	if err := m.DeleteDocument("c.md"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetDocument("c.md"); err == nil {
+		t.Error("Expected deleted document to be hidden")
+	}
+	if err := m.DeleteDocument("c.md"); err == nil {
+		t.Error("Expected error deleting missing document")
+	}
+
+	// 删除后重新索引恢复
+	indexDocs(t, m, Document{Collection: "notes", Path: "c.md", Title: "C", Content: "unique content"})
+
+	status, err = m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.TotalDocuments != 3 {
+		t.Errorf("Expected 3 documents after restore, got %d", status.TotalDocuments)
+	}
+}
+
+func conformDocumentQueries(t *testing.T, m *MMQ) {
+	indexDocs(t, m,
+		Document{Collection: "docs", Path: "guide/intro.md", Title: "Intro", Content: "introduction"},
+		Document{Collection: "docs", Path: "guide/advanced.md", Title: "Advanced", Content: "advanced topics"},
+		Document{Collection: "docs", Path: "api.md", Title: "API", Content: "api reference"},
+		Document{Collection: "blog", Path: "post.md", Title: "Post", Content: "a blog post"},
+	)
+
+	all, err := m.ListDocuments("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 4 {
+		t.Fatalf("Expected 4 documents, got %d", len(all))
+	}
+	if all[0].Collection != "blog" || all[1].Path != "api.md" {
+		t.Errorf("Expected documents ordered by collection and path, got %s/%s, %s/%s",
+			all[0].Collection, all[0].Path, all[1].Collection, all[1].Path)
+	}
+
+	guide, err := m.ListDocuments("docs", "guide/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(guide) != 2 {
+		t.Errorf("Expected 2 documents under guide/, got %d", len(guide))
+	}
+
+	detail, err := m.GetDocumentByPath("qmd://docs/api.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detail.Content != "api reference" || !strings.HasPrefix(detail.DocID, "#") {
+		t.Errorf("Unexpected detail: %+v", detail)
+	}
+
+	byID, err := m.GetDocumentByID(detail.DocID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byID.Path != "api.md" {
+		t.Errorf("Expected api.md, got %s", byID.Path)
+	}
+
+	globbed, err := m.GetMultipleDocuments("docs/guide/*.md", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(globbed) != 2 {
+		t.Errorf("Expected 2 glob matches, got %d", len(globbed))
+	}
+
+	listed, err := m.GetMultipleDocuments("docs/api.md, blog/post.md, docs/missing.md", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 2 {
+		t.Errorf("Expected 2 listed documents, got %d", len(listed))
+	}
+
+	small, err := m.GetMultipleDocuments("docs/**/*.md", 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range small {
+		if len(d.Content) > 12 {
+			t.Errorf("Document %s exceeds maxBytes", d.Path)
+		}
+	}
+}
+
+func conformFullTextSearch(t *testing.T, m *MMQ) {
+	indexDocs(t, m,
+		Document{Collection: "tech", Path: "golang.md", Title: "Go", Content: "Go is a compiled language with goroutines."},
+		Document{Collection: "tech", Path: "python.md", Title: "Python", Content: "Python is an interpreted language."},
+		Document{Collection: "tech", Path: "rust.md", Title: "Rust", Content: "Rust is a systems language without goroutines."},
+		Document{Collection: "misc", Path: "cooking.md", Title: "Cooking", Content: "A recipe for pasta."},
+	)
+
+	results, err := m.Search("language", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Errorf("Expected 3 results for 'language', got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Score <= 0 || r.Score > 1 {
+			t.Errorf("Score out of range: %f", r.Score)
+		}
+	}
+
+	// 多个词为AND语义
+	results, err = m.Search("compiled goroutines", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != "golang.md" {
+		t.Errorf("Expected only golang.md, got %v", searchPaths(results))
+	}
+
+	// 前缀匹配
+	results, err = m.Search("interpret", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != "python.md" {
+		t.Errorf("Expected prefix match on python.md, got %v", searchPaths(results))
+	}
+
+	// 文件路径权重最高
+	results, err = m.Search("rust", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 || results[0].Path != "rust.md" {
+		t.Errorf("Expected rust.md first, got %v", searchPaths(results))
+	}
+
+	// 集合过滤与数量限制
+	results, err = m.Search("language", SearchOptions{Limit: 10, Collection: "misc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results in misc, got %d", len(results))
+	}
+
+	results, err = m.Search("language", SearchOptions{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected limit of 2, got %d", len(results))
+	}
+
+	// 删除的文档不再被搜索到
+	if err := m.DeleteDocument("python.md"); err != nil {
+		t.Fatal(err)
+	}
+	results, err = m.Search("interpreted", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected deleted document to be excluded, got %v", searchPaths(results))
+	}
+
+	results, err = m.Search("   ", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results for empty query, got %d", len(results))
+	}
+}
+
+func conformDocumentSummaries(t *testing.T, m *MMQ) {
+	indexDocs(t, m,
+		Document{Collection: "kb", Path: "a.md", Title: "A", Content: "Deployment checklist for the API gateway."},
+		Document{Collection: "kb", Path: "b.md", Title: "B", Content: "Deployment checklist for the API gateway."},
+		Document{Collection: "kb", Path: "c.md", Title: "C", Content: "Notes about database migrations."},
+	)
+
+	// 相同内容只生成一次摘要
+	n, err := m.GenerateSummaries(SummarizeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 summaries for 2 distinct contents, got %d", n)
+	}
+	if n, _ := m.GenerateSummaries(SummarizeOptions{}); n != 0 {
+		t.Errorf("Expected no work on second run, got %d", n)
+	}
+
+	summary, err := m.GetDocumentSummary("kb/b.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary == nil || summary.Summary == "" || summary.Model == "" {
+		t.Fatalf("Expected stored summary with model, got %+v", summary)
+	}
+
+	// 摘要参与全文检索：只出现在摘要中的词也能命中文档
+	if err := m.GetBackend().SaveDocumentSummary(summary.Hash, "Covers blue-green rollout and canary releases.", "test"); err != nil {
+		t.Fatal(err)
+	}
+	results, err := m.Search("canary", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := searchPaths(results); len(got) != 2 || got[0] != "kb/a.md" || got[1] != "kb/b.md" {
+		t.Errorf("Expected both documents sharing the summary, got %v", got)
+	}
+
+	// 摘要与正文是不同语料，BM25分数按各自语料归一化后再合并：
+	// 正文命中排在前面，摘要命中的分数与正文处于同一量级
+	if err := m.GetBackend().SaveDocumentSummary(summary.Hash, "Mentions migrations in passing.", "test"); err != nil {
+		t.Fatal(err)
+	}
+	results, err = m.Search("migrations", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := searchPaths(results); len(got) != 3 || got[0] != "kb/c.md" {
+		t.Fatalf("Expected the body match first, got %v", got)
+	}
+	if results[2].Score < results[0].Score/2 {
+		t.Errorf("Expected summary hits on the body score scale, got %.3g vs %.3g", results[2].Score, results[0].Score)
+	}
+
+	// 内容变化后摘要失效并重新生成
+	indexDocs(t, m, Document{Collection: "kb", Path: "c.md", Title: "C", Content: "Notes about schema migrations and rollbacks."})
+	if summary, _ := m.GetDocumentSummary("kb/c.md"); summary != nil {
+		t.Errorf("Expected stale summary to be ignored, got %+v", summary)
+	}
+	if n, _ := m.GenerateSummaries(SummarizeOptions{Collection: "kb"}); n != 1 {
+		t.Errorf("Expected changed document to be summarized again, got %d", n)
+	}
+	if summary, _ := m.GetDocumentSummary("kb/c.md"); summary == nil {
+		t.Error("Expected regenerated summary")
+	}
+}
+
+func conformQueryLog(t *testing.T, m *MMQ) {
+	indexDocs(t, m,
+		Document{Collection: "kb", Path: "a.md", Title: "A", Content: "Kubernetes deployment guide."},
+		Document{Collection: "kb", Path: "b.md", Title: "B", Content: "Unrelated gardening notes."},
+	)
+
+	// 默认不记录
+	results, err := m.Search("kubernetes", SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].QueryID != "" {
+		t.Fatalf("Expected unlogged result, got %+v", results)
+	}
+
+	m.SetQueryLogging(true)
+	results, err = m.Search("kubernetes", SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].QueryID == "" {
+		t.Fatalf("Expected QueryID on logged result, got %+v", results)
+	}
+
+	entry, err := m.GetQueryLog(results[0].QueryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Kind != QueryKindSearch || entry.Query != "kubernetes" || entry.Strategy != "fts" {
+		t.Errorf("Unexpected log entry %+v", entry)
+	}
+	if len(entry.Results) != 1 || entry.Results[0].Path != "kb/a.md" || entry.Results[0].ID != results[0].ID {
+		t.Errorf("Expected logged result kb/a.md, got %+v", entry.Results)
+	}
+	if entry.Timestamp.IsZero() || entry.Latency <= 0 {
+		t.Errorf("Expected timestamp and latency, got %v %v", entry.Timestamp, entry.Latency)
+	}
+	if _, err := m.GetQueryLog("missing"); err == nil {
+		t.Error("Expected error for unknown query ID")
+	}
+
+	// 保留期：清理早于保留期的日志
+	st := m.GetBackend()
+	if _, err := st.LogQuery(store.QueryLogEntry{Timestamp: time.Now().Add(-60 * 24 * time.Hour), Kind: QueryKindSearch, Query: "old"}); err != nil {
+		t.Fatal(err)
+	}
+	all, err := st.ListQueryLog(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].Query != "old" {
+		t.Fatalf("Expected 2 entries ordered by time, got %+v", all)
+	}
+	removed, err := m.PruneQueryLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 expired entry pruned, got %d", removed)
+	}
+}
+
+func conformFeedback(t *testing.T, m *MMQ) {
+	indexDocs(t, m,
+		Document{Collection: "kb", Path: "a.md", Title: "A", Content: "Kubernetes deployment guide."},
+		Document{Collection: "kb", Path: "b.md", Title: "B", Content: "Kubernetes cluster notes."},
+	)
+	m.SetQueryLogging(true)
+
+	results, err := m.Search("kubernetes", SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	queryID := results[0].QueryID
+
+	// 结果ID和路径都可以标识文档，重复反馈只保留一条
+	if err := m.RecordFeedback(queryID, results[0].ID, FeedbackUp); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RecordFeedback(queryID, "kb/"+results[0].Path, FeedbackUp); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RecordFeedback(queryID, "qmd://kb/"+results[1].Path, FeedbackDown); err != nil {
+		t.Fatal(err)
+	}
+
+	feedback, err := m.GetBackend().ListFeedback(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feedback) != 2 {
+		t.Fatalf("Expected 2 feedback entries, got %+v", feedback)
+	}
+	for _, f := range feedback {
+		if f.QueryID != queryID || f.Query != "kubernetes" || f.CreatedAt.IsZero() {
+			t.Errorf("Unexpected feedback %+v", f)
+		}
+		switch f.DocPath {
+		case "kb/" + results[0].Path:
+			if f.Signal != "up" || f.Weight != 1 {
+				t.Errorf("Unexpected up feedback %+v", f)
+			}
+		case "kb/" + results[1].Path:
+			if f.Signal != "down" || f.Weight != -1 {
+				t.Errorf("Unexpected down feedback %+v", f)
+			}
+		default:
+			t.Errorf("Unexpected feedback path %s", f.DocPath)
+		}
+	}
+
+	recent, err := m.GetBackend().ListFeedback(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 0 {
+		t.Errorf("Expected no feedback after since, got %d", len(recent))
+	}
+}
+
+func conformStructuredRecords(t *testing.T, m *MMQ) {
+	if err := m.CreateCollection("kb", "/tmp/kb", CollectionOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.IndexStructured([]byte(faqCSV), StructuredOptions{Collection: "kb", Path: "faq.csv"}); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := m.FindRecords("", map[string]string{"category": "BILLING"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Row != 3 || records[1].Row != 5 || records[0].SourcePath != "faq.csv" {
+		t.Fatalf("Expected billing rows on lines 3 and 5, got %+v", records)
+	}
+
+	both, err := m.FindRecords("kb", map[string]string{"category": "billing", "question": "can i get a refund?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(both) != 1 || both[0].Path != "faq.csv#3" {
+		t.Errorf("Expected one row matching both fields, got %+v", both)
+	}
+
+	// 行记录随集合改名，删除集合后不再返回
+	if err := m.RenameCollection("kb", "help"); err != nil {
+		t.Fatal(err)
+	}
+	record, err := m.GetRecord("help/faq.csv#2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Collection != "help" || record.Fields["category"] != "account" {
+		t.Errorf("Unexpected record after rename %+v", record)
+	}
+
+	if err := m.RemoveCollection("help"); err != nil {
+		t.Fatal(err)
+	}
+	records, err = m.FindRecords("", map[string]string{"category": "billing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Errorf("Expected no records after removing collection, got %+v", records)
+	}
+}
+
+func searchPaths(results []SearchResult) []string {
+	paths := make([]string, len(results))
+	for i, r := range results {
+		paths[i] = r.Collection + "/" + r.Path
+	}
+	return paths
+}
+
+func conformLanguages(t *testing.T, m *MMQ) {
+	indexMixedCorpus(t, m)
+
+	languages, err := m.DocumentLanguages("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"kb/zh/db.md": "zh", "kb/zh/go.md": "zh", "kb/en/db.md": "en", "kb/en/go.md": "en"}
+	for path, lang := range want {
+		if languages[path] != lang {
+			t.Errorf("Expected %s to be %q, got %q", path, lang, languages[path])
+		}
+	}
+
+	// 中文查询路由到双字切分的索引
+	results, err := m.Search("查询性能", SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != "zh/db.md" {
+		t.Errorf("Expected zh/db.md for a Chinese query, got %v", searchPaths(results))
+	}
+
+	results, err = m.Search("Go", SearchOptions{Limit: 5, Language: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != "en/go.md" {
+		t.Errorf("Expected only en/go.md with language filter, got %v", searchPaths(results))
+	}
+
+	// 改名后CJK索引随文档更新
+	if err := m.CreateCollection("kb", "/tmp/kb", CollectionOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RenameCollection("kb", "知识库"); err != nil {
+		t.Fatal(err)
+	}
+	results, err = m.Search("写入", SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Collection != "知识库" {
+		t.Errorf("Expected renamed document for a Chinese query, got %v", searchPaths(results))
+	}
+}
+
+func conformQuantization(t *testing.T, m *MMQ) {
+	if err := m.CreateCollection("vec", "/tmp/vec", CollectionOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	contents := []string{
+		"Vector databases store embeddings.",
+		"Cosine similarity compares vectors.",
+		"Unrelated text about gardening.",
+	}
+	for i, c := range contents {
+		indexDocs(t, m, Document{Collection: "vec", Path: fmt.Sprintf("doc%d.md", i), Title: fmt.Sprintf("Doc %d", i), Content: c})
+	}
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []Quantization{QuantizationBinary, QuantizationInt8, QuantizationNone} {
+		if err := m.SetCollectionQuantization("vec", mode); err != nil {
+			t.Fatal(err)
+		}
+		stats, err := m.QuantizeEmbeddings()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Vectors != 3 {
+			t.Errorf("[%s] Expected 3 vectors, got %+v", mode, stats)
+		}
+
+		results, err := m.VectorSearch(contents[1], SearchOptions{Limit: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 3 || results[0].Path != "doc1.md" || results[0].Score < 0.99 {
+			t.Errorf("[%s] Expected doc1.md first, got %v", mode, searchPaths(results))
+		}
+	}
+
+	// 量化设置随集合改名
+	if err := m.SetCollectionQuantization("vec", QuantizationInt8); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RenameCollection("vec", "vectors"); err != nil {
+		t.Fatal(err)
+	}
+	mode, err := m.GetCollectionQuantization("vectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode != QuantizationInt8 {
+		t.Errorf("Expected int8 after rename, got %q", mode)
+	}
+
+	// 之后写入的向量直接按集合设置量化
+	if _, err := m.QuantizeEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+	indexDocs(t, m, Document{Collection: "vectors", Path: "doc3.md", Title: "Doc 3", Content: "Approximate nearest neighbour search."})
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := m.QuantizeEmbeddings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Int8 != 4 || stats.BytesAfter != stats.BytesBefore {
+		t.Errorf("Expected new vectors stored as int8 on write, got %+v", stats)
+	}
+	results, err := m.VectorSearch("Approximate nearest neighbour search.", SearchOptions{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != "doc3.md" || results[0].Content == "" {
+		t.Errorf("Expected doc3.md with its body, got %+v", results)
+	}
+}
+
+func conformVectorSearch(t *testing.T, m *MMQ) {
+	contents := []string{
+		"Vector databases store embeddings.",
+		"Cosine similarity compares vectors.",
+		"Unrelated text about gardening.",
+	}
+	for i, c := range contents {
+		indexDocs(t, m, Document{Collection: "vec", Path: fmt.Sprintf("doc%d.md", i), Title: fmt.Sprintf("Doc %d", i), Content: c})
+	}
+
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.NeedsEmbedding != 0 {
+		t.Errorf("Expected all documents embedded, %d remaining", status.NeedsEmbedding)
+	}
+
+	// Mock嵌入是确定性的：与文档完全相同的查询应排第一
+	results, err := m.VectorSearch(contents[1], SearchOptions{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 vector results, got %d", len(results))
+	}
+	if results[0].Path != "doc1.md" {
+		t.Errorf("Expected doc1.md first, got %v", searchPaths(results))
+	}
+	if results[0].Score < 0.99 {
+		t.Errorf("Expected near-identical similarity, got %f", results[0].Score)
+	}
+
+	results, err = m.VectorSearch(contents[1], SearchOptions{Limit: 3, Collection: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results in other collection, got %d", len(results))
+	}
+
+	hybrid, err := m.HybridSearch("embeddings", SearchOptions{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hybrid) == 0 {
+		t.Fatal("Expected hybrid results")
+	}
+	if hybrid[0].Path != "doc0.md" {
+		t.Errorf("Expected doc0.md first in hybrid results, got %v", searchPaths(hybrid))
+	}
+
+	contexts, err := m.RetrieveContext("embeddings", RetrieveOptions{Limit: 2, Strategy: StrategyHybrid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contexts) == 0 {
+		t.Error("Expected retrieved contexts")
+	}
+}
+
+func conformCollections(t *testing.T, m *MMQ) {
+	if err := m.CreateCollection("work", "/tmp/work", CollectionOptions{Mask: "**/*.md"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.CreateCollection("work", "/tmp/work", CollectionOptions{}); err == nil {
+		t.Error("Expected error creating duplicate collection")
+	}
+
+	indexDocs(t, m,
+		Document{Collection: "work", Path: "a.md", Content: "alpha"},
+		Document{Collection: "work", Path: "b.md", Content: "beta"},
+	)
+
+	coll, err := m.GetCollection("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coll.DocCount != 2 || coll.Mask != "**/*.md" || coll.Path != "/tmp/work" {
+		t.Errorf("Unexpected collection: %+v", coll)
+	}
+
+	if err := m.RenameCollection("work", "job"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetCollection("work"); err == nil {
+		t.Error("Expected old collection name to be gone")
+	}
+	if _, err := m.GetDocumentByPath("job/a.md"); err != nil {
+		t.Errorf("Expected documents to move with rename: %v", err)
+	}
+
+	results, err := m.Search("job", SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected renamed collection in indexed file path, got %d results", len(results))
+	}
+
+	collections, err := m.ListCollections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collections) != 1 || collections[0].Name != "job" || collections[0].DocCount != 2 {
+		t.Errorf("Unexpected collections: %+v", collections)
+	}
+
+	if err := m.RemoveCollection("job"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RemoveCollection("job"); err == nil {
+		t.Error("Expected error removing missing collection")
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.TotalDocuments != 0 {
+		t.Errorf("Expected documents to be deactivated, got %d", status.TotalDocuments)
+	}
+}
+
+func conformRankingProfiles(t *testing.T, m *MMQ) {
+	if err := m.CreateCollection("kb", "/tmp/kb", CollectionOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	indexDocs(t, m,
+		Document{Collection: "kb", Path: "widget.md", Title: "Overview", Content: "General notes about the product."},
+		Document{Collection: "kb", Path: "notes.md", Title: "Notes", Content: "The widget is configured via the widget panel; widget settings persist."},
+	)
+
+	err := m.SaveRankingProfile(RankingProfile{
+		Name:             "body",
+		FieldWeights:     FieldWeights{Filepath: 0.1, Title: 1, Body: 10},
+		CollectionBoosts: map[string]float64{"kb": 1.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SaveRankingProfile(RankingProfile{}); err == nil {
+		t.Error("Expected error saving unnamed profile")
+	}
+
+	profile, err := m.GetRankingProfile("body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.CollectionBoosts["kb"] != 1.5 {
+		t.Errorf("Profile not round-tripped: %+v", profile)
+	}
+
+	if err := m.SetCollectionProfile("kb", "body"); err != nil {
+		t.Fatal(err)
+	}
+	results, err := m.Search("widget", SearchOptions{Limit: 10, Collection: "kb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Path != "notes.md" {
+		t.Errorf("Expected body-weighted ranking, got %v", searchPaths(results))
+	}
+
+	if err := m.RenameCollection("kb", "docs"); err != nil {
+		t.Fatal(err)
+	}
+	if bound, _ := m.GetCollectionProfile("docs"); bound != "body" {
+		t.Errorf("Expected binding to follow rename, got %q", bound)
+	}
+
+	if err := m.DeleteRankingProfile("body"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteRankingProfile("body"); err == nil {
+		t.Error("Expected error deleting missing profile")
+	}
+	if bound, _ := m.GetCollectionProfile("docs"); bound != "" {
+		t.Errorf("Expected binding removed with profile, got %q", bound)
+	}
+}
+
+func conformContexts(t *testing.T, m *MMQ) {
+	if err := m.CreateCollection("kb", "/tmp/kb", CollectionOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	missing, err := m.CheckMissingContexts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 2 {
+		t.Errorf("Expected global and collection contexts missing, got %v", missing)
+	}
+
+	for path, content := range map[string]string{
+		"/":                     "global",
+		"qmd://kb":              "knowledge base",
+		"qmd://kb/guides":       "guides folder",
+		"qmd://kb/guides/a.md":  "single file",
+		"qmd://other/elsewhere": "unrelated",
+	} {
+		if err := m.AddContext(path, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 已存在则更新
+	if err := m.AddContext("qmd://kb", "knowledge base v2"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := m.GetContext("qmd://kb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Content != "knowledge base v2" {
+		t.Errorf("Expected updated context, got %s", ctx.Content)
+	}
+
+	all, err := m.ListContexts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 5 || all[0].Path != "/" {
+		t.Errorf("Expected 5 contexts ordered by path, got %+v", all)
+	}
+
+	chain, err := m.GetContextsForPath("qmd://kb/guides/a.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chainPaths []string
+	for _, c := range chain {
+		chainPaths = append(chainPaths, c.Path)
+	}
+	expected := "/ qmd://kb qmd://kb/guides qmd://kb/guides/a.md"
+	if strings.Join(chainPaths, " ") != expected {
+		t.Errorf("Expected chain %q, got %q", expected, strings.Join(chainPaths, " "))
+	}
+
+	docContexts, err := m.GetDocumentContexts("kb", "guides/a.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docContexts) != 3 || docContexts[0].Path != "qmd://kb/guides/a.md" {
+		t.Errorf("Unexpected document contexts: %+v", docContexts)
+	}
+
+	missing, err = m.CheckMissingContexts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 0 {
+		t.Errorf("Expected no missing contexts, got %v", missing)
+	}
+
+	if err := m.RemoveContext("qmd://kb/guides"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RemoveContext("qmd://kb/guides"); err == nil {
+		t.Error("Expected error removing missing context")
+	}
+	if _, err := m.GetContext("qmd://kb/guides"); err == nil {
+		t.Error("Expected removed context to be gone")
+	}
+}
+
+func conformMemories(t *testing.T, m *MMQ) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	memories := []Memory{
+		{Type: MemoryTypeFact, Content: "Paris is the capital of France", Tags: []string{"geo"}, Timestamp: time.Now(), Importance: 0.9,
+			Metadata: map[string]interface{}{"source": "atlas", "page": 42}},
+		{Type: MemoryTypePreference, Content: "User likes green tea", Timestamp: time.Now()},
+		{Type: MemoryTypeFact, Content: "Old expired fact", Timestamp: past, ExpiresAt: &past},
+		{Type: MemoryTypeFact, Content: "Fresh temporary fact", Timestamp: time.Now(), ExpiresAt: &future},
+	}
+	for _, mem := range memories {
+		if err := m.StoreMemory(mem); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count, err := m.CountMemories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Errorf("Expected 4 memories, got %d", count)
+	}
+
+	recalled, err := m.RecallMemories("Paris is the capital of France", RecallOptions{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recalled) == 0 || recalled[0].Content != "Paris is the capital of France" {
+		t.Fatalf("Expected exact memory first, got %+v", recalled)
+	}
+
+	top := recalled[0]
+	if len(top.Tags) != 1 || top.Tags[0] != "geo" || top.Importance != 0.9 {
+		t.Errorf("Memory fields not preserved: %+v", top)
+	}
+	// 元数据按JSON存储，数字解码为float64
+	if top.Metadata["source"] != "atlas" || top.Metadata["page"] != float64(42) {
+		t.Errorf("Metadata not preserved: %v", top.Metadata)
+	}
+
+	typed, err := m.RecallMemories("tea", RecallOptions{Limit: 5, MemoryTypes: []MemoryType{MemoryTypePreference}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(typed) != 1 || typed[0].Type != MemoryTypePreference {
+		t.Errorf("Expected only preference memories, got %+v", typed)
+	}
+
+	byID, err := m.GetMemoryByID(top.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byID.Content != top.Content {
+		t.Errorf("Unexpected memory by ID: %+v", byID)
+	}
+
+	update := *byID
+	update.Content = "Paris is the capital and largest city of France"
+	update.Importance = 0.7
+	if err := m.UpdateMemory(top.ID, update); err != nil {
+		t.Fatal(err)
+	}
+	byID, err = m.GetMemoryByID(top.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byID.Content != update.Content || byID.Importance != 0.7 {
+		t.Errorf("Memory not updated: %+v", byID)
+	}
+
+	cleaned, err := m.CleanupExpiredMemories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cleaned != 1 {
+		t.Errorf("Expected 1 expired memory removed, got %d", cleaned)
+	}
+
+	if err := m.DeleteMemory(top.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetMemoryByID(top.ID); err == nil {
+		t.Error("Expected deleted memory to be gone")
+	}
+
+	count, err = m.CountMemories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 memories remaining, got %d", count)
+	}
+}
+
+func conformConversationSessions(t *testing.T, m *MMQ) {
+	conv := memory.NewConversationMemory(m.GetMemoryManager())
+
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		err := conv.StoreTurn(memory.ConversationTurn{
+			User:      fmt.Sprintf("question %d", i),
+			Assistant: fmt.Sprintf("answer %d", i),
+			SessionID: "s1",
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := conv.StoreTurn(memory.ConversationTurn{
+		User: "hello", Assistant: "hi", SessionID: "s2", Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := conv.GetHistory("s1", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 turns, got %d", len(history))
+	}
+
+	n, err := conv.CountBySession("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 turns in s1, got %d", n)
+	}
+
+	sessions, err := conv.GetSessionIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Errorf("Expected 2 sessions, got %v", sessions)
+	}
+
+	recent, err := conv.GetRecentTurns(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 1 || recent[0].SessionID != "s2" {
+		t.Errorf("Expected latest turn from s2, got %+v", recent)
+	}
+
+	cleared, err := conv.ClearSession("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cleared != 3 {
+		t.Errorf("Expected 3 turns cleared, got %d", cleared)
+	}
+
+	count, err := m.CountMemories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 memory remaining, got %d", count)
+	}
+}
+
+func conformMemoryNamespaces(t *testing.T, m *MMQ) {
+	storeNamespacedMemories(t, m)
+
+	recalled, err := m.RecallMemories("coffee", RecallOptions{Limit: 10, Scopes: []Namespace{alice}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recalled) != 4 {
+		t.Errorf("Expected 4 memories for alice, got %d", len(recalled))
+	}
+	for _, mem := range recalled {
+		if mem.Namespace.User != "alice" {
+			t.Errorf("Memory from another user leaked: %+v", mem)
+		}
+	}
+
+	cleaned, err := m.CleanupExpiredMemoriesIn(bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cleaned != 1 {
+		t.Errorf("Expected 1 expired memory removed for bob, got %d", cleaned)
+	}
+	count, err := m.CountMemoriesIn(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Errorf("Expected alice's memories untouched, got %d", count)
+	}
+
+	// 不同用户使用相同的会话ID
+	for _, ns := range []Namespace{alice, bob} {
+		conv := memory.NewConversationMemory(m.GetMemoryManagerIn(ns))
+		err := conv.StoreTurn(memory.ConversationTurn{
+			User: "hello from " + ns.User, Assistant: "hi", SessionID: "shared", Timestamp: time.Now(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conv := memory.NewConversationMemory(m.GetMemoryManagerIn(alice))
+	history, err := conv.GetHistory("shared", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].User != "hello from alice" {
+		t.Errorf("Expected only alice's turn, got %+v", history)
+	}
+
+	sessions, err := conv.GetSessionIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0] != "shared" {
+		t.Errorf("Expected alice's sessions only, got %v", sessions)
+	}
+
+	cleared, err := conv.ClearSession("shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cleared != 1 {
+		t.Errorf("Expected 1 turn cleared, got %d", cleared)
+	}
+	n, err := memory.NewConversationMemory(m.GetMemoryManagerIn(bob)).CountBySession("shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("Expected bob's turn to survive, got %d", n)
+	}
+}
+
+func conformMemoryFullText(t *testing.T, m *MMQ) {
+	memories := []Memory{
+		{Namespace: alice, Type: MemoryTypeFact, Content: "Support ticket 48213 was escalated to billing", Timestamp: time.Now()},
+		{Namespace: alice, Type: MemoryTypeFact, Content: "Support ticket 51007 was closed", Timestamp: time.Now()},
+		{Namespace: alice, Type: MemoryTypePreference, Content: "用户喜欢喝乌龙茶", Timestamp: time.Now()},
+		{Namespace: alice, Type: MemoryTypeFact, Content: "Prefers window seats", Tags: []string{"travel"}, Timestamp: time.Now()},
+		{Namespace: bob, Type: MemoryTypeFact, Content: "Support ticket 48213 was reopened", Timestamp: time.Now()},
+	}
+	for _, mem := range memories {
+		if err := m.StoreMemory(mem); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recall := func(query string, scopes ...Namespace) []Memory {
+		t.Helper()
+		recalled, err := m.RecallMemories(query, RecallOptions{Limit: 10, Scopes: scopes, Mode: RecallModeFTS})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return recalled
+	}
+
+	recalled := recall("ticket 48213", alice)
+	if len(recalled) != 1 || recalled[0].Content != "Support ticket 48213 was escalated to billing" {
+		t.Errorf("Expected exact ticket match for alice, got %+v", recalled)
+	}
+	if recalled := recall("ticket 48213"); len(recalled) != 2 {
+		t.Errorf("Expected matches from all namespaces without scopes, got %d", len(recalled))
+	}
+
+	if recalled := recall("乌龙茶"); len(recalled) != 1 || recalled[0].Type != MemoryTypePreference {
+		t.Errorf("Expected CJK memory matched, got %+v", recalled)
+	}
+	if recalled := recall("travel"); len(recalled) != 1 || recalled[0].Content != "Prefers window seats" {
+		t.Errorf("Expected tag match, got %+v", recalled)
+	}
+
+	// 更新和删除同步到全文索引
+	mem := recall("51007")[0]
+	mem.Content = "Support ticket 51007 was reassigned to Kim"
+	if err := m.UpdateMemory(mem.ID, mem); err != nil {
+		t.Fatal(err)
+	}
+	if recalled := recall("reassigned"); len(recalled) != 1 || recalled[0].ID != mem.ID {
+		t.Errorf("Expected updated content indexed, got %+v", recalled)
+	}
+	if recalled := recall("closed"); len(recalled) != 0 {
+		t.Errorf("Expected old content removed from index, got %+v", recalled)
+	}
+	if err := m.DeleteMemory(mem.ID); err != nil {
+		t.Fatal(err)
+	}
+	if recalled := recall("51007"); len(recalled) != 0 {
+		t.Errorf("Expected deleted memory removed from index, got %+v", recalled)
+	}
+}
+
+func conformMemoryFilters(t *testing.T, m *MMQ) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	shanghai := time.FixedZone("UTC+8", 8*3600)
+
+	memories := []Memory{
+		{Type: MemoryTypeConversation, Content: "User asked about billing cycles", Tags: []string{"billing"},
+			Metadata: map[string]interface{}{"channel": "email", "priority": 2}, Timestamp: now.Add(-2 * 24 * time.Hour), Importance: 0.4},
+		{Type: MemoryTypeConversation, Content: "User asked about billing refunds", Tags: []string{"billing", "refund"},
+			Metadata: map[string]interface{}{"channel": "chat", "urgent": true}, Timestamp: now.Add(-10 * 24 * time.Hour), Importance: 0.9},
+		// 其他时区写入的时间按绝对时间比较
+		{Type: MemoryTypeConversation, Content: "User asked about billing addresses", Tags: []string{"billing", "address"},
+			Metadata: map[string]interface{}{"channel": "chat"}, Timestamp: now.Add(-time.Hour).In(shanghai), Importance: 0.6},
+		{Type: MemoryTypeFact, Content: "Billing runs on the first of the month", Tags: []string{"billing"},
+			Timestamp: now, Importance: 0.8, ExpiresAt: &past},
+	}
+	for _, mem := range memories {
+		if err := m.StoreMemory(mem); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := func(opts ListMemoriesOptions) []string {
+		t.Helper()
+		page, err := m.ListMemories(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents := make([]string, len(page.Memories))
+		for i, mem := range page.Memories {
+			contents[i] = mem.Content
+		}
+		return contents
+	}
+	expect := func(name string, got []string, want ...string) {
+		t.Helper()
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+
+	lastWeek := MemoryFilter{Since: now.Add(-7 * 24 * time.Hour)}
+	expect("since", list(ListMemoriesOptions{Filter: lastWeek, MemoryTypes: []MemoryType{MemoryTypeConversation}}),
+		"User asked about billing addresses", "User asked about billing cycles")
+	expect("until", list(ListMemoriesOptions{Filter: MemoryFilter{Until: now.Add(-90 * time.Minute)}}),
+		"User asked about billing cycles", "User asked about billing refunds")
+	expect("any tags", list(ListMemoriesOptions{Filter: MemoryFilter{AnyTags: []string{"refund", "address"}}}),
+		"User asked about billing addresses", "User asked about billing refunds")
+	expect("all tags", list(ListMemoriesOptions{Filter: MemoryFilter{AllTags: []string{"billing", "refund"}}}),
+		"User asked about billing refunds")
+	expect("metadata", list(ListMemoriesOptions{Filter: MemoryFilter{Metadata: map[string]interface{}{"channel": "chat"}}}),
+		"User asked about billing addresses", "User asked about billing refunds")
+	expect("metadata number", list(ListMemoriesOptions{Filter: MemoryFilter{Metadata: map[string]interface{}{"priority": 2.0}}}),
+		"User asked about billing cycles")
+	expect("metadata bool", list(ListMemoriesOptions{Filter: MemoryFilter{Metadata: map[string]interface{}{"urgent": true}}}),
+		"User asked about billing refunds")
+	expect("importance", list(ListMemoriesOptions{Filter: MemoryFilter{MinImportance: 0.5, MaxImportance: 0.85}}),
+		"Billing runs on the first of the month", "User asked about billing addresses")
+	expect("expired", list(ListMemoriesOptions{Filter: MemoryFilter{Expiry: ExpiryExpired}}),
+		"Billing runs on the first of the month")
+	if got := list(ListMemoriesOptions{Filter: MemoryFilter{Expiry: ExpiryActive}}); len(got) != 3 {
+		t.Errorf("Expected 3 active memories, got %q", got)
+	}
+
+	// 分页
+	page, err := m.ListMemories(ListMemoriesOptions{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 4 || len(page.Memories) != 3 || !page.HasMore() {
+		t.Errorf("Unexpected first page: total=%d len=%d", page.Total, len(page.Memories))
+	}
+	page, err = m.ListMemories(ListMemoriesOptions{Limit: 3, Offset: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 4 || len(page.Memories) != 1 || page.HasMore() ||
+		page.Memories[0].Content != "User asked about billing refunds" {
+		t.Errorf("Unexpected last page: %+v", page)
+	}
+
+	// 回忆时同样过滤：上周用户问过的账单问题
+	for _, mode := range []RecallMode{RecallModeVector, RecallModeFTS} {
+		recalled, err := m.RecallMemories("billing", RecallOptions{
+			Limit: 10, Mode: mode, MemoryTypes: []MemoryType{MemoryTypeConversation}, Filter: lastWeek,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(recalled) != 2 {
+			t.Errorf("%s: expected 2 conversations from last week, got %d", mode, len(recalled))
+		}
+		for _, mem := range recalled {
+			if mem.Timestamp.Before(lastWeek.Since.Add(-time.Second)) {
+				t.Errorf("%s: memory outside time range: %q", mode, mem.Content)
+			}
+		}
+	}
+
+	if _, err := m.ListMemories(ListMemoriesOptions{Filter: MemoryFilter{Expiry: "stale"}}); err == nil {
+		t.Error("Expected error for unknown expiry state")
+	}
+}
@@ -0,0 +1,217 @@
+package mmq
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 并发压力测试，建议使用 -race 运行：
+//   go test -race -tags "fts5" -run Concurrent ./pkg/mmq
+
+func TestConcurrentMixedWorkload(t *testing.T) {
+	t.Run("file", func(t *testing.T) {
+		runMixedWorkload(t, filepath.Join(t.TempDir(), "test.db"))
+	})
+	// 内存库的读写连接池各自独立，读操作持有结果集时写操作不会死锁
+	t.Run("memory", func(t *testing.T) {
+		runMixedWorkload(t, ":memory:")
+	})
+}
+
+func runMixedWorkload(t *testing.T, dbPath string) {
+	m, err := NewWithDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	// 预先索引一些文档，保证搜索有结果
+	for i := 0; i < 10; i++ {
+		doc := Document{
+			Collection: "seed",
+			Path:       fmt.Sprintf("seed-%d.md", i),
+			Title:      fmt.Sprintf("Seed %d", i),
+			Content:    fmt.Sprintf("Seed document %d about Go concurrency and SQLite locking.", i),
+		}
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const (
+		writers   = 4
+		searchers = 4
+		rounds    = 15
+	)
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, 256)
+
+	report := func(op string, err error) {
+		if err != nil {
+			select {
+			case errCh <- fmt.Errorf("%s: %w", op, err):
+			default:
+			}
+		}
+	}
+
+	// 索引
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				doc := Document{
+					Collection: fmt.Sprintf("coll-%d", w%2),
+					Path:       fmt.Sprintf("w%d/doc-%d.md", w, i),
+					Title:      fmt.Sprintf("Writer %d Doc %d", w, i),
+					Content:    fmt.Sprintf("Document %d from writer %d discussing goroutines, channels and databases.", i, w),
+				}
+				report("IndexDocument", m.IndexDocument(doc))
+			}
+		}(w)
+	}
+
+	// 嵌入
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 5; i++ {
+			report("GenerateEmbeddings", m.GenerateEmbeddings())
+		}
+	}()
+
+	// 搜索
+	for s := 0; s < searchers; s++ {
+		wg.Add(1)
+		go func(s int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				opts := SearchOptions{Limit: 5}
+				_, err := m.Search("goroutines", opts)
+				report("Search", err)
+				_, err = m.VectorSearch("database locking", opts)
+				report("VectorSearch", err)
+				_, err = m.HybridSearch("Go concurrency", opts)
+				report("HybridSearch", err)
+				_, err = m.Status()
+				report("Status", err)
+			}
+		}(s)
+	}
+
+	// 记忆读写
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			report("StoreMemory", m.StoreMemory(Memory{
+				Type:      MemoryTypeFact,
+				Content:   fmt.Sprintf("fact number %d", i),
+				Timestamp: time.Now(),
+			}))
+			_, err := m.RecallMemories("fact", RecallOptions{Limit: 3})
+			report("RecallMemories", err)
+		}
+	}()
+
+	// 集合与上下文
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			report("AddContext", m.AddContext("/", fmt.Sprintf("global context v%d", i)))
+			_, err := m.ListCollections()
+			report("ListCollections", err)
+		}
+	}()
+
+	wg.Wait()
+	close(errCh)
+
+	for err := range errCh {
+		t.Error(err)
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := 10 + writers*rounds
+	if status.TotalDocuments != expected {
+		t.Errorf("Expected %d documents, got %d", expected, status.TotalDocuments)
+	}
+}
+
+func TestConcurrentMultipleInstances(t *testing.T) {
+	// 两个MMQ实例打开同一个数据库文件，模拟服务进程与cron任务并发运行
+	dbPath := filepath.Join(t.TempDir(), "shared.db")
+
+	server, err := NewWithDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	cron, err := NewWithDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cron.Close()
+
+	const rounds = 30
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, 128)
+
+	for idx, inst := range []*MMQ{server, cron} {
+		wg.Add(2)
+
+		go func(idx int, inst *MMQ) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				doc := Document{
+					Collection: "shared",
+					Path:       fmt.Sprintf("inst%d/doc-%d.md", idx, i),
+					Title:      "Shared",
+					Content:    fmt.Sprintf("Instance %d wrote document %d about locking", idx, i),
+				}
+				if err := inst.IndexDocument(doc); err != nil {
+					errCh <- fmt.Errorf("instance %d IndexDocument: %w", idx, err)
+					return
+				}
+			}
+		}(idx, inst)
+
+		go func(idx int, inst *MMQ) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				if _, err := inst.Search("locking", SearchOptions{Limit: 5}); err != nil {
+					errCh <- fmt.Errorf("instance %d Search: %w", idx, err)
+					return
+				}
+			}
+		}(idx, inst)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	for err := range errCh {
+		t.Error(err)
+	}
+
+	status, err := server.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.TotalDocuments != 2*rounds {
+		t.Errorf("Expected %d documents, got %d", 2*rounds, status.TotalDocuments)
+	}
+}
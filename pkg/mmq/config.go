@@ -8,7 +8,7 @@ import (
 
 // Config MMQ配置
 type Config struct {
-	// DBPath 数据库路径
+	// DBPath 数据库路径（":memory:"为临时内存数据库）
 	DBPath string
 	// CacheDir 模型缓存目录
 	CacheDir string
@@ -16,6 +16,8 @@ type Config struct {
 	EmbeddingModel string
 	// RerankModel 重排模型
 	RerankModel string
+	// GenerateModel 生成模型（上下文和摘要生成）
+	GenerateModel string
 	// ChunkSize 分块大小（字符数）
 	ChunkSize int
 	// ChunkOverlap 分块重叠（字符数）
@@ -24,6 +26,16 @@ type Config struct {
 	Threads int
 	// InactivityTimeout 模型空闲自动卸载时间
 	InactivityTimeout time.Duration
+	// BusyTimeout 数据库被其他连接/进程锁定时的最长等待时间
+	BusyTimeout time.Duration
+	// MaxReadConns 数据库读连接池大小
+	MaxReadConns int
+	// QueryLog 记录每次搜索和检索到query_log表
+	QueryLog bool
+	// QueryLogRetention 查询日志保留时长，更早的日志自动清理
+	QueryLogRetention time.Duration
+	// MemoryDedupThreshold 写入记忆时的语义去重阈值（余弦相似度），0表示不去重
+	MemoryDedupThreshold float64
 }
 
 // DefaultConfig 返回默认配置
@@ -35,10 +47,14 @@ func DefaultConfig() Config {
 		CacheDir:          filepath.Join(homeDir, ".cache", "modu", "models"),
 		EmbeddingModel:    "embeddinggemma-300M-Q8_0",
 		RerankModel:       "qwen3-reranker-0.6b-q8_0",
-		ChunkSize:         3200,           // ~800 tokens
-		ChunkOverlap:      480,            // 15% overlap
-		Threads:           4,              // 4线程
+		GenerateModel:     "qwen3-0_6b-q8_0",
+		ChunkSize:         3200,            // ~800 tokens
+		ChunkOverlap:      480,             // 15% overlap
+		Threads:           4,               // 4线程
 		InactivityTimeout: 5 * time.Minute, // 5分钟自动卸载
+		BusyTimeout:       5 * time.Second, // 5秒锁等待
+		MaxReadConns:      4,               // 4个读连接
+		QueryLogRetention: 30 * 24 * time.Hour,
 	}
 }
 
@@ -52,9 +68,11 @@ func (c *Config) Validate() error {
 		c.CacheDir = DefaultConfig().CacheDir
 	}
 
-	// 创建必要的目录
-	if err := os.MkdirAll(filepath.Dir(c.DBPath), 0755); err != nil {
-		return err
+	// 创建必要的目录（":memory:"为临时内存数据库）
+	if c.DBPath != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(c.DBPath), 0755); err != nil {
+			return err
+		}
 	}
 
 	if err := os.MkdirAll(c.CacheDir, 0755); err != nil {
@@ -78,6 +96,10 @@ func (c *Config) Validate() error {
 		c.RerankModel = "qwen3-reranker-0.6b-q8_0"
 	}
 
+	if c.GenerateModel == "" {
+		c.GenerateModel = "qwen3-0_6b-q8_0"
+	}
+
 	if c.Threads == 0 {
 		c.Threads = 4
 	}
@@ -86,5 +108,17 @@ func (c *Config) Validate() error {
 		c.InactivityTimeout = 5 * time.Minute
 	}
 
+	if c.BusyTimeout == 0 {
+		c.BusyTimeout = 5 * time.Second
+	}
+
+	if c.MaxReadConns == 0 {
+		c.MaxReadConns = 4
+	}
+
+	if c.QueryLogRetention == 0 {
+		c.QueryLogRetention = 30 * 24 * time.Hour
+	}
+
 	return nil
 }
@@ -0,0 +1,261 @@
+package mmq
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func contextSources(contexts []Context) []string {
+	sources := make([]string, len(contexts))
+	for i, c := range contexts {
+		sources[i] = c.Source
+	}
+	return sources
+}
+
+func TestRetrieveMaxPerDocument(t *testing.T) {
+	m := newRankingTestMMQ(t)
+
+	// 同一文件出现在两个集合中：按集合/路径是两个文档，内容相同
+	guide := "Release checklist: tag the release, publish the release notes."
+	indexDocs(t, m,
+		Document{Collection: "kb", Path: "release.md", Title: "Release", Content: guide},
+		Document{Collection: "mirror", Path: "release.md", Title: "Release", Content: guide},
+		Document{Collection: "kb", Path: "hotfix.md", Title: "Hotfix", Content: "Hotfix release: branch from the last release tag."},
+	)
+
+	opts := RetrieveOptions{Limit: 10, Strategy: StrategyFTS}
+	contexts, err := m.RetrieveContext("release", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Without cap: %v", contextSources(contexts))
+	if len(contexts) != 3 {
+		t.Fatalf("Expected 3 contexts without cap, got %d", len(contexts))
+	}
+
+	// 上限按集合/路径计数，不合并内容相同的不同文件
+	opts.MaxPerDocument = 1
+	contexts, err = m.RetrieveContext("release", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("With cap: %v", contextSources(contexts))
+	if len(contexts) != 3 {
+		t.Fatalf("Expected the cap to keep distinct files, got %v", contextSources(contexts))
+	}
+
+	// 内容相同的副本由近重复抑制合并
+	opts.DedupThreshold = 0.99
+	contexts, err = m.RetrieveContext("release", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contexts) != 2 || contexts[0].Text == contexts[1].Text {
+		t.Errorf("Expected duplicate file collapsed to one context, got %v", contextSources(contexts))
+	}
+}
+
+// newChunkingTestMMQ 使用小分块的MMQ，每个段落单独成块
+func newChunkingTestMMQ(t *testing.T) *MMQ {
+	t.Helper()
+
+	cfg := DefaultConfig()
+	cfg.DBPath = filepath.Join(t.TempDir(), "test.db")
+	cfg.ChunkSize = 90
+	cfg.ChunkOverlap = 1
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	return m
+}
+
+// contextsFrom 来自指定文档的上下文数量
+func contextsFrom(contexts []Context, source string) int {
+	n := 0
+	for _, c := range contexts {
+		if c.Source == source {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRetrieveMaxPerDocumentChunks(t *testing.T) {
+	m := newChunkingTestMMQ(t)
+
+	handbook := strings.Join([]string{
+		"Release handbook: every release starts from a green main branch build.",
+		"Release notes list each user facing change and link the release ticket.",
+		"Release tags are signed and pushed only after the release review ends.",
+		"Release rollback reverts the deploy and reopens the release ticket.",
+	}, "\n\n")
+	indexDocs(t, m,
+		Document{Collection: "kb", Path: "handbook.md", Title: "Handbook", Content: handbook},
+		Document{Collection: "kb", Path: "hotfix.md", Title: "Hotfix", Content: "Hotfix release: branch from the last release tag."},
+	)
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 多样性选择在块级候选上执行：同一文档的多个块分别成为上下文
+	opts := RetrieveOptions{Limit: 10, Strategy: StrategyFTS, MaxPerDocument: 3}
+	contexts, err := m.RetrieveContext("release", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Cap 3: %v", contextSources(contexts))
+	if n := contextsFrom(contexts, "kb/handbook.md"); n != 3 {
+		t.Fatalf("Expected 3 chunks of the handbook, got %d", n)
+	}
+	seen := make(map[string]bool)
+	for _, c := range contexts {
+		if c.Source == "kb/handbook.md" {
+			if seen[c.Text] || len(c.Text) >= len(handbook) {
+				t.Errorf("Expected distinct chunk texts, got %q", c.Text)
+			}
+			seen[c.Text] = true
+		}
+	}
+
+	// 上限为1时同一文档的其余块被移除
+	opts.MaxPerDocument = 1
+	contexts, err = m.RetrieveContext("release", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Cap 1: %v", contextSources(contexts))
+	if len(contexts) != 2 || contextsFrom(contexts, "kb/handbook.md") != 1 || contextsFrom(contexts, "kb/hotfix.md") != 1 {
+		t.Errorf("Expected one chunk per document, got %v", contextSources(contexts))
+	}
+}
+
+func TestRetrieveNearDuplicateChunks(t *testing.T) {
+	m := newChunkingTestMMQ(t)
+
+	// 第二段和第四段相同（复制粘贴的段落）
+	repeated := "Rotate the signing key every quarter and revoke the previous key."
+	runbook := strings.Join([]string{
+		"Key runbook: signing keys live in the hardware module of the vault.",
+		repeated,
+		"Audit each key use and alert when a key signs outside the pipeline.",
+		repeated,
+	}, "\n\n") + "\n\n"
+	indexDocs(t, m, Document{Collection: "ops", Path: "keys.md", Title: "Keys", Content: runbook})
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := RetrieveOptions{Limit: 10, Strategy: StrategyFTS, MaxPerDocument: 10}
+	contexts, err := m.RetrieveContext("signing key", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contexts) != 4 {
+		t.Fatalf("Expected all 4 chunks without dedup, got %d", len(contexts))
+	}
+
+	opts.DedupThreshold = 0.99
+	deduped, err := m.RetrieveContext("signing key", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deduped) != 3 {
+		t.Fatalf("Expected the repeated chunk suppressed, got %d contexts", len(deduped))
+	}
+	count := 0
+	for _, c := range deduped {
+		if strings.Contains(c.Text, repeated) {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("Expected the repeated paragraph once, got %d", count)
+	}
+}
+
+func TestRetrieveNearDuplicateSuppression(t *testing.T) {
+	m := newRankingTestMMQ(t)
+
+	indexDocs(t, m,
+		Document{Collection: "ops", Path: "deploy-v1.md", Title: "Deploy", Content: "Deploy the service with kubectl apply and then check the rollout status."},
+		Document{Collection: "ops", Path: "deploy-v2.md", Title: "Deploy", Content: "Deploy the service with kubectl apply and then verify the rollout status."},
+		Document{Collection: "ops", Path: "deploy-v3.md", Title: "Deploy", Content: "Deploy the service using kubectl apply and then check the rollout status."},
+		Document{Collection: "ops", Path: "canary.md", Title: "Canary", Content: "Canary deploy: route five percent of traffic to the new service first."},
+	)
+
+	opts := RetrieveOptions{Limit: 10, Strategy: StrategyFTS}
+	contexts, err := m.RetrieveContext("deploy service", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contexts) != 4 {
+		t.Fatalf("Expected 4 contexts without dedup, got %v", contextSources(contexts))
+	}
+
+	opts.DedupThreshold = 0.7
+	deduped, err := m.RetrieveContext("deploy service", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Deduped: %v", contextSources(deduped))
+	if len(deduped) != 2 {
+		t.Fatalf("Expected near-duplicates collapsed, got %v", contextSources(deduped))
+	}
+
+	// 保留的是该组中得分最高的一条
+	if deduped[0].Source != contexts[0].Source {
+		t.Errorf("Expected highest-scoring variant kept, got %s want %s", deduped[0].Source, contexts[0].Source)
+	}
+	if deduped[0].Source != "ops/canary.md" && deduped[1].Source != "ops/canary.md" {
+		t.Errorf("Expected distinct document kept, got %v", contextSources(deduped))
+	}
+}
+
+func TestRetrieveMMRDiversity(t *testing.T) {
+	m := newRankingTestMMQ(t)
+
+	indexDocs(t, m,
+		Document{Collection: "ops", Path: "backup-a.md", Title: "Backup", Content: "Backup the database nightly; backup files go to object storage."},
+		Document{Collection: "ops", Path: "backup-b.md", Title: "Backup", Content: "Backup the database nightly; backup files go to cold storage."},
+		Document{Collection: "ops", Path: "restore.md", Title: "Restore", Content: "Restore drills run monthly and validate each database backup."},
+	)
+
+	plain, err := m.RetrieveContext("backup database", RetrieveOptions{Limit: 2, Strategy: StrategyFTS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Plain: %v", contextSources(plain))
+
+	diverse, err := m.RetrieveContext("backup database", RetrieveOptions{Limit: 2, Strategy: StrategyFTS, MMRLambda: 0.3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("MMR:   %v", contextSources(diverse))
+	if len(diverse) != 2 {
+		t.Fatalf("Expected 2 contexts, got %d", len(diverse))
+	}
+	if diverse[0].Source != plain[0].Source {
+		t.Errorf("Expected MMR to keep the most relevant result first, got %s", diverse[0].Source)
+	}
+	if diverse[1].Source != "ops/restore.md" {
+		t.Errorf("Expected MMR to prefer the dissimilar document second, got %v", contextSources(diverse))
+	}
+
+	// 有向量时使用文档向量计算相似度
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+	hybrid, err := m.RetrieveContext("backup database", RetrieveOptions{Limit: 3, Strategy: StrategyHybrid, MMRLambda: 0.5, DedupThreshold: 0.99})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Hybrid MMR: %v", contextSources(hybrid))
+	if len(hybrid) != 3 {
+		t.Errorf("Expected all distinct documents with vectors, got %v", contextSources(hybrid))
+	}
+}
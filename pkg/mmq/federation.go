@@ -0,0 +1,247 @@
+package mmq
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+// FederatedIndex 联邦中的一个索引
+type FederatedIndex struct {
+	Name   string  // 索引名称，写入结果的Index字段
+	MMQ    *MMQ    // 索引实例
+	Weight float64 // RRF融合权重（0表示1.0）
+}
+
+// Federation 多索引联邦检索
+// 在多个MMQ实例上并行执行检索，并用ReciprocalRankFusion按名次融合结果，
+// 每条结果的Index字段标记其来源索引。各索引的分数量纲不同，融合后的Score为RRF分数。
+type Federation struct {
+	indexes []FederatedIndex
+	owned   bool // 由NewFederation打开的实例在Close时关闭
+	rrfK    int
+}
+
+// NewFederation 打开多个数据库文件组成联邦，索引名称为数据库路径
+func NewFederation(dbPaths ...string) (*Federation, error) {
+	if len(dbPaths) == 0 {
+		return nil, fmt.Errorf("federation requires at least one database")
+	}
+
+	f := &Federation{owned: true}
+	for _, path := range dbPaths {
+		m, err := NewWithDB(expandPath(path))
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to open index %s: %w", path, err)
+		}
+		f.indexes = append(f.indexes, FederatedIndex{Name: path, MMQ: m})
+	}
+
+	return f, nil
+}
+
+// NewFederationFromIndexes 使用已打开的实例组成联邦
+// 实例的生命周期由调用方管理，Federation.Close不会关闭它们
+func NewFederationFromIndexes(indexes ...FederatedIndex) (*Federation, error) {
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("federation requires at least one index")
+	}
+
+	for i, idx := range indexes {
+		if idx.MMQ == nil {
+			return nil, fmt.Errorf("index %d has no MMQ instance", i)
+		}
+		if idx.Name == "" {
+			indexes[i].Name = fmt.Sprintf("index-%d", i)
+		}
+	}
+
+	return &Federation{indexes: indexes}, nil
+}
+
+// SetRRFK 设置RRF的k值（默认60）
+func (f *Federation) SetRRFK(k int) {
+	f.rrfK = k
+}
+
+// Indexes 返回联邦中的索引
+func (f *Federation) Indexes() []FederatedIndex {
+	return f.indexes
+}
+
+// Close 关闭由NewFederation打开的实例
+func (f *Federation) Close() error {
+	if !f.owned {
+		return nil
+	}
+
+	var firstErr error
+	for _, idx := range f.indexes {
+		if err := idx.MMQ.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Search 在所有索引上执行BM25全文搜索
+func (f *Federation) Search(query string, opts SearchOptions) ([]SearchResult, error) {
+	return f.searchAll(opts.Limit, func(m *MMQ) ([]SearchResult, error) {
+		return m.Search(query, opts)
+	})
+}
+
+// VectorSearch 在所有索引上执行向量语义搜索
+func (f *Federation) VectorSearch(query string, opts SearchOptions) ([]SearchResult, error) {
+	return f.searchAll(opts.Limit, func(m *MMQ) ([]SearchResult, error) {
+		return m.VectorSearch(query, opts)
+	})
+}
+
+// HybridSearch 在所有索引上执行混合搜索
+func (f *Federation) HybridSearch(query string, opts SearchOptions) ([]SearchResult, error) {
+	return f.searchAll(opts.Limit, func(m *MMQ) ([]SearchResult, error) {
+		return m.HybridSearch(query, opts)
+	})
+}
+
+// RecallMemories 在所有索引上回忆记忆
+func (f *Federation) RecallMemories(query string, opts RecallOptions) ([]Memory, error) {
+	lists, err := fanOut(f.indexes, func(m *MMQ) ([]Memory, error) {
+		return m.RecallMemories(query, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 记忆按名次转换为RRF输入，融合后映射回原记录
+	byKey := make(map[string]Memory)
+	source := make(map[string]int)
+	rankLists := make([][]store.SearchResult, len(lists))
+	for i, list := range lists {
+		for _, mem := range list {
+			key := fmt.Sprintf("%d:%s", i, mem.ID)
+			mem.Index = f.indexes[i].Name
+			byKey[key] = mem
+			source[key] = i
+			rankLists[i] = append(rankLists[i], store.SearchResult{ID: key})
+		}
+	}
+
+	fused := store.ReciprocalRankFusion(rankLists, f.weights(), f.rrfK)
+
+	// 名次相同时按索引顺序排列
+	sort.SliceStable(fused, func(i, j int) bool {
+		if fused[i].Score != fused[j].Score {
+			return fused[i].Score > fused[j].Score
+		}
+		return source[fused[i].ID] < source[fused[j].ID]
+	})
+
+	memories := make([]Memory, 0, len(fused))
+	for _, r := range fused {
+		memories = append(memories, byKey[r.ID])
+	}
+	if opts.Limit > 0 && len(memories) > opts.Limit {
+		memories = memories[:opts.Limit]
+	}
+
+	return memories, nil
+}
+
+// searchAll 在所有索引上执行检索并融合结果
+func (f *Federation) searchAll(limit int, search func(m *MMQ) ([]SearchResult, error)) ([]SearchResult, error) {
+	lists, err := fanOut(f.indexes, search)
+	if err != nil {
+		return nil, err
+	}
+
+	// 键加上索引序号：不同索引中相同内容的文档是不同的结果
+	byKey := make(map[string]SearchResult)
+	rankLists := make([][]store.SearchResult, len(lists))
+	for i, list := range lists {
+		for _, r := range list {
+			id := r.ID
+			if id == "" {
+				id = r.Collection + "/" + r.Path
+			}
+			key := fmt.Sprintf("%d:%s", i, id)
+			r.Index = f.indexes[i].Name
+			byKey[key] = r
+			rankLists[i] = append(rankLists[i], store.SearchResult{ID: key})
+		}
+	}
+
+	fused := store.ReciprocalRankFusion(rankLists, f.weights(), f.rrfK)
+
+	type fusedResult struct {
+		result   SearchResult
+		original float64
+	}
+	merged := make([]fusedResult, len(fused))
+	for i, r := range fused {
+		merged[i] = fusedResult{result: byKey[r.ID], original: byKey[r.ID].Score}
+		merged[i].result.Score = r.Score
+	}
+
+	// 名次相同（例如各索引的第一名）时按原始分数排序，保证结果稳定
+	sort.SliceStable(merged, func(i, j int) bool {
+		a, b := merged[i], merged[j]
+		if a.result.Score != b.result.Score {
+			return a.result.Score > b.result.Score
+		}
+		if a.original != b.original {
+			return a.original > b.original
+		}
+		return a.result.Index < b.result.Index
+	})
+
+	results := make([]SearchResult, len(merged))
+	for i, m := range merged {
+		results[i] = m.result
+	}
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// weights 返回各索引的RRF权重
+func (f *Federation) weights() []float64 {
+	weights := make([]float64, len(f.indexes))
+	for i, idx := range f.indexes {
+		weights[i] = idx.Weight
+		if weights[i] == 0 {
+			weights[i] = 1.0
+		}
+	}
+	return weights
+}
+
+// fanOut 并行地在每个索引上执行fn，结果按索引顺序返回
+func fanOut[T any](indexes []FederatedIndex, fn func(m *MMQ) ([]T, error)) ([][]T, error) {
+	lists := make([][]T, len(indexes))
+	errs := make([]error, len(indexes))
+
+	var wg sync.WaitGroup
+	for i, idx := range indexes {
+		wg.Add(1)
+		go func(i int, m *MMQ) {
+			defer wg.Done()
+			lists[i], errs[i] = fn(m)
+		}(i, idx.MMQ)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("index %s: %w", indexes[i].Name, err)
+		}
+	}
+
+	return lists, nil
+}
@@ -0,0 +1,175 @@
+package mmq
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newFederationIndex(t *testing.T, dbPath string, docs []Document, memories []Memory) {
+	t.Helper()
+
+	m, err := NewWithDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	for _, doc := range docs {
+		if err := m.IndexDocument(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+	for _, mem := range memories {
+		if err := m.StoreMemory(mem); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFederationSearch(t *testing.T) {
+	tmpDir := t.TempDir()
+	teamDB := filepath.Join(tmpDir, "team.db")
+	projectDB := filepath.Join(tmpDir, "project.db")
+
+	newFederationIndex(t, teamDB, []Document{
+		{Collection: "team", Path: "deploy.md", Title: "Deploy", Content: "How the team deploys services to production."},
+		{Collection: "team", Path: "shared.md", Title: "Shared", Content: "Shared onboarding checklist."},
+	}, nil)
+	newFederationIndex(t, projectDB, []Document{
+		{Collection: "project", Path: "release.md", Title: "Release", Content: "Project release and deploys schedule."},
+		{Collection: "project", Path: "shared.md", Title: "Shared", Content: "Shared onboarding checklist."},
+	}, nil)
+
+	fed, err := NewFederation(teamDB, projectDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fed.Close()
+
+	results, err := fed.Search("deploys", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results across indexes, got %d", len(results))
+	}
+
+	indexes := make(map[string]string)
+	for _, r := range results {
+		t.Logf("  [%s] %s/%s score=%.4f", r.Index, r.Collection, r.Path, r.Score)
+		indexes[r.Collection] = r.Index
+	}
+	if indexes["team"] != teamDB || indexes["project"] != projectDB {
+		t.Errorf("Results not tagged with source index: %v", indexes)
+	}
+
+	// 相同内容在不同索引中是不同的结果
+	results, err = fed.Search("onboarding", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Index == results[1].Index {
+		t.Errorf("Expected identical documents from both indexes, got %+v", results)
+	}
+
+	results, err = fed.Search("deploys onboarding", SearchOptions{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) > 1 {
+		t.Errorf("Expected limit to apply after fusion, got %d", len(results))
+	}
+
+	vec, err := fed.VectorSearch("Shared onboarding checklist.", SearchOptions{Limit: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vec) != 4 {
+		t.Fatalf("Expected 4 vector results, got %d", len(vec))
+	}
+	// 每个索引的第一名交替排在最前
+	if vec[0].Path != "shared.md" || vec[1].Path != "shared.md" {
+		t.Errorf("Expected shared.md from both indexes first, got %s, %s", vec[0].Path, vec[1].Path)
+	}
+
+	hybrid, err := fed.HybridSearch("release", SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hybrid) == 0 || hybrid[0].Index != projectDB {
+		t.Errorf("Expected project index first for 'release', got %+v", hybrid)
+	}
+}
+
+func TestFederationWeightsAndMemories(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	a, err := NewWithDB(filepath.Join(tmpDir, "a.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	b, err := NewWithDB(filepath.Join(tmpDir, "b.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	for _, m := range []*MMQ{a, b} {
+		if err := m.IndexDocument(Document{Collection: "kb", Path: "topic.md", Title: "Topic", Content: "Kubernetes operators"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.StoreMemory(Memory{Type: MemoryTypeFact, Content: "Kubernetes runs containers", Timestamp: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fed, err := NewFederationFromIndexes(
+		FederatedIndex{Name: "a", MMQ: a},
+		FederatedIndex{Name: "b", MMQ: b, Weight: 2.0},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := fed.Search("kubernetes", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Index != "b" {
+		t.Errorf("Expected higher-weighted index first, got %+v", results)
+	}
+
+	memories, err := fed.RecallMemories("Kubernetes runs containers", RecallOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(memories) != 2 {
+		t.Fatalf("Expected 2 memories, got %d", len(memories))
+	}
+	if memories[0].Index != "b" || memories[1].Index != "a" {
+		t.Errorf("Memories not tagged or ordered by weight: %s, %s", memories[0].Index, memories[1].Index)
+	}
+
+	// 外部传入的实例不由联邦关闭
+	if err := fed.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Search("kubernetes", SearchOptions{Limit: 1}); err != nil {
+		t.Errorf("Expected instance to remain open: %v", err)
+	}
+}
+
+func TestFederationErrors(t *testing.T) {
+	if _, err := NewFederation(); err == nil {
+		t.Error("Expected error for empty federation")
+	}
+	if _, err := NewFederationFromIndexes(FederatedIndex{Name: "x"}); err == nil {
+		t.Error("Expected error for index without instance")
+	}
+}
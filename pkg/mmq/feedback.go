@@ -0,0 +1,74 @@
+package mmq
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+// FeedbackSignal 相关性反馈信号
+type FeedbackSignal string
+
+const (
+	FeedbackUp   FeedbackSignal = "up"   // 点赞：结果相关
+	FeedbackDown FeedbackSignal = "down" // 点踩：结果不相关
+	FeedbackUsed FeedbackSignal = "used" // 结果被用于回答（强于点赞）
+)
+
+// DefaultFeedbackHalfLife 反馈衰减的默认半衰期（RankingProfile.FeedbackHalfLife为0时使用）
+const DefaultFeedbackHalfLife = store.DefaultFeedbackHalfLife
+
+// feedbackWeights 各信号的权重
+var feedbackWeights = map[FeedbackSignal]float64{
+	FeedbackUp:   1.0,
+	FeedbackDown: -1.0,
+	FeedbackUsed: 1.5,
+}
+
+// RecordFeedback 记录对某次查询结果的相关性反馈
+// queryID 来自结果的QueryID字段（需开启查询日志）；
+// docID 可以是结果ID（内容哈希）或 collection/path。
+// 启用RankingProfile.FeedbackWeight后，反馈会作为排序信号：
+// 文档整体加权，以及与反馈查询中的词关联的文档加权，两者都随时间衰减。
+func (m *MMQ) RecordFeedback(queryID, docID string, signal FeedbackSignal) error {
+	weight, ok := feedbackWeights[signal]
+	if !ok {
+		return fmt.Errorf("unknown feedback signal: %s", signal)
+	}
+
+	entry, err := m.store.GetQueryLogEntry(queryID)
+	if err != nil {
+		return err
+	}
+
+	path, err := m.resolveFeedbackDoc(entry, docID)
+	if err != nil {
+		return err
+	}
+
+	return m.store.SaveFeedback(store.Feedback{
+		QueryID: entry.ID,
+		Query:   entry.Query,
+		DocPath: path,
+		Signal:  string(signal),
+		Weight:  weight,
+	})
+}
+
+// resolveFeedbackDoc 将docID解析为 collection/path
+// 优先匹配查询返回的结果，其次接受索引中存在的文档路径
+func (m *MMQ) resolveFeedbackDoc(entry *store.QueryLogEntry, docID string) (string, error) {
+	key := strings.TrimPrefix(docID, "qmd://")
+	for _, r := range entry.Results {
+		if r.ID == docID || r.Path == key {
+			return r.Path, nil
+		}
+	}
+
+	doc, err := m.store.GetDocumentByPath(docID)
+	if err != nil {
+		return "", fmt.Errorf("document not found in query results: %s", docID)
+	}
+	return doc.Collection + "/" + doc.Path, nil
+}
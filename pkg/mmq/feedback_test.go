@@ -0,0 +1,184 @@
+package mmq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+func newFeedbackTestMMQ(t *testing.T) *MMQ {
+	t.Helper()
+
+	m := newRankingTestMMQ(t)
+	m.SetQueryLogging(true)
+	indexDocs(t, m,
+		Document{Collection: "kb", Path: "deploy-old.md", Title: "Old", Content: "Deploy steps for the legacy cluster."},
+		Document{Collection: "kb", Path: "deploy-new.md", Title: "New", Content: "Deploy steps for the current cluster."},
+		Document{Collection: "kb", Path: "backup.md", Title: "Backup", Content: "Backup steps for the database."},
+	)
+	return m
+}
+
+func TestFeedbackImprovesRanking(t *testing.T) {
+	m := newFeedbackTestMMQ(t)
+	ranking := &RankingProfile{FeedbackWeight: 1.0}
+
+	results, err := m.Search("deploy steps", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Before feedback: %v", searchPaths(results))
+
+	// 对排在后面的部署文档点赞、对第一条点踩
+	var target SearchResult
+	for _, r := range results {
+		if r.Path != results[0].Path && r.Path != "backup.md" {
+			target = r
+		}
+	}
+	if err := m.RecordFeedback(results[0].QueryID, target.ID, FeedbackUsed); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RecordFeedback(results[0].QueryID, "kb/"+results[0].Path, FeedbackDown); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未启用时排序不变
+	plain, err := m.Search("deploy steps", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain[0].Path != results[0].Path {
+		t.Errorf("Expected ranking unchanged without feedback weight, got %v", searchPaths(plain))
+	}
+
+	boosted, err := m.Search("deploy steps", SearchOptions{Limit: 10, Ranking: ranking})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("After feedback: %v", searchPaths(boosted))
+	if boosted[0].Path != target.Path {
+		t.Errorf("Expected %s first after feedback, got %v", target.Path, searchPaths(boosted))
+	}
+
+	contexts, err := m.RetrieveContext("deploy steps", RetrieveOptions{Limit: 10, Strategy: StrategyFTS, Ranking: ranking})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contexts[0].Source != "kb/"+target.Path {
+		t.Errorf("Expected %s first in retrieval, got %s", target.Path, contexts[0].Source)
+	}
+
+	// 无效信号和未知文档
+	if err := m.RecordFeedback(results[0].QueryID, target.ID, FeedbackSignal("meh")); err == nil {
+		t.Error("Expected error for unknown signal")
+	}
+	if err := m.RecordFeedback(results[0].QueryID, "kb/missing.md", FeedbackUp); err == nil {
+		t.Error("Expected error for unknown document")
+	}
+	if err := m.RecordFeedback("missing", target.ID, FeedbackUp); err == nil {
+		t.Error("Expected error for unknown query")
+	}
+}
+
+func TestFeedbackDecay(t *testing.T) {
+	m := newFeedbackTestMMQ(t)
+	ranking := &RankingProfile{FeedbackWeight: 1.0, FeedbackHalfLife: 24 * time.Hour}
+
+	results, err := m.Search("deploy steps", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := results[1]
+
+	// 很久以前的反馈已衰减到几乎不影响排序
+	if err := m.GetBackend().SaveFeedback(store.Feedback{
+		QueryID:   "old",
+		Query:     "deploy steps",
+		DocPath:   "kb/" + last.Path,
+		Signal:    string(FeedbackUsed),
+		Weight:    1.5,
+		CreatedAt: time.Now().Add(-30 * 24 * time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	decayed, err := m.Search("deploy steps", SearchOptions{Limit: 10, Ranking: ranking})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decayed[0].Path != results[0].Path {
+		t.Errorf("Expected expired feedback to be ignored, got %v", searchPaths(decayed))
+	}
+
+	// 同样的反馈在半衰期内仍然有效
+	if err := m.GetBackend().SaveFeedback(store.Feedback{
+		QueryID:   "recent",
+		Query:     "deploy steps",
+		DocPath:   "kb/" + last.Path,
+		Signal:    string(FeedbackUsed),
+		Weight:    1.5,
+		CreatedAt: time.Now().Add(-time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	boosted, err := m.Search("deploy steps", SearchOptions{Limit: 10, Ranking: ranking})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if boosted[0].Path != last.Path {
+		t.Errorf("Expected recent feedback to promote %s, got %v", last.Path, searchPaths(boosted))
+	}
+}
+
+func TestFeedbackQueryTermAssociation(t *testing.T) {
+	m := newFeedbackTestMMQ(t)
+
+	// “cluster database”查询的反馈只加强这两个词与文档的关联
+	now := time.Now()
+	for _, f := range []store.Feedback{
+		{QueryID: "q1", Query: "cluster database", DocPath: "kb/backup.md", Signal: "used", Weight: 1.5, CreatedAt: now},
+		{QueryID: "q1", Query: "cluster database", DocPath: "kb/deploy-old.md", Signal: "down", Weight: -1, CreatedAt: now},
+	} {
+		if err := m.GetBackend().SaveFeedback(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results := []store.SearchResult{
+		{Collection: "kb", Path: "deploy-old.md", Score: 1.0},
+		{Collection: "kb", Path: "backup.md", Score: 0.9},
+	}
+	related, err := store.ApplyFeedbackBoost(m.GetBackend(), "database", append([]store.SearchResult(nil), results...), 1.0, 0, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unrelated, err := store.ApplyFeedbackBoost(m.GetBackend(), "kubernetes", append([]store.SearchResult(nil), results...), 1.0, 0, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("related: %+v", related)
+	t.Logf("unrelated: %+v", unrelated)
+
+	if related[0].Path != "backup.md" {
+		t.Errorf("Expected backup.md first for associated query, got %+v", related)
+	}
+
+	// 查询词关联的调整大于仅有文档先验时
+	var relatedScore, unrelatedScore float64
+	for _, r := range related {
+		if r.Path == "backup.md" {
+			relatedScore = r.Score
+		}
+	}
+	for _, r := range unrelated {
+		if r.Path == "backup.md" {
+			unrelatedScore = r.Score
+		}
+	}
+	if relatedScore <= unrelatedScore {
+		t.Errorf("Expected term association to add to the prior, got %v <= %v", relatedScore, unrelatedScore)
+	}
+}
@@ -0,0 +1,271 @@
+package mmq
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/crosszan/modu/pkg/mmq/llm"
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+const (
+	defaultContextSampleSize  = 5    // 上下文生成默认采样文档数
+	defaultContextSampleChars = 1000 // 上下文生成时每篇采样文档截取的字符数
+	defaultSummaryInputChars  = 4000 // 摘要生成时文档截取的字符数
+)
+
+// contextPrompt 集合/目录描述的生成提示
+const contextPrompt = `Below are sample documents from %s.
+Write one or two sentences describing what this collection contains and what it is useful for.
+Answer in the same language as the documents. Output only the description.
+
+%s
+Description:`
+
+// summaryPrompt 文档摘要的生成提示
+const summaryPrompt = `Summarize the following document in two to four sentences.
+Mention its main topics and key terms. Answer in the same language as the document. Output only the summary.
+
+Title: %s
+
+%s
+
+Summary:`
+
+// contextTarget 待生成上下文的集合或目录
+type contextTarget struct {
+	path       string // 上下文路径 qmd://collection[/folder]
+	collection string
+	prefix     string // 集合内的目录前缀，为空表示整个集合
+}
+
+// GenerateContexts 使用LLM为集合或目录生成上下文描述
+// 从每个目标下均匀采样文档，用LLM.Generate总结内容后保存为上下文（DryRun时只返回不保存）；
+// 已有上下文的目标默认跳过，Overwrite为true时重新生成
+func (m *MMQ) GenerateContexts(opts GenerateContextsOptions) ([]GeneratedContext, error) {
+	if opts.Path != "" && opts.Collection == "" {
+		return nil, fmt.Errorf("path requires a collection")
+	}
+	if opts.SampleSize <= 0 {
+		opts.SampleSize = defaultContextSampleSize
+	}
+	if opts.MaxChars <= 0 {
+		opts.MaxChars = defaultContextSampleChars
+	}
+
+	targets, err := m.contextTargets(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var generated []GeneratedContext
+	for _, target := range targets {
+		if !opts.Overwrite {
+			if existing, _ := m.store.GetContext(target.path); existing != nil {
+				generated = append(generated, GeneratedContext{Path: target.path, Content: existing.Content, Skipped: true})
+				continue
+			}
+		}
+
+		entries, err := m.store.ListDocumentsByPath(target.collection, target.prefix)
+		if err != nil {
+			return generated, fmt.Errorf("failed to list documents for %s: %w", target.path, err)
+		}
+		if len(entries) == 0 {
+			continue
+		}
+
+		var samples []string
+		for _, entry := range sampleEvenly(entries, opts.SampleSize) {
+			doc, err := m.store.GetDocumentByPath(entry.Collection + "/" + entry.Path)
+			if err != nil {
+				return generated, fmt.Errorf("failed to read %s/%s: %w", entry.Collection, entry.Path, err)
+			}
+			samples = append(samples, fmt.Sprintf("--- %s (%s)\n%s\n", doc.Title, doc.Path, truncateRunes(doc.Content, opts.MaxChars)))
+		}
+
+		content, err := m.generate(fmt.Sprintf(contextPrompt, target.path, strings.Join(samples, "\n")), 128)
+		if err != nil {
+			return generated, fmt.Errorf("failed to generate context for %s: %w", target.path, err)
+		}
+
+		if !opts.DryRun {
+			if err := m.store.AddContext(target.path, content); err != nil {
+				return generated, fmt.Errorf("failed to save context for %s: %w", target.path, err)
+			}
+		}
+
+		generated = append(generated, GeneratedContext{Path: target.path, Content: content, Sampled: len(samples)})
+	}
+
+	return generated, nil
+}
+
+// contextTargets 按选项列出需要生成上下文的集合和目录
+func (m *MMQ) contextTargets(opts GenerateContextsOptions) ([]contextTarget, error) {
+	var collections []string
+	if opts.Collection != "" {
+		exists, err := m.store.CollectionExists(opts.Collection)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("collection '%s' not found", opts.Collection)
+		}
+		collections = []string{opts.Collection}
+	} else {
+		all, err := m.store.ListCollections()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list collections: %w", err)
+		}
+		for _, c := range all {
+			collections = append(collections, c.Name)
+		}
+	}
+
+	if opts.Path != "" {
+		prefix := strings.Trim(opts.Path, "/")
+		return []contextTarget{{
+			path:       fmt.Sprintf("qmd://%s/%s", opts.Collection, prefix),
+			collection: opts.Collection,
+			prefix:     prefix,
+		}}, nil
+	}
+
+	var targets []contextTarget
+	for _, name := range collections {
+		targets = append(targets, contextTarget{path: fmt.Sprintf("qmd://%s", name), collection: name})
+
+		if !opts.Folders {
+			continue
+		}
+
+		entries, err := m.store.ListDocumentsByPath(name, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list documents: %w", err)
+		}
+		seen := make(map[string]bool)
+		var folders []string
+		for _, e := range entries {
+			folder, _, ok := strings.Cut(e.Path, "/")
+			if ok && !seen[folder] {
+				seen[folder] = true
+				folders = append(folders, folder)
+			}
+		}
+		sort.Strings(folders)
+		for _, folder := range folders {
+			targets = append(targets, contextTarget{
+				path:       fmt.Sprintf("qmd://%s/%s", name, folder),
+				collection: name,
+				prefix:     folder,
+			})
+		}
+	}
+
+	return targets, nil
+}
+
+// GenerateSummaries 使用LLM为还没有摘要的文档生成摘要，返回生成数量
+// 摘要按内容哈希保存并参与全文检索；文档内容变化后哈希改变，会在下次调用时重新生成，
+// 不再被引用的旧摘要同时被清理
+func (m *MMQ) GenerateSummaries(opts SummarizeOptions) (int, error) {
+	if opts.MaxChars <= 0 {
+		opts.MaxChars = defaultSummaryInputChars
+	}
+
+	docs, err := m.store.GetDocumentsNeedingSummary(opts.Collection)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get documents: %w", err)
+	}
+	if opts.Limit > 0 && len(docs) > opts.Limit {
+		docs = docs[:opts.Limit]
+	}
+
+	generated := 0
+	for _, doc := range docs {
+		summary, err := m.generate(fmt.Sprintf(summaryPrompt, doc.Title, truncateRunes(doc.Content, opts.MaxChars)), 200)
+		if err != nil {
+			return generated, fmt.Errorf("failed to summarize %s/%s: %w", doc.Collection, doc.Path, err)
+		}
+
+		if err := m.store.SaveDocumentSummary(doc.Hash, summary, m.cfg.GenerateModel); err != nil {
+			return generated, fmt.Errorf("failed to store summary: %w", err)
+		}
+		generated++
+	}
+
+	if _, err := m.store.PruneDocumentSummaries(); err != nil {
+		return generated, err
+	}
+
+	return generated, nil
+}
+
+// GetDocumentSummary 获取文档的摘要（路径格式：collection/path 或 qmd://collection/path）
+// 文档还没有摘要（或内容修改后尚未重新生成）时返回nil
+func (m *MMQ) GetDocumentSummary(filePath string) (*DocumentSummary, error) {
+	doc, err := m.store.GetDocumentByPath(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := m.store.GetDocumentSummary(doc.Hash)
+	if err != nil || summary == nil {
+		return nil, err
+	}
+
+	return convertDocumentSummary(summary), nil
+}
+
+// convertDocumentSummary 转换store.DocumentSummary到mmq.DocumentSummary
+func convertDocumentSummary(s *store.DocumentSummary) *DocumentSummary {
+	return &DocumentSummary{
+		Hash:      s.Hash,
+		Summary:   s.Summary,
+		Model:     s.Model,
+		CreatedAt: s.CreatedAt,
+	}
+}
+
+// generate 调用生成模型并清理输出
+func (m *MMQ) generate(prompt string, maxTokens int) (string, error) {
+	opts := llm.DefaultGenerateOptions()
+	opts.Temperature = 0.3
+	opts.MaxTokens = maxTokens
+
+	text, err := m.llm.Generate(prompt, opts)
+	if err != nil {
+		return "", err
+	}
+
+	text = strings.Trim(strings.TrimSpace(text), "\"“”")
+	if text == "" {
+		return "", fmt.Errorf("empty generation")
+	}
+
+	return text, nil
+}
+
+// sampleEvenly 从按路径排序的文档中均匀采样n篇
+func sampleEvenly(entries []store.DocumentListEntry, n int) []store.DocumentListEntry {
+	if len(entries) <= n {
+		return entries
+	}
+
+	samples := make([]store.DocumentListEntry, 0, n)
+	for i := 0; i < n; i++ {
+		samples = append(samples, entries[i*len(entries)/n])
+	}
+	return samples
+}
+
+// truncateRunes 按字符数截断文本
+func truncateRunes(text string, maxChars int) string {
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	return string(runes[:maxChars]) + "..."
+}
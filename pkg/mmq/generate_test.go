@@ -0,0 +1,162 @@
+package mmq
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newGenerateTestMMQ(t *testing.T) *MMQ {
+	t.Helper()
+
+	m := newRankingTestMMQ(t)
+	if err := m.CreateCollection("kb", "/tmp/kb", CollectionOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 8; i++ {
+		folder := "guides"
+		if i%2 == 1 {
+			folder = "reference"
+		}
+		indexDocs(t, m, Document{
+			Collection: "kb",
+			Path:       fmt.Sprintf("%s/doc%d.md", folder, i),
+			Title:      fmt.Sprintf("Doc %d", i),
+			Content:    fmt.Sprintf("Document number %d in %s.", i, folder),
+		})
+	}
+	indexDocs(t, m, Document{Collection: "kb", Path: "README.md", Title: "Readme", Content: "Top level readme."})
+
+	return m
+}
+
+func TestGenerateContexts(t *testing.T) {
+	m := newGenerateTestMMQ(t)
+
+	generated, err := m.GenerateContexts(GenerateContextsOptions{SampleSize: 3, Folders: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, g := range generated {
+		paths = append(paths, g.Path)
+		t.Logf("%s (sampled %d): %.60s...", g.Path, g.Sampled, g.Content)
+	}
+	want := []string{"qmd://kb", "qmd://kb/guides", "qmd://kb/reference"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Fatalf("Expected targets %v, got %v", want, paths)
+	}
+
+	for _, g := range generated {
+		if g.Sampled != 3 {
+			t.Errorf("Expected 3 sampled documents for %s, got %d", g.Path, g.Sampled)
+		}
+
+		ctx, err := m.GetContext(g.Path)
+		if err != nil {
+			t.Fatalf("Expected context saved for %s: %v", g.Path, err)
+		}
+		if ctx.Content != g.Content {
+			t.Errorf("Saved context differs from generated for %s", g.Path)
+		}
+	}
+
+	// 目录采样只包含该目录下的文档
+	if strings.Contains(generated[1].Content, "reference/") {
+		t.Errorf("Expected guides samples only, got %q", generated[1].Content)
+	}
+
+	// CheckMissingContexts 中的集合已补齐
+	missing, err := m.CheckMissingContexts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range missing {
+		if p == "qmd://kb" {
+			t.Error("Expected collection context to be filled")
+		}
+	}
+}
+
+func TestGenerateContextsSkipAndOverwrite(t *testing.T) {
+	m := newGenerateTestMMQ(t)
+
+	if err := m.AddContext("qmd://kb", "hand written"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 默认不覆盖已有上下文
+	generated, err := m.GenerateContexts(GenerateContextsOptions{Collection: "kb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(generated) != 1 || !generated[0].Skipped {
+		t.Fatalf("Expected existing context skipped, got %+v", generated)
+	}
+
+	// DryRun 只返回结果
+	generated, err = m.GenerateContexts(GenerateContextsOptions{Collection: "kb", Overwrite: true, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(generated) != 1 || generated[0].Skipped || generated[0].Content == "" {
+		t.Fatalf("Expected generated context, got %+v", generated)
+	}
+	if ctx, _ := m.GetContext("qmd://kb"); ctx.Content != "hand written" {
+		t.Errorf("Expected dry run to keep existing context, got %q", ctx.Content)
+	}
+
+	if _, err := m.GenerateContexts(GenerateContextsOptions{Collection: "kb", Overwrite: true}); err != nil {
+		t.Fatal(err)
+	}
+	if ctx, _ := m.GetContext("qmd://kb"); ctx.Content == "hand written" {
+		t.Error("Expected overwrite to replace existing context")
+	}
+
+	// 单个目录
+	generated, err = m.GenerateContexts(GenerateContextsOptions{Collection: "kb", Path: "reference/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(generated) != 1 || generated[0].Path != "qmd://kb/reference" || generated[0].Sampled != 4 {
+		t.Errorf("Expected reference folder context from 4 documents, got %+v", generated)
+	}
+
+	if _, err := m.GenerateContexts(GenerateContextsOptions{Path: "guides"}); err == nil {
+		t.Error("Expected error for path without collection")
+	}
+	if _, err := m.GenerateContexts(GenerateContextsOptions{Collection: "missing"}); err == nil {
+		t.Error("Expected error for unknown collection")
+	}
+}
+
+func TestIndexWithSummaries(t *testing.T) {
+	m := newRankingTestMMQ(t)
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.md": "# Alpha\n\nFirst document.",
+		"b.md": "# Beta\n\nSecond document.",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m.IndexDirectory(dir, IndexOptions{Collection: "docs", Summarize: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"docs/a.md", "docs/b.md"} {
+		summary, err := m.GetDocumentSummary(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary == nil {
+			t.Errorf("Expected summary for %s after indexing", path)
+		}
+	}
+}
@@ -92,6 +92,18 @@ func (m *MMQ) IndexDirectory(path string, opts IndexOptions) error {
 			modTime = info.ModTime()
 		}
 
+		// 结构化数据按行索引
+		if format := structuredFormat(relPath); format != "" {
+			opts := StructuredOptions{Collection: collection, Path: relPath, Format: format}
+			if _, err := m.indexStructured(content, opts, modTime); err != nil {
+				fmt.Printf("Warning: failed to index %s: %v\n", relPath, err)
+				skipped++
+				return nil
+			}
+			indexed++
+			return nil
+		}
+
 		// 提取标题（从文件名或内容）
 		title := extractTitle(string(content), relPath)
 
@@ -130,6 +142,15 @@ func (m *MMQ) IndexDirectory(path string, opts IndexOptions) error {
 
 	fmt.Printf("\nIndexing complete: %d files indexed, %d skipped\n", indexed, skipped)
 
+	// 为新增或内容变化的文档生成摘要
+	if opts.Summarize {
+		summarized, err := m.GenerateSummaries(SummarizeOptions{Collection: collection})
+		if err != nil {
+			return fmt.Errorf("failed to generate summaries: %w", err)
+		}
+		fmt.Printf("Summarized %d documents\n", summarized)
+	}
+
 	return nil
 }
 
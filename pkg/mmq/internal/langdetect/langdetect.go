@@ -0,0 +1,209 @@
+// Package langdetect 纯Go的语言检测
+//
+// 先按文字系统区分：汉字/假名/谚文等直接由字符范围判定；
+// 拉丁字母文本再用字符三元组（n-gram）频率表和常用虚词在常见欧洲语言之间打分。
+package langdetect
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 语言代码（ISO 639-1）
+const (
+	Unknown    = ""
+	English    = "en"
+	German     = "de"
+	French     = "fr"
+	Spanish    = "es"
+	Portuguese = "pt"
+	Italian    = "it"
+	Dutch      = "nl"
+	Chinese    = "zh"
+	Japanese   = "ja"
+	Korean     = "ko"
+	Russian    = "ru"
+	Arabic     = "ar"
+	Greek      = "el"
+	Hebrew     = "he"
+	Thai       = "th"
+	Hindi      = "hi"
+)
+
+const (
+	// cjkWeight 一个汉字/假名/谚文字符约相当于几个拉丁字母（按词的信息量折算）
+	cjkWeight = 3
+	// kanaRatio 假名占CJK字符的比例达到该值时判定为日文
+	kanaRatio = 0.1
+	// minLetters 少于该数量的字母不做判断
+	minLetters = 3
+	// sampleRunes 最多检查的字符数（长文档取开头）
+	sampleRunes = 4096
+	// stopwordWeight 一个虚词相当于几个三元组命中
+	stopwordWeight = 3
+)
+
+// scriptLanguages 只按文字系统判定的语言
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Cyrillic, Russian},
+	{unicode.Arabic, Arabic},
+	{unicode.Greek, Greek},
+	{unicode.Hebrew, Hebrew},
+	{unicode.Thai, Thai},
+	{unicode.Devanagari, Hindi},
+}
+
+// IsCJK 是否为汉字、假名或谚文
+func IsCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// HasCJK 文本是否包含汉字、假名或谚文
+func HasCJK(text string) bool {
+	for _, r := range text {
+		if IsCJK(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Detect 检测文本的主要语言，无法判断时返回Unknown
+// 中英混排时按折算后的字符数取占比较大的一方
+func Detect(text string) string {
+	var han, kana, hangul, latin int
+	scripts := make([]int, len(scriptLanguages))
+
+	n := 0
+	for _, r := range text {
+		if n >= sampleRunes {
+			break
+		}
+		n++
+		if !unicode.IsLetter(r) {
+			continue
+		}
+
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		default:
+			for i, s := range scriptLanguages {
+				if unicode.Is(s.table, r) {
+					scripts[i]++
+					break
+				}
+			}
+		}
+	}
+
+	cjk := han + kana + hangul
+	if cjk*cjkWeight+latin < minLetters && maxInt(scripts) < minLetters {
+		return Unknown
+	}
+
+	// 取折算后占比最大的文字系统
+	best, bestCount := "latin", latin
+	if cjk*cjkWeight > bestCount {
+		best, bestCount = "cjk", cjk*cjkWeight
+	}
+	for i, count := range scripts {
+		if count > bestCount {
+			best, bestCount = scriptLanguages[i].lang, count
+		}
+	}
+
+	switch best {
+	case "cjk":
+		switch {
+		case hangul > han+kana:
+			return Korean
+		case float64(kana) >= kanaRatio*float64(cjk):
+			return Japanese
+		default:
+			return Chinese
+		}
+	case "latin":
+		return detectLatin(text)
+	default:
+		return best
+	}
+}
+
+// detectLatin 用三元组频率表和常用虚词在拉丁字母语言之间打分
+func detectLatin(text string) string {
+	words := latinWords(text)
+	counts := trigrams(words)
+	if len(counts) == 0 {
+		return Unknown
+	}
+
+	best, bestScore := Unknown, 0.0
+	for _, lang := range latinLanguages {
+		profile := latinProfiles[lang]
+		score := 0.0
+		for gram, count := range counts {
+			if rank, ok := profile[gram]; ok {
+				// 排名越靠前权重越高
+				score += float64(count) * (1 + float64(len(profile)-rank)/float64(len(profile)))
+			}
+		}
+		stopwords := latinStopwordSets[lang]
+		for _, w := range words {
+			if stopwords[w] {
+				score += stopwordWeight
+			}
+		}
+		if score > bestScore {
+			best, bestScore = lang, score
+		}
+	}
+	return best
+}
+
+// latinWords 小写的单词序列（最多约sampleRunes个字符）
+func latinWords(text string) []string {
+	var words []string
+	n := 0
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		words = append(words, word)
+		if n += len(word) + 1; n >= sampleRunes {
+			break
+		}
+	}
+	return words
+}
+
+// trigrams 统计单词中的字符三元组（单词两端补空格）
+func trigrams(words []string) map[string]int {
+	counts := make(map[string]int)
+	for _, word := range words {
+		runes := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			counts[string(runes[i:i+3])]++
+		}
+	}
+	return counts
+}
+
+func maxInt(values []int) int {
+	m := 0
+	for _, v := range values {
+		if v > m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,119 @@
+package langdetect
+
+// 拉丁字母语言的高频三元组（按频率降序，空格表示词边界）
+var latinTrigrams = map[string][]string{
+	English: {
+		"the", " th", "he ", "ed ", "nd ", "and", " an", "ing", "ng ", " to",
+		"of ", " of", "to ", "in ", "er ", "is ", " in", "ion", "tio", "re ",
+		"on ", "at ", "es ", "ent", "his", " co", "for", " is", "as ", "ly ",
+		" be", "ter", "or ", "hat", "tha", " wh", " fo", "all", "are", "ith",
+		"wit", " wi", "ati", "ve ", "thi", " re", "hen", "ere", "you", " yo",
+	},
+	German: {
+		"en ", "er ", "der", "ch ", "ein", "ie ", "ich", "sch", "die", " di",
+		" de", "und", "nd ", " un", "cht", "ine", "gen", " ei", " zu", "den",
+		"te ", "in ", "es ", "ung", " da", "das", "ist", " is", "che", "ten",
+		"nde", "it ", "ver", " ve", "mit", " mi", "auf", "sie", " si", "eit",
+		"ber", " be", "ern", "ges", " ge", "ach", "nic", "ht ", "ene", "ier",
+	},
+	French: {
+		"es ", " de", "de ", "le ", "ent", " le", "nt ", "la ", " la", "ion",
+		"on ", " et", "et ", "re ", "les", " co", "que", "ue ", "des", " qu",
+		"ne ", "tio", "ons", "ur ", " pa", "men", " un", "est", " pr", "une",
+		" po", "our", "par", "ais", "ant", " en", "en ", " da", "dan", "ans",
+		"ait", "eur", " so", "pou", " ce", "ell", "lle", "sur", " su", "qui",
+	},
+	Spanish: {
+		"de ", " de", "os ", " la", "la ", "el ", "es ", " qu", "que", "ue ",
+		"en ", " el", "ent", " en", "as ", "nte", "ión", "ció", "con", " co",
+		"los", " lo", "ar ", "ado", "do ", "aci", " se", "del", "las", " po",
+		"por", "er ", "est", "ra ", "una", " un", "ien", "nes", " es", "ón ",
+		" pa", "par", "ara", "sta", "ero", "tra", "mos", "cio", "o e", "res",
+	},
+	Portuguese: {
+		"de ", " de", "os ", "ão ", "do ", " qu", "que", "ue ", " co", "es ",
+		"ent", " a ", "da ", "ção", "açã", " do", "nte", "em ", " da", "as ",
+		"ar ", "com", "ra ", "men", " e ", "par", " pa", "est", "não", " nã",
+		"uma", " um", "dos", "ado", "ida", "ões", " se", "ara", "nto", "ess",
+		" es", "ica", "ame", "mos", "ter", "ist", " po", "por", "ma ", "ele",
+	},
+	Italian: {
+		"di ", " di", "che", " ch", "la ", "to ", "re ", "ell", " de", "del",
+		"one", " la", "lla", "ent", " il", "il ", "per", " pe", "zio", "ion",
+		"ato", "no ", "nte", "ere", "are", "con", " co", " in", "ne ", "ta ",
+		"gli", "le ", "men", "tto", "ono", " un", "una", "ità", "azi", " è ",
+		"sta", "ndo", "ia ", "ala", " ne", "nel", "ll ", "ano", "ale", " so",
+	},
+	Dutch: {
+		"en ", "de ", " de", "an ", "et ", "van", " va", " he", "het", "een",
+		" ee", "ing", "er ", "ij ", "oor", "nd ", "ver", " ve", " in", "in ",
+		"gen", "te ", "aar", " da", "dat", "cht", "voo", " vo", "ijk", "lij",
+		"sch", "ter", "eer", "den", "zij", " zi", "ie ", "ond", "ere", " ni",
+		"nie", "iet", " me", "met", "ook", " oo", "wor", " wo", "ord", "erd",
+	},
+}
+
+// latinStopwords 拉丁字母语言的常用虚词（短文本中比三元组更可靠）
+var latinStopwords = map[string][]string{
+	English: {
+		"the", "and", "of", "to", "in", "is", "it", "that", "for", "on", "with", "as", "are",
+		"be", "this", "by", "or", "but", "not", "you", "from", "at", "was", "have", "can", "will", "up", "an",
+	},
+	German: {
+		"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "den", "mit", "sich", "auf",
+		"für", "von", "dem", "auch", "es", "im", "sind", "wird", "oder", "aber", "wie", "bei", "nach",
+	},
+	French: {
+		"le", "la", "les", "de", "des", "et", "est", "un", "une", "du", "en", "que", "qui",
+		"dans", "pour", "pas", "sur", "au", "avec", "ce", "il", "elle", "sont", "ou", "mais", "par",
+	},
+	Spanish: {
+		"el", "la", "los", "las", "de", "del", "y", "que", "en", "un", "una", "es", "por",
+		"para", "con", "no", "se", "su", "al", "lo", "como", "pero", "más", "está", "son",
+	},
+	Portuguese: {
+		"o", "a", "os", "as", "de", "do", "da", "dos", "das", "e", "que", "em", "um",
+		"uma", "é", "para", "com", "não", "por", "no", "na", "se", "mais", "como", "mas", "são",
+	},
+	Italian: {
+		"il", "lo", "la", "gli", "le", "di", "del", "della", "e", "che", "è", "un", "una",
+		"per", "non", "con", "in", "sono", "si", "da", "al", "come", "ma", "anche", "nel",
+	},
+	Dutch: {
+		"de", "het", "een", "en", "van", "is", "dat", "die", "in", "op", "te", "voor", "met",
+		"niet", "zijn", "aan", "ook", "er", "als", "maar", "bij", "om", "naar", "wordt",
+	},
+}
+
+// latinLanguages 打分顺序（分数相同时靠前的语言优先）
+var latinLanguages = []string{English, German, French, Spanish, Portuguese, Italian, Dutch}
+
+// latinProfiles 语言 -> 三元组 -> 排名
+var latinProfiles = buildProfiles()
+
+// latinStopwordSets 语言 -> 虚词集合
+var latinStopwordSets = buildStopwordSets()
+
+func buildProfiles() map[string]map[string]int {
+	profiles := make(map[string]map[string]int, len(latinTrigrams))
+	for lang, grams := range latinTrigrams {
+		profile := make(map[string]int, len(grams))
+		for rank, gram := range grams {
+			profile[gram] = rank
+		}
+		profiles[lang] = profile
+	}
+	return profiles
+}
+
+func buildStopwordSets() map[string]map[string]bool {
+	sets := make(map[string]map[string]bool, len(latinStopwords))
+	for lang, words := range latinStopwords {
+		set := make(map[string]bool, len(words))
+		for _, w := range words {
+			set[w] = true
+		}
+		sets[lang] = set
+	}
+	return sets
+}
@@ -0,0 +1,29 @@
+// Package tokens 不依赖模型词表的token数估算
+package tokens
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Estimate 估算文本的token数量
+// 启发式规则：CJK字符每个≈1个token，其余文本平均4个字节≈1个token
+func Estimate(text string) int {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0
+	}
+
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other += utf8.RuneLen(r)
+		}
+	}
+
+	return cjk + (other+3)/4
+}
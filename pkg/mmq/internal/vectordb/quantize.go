@@ -0,0 +1,107 @@
+package vectordb
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// QuantizeInt8 对称标量量化：每维映射到 [-127, 127]，返回编码和缩放系数
+// 原值 ≈ float32(int8(code[i])) * scale
+func QuantizeInt8(v []float32) ([]byte, float32) {
+	var maxAbs float64
+	for _, x := range v {
+		if a := math.Abs(float64(x)); a > maxAbs {
+			maxAbs = a
+		}
+	}
+
+	codes := make([]byte, len(v))
+	if maxAbs == 0 {
+		return codes, 0
+	}
+
+	scale := maxAbs / 127
+	for i, x := range v {
+		q := math.Round(float64(x) / scale)
+		if q > 127 {
+			q = 127
+		} else if q < -127 {
+			q = -127
+		}
+		codes[i] = byte(int8(q))
+	}
+
+	return codes, float32(scale)
+}
+
+// DequantizeInt8 将int8编码还原为float32
+func DequantizeInt8(codes []byte, scale float32) []float32 {
+	v := make([]float32, len(codes))
+	for i, c := range codes {
+		v[i] = float32(int8(c)) * scale
+	}
+	return v
+}
+
+// BinaryCode 二值量化：每维取符号位（>0为1），按8维一个字节打包
+func BinaryCode(v []float32) []byte {
+	code := make([]byte, (len(v)+7)/8)
+	for i, x := range v {
+		if x > 0 {
+			code[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return code
+}
+
+// HammingDist 计算两个二值编码的汉明距离（长度不同时多出的字节按全部不同计）
+func HammingDist(a, b []byte) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+
+	dist := 0
+	i := 0
+	for ; i+8 <= n; i += 8 {
+		x := uint64(a[i]) | uint64(a[i+1])<<8 | uint64(a[i+2])<<16 | uint64(a[i+3])<<24 |
+			uint64(a[i+4])<<32 | uint64(a[i+5])<<40 | uint64(a[i+6])<<48 | uint64(a[i+7])<<56
+		y := uint64(b[i]) | uint64(b[i+1])<<8 | uint64(b[i+2])<<16 | uint64(b[i+3])<<24 |
+			uint64(b[i+4])<<32 | uint64(b[i+5])<<40 | uint64(b[i+6])<<48 | uint64(b[i+7])<<56
+		dist += bits.OnesCount64(x ^ y)
+	}
+	for ; i < n; i++ {
+		dist += bits.OnesCount8(a[i] ^ b[i])
+	}
+
+	if len(a) > n {
+		dist += (len(a) - n) * 8
+	} else if len(b) > n {
+		dist += (len(b) - n) * 8
+	}
+	return dist
+}
+
+// NearestByHamming 返回与query汉明距离最小的k个编码的下标（按距离升序，距离相同保持原顺序）
+func NearestByHamming(query []byte, codes [][]byte, k int) []int {
+	if k <= 0 || len(codes) == 0 {
+		return nil
+	}
+
+	idx := make([]int, len(codes))
+	dist := make([]int, len(codes))
+	for i, code := range codes {
+		idx[i] = i
+		dist[i] = HammingDist(query, code)
+	}
+
+	sort.SliceStable(idx, func(i, j int) bool {
+		return dist[idx[i]] < dist[idx[j]]
+	})
+
+	if k < len(idx) {
+		idx = idx[:k]
+	}
+	return idx
+}
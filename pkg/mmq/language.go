@@ -0,0 +1,48 @@
+package mmq
+
+import (
+	"github.com/crosszan/modu/pkg/mmq/internal/langdetect"
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+// LanguageAuto 偏好语言取查询文本检测到的语言
+const LanguageAuto = "auto"
+
+// DetectLanguage 检测文本的主要语言（ISO 639-1代码，如"zh"、"en"），无法判断时返回空字符串
+//
+// 汉字、假名、谚文、西里尔字母等按文字系统判定；拉丁字母文本用三元组频率表
+// 在 en、de、fr、es、pt、it、nl 之间打分。中英混排时按折算后的字符数取占比较大的一方。
+// 索引时对每个文档的内容检测语言，用于 SearchOptions.Language 过滤和 PreferLanguage 排序。
+func DetectLanguage(text string) string {
+	return langdetect.Detect(text)
+}
+
+// DocumentLanguages 返回文档的语言（collection/path -> 语言代码）
+// collection为空时返回全部集合
+func (m *MMQ) DocumentLanguages(collection string) (map[string]string, error) {
+	return m.store.DocumentLanguages(collection)
+}
+
+// languageFetchFactor 偏好语言时候选结果的额外倍数（其他语言的结果会被降权）
+const languageFetchFactor = 5
+
+// documentFilter 检索查询的文档过滤条件（集合、结构化字段和语言）
+func documentFilter(opts SearchOptions) store.DocumentFilter {
+	return store.DocumentFilter{Collection: opts.Collection, Fields: opts.Fields, Language: opts.Language}
+}
+
+// preferredLanguage 解析偏好语言（LanguageAuto时检测查询的语言）
+func preferredLanguage(query, prefer string) string {
+	if prefer == LanguageAuto {
+		return langdetect.Detect(query)
+	}
+	return prefer
+}
+
+// searchFetchLimit 偏好语言时多取候选结果
+func searchFetchLimit(limit int, lang string) int {
+	if lang != "" {
+		return limit * languageFetchFactor
+	}
+	return limit
+}
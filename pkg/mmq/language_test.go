@@ -0,0 +1,298 @@
+package mmq
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crosszan/modu/pkg/mmq/llm"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	cases := []struct {
+		text string
+		want string
+	}{
+		{"The quick brown fox jumps over the lazy dog and runs into the forest.", "en"},
+		{"Der schnelle braune Fuchs springt über den faulen Hund und läuft in den Wald.", "de"},
+		{"Le renard brun rapide saute par-dessus le chien paresseux et court dans la forêt.", "fr"},
+		{"El rápido zorro marrón salta sobre el perro perezoso y corre hacia el bosque.", "es"},
+		{"A raposa marrom rápida pula sobre o cão preguiçoso e não para de correr.", "pt"},
+		{"La volpe marrone veloce salta sopra il cane pigro e corre nella foresta.", "it"},
+		{"De snelle bruine vos springt over de luie hond en rent het bos in.", "nl"},
+		{"数据库索引可以显著提升查询性能。", "zh"},
+		{"使用 Kubernetes 部署服务时需要配置健康检查。", "zh"},
+		{"データベースのインデックスはクエリを高速化します。", "ja"},
+		{"데이터베이스 인덱스는 쿼리 성능을 향상시킵니다.", "ko"},
+		{"Быстрая коричневая лиса прыгает через ленивую собаку.", "ru"},
+		{"Database indexes speed up queries but add write overhead.", "en"},
+		{"Go uses goroutines and channels for concurrent programming.", "en"},
+		{"Run the tests before merging the branch.", "en"},
+		{"Die Datenbank ist nicht erreichbar.", "de"},
+		{"La base de données est indisponible.", "fr"},
+		{"La base de datos no está disponible.", "es"},
+		{"The term 数据库 means database in Chinese, and it is used everywhere in this guide.", "en"},
+		{"42", ""},
+		{"", ""},
+	}
+
+	for _, tc := range cases {
+		got := DetectLanguage(tc.text)
+		t.Logf("%q -> %q", tc.text, got)
+		if got != tc.want {
+			t.Errorf("DetectLanguage(%q) = %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
+
+// indexMixedCorpus 中英混合语料
+func indexMixedCorpus(t *testing.T, m *MMQ) {
+	t.Helper()
+	indexDocs(t, m,
+		Document{Collection: "kb", Path: "zh/db.md", Title: "数据库索引", Content: "数据库索引可以显著提升查询性能，但会增加写入开销。"},
+		Document{Collection: "kb", Path: "zh/go.md", Title: "Go 并发", Content: "Go 语言通过 goroutine 和 channel 实现并发编程。"},
+		Document{Collection: "kb", Path: "en/db.md", Title: "Database indexes", Content: "Database indexes speed up queries but add write overhead."},
+		Document{Collection: "kb", Path: "en/go.md", Title: "Go concurrency", Content: "Go uses goroutines and channels for concurrent programming."},
+	)
+}
+
+func TestChineseFullTextSearch(t *testing.T) {
+	m, err := NewWithDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	indexMixedCorpus(t, m)
+
+	// 中文词出现在连续汉字中间也能命中（双字切分）
+	for query, want := range map[string]string{
+		"查询性能":         "zh/db.md",
+		"写入":           "zh/db.md",
+		"并发编程":         "zh/go.md",
+		"goroutine 并发": "zh/go.md",
+	} {
+		results, err := m.Search(query, SearchOptions{Limit: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("%s -> %v", query, searchPaths(results))
+		if len(results) != 1 || results[0].Path != want {
+			t.Errorf("Search(%q): expected only %s, got %v", query, want, searchPaths(results))
+		}
+	}
+
+	// 英文查询仍使用原索引
+	results, err := m.Search("goroutines", SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected both Go documents for an English query, got %v", searchPaths(results))
+	}
+}
+
+func TestLanguageFilterAndPreference(t *testing.T) {
+	m, err := NewWithDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	indexMixedCorpus(t, m)
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+
+	languages, err := m.DocumentLanguages("kb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("languages: %v", languages)
+	if languages["kb/zh/go.md"] != "zh" || languages["kb/en/go.md"] != "en" {
+		t.Errorf("Unexpected languages %v", languages)
+	}
+
+	results, err := m.Search("Go", SearchOptions{Limit: 5, Language: "zh"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != "zh/go.md" {
+		t.Errorf("Expected only the Chinese Go document, got %v", searchPaths(results))
+	}
+
+	for name, search := range map[string]func(string, SearchOptions) ([]SearchResult, error){
+		"vsearch": m.VectorSearch,
+		"hybrid":  m.HybridSearch,
+	} {
+		results, err := search("database", SearchOptions{Limit: 4, Language: "en"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			if !strings.HasPrefix(r.Path, "en/") {
+				t.Errorf("[%s] Expected only English documents, got %v", name, searchPaths(results))
+				break
+			}
+		}
+	}
+
+	// 偏好查询语言：中文文档排在英文文档之前
+	results, err = m.Search("Go", SearchOptions{Limit: 5, PreferLanguage: "zh"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Path != "zh/go.md" {
+		t.Errorf("Expected the Chinese document first, got %v", searchPaths(results))
+	}
+
+	contexts, err := m.RetrieveContext("Go 语言的并发", RetrieveOptions{Limit: 4, Strategy: StrategyHybrid, PreferLanguage: LanguageAuto})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contexts) == 0 || !strings.HasPrefix(getMetadataString(contexts[0].Metadata, "path"), "zh/") {
+		t.Errorf("Expected a Chinese document first with auto preference, got %+v", contexts)
+	}
+}
+
+func TestLanguageFilterInQuery(t *testing.T) {
+	m, err := NewWithDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	// 英文文档的得分全部高于德文文档，语言过滤必须在查询中应用才能返回德文文档
+	var docs []Document
+	for i := 0; i < 20; i++ {
+		docs = append(docs, Document{
+			Collection: "kb",
+			Path:       fmt.Sprintf("en/%02d.md", i),
+			Title:      "Go concurrency",
+			Content:    "Go channels and Go goroutines make Go concurrency simple in Go programs.",
+		})
+	}
+	docs = append(docs, Document{
+		Collection: "kb",
+		Path:       "de/go.md",
+		Title:      "Nebenläufigkeit",
+		Content:    "Die Programmiersprache Go ist für nebenläufige Programme und die Arbeit mit Kanälen gedacht.",
+	})
+	indexDocs(t, m, docs...)
+
+	results, err := m.Search("Go", SearchOptions{Limit: 1, Language: "de"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != "de/go.md" || results[0].Language != "de" {
+		t.Errorf("Expected the German document, got %+v", results)
+	}
+}
+
+func TestCJKIndexFollowsDocumentChanges(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	m, err := NewWithDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	indexMixedCorpus(t, m)
+
+	// 修改文档内容后旧内容不再命中
+	indexDocs(t, m, Document{Collection: "kb", Path: "zh/db.md", Title: "数据库索引", Content: "索引需要定期维护。"})
+	results, err := m.Search("查询性能", SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected stale CJK rows removed, got %v", searchPaths(results))
+	}
+	results, err = m.Search("定期维护", SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Language != "zh" {
+		t.Errorf("Expected the updated document in Chinese, got %+v", results)
+	}
+
+	// 文档表的触发器只使用内置SQL，标准驱动也能更新文档
+	plain, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	if _, err := plain.Exec("UPDATE documents SET active = 0 WHERE path = 'zh/db.md'"); err != nil {
+		t.Fatalf("Expected document triggers to work without custom functions: %v", err)
+	}
+	results, err = m.Search("定期维护", SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected deactivated document removed from the CJK index, got %v", searchPaths(results))
+	}
+}
+
+func TestLanguageBackfill(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	m, err := NewWithDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexMixedCorpus(t, m)
+
+	// 模拟旧版本写入的数据：没有语言和CJK索引
+	db := m.GetStore().DB()
+	for _, stmt := range []string{"DELETE FROM content_languages", "DELETE FROM documents_fts_cjk"} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Close()
+
+	m, err = NewWithDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	languages, err := m.DocumentLanguages("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if languages["kb/zh/db.md"] != "zh" {
+		t.Errorf("Expected language backfilled, got %v", languages)
+	}
+
+	results, err := m.Search("查询性能", SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != "zh/db.md" {
+		t.Errorf("Expected CJK index backfilled, got %v", searchPaths(results))
+	}
+}
+
+func TestEmbeddingPromptByLanguage(t *testing.T) {
+	cases := []struct {
+		model, text string
+		isQuery     bool
+		want        string
+	}{
+		{"models/embeddinggemma-300M-Q8_0.gguf", "数据库", true, "task: search result | query: 数据库"},
+		{"models/embeddinggemma-300M-Q8_0.gguf", "database", false, "title: none | text: database"},
+		{"bge-small-zh-v1.5.gguf", "数据库索引怎么用", true, "为这个句子生成表示以用于检索相关文章：数据库索引怎么用"},
+		{"bge-small-en-v1.5.gguf", "how do database indexes work", true, "Represent this sentence for searching relevant passages: how do database indexes work"},
+		{"bge-small-zh-v1.5.gguf", "数据库索引", false, "数据库索引"},
+		{"bge-m3.gguf", "数据库索引怎么用", true, "数据库索引怎么用"},
+		{"multilingual-e5-small.gguf", "数据库", false, "passage: 数据库"},
+		{"nomic-embed-text-v1.5.gguf", "database", true, "search_query: database"},
+		{"unknown.gguf", "database", true, "task: search result | query: database"},
+	}
+
+	for _, tc := range cases {
+		if got := llm.FormatForEmbedding(tc.model, tc.text, tc.isQuery); got != tc.want {
+			t.Errorf("FormatForEmbedding(%q, %q, %v) = %q, want %q", tc.model, tc.text, tc.isQuery, got, tc.want)
+		}
+	}
+}
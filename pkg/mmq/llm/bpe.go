@@ -0,0 +1,234 @@
+package llm
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultBPEPattern 默认预分词正则（cl100k_base，去掉RE2不支持的 \s+(?!\S) 前瞻，
+// 其效果由 BPETokenizer.split 补偿）
+const DefaultBPEPattern = `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`
+
+// bpeCacheSize 片段编码缓存上限，超过后清空
+const bpeCacheSize = 10000
+
+// BPETokenizer 字节级BPE分词器（兼容tiktoken词表文件）
+type BPETokenizer struct {
+	ranks   map[string]int
+	decoder map[int]string
+	pattern *regexp.Regexp
+
+	mu    sync.RWMutex
+	cache map[string][]int
+}
+
+// NewBPETokenizer 使用词表（token字节 -> rank）和预分词正则创建分词器
+// pattern为空时使用DefaultBPEPattern
+func NewBPETokenizer(ranks map[string]int, pattern string) (*BPETokenizer, error) {
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("empty vocabulary")
+	}
+	if pattern == "" {
+		pattern = DefaultBPEPattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	decoder := make(map[int]string, len(ranks))
+	for token, rank := range ranks {
+		decoder[rank] = token
+	}
+
+	return &BPETokenizer{
+		ranks:   ranks,
+		decoder: decoder,
+		pattern: re,
+		cache:   make(map[string][]int),
+	}, nil
+}
+
+// LoadTiktoken 从本地tiktoken词表文件加载分词器
+// 文件每行格式为 "<base64编码的token> <rank>"（如 cl100k_base.tiktoken）
+func LoadTiktoken(path string, pattern string) (*BPETokenizer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vocabulary: %w", err)
+	}
+	defer file.Close()
+
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		encoded, rankStr, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid vocabulary line %d", lineNo)
+		}
+		token, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token on line %d: %w", lineNo, err)
+		}
+		rank, err := strconv.Atoi(rankStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rank on line %d: %w", lineNo, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read vocabulary: %w", err)
+	}
+
+	return NewBPETokenizer(ranks, pattern)
+}
+
+// VocabSize 词表大小
+func (t *BPETokenizer) VocabSize() int {
+	return len(t.ranks)
+}
+
+// Encode 将文本编码为token序列
+func (t *BPETokenizer) Encode(text string) []int {
+	var tokens []int
+	for _, piece := range t.split(text) {
+		tokens = append(tokens, t.encodePiece(piece)...)
+	}
+	return tokens
+}
+
+// Decode 将token序列解码为文本（未知token被忽略）
+func (t *BPETokenizer) Decode(tokens []int) string {
+	var builder strings.Builder
+	for _, id := range tokens {
+		builder.WriteString(t.decoder[id])
+	}
+	return builder.String()
+}
+
+// Count 返回文本的token数
+func (t *BPETokenizer) Count(text string) int {
+	count := 0
+	for _, piece := range t.split(text) {
+		count += len(t.encodePiece(piece))
+	}
+	return count
+}
+
+// split 预分词
+// 纯空白片段后紧跟非空白时，最后一个空白字符留给下一个片段（等价于 \s+(?!\S)）
+func (t *BPETokenizer) split(text string) []string {
+	var pieces []string
+
+	for pos := 0; pos < len(text); {
+		loc := t.pattern.FindStringIndex(text[pos:])
+		if loc == nil {
+			pieces = append(pieces, text[pos:])
+			break
+		}
+		if loc[0] > 0 {
+			pieces = append(pieces, text[pos:pos+loc[0]])
+		}
+
+		start, end := pos+loc[0], pos+loc[1]
+		if end == start {
+			// 空匹配，前进一个字符避免死循环
+			_, size := utf8.DecodeRuneInString(text[start:])
+			end = start + size
+		}
+
+		piece := text[start:end]
+		if end < len(text) && isAllSpace(piece) && utf8.RuneCountInString(piece) > 1 {
+			_, size := utf8.DecodeLastRuneInString(piece)
+			end -= size
+			piece = text[start:end]
+		}
+
+		pieces = append(pieces, piece)
+		pos = end
+	}
+
+	return pieces
+}
+
+// encodePiece 对单个片段执行字节对合并
+func (t *BPETokenizer) encodePiece(piece string) []int {
+	if rank, ok := t.ranks[piece]; ok {
+		return []int{rank}
+	}
+
+	t.mu.RLock()
+	cached, ok := t.cache[piece]
+	t.mu.RUnlock()
+	if ok {
+		return cached
+	}
+
+	// 初始按字节切分
+	parts := make([]string, len(piece))
+	for i := 0; i < len(piece); i++ {
+		parts[i] = piece[i : i+1]
+	}
+
+	// 反复合并rank最小的相邻对
+	for len(parts) > 1 {
+		best, bestRank := -1, 0
+		for i := 0; i < len(parts)-1; i++ {
+			if rank, ok := t.ranks[parts[i]+parts[i+1]]; ok && (best == -1 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best == -1 {
+			break
+		}
+
+		parts[best] += parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+
+	tokens := make([]int, 0, len(parts))
+	for _, part := range parts {
+		if rank, ok := t.ranks[part]; ok {
+			tokens = append(tokens, rank)
+		} else {
+			// 词表缺少单字节时每个字节计为一个token
+			for i := 0; i < len(part); i++ {
+				tokens = append(tokens, -1)
+			}
+		}
+	}
+
+	t.mu.Lock()
+	if len(t.cache) >= bpeCacheSize {
+		t.cache = make(map[string][]int)
+	}
+	t.cache[piece] = tokens
+	t.mu.Unlock()
+
+	return tokens
+}
+
+// isAllSpace 是否全部为空白字符
+func isAllSpace(s string) bool {
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return s != ""
+}
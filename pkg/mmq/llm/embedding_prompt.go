@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/crosszan/modu/pkg/mmq/internal/langdetect"
+)
+
+// EmbeddingPrompt 嵌入模型的查询/文档前缀
+// 非对称检索模型要求查询和文档使用不同的前缀，前缀与训练时不一致会明显降低召回
+type EmbeddingPrompt struct {
+	Query    string
+	Document string
+}
+
+// defaultEmbeddingPrompt 默认前缀（EmbeddingGemma格式）
+var defaultEmbeddingPrompt = EmbeddingPrompt{
+	Query:    "task: search result | query: ",
+	Document: "title: none | text: ",
+}
+
+// embeddingPrompts 按模型名称（小写子串，先匹配先用）和语言选择前缀，lang为空表示任意语言
+var embeddingPrompts = []struct {
+	model  string
+	lang   string
+	prompt EmbeddingPrompt
+}{
+	{"embeddinggemma", "", defaultEmbeddingPrompt},
+	{"bge-m3", "", EmbeddingPrompt{}}, // 不需要指令
+	{"bge", langdetect.Chinese, EmbeddingPrompt{Query: "为这个句子生成表示以用于检索相关文章："}},
+	{"bge", "", EmbeddingPrompt{Query: "Represent this sentence for searching relevant passages: "}},
+	{"e5", "", EmbeddingPrompt{Query: "query: ", Document: "passage: "}},
+	{"nomic", "", EmbeddingPrompt{Query: "search_query: ", Document: "search_document: "}},
+	{"qwen3-embedding", "", EmbeddingPrompt{
+		Query: "Instruct: Given a web search query, retrieve relevant passages that answer the query\nQuery: ",
+	}},
+}
+
+// EmbeddingPromptFor 返回模型在指定语言下的查询/文档前缀
+// model为模型名称或路径（按文件名匹配），未知模型使用EmbeddingGemma格式
+func EmbeddingPromptFor(model, lang string) EmbeddingPrompt {
+	name := strings.ToLower(filepath.Base(model))
+	for _, p := range embeddingPrompts {
+		if strings.Contains(name, p.model) && (p.lang == "" || p.lang == lang) {
+			return p.prompt
+		}
+	}
+	return defaultEmbeddingPrompt
+}
+
+// FormatForEmbedding 按模型和文本语言添加查询/文档前缀
+func FormatForEmbedding(model, text string, isQuery bool) string {
+	prompt := EmbeddingPromptFor(model, langdetect.Detect(text))
+	if isQuery {
+		return prompt.Query + text
+	}
+	return prompt.Document + text
+}
@@ -202,8 +202,8 @@ func (l *LlamaCpp) Embed(text string, isQuery bool) ([]float32, error) {
 		return nil, fmt.Errorf("embedding model not loaded")
 	}
 
-	// 格式化文本
-	formatted := formatTextForEmbedding(text, isQuery)
+	// 格式化文本（前缀按模型和文本语言选择）
+	formatted := FormatForEmbedding(l.embeddingModelPath, text, isQuery)
 
 	// 生成嵌入
 	embedding, err := model.Embeddings(formatted)
@@ -376,14 +376,6 @@ func (l *LlamaCpp) Close() error {
 	return nil
 }
 
-// formatTextForEmbedding 格式化文本用于嵌入
-func formatTextForEmbedding(text string, isQuery bool) string {
-	if isQuery {
-		return fmt.Sprintf("task: search result | query: %s", text)
-	}
-	return fmt.Sprintf("title: none | text: %s", text)
-}
-
 // sortRerankResults 按分数排序重排结果
 func sortRerankResults(results []RerankResult) {
 	// 使用简单的冒泡排序（实际应该用更高效的排序算法）
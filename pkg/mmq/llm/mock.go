@@ -1,3 +1,4 @@
+//go:build !llama
 // +build !llama
 
 package llm
@@ -7,12 +8,17 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"sync"
 )
 
 // MockLLM 模拟LLM实现（用于测试和开发）
+// 可被多个goroutine并发使用
 type MockLLM struct {
 	dimensions int
+	mu         sync.RWMutex
 	loaded     map[ModelType]bool
+	responses  []string // 预设的生成结果，按调用顺序依次返回
+	prompts    []string // 收到的生成提示
 }
 
 // NewMockLLM 创建模拟LLM实例
@@ -29,7 +35,7 @@ func (m *MockLLM) Embed(text string, isQuery bool) ([]float32, error) {
 		return nil, fmt.Errorf("empty text")
 	}
 
-	m.loaded[ModelTypeEmbedding] = true
+	m.markLoaded(ModelTypeEmbedding)
 
 	// 生成确定性的伪随机向量
 	embedding := make([]float32, m.dimensions)
@@ -67,7 +73,7 @@ func (m *MockLLM) EmbedBatch(texts []string, isQuery bool) ([][]float32, error)
 
 // Rerank 模拟重排
 func (m *MockLLM) Rerank(query string, docs []Document) ([]RerankResult, error) {
-	m.loaded[ModelTypeRerank] = true
+	m.markLoaded(ModelTypeRerank)
 
 	results := make([]RerankResult, len(docs))
 
@@ -90,24 +96,59 @@ func (m *MockLLM) Rerank(query string, docs []Document) ([]RerankResult, error)
 }
 
 // Generate 生成文本
+// 设置了预设结果时依次返回，用完后回退到模拟生成
 func (m *MockLLM) Generate(prompt string, opts GenerateOptions) (string, error) {
-	m.loaded[ModelTypeGenerate] = true
+	m.markLoaded(ModelTypeGenerate)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prompts = append(m.prompts, prompt)
+	if len(m.responses) > 0 {
+		response := m.responses[0]
+		m.responses = m.responses[1:]
+		return response, nil
+	}
 
 	// 简单的模拟生成
 	return fmt.Sprintf("Mock generated response for: %s", prompt), nil
 }
 
+// SetResponses 预设后续Generate调用的返回结果（用于测试依赖LLM输出的逻辑）
+func (m *MockLLM) SetResponses(responses ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.responses = append([]string(nil), responses...)
+}
+
+// Prompts 返回Generate收到的全部提示
+func (m *MockLLM) Prompts() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string(nil), m.prompts...)
+}
+
 // Close 关闭
 func (m *MockLLM) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.loaded = make(map[ModelType]bool)
 	return nil
 }
 
 // IsLoaded 检查模型是否已加载
 func (m *MockLLM) IsLoaded(modelType ModelType) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.loaded[modelType]
 }
 
+// markLoaded 标记模型已加载
+func (m *MockLLM) markLoaded(modelType ModelType) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.loaded[modelType] = true
+}
+
 // computeSimpleTextSimilarity 计算简单的文本相似度
 func computeSimpleTextSimilarity(query, doc string) float64 {
 	// 简化版本：统计共同词汇
@@ -0,0 +1,20 @@
+package llm
+
+import (
+	"github.com/crosszan/modu/pkg/mmq/internal/tokens"
+)
+
+// Tokenizer 分词器接口，用于按模型词表精确计算token预算
+type Tokenizer interface {
+	// Count 返回文本编码后的token数
+	Count(text string) int
+}
+
+// HeuristicTokenizer 启发式估算（没有词表时的回退实现）
+// CJK字符按1个token计，其余文本按4字节≈1个token计
+type HeuristicTokenizer struct{}
+
+// Count 估算文本的token数
+func (HeuristicTokenizer) Count(text string) int {
+	return tokens.Estimate(text)
+}
@@ -0,0 +1,48 @@
+//go:build llama
+// +build llama
+
+package llm
+
+// llamaTokenizer 使用llama.cpp模型词表计数
+type llamaTokenizer struct {
+	l         *LlamaCpp
+	modelType ModelType
+}
+
+// Tokenizer 返回使用指定模型词表的分词器
+// 计数时按需加载模型；模型不可用时退化为启发式估算
+func (l *LlamaCpp) Tokenizer(modelType ModelType) Tokenizer {
+	return &llamaTokenizer{l: l, modelType: modelType}
+}
+
+// Count 返回文本在模型词表下的token数
+func (t *llamaTokenizer) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+
+	if err := t.l.loadModel(t.modelType); err != nil {
+		return HeuristicTokenizer{}.Count(text)
+	}
+
+	t.l.mu.RLock()
+	defer t.l.mu.RUnlock()
+
+	var model = t.l.generateModel
+	switch t.modelType {
+	case ModelTypeEmbedding:
+		model = t.l.embeddingModel
+	case ModelTypeRerank:
+		model = t.l.rerankModel
+	}
+	if model == nil {
+		return HeuristicTokenizer{}.Count(text)
+	}
+
+	n, _, err := model.TokenizeString(text)
+	if err != nil {
+		return HeuristicTokenizer{}.Count(text)
+	}
+
+	return int(n)
+}
@@ -3,10 +3,13 @@ package memory
 import (
 	"fmt"
 	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/llm"
 )
 
 // ConversationTurn 对话轮次
 type ConversationTurn struct {
+	ID        string // 记忆ID（读取时填充）
 	User      string
 	Assistant string
 	SessionID string
@@ -16,12 +19,30 @@ type ConversationTurn struct {
 
 // ConversationMemory 对话记忆管理
 type ConversationMemory struct {
-	manager *Manager
+	manager    *Manager
+	tokenizer  llm.Tokenizer
+	summarizer Summarizer // 为nil时上下文窗口不生成摘要
 }
 
 // NewConversationMemory 创建对话记忆管理器
 func NewConversationMemory(manager *Manager) *ConversationMemory {
-	return &ConversationMemory{manager: manager}
+	return &ConversationMemory{
+		manager:   manager,
+		tokenizer: llm.HeuristicTokenizer{},
+	}
+}
+
+// SetTokenizer 设置计算消息token数的分词器（nil时使用启发式估算）
+func (c *ConversationMemory) SetTokenizer(tokenizer llm.Tokenizer) {
+	if tokenizer == nil {
+		tokenizer = llm.HeuristicTokenizer{}
+	}
+	c.tokenizer = tokenizer
+}
+
+// SetSummarizer 设置上下文窗口使用的摘要生成器
+func (c *ConversationMemory) SetSummarizer(summarizer Summarizer) {
+	c.summarizer = summarizer
 }
 
 // StoreTurn 存储对话轮次
@@ -37,6 +58,7 @@ func (c *ConversationMemory) StoreTurn(turn ConversationTurn) error {
 	metadata["session_id"] = turn.SessionID
 
 	mem := Memory{
+		Namespace:  Namespace{Session: turn.SessionID},
 		Type:       MemoryTypeConversation,
 		Content:    content,
 		Metadata:   metadata,
@@ -50,7 +72,7 @@ func (c *ConversationMemory) StoreTurn(turn ConversationTurn) error {
 // GetHistory 获取会话历史
 func (c *ConversationMemory) GetHistory(sessionID string, maxTurns int) ([]ConversationTurn, error) {
 	// 从store获取指定会话的记忆
-	memories, err := c.manager.store.GetMemoriesBySession(sessionID, maxTurns)
+	memories, err := c.manager.store.GetMemoriesBySession(sessionID, maxTurns, c.manager.ownScope())
 	if err != nil {
 		return nil, err
 	}
@@ -58,17 +80,13 @@ func (c *ConversationMemory) GetHistory(sessionID string, maxTurns int) ([]Conve
 	turns := make([]ConversationTurn, 0, len(memories))
 	for _, mem := range memories {
 		turn := ConversationTurn{
+			ID:        mem.ID,
 			SessionID: sessionID,
 			Timestamp: mem.Timestamp,
 			Metadata:  mem.Metadata,
 		}
 
-		if userMsg, ok := mem.Metadata["user_msg"].(string); ok {
-			turn.User = userMsg
-		}
-		if assistantMsg, ok := mem.Metadata["assistant_msg"].(string); ok {
-			turn.Assistant = assistantMsg
-		}
+		fillTurn(&turn, mem.Content, mem.Metadata)
 
 		turns = append(turns, turn)
 	}
@@ -94,19 +112,13 @@ func (c *ConversationMemory) SearchHistory(query string, limit int) ([]Conversat
 	turns := make([]ConversationTurn, 0, len(memories))
 	for _, mem := range memories {
 		turn := ConversationTurn{
+			ID:        mem.ID,
 			Timestamp: mem.Timestamp,
 			Metadata:  mem.Metadata,
 		}
 
-		if sessionID, ok := mem.Metadata["session_id"].(string); ok {
-			turn.SessionID = sessionID
-		}
-		if userMsg, ok := mem.Metadata["user_msg"].(string); ok {
-			turn.User = userMsg
-		}
-		if assistantMsg, ok := mem.Metadata["assistant_msg"].(string); ok {
-			turn.Assistant = assistantMsg
-		}
+		turn.SessionID = mem.Namespace.Session
+		fillTurn(&turn, mem.Content, mem.Metadata)
 
 		turns = append(turns, turn)
 	}
@@ -116,7 +128,7 @@ func (c *ConversationMemory) SearchHistory(query string, limit int) ([]Conversat
 
 // GetRecentTurns 获取最近的对话轮次（所有会话）
 func (c *ConversationMemory) GetRecentTurns(limit int) ([]ConversationTurn, error) {
-	memories, err := c.manager.store.GetRecentMemoriesByType(string(MemoryTypeConversation), limit)
+	memories, err := c.manager.store.GetRecentMemoriesByType(string(MemoryTypeConversation), limit, c.manager.ownScope())
 	if err != nil {
 		return nil, err
 	}
@@ -124,19 +136,13 @@ func (c *ConversationMemory) GetRecentTurns(limit int) ([]ConversationTurn, erro
 	turns := make([]ConversationTurn, 0, len(memories))
 	for _, mem := range memories {
 		turn := ConversationTurn{
+			ID:        mem.ID,
 			Timestamp: mem.Timestamp,
 			Metadata:  mem.Metadata,
 		}
 
-		if sessionID, ok := mem.Metadata["session_id"].(string); ok {
-			turn.SessionID = sessionID
-		}
-		if userMsg, ok := mem.Metadata["user_msg"].(string); ok {
-			turn.User = userMsg
-		}
-		if assistantMsg, ok := mem.Metadata["assistant_msg"].(string); ok {
-			turn.Assistant = assistantMsg
-		}
+		turn.SessionID = mem.Namespace.Session
+		fillTurn(&turn, mem.Content, mem.Metadata)
 
 		turns = append(turns, turn)
 	}
@@ -144,17 +150,46 @@ func (c *ConversationMemory) GetRecentTurns(limit int) ([]ConversationTurn, erro
 	return turns, nil
 }
 
-// ClearSession 清除指定会话的历史
+// ClearSession 清除指定会话的历史（及其滚动摘要）
 func (c *ConversationMemory) ClearSession(sessionID string) (int, error) {
-	return c.manager.store.DeleteMemoriesBySession(sessionID)
+	deleted, err := c.manager.store.DeleteMemoriesBySession(sessionID, c.manager.ownScope())
+	if err != nil {
+		return deleted, err
+	}
+
+	if _, err := c.manager.store.DeleteConversationSummaries(sessionID, c.manager.ownScope()); err != nil {
+		return deleted, fmt.Errorf("failed to delete conversation summaries: %w", err)
+	}
+	return deleted, nil
 }
 
 // GetSessionIDs 获取所有会话ID
 func (c *ConversationMemory) GetSessionIDs() ([]string, error) {
-	return c.manager.store.GetSessionIDs()
+	return c.manager.store.GetSessionIDs(c.manager.ownScope())
 }
 
 // CountBySession 统计指定会话的对话轮次数
 func (c *ConversationMemory) CountBySession(sessionID string) (int, error) {
-	return c.manager.store.CountMemoriesBySession(sessionID)
+	return c.manager.store.CountMemoriesBySession(sessionID, c.manager.ownScope())
+}
+
+// fillTurn 从对话记忆中填充轮次内容
+// 按消息写入的记忆根据角色填入用户或助手一侧
+func fillTurn(turn *ConversationTurn, content string, metadata map[string]interface{}) {
+	if role, ok := metadata["role"].(string); ok {
+		switch MessageRole(role) {
+		case RoleUser:
+			turn.User = content
+		case RoleAssistant:
+			turn.Assistant = content
+		}
+		return
+	}
+
+	if userMsg, ok := metadata["user_msg"].(string); ok {
+		turn.User = userMsg
+	}
+	if assistantMsg, ok := metadata["assistant_msg"].(string); ok {
+		turn.Assistant = assistantMsg
+	}
 }
@@ -0,0 +1,303 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/internal/vectordb"
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+// DefaultDedupImportanceBoost 每合并一条重复记忆增加的重要性
+const DefaultDedupImportanceBoost = 0.1
+
+// 合并来源记录在metadata中的键
+const (
+	MetadataProvenance = "provenance"  // 被合并记忆的内容、时间和元数据列表
+	MetadataMergeCount = "merge_count" // 累计合并的记忆数
+)
+
+// maxProvenance provenance最多保留的条数（保留最近的）
+const maxProvenance = 20
+
+// DedupOptions 语义去重选项
+// 对话记忆按轮次记录历史、情景记忆按时间记录事件，都不参与去重；
+// 偏好只与取值相同的偏好合并（"喜欢X"和"不喜欢X"语义相近但不是重复）
+type DedupOptions struct {
+	Threshold       float64 // 同一命名空间、同一类型的记忆余弦相似度达到该值视为重复，0表示不去重
+	ImportanceBoost float64 // 每合并一条增加的重要性，0时使用DefaultDedupImportanceBoost
+	DryRun          bool    // 只统计不修改（仅用于Dedupe）
+}
+
+func (o DedupOptions) boost() float64 {
+	if o.ImportanceBoost > 0 {
+		return o.ImportanceBoost
+	}
+	return DefaultDedupImportanceBoost
+}
+
+// DedupStats 批量去重统计
+type DedupStats struct {
+	Scanned int // 检查的记忆数
+	Merged  int // 合并后删除的重复记忆数
+	Kept    int // 吸收了重复记忆的记忆数
+}
+
+// SetDedup 设置写入时的语义去重（Threshold为0时关闭）
+// 开启后Store遇到近似重复的记忆不再插入新行，而是合并到已有记忆
+func (m *Manager) SetDedup(opts DedupOptions) {
+	m.dedup = opts
+}
+
+// dedupable 该类型的记忆是否参与去重
+func dedupable(memType MemoryType) bool {
+	return memType != MemoryTypeConversation && memType != MemoryTypeEpisodic
+}
+
+// findDuplicate 查找与新记忆重复的已有记忆（命名空间和类型都相同，且未过期）
+// 命名空间、过期和偏好取值都在查询中过滤，只需取最相似的一条
+func (m *Manager) findDuplicate(ns Namespace, mem Memory, embedding []float32) (*Memory, error) {
+	target := ns.toStore()
+	filter := store.MemoryFilter{
+		Types:     []string{string(mem.Type)},
+		Namespace: &target,
+		Expiry:    store.ExpiryActive,
+	}
+	if mem.Type == MemoryTypePreference {
+		filter.Metadata = map[string]interface{}{"value": mem.Metadata["value"]}
+	}
+
+	results, err := m.store.SearchMemories(embedding, 1, filter, []store.Namespace{target})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search duplicates: %w", err)
+	}
+	if len(results) == 0 || results[0].Relevance < m.dedup.Threshold {
+		return nil, nil
+	}
+	dup := memoryFromResult(results[0])
+	return &dup, nil
+}
+
+// mergeable 两条同组记忆是否可以合并：偏好的取值必须相同（与findDuplicate的查询条件一致）
+func mergeable(a, b store.MemoryResult) bool {
+	if MemoryType(a.Type) != MemoryTypePreference {
+		return true
+	}
+	x, errX := json.Marshal(a.Metadata["value"])
+	y, errY := json.Marshal(b.Metadata["value"])
+	return errX == nil && errY == nil && string(x) == string(y)
+}
+
+// mergeOf 合并后的记忆写回时的参数
+func mergeOf(mem Memory, duplicates []string) store.MemoryMerge {
+	return store.MemoryMerge{
+		ID:         mem.ID,
+		Metadata:   mem.Metadata,
+		Tags:       mem.Tags,
+		Timestamp:  mem.Timestamp,
+		ExpiresAt:  mem.ExpiresAt,
+		Importance: mem.Importance,
+		Duplicates: duplicates,
+	}
+}
+
+// Dedupe 批量合并命名空间内已有的近似重复记忆
+// 按写入时间顺序处理，较早的记忆保留并吸收之后的重复记忆；两两比较，适合记忆量不大的场景
+func (m *Manager) Dedupe(opts DedupOptions) (*DedupStats, error) {
+	if opts.Threshold <= 0 {
+		return nil, fmt.Errorf("dedupe threshold must be positive")
+	}
+
+	results, err := m.store.GetMemoriesWithEmbeddings(m.ownScope())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load memories: %w", err)
+	}
+
+	type keptMemory struct {
+		result     store.MemoryResult
+		mem        Memory
+		duplicates []string
+	}
+	type groupKey struct {
+		ns      store.Namespace
+		memType string
+	}
+
+	stats := &DedupStats{}
+	groups := make(map[groupKey][]*keptMemory)
+	var changed []*keptMemory
+	now := time.Now()
+
+	for _, r := range results {
+		if !dedupable(MemoryType(r.Type)) || linkedFact(r.Metadata) {
+			continue
+		}
+		stats.Scanned++
+		if r.ExpiresAt != nil && r.ExpiresAt.Before(now) {
+			continue // 过期记忆交给CleanupExpired
+		}
+
+		key := groupKey{r.Namespace, r.Type}
+		var best *keptMemory
+		bestSim := opts.Threshold
+		for _, k := range groups[key] {
+			if !mergeable(k.result, r) {
+				continue
+			}
+			sim, err := vectordb.CosineSim(r.Embedding, k.result.Embedding)
+			if err == nil && sim >= bestSim {
+				best, bestSim = k, sim
+			}
+		}
+
+		if best == nil {
+			groups[key] = append(groups[key], &keptMemory{result: r, mem: memoryFromResult(r)})
+			continue
+		}
+
+		mergeDuplicate(&best.mem, memoryFromResult(r), opts.boost())
+		if len(best.duplicates) == 0 {
+			changed = append(changed, best)
+		}
+		best.duplicates = append(best.duplicates, r.ID)
+		stats.Merged++
+	}
+
+	stats.Kept = len(changed)
+	if opts.DryRun || len(changed) == 0 {
+		return stats, nil
+	}
+
+	// 所有合并和删除在同一事务中完成，中途失败不会留下只合并未删除的记忆
+	merges := make([]store.MemoryMerge, len(changed))
+	for i, k := range changed {
+		merges[i] = mergeOf(k.mem, k.duplicates)
+	}
+	if err := m.store.MergeMemories(merges); err != nil {
+		return nil, fmt.Errorf("failed to merge duplicate memories: %w", err)
+	}
+
+	return stats, nil
+}
+
+// mergeDuplicate 把dup合并到base
+// 重要性取两者较大值再加boost（不超过1），时间取较新的，标签取并集，过期时间取较晚的（任一不过期则不过期）；
+// base的内容不变，dup的内容、时间和元数据追加到provenance，dup独有的元数据键并入base
+func mergeDuplicate(base *Memory, dup Memory, boost float64) {
+	base.Importance = maxFloat(base.Importance, dup.Importance) + boost
+	if base.Importance > 1 {
+		base.Importance = 1
+	}
+
+	if dup.Timestamp.IsZero() {
+		dup.Timestamp = time.Now()
+	}
+	if dup.Timestamp.After(base.Timestamp) {
+		base.Timestamp = dup.Timestamp
+	}
+
+	if base.ExpiresAt != nil && (dup.ExpiresAt == nil || dup.ExpiresAt.After(*base.ExpiresAt)) {
+		base.ExpiresAt = dup.ExpiresAt
+	}
+
+	seen := make(map[string]bool, len(base.Tags))
+	for _, tag := range base.Tags {
+		seen[tag] = true
+	}
+	for _, tag := range dup.Tags {
+		if !seen[tag] {
+			seen[tag] = true
+			base.Tags = append(base.Tags, tag)
+		}
+	}
+
+	metadata := make(map[string]interface{}, len(base.Metadata)+2)
+	for k, v := range base.Metadata {
+		metadata[k] = v
+	}
+
+	// dup自身的合并记录也一并继承
+	provenance := provenanceOf(base.Metadata)
+	provenance = append(provenance, provenanceOf(dup.Metadata)...)
+
+	entry := map[string]interface{}{
+		"content":   dup.Content,
+		"timestamp": dup.Timestamp.UTC().Format(time.RFC3339),
+	}
+	dupMetadata := make(map[string]interface{})
+	for k, v := range dup.Metadata {
+		if k == MetadataProvenance || k == MetadataMergeCount {
+			continue
+		}
+		dupMetadata[k] = v
+		if _, ok := metadata[k]; !ok {
+			metadata[k] = v
+		}
+	}
+	if len(dupMetadata) > 0 {
+		entry["metadata"] = dupMetadata
+	}
+	provenance = append(provenance, entry)
+	if len(provenance) > maxProvenance {
+		provenance = provenance[len(provenance)-maxProvenance:]
+	}
+
+	// 反思进度随时间一起前移，与较新的时间保持一致
+	if later := laterRFC3339(base.Metadata[MetadataReflectedThrough], dup.Metadata[MetadataReflectedThrough]); later != "" {
+		metadata[MetadataReflectedThrough] = later
+	}
+
+	metadata[MetadataProvenance] = provenance
+	metadata[MetadataMergeCount] = mergeCountOf(base.Metadata) + mergeCountOf(dup.Metadata) + 1
+	base.Metadata = metadata
+}
+
+// laterRFC3339 返回两个RFC3339时间中较晚的一个，都无效时返回空
+func laterRFC3339(a, b interface{}) string {
+	var later string
+	var latest time.Time
+	for _, v := range []interface{}{a, b} {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, s); err == nil && (later == "" || t.After(latest)) {
+			later, latest = s, t
+		}
+	}
+	return later
+}
+
+// provenanceOf 读取metadata中的合并记录（JSON解码后为[]interface{}）
+func provenanceOf(metadata map[string]interface{}) []interface{} {
+	switch p := metadata[MetadataProvenance].(type) {
+	case []interface{}:
+		return append([]interface{}(nil), p...)
+	case []map[string]interface{}:
+		out := make([]interface{}, len(p))
+		for i, e := range p {
+			out[i] = e
+		}
+		return out
+	}
+	return nil
+}
+
+// mergeCountOf 读取metadata中的合并计数（JSON解码后为float64）
+func mergeCountOf(metadata map[string]interface{}) int {
+	switch n := metadata[MetadataMergeCount].(type) {
+	case int:
+		return n
+	case float64:
+		return int(n)
+	}
+	return 0
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,405 @@
+package memory
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 情景事件的标签前缀：参与者和情节写入标签，便于下推过滤
+const (
+	ActorTagPrefix   = "actor:"
+	EpisodeTagPrefix = "episode:"
+)
+
+const (
+	// DefaultEpisodeGap 相邻事件间隔超过该值时划分为不同情节
+	DefaultEpisodeGap = 30 * time.Minute
+	// DefaultAroundWindow 回忆某时刻前后事件的默认时间范围（前后各12小时）
+	DefaultAroundWindow = 12 * time.Hour
+	// DefaultAroundTimeWeight 时间接近度在综合得分中的默认权重
+	DefaultAroundTimeWeight = 0.5
+)
+
+// Event 情景事件
+type Event struct {
+	ID          string
+	Description string    // 发生了什么
+	Actors      []string  // 参与者
+	Location    string    // 地点
+	Context     string    // 背景（如所在任务、渠道）
+	StartTime   time.Time // 开始时间，为空时为当前时间
+	EndTime     time.Time // 结束时间，为空表示瞬时事件
+	Outcome     string    // 结果
+	Episode     string    // 所属情节ID，为空时按时间间隔推断
+	Tags        []string
+	Importance  float64
+	Relevance   float64 // 检索时的相关度
+}
+
+// End 事件的结束时间（瞬时事件为开始时间）
+func (e Event) End() time.Time {
+	if e.EndTime.IsZero() {
+		return e.StartTime
+	}
+	return e.EndTime
+}
+
+// distance 时刻t到事件时间段的距离（在事件期间为0）
+func (e Event) distance(t time.Time) time.Duration {
+	switch {
+	case t.Before(e.StartTime):
+		return e.StartTime.Sub(t)
+	case t.After(e.End()):
+		return t.Sub(e.End())
+	}
+	return 0
+}
+
+// Episode 由时间上相邻（或显式指定同一ID）的事件组成的情节
+type Episode struct {
+	ID     string // 显式指定的情节ID，按时间间隔推断的情节为空
+	Start  time.Time
+	End    time.Time
+	Actors []string // 参与者并集（按出现顺序）
+	Events []Event  // 按开始时间排序
+}
+
+// TimelineOptions 时间线查询选项
+type TimelineOptions struct {
+	After   time.Time // 开始时间不早于After
+	Before  time.Time // 开始时间早于Before
+	Actor   string    // 只包含该参与者的事件
+	Episode string    // 只包含该情节的事件
+	Limit   int       // 最多返回的事件数（取最早的），0表示不限
+}
+
+// AroundOptions 回忆某时刻前后事件的选项
+type AroundOptions struct {
+	Window     time.Duration // At前后的时间范围，0时使用DefaultAroundWindow
+	TimeWeight float64       // 时间接近度的权重（0-1），0时使用DefaultAroundTimeWeight，语义相似度权重为1-TimeWeight
+	Limit      int
+}
+
+// EpisodicMemory 情景记忆管理
+type EpisodicMemory struct {
+	manager *Manager
+}
+
+// NewEpisodicMemory 创建情景记忆管理器
+func NewEpisodicMemory(manager *Manager) *EpisodicMemory {
+	return &EpisodicMemory{manager: manager}
+}
+
+// RecordEvent 记录事件
+func (e *EpisodicMemory) RecordEvent(event Event) error {
+	if event.Description == "" {
+		return fmt.Errorf("event description is required")
+	}
+	if event.StartTime.IsZero() {
+		event.StartTime = time.Now()
+	}
+	if !event.EndTime.IsZero() && event.EndTime.Before(event.StartTime) {
+		return fmt.Errorf("event ends before it starts")
+	}
+
+	metadata := map[string]interface{}{
+		"description": event.Description,
+	}
+	if len(event.Actors) > 0 {
+		metadata["actors"] = event.Actors
+	}
+	if event.Location != "" {
+		metadata["location"] = event.Location
+	}
+	if event.Context != "" {
+		metadata["context"] = event.Context
+	}
+	if !event.EndTime.IsZero() {
+		metadata["end_time"] = event.EndTime.Format(time.RFC3339)
+	}
+	if event.Outcome != "" {
+		metadata["outcome"] = event.Outcome
+	}
+	if event.Episode != "" {
+		metadata["episode"] = event.Episode
+	}
+
+	tags := append([]string(nil), event.Tags...)
+	for _, actor := range event.Actors {
+		tags = append(tags, ActorTagPrefix+actor)
+	}
+	if event.Episode != "" {
+		tags = append(tags, EpisodeTagPrefix+event.Episode)
+	}
+
+	mem := Memory{
+		Type:       MemoryTypeEpisodic,
+		Content:    eventContent(event),
+		Metadata:   metadata,
+		Tags:       tags,
+		Timestamp:  event.StartTime,
+		Importance: event.Importance,
+	}
+
+	return e.manager.Store(mem)
+}
+
+// eventContent 用于嵌入和全文检索的事件文本
+func eventContent(event Event) string {
+	parts := []string{event.Description}
+	if len(event.Actors) > 0 {
+		parts = append(parts, "参与者: "+strings.Join(event.Actors, ", "))
+	}
+	if event.Location != "" {
+		parts = append(parts, "地点: "+event.Location)
+	}
+	if event.Context != "" {
+		parts = append(parts, "背景: "+event.Context)
+	}
+	if event.Outcome != "" {
+		parts = append(parts, "结果: "+event.Outcome)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Timeline 按开始时间顺序列出事件
+func (e *EpisodicMemory) Timeline(opts TimelineOptions) ([]Event, error) {
+	filter := Filter{Since: opts.After, Until: opts.Before}
+	if opts.Actor != "" {
+		filter.AllTags = append(filter.AllTags, ActorTagPrefix+opts.Actor)
+	}
+	if opts.Episode != "" {
+		filter.AllTags = append(filter.AllTags, EpisodeTagPrefix+opts.Episode)
+	}
+	return e.list(filter, true, opts.Limit)
+}
+
+// list 按时间顺序查询事件，数量限制在查询中应用
+// oldestFirst为false时取最近的limit个，结果仍按时间顺序返回
+func (e *EpisodicMemory) list(filter Filter, oldestFirst bool, limit int) ([]Event, error) {
+	page, err := e.manager.List(ListOptions{
+		MemoryTypes: []MemoryType{MemoryTypeEpisodic},
+		Filter:      filter,
+		Limit:       limit,
+		OldestFirst: oldestFirst,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	memories := page.Memories
+	if !oldestFirst {
+		for i, j := 0, len(memories)-1; i < j; i, j = i+1, j-1 {
+			memories[i], memories[j] = memories[j], memories[i]
+		}
+	}
+	return eventsFromMemories(memories), nil
+}
+
+// Before 时刻t之前最近的limit个事件（按时间顺序）
+func (e *EpisodicMemory) Before(t time.Time, limit int) ([]Event, error) {
+	return e.list(Filter{Until: t}, false, limit)
+}
+
+// After 时刻t之后（含）最早的limit个事件
+func (e *EpisodicMemory) After(t time.Time, limit int) ([]Event, error) {
+	return e.Timeline(TimelineOptions{After: t, Limit: limit})
+}
+
+// Between 开始时间在[start, end)内的事件
+func (e *EpisodicMemory) Between(start, end time.Time) ([]Event, error) {
+	if !end.After(start) {
+		return nil, fmt.Errorf("invalid time range: %s - %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	return e.Timeline(TimelineOptions{After: start, Before: end})
+}
+
+// Around 回忆时刻at前后与query相关的事件（"X时发生了什么"）
+// 候选为时间段与[at-Window, at+Window]重叠的事件（开始不晚于at+Window，结束不早于at-Window），
+// 跨越该时刻的长事件也会命中；得分为时间接近度（在事件期间为1，到范围边缘线性降为0）
+// 和语义相似度（混合检索）的加权和；query为空时只按时间接近度排序
+func (e *EpisodicMemory) Around(query string, at time.Time, opts AroundOptions) ([]Event, error) {
+	window := opts.Window
+	if window <= 0 {
+		window = DefaultAroundWindow
+	}
+	timeWeight := opts.TimeWeight
+	if timeWeight <= 0 {
+		timeWeight = DefaultAroundTimeWeight
+	}
+	if timeWeight > 1 {
+		timeWeight = 1
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	// Until不含边界，时间按秒存储，加一秒使开始时间恰为at+Window的事件也计入
+	filter := Filter{Until: at.Add(window).Truncate(time.Second).Add(time.Second), EndsSince: at.Add(-window)}
+
+	var events []Event
+	if strings.TrimSpace(query) == "" {
+		timeline, err := e.list(filter, true, 0)
+		if err != nil {
+			return nil, err
+		}
+		events = timeline
+		timeWeight = 1
+	} else {
+		memories, err := e.manager.Recall(query, RecallOptions{
+			Limit:       limit * 2,
+			MemoryTypes: []MemoryType{MemoryTypeEpisodic},
+			Mode:        RecallModeHybrid,
+			Filter:      filter,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to recall events: %w", err)
+		}
+		events = eventsFromMemories(memories)
+	}
+
+	for i := range events {
+		proximity := 1 - float64(events[i].distance(at))/float64(window)
+		events[i].Relevance = timeWeight*math.Max(proximity, 0) + (1-timeWeight)*events[i].Relevance
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Relevance > events[j].Relevance
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// Episodes 把开始时间在[start, end)内的事件划分为情节（零值表示不限）
+// 指定了情节ID的事件归入该情节；其余事件按时间顺序，与上一事件的间隔（上一事件结束到本事件开始）
+// 不超过gap时归入同一情节，gap为0时使用DefaultEpisodeGap
+func (e *EpisodicMemory) Episodes(start, end time.Time, gap time.Duration) ([]Episode, error) {
+	if gap <= 0 {
+		gap = DefaultEpisodeGap
+	}
+
+	events, err := e.Timeline(TimelineOptions{After: start, Before: end})
+	if err != nil {
+		return nil, err
+	}
+
+	var episodes []*Episode
+	named := make(map[string]*Episode)
+	var current *Episode
+
+	for _, event := range events {
+		if event.Episode != "" {
+			ep, ok := named[event.Episode]
+			if !ok {
+				ep = &Episode{ID: event.Episode}
+				named[event.Episode] = ep
+				episodes = append(episodes, ep)
+			}
+			ep.add(event)
+			continue
+		}
+
+		if current == nil || event.StartTime.Sub(current.End) > gap {
+			current = &Episode{}
+			episodes = append(episodes, current)
+		}
+		current.add(event)
+	}
+
+	result := make([]Episode, len(episodes))
+	for i, ep := range episodes {
+		result[i] = *ep
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+	return result, nil
+}
+
+// add 追加事件（事件按开始时间顺序加入）
+func (ep *Episode) add(event Event) {
+	if len(ep.Events) == 0 || event.StartTime.Before(ep.Start) {
+		ep.Start = event.StartTime
+	}
+	if event.End().After(ep.End) {
+		ep.End = event.End()
+	}
+	for _, actor := range event.Actors {
+		found := false
+		for _, a := range ep.Actors {
+			if a == actor {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ep.Actors = append(ep.Actors, actor)
+		}
+	}
+	ep.Events = append(ep.Events, event)
+}
+
+// eventsFromMemories 转换记忆为事件，按开始时间排序
+func eventsFromMemories(memories []Memory) []Event {
+	events := make([]Event, 0, len(memories))
+	for _, mem := range memories {
+		events = append(events, eventFromMemory(mem))
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].StartTime.Before(events[j].StartTime)
+	})
+	return events
+}
+
+// eventFromMemory 从记忆的metadata还原事件
+func eventFromMemory(mem Memory) Event {
+	event := Event{
+		ID:         mem.ID,
+		StartTime:  mem.Timestamp,
+		Importance: mem.Importance,
+		Relevance:  mem.Relevance,
+	}
+
+	if description, ok := mem.Metadata["description"].(string); ok {
+		event.Description = description
+	} else {
+		event.Description = mem.Content
+	}
+	if actors, ok := mem.Metadata["actors"].([]interface{}); ok {
+		for _, a := range actors {
+			if actor, ok := a.(string); ok {
+				event.Actors = append(event.Actors, actor)
+			}
+		}
+	}
+	if location, ok := mem.Metadata["location"].(string); ok {
+		event.Location = location
+	}
+	if context, ok := mem.Metadata["context"].(string); ok {
+		event.Context = context
+	}
+	if endTime, ok := mem.Metadata["end_time"].(string); ok {
+		event.EndTime, _ = time.Parse(time.RFC3339, endTime)
+	}
+	if outcome, ok := mem.Metadata["outcome"].(string); ok {
+		event.Outcome = outcome
+	}
+	if episode, ok := mem.Metadata["episode"].(string); ok {
+		event.Episode = episode
+	}
+
+	// 参与者和情节标签由RecordEvent添加，不返回给调用方
+	for _, tag := range mem.Tags {
+		if !strings.HasPrefix(tag, ActorTagPrefix) && !strings.HasPrefix(tag, EpisodeTagPrefix) {
+			event.Tags = append(event.Tags, tag)
+		}
+	}
+
+	return event
+}
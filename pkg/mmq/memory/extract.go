@@ -0,0 +1,405 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/llm"
+)
+
+// extractionPrompt 从对话或文本中抽取事实和偏好的提示
+const extractionPrompt = `Extract durable facts and user preferences from the input below.
+Facts are subject-predicate-object triples about people, organizations, places or things. Use short snake_case predicates such as "lives_in" or "works_for".
+Preferences are things the user likes, dislikes or wants, grouped by category and key.
+Give each item a confidence between 0 and 1, and list the numbers of the turns it comes from (omit "turns" for plain text).
+Skip greetings, questions, speculation and anything only true for the moment. Keep the language of the input for names and values.
+Output only JSON matching this schema:
+{"facts":[{"subject":"...","predicate":"...","object":"...","confidence":0.9,"turns":[1]}],"preferences":[{"category":"...","key":"...","value":"...","confidence":0.8,"turns":[1]}]}
+
+Input:
+%s
+
+JSON:`
+
+// 抽取结果的字段长度上限（超过时视为无效候选）
+const maxExtractedFieldRunes = 200
+
+// ExtractorOptions 抽取选项
+type ExtractorOptions struct {
+	MinConfidence float64 // 低于该置信度的候选丢弃
+	Source        string  // 写入事实和偏好时的来源
+	MaxTokens     int     // 生成的最大token数
+}
+
+// DefaultExtractorOptions 默认抽取选项
+func DefaultExtractorOptions() ExtractorOptions {
+	return ExtractorOptions{
+		MinConfidence: 0.5,
+		Source:        "extracted",
+		MaxTokens:     1024,
+	}
+}
+
+// FactCandidate 抽取出的候选事实（置信度和来源轮次ID见Fact.Confidence、Fact.SourceTurns）
+type FactCandidate struct {
+	Fact
+}
+
+// PreferenceCandidate 抽取出的候选偏好（置信度和来源轮次ID见Preference.Confidence、Preference.SourceTurns）
+type PreferenceCandidate struct {
+	Preference
+}
+
+// Extraction 一次抽取的候选结果（已校验并在批内去重）
+type Extraction struct {
+	Facts       []FactCandidate
+	Preferences []PreferenceCandidate
+	Rejected    []string // 校验未通过的候选及原因
+}
+
+// ExtractionStats 抽取结果写入统计
+type ExtractionStats struct {
+	Facts       int // 写入的事实数（含与已有事实合并的）
+	Preferences int // 写入或更新的偏好数
+	Duplicates  int // 与已有偏好完全相同而跳过的数量（只合并来源轮次）
+	Kept        int // 置信度低于已有偏好而保留原值的数量
+	Rejected    int // 校验未通过的候选数
+}
+
+// Extractor 从对话和文本中自动抽取事实与偏好
+// 抽取结果经校验和去重后通过FactMemory.StoreFact、PreferenceMemory.UpdatePreference写入
+type Extractor struct {
+	model       llm.LLM
+	facts       *FactMemory
+	preferences *PreferenceMemory
+	opts        ExtractorOptions
+}
+
+// NewExtractor 创建抽取器
+func NewExtractor(model llm.LLM, facts *FactMemory, preferences *PreferenceMemory, opts ExtractorOptions) *Extractor {
+	return &Extractor{
+		model:       model,
+		facts:       facts,
+		preferences: preferences,
+		opts:        opts,
+	}
+}
+
+// extractedItem LLM输出中的一个候选（事实和偏好共用）
+type extractedItem struct {
+	Subject    string      `json:"subject"`
+	Predicate  string      `json:"predicate"`
+	Object     string      `json:"object"`
+	Category   string      `json:"category"`
+	Key        string      `json:"key"`
+	Value      interface{} `json:"value"`
+	Confidence float64     `json:"confidence"`
+	Turns      []int       `json:"turns"`
+}
+
+type extractedOutput struct {
+	Facts       []extractedItem `json:"facts"`
+	Preferences []extractedItem `json:"preferences"`
+}
+
+// ExtractTurns 从对话轮次中抽取候选事实和偏好
+// 来源轮次以轮次ID表示，未设置ID的轮次以其在输入中的序号（从1开始）表示
+func (e *Extractor) ExtractTurns(turns []ConversationTurn) (*Extraction, error) {
+	var b strings.Builder
+	ids := make([]string, len(turns))
+	times := make([]time.Time, len(turns))
+	for i, turn := range turns {
+		fmt.Fprintf(&b, "[Turn %d]\n", i+1)
+		if turn.User != "" {
+			fmt.Fprintf(&b, "user: %s\n", turn.User)
+		}
+		if turn.Assistant != "" {
+			fmt.Fprintf(&b, "assistant: %s\n", turn.Assistant)
+		}
+		ids[i] = turn.ID
+		if ids[i] == "" {
+			ids[i] = strconv.Itoa(i + 1)
+		}
+		times[i] = turn.Timestamp
+	}
+	return e.extract(strings.TrimRight(b.String(), "\n"), ids, times)
+}
+
+// ExtractText 从任意文本中抽取候选事实和偏好
+func (e *Extractor) ExtractText(text string) (*Extraction, error) {
+	return e.extract(text, nil, nil)
+}
+
+// IngestTurns 从对话轮次中抽取并写入事实和偏好
+func (e *Extractor) IngestTurns(turns []ConversationTurn) (*ExtractionStats, error) {
+	extraction, err := e.ExtractTurns(turns)
+	if err != nil {
+		return nil, err
+	}
+	return e.Store(extraction)
+}
+
+// IngestText 从任意文本中抽取并写入事实和偏好
+func (e *Extractor) IngestText(text string) (*ExtractionStats, error) {
+	extraction, err := e.ExtractText(text)
+	if err != nil {
+		return nil, err
+	}
+	return e.Store(extraction)
+}
+
+// extract 调用LLM抽取并校验候选
+func (e *Extractor) extract(input string, turnIDs []string, turnTimes []time.Time) (*Extraction, error) {
+	if strings.TrimSpace(input) == "" {
+		return &Extraction{}, nil
+	}
+
+	opts := llm.DefaultGenerateOptions()
+	opts.Temperature = 0
+	if e.opts.MaxTokens > 0 {
+		opts.MaxTokens = e.opts.MaxTokens
+	}
+
+	text, err := e.model.Generate(fmt.Sprintf(extractionPrompt, input), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate extraction: %w", err)
+	}
+
+	output, err := parseExtraction(text)
+	if err != nil {
+		return nil, err
+	}
+
+	extraction := &Extraction{}
+	now := time.Now()
+	factIndex := make(map[string]int)
+	prefIndex := make(map[string]int)
+
+	for _, item := range output.Facts {
+		fact := Fact{
+			Subject:    normalizeField(item.Subject),
+			Predicate:  normalizePredicate(item.Predicate),
+			Object:     normalizeField(item.Object),
+			Confidence: item.Confidence,
+			Source:     e.opts.Source,
+		}
+		if reason := e.validate(item.Confidence, fact.Subject, fact.Predicate, fact.Object); reason != "" {
+			extraction.Rejected = append(extraction.Rejected,
+				fmt.Sprintf("fact %q %q %q: %s", item.Subject, item.Predicate, item.Object, reason))
+			continue
+		}
+
+		turns, at := sourceTurns(item.Turns, turnIDs, turnTimes, now)
+		fact.Timestamp = at
+
+		// 批内去重：同一三元组取最高置信度，合并来源轮次
+		key := fact.Subject + "\x00" + fact.Predicate + "\x00" + fact.Object
+		if i, ok := factIndex[key]; ok {
+			existing := &extraction.Facts[i]
+			if fact.Confidence > existing.Confidence {
+				existing.Confidence = fact.Confidence
+			}
+			if at.After(existing.Timestamp) {
+				existing.Timestamp = at
+			}
+			existing.SourceTurns = mergeTurnIDs(existing.SourceTurns, turns)
+			continue
+		}
+		fact.SourceTurns = turns
+		factIndex[key] = len(extraction.Facts)
+		extraction.Facts = append(extraction.Facts, FactCandidate{Fact: fact})
+	}
+
+	for _, item := range output.Preferences {
+		pref := Preference{
+			Category: strings.ToLower(normalizeField(item.Category)),
+			Key:      strings.ToLower(normalizeField(item.Key)),
+			Value:    item.Value,
+			Source:   e.opts.Source,
+		}
+		if s, ok := pref.Value.(string); ok {
+			pref.Value = normalizeField(s)
+		}
+		value := ""
+		if pref.Value != nil {
+			value = fmt.Sprint(pref.Value)
+		}
+		if reason := e.validate(item.Confidence, pref.Category, pref.Key, value); reason != "" {
+			extraction.Rejected = append(extraction.Rejected,
+				fmt.Sprintf("preference %q/%q: %s", item.Category, item.Key, reason))
+			continue
+		}
+
+		turns, at := sourceTurns(item.Turns, turnIDs, turnTimes, now)
+		pref.Timestamp = at
+		pref.Confidence = item.Confidence
+		pref.SourceTurns = turns
+		candidate := PreferenceCandidate{Preference: pref}
+
+		// 批内去重：同一类别和键只保留一个值（置信度高的优先，相同时取后出现的）
+		key := pref.Category + "\x00" + pref.Key
+		if i, ok := prefIndex[key]; ok {
+			existing := &extraction.Preferences[i]
+			if candidate.Confidence >= existing.Confidence {
+				if valuesEqual(existing.Value, candidate.Value) {
+					candidate.SourceTurns = mergeTurnIDs(existing.SourceTurns, turns)
+				}
+				*existing = candidate
+			}
+			continue
+		}
+		prefIndex[key] = len(extraction.Preferences)
+		extraction.Preferences = append(extraction.Preferences, candidate)
+	}
+
+	return extraction, nil
+}
+
+// validate 校验候选，返回不通过的原因（通过时为空）
+func (e *Extractor) validate(confidence float64, fields ...string) string {
+	for _, field := range fields {
+		if field == "" {
+			return "missing field"
+		}
+		if len([]rune(field)) > maxExtractedFieldRunes {
+			return "field too long"
+		}
+	}
+	if confidence < 0 || confidence > 1 {
+		return fmt.Sprintf("confidence %.2f out of range", confidence)
+	}
+	if confidence < e.opts.MinConfidence {
+		return fmt.Sprintf("confidence %.2f below %.2f", confidence, e.opts.MinConfidence)
+	}
+	return ""
+}
+
+// Store 写入抽取结果
+// 事实通过StoreFact写入（同一三元组与已有事实合并，来源轮次记录在检索记忆的metadata中）；
+// 偏好通过UpdatePreference写入：与已有值相同时只合并来源轮次，不同时置信度不低于已有偏好才更新
+func (e *Extractor) Store(extraction *Extraction) (*ExtractionStats, error) {
+	stats := &ExtractionStats{Rejected: len(extraction.Rejected)}
+
+	for _, candidate := range extraction.Facts {
+		if err := e.facts.StoreFact(candidate.Fact); err != nil {
+			return stats, fmt.Errorf("failed to store fact: %w", err)
+		}
+		stats.Facts++
+	}
+
+	for _, candidate := range extraction.Preferences {
+		pref := candidate.Preference
+		existing, err := e.preferences.getPreferenceExact(pref.Category, pref.Key)
+		duplicate := err == nil && valuesEqual(existing, pref.Value)
+
+		updated, err := e.preferences.UpdatePreference(pref)
+		if err != nil {
+			return stats, fmt.Errorf("failed to store preference: %w", err)
+		}
+		switch {
+		case duplicate:
+			stats.Duplicates++
+		case updated:
+			stats.Preferences++
+		default:
+			stats.Kept++
+		}
+	}
+
+	return stats, nil
+}
+
+// parseExtraction 解析LLM输出的JSON（容忍代码块和前后的说明文字）
+func parseExtraction(text string) (*extractedOutput, error) {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("failed to parse extraction: no JSON object in output")
+	}
+
+	var output extractedOutput
+	if err := json.Unmarshal([]byte(text[start:end+1]), &output); err != nil {
+		return nil, fmt.Errorf("failed to parse extraction: %w", err)
+	}
+	return &output, nil
+}
+
+// sourceTurns 将轮次序号转换为轮次ID，返回ID和最晚的轮次时间（无有效轮次时为now）
+func sourceTurns(numbers []int, turnIDs []string, turnTimes []time.Time, now time.Time) ([]string, time.Time) {
+	var ids []string
+	var latest time.Time
+	for _, n := range numbers {
+		if n < 1 || n > len(turnIDs) {
+			continue // 忽略不存在的轮次
+		}
+		ids = mergeTurnIDs(ids, []string{turnIDs[n-1]})
+		if turnTimes[n-1].After(latest) {
+			latest = turnTimes[n-1]
+		}
+	}
+	if latest.IsZero() {
+		latest = now
+	}
+	return ids, latest
+}
+
+func mergeTurnIDs(ids, more []string) []string {
+	for _, id := range more {
+		if !containsString(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// metadataStrings 读取metadata中的字符串列表（经过JSON往返后为[]interface{}）
+func metadataStrings(v interface{}) []string {
+	switch values := v.(type) {
+	case []string:
+		return append([]string(nil), values...)
+	case []interface{}:
+		var out []string
+		for _, value := range values {
+			if s, ok := value.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeField 去除首尾空白并合并连续空白
+func normalizeField(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// normalizePredicate 谓词统一为小写的snake_case（"Lives In" -> "lives_in"）
+func normalizePredicate(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), "_"))
+}
+
+// valuesEqual 按JSON编码比较偏好值
+func valuesEqual(a, b interface{}) bool {
+	da, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	db, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(da) == string(db)
+}
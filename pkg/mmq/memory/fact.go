@@ -2,229 +2,413 @@ package memory
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/store"
 )
 
+// DefaultMaxHops FindPath默认的最大跳数
+const DefaultMaxHops = 3
+
 // Fact 事实结构（主谓宾三元组）
 type Fact struct {
+	ID         string
 	Subject    string
 	Predicate  string
 	Object     string
-	Confidence float64 // 置信度 0.0-1.0
-	Source     string  // 事实来源
+	Confidence float64 // 置信度 0.0-1.0（多个来源断言时为综合置信度）
+	Source     string  // 事实来源（读取时为置信度最高的来源）
 	Timestamp  time.Time
+
+	Sources      map[string]float64 // 各来源给出的置信度
+	SupersededAt *time.Time         // 被新值取代的时间，nil表示当前有效
+	SupersededBy string             // 取代它的事实ID
+
+	SourceTurns []string // 来源轮次ID（写入时合并到检索记忆的metadata，读取知识图谱时为空）
+}
+
+// Current 事实当前是否有效（未被取代）
+func (f Fact) Current() bool {
+	return f.SupersededAt == nil
+}
+
+// FactPattern 事实查询模式，空字段或"*"为通配，含"*"的值按通配符匹配（如 "Ali*"）
+type FactPattern struct {
+	Subject       string
+	Predicate     string
+	Object        string
+	MinConfidence float64
+	History       bool // 包含已被取代的事实
+	Limit         int  // 0表示不限
 }
 
 // FactMemory 事实记忆管理
+// 事实保存在知识图谱（facts表）中，支持精确和通配查询、多跳遍历；
+// 同时写入一条事实记忆供语义检索（QueryFact、SearchFacts）
 type FactMemory struct {
 	manager *Manager
 }
 
 // NewFactMemory 创建事实记忆管理器
 func NewFactMemory(manager *Manager) *FactMemory {
-	return &FactMemory{manager: manager}
+	return &FactMemory{
+		manager: manager,
+	}
+}
+
+// SetFunctional 将谓词设为函数型（如 "lives_in"、"works_for"）
+// 函数型谓词的新值取代同一主体的旧值，旧值保留为历史；设置按命名空间持久化
+func (f *FactMemory) SetFunctional(predicates ...string) error {
+	ns, err := f.manager.within(Namespace{})
+	if err != nil {
+		return err
+	}
+	return f.manager.store.SetFunctionalPredicates(ns.toStore(), predicates)
 }
 
 // StoreFact 存储事实
 func (f *FactMemory) StoreFact(fact Fact) error {
-	content := fmt.Sprintf("%s %s %s", fact.Subject, fact.Predicate, fact.Object)
+	_, err := f.Assert(fact)
+	return err
+}
+
+// Assert 断言事实，返回断言后的事实
+// 同一三元组再次断言时合并来源：每个来源保留最高的置信度，综合置信度为 1-∏(1-c)；
+// 函数型谓词的新值取代主体的其他当前值（断言时间早于当前值时，新值直接记为历史）
+func (f *FactMemory) Assert(fact Fact) (*Fact, error) {
+	fact.Subject = strings.TrimSpace(fact.Subject)
+	fact.Predicate = strings.TrimSpace(fact.Predicate)
+	fact.Object = strings.TrimSpace(fact.Object)
+	if fact.Subject == "" || fact.Predicate == "" || fact.Object == "" {
+		return nil, fmt.Errorf("fact requires subject, predicate and object")
+	}
+	if fact.Confidence <= 0 {
+		fact.Confidence = 0.5 // 默认中等置信度
+	}
+	if fact.Confidence > 1 {
+		fact.Confidence = 1
+	}
+	if fact.Timestamp.IsZero() {
+		fact.Timestamp = time.Now()
+	}
+
+	ns, err := f.manager.within(Namespace{})
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. 在存储的事务中合并事实并取代旧值（只看同一命名空间，不同用户的事实互不影响）
+	result, err := f.manager.store.AssertFact(store.FactAssertion{
+		Namespace:  ns.toStore(),
+		Subject:    fact.Subject,
+		Predicate:  fact.Predicate,
+		Object:     fact.Object,
+		Source:     fact.Source,
+		Confidence: fact.Confidence,
+		At:         fact.Timestamp,
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	// 2. 同步检索用的事实记忆：当前有效的事实才保留
+	record := result.Fact
+	if record.SupersededAt != nil {
+		if err := f.unlinkMemory(record.ID); err != nil {
+			return nil, err
+		}
+	} else if err := f.syncMemory(record, fact.SourceTurns); err != nil {
+		return nil, err
+	}
+	for _, old := range result.Superseded {
+		if err := f.unlinkMemory(old.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	asserted := factFromRecord(record)
+	return &asserted, nil
+}
+
+// syncMemory 写入或更新事实对应的检索记忆（metadata.fact_id关联）
+// metadata同时记录各来源的置信度和来源轮次（与已有记忆的轮次合并）
+func (f *FactMemory) syncMemory(record store.FactRecord, turns []string) error {
+	fact := factFromRecord(record)
 	metadata := map[string]interface{}{
+		"fact_id":    record.ID,
 		"subject":    fact.Subject,
 		"predicate":  fact.Predicate,
 		"object":     fact.Object,
 		"confidence": fact.Confidence,
 	}
-
 	if fact.Source != "" {
 		metadata["source"] = fact.Source
 	}
+	if len(record.Sources) > 0 {
+		metadata["sources"] = record.Sources
+	}
+
+	existing, err := f.linkedMemory(record.ID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		turns = mergeTurnIDs(metadataStrings(existing.Metadata["source_turns"]), turns)
+	}
+	if len(turns) > 0 {
+		metadata["source_turns"] = turns
+	}
+	if existing != nil {
+		existing.Metadata = metadata
+		existing.Importance = fact.Confidence
+		return f.manager.Update(existing.ID, *existing)
+	}
 
-	mem := Memory{
+	return f.manager.Store(Memory{
 		Type:       MemoryTypeFact,
-		Content:    content,
+		Content:    fmt.Sprintf("%s %s %s", fact.Subject, fact.Predicate, fact.Object),
 		Metadata:   metadata,
 		Timestamp:  fact.Timestamp,
 		Importance: fact.Confidence, // 使用置信度作为重要性
-	}
+	})
+}
 
-	return f.manager.Store(mem)
+// unlinkMemory 删除事实对应的检索记忆（被取代或删除的事实不再参与语义检索）
+func (f *FactMemory) unlinkMemory(factID string) error {
+	existing, err := f.linkedMemory(factID)
+	if err != nil || existing == nil {
+		return err
+	}
+	return f.manager.Delete(existing.ID)
 }
 
-// QueryFact 查询事实
-func (f *FactMemory) QueryFact(subject, predicate string) ([]Fact, error) {
-	query := fmt.Sprintf("%s %s", subject, predicate)
+// linkedMemory 查找事实对应的检索记忆，不存在时返回nil
+// 旧版本写入的事实记忆没有fact_id，其事实ID即记忆ID
+func (f *FactMemory) linkedMemory(factID string) (*Memory, error) {
+	results, _, err := f.manager.store.ListMemories(store.MemoryFilter{
+		Types:    []string{string(MemoryTypeFact)},
+		Metadata: map[string]interface{}{"fact_id": factID},
+	}, f.manager.ownScope(), store.NewestFirst, 1, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find fact memory: %w", err)
+	}
+	if len(results) > 0 {
+		mem := memoryFromResult(results[0])
+		return &mem, nil
+	}
 
-	opts := RecallOptions{
-		Limit:              10,
-		MemoryTypes:        []MemoryType{MemoryTypeFact},
-		ApplyDecay:         false, // 事实不衰减
-		WeightByImportance: true,  // 按置信度加权
-		MinRelevance:       0.3,   // 最小相关度阈值
+	if mem, err := f.manager.GetByID(factID); err == nil && mem.Type == MemoryTypeFact {
+		return mem, nil
 	}
+	return nil, nil
+}
 
-	memories, err := f.manager.Recall(query, opts)
+// linkedFact 记忆是否为知识图谱事实的检索记忆（事实按三元组合并，不参与语义去重）
+func linkedFact(metadata map[string]interface{}) bool {
+	_, ok := metadata["fact_id"]
+	return ok
+}
+
+// Match 按模式精确或通配查询事实（按生效时间顺序）
+func (f *FactMemory) Match(pattern FactPattern) ([]Fact, error) {
+	records, err := f.manager.store.QueryFacts(store.FactPattern{
+		Subject:       pattern.Subject,
+		Predicate:     pattern.Predicate,
+		Object:        pattern.Object,
+		MinConfidence: pattern.MinConfidence,
+		History:       pattern.History,
+	}, f.manager.ownScope(), pattern.Limit)
 	if err != nil {
 		return nil, err
 	}
+	return factsFromRecords(records), nil
+}
 
-	facts := make([]Fact, 0, len(memories))
-	for _, mem := range memories {
-		fact := Fact{
-			Timestamp: mem.Timestamp,
-		}
+// History 主体某谓词的全部取值（含已被取代的旧值，按生效时间顺序）
+func (f *FactMemory) History(subject, predicate string) ([]Fact, error) {
+	return f.Match(FactPattern{Subject: subject, Predicate: predicate, History: true})
+}
 
-		if subject, ok := mem.Metadata["subject"].(string); ok {
-			fact.Subject = subject
-		}
-		if predicate, ok := mem.Metadata["predicate"].(string); ok {
-			fact.Predicate = predicate
-		}
-		if object, ok := mem.Metadata["object"].(string); ok {
-			fact.Object = object
-		}
-		if confidence, ok := mem.Metadata["confidence"].(float64); ok {
-			fact.Confidence = confidence
-		}
-		if source, ok := mem.Metadata["source"].(string); ok {
-			fact.Source = source
-		}
+// Neighbors 获取与实体相距hops跳以内的当前事实（不区分方向，按发现顺序）
+func (f *FactMemory) Neighbors(entity string, hops int) ([]Fact, error) {
+	if hops <= 0 {
+		hops = 1
+	}
 
-		facts = append(facts, fact)
+	visited := map[string]bool{entity: true}
+	seen := make(map[string]bool)
+	frontier := []string{entity}
+	var facts []Fact
+
+	for hop := 0; hop < hops && len(frontier) > 0; hop++ {
+		var next []string
+		for _, e := range frontier {
+			edges, err := f.edges(e)
+			if err != nil {
+				return nil, err
+			}
+			for _, fact := range edges {
+				if !seen[fact.ID] {
+					seen[fact.ID] = true
+					facts = append(facts, fact)
+				}
+				other := otherEnd(fact, e)
+				if !visited[other] {
+					visited[other] = true
+					next = append(next, other)
+				}
+			}
+		}
+		frontier = next
 	}
 
 	return facts, nil
 }
 
-// GetFactsBySubject 获取关于某主体的所有事实
-func (f *FactMemory) GetFactsBySubject(subject string) ([]Fact, error) {
-	opts := RecallOptions{
-		Limit:              50,
-		MemoryTypes:        []MemoryType{MemoryTypeFact},
-		ApplyDecay:         false,
-		WeightByImportance: true,
-		MinRelevance:       0.2,
+// FindPath 查找两个实体之间跳数最少的事实路径（不区分方向），找不到时返回nil
+// 路径中的事实按从from到to的顺序排列；maxHops<=0时使用DefaultMaxHops
+func (f *FactMemory) FindPath(from, to string, maxHops int) ([]Fact, error) {
+	if maxHops <= 0 {
+		maxHops = DefaultMaxHops
+	}
+	if from == to {
+		return []Fact{}, nil
+	}
+
+	type step struct {
+		prev string
+		fact Fact
+	}
+	parents := map[string]step{from: {}}
+	frontier := []string{from}
+
+	for hop := 0; hop < maxHops && len(frontier) > 0; hop++ {
+		var next []string
+		for _, e := range frontier {
+			edges, err := f.edges(e)
+			if err != nil {
+				return nil, err
+			}
+			for _, fact := range edges {
+				other := otherEnd(fact, e)
+				if _, ok := parents[other]; ok {
+					continue
+				}
+				parents[other] = step{prev: e, fact: fact}
+				if other == to {
+					// 从终点回溯
+					var path []Fact
+					for cur := to; cur != from; cur = parents[cur].prev {
+						path = append(path, parents[cur].fact)
+					}
+					for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+						path[i], path[j] = path[j], path[i]
+					}
+					return path, nil
+				}
+				next = append(next, other)
+			}
+		}
+		frontier = next
 	}
 
-	memories, err := f.manager.Recall(subject, opts)
+	return nil, nil
+}
+
+// edges 实体作为主体或客体的当前事实
+func (f *FactMemory) edges(entity string) ([]Fact, error) {
+	records, err := f.manager.store.QueryFacts(store.FactPattern{Entity: entity}, f.manager.ownScope(), 0)
 	if err != nil {
 		return nil, err
 	}
+	return factsFromRecords(records), nil
+}
 
-	facts := make([]Fact, 0, len(memories))
-	for _, mem := range memories {
-		// 过滤：只返回subject匹配的事实
-		if subj, ok := mem.Metadata["subject"].(string); !ok || subj != subject {
-			continue
-		}
+// otherEnd 事实中与entity相对的另一端
+func otherEnd(fact Fact, entity string) string {
+	if fact.Subject == entity {
+		return fact.Object
+	}
+	return fact.Subject
+}
 
-		fact := Fact{
-			Subject:   subject,
-			Timestamp: mem.Timestamp,
-		}
+// QueryFact 查询事实
+func (f *FactMemory) QueryFact(subject, predicate string) ([]Fact, error) {
+	query := fmt.Sprintf("%s %s", subject, predicate)
 
-		if predicate, ok := mem.Metadata["predicate"].(string); ok {
-			fact.Predicate = predicate
-		}
-		if object, ok := mem.Metadata["object"].(string); ok {
-			fact.Object = object
-		}
-		if confidence, ok := mem.Metadata["confidence"].(float64); ok {
-			fact.Confidence = confidence
-		}
-		if source, ok := mem.Metadata["source"].(string); ok {
-			fact.Source = source
-		}
+	opts := RecallOptions{
+		Limit:              10,
+		MemoryTypes:        []MemoryType{MemoryTypeFact},
+		ApplyDecay:         false, // 事实不衰减
+		WeightByImportance: true,  // 按置信度加权
+		MinRelevance:       0.3,   // 最小相关度阈值
+	}
 
-		facts = append(facts, fact)
+	memories, err := f.manager.Recall(query, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	return facts, nil
+	return factsFromMemories(memories), nil
+}
+
+// GetFactsBySubject 获取关于某主体的所有当前事实
+func (f *FactMemory) GetFactsBySubject(subject string) ([]Fact, error) {
+	return f.Match(FactPattern{Subject: subject})
 }
 
 // UpdateFactConfidence 更新事实的置信度
+// 手动设置的置信度在该事实下次被断言时按来源重新综合
 func (f *FactMemory) UpdateFactConfidence(subject, predicate, object string, newConfidence float64) error {
-	// 查找匹配的事实
-	facts, err := f.QueryFact(subject, predicate)
+	records, err := f.manager.store.QueryFacts(store.FactPattern{Subject: subject, Predicate: predicate, Object: object},
+		f.manager.ownScope(), 0)
 	if err != nil {
 		return err
 	}
+	if len(records) == 0 {
+		return fmt.Errorf("fact not found: %s %s %s", subject, predicate, object)
+	}
 
-	// 找到匹配的事实并更新
-	for _, fact := range facts {
-		if fact.Object == object {
-			// 从metadata获取memory ID
-			memories, _ := f.manager.GetByType(MemoryTypeFact)
-			for _, mem := range memories {
-				if mem.Metadata["subject"] == subject &&
-					mem.Metadata["predicate"] == predicate &&
-					mem.Metadata["object"] == object {
-
-					mem.Importance = newConfidence
-					mem.Metadata["confidence"] = newConfidence
-
-					return f.manager.Update(mem.ID, mem)
-				}
-			}
+	for _, record := range records {
+		record.Confidence = newConfidence
+		if err := f.manager.store.UpdateFact(record); err != nil {
+			return err
+		}
+		if err := f.syncMemory(record, nil); err != nil {
+			return err
 		}
 	}
-
-	return fmt.Errorf("fact not found: %s %s %s", subject, predicate, object)
+	return nil
 }
 
-// DeleteFact 删除事实
+// DeleteFact 删除事实（包括其历史记录）
 func (f *FactMemory) DeleteFact(subject, predicate, object string) error {
-	// 查找并删除匹配的事实
-	memories, err := f.manager.GetByType(MemoryTypeFact)
+	records, err := f.manager.store.QueryFacts(store.FactPattern{
+		Subject: subject, Predicate: predicate, Object: object, History: true,
+	}, f.manager.ownScope(), 0)
 	if err != nil {
 		return err
 	}
-
-	for _, mem := range memories {
-		if mem.Metadata["subject"] == subject &&
-			mem.Metadata["predicate"] == predicate &&
-			mem.Metadata["object"] == object {
-
-			return f.manager.Delete(mem.ID)
-		}
+	if len(records) == 0 {
+		return fmt.Errorf("fact not found: %s %s %s", subject, predicate, object)
 	}
 
-	return fmt.Errorf("fact not found: %s %s %s", subject, predicate, object)
-}
-
-// GetAllFacts 获取所有事实
-func (f *FactMemory) GetAllFacts() ([]Fact, error) {
-	memories, err := f.manager.GetByType(MemoryTypeFact)
-	if err != nil {
-		return nil, err
-	}
-
-	facts := make([]Fact, 0, len(memories))
-	for _, mem := range memories {
-		fact := Fact{
-			Timestamp: mem.Timestamp,
-		}
-
-		if subject, ok := mem.Metadata["subject"].(string); ok {
-			fact.Subject = subject
+	for _, record := range records {
+		if err := f.unlinkMemory(record.ID); err != nil {
+			return err
 		}
-		if predicate, ok := mem.Metadata["predicate"].(string); ok {
-			fact.Predicate = predicate
-		}
-		if object, ok := mem.Metadata["object"].(string); ok {
-			fact.Object = object
+		if err := f.manager.store.DeleteFact(record.ID); err != nil {
+			return err
 		}
-		if confidence, ok := mem.Metadata["confidence"].(float64); ok {
-			fact.Confidence = confidence
-		}
-		if source, ok := mem.Metadata["source"].(string); ok {
-			fact.Source = source
-		}
-
-		facts = append(facts, fact)
 	}
+	return nil
+}
 
-	return facts, nil
+// GetAllFacts 获取所有当前事实
+func (f *FactMemory) GetAllFacts() ([]Fact, error) {
+	return f.Match(FactPattern{})
 }
 
 // SearchFacts 语义搜索事实
@@ -242,12 +426,55 @@ func (f *FactMemory) SearchFacts(query string, limit int) ([]Fact, error) {
 		return nil, err
 	}
 
+	return factsFromMemories(memories), nil
+}
+
+func factFromRecord(r store.FactRecord) Fact {
+	fact := Fact{
+		ID:           r.ID,
+		Subject:      r.Subject,
+		Predicate:    r.Predicate,
+		Object:       r.Object,
+		Confidence:   r.Confidence,
+		Timestamp:    r.ValidFrom,
+		Sources:      r.Sources,
+		SupersededAt: r.SupersededAt,
+		SupersededBy: r.SupersededBy,
+	}
+
+	// 置信度最高的来源（相同时取名称靠前的）
+	sources := make([]string, 0, len(r.Sources))
+	for s := range r.Sources {
+		sources = append(sources, s)
+	}
+	sort.Strings(sources)
+	best := -1.0
+	for _, s := range sources {
+		if r.Sources[s] > best {
+			fact.Source, best = s, r.Sources[s]
+		}
+	}
+	return fact
+}
+
+func factsFromRecords(records []store.FactRecord) []Fact {
+	facts := make([]Fact, 0, len(records))
+	for _, r := range records {
+		facts = append(facts, factFromRecord(r))
+	}
+	return facts
+}
+
+func factsFromMemories(memories []Memory) []Fact {
 	facts := make([]Fact, 0, len(memories))
 	for _, mem := range memories {
 		fact := Fact{
 			Timestamp: mem.Timestamp,
 		}
 
+		if id, ok := mem.Metadata["fact_id"].(string); ok {
+			fact.ID = id
+		}
 		if subject, ok := mem.Metadata["subject"].(string); ok {
 			fact.Subject = subject
 		}
@@ -266,6 +493,5 @@ func (f *FactMemory) SearchFacts(query string, limit int) ([]Fact, error) {
 
 		facts = append(facts, fact)
 	}
-
-	return facts, nil
+	return facts
 }
@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+// ExpiryState 记忆的过期状态
+type ExpiryState string
+
+const (
+	ExpiryAny     ExpiryState = ""        // 不限
+	ExpiryActive  ExpiryState = "active"  // 未过期（含未设置过期时间）
+	ExpiryExpired ExpiryState = "expired" // 已过期
+)
+
+// Filter 记忆过滤条件，各条件之间为AND关系，零值不过滤
+// 条件下推到存储层执行，回忆时先过滤再取相似度最高的候选
+type Filter struct {
+	AnyTags       []string               // 至少包含其中一个标签
+	AllTags       []string               // 包含全部标签
+	Metadata      map[string]interface{} // metadata中对应键的值相等
+	Since         time.Time              // 时间不早于Since
+	Until         time.Time              // 时间早于Until
+	EndsSince     time.Time              // 情景事件的结束时间（瞬时事件为开始时间）不早于EndsSince
+	MinImportance float64                // 重要性不低于该值
+	MaxImportance float64                // 重要性不高于该值，0表示不限
+	Expiry        ExpiryState
+}
+
+func (f Filter) toStore(types []MemoryType) store.MemoryFilter {
+	var memTypes []string
+	if types != nil {
+		memTypes = make([]string, len(types))
+		for i, mt := range types {
+			memTypes[i] = string(mt)
+		}
+	}
+	return store.MemoryFilter{
+		Types:         memTypes,
+		AnyTags:       f.AnyTags,
+		AllTags:       f.AllTags,
+		Metadata:      f.Metadata,
+		Since:         f.Since,
+		Until:         f.Until,
+		EndsSince:     f.EndsSince,
+		MinImportance: f.MinImportance,
+		MaxImportance: f.MaxImportance,
+		Expiry:        store.ExpiryState(f.Expiry),
+	}
+}
+
+// ListOptions 列出记忆的选项
+type ListOptions struct {
+	MemoryTypes []MemoryType
+	Filter      Filter
+	Scopes      []Namespace // 为空时为管理器的命名空间
+	Limit       int         // 每页数量，0表示不限
+	Offset      int
+	OldestFirst bool // 按时间正序，默认按时间倒序
+}
+
+// MemoryPage 一页记忆（默认按时间倒序）
+type MemoryPage struct {
+	Memories []Memory
+	Total    int // 满足条件的记忆总数
+	Offset   int
+}
+
+// HasMore 之后是否还有记忆
+func (p *MemoryPage) HasMore() bool {
+	return p.Offset+len(p.Memories) < p.Total
+}
+
+// List 按过滤条件分页列出记忆
+func (m *Manager) List(opts ListOptions) (*MemoryPage, error) {
+	scopes, err := m.scopes(opts.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Limit < 0 || opts.Offset < 0 {
+		return nil, fmt.Errorf("invalid page: limit=%d offset=%d", opts.Limit, opts.Offset)
+	}
+
+	order := store.NewestFirst
+	if opts.OldestFirst {
+		order = store.OldestFirst
+	}
+	results, total, err := m.store.ListMemories(opts.Filter.toStore(opts.MemoryTypes), scopes, order, opts.Limit, opts.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &MemoryPage{Memories: make([]Memory, len(results)), Total: total, Offset: opts.Offset}
+	for i, r := range results {
+		page.Memories[i] = memoryFromResult(r)
+	}
+	return page, nil
+}
@@ -0,0 +1,230 @@
+package memory
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ForgetAction 遗忘动作
+type ForgetAction string
+
+const (
+	ForgetArchive ForgetAction = "archive" // 移入归档，不再参与召回，可恢复（默认）
+	ForgetDelete  ForgetAction = "delete"  // 直接删除
+)
+
+// ForgettingPolicy 遗忘策略：强度低于阈值的记忆被归档或删除
+type ForgettingPolicy struct {
+	Types       []MemoryType   // 适用的记忆类型，为空时适用于除会话消息外的所有类型（会话消息需显式列出）
+	Namespace   Namespace      // 适用的命名空间（限制在管理器的命名空间内），零值为管理器的命名空间
+	MinStrength float64        // 强度低于该值的记忆被遗忘
+	MinAge      time.Duration  // 写入不足该时长的记忆不遗忘
+	Action      ForgetAction   // 为空时为ForgetArchive
+	Model       *StrengthModel // 为nil时使用清理器的强度模型
+}
+
+// JanitorOptions 记忆清理器选项
+type JanitorOptions struct {
+	Interval time.Duration                       // 后台清理间隔
+	Model    StrengthModel                       // 默认强度模型
+	Policies []ForgettingPolicy                  // 按顺序匹配，每条记忆只应用第一条适用的策略
+	DryRun   bool                                // 只统计将被清理的记忆，不做修改
+	OnRun    func(stats JanitorStats, err error) // 后台每次清理后回调（可选）
+}
+
+// DefaultJanitorOptions 默认清理器选项（只清理过期记忆）
+func DefaultJanitorOptions() JanitorOptions {
+	return JanitorOptions{
+		Interval: time.Hour,
+		Model:    DefaultStrengthModel(),
+	}
+}
+
+// JanitorStats 一次清理的统计
+type JanitorStats struct {
+	Expired   int      // 删除的过期记忆
+	Scanned   int      // 按遗忘策略检查的记忆
+	Archived  int      // 归档的记忆
+	Deleted   int      // 按遗忘策略删除的记忆
+	Forgotten []string // 被遗忘（DryRun时为将被遗忘）的记忆ID
+}
+
+// Janitor 记忆清理器：删除过期记忆，并按遗忘策略归档或删除强度过低的记忆
+type Janitor struct {
+	manager *Manager
+	opts    JanitorOptions
+
+	runMu    sync.Mutex // 串行执行RunOnce
+	schedule periodic
+}
+
+// NewJanitor 创建记忆清理器，作用范围为管理器的命名空间
+func NewJanitor(manager *Manager, opts JanitorOptions) *Janitor {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultJanitorOptions().Interval
+	}
+	if opts.Model.Halflife <= 0 {
+		opts.Model = DefaultStrengthModel()
+	}
+	return &Janitor{manager: manager, opts: opts}
+}
+
+// RunOnce 执行一次清理：先删除过期记忆，再按遗忘策略处理
+func (j *Janitor) RunOnce() (JanitorStats, error) {
+	j.runMu.Lock()
+	defer j.runMu.Unlock()
+
+	var stats JanitorStats
+	now := time.Now()
+
+	// 1. 过期记忆直接删除，不归档
+	if j.opts.DryRun {
+		page, err := j.manager.List(ListOptions{Filter: Filter{Expiry: ExpiryExpired}, Limit: 1})
+		if err != nil {
+			return stats, fmt.Errorf("failed to count expired memories: %w", err)
+		}
+		stats.Expired = page.Total
+	} else {
+		expired, err := j.manager.CleanupExpired()
+		if err != nil {
+			return stats, fmt.Errorf("failed to cleanup expired memories: %w", err)
+		}
+		stats.Expired = expired
+	}
+
+	// 2. 遗忘策略，先匹配的策略优先
+	handled := make(map[string]bool)
+	for i, policy := range j.opts.Policies {
+		model := j.opts.Model
+		if policy.Model != nil {
+			model = *policy.Model
+		}
+
+		var scopes []Namespace
+		if !policy.Namespace.IsZero() {
+			scopes = []Namespace{policy.Namespace}
+		}
+		page, err := j.manager.List(ListOptions{
+			MemoryTypes: policy.Types,
+			Filter:      Filter{Expiry: ExpiryActive},
+			Scopes:      scopes,
+		})
+		if err != nil {
+			return stats, fmt.Errorf("failed to list memories for policy %d: %w", i, err)
+		}
+
+		var ids []string
+		for _, mem := range page.Memories {
+			if len(policy.Types) == 0 && mem.Type == MemoryTypeConversation {
+				continue // 会话消息由会话窗口和摘要管理，不随通用策略遗忘
+			}
+			if handled[mem.ID] {
+				continue
+			}
+			handled[mem.ID] = true
+			stats.Scanned++
+
+			if now.Sub(mem.Timestamp) < policy.MinAge || model.Strength(mem, now) >= policy.MinStrength {
+				continue
+			}
+			ids = append(ids, mem.ID)
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		stats.Forgotten = append(stats.Forgotten, ids...)
+
+		if err := j.forget(policy.Action, ids, now, &stats); err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
+
+// forget 按动作归档或删除记忆
+func (j *Janitor) forget(action ForgetAction, ids []string, now time.Time, stats *JanitorStats) error {
+	switch action {
+	case "", ForgetArchive:
+		if j.opts.DryRun {
+			stats.Archived += len(ids)
+			return nil
+		}
+		archived, err := j.manager.store.ArchiveMemories(ids, now)
+		if err != nil {
+			return err
+		}
+		stats.Archived += archived
+	case ForgetDelete:
+		if j.opts.DryRun {
+			stats.Deleted += len(ids)
+			return nil
+		}
+		for _, id := range ids {
+			if err := j.manager.store.DeleteMemory(id); err != nil {
+				return fmt.Errorf("failed to delete memory %s: %w", id, err)
+			}
+			stats.Deleted++
+		}
+	default:
+		return fmt.Errorf("unknown forget action: %s", action)
+	}
+	return nil
+}
+
+// Start 在后台按间隔定期清理，重复调用无效
+func (j *Janitor) Start() {
+	j.schedule.start(j.opts.Interval, func() {
+		stats, err := j.RunOnce()
+		if j.opts.OnRun != nil {
+			j.opts.OnRun(stats, err)
+		}
+	})
+}
+
+// Stop 停止后台清理并等待正在进行的清理结束
+func (j *Janitor) Stop() {
+	j.schedule.halt()
+}
+
+// ArchivedMemory 被遗忘策略归档的记忆
+type ArchivedMemory struct {
+	Memory
+	ArchivedAt time.Time
+}
+
+// ListArchived 按归档时间倒序列出命名空间内的归档记忆，同时返回总数（limit为0表示不限）
+func (m *Manager) ListArchived(limit, offset int) ([]ArchivedMemory, int, error) {
+	results, total, err := m.store.ListArchivedMemories(m.ownScope(), limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	archived := make([]ArchivedMemory, len(results))
+	for i, r := range results {
+		archived[i] = ArchivedMemory{Memory: memoryFromResult(r.MemoryResult), ArchivedAt: r.ArchivedAt}
+	}
+	return archived, total, nil
+}
+
+// RestoreArchived 将命名空间内的归档记忆恢复为活跃记忆
+func (m *Manager) RestoreArchived(id string) error {
+	if !m.namespace.IsZero() {
+		archived, _, err := m.store.ListArchivedMemories(m.ownScope(), 0, 0)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, a := range archived {
+			if a.ID == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("archived memory %s: %w", id, ErrOutsideNamespace)
+		}
+	}
+	return m.store.RestoreArchivedMemory(id)
+}
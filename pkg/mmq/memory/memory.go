@@ -2,6 +2,7 @@
 package memory
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"sort"
@@ -19,11 +20,38 @@ const (
 	MemoryTypeFact         MemoryType = "fact"
 	MemoryTypePreference   MemoryType = "preference"
 	MemoryTypeEpisodic     MemoryType = "episodic"
+	MemoryTypeReflection   MemoryType = "reflection" // 由其他记忆归纳出的高层洞察
 )
 
+// ErrOutsideNamespace 访问了管理器命名空间之外的记忆
+var ErrOutsideNamespace = errors.New("outside memory namespace")
+
+// Namespace 记忆的归属：租户、用户、agent和会话
+// 作为检索范围时，非空字段必须相同，空字段不限定
+type Namespace struct {
+	Tenant  string
+	User    string
+	Agent   string
+	Session string
+}
+
+// IsZero 是否未设置任何字段
+func (ns Namespace) IsZero() bool {
+	return ns == Namespace{}
+}
+
+func (ns Namespace) toStore() store.Namespace {
+	return store.Namespace{Tenant: ns.Tenant, User: ns.User, Agent: ns.Agent, Session: ns.Session}
+}
+
+func namespaceFromStore(ns store.Namespace) Namespace {
+	return Namespace{Tenant: ns.Tenant, User: ns.User, Agent: ns.Agent, Session: ns.Session}
+}
+
 // Memory 记忆结构
 type Memory struct {
 	ID         string
+	Namespace  Namespace
 	Type       MemoryType
 	Content    string
 	Metadata   map[string]interface{}
@@ -32,8 +60,27 @@ type Memory struct {
 	ExpiresAt  *time.Time
 	Importance float64   // 0.0-1.0
 	Relevance  float64   // 检索时的相关度
+
+	AccessCount  int        // 被召回的次数
+	LastAccessed *time.Time // 最近一次被召回的时间，nil表示从未被召回
 }
 
+// RecallMode 回忆的检索方式
+type RecallMode string
+
+const (
+	RecallModeVector RecallMode = "vector" // 向量相似度（默认）
+	RecallModeFTS    RecallMode = "fts"    // BM25全文检索，适合精确的名称、编号等
+	RecallModeHybrid RecallMode = "hybrid" // 全文和向量结果按RRF融合
+)
+
+const (
+	// hybridRRFK 混合检索的RRF k值
+	hybridRRFK = 60
+	// hybridTopRankBonus ReciprocalRankFusion给排名第一的结果的奖励分
+	hybridTopRankBonus = 0.05
+)
+
 // RecallOptions 回忆选项
 type RecallOptions struct {
 	Limit              int
@@ -42,6 +89,10 @@ type RecallOptions struct {
 	DecayHalflife      time.Duration
 	WeightByImportance bool
 	MinRelevance       float64
+	Scopes             []Namespace // 检索的命名空间（取并集），为空时为管理器的命名空间
+	Mode               RecallMode  // 检索方式，为空时为RecallModeVector
+	Filter             Filter      // 标签、元数据、时间、重要性和过期状态过滤
+	NoReinforce        bool        // 不记录本次召回：被召回记忆的召回次数和最近召回时间不变
 }
 
 // DefaultRecallOptions 默认回忆选项
@@ -53,25 +104,100 @@ func DefaultRecallOptions() RecallOptions {
 		DecayHalflife:      24 * time.Hour * 30, // 30天半衰期
 		WeightByImportance: true,
 		MinRelevance:       0.0,
+		Mode:               RecallModeVector,
 	}
 }
 
 // Manager 记忆管理器
 type Manager struct {
-	store     *store.Store
+	store     store.Backend
 	embedding *llm.EmbeddingGenerator
+	namespace Namespace    // 零值表示不限定
+	dedup     DedupOptions // 写入时的语义去重
 }
 
 // NewManager 创建记忆管理器
-func NewManager(st *store.Store, embedding *llm.EmbeddingGenerator) *Manager {
+func NewManager(st store.Backend, embedding *llm.EmbeddingGenerator) *Manager {
 	return &Manager{
 		store:     st,
 		embedding: embedding,
 	}
 }
 
+// WithNamespace 返回限定在命名空间内的管理器（共享存储和嵌入生成器）
+// 限定后只能写入、检索、统计和清理该命名空间内的记忆，多个用户共用一个数据库时互不可见
+func (m *Manager) WithNamespace(ns Namespace) *Manager {
+	scoped := *m
+	scoped.namespace = ns
+	return &scoped
+}
+
+// Namespace 返回管理器限定的命名空间
+func (m *Manager) Namespace() Namespace {
+	return m.namespace
+}
+
+// within 在管理器的命名空间内解析ns：空字段取管理器的值，与管理器冲突时返回ErrOutsideNamespace
+func (m *Manager) within(ns Namespace) (Namespace, error) {
+	resolved := ns
+	for _, f := range []struct {
+		bound string
+		value *string
+	}{
+		{m.namespace.Tenant, &resolved.Tenant},
+		{m.namespace.User, &resolved.User},
+		{m.namespace.Agent, &resolved.Agent},
+		{m.namespace.Session, &resolved.Session},
+	} {
+		if f.bound == "" {
+			continue
+		}
+		if *f.value != "" && *f.value != f.bound {
+			return Namespace{}, fmt.Errorf("%w: %+v", ErrOutsideNamespace, ns)
+		}
+		*f.value = f.bound
+	}
+	return resolved, nil
+}
+
+// scopes 检索范围：未指定时为管理器的命名空间，指定的范围都限制在管理器的命名空间内
+func (m *Manager) scopes(requested []Namespace) ([]store.Namespace, error) {
+	if len(requested) == 0 {
+		return m.ownScope(), nil
+	}
+
+	scopes := make([]store.Namespace, 0, len(requested))
+	for _, ns := range requested {
+		resolved, err := m.within(ns)
+		if err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, resolved.toStore())
+	}
+	return scopes, nil
+}
+
+// ownScope 管理器的命名空间范围，未限定时为nil（全部记忆）
+func (m *Manager) ownScope() []store.Namespace {
+	if m.namespace.IsZero() {
+		return nil
+	}
+	return []store.Namespace{m.namespace.toStore()}
+}
+
+// owns 记忆是否在管理器的命名空间内
+func (m *Manager) owns(result *store.MemoryResult) bool {
+	return m.namespace.toStore().Contains(result.Namespace)
+}
+
 // Store 存储记忆
+// 记忆未设置的命名空间字段取管理器的值；开启去重时近似重复的记忆合并到已有记忆
 func (m *Manager) Store(mem Memory) error {
+	ns, err := m.within(mem.Namespace)
+	if err != nil {
+		return err
+	}
+
 	// 1. 生成嵌入
 	embedding, err := m.embedding.Generate(mem.Content, false)
 	if err != nil {
@@ -83,30 +209,35 @@ func (m *Manager) Store(mem Memory) error {
 		mem.Importance = 0.5 // 默认中等重要性
 	}
 
-	// 3. 存储到数据库
-	return m.store.InsertMemory(string(mem.Type), mem.Content, mem.Metadata, mem.Tags,
+	// 3. 语义去重
+	if m.dedup.Threshold > 0 && dedupable(mem.Type) && !linkedFact(mem.Metadata) {
+		existing, err := m.findDuplicate(ns, mem, embedding)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			mergeDuplicate(existing, mem, m.dedup.boost())
+			return m.store.MergeMemories([]store.MemoryMerge{mergeOf(*existing, nil)})
+		}
+	}
+
+	// 4. 存储到数据库
+	return m.store.InsertMemory(ns.toStore(), string(mem.Type), mem.Content, mem.Metadata, mem.Tags,
 		mem.Timestamp, mem.ExpiresAt, mem.Importance, embedding)
 }
 
 // Recall 回忆记忆
 func (m *Manager) Recall(query string, opts RecallOptions) ([]Memory, error) {
-	// 1. 生成查询向量
-	queryEmbedding, err := m.embedding.Generate(query, true)
+	scopes, err := m.scopes(opts.Scopes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+		return nil, err
 	}
 
-	// 2. 向量搜索
-	// 转换MemoryType到string
-	var memTypes []string
-	if opts.MemoryTypes != nil {
-		memTypes = make([]string, len(opts.MemoryTypes))
-		for i, mt := range opts.MemoryTypes {
-			memTypes[i] = string(mt)
-		}
-	}
+	// 1. 过滤条件（包括记忆类型）
+	filter := opts.Filter.toStore(opts.MemoryTypes)
 
-	results, err := m.store.SearchMemories(queryEmbedding, opts.Limit*2, memTypes)
+	// 2. 按检索方式搜索候选记忆
+	results, err := m.search(query, opts.Mode, opts.Limit*2, filter, scopes)
 	if err != nil {
 		return nil, err
 	}
@@ -116,6 +247,7 @@ func (m *Manager) Recall(query string, opts RecallOptions) ([]Memory, error) {
 	for i, r := range results {
 		memories[i] = Memory{
 			ID:         r.ID,
+			Namespace:  namespaceFromStore(r.Namespace),
 			Type:       MemoryType(r.Type),
 			Content:    r.Content,
 			Metadata:   r.Metadata,
@@ -124,10 +256,13 @@ func (m *Manager) Recall(query string, opts RecallOptions) ([]Memory, error) {
 			ExpiresAt:  r.ExpiresAt,
 			Importance: r.Importance,
 			Relevance:  r.Relevance,
+
+			AccessCount:  r.AccessCount,
+			LastAccessed: r.LastAccessed,
 		}
 	}
 
-	// 4. 应用时间衰减
+	// 4. 应用时间衰减（被召回过的记忆衰减更慢）
 	if opts.ApplyDecay {
 		memories = m.applyTimeDecay(memories, opts.DecayHalflife)
 	}
@@ -158,16 +293,120 @@ func (m *Manager) Recall(query string, opts RecallOptions) ([]Memory, error) {
 		memories = memories[:opts.Limit]
 	}
 
+	// 9. 记录召回，强化返回的记忆
+	if !opts.NoReinforce && len(memories) > 0 {
+		if err := m.reinforce(memories); err != nil {
+			return nil, err
+		}
+	}
+
 	return memories, nil
 }
 
+// reinforce 记录一次召回，并同步返回记忆的召回统计
+func (m *Manager) reinforce(memories []Memory) error {
+	now := time.Now()
+	ids := make([]string, len(memories))
+	for i, mem := range memories {
+		ids[i] = mem.ID
+	}
+	if err := m.store.TouchMemories(ids, now); err != nil {
+		return err
+	}
+
+	accessed := now.Truncate(time.Second)
+	for i := range memories {
+		memories[i].AccessCount++
+		memories[i].LastAccessed = &accessed
+	}
+	return nil
+}
+
+// search 按检索方式搜索候选记忆
+func (m *Manager) search(query string, mode RecallMode, limit int, filter store.MemoryFilter, scopes []store.Namespace) ([]store.MemoryResult, error) {
+	switch mode {
+	case "", RecallModeVector, RecallModeHybrid:
+	case RecallModeFTS:
+		return m.store.SearchMemoriesFTS(query, limit, filter, scopes)
+	default:
+		return nil, fmt.Errorf("unknown recall mode: %s", mode)
+	}
+
+	queryEmbedding, err := m.embedding.Generate(query, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+	}
+
+	vectorResults, err := m.store.SearchMemories(queryEmbedding, limit, filter, scopes)
+	if err != nil {
+		return nil, err
+	}
+	if mode != RecallModeHybrid {
+		return vectorResults, nil
+	}
+
+	ftsResults, err := m.store.SearchMemoriesFTS(query, limit, filter, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	return fuseMemoryResults(ftsResults, vectorResults, limit), nil
+}
+
+// fuseMemoryResults 用RRF融合全文和向量结果
+// Relevance为RRF分数除以两路都排第一时的分数，归一化到0-1后再参与时间衰减和重要性加权
+func fuseMemoryResults(ftsResults, vectorResults []store.MemoryResult, limit int) []store.MemoryResult {
+	byID := make(map[string]store.MemoryResult, len(ftsResults)+len(vectorResults))
+	lists := make([][]store.SearchResult, 2)
+	for i, results := range [][]store.MemoryResult{ftsResults, vectorResults} {
+		for _, r := range results {
+			byID[r.ID] = r
+			lists[i] = append(lists[i], store.SearchResult{ID: r.ID})
+		}
+	}
+
+	fused := store.ReciprocalRankFusion(lists, nil, hybridRRFK)
+	maxScore := 2.0/float64(hybridRRFK+1) + hybridTopRankBonus
+
+	if len(fused) > limit {
+		fused = fused[:limit]
+	}
+	results := make([]store.MemoryResult, len(fused))
+	for i, f := range fused {
+		results[i] = byID[f.ID]
+		results[i].Relevance = math.Min(f.Score/maxScore, 1)
+	}
+	return results
+}
+
+// memoryFromResult 转换存储层的记忆
+func memoryFromResult(r store.MemoryResult) Memory {
+	return Memory{
+		ID:         r.ID,
+		Namespace:  namespaceFromStore(r.Namespace),
+		Type:       MemoryType(r.Type),
+		Content:    r.Content,
+		Metadata:   r.Metadata,
+		Tags:       r.Tags,
+		Timestamp:  r.Timestamp,
+		ExpiresAt:  r.ExpiresAt,
+		Importance: r.Importance,
+		Relevance:  r.Relevance,
+
+		AccessCount:  r.AccessCount,
+		LastAccessed: r.LastAccessed,
+	}
+}
+
 // applyTimeDecay 应用时间衰减
+// 从最近一次强化（写入或召回）开始计算，每次召回按默认强度模型延长半衰期
 func (m *Manager) applyTimeDecay(memories []Memory, halflife time.Duration) []Memory {
 	now := time.Now()
+	model := DefaultStrengthModel()
+	model.Halflife = halflife
 
 	for i := range memories {
-		age := now.Sub(memories[i].Timestamp)
-		decayFactor := math.Exp(-age.Hours() / halflife.Hours())
+		decayFactor := model.Retention(memories[i], now)
 
 		// 调整相关性分数
 		memories[i].Relevance *= decayFactor
@@ -187,8 +426,12 @@ func (m *Manager) weightByImportance(memories []Memory) []Memory {
 	return memories
 }
 
-// Update 更新记忆
+// Update 更新记忆（命名空间不变）
 func (m *Manager) Update(id string, mem Memory) error {
+	if _, err := m.GetByID(id); err != nil {
+		return err
+	}
+
 	// 生成新的嵌入
 	embedding, err := m.embedding.Generate(mem.Content, false)
 	if err != nil {
@@ -201,6 +444,9 @@ func (m *Manager) Update(id string, mem Memory) error {
 
 // Delete 删除记忆
 func (m *Manager) Delete(id string) error {
+	if _, err := m.GetByID(id); err != nil {
+		return err
+	}
 	return m.store.DeleteMemory(id)
 }
 
@@ -210,9 +456,13 @@ func (m *Manager) GetByID(id string) (*Memory, error) {
 	if err != nil {
 		return nil, err
 	}
+	if !m.owns(result) {
+		return nil, fmt.Errorf("memory %s: %w", id, ErrOutsideNamespace)
+	}
 
 	mem := &Memory{
 		ID:         result.ID,
+		Namespace:  namespaceFromStore(result.Namespace),
 		Type:       MemoryType(result.Type),
 		Content:    result.Content,
 		Metadata:   result.Metadata,
@@ -221,6 +471,9 @@ func (m *Manager) GetByID(id string) (*Memory, error) {
 		ExpiresAt:  result.ExpiresAt,
 		Importance: result.Importance,
 		Relevance:  0,
+
+		AccessCount:  result.AccessCount,
+		LastAccessed: result.LastAccessed,
 	}
 
 	return mem, nil
@@ -228,7 +481,7 @@ func (m *Manager) GetByID(id string) (*Memory, error) {
 
 // GetByType 获取指定类型的所有记忆
 func (m *Manager) GetByType(memType MemoryType) ([]Memory, error) {
-	results, err := m.store.GetMemoriesByType(string(memType))
+	results, err := m.store.GetMemoriesByType(string(memType), m.ownScope())
 	if err != nil {
 		return nil, err
 	}
@@ -237,6 +490,7 @@ func (m *Manager) GetByType(memType MemoryType) ([]Memory, error) {
 	for i, r := range results {
 		memories[i] = Memory{
 			ID:         r.ID,
+			Namespace:  namespaceFromStore(r.Namespace),
 			Type:       MemoryType(r.Type),
 			Content:    r.Content,
 			Metadata:   r.Metadata,
@@ -245,23 +499,26 @@ func (m *Manager) GetByType(memType MemoryType) ([]Memory, error) {
 			ExpiresAt:  r.ExpiresAt,
 			Importance: r.Importance,
 			Relevance:  0,
+
+			AccessCount:  r.AccessCount,
+			LastAccessed: r.LastAccessed,
 		}
 	}
 
 	return memories, nil
 }
 
-// CleanupExpired 清理过期记忆
+// CleanupExpired 清理命名空间内的过期记忆
 func (m *Manager) CleanupExpired() (int, error) {
-	return m.store.DeleteExpiredMemories()
+	return m.store.DeleteExpiredMemories(m.ownScope())
 }
 
-// Count 统计记忆数量
+// Count 统计命名空间内的记忆数量
 func (m *Manager) Count() (int, error) {
-	return m.store.CountMemories()
+	return m.store.CountMemories(m.ownScope())
 }
 
-// CountByType 按类型统计记忆数量
+// CountByType 按类型统计命名空间内的记忆数量
 func (m *Manager) CountByType(memType MemoryType) (int, error) {
-	return m.store.CountMemoriesByType(string(memType))
+	return m.store.CountMemoriesByType(string(memType), m.ownScope())
 }
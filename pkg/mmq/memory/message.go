@@ -0,0 +1,375 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/llm"
+	"github.com/crosszan/modu/pkg/mmq/rag"
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+// MessageRole 消息角色
+type MessageRole string
+
+const (
+	RoleSystem    MessageRole = "system"
+	RoleUser      MessageRole = "user"
+	RoleAssistant MessageRole = "assistant"
+	RoleTool      MessageRole = "tool"
+)
+
+// ToolCall 助手发起的工具调用
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // 调用参数（通常为JSON）
+}
+
+// Message 会话中的一条消息
+type Message struct {
+	ID         string
+	SessionID  string
+	Role       MessageRole
+	Content    string
+	Name       string     // 发言者或工具名称（可选）
+	ToolCalls  []ToolCall // 助手消息发起的工具调用
+	ToolCallID string     // 工具消息对应的调用ID
+	Tokens     int        // 消息token数，写入时为0则按分词器计算
+	Index      int        // 在会话中的位置（读取时按时间顺序编号，从0开始）
+	Timestamp  time.Time
+	Metadata   map[string]interface{}
+}
+
+// Summarizer 会话摘要生成器
+type Summarizer interface {
+	// Summarize 将新消息合并进已有摘要（previous为空表示从头生成），摘要不超过maxTokens
+	Summarize(previous string, messages []Message, maxTokens int) (string, error)
+}
+
+// conversationSummaryPrompt 会话滚动摘要的生成提示
+const conversationSummaryPrompt = `Update the running summary of a conversation with the new messages below.
+Keep facts, decisions, user preferences and open tasks; drop greetings and small talk. Answer in the same language as the conversation. Output only the summary.
+
+Current summary:
+%s
+
+New messages:
+%s
+
+Updated summary:`
+
+// LLMSummarizer 使用LLM生成会话摘要
+type LLMSummarizer struct {
+	model llm.LLM
+}
+
+// NewLLMSummarizer 创建基于LLM的会话摘要生成器
+func NewLLMSummarizer(model llm.LLM) *LLMSummarizer {
+	return &LLMSummarizer{model: model}
+}
+
+// Summarize 将新消息合并进已有摘要
+func (s *LLMSummarizer) Summarize(previous string, messages []Message, maxTokens int) (string, error) {
+	if previous == "" {
+		previous = "(none)"
+	}
+
+	opts := llm.DefaultGenerateOptions()
+	opts.Temperature = 0.3
+	if maxTokens > 0 {
+		opts.MaxTokens = maxTokens
+	}
+
+	text, err := s.model.Generate(fmt.Sprintf(conversationSummaryPrompt, previous, formatMessages(messages)), opts)
+	if err != nil {
+		return "", err
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return text, nil
+}
+
+// formatMessages 将消息渲染为 "角色: 内容" 的文本
+func formatMessages(messages []Message) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		role := string(msg.Role)
+		if msg.Name != "" {
+			role += " (" + msg.Name + ")"
+		}
+		content := msg.Content
+		if content == "" {
+			content = renderToolCalls(msg.ToolCalls)
+		}
+		fmt.Fprintf(&b, "%s: %s\n", role, content)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// renderToolCalls 渲染工具调用（没有文本内容的助手消息以此作为记忆内容）
+func renderToolCalls(calls []ToolCall) string {
+	parts := make([]string, 0, len(calls))
+	for _, call := range calls {
+		parts = append(parts, fmt.Sprintf("%s(%s)", call.Name, call.Arguments))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// AppendMessage 追加一条消息到会话
+func (c *ConversationMemory) AppendMessage(msg Message) error {
+	if msg.SessionID == "" {
+		return fmt.Errorf("message requires a session id")
+	}
+	switch msg.Role {
+	case RoleSystem, RoleUser, RoleAssistant, RoleTool:
+	default:
+		return fmt.Errorf("unknown message role: %s", msg.Role)
+	}
+
+	content := msg.Content
+	if content == "" {
+		content = renderToolCalls(msg.ToolCalls)
+	}
+	if content == "" {
+		return fmt.Errorf("message has neither content nor tool calls")
+	}
+
+	if msg.Tokens == 0 {
+		msg.Tokens = c.tokenizer.Count(content)
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+
+	metadata := make(map[string]interface{}, len(msg.Metadata)+6)
+	for k, v := range msg.Metadata {
+		metadata[k] = v
+	}
+	metadata["role"] = string(msg.Role)
+	metadata["tokens"] = msg.Tokens
+	metadata["session_id"] = msg.SessionID
+	if msg.Name != "" {
+		metadata["name"] = msg.Name
+	}
+	if len(msg.ToolCalls) > 0 {
+		metadata["tool_calls"] = msg.ToolCalls
+	}
+	if msg.ToolCallID != "" {
+		metadata["tool_call_id"] = msg.ToolCallID
+	}
+
+	return c.manager.Store(Memory{
+		Namespace:  Namespace{Session: msg.SessionID},
+		Type:       MemoryTypeConversation,
+		Content:    content,
+		Metadata:   metadata,
+		Timestamp:  msg.Timestamp,
+		Importance: 0.5,
+	})
+}
+
+// GetMessages 按时间顺序获取会话的全部消息
+// 以StoreTurn写入的对话轮次拆分为用户和助手两条消息
+func (c *ConversationMemory) GetMessages(sessionID string) ([]Message, error) {
+	results, err := c.manager.store.GetMemoriesBySession(sessionID, -1, c.manager.ownScope())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	var messages []Message
+	// 存储按时间倒序返回，这里反转为正序
+	for i := len(results) - 1; i >= 0; i-- {
+		mem := memoryFromResult(results[i])
+		for _, msg := range c.messagesFromMemory(sessionID, mem) {
+			msg.Index = len(messages)
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
+}
+
+// messagesFromMemory 将对话记忆转换为消息
+func (c *ConversationMemory) messagesFromMemory(sessionID string, mem Memory) []Message {
+	base := Message{
+		ID:        mem.ID,
+		SessionID: sessionID,
+		Timestamp: mem.Timestamp,
+		Metadata:  mem.Metadata,
+	}
+
+	role, ok := mem.Metadata["role"].(string)
+	if !ok {
+		// StoreTurn写入的对话轮次
+		var messages []Message
+		if userMsg, ok := mem.Metadata["user_msg"].(string); ok && userMsg != "" {
+			msg := base
+			msg.Role, msg.Content, msg.Tokens = RoleUser, userMsg, c.tokenizer.Count(userMsg)
+			messages = append(messages, msg)
+		}
+		if assistantMsg, ok := mem.Metadata["assistant_msg"].(string); ok && assistantMsg != "" {
+			msg := base
+			msg.Role, msg.Content, msg.Tokens = RoleAssistant, assistantMsg, c.tokenizer.Count(assistantMsg)
+			messages = append(messages, msg)
+		}
+		return messages
+	}
+
+	msg := base
+	msg.Role = MessageRole(role)
+	msg.Content = mem.Content
+	if name, ok := mem.Metadata["name"].(string); ok {
+		msg.Name = name
+	}
+	if toolCallID, ok := mem.Metadata["tool_call_id"].(string); ok {
+		msg.ToolCallID = toolCallID
+	}
+	if raw, ok := mem.Metadata["tool_calls"]; ok {
+		// 元数据经过JSON往返，重新编码后解析
+		if data, err := json.Marshal(raw); err == nil {
+			_ = json.Unmarshal(data, &msg.ToolCalls)
+		}
+		if msg.Content == renderToolCalls(msg.ToolCalls) {
+			msg.Content = ""
+		}
+	}
+	switch tokens := mem.Metadata["tokens"].(type) {
+	case float64:
+		msg.Tokens = int(tokens)
+	case int:
+		msg.Tokens = tokens
+	default:
+		msg.Tokens = c.tokenizer.Count(mem.Content)
+	}
+	return []Message{msg}
+}
+
+// ContextWindow 适应token预算的会话上下文
+type ContextWindow struct {
+	Summary       string    // 较早消息的滚动摘要
+	SummaryTokens int       // 摘要的token数
+	Summarized    int       // 摘要覆盖的消息数
+	Messages      []Message // 原样保留的最近消息（按时间顺序）
+	Tokens        int       // 摘要和消息的总token数
+	Omitted       int       // 既未保留也未被摘要覆盖的消息数（未设置摘要生成器时）
+}
+
+// GetContextWindow 获取适应token预算的会话上下文
+// 最近的消息原样保留；放不下的较早消息由滚动摘要代替，摘要持久化并随新消息增量刷新
+// 预算的1/4留给摘要，最新一条消息总是保留（即使超出预算）
+func (c *ConversationMemory) GetContextWindow(sessionID string, tokenBudget int) (*ContextWindow, error) {
+	messages, err := c.GetMessages(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	window := &ContextWindow{}
+	if len(messages) == 0 {
+		return window, nil
+	}
+
+	// 1. 预算足够时原样返回全部消息
+	if tokenBudget <= 0 || recentStart(messages, tokenBudget) == 0 {
+		window.Messages = messages
+		window.Tokens = sumTokens(messages)
+		return window, nil
+	}
+
+	// 2. 预留摘要预算，剩余预算从最新消息向前填充
+	reserve := tokenBudget / 4
+	start := recentStart(messages, tokenBudget-reserve)
+	if c.summarizer == nil {
+		window.Messages = messages[start:]
+		window.Tokens = sumTokens(window.Messages)
+		window.Omitted = start
+		return window, nil
+	}
+
+	// 3. 刷新滚动摘要，使其覆盖到最近消息之前
+	summary, err := c.refreshSummary(sessionID, messages, start, reserve)
+	if err != nil {
+		return nil, err
+	}
+	if summary.Covered > start {
+		// 摘要已覆盖的消息不再重复放入上下文
+		start = summary.Covered
+	}
+
+	window.Summary = rag.NewContextBuilder(rag.ContextBuilderOptions{Tokenizer: c.tokenizer}).
+		TruncateContext(summary.Summary, reserve)
+	window.SummaryTokens = c.tokenizer.Count(window.Summary)
+	window.Summarized = summary.Covered
+	window.Messages = messages[start:]
+	window.Tokens = window.SummaryTokens + sumTokens(window.Messages)
+	return window, nil
+}
+
+// refreshSummary 增量刷新会话摘要，使其至少覆盖messages[:through]
+// 已有摘要覆盖到的消息不再重新摘要；摘要覆盖的最后一条消息已不存在时从头生成
+func (c *ConversationMemory) refreshSummary(sessionID string, messages []Message, through, maxTokens int) (*store.ConversationSummary, error) {
+	ns, err := c.manager.within(Namespace{Session: sessionID})
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := c.manager.store.GetConversationSummary(ns.toStore())
+	if err != nil {
+		return nil, err
+	}
+
+	previous, covered := "", 0
+	if existing != nil {
+		// 对话轮次拆出的两条消息共享ID，取最后一个位置
+		for i := len(messages) - 1; i >= 0; i-- {
+			if messages[i].ID == existing.ThroughID {
+				previous, covered = existing.Summary, i+1
+				break
+			}
+		}
+		if covered >= through {
+			existing.Covered = covered
+			return existing, nil
+		}
+	}
+
+	text, err := c.summarizer.Summarize(previous, messages[covered:through], maxTokens)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+
+	summary := store.ConversationSummary{
+		Namespace: ns.toStore(),
+		Summary:   text,
+		ThroughID: messages[through-1].ID,
+		Covered:   through,
+		Tokens:    c.tokenizer.Count(text),
+	}
+	if err := c.manager.store.SaveConversationSummary(summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// recentStart 从最新消息向前填充预算，返回保留的第一条消息的位置（至少保留最新一条）
+func recentStart(messages []Message, budget int) int {
+	start := len(messages) - 1
+	used := messages[start].Tokens
+	for start > 0 && used+messages[start-1].Tokens <= budget {
+		start--
+		used += messages[start].Tokens
+	}
+	return start
+}
+
+func sumTokens(messages []Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += msg.Tokens
+	}
+	return total
+}
@@ -13,6 +13,25 @@ type Preference struct {
 	Value    interface{}
 	Source   string // 偏好来源（如 "user", "inferred"）
 	Timestamp time.Time
+
+	Confidence  float64  // 置信度 0.0-1.0，0表示未指定（按用户明确给出处理，即1.0）
+	SourceTurns []string // 来源轮次ID
+}
+
+// confidence 偏好的有效置信度
+func (pref Preference) confidence() float64 {
+	if pref.Confidence <= 0 || pref.Confidence > 1 {
+		return 1
+	}
+	return pref.Confidence
+}
+
+// storedConfidence 已存偏好的置信度，旧版本写入的偏好没有记录时按1.0处理
+func storedConfidence(mem Memory) float64 {
+	if c, ok := mem.Metadata["confidence"].(float64); ok && c > 0 {
+		return c
+	}
+	return 1
 }
 
 // PreferenceMemory 偏好记忆管理
@@ -44,6 +63,10 @@ func (p *PreferenceMemory) RecordPreference(pref Preference) error {
 	if pref.Source != "" {
 		metadata["source"] = pref.Source
 	}
+	metadata["confidence"] = pref.confidence()
+	if len(pref.SourceTurns) > 0 {
+		metadata["source_turns"] = pref.SourceTurns
+	}
 
 	mem := Memory{
 		Type:       MemoryTypePreference,
@@ -89,14 +112,23 @@ func (p *PreferenceMemory) GetPreference(category, key string) (interface{}, err
 
 // getPreferenceExact 精确查找偏好
 func (p *PreferenceMemory) getPreferenceExact(category, key string) (interface{}, error) {
+	mem, err := p.findPreference(category, key)
+	if err != nil {
+		return nil, err
+	}
+	return mem.Metadata["value"], nil
+}
+
+// findPreference 精确查找偏好对应的记忆
+func (p *PreferenceMemory) findPreference(category, key string) (*Memory, error) {
 	memories, err := p.manager.GetByType(MemoryTypePreference)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, mem := range memories {
-		if mem.Metadata["category"] == category && mem.Metadata["key"] == key {
-			return mem.Metadata["value"], nil
+	for i := range memories {
+		if memories[i].Metadata["category"] == category && memories[i].Metadata["key"] == key {
+			return &memories[i], nil
 		}
 	}
 
@@ -155,35 +187,48 @@ func (p *PreferenceMemory) GetPreferencesByCategory(category string) (map[string
 	return prefs, nil
 }
 
-// UpdatePreference 更新偏好
-func (p *PreferenceMemory) UpdatePreference(category, key string, newValue interface{}) error {
-	// 查找现有偏好
-	memories, err := p.manager.GetByType(MemoryTypePreference)
+// UpdatePreference 更新偏好，返回是否写入
+// 新值的置信度不低于已有偏好时才替换（未指定置信度按1.0处理，用户明确给出的偏好总会替换）；
+// 值相同时合并来源轮次并保留较高的置信度；不存在时创建新偏好
+func (p *PreferenceMemory) UpdatePreference(pref Preference) (bool, error) {
+	mem, err := p.findPreference(pref.Category, pref.Key)
 	if err != nil {
-		return err
+		if pref.Source == "" {
+			pref.Source = "updated"
+		}
+		if pref.Timestamp.IsZero() {
+			pref.Timestamp = time.Now()
+		}
+		return true, p.RecordPreference(pref)
 	}
 
-	for _, mem := range memories {
-		if mem.Metadata["category"] == category && mem.Metadata["key"] == key {
-			// 更新值
-			mem.Metadata["value"] = newValue
-
-			// 重新生成content
-			valueJSON, _ := json.Marshal(newValue)
-			mem.Content = fmt.Sprintf("用户偏好 %s: %s = %s", category, key, string(valueJSON))
-
-			return p.manager.Update(mem.ID, mem)
+	stored := storedConfidence(*mem)
+	confidence := pref.confidence()
+	if valuesEqual(mem.Metadata["value"], pref.Value) {
+		// 同一值再次出现：只合并来源
+		if confidence < stored {
+			confidence = stored
 		}
+		pref.SourceTurns = mergeTurnIDs(metadataStrings(mem.Metadata["source_turns"]), pref.SourceTurns)
+	} else if confidence < stored {
+		return false, nil // 置信度更低的新值不覆盖已有偏好
 	}
 
-	// 如果不存在，创建新偏好
-	return p.RecordPreference(Preference{
-		Category:  category,
-		Key:       key,
-		Value:     newValue,
-		Source:    "updated",
-		Timestamp: time.Now(),
-	})
+	// 更新值和来源，重新生成content
+	mem.Metadata["value"] = pref.Value
+	mem.Metadata["confidence"] = confidence
+	if pref.Source != "" {
+		mem.Metadata["source"] = pref.Source
+	}
+	if len(pref.SourceTurns) > 0 {
+		mem.Metadata["source_turns"] = pref.SourceTurns
+	} else {
+		delete(mem.Metadata, "source_turns")
+	}
+	valueJSON, _ := json.Marshal(pref.Value)
+	mem.Content = fmt.Sprintf("用户偏好 %s: %s = %s", pref.Category, pref.Key, string(valueJSON))
+
+	return true, p.manager.Update(mem.ID, *mem)
 }
 
 // DeletePreference 删除偏好
@@ -254,6 +299,10 @@ func (p *PreferenceMemory) SearchPreferences(query string, limit int) ([]Prefere
 		if source, ok := mem.Metadata["source"].(string); ok {
 			pref.Source = source
 		}
+		if confidence, ok := mem.Metadata["confidence"].(float64); ok {
+			pref.Confidence = confidence
+		}
+		pref.SourceTurns = metadataStrings(mem.Metadata["source_turns"])
 
 		prefs = append(prefs, pref)
 	}
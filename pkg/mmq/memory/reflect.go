@@ -0,0 +1,360 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/llm"
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+// reflectionPrompt 从近期记忆归纳高层洞察的提示
+const reflectionPrompt = `Below are recent memories of an assistant about its user, numbered and oldest first.
+What are the %d most important high-level insights you can infer from them? Good insights generalize over several memories: lasting traits, goals, relationships, habits or recurring problems. Do not restate a single memory and do not speculate beyond the evidence.
+For each insight, list the numbers of the memories it is based on. Keep the language of the memories.
+Output only JSON matching this schema:
+{"insights":[{"content":"...","sources":[1,3]}]}
+
+Memories:
+%s
+
+JSON:`
+
+// 反思记忆的metadata键
+const (
+	MetadataReflectionSources = "source_ids"        // 来源记忆ID列表
+	MetadataReflectedThrough  = "reflected_through" // 本次反思参考的最新记忆时间（RFC3339）
+)
+
+const (
+	// maxReflectionMemoryRunes 提示中每条记忆的最大长度
+	maxReflectionMemoryRunes = 500
+	// maxInsightRunes 洞察的最大长度（超过时视为无效）
+	maxInsightRunes = 1000
+)
+
+// ReflectorOptions 反思选项
+type ReflectorOptions struct {
+	MemoryTypes     []MemoryType                            // 参考的记忆类型，为空时为对话、事实、偏好和情景记忆
+	Window          time.Duration                           // 只参考最近这段时间的记忆，0表示不限
+	MaxMemories     int                                     // 每次最多参考的记忆数（取最新的）
+	MinMemories     int                                     // 新记忆少于该数时跳过，不调用LLM
+	MaxInsights     int                                     // 每次最多生成的洞察数
+	MinImportance   float64                                 // 洞察重要性的下限
+	ImportanceBoost float64                                 // 洞察重要性在来源记忆最高重要性上增加的值
+	MaxTokens       int                                     // 生成的最大token数
+	DryRun          bool                                    // 只生成不写入
+	Interval        time.Duration                           // 后台反思间隔
+	OnRun           func(reflection *Reflection, err error) // 后台每次反思后回调（可选）
+}
+
+// DefaultReflectorOptions 默认反思选项
+func DefaultReflectorOptions() ReflectorOptions {
+	return ReflectorOptions{
+		MaxMemories:     50,
+		MinMemories:     5,
+		MaxInsights:     5,
+		MinImportance:   0.7,
+		ImportanceBoost: 0.2,
+		MaxTokens:       1024,
+		Interval:        6 * time.Hour,
+	}
+}
+
+// Insight 由多条记忆归纳出的高层洞察
+type Insight struct {
+	Content    string
+	Sources    []string // 来源记忆ID
+	Importance float64
+}
+
+// Reflection 一次反思的结果
+type Reflection struct {
+	Considered int       // 参考的记忆数
+	Insights   []Insight // 写入（DryRun时为将写入）的洞察
+	Rejected   []string  // 校验未通过的洞察及原因
+	Skipped    bool      // 新记忆不足MinMemories，未调用LLM
+}
+
+// Reflector 反思器：定期收集命名空间内的近期记忆，由LLM归纳为高层洞察并写入反思记忆
+// 每次只参考上一次反思之后写入的记忆，长期运行的助手因此积累持久的摘要而不是大量原始对话；
+// 反思进度按命名空间单独保存（与洞察是否写入、是否被去重合并无关）
+type Reflector struct {
+	manager *Manager
+	model   llm.LLM
+	opts    ReflectorOptions
+
+	runMu    sync.Mutex // 串行执行Reflect
+	schedule periodic
+}
+
+// NewReflector 创建反思器，作用范围为管理器的命名空间
+func NewReflector(manager *Manager, model llm.LLM, opts ReflectorOptions) *Reflector {
+	defaults := DefaultReflectorOptions()
+	if opts.MaxMemories <= 0 {
+		opts.MaxMemories = defaults.MaxMemories
+	}
+	if opts.MaxInsights <= 0 {
+		opts.MaxInsights = defaults.MaxInsights
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaults.Interval
+	}
+	return &Reflector{manager: manager, model: model, opts: opts}
+}
+
+type reflectedInsight struct {
+	Content string `json:"content"`
+	Sources []int  `json:"sources"`
+}
+
+type reflectedOutput struct {
+	Insights []reflectedInsight `json:"insights"`
+}
+
+// Reflect 执行一次反思：收集上次反思之后的近期记忆，生成洞察并写入
+func (r *Reflector) Reflect() (*Reflection, error) {
+	r.runMu.Lock()
+	defer r.runMu.Unlock()
+
+	ns, err := r.manager.within(Namespace{})
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := r.manager.store.GetReflectionCursor(ns.toStore())
+	if err != nil {
+		return nil, err
+	}
+	memories, err := r.gather(cursor)
+	if err != nil {
+		return nil, err
+	}
+	next := advanceCursor(ns, cursor, memories)
+
+	reflection := &Reflection{Considered: len(memories)}
+	if len(memories) == 0 || len(memories) < r.opts.MinMemories {
+		reflection.Skipped = true
+		return reflection, nil
+	}
+
+	var b strings.Builder
+	for i, mem := range memories {
+		content := []rune(normalizeField(mem.Content))
+		if len(content) > maxReflectionMemoryRunes {
+			content = append(content[:maxReflectionMemoryRunes], '…')
+		}
+		fmt.Fprintf(&b, "[%d] (%s, %s) %s\n", i+1, mem.Type, mem.Timestamp.Format("2006-01-02"), string(content))
+	}
+
+	opts := llm.DefaultGenerateOptions()
+	opts.Temperature = 0
+	if r.opts.MaxTokens > 0 {
+		opts.MaxTokens = r.opts.MaxTokens
+	}
+	text, err := r.model.Generate(fmt.Sprintf(reflectionPrompt, r.opts.MaxInsights, strings.TrimRight(b.String(), "\n")), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate reflection: %w", err)
+	}
+
+	output, err := parseReflection(text)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, item := range output.Insights {
+		content := normalizeField(item.Content)
+		switch {
+		case content == "":
+			reflection.Rejected = append(reflection.Rejected, "insight without content")
+			continue
+		case len([]rune(content)) > maxInsightRunes:
+			reflection.Rejected = append(reflection.Rejected, fmt.Sprintf("insight %q: too long", truncateRunes(content, 40)))
+			continue
+		case seen[strings.ToLower(content)]:
+			continue
+		}
+
+		insight := Insight{Content: content}
+		maxImportance := 0.0
+		for _, n := range item.Sources {
+			if n < 1 || n > len(memories) {
+				continue // 忽略不存在的记忆编号
+			}
+			source := memories[n-1]
+			if containsString(insight.Sources, source.ID) {
+				continue
+			}
+			insight.Sources = append(insight.Sources, source.ID)
+			maxImportance = math.Max(maxImportance, source.Importance)
+		}
+		if len(insight.Sources) == 0 {
+			reflection.Rejected = append(reflection.Rejected, fmt.Sprintf("insight %q: no valid sources", truncateRunes(content, 40)))
+			continue
+		}
+		if len(reflection.Insights) == r.opts.MaxInsights {
+			reflection.Rejected = append(reflection.Rejected, fmt.Sprintf("insight %q: over %d insights", truncateRunes(content, 40), r.opts.MaxInsights))
+			continue
+		}
+
+		// 洞察比来源记忆更重要
+		insight.Importance = math.Min(math.Max(r.opts.MinImportance, maxImportance+r.opts.ImportanceBoost), 1)
+		seen[strings.ToLower(content)] = true
+		reflection.Insights = append(reflection.Insights, insight)
+	}
+
+	if r.opts.DryRun {
+		return reflection, nil
+	}
+
+	// 先写入洞察再推进进度：一条都没写入时下次重新参考这些记忆；
+	// 写入部分洞察后失败时推进进度，避免重试时重复写入已保存的洞察
+	now := time.Now()
+	for i, insight := range reflection.Insights {
+		err := r.manager.Store(Memory{
+			Type:    MemoryTypeReflection,
+			Content: insight.Content,
+			Metadata: map[string]interface{}{
+				MetadataReflectionSources: insight.Sources,
+				MetadataReflectedThrough:  next.Through.Format(time.RFC3339),
+			},
+			Timestamp:  now,
+			Importance: insight.Importance,
+		})
+		if err != nil {
+			reflection.Insights = reflection.Insights[:i]
+			if i > 0 {
+				if cursorErr := r.manager.store.SaveReflectionCursor(next); cursorErr != nil {
+					return reflection, fmt.Errorf("failed to store reflection: %w (reflection cursor not saved: %v)", err, cursorErr)
+				}
+			}
+			return reflection, fmt.Errorf("failed to store reflection: %w", err)
+		}
+	}
+
+	// 没有有效洞察时同样推进，避免反复参考同一批记忆
+	if err := r.manager.store.SaveReflectionCursor(next); err != nil {
+		return reflection, err
+	}
+	return reflection, nil
+}
+
+// advanceCursor 参考memories之后的反思进度
+func advanceCursor(ns Namespace, cursor *store.ReflectionCursor, memories []Memory) store.ReflectionCursor {
+	next := store.ReflectionCursor{Namespace: ns.toStore()}
+	if cursor != nil {
+		next.Through = cursor.Through
+		next.ThroughIDs = append(next.ThroughIDs, cursor.ThroughIDs...)
+	}
+	for _, mem := range memories {
+		switch {
+		case mem.Timestamp.After(next.Through):
+			next.Through, next.ThroughIDs = mem.Timestamp, []string{mem.ID}
+		case mem.Timestamp.Equal(next.Through):
+			next.ThroughIDs = mergeTurnIDs(next.ThroughIDs, []string{mem.ID})
+		}
+	}
+	return next
+}
+
+// gather 收集反思进度之后（且在Window内）写入的记忆，按时间顺序返回
+// 超过MaxMemories时取最早的，其余留给之后的反思
+func (r *Reflector) gather(cursor *store.ReflectionCursor) ([]Memory, error) {
+	var since time.Time
+	if r.opts.Window > 0 {
+		since = time.Now().Add(-r.opts.Window)
+	}
+
+	// 时间精度为秒：从进度所在的秒开始（含），跳过该秒内已参考的记忆
+	seen := make(map[string]bool)
+	if cursor != nil && !cursor.Through.Before(since) {
+		since = cursor.Through
+		for _, id := range cursor.ThroughIDs {
+			seen[id] = true
+		}
+	}
+
+	types := r.opts.MemoryTypes
+	if len(types) == 0 {
+		types = []MemoryType{MemoryTypeConversation, MemoryTypeFact, MemoryTypePreference, MemoryTypeEpisodic}
+	}
+	page, err := r.manager.List(ListOptions{
+		MemoryTypes: types,
+		Filter:      Filter{Since: since, Expiry: ExpiryActive},
+		Limit:       r.opts.MaxMemories + len(seen),
+		OldestFirst: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather memories: %w", err)
+	}
+
+	var memories []Memory
+	for _, mem := range page.Memories {
+		if seen[mem.ID] {
+			continue
+		}
+		if len(memories) == r.opts.MaxMemories {
+			break
+		}
+		memories = append(memories, mem)
+	}
+	return memories, nil
+}
+
+// Start 在后台按间隔定期反思，重复调用无效
+func (r *Reflector) Start() {
+	r.schedule.start(r.opts.Interval, func() {
+		reflection, err := r.Reflect()
+		if r.opts.OnRun != nil {
+			r.opts.OnRun(reflection, err)
+		}
+	})
+}
+
+// Stop 停止后台反思并等待正在进行的反思结束
+func (r *Reflector) Stop() {
+	r.schedule.halt()
+}
+
+// ReflectionSources 反思记忆的来源记忆ID
+func ReflectionSources(mem Memory) []string {
+	var ids []string
+	switch sources := mem.Metadata[MetadataReflectionSources].(type) {
+	case []string:
+		ids = append(ids, sources...)
+	case []interface{}:
+		for _, source := range sources {
+			if id, ok := source.(string); ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// parseReflection 解析LLM输出的JSON（容忍代码块和前后的说明文字）
+func parseReflection(text string) (*reflectedOutput, error) {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("failed to parse reflection: no JSON object in output")
+	}
+
+	var output reflectedOutput
+	if err := json.Unmarshal([]byte(text[start:end+1]), &output); err != nil {
+		return nil, fmt.Errorf("failed to parse reflection: %w", err)
+	}
+	return &output, nil
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
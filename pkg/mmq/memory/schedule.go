@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"sync"
+	"time"
+)
+
+// periodic 在后台按固定间隔执行任务（清理器和反思器共用）
+type periodic struct {
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// start 启动后台任务，已在运行时无效
+func (p *periodic) start(interval time.Duration, run func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil {
+		return
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	p.stop, p.done = stop, done
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				run()
+			}
+		}
+	}()
+}
+
+// halt 停止后台任务并等待正在执行的任务结束
+func (p *periodic) halt() {
+	p.mu.Lock()
+	stop, done := p.stop, p.done
+	p.stop, p.done = nil, nil
+	p.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"math"
+	"time"
+)
+
+// StrengthModel 记忆强度模型（间隔重复）
+// 强度随距上次强化（写入或召回）的时间指数衰减：strength = exp(-elapsed / stability)
+// 稳定性 stability = Halflife × (0.5 + Importance) × Growth^min(AccessCount, MaxReinforcements)，
+// 即越重要、被召回越多的记忆遗忘得越慢
+type StrengthModel struct {
+	Halflife          time.Duration // 未被召回、重要性为0.5时的衰减时间常数
+	Growth            float64       // 每次召回对稳定性的放大倍数，<=1时召回不强化
+	MaxReinforcements int           // 参与强化的召回次数上限
+}
+
+// DefaultStrengthModel 默认强度模型（与默认回忆选项一样为30天半衰期）
+func DefaultStrengthModel() StrengthModel {
+	return StrengthModel{
+		Halflife:          30 * 24 * time.Hour,
+		Growth:            1.5,
+		MaxReinforcements: 10,
+	}
+}
+
+// Stability 记忆的稳定性（不含重要性），召回次数越多越稳定
+func (s StrengthModel) Stability(mem Memory) time.Duration {
+	n := mem.AccessCount
+	if n > s.MaxReinforcements {
+		n = s.MaxReinforcements
+	}
+	stability := float64(s.Halflife)
+	if s.Growth > 1 && n > 0 {
+		stability *= math.Pow(s.Growth, float64(n))
+	}
+	return time.Duration(stability)
+}
+
+// Retention 按时间和召回计算的保持率（0-1，不含重要性），用于回忆时的时间衰减
+func (s StrengthModel) Retention(mem Memory, now time.Time) float64 {
+	return decay(now.Sub(mem.LastReinforced()), s.Stability(mem))
+}
+
+// Strength 综合重要性、时间和召回的记忆强度（0-1）
+func (s StrengthModel) Strength(mem Memory, now time.Time) float64 {
+	stability := float64(s.Stability(mem)) * (0.5 + mem.Importance)
+	return decay(now.Sub(mem.LastReinforced()), time.Duration(stability))
+}
+
+// LastReinforced 最近一次强化的时间（写入时间和最近召回时间中较晚的一个）
+func (mem Memory) LastReinforced() time.Time {
+	if mem.LastAccessed != nil && mem.LastAccessed.After(mem.Timestamp) {
+		return *mem.LastAccessed
+	}
+	return mem.Timestamp
+}
+
+func decay(elapsed, stability time.Duration) float64 {
+	if elapsed <= 0 {
+		return 1
+	}
+	if stability <= 0 {
+		return 0
+	}
+	return math.Exp(-elapsed.Hours() / stability.Hours())
+}
@@ -0,0 +1,279 @@
+package mmq
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/memory"
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+// recordingSummarizer 记录每次调用的输入，摘要为覆盖的消息内容列表
+type recordingSummarizer struct {
+	calls []summarizeCall
+}
+
+type summarizeCall struct {
+	previous string
+	messages []memory.Message
+}
+
+func (s *recordingSummarizer) Summarize(previous string, messages []memory.Message, maxTokens int) (string, error) {
+	s.calls = append(s.calls, summarizeCall{previous: previous, messages: messages})
+
+	parts := []string{}
+	if previous != "" {
+		parts = append(parts, previous)
+	}
+	for _, msg := range messages {
+		parts = append(parts, msg.Content)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// appendTestMessages 追加n条交替的用户/助手消息，每条固定10个token
+func appendTestMessages(t *testing.T, conv *memory.ConversationMemory, session string, from, n int, base time.Time) {
+	t.Helper()
+	for i := from; i < from+n; i++ {
+		role := memory.RoleUser
+		if i%2 == 1 {
+			role = memory.RoleAssistant
+		}
+		err := conv.AppendMessage(memory.Message{
+			SessionID: session,
+			Role:      role,
+			Content:   fmt.Sprintf("m%d", i),
+			Tokens:    10,
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConversationMessages(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			m, err := backend.open(t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			conv := memory.NewConversationMemory(m.GetMemoryManager())
+			base := time.Now().Add(-time.Hour)
+
+			// 旧接口写入的对话轮次与消息混合
+			err = conv.StoreTurn(memory.ConversationTurn{
+				User: "hello", Assistant: "hi there", SessionID: "chat", Timestamp: base,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			messages := []memory.Message{
+				{Role: memory.RoleSystem, Content: "You are a travel agent."},
+				{Role: memory.RoleUser, Content: "Weather in Paris?"},
+				{Role: memory.RoleAssistant, ToolCalls: []memory.ToolCall{
+					{ID: "call-1", Name: "weather", Arguments: `{"city":"Paris"}`},
+				}},
+				{Role: memory.RoleTool, Name: "weather", ToolCallID: "call-1", Content: "18C, sunny"},
+				{Role: memory.RoleAssistant, Content: "It is 18C and sunny in Paris.", Tokens: 42},
+			}
+			for i, msg := range messages {
+				msg.SessionID = "chat"
+				msg.Timestamp = base.Add(time.Duration(i+1) * time.Minute)
+				if err := conv.AppendMessage(msg); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := conv.AppendMessage(memory.Message{SessionID: "chat", Role: memory.RoleAssistant}); err == nil {
+				t.Error("Expected error for message without content or tool calls")
+			}
+			if err := conv.AppendMessage(memory.Message{SessionID: "chat", Role: "robot", Content: "x"}); err == nil {
+				t.Error("Expected error for unknown role")
+			}
+
+			got, err := conv.GetMessages("chat")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 7 {
+				t.Fatalf("Expected 7 messages (2 from the turn + 5), got %d", len(got))
+			}
+			t.Logf("Messages: %d", len(got))
+
+			wantRoles := []memory.MessageRole{memory.RoleUser, memory.RoleAssistant, memory.RoleSystem,
+				memory.RoleUser, memory.RoleAssistant, memory.RoleTool, memory.RoleAssistant}
+			for i, msg := range got {
+				if msg.Index != i {
+					t.Errorf("Message %d has index %d", i, msg.Index)
+				}
+				if msg.Role != wantRoles[i] {
+					t.Errorf("Message %d: expected role %s, got %s", i, wantRoles[i], msg.Role)
+				}
+				if msg.Tokens <= 0 {
+					t.Errorf("Message %d has no token count", i)
+				}
+			}
+
+			call := got[4]
+			if call.Content != "" || len(call.ToolCalls) != 1 || call.ToolCalls[0].Name != "weather" ||
+				call.ToolCalls[0].Arguments != `{"city":"Paris"}` {
+				t.Errorf("Tool call not round-tripped: %+v", call)
+			}
+			if got[5].ToolCallID != "call-1" || got[5].Name != "weather" {
+				t.Errorf("Tool result not round-tripped: %+v", got[5])
+			}
+			if got[6].Tokens != 42 {
+				t.Errorf("Expected explicit token count 42, got %d", got[6].Tokens)
+			}
+
+			// 旧接口读取时按角色映射到轮次
+			turns, err := conv.GetHistory("chat", 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(turns) != 1 || turns[0].Assistant != "It is 18C and sunny in Paris." {
+				t.Errorf("Expected latest assistant message in history, got %+v", turns)
+			}
+		})
+	}
+}
+
+func TestConversationContextWindow(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			m, err := backend.open(t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			manager := m.GetMemoryManagerIn(alice)
+			conv := memory.NewConversationMemory(manager)
+			base := time.Now().Add(-time.Hour)
+			appendTestMessages(t, conv, "chat", 0, 10, base)
+
+			// 预算足够时不生成摘要
+			window, err := conv.GetContextWindow("chat", 1000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(window.Messages) != 10 || window.Summary != "" || window.Tokens != 100 {
+				t.Errorf("Expected all 10 messages without summary, got %d messages, summary %q", len(window.Messages), window.Summary)
+			}
+
+			// 未设置摘要生成器时只保留最近消息
+			window, err = conv.GetContextWindow("chat", 40)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(window.Messages) != 3 || window.Omitted != 7 {
+				t.Errorf("Expected 3 recent messages and 7 omitted, got %d and %d", len(window.Messages), window.Omitted)
+			}
+
+			// 预算40：10留给摘要，最近3条原样保留，较早的7条生成摘要
+			summarizer := &recordingSummarizer{}
+			conv.SetSummarizer(summarizer)
+			window, err = conv.GetContextWindow("chat", 40)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(window.Messages) != 3 || window.Messages[0].Content != "m7" {
+				t.Fatalf("Expected messages m7..m9, got %+v", window.Messages)
+			}
+			if window.Summary != "m0,m1,m2,m3,m4,m5,m6" || window.Summarized != 7 || window.Omitted != 0 {
+				t.Errorf("Unexpected summary %q covering %d", window.Summary, window.Summarized)
+			}
+			if window.Tokens != window.SummaryTokens+30 {
+				t.Errorf("Expected tokens to include summary and messages, got %d", window.Tokens)
+			}
+			t.Logf("Summary: %q (%d tokens), total %d tokens", window.Summary, window.SummaryTokens, window.Tokens)
+
+			// 摘要已持久化：新的实例不再重新摘要
+			again := memory.NewConversationMemory(manager)
+			again.SetSummarizer(summarizer)
+			if _, err := again.GetContextWindow("chat", 40); err != nil {
+				t.Fatal(err)
+			}
+			if len(summarizer.calls) != 1 {
+				t.Fatalf("Expected persisted summary to be reused, got %d calls", len(summarizer.calls))
+			}
+
+			saved, err := m.store.GetConversationSummary(store.Namespace{Tenant: alice.Tenant, User: alice.User, Session: "chat"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved == nil || saved.Covered != 7 || saved.ThroughID == "" {
+				t.Errorf("Expected saved summary covering 7 messages, got %+v", saved)
+			}
+
+			// 新消息只增量摘要滑出窗口的部分
+			appendTestMessages(t, conv, "chat", 10, 2, base)
+			window, err = again.GetContextWindow("chat", 40)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(summarizer.calls) != 2 {
+				t.Fatalf("Expected one incremental refresh, got %d calls", len(summarizer.calls))
+			}
+			last := summarizer.calls[1]
+			if last.previous != "m0,m1,m2,m3,m4,m5,m6" || len(last.messages) != 2 ||
+				last.messages[0].Content != "m7" || last.messages[1].Content != "m8" {
+				t.Errorf("Expected refresh with m7,m8 on top of previous summary, got %q + %d messages", last.previous, len(last.messages))
+			}
+			if window.Summarized != 9 || len(window.Messages) != 3 || window.Messages[0].Content != "m9" {
+				t.Errorf("Expected 9 summarized and m9..m11 kept, got %d and %+v", window.Summarized, window.Messages)
+			}
+
+			// 其他用户看不到该会话的摘要
+			other, err := m.store.GetConversationSummary(store.Namespace{Tenant: bob.Tenant, User: bob.User, Session: "chat"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if other != nil {
+				t.Errorf("Expected no summary for another user, got %+v", other)
+			}
+
+			// 清除会话同时删除摘要
+			if _, err := conv.ClearSession("chat"); err != nil {
+				t.Fatal(err)
+			}
+			saved, err = m.store.GetConversationSummary(store.Namespace{Tenant: alice.Tenant, User: alice.User, Session: "chat"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved != nil {
+				t.Errorf("Expected summary to be deleted with the session, got %+v", saved)
+			}
+		})
+	}
+}
+
+func TestConversationContextWindowLLM(t *testing.T) {
+	m, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	conv := m.GetConversationMemoryIn(Namespace{User: "alice"})
+	appendTestMessages(t, conv, "chat", 0, 10, time.Now().Add(-time.Hour))
+
+	window, err := conv.GetContextWindow("chat", 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if window.Summary == "" || window.SummaryTokens > 10 {
+		t.Errorf("Expected LLM summary within the 10 token reserve, got %q (%d tokens)", window.Summary, window.SummaryTokens)
+	}
+	if window.Summarized != 7 || len(window.Messages) != 3 {
+		t.Errorf("Expected 7 summarized and 3 kept, got %d and %d", window.Summarized, len(window.Messages))
+	}
+	t.Logf("LLM summary: %q", window.Summary)
+}
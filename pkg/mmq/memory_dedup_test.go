@@ -0,0 +1,227 @@
+package mmq
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/memory"
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+func TestStoreDedupMergesDuplicates(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DBPath = filepath.Join(t.TempDir(), "test.db")
+	cfg.MemoryDedupThreshold = 0.95
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i, tag := range []string{"drinks", "morning", "drinks"} {
+		err := m.StoreMemory(Memory{
+			Namespace: alice,
+			Type:      MemoryTypePreference,
+			Content:   "Prefers green tea",
+			Tags:      []string{tag},
+			Metadata:  map[string]interface{}{"source": "chat", "turn": i},
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 其他命名空间、其他类型、对话和情景记忆不合并
+	others := []Memory{
+		{Namespace: bob, Type: MemoryTypePreference, Content: "Prefers green tea", Timestamp: base},
+		{Namespace: alice, Type: MemoryTypeFact, Content: "Prefers green tea", Timestamp: base},
+		{Namespace: alice, Type: MemoryTypeConversation, Content: "hi", Timestamp: base},
+		{Namespace: alice, Type: MemoryTypeConversation, Content: "hi", Timestamp: base},
+		{Namespace: alice, Type: MemoryTypeEpisodic, Content: "Daily standup", Timestamp: base},
+		{Namespace: alice, Type: MemoryTypeEpisodic, Content: "Daily standup", Timestamp: base.Add(24 * time.Hour)},
+	}
+	for _, mem := range others {
+		if err := m.StoreMemory(mem); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count, err := m.CountMemories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 7 {
+		t.Errorf("Expected 7 memories after dedup, got %d", count)
+	}
+
+	recalled, err := m.RecallMemories("Prefers green tea", RecallOptions{
+		Limit: 1, MemoryTypes: []MemoryType{MemoryTypePreference}, Scopes: []Namespace{alice},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recalled) != 1 {
+		t.Fatalf("Expected merged memory, got %+v", recalled)
+	}
+
+	merged := recalled[0]
+	t.Logf("Merged memory: importance=%.2f tags=%v metadata=%v", merged.Importance, merged.Tags, merged.Metadata)
+	if merged.Importance < 0.69 || merged.Importance > 0.71 {
+		t.Errorf("Expected importance boosted to 0.7, got %.2f", merged.Importance)
+	}
+	if !merged.Timestamp.Equal(base.Add(2 * time.Minute)) {
+		t.Errorf("Expected timestamp refreshed, got %v", merged.Timestamp)
+	}
+	if len(merged.Tags) != 2 || merged.Tags[0] != "drinks" || merged.Tags[1] != "morning" {
+		t.Errorf("Expected tags unioned, got %v", merged.Tags)
+	}
+	provenance, _ := merged.Metadata[memory.MetadataProvenance].([]interface{})
+	if len(provenance) != 2 || merged.Metadata[memory.MetadataMergeCount] != float64(2) {
+		t.Errorf("Expected 2 provenance entries, got %v", merged.Metadata)
+	}
+	if merged.Metadata["turn"] != float64(0) {
+		t.Errorf("Expected original metadata kept, got %v", merged.Metadata["turn"])
+	}
+}
+
+// storeMemoryVector 直接写入指定嵌入的记忆（模拟近似但不相同的向量）
+func storeMemoryVector(t *testing.T, st store.Backend, ns store.Namespace, memType, content string, ts time.Time, vec []float32) {
+	t.Helper()
+	err := st.InsertMemory(ns, memType, content, nil, []string{content}, ts, nil, 0.5, vec)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDedupeExistingMemories(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			m, err := backend.open(t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			st := m.GetBackend()
+			ns := store.Namespace{Tenant: "acme", User: "alice"}
+			base := time.Now().Add(-time.Hour)
+
+			storeMemoryVector(t, st, ns, "fact", "The office is in Berlin", base, []float32{1, 0, 0})
+			storeMemoryVector(t, st, ns, "fact", "Office located in Berlin", base.Add(time.Minute), []float32{0.99, 0.1, 0})
+			storeMemoryVector(t, st, ns, "fact", "Our office: Berlin", base.Add(2*time.Minute), []float32{0.98, 0.05, 0.1})
+			storeMemoryVector(t, st, ns, "fact", "Lunch is at noon", base, []float32{0, 1, 0})
+			storeMemoryVector(t, st, store.Namespace{Tenant: "acme", User: "bob"}, "fact", "Office in Berlin", base, []float32{1, 0, 0})
+
+			opts := DedupOptions{Threshold: 0.95, DryRun: true, Namespace: alice}
+			stats, err := m.DedupeMemories(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Scanned != 4 || stats.Merged != 2 || stats.Kept != 1 {
+				t.Errorf("Unexpected dry run stats: %+v", stats)
+			}
+			if count, _ := m.CountMemories(); count != 5 {
+				t.Errorf("Dry run should not modify memories, got %d", count)
+			}
+
+			opts.DryRun = false
+			if _, err := m.DedupeMemories(opts); err != nil {
+				t.Fatal(err)
+			}
+
+			count, err := m.CountMemoriesIn(alice)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 2 {
+				t.Errorf("Expected 2 memories for alice after dedupe, got %d", count)
+			}
+			if count, _ := m.CountMemoriesIn(bob); count != 1 {
+				t.Errorf("Expected bob's memory untouched, got %d", count)
+			}
+
+			results, err := st.GetMemoriesByType("fact", []store.Namespace{ns})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var kept *store.MemoryResult
+			for i := range results {
+				if results[i].Content == "The office is in Berlin" {
+					kept = &results[i]
+				}
+			}
+			if kept == nil {
+				t.Fatalf("Expected oldest memory kept, got %+v", results)
+			}
+			if len(kept.Tags) != 3 || kept.Metadata[memory.MetadataMergeCount] != float64(2) {
+				t.Errorf("Expected merged tags and provenance, got tags=%v metadata=%v", kept.Tags, kept.Metadata)
+			}
+			if !kept.Timestamp.Equal(base.Add(2 * time.Minute).Truncate(time.Second)) {
+				t.Errorf("Expected timestamp of newest duplicate, got %v", kept.Timestamp)
+			}
+		})
+	}
+}
+
+func TestStoreDedupNamespaceAndPreferenceValue(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			m, err := backend.open(t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+			m.memoryManager.SetDedup(memory.DedupOptions{Threshold: 0.95})
+
+			// alice的各个会话中有更多相同内容的记忆，去重仍能找到alice自身命名空间的记忆
+			for i := 0; i < 6; i++ {
+				session := alice
+				session.Session = fmt.Sprintf("s%d", i)
+				if err := m.StoreMemory(Memory{Namespace: session, Type: MemoryTypeFact, Content: "The office is in Berlin"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i := 0; i < 2; i++ {
+				if err := m.StoreMemory(Memory{Namespace: alice, Type: MemoryTypeFact, Content: "The office is in Berlin"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if count, _ := m.CountMemoriesIn(alice); count != 7 {
+				t.Errorf("Expected the repeated alice memory merged, got %d memories", count)
+			}
+
+			// 取值不同的偏好不合并
+			for _, value := range []string{"like", "dislike", "like"} {
+				err := m.StoreMemory(Memory{
+					Namespace: bob,
+					Type:      MemoryTypePreference,
+					Content:   "Spicy food",
+					Metadata:  map[string]interface{}{"category": "food", "key": "spicy", "value": value},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			prefs, err := m.GetBackend().GetMemoriesByType("preference", []store.Namespace{{Tenant: "acme", User: "bob"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(prefs) != 2 {
+				t.Errorf("Expected one memory per preference value, got %+v", prefs)
+			}
+
+			// 批量去重同样不合并取值不同的偏好
+			stats, err := m.DedupeMemories(DedupOptions{Threshold: 0.95, Namespace: bob})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Merged != 0 {
+				t.Errorf("Expected no merge across preference values, got %+v", stats)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package mmq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/memory"
+)
+
+// recordTestEvents 记录一天内的几组事件：上午的发布、下午的客户会议、以及显式指定情节的事件
+func recordTestEvents(t *testing.T, episodic *memory.EpisodicMemory, day time.Time) {
+	t.Helper()
+	at := func(hour, minute int) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+	}
+
+	events := []memory.Event{
+		{Description: "Deployed release 2.4 to production", Actors: []string{"alice"}, Context: "release",
+			StartTime: at(9, 0), EndTime: at(9, 20), Outcome: "success"},
+		{Description: "Error rate spiked after the deploy", Actors: []string{"alice", "bob"},
+			StartTime: at(9, 35), Outcome: "rolled back"},
+		{Description: "Met the customer to discuss billing", Actors: []string{"bob", "carol"}, Location: "Berlin office",
+			StartTime: at(14, 0), EndTime: at(15, 0), Outcome: "agreed on annual plan"},
+		{Description: "Sent the billing follow-up email", Actors: []string{"bob"},
+			StartTime: at(15, 10)},
+		{Description: "Started the migration plan", Actors: []string{"carol"}, Episode: "migration",
+			StartTime: at(11, 0)},
+		{Description: "Finished the migration plan", Actors: []string{"carol"}, Episode: "migration",
+			StartTime: at(17, 0)},
+	}
+	for _, event := range events {
+		if err := episodic.RecordEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEpisodicMemory(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			m, err := backend.open(t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			day := time.Now().AddDate(0, 0, -1)
+			at := func(hour, minute int) time.Time {
+				return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+			}
+			episodic := memory.NewEpisodicMemory(m.GetMemoryManager())
+			recordTestEvents(t, episodic, day)
+
+			timeline, err := episodic.Timeline(memory.TimelineOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(timeline) != 6 {
+				t.Fatalf("Expected 6 events, got %d", len(timeline))
+			}
+			for i := 1; i < len(timeline); i++ {
+				if timeline[i].StartTime.Before(timeline[i-1].StartTime) {
+					t.Errorf("Timeline out of order at %d", i)
+				}
+			}
+			first := timeline[0]
+			if first.Description != "Deployed release 2.4 to production" || first.Outcome != "success" ||
+				first.Context != "release" || !first.EndTime.Equal(at(9, 20)) || len(first.Actors) != 1 || len(first.Tags) != 0 {
+				t.Errorf("Event not restored: %+v", first)
+			}
+
+			before, err := episodic.Before(at(14, 0), 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(before) != 2 || before[0].Description != "Error rate spiked after the deploy" ||
+				before[1].Description != "Started the migration plan" {
+				t.Errorf("Unexpected events before 14:00: %+v", before)
+			}
+
+			after, err := episodic.After(at(14, 0), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(after) != 1 || after[0].Description != "Met the customer to discuss billing" {
+				t.Errorf("Unexpected events after 14:00: %+v", after)
+			}
+
+			between, err := episodic.Between(at(9, 0), at(12, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(between) != 3 {
+				t.Errorf("Expected 3 morning events, got %d", len(between))
+			}
+
+			bobs, err := episodic.Timeline(memory.TimelineOptions{Actor: "bob"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(bobs) != 3 {
+				t.Errorf("Expected 3 events with bob, got %d", len(bobs))
+			}
+
+			// 下午3点左右和账单有关的事
+			around, err := episodic.Around("billing", at(15, 0), memory.AroundOptions{Window: 2 * time.Hour, Limit: 3})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(around) == 0 || around[0].Description != "Met the customer to discuss billing" {
+				t.Errorf("Expected the billing meeting first, got %+v", around)
+			}
+			for _, event := range around {
+				t.Logf("around 15:00: %s (%.3f)", event.Description, event.Relevance)
+				if event.End().Before(at(13, 0)) || event.StartTime.After(at(17, 0)) {
+					t.Errorf("Event outside window: %s", event.Description)
+				}
+			}
+
+			nearby, err := episodic.Around("", at(9, 30), memory.AroundOptions{Window: time.Hour})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(nearby) != 2 || nearby[0].Description != "Error rate spiked after the deploy" {
+				t.Errorf("Expected events near 9:30 by proximity, got %+v", nearby)
+			}
+
+			episodes, err := episodic.Episodes(time.Time{}, time.Time{}, 30*time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(episodes) != 3 {
+				t.Fatalf("Expected 3 episodes, got %d", len(episodes))
+			}
+			if len(episodes[0].Events) != 2 || !episodes[0].End.Equal(at(9, 35)) ||
+				len(episodes[0].Actors) != 2 {
+				t.Errorf("Unexpected deploy episode: %+v", episodes[0])
+			}
+			if episodes[1].ID != "migration" || len(episodes[1].Events) != 2 {
+				t.Errorf("Expected named migration episode, got %+v", episodes[1])
+			}
+			if len(episodes[2].Events) != 2 || episodes[2].Events[0].Location != "Berlin office" {
+				t.Errorf("Unexpected customer episode: %+v", episodes[2])
+			}
+
+			if err := episodic.RecordEvent(memory.Event{Description: "x", StartTime: at(10, 0), EndTime: at(9, 0)}); err == nil {
+				t.Error("Expected error for event ending before it starts")
+			}
+		})
+	}
+}
+
+func TestEpisodicOverlapAndLimit(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			m, err := backend.open(t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			day := time.Now().AddDate(0, 0, -1)
+			at := func(hour, minute int) time.Time {
+				return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+			}
+			episodic := memory.NewEpisodicMemory(m.GetMemoryManager())
+			recordTestEvents(t, episodic, day)
+
+			// 开始时间早于时间范围、但持续到范围内的长事件
+			err = episodic.RecordEvent(memory.Event{
+				Description: "Billing system outage", StartTime: at(6, 0), EndTime: at(14, 30),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, query := range []string{"", "billing outage"} {
+				around, err := episodic.Around(query, at(15, 0), memory.AroundOptions{Window: time.Hour})
+				if err != nil {
+					t.Fatal(err)
+				}
+				found := false
+				for _, event := range around {
+					if event.Description == "Billing system outage" {
+						found = true
+					}
+					if event.Description == "Started the migration plan" {
+						t.Errorf("[%q] Event outside window: %s", query, event.Description)
+					}
+				}
+				if !found {
+					t.Errorf("[%q] Expected the overlapping outage, got %+v", query, around)
+				}
+			}
+
+			// 数量限制取最早的事件
+			timeline, err := episodic.Timeline(memory.TimelineOptions{Limit: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(timeline) != 2 || timeline[0].Description != "Billing system outage" ||
+				timeline[1].Description != "Deployed release 2.4 to production" {
+				t.Errorf("Expected the two earliest events, got %+v", timeline)
+			}
+		})
+	}
+}
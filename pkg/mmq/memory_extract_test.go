@@ -0,0 +1,233 @@
+package mmq
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/llm"
+	"github.com/crosszan/modu/pkg/mmq/memory"
+)
+
+// scriptedExtraction 模拟LLM的抽取输出：带代码块和说明文字，包含重复、低置信度和无效候选
+const scriptedExtraction = "Here is what I found:\n```json\n" + `{
+  "facts": [
+    {"subject": "alice", "predicate": "lives in", "object": "Berlin", "confidence": 0.9, "turns": [1]},
+    {"subject": " alice ", "predicate": "Lives In", "object": "Berlin", "confidence": 0.7, "turns": [2, 9]},
+    {"subject": "alice", "predicate": "works_for", "object": "Acme", "confidence": 0.8, "turns": [2]},
+    {"subject": "alice", "predicate": "might_visit", "object": "Rome", "confidence": 0.2, "turns": [2]},
+    {"subject": "", "predicate": "is", "object": "tired", "confidence": 0.9}
+  ],
+  "preferences": [
+    {"category": "Drinks", "key": "tea", "value": "green", "confidence": 0.6, "turns": [1]},
+    {"category": "drinks", "key": "tea", "value": "jasmine", "confidence": 0.9, "turns": [2]},
+    {"category": "travel", "key": "seat", "value": "window", "confidence": 1.5}
+  ]
+}` + "\n```"
+
+func TestExtractorFromTurns(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			m, err := backend.open(t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			conv := memory.NewConversationMemory(m.GetMemoryManagerIn(alice))
+			base := time.Now().Add(-time.Hour)
+			for i, turn := range []memory.ConversationTurn{
+				{User: "I moved to Berlin last month, I drink green tea", Assistant: "Nice!"},
+				{User: "I started at Acme, and I switched to jasmine tea", Assistant: "Congrats!"},
+			} {
+				turn.SessionID = "chat"
+				turn.Timestamp = base.Add(time.Duration(i) * time.Minute)
+				if err := conv.StoreTurn(turn); err != nil {
+					t.Fatal(err)
+				}
+			}
+			turns, err := conv.GetHistory("chat", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// GetHistory按时间倒序，抽取按时间顺序
+			turns[0], turns[1] = turns[1], turns[0]
+
+			mock := m.llm.(*llm.MockLLM)
+			mock.SetResponses(scriptedExtraction)
+			extractor := m.GetExtractorIn(alice)
+
+			extraction, err := extractor.ExtractTurns(turns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			prompts := mock.Prompts()
+			if len(prompts) != 1 || !strings.Contains(prompts[0], "[Turn 2]\nuser: I started at Acme") {
+				t.Errorf("Expected numbered turns in the prompt, got %q", prompts)
+			}
+
+			if len(extraction.Facts) != 2 {
+				t.Fatalf("Expected 2 facts after validation and dedup, got %+v", extraction.Facts)
+			}
+			lives := extraction.Facts[0]
+			if lives.Predicate != "lives_in" || lives.Confidence != 0.9 || lives.Source != "extracted" {
+				t.Errorf("Expected normalized lives_in fact with max confidence, got %+v", lives)
+			}
+			if len(lives.SourceTurns) != 2 || lives.SourceTurns[0] != turns[0].ID || lives.SourceTurns[1] != turns[1].ID {
+				t.Errorf("Expected source turns %s and %s, got %v", turns[0].ID, turns[1].ID, lives.SourceTurns)
+			}
+			if len(extraction.Preferences) != 1 || extraction.Preferences[0].Value != "jasmine" ||
+				extraction.Preferences[0].Category != "drinks" {
+				t.Errorf("Expected the higher-confidence tea preference, got %+v", extraction.Preferences)
+			}
+			if len(extraction.Rejected) != 3 {
+				t.Errorf("Expected 3 rejected candidates, got %v", extraction.Rejected)
+			}
+			for _, reason := range extraction.Rejected {
+				t.Logf("Rejected: %s", reason)
+			}
+
+			stats, err := extractor.Store(extraction)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Facts != 2 || stats.Preferences != 1 || stats.Rejected != 3 {
+				t.Errorf("Unexpected stats: %+v", stats)
+			}
+
+			facts := memory.NewFactMemory(m.GetMemoryManagerIn(alice))
+			stored, err := facts.Match(memory.FactPattern{Subject: "alice", Predicate: "lives_in"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored) != 1 || stored[0].Object != "Berlin" {
+				t.Errorf("Expected stored lives_in fact, got %+v", stored)
+			}
+			value, err := memory.NewPreferenceMemory(m.GetMemoryManagerIn(alice)).GetPreference("drinks", "tea")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != "jasmine" {
+				t.Errorf("Expected tea preference jasmine, got %v", value)
+			}
+
+			// 来源轮次和抽取置信度保存在记忆的metadata中
+			manager := m.GetMemoryManagerIn(alice)
+			for _, mt := range []memory.MemoryType{memory.MemoryTypeFact, memory.MemoryTypePreference} {
+				memories, err := manager.GetByType(mt)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, mem := range memories {
+					turnIDs, _ := mem.Metadata["source_turns"].([]interface{})
+					if len(turnIDs) == 0 || mem.Metadata["confidence"] == nil {
+						t.Errorf("Expected provenance in %s metadata, got %v", mt, mem.Metadata)
+					}
+				}
+			}
+			prefMemories, err := manager.GetByType(memory.MemoryTypePreference)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(prefMemories) != 1 || prefMemories[0].Metadata["confidence"] != 0.9 ||
+				fmt.Sprint(prefMemories[0].Metadata["source_turns"]) != fmt.Sprint([]string{turns[1].ID}) {
+				t.Errorf("Expected tea preference with confidence 0.9 from turn 2, got %v", prefMemories)
+			}
+
+			// 重复抽取同样的内容：事实合并（同一来源不提升置信度），偏好跳过
+			mock.SetResponses(scriptedExtraction)
+			stats, err = extractor.IngestTurns(turns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Duplicates != 1 || stats.Preferences != 0 {
+				t.Errorf("Expected the preference to be skipped as duplicate, got %+v", stats)
+			}
+			stored, err = facts.Match(memory.FactPattern{Subject: "alice"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored) != 2 || stored[0].Confidence != 0.9 {
+				t.Errorf("Expected 2 facts with unchanged confidence, got %+v", stored)
+			}
+		})
+	}
+}
+
+func TestExtractorFromText(t *testing.T) {
+	m, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	mock := m.llm.(*llm.MockLLM)
+	extractor := m.GetExtractorIn(alice)
+	prefs := memory.NewPreferenceMemory(m.GetMemoryManagerIn(alice))
+	if err := prefs.RecordPreference(memory.Preference{Category: "drinks", Key: "tea", Value: "green", Source: "inferred", Confidence: 0.6}); err != nil {
+		t.Fatal(err)
+	}
+	if err := prefs.RecordPreference(memory.Preference{Category: "travel", Key: "seat", Value: "aisle", Source: "user"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 偏好值变化且置信度不低于已有偏好时更新，而不是新增一条
+	mock.SetResponses(`{"facts": [], "preferences": [{"category": "drinks", "key": "tea", "value": "oolong", "confidence": 0.8}]}`)
+	stats, err := extractor.IngestText("These days Alice only drinks oolong.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Preferences != 1 {
+		t.Errorf("Expected 1 preference updated, got %+v", stats)
+	}
+	all, err := prefs.GetAllPreferences()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all["drinks"]["tea"] != "oolong" {
+		t.Errorf("Expected tea preference updated to oolong, got %v", all)
+	}
+	count, err := m.GetMemoryManagerIn(alice).CountByType(memory.MemoryTypePreference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 preference memories, got %d", count)
+	}
+
+	// 置信度更低的新值不覆盖已有偏好，用户明确给出的偏好（未指定置信度）不被抽取结果覆盖
+	mock.SetResponses(`{"facts": [], "preferences": [
+		{"category": "drinks", "key": "tea", "value": "black", "confidence": 0.7},
+		{"category": "travel", "key": "seat", "value": "window", "confidence": 0.95}]}`)
+	stats, err = extractor.IngestText("Alice once had black tea and sat by the window.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Kept != 2 || stats.Preferences != 0 {
+		t.Errorf("Expected both preferences kept, got %+v", stats)
+	}
+	all, err = prefs.GetAllPreferences()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all["drinks"]["tea"] != "oolong" || all["travel"]["seat"] != "aisle" {
+		t.Errorf("Expected lower-confidence values ignored, got %v", all)
+	}
+
+	// 无法解析的输出返回错误，不写入任何内容
+	mock.SetResponses("I could not find anything.")
+	if _, err := extractor.IngestText("Hello there"); err == nil {
+		t.Error("Expected error for output without JSON")
+	}
+
+	// 空输入不调用LLM
+	before := len(mock.Prompts())
+	extraction, err := extractor.ExtractText("   ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(extraction.Facts) != 0 || len(mock.Prompts()) != before {
+		t.Errorf("Expected empty input to skip generation")
+	}
+}
@@ -0,0 +1,349 @@
+package mmq
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/memory"
+)
+
+// assertTestGraph 写入一个小型知识图谱：alice -> Acme -> Berlin -> Germany，bob -> Acme
+func assertTestGraph(t *testing.T, facts *memory.FactMemory) {
+	t.Helper()
+	for _, fact := range []memory.Fact{
+		{Subject: "alice", Predicate: "works_for", Object: "Acme", Confidence: 0.9},
+		{Subject: "bob", Predicate: "works_for", Object: "Acme", Confidence: 0.8},
+		{Subject: "Acme", Predicate: "located_in", Object: "Berlin", Confidence: 0.9},
+		{Subject: "Berlin", Predicate: "capital_of", Object: "Germany", Confidence: 1},
+		{Subject: "alice", Predicate: "likes", Object: "tea", Confidence: 0.6},
+	} {
+		if err := facts.StoreFact(fact); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFactConfidenceAcrossSources(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			m, err := backend.open(t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			facts := memory.NewFactMemory(m.GetMemoryManagerIn(alice))
+			for _, fact := range []memory.Fact{
+				{Subject: "alice", Predicate: "likes", Object: "tea", Confidence: 0.6, Source: "chat"},
+				{Subject: "alice", Predicate: "likes", Object: "tea", Confidence: 0.5, Source: "email"},
+				{Subject: "alice", Predicate: "likes", Object: "tea", Confidence: 0.3, Source: "chat"}, // 同一来源取较高值
+			} {
+				if err := facts.StoreFact(fact); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := facts.Match(memory.FactPattern{Subject: "alice", Predicate: "likes"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 {
+				t.Fatalf("Expected one merged fact, got %d", len(got))
+			}
+			// 1 - (1-0.6)(1-0.5) = 0.8
+			if math.Abs(got[0].Confidence-0.8) > 1e-9 || got[0].Source != "chat" || len(got[0].Sources) != 2 {
+				t.Errorf("Expected combined confidence 0.8 from chat and email, got %+v", got[0])
+			}
+			t.Logf("Combined: %.2f from %v", got[0].Confidence, got[0].Sources)
+
+			// 检索用的事实记忆只有一条，且置信度同步
+			count, err := m.GetMemoryManagerIn(alice).CountByType(memory.MemoryTypeFact)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 1 {
+				t.Errorf("Expected 1 fact memory, got %d", count)
+			}
+			searched, err := facts.SearchFacts("alice likes tea", 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(searched) != 1 || searched[0].ID != got[0].ID || math.Abs(searched[0].Confidence-0.8) > 1e-9 {
+				t.Errorf("Expected semantic search to return the merged fact, got %+v", searched)
+			}
+
+			// 其他用户断言同一事实不影响alice的置信度
+			bobFacts := memory.NewFactMemory(m.GetMemoryManagerIn(bob))
+			if err := bobFacts.StoreFact(memory.Fact{Subject: "alice", Predicate: "likes", Object: "tea", Confidence: 0.9, Source: "gossip"}); err != nil {
+				t.Fatal(err)
+			}
+			got, err = facts.Match(memory.FactPattern{Subject: "alice"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || math.Abs(got[0].Confidence-0.8) > 1e-9 {
+				t.Errorf("Expected alice's fact unchanged by bob, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestFunctionalPredicates(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			m, err := backend.open(t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			facts := memory.NewFactMemory(m.GetMemoryManager())
+			if err := facts.SetFunctional("lives_in"); err != nil {
+				t.Fatal(err)
+			}
+
+			base := time.Now().Add(-72 * time.Hour)
+			for i, city := range []string{"Berlin", "Paris"} {
+				err := facts.StoreFact(memory.Fact{Subject: "alice", Predicate: "lives_in", Object: city,
+					Confidence: 0.9, Timestamp: base.Add(time.Duration(i) * 24 * time.Hour)})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			// 晚到的旧消息：断言时间早于当前值，直接记为历史
+			late, err := facts.Assert(memory.Fact{Subject: "alice", Predicate: "lives_in", Object: "Rome",
+				Confidence: 0.9, Timestamp: base.Add(12 * time.Hour)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if late.Current() {
+				t.Error("Expected out-of-order assertion to be recorded as history")
+			}
+			// 非函数型谓词可以有多个值
+			for _, lang := range []string{"German", "French"} {
+				if err := facts.StoreFact(memory.Fact{Subject: "alice", Predicate: "speaks", Object: lang}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			current, err := facts.Match(memory.FactPattern{Subject: "alice", Predicate: "lives_in"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(current) != 1 || current[0].Object != "Paris" {
+				t.Fatalf("Expected Paris as the only current value, got %+v", current)
+			}
+
+			history, err := facts.History("alice", "lives_in")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(history) != 3 {
+				t.Fatalf("Expected 3 values in history, got %d", len(history))
+			}
+			for _, fact := range history {
+				t.Logf("%s lives_in %s since %s, current=%v", fact.Subject, fact.Object, fact.Timestamp.Format(time.RFC3339), fact.Current())
+				if fact.Object != "Paris" && (fact.Current() || fact.SupersededBy != current[0].ID) {
+					t.Errorf("Expected %s to be superseded by Paris, got %+v", fact.Object, fact)
+				}
+			}
+
+			speaks, err := facts.Match(memory.FactPattern{Predicate: "speaks"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(speaks) != 2 {
+				t.Errorf("Expected 2 languages, got %d", len(speaks))
+			}
+
+			// 被取代的值不再参与语义检索
+			count, err := m.GetMemoryManager().CountByType(memory.MemoryTypeFact)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 3 {
+				t.Errorf("Expected 3 fact memories (Paris, German, French), got %d", count)
+			}
+
+			// 搬回柏林：新记录取代巴黎，旧的柏林保留为历史
+			// 函数型谓词按命名空间持久化，新建的管理器同样生效
+			if err := memory.NewFactMemory(m.GetMemoryManager()).StoreFact(memory.Fact{Subject: "alice", Predicate: "lives_in", Object: "Berlin"}); err != nil {
+				t.Fatal(err)
+			}
+			current, err = facts.Match(memory.FactPattern{Subject: "alice", Predicate: "lives_in"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(current) != 1 || current[0].Object != "Berlin" {
+				t.Errorf("Expected Berlin as current value, got %+v", current)
+			}
+
+			// 其他命名空间没有设置函数型谓词，可以有多个值
+			bobFacts := memory.NewFactMemory(m.GetMemoryManagerIn(Namespace{User: "bob"}))
+			for _, city := range []string{"Oslo", "Bergen"} {
+				if err := bobFacts.StoreFact(memory.Fact{Subject: "bob", Predicate: "lives_in", Object: city}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if bobCities, _ := bobFacts.Match(memory.FactPattern{Subject: "bob", Predicate: "lives_in"}); len(bobCities) != 2 {
+				t.Errorf("Expected lives_in not functional for bob, got %+v", bobCities)
+			}
+
+			// 删除事实同时删除历史
+			if err := facts.DeleteFact("alice", "lives_in", "Berlin"); err != nil {
+				t.Fatal(err)
+			}
+			history, err = facts.History("alice", "lives_in")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(history) != 2 {
+				t.Errorf("Expected Paris and Rome left in history, got %d", len(history))
+			}
+		})
+	}
+}
+
+func TestFactPatternsAndTraversal(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			m, err := backend.open(t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			facts := memory.NewFactMemory(m.GetMemoryManager())
+			assertTestGraph(t, facts)
+
+			cases := []struct {
+				pattern memory.FactPattern
+				want    int
+			}{
+				{memory.FactPattern{Subject: "alice"}, 2},
+				{memory.FactPattern{Predicate: "works_for", Object: "Acme"}, 2},
+				{memory.FactPattern{Subject: "ali*"}, 2},
+				{memory.FactPattern{Subject: "*", Predicate: "*_in"}, 1},
+				{memory.FactPattern{Object: "*e*"}, 5}, // Acme x2, Berlin, Germany, tea
+				{memory.FactPattern{MinConfidence: 0.85}, 3},
+				{memory.FactPattern{Limit: 2}, 2},
+				{memory.FactPattern{Subject: "Alice"}, 0},
+			}
+			for _, tc := range cases {
+				got, err := facts.Match(tc.pattern)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(got) != tc.want {
+					t.Errorf("Pattern %+v: expected %d facts, got %d", tc.pattern, tc.want, len(got))
+				}
+			}
+
+			neighbors, err := facts.Neighbors("alice", 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(neighbors) != 2 {
+				t.Errorf("Expected 2 facts within 1 hop of alice, got %d", len(neighbors))
+			}
+			neighbors, err = facts.Neighbors("alice", 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(neighbors) != 4 {
+				t.Errorf("Expected 4 facts within 2 hops of alice (+bob works_for, Acme located_in), got %d", len(neighbors))
+			}
+
+			path, err := facts.FindPath("alice", "Germany", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{"works_for", "located_in", "capital_of"}
+			if len(path) != len(want) {
+				t.Fatalf("Expected 3-hop path, got %+v", path)
+			}
+			for i, fact := range path {
+				t.Logf("Hop %d: %s %s %s", i+1, fact.Subject, fact.Predicate, fact.Object)
+				if fact.Predicate != want[i] {
+					t.Errorf("Hop %d: expected %s, got %s", i+1, want[i], fact.Predicate)
+				}
+			}
+
+			// 路径不区分方向
+			path, err = facts.FindPath("alice", "bob", 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(path) != 2 || path[1].Subject != "bob" {
+				t.Errorf("Expected alice -> Acme <- bob, got %+v", path)
+			}
+
+			path, err = facts.FindPath("alice", "Germany", 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != nil {
+				t.Errorf("Expected no path within 2 hops, got %+v", path)
+			}
+		})
+	}
+}
+
+func TestFactBackfill(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	m, err := NewWithDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟旧版本写入的事实记忆：三元组只在metadata中
+	err = m.StoreMemory(Memory{
+		Type:      MemoryTypeFact,
+		Content:   "Go is a programming language",
+		Metadata:  map[string]interface{}{"subject": "Go", "predicate": "is", "object": "programming language", "confidence": 0.7},
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetStore().DB().Exec("DELETE FROM facts"); err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	m, err = NewWithDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	facts := memory.NewFactMemory(m.GetMemoryManager())
+	got, err := facts.GetFactsBySubject("Go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Object != "programming language" || got[0].Confidence != 0.7 {
+		t.Fatalf("Expected legacy fact backfilled, got %+v", got)
+	}
+
+	// 再次断言合并到回填的事实，检索记忆仍只有一条
+	if err := facts.StoreFact(memory.Fact{Subject: "Go", Predicate: "is", Object: "programming language", Confidence: 0.5, Source: "docs"}); err != nil {
+		t.Fatal(err)
+	}
+	count, err := m.GetMemoryManager().CountByType(memory.MemoryTypeFact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("Expected the legacy memory to be reused, got %d fact memories", count)
+	}
+	got, err = facts.GetFactsBySubject("Go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || math.Abs(got[0].Confidence-0.85) > 1e-9 {
+		t.Errorf("Expected combined confidence 0.85, got %+v", got)
+	}
+}
@@ -0,0 +1,282 @@
+package mmq
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/memory"
+)
+
+var (
+	alice = Namespace{Tenant: "acme", User: "alice"}
+	bob   = Namespace{Tenant: "acme", User: "bob"}
+)
+
+// storeNamespacedMemories 为alice和bob写入内容相同的记忆，另有一条全局记忆
+func storeNamespacedMemories(t *testing.T, m *MMQ) {
+	t.Helper()
+	past := time.Now().Add(-time.Hour)
+
+	memories := []Memory{
+		{Namespace: alice, Type: MemoryTypePreference, Content: "Prefers dark roast coffee", Timestamp: time.Now()},
+		{Namespace: bob, Type: MemoryTypePreference, Content: "Prefers dark roast coffee", Timestamp: time.Now()},
+		{Namespace: Namespace{Tenant: "acme", User: "alice", Agent: "planner"}, Type: MemoryTypeFact,
+			Content: "Quarterly planning is due in March", Timestamp: time.Now()},
+		{Namespace: Namespace{Tenant: "acme", User: "alice", Session: "s1"}, Type: MemoryTypeFact,
+			Content: "Currently drafting the coffee budget", Timestamp: time.Now()},
+		{Namespace: alice, Type: MemoryTypeFact, Content: "Expired coffee voucher", Timestamp: past, ExpiresAt: &past},
+		{Namespace: bob, Type: MemoryTypeFact, Content: "Expired coffee voucher", Timestamp: past, ExpiresAt: &past},
+		{Type: MemoryTypeFact, Content: "Coffee beans are roasted seeds", Timestamp: time.Now()},
+	}
+	for _, mem := range memories {
+		if err := m.StoreMemory(mem); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMemoryNamespaceIsolation(t *testing.T) {
+	m, err := NewWithDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	storeNamespacedMemories(t, m)
+
+	recalled, err := m.RecallMemories("coffee", RecallOptions{Limit: 10, Scopes: []Namespace{bob}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recalled) != 2 {
+		t.Errorf("Expected 2 memories for bob, got %d", len(recalled))
+	}
+	for _, mem := range recalled {
+		if mem.Namespace.User != "bob" {
+			t.Errorf("Memory from another user leaked: %+v", mem)
+		}
+	}
+
+	// 限定在alice命名空间内的管理器
+	mgr := m.GetMemoryManagerIn(alice)
+	memories, err := mgr.Recall("coffee", memory.RecallOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(memories) != 4 {
+		t.Errorf("Expected 4 memories for alice, got %d", len(memories))
+	}
+	for _, mem := range memories {
+		if mem.Namespace.Tenant != "acme" || mem.Namespace.User != "alice" {
+			t.Errorf("Unexpected namespace %+v", mem.Namespace)
+		}
+	}
+
+	// 限定的管理器不能越界访问
+	_, err = mgr.Recall("coffee", memory.RecallOptions{Limit: 10, Scopes: []memory.Namespace{{User: "bob"}}})
+	if !errors.Is(err, memory.ErrOutsideNamespace) {
+		t.Errorf("Expected ErrOutsideNamespace for foreign scope, got %v", err)
+	}
+
+	bobMemories, err := m.RecallMemories("coffee", RecallOptions{Limit: 1, Scopes: []Namespace{bob}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobID := bobMemories[0].ID
+	if _, err := mgr.GetByID(bobID); !errors.Is(err, ErrOutsideNamespace) {
+		t.Errorf("Expected GetByID outside namespace to fail, got %v", err)
+	}
+	if err := mgr.Delete(bobID); !errors.Is(err, ErrOutsideNamespace) {
+		t.Errorf("Expected Delete outside namespace to fail, got %v", err)
+	}
+	if _, err := m.GetMemoryByID(bobID); err != nil {
+		t.Errorf("Bob's memory should survive: %v", err)
+	}
+
+	err = mgr.Store(memory.Memory{Namespace: memory.Namespace{User: "bob"}, Type: memory.MemoryTypeFact, Content: "x"})
+	if !errors.Is(err, memory.ErrOutsideNamespace) {
+		t.Errorf("Expected Store into foreign namespace to fail, got %v", err)
+	}
+}
+
+func TestRecallAcrossScopes(t *testing.T) {
+	m, err := NewWithDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	storeNamespacedMemories(t, m)
+
+	// agent记忆和当前会话记忆的并集
+	scopes := []Namespace{
+		{Tenant: "acme", User: "alice", Agent: "planner"},
+		{Tenant: "acme", User: "alice", Session: "s1"},
+	}
+	recalled, err := m.RecallMemories("planning budget", RecallOptions{Limit: 10, Scopes: scopes})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]bool)
+	for _, mem := range recalled {
+		got[mem.Content] = true
+	}
+	if len(recalled) != 2 || !got["Quarterly planning is due in March"] || !got["Currently drafting the coffee budget"] {
+		t.Errorf("Expected the agent and session memories, got %+v", recalled)
+	}
+
+	// 未指定范围时搜索全部记忆
+	all, err := m.RecallMemories("coffee", RecallOptions{Limit: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 7 {
+		t.Errorf("Expected 7 memories without scopes, got %d", len(all))
+	}
+}
+
+func TestNamespaceScopedCountAndCleanup(t *testing.T) {
+	m, err := NewWithDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	storeNamespacedMemories(t, m)
+
+	count, err := m.CountMemoriesIn(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Errorf("Expected 4 memories for alice, got %d", count)
+	}
+
+	cleaned, err := m.CleanupExpiredMemoriesIn(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cleaned != 1 {
+		t.Errorf("Expected 1 expired memory removed for alice, got %d", cleaned)
+	}
+
+	// bob的过期记忆不受影响
+	count, err = m.CountMemoriesIn(bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Expected bob's memories untouched, got %d", count)
+	}
+
+	count, err = m.CountMemories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 6 {
+		t.Errorf("Expected 6 memories in total, got %d", count)
+	}
+}
+
+func TestMemoryNamespaceMigration(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// 旧版本的数据库：memories表没有命名空间列，会话ID只在metadata中
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE memories (
+			id TEXT PRIMARY KEY,
+			type TEXT NOT NULL,
+			content TEXT NOT NULL,
+			metadata TEXT,
+			tags TEXT,
+			timestamp TEXT NOT NULL,
+			expires_at TEXT,
+			importance REAL NOT NULL DEFAULT 0.5,
+			embedding BLOB
+		);
+		INSERT INTO memories (id, type, content, metadata, tags, timestamp, importance)
+		VALUES ('m1', 'conversation', '用户: hi\n助手: hello',
+			'{"session_id":"legacy","user_msg":"hi","assistant_msg":"hello"}', '[]', '2024-01-01T00:00:00Z', 0.5);
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewWithDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	conv := memory.NewConversationMemory(m.GetMemoryManager())
+	history, err := conv.GetHistory("legacy", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].User != "hi" {
+		t.Errorf("Expected legacy session migrated, got %+v", history)
+	}
+
+	count, err := m.CountMemoriesIn(Namespace{Session: "legacy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 memory in legacy session, got %d", count)
+	}
+
+	// 命名空间列带复合索引
+	var index string
+	err = m.GetStore().DB().QueryRow(
+		"SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'memories' AND sql LIKE '%tenant, user_id, agent_id, session_id%'",
+	).Scan(&index)
+	if err != nil {
+		t.Fatalf("Expected composite namespace index on memories: %v", err)
+	}
+}
+
+func TestMemoryNamespaceArchiveRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	src, err := NewWithDB(filepath.Join(tmpDir, "src.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	storeNamespacedMemories(t, src)
+
+	archivePath := filepath.Join(tmpDir, "export.tar")
+	if _, err := src.Export(archivePath, DefaultArchiveOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := NewWithDB(filepath.Join(tmpDir, "dst.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	if _, err := dst.Import(archivePath, DefaultArchiveOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ns := range []Namespace{alice, bob} {
+		want, _ := src.CountMemoriesIn(ns)
+		got, err := dst.CountMemoriesIn(ns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Namespace %+v: expected %d memories after import, got %d", ns, want, got)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package mmq
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/memory"
+)
+
+func TestHybridMemoryRecall(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			m, err := backend.open(t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			contents := []string{
+				"Invoice 90417 is overdue",
+				"Invoice 90418 was paid in full",
+				"The customer asked about invoices last week",
+				"Quarterly invoices are sent on the first Monday",
+			}
+			ts := time.Now()
+			for _, content := range contents {
+				err := m.StoreMemory(Memory{Type: MemoryTypeFact, Content: content, Timestamp: ts, Importance: 0.5})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opts := RecallOptions{Limit: 4}
+			for _, mode := range []RecallMode{RecallModeVector, RecallModeFTS, RecallModeHybrid} {
+				opts.Mode = mode
+				recalled, err := m.RecallMemories("90417", opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(recalled) == 0 {
+					t.Errorf("%s: expected results", mode)
+					continue
+				}
+				t.Logf("%s: top=%q (%d results)", mode, recalled[0].Content, len(recalled))
+
+				if mode == RecallModeVector {
+					continue
+				}
+				if recalled[0].Content != "Invoice 90417 is overdue" {
+					t.Errorf("%s: expected exact invoice first, got %q", mode, recalled[0].Content)
+				}
+			}
+
+			// 混合检索保留向量召回的结果
+			opts.Mode = RecallModeHybrid
+			recalled, err := m.RecallMemories("90417", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(recalled) != len(contents) {
+				t.Errorf("Expected vector results fused in, got %d", len(recalled))
+			}
+
+			// 融合后的相关度归一化到0-1
+			memories, err := m.GetMemoryManager().Recall("90417", memory.RecallOptions{Limit: 4, Mode: memory.RecallModeHybrid})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, mem := range memories {
+				if mem.Relevance <= 0 || mem.Relevance > 1 {
+					t.Errorf("Expected hybrid relevance in (0,1], got %f for %q", mem.Relevance, mem.Content)
+				}
+			}
+
+			if _, err := m.RecallMemories("90417", RecallOptions{Limit: 4, Mode: "semantic"}); err == nil {
+				t.Error("Expected error for unknown recall mode")
+			}
+		})
+	}
+}
+
+func TestMemoryFullTextBackfill(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	m, err := NewWithDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.StoreMemory(Memory{Type: MemoryTypeFact, Content: "Warehouse code ZX-42 moved", Timestamp: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟旧版本的数据库：记忆没有全文索引
+	db := m.GetStore().DB()
+	if _, err := db.Exec("DELETE FROM memories_fts"); err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	m, err = NewWithDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	recalled, err := m.RecallMemories("warehouse", RecallOptions{Limit: 5, Mode: RecallModeFTS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recalled) != 1 {
+		t.Errorf("Expected memory backfilled into full-text index, got %d", len(recalled))
+	}
+}
+
+func TestMemoryFullTextStableKeys(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	m, err := NewWithDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := memory.DefaultRecallOptions().Mode; got != memory.RecallModeVector {
+		t.Errorf("Expected vector recall by default, got %s", got)
+	}
+
+	for _, content := range []string{"Invoice 7731 was paid", "Warehouse code ZX-42 moved", "仓库编号已经更新"} {
+		if err := m.StoreMemory(Memory{Type: MemoryTypeFact, Content: content, Timestamp: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 模拟旧版本的数据库：索引行以memories.rowid为键，没有键表
+	db := m.GetStore().DB()
+	for _, stmt := range []string{
+		"DELETE FROM memory_fts_keys",
+		"DELETE FROM memories_fts",
+		"INSERT INTO memories_fts (rowid, content, tags) SELECT rowid, content, tags FROM memories",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Close()
+
+	m, err = NewWithDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	// 删除记忆并VACUUM后，全文检索仍返回对应的记忆
+	recalled, err := m.RecallMemories("invoice", RecallOptions{Limit: 5, Mode: RecallModeFTS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recalled) != 1 {
+		t.Fatalf("Expected rebuilt index, got %+v", recalled)
+	}
+	if err := m.DeleteMemory(recalled[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetStore().DB().Exec("VACUUM"); err != nil {
+		t.Fatal(err)
+	}
+
+	for query, want := range map[string]string{
+		"warehouse": "Warehouse code ZX-42 moved",
+		"仓库编号":      "仓库编号已经更新",
+	} {
+		recalled, err := m.RecallMemories(query, RecallOptions{Limit: 5, Mode: RecallModeFTS})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(recalled) != 1 || recalled[0].Content != want {
+			t.Errorf("RecallMemories(%q): expected %q, got %+v", query, want, recalled)
+		}
+	}
+
+	// 记忆表的触发器只使用内置SQL，标准驱动也能修改记忆
+	plain, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	if _, err := plain.Exec("UPDATE memories SET tags = '[\"archive\"]'"); err != nil {
+		t.Fatalf("Expected memory triggers to work without custom functions: %v", err)
+	}
+	if _, err := plain.Exec("DELETE FROM memories WHERE content LIKE 'Warehouse%'"); err != nil {
+		t.Fatalf("Expected memory triggers to work without custom functions: %v", err)
+	}
+}
@@ -0,0 +1,367 @@
+package mmq
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/llm"
+	"github.com/crosszan/modu/pkg/mmq/memory"
+)
+
+// scriptedReflection 模拟LLM的反思输出：包含重复、无来源和来源编号无效的洞察
+const scriptedReflection = "```json\n" + `{
+  "insights": [
+    {"content": "Alice is training for a marathon", "sources": [1, 3, 5]},
+    {"content": "Alice prefers   mornings for exercise", "sources": [2, 3, 3]},
+    {"content": "alice is training for a marathon", "sources": [1]},
+    {"content": "Alice is generally happy", "sources": []},
+    {"content": "Alice owns a boat", "sources": [42]}
+  ]
+}` + "\n```"
+
+func storeReflectionInputs(t *testing.T, manager *memory.Manager, base time.Time, contents ...string) []string {
+	t.Helper()
+	for i, content := range contents {
+		err := manager.Store(memory.Memory{
+			Type:       memory.MemoryTypeEpisodic,
+			Content:    content,
+			Timestamp:  base.Add(time.Duration(i) * time.Minute),
+			Importance: 0.4 + 0.1*float64(i%3),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := manager.List(memory.ListOptions{MemoryTypes: []memory.MemoryType{memory.MemoryTypeEpisodic}, Filter: memory.Filter{Since: base}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, len(contents))
+	for i := len(page.Memories) - 1; i >= 0; i-- {
+		ids = append(ids, page.Memories[i].ID)
+	}
+	return ids
+}
+
+func TestReflectorSynthesizesInsights(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			m, err := backend.open(t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			manager := m.GetMemoryManagerIn(alice)
+			base := time.Now().Add(-10 * time.Hour)
+			ids := storeReflectionInputs(t, manager, base,
+				"Alice ran 10km before work",
+				"Alice said she likes to exercise at 6am",
+				"Alice bought new running shoes",
+				"Alice had pasta for dinner",
+				"Alice signed up for the city marathon",
+			)
+			if err := m.GetMemoryManagerIn(bob).Store(memory.Memory{
+				Type: memory.MemoryTypeEpisodic, Content: "Bob went sailing", Timestamp: base,
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			mock := m.llm.(*llm.MockLLM)
+			mock.SetResponses(scriptedReflection)
+			reflector := m.GetReflectorIn(alice, memory.DefaultReflectorOptions())
+
+			reflection, err := reflector.Reflect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			prompts := mock.Prompts()
+			if len(prompts) != 1 || !strings.Contains(prompts[0], "[1] (episodic, ") ||
+				!strings.Contains(prompts[0], ") Alice signed up for the city marathon") {
+				t.Fatalf("Expected numbered memories in the prompt, got %q", prompts)
+			}
+			if strings.Contains(prompts[0], "Bob went sailing") {
+				t.Error("Expected other namespaces to be excluded from the prompt")
+			}
+			if strings.Index(prompts[0], "ran 10km") > strings.Index(prompts[0], "city marathon") {
+				t.Error("Expected memories in chronological order")
+			}
+
+			if reflection.Considered != 5 || len(reflection.Insights) != 2 || len(reflection.Rejected) != 2 {
+				t.Fatalf("Unexpected reflection: %+v", reflection)
+			}
+			for _, reason := range reflection.Rejected {
+				t.Logf("Rejected: %s", reason)
+			}
+			marathon := reflection.Insights[0]
+			if len(marathon.Sources) != 3 || marathon.Sources[0] != ids[0] || marathon.Sources[2] != ids[4] {
+				t.Errorf("Expected marathon insight to link memories 1, 3 and 5, got %v", marathon.Sources)
+			}
+			mornings := reflection.Insights[1]
+			if mornings.Content != "Alice prefers mornings for exercise" || len(mornings.Sources) != 2 {
+				t.Errorf("Expected normalized insight with deduplicated sources, got %+v", mornings)
+			}
+			for _, insight := range reflection.Insights {
+				if insight.Importance < 0.7 || insight.Importance > 1 {
+					t.Errorf("Expected elevated importance, got %+v", insight)
+				}
+			}
+
+			stored, err := manager.GetByType(memory.MemoryTypeReflection)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored) != 2 {
+				t.Fatalf("Expected 2 reflection memories, got %d", len(stored))
+			}
+			for _, mem := range stored {
+				if mem.Namespace != toMemoryNamespace(alice) || len(memory.ReflectionSources(mem)) == 0 {
+					t.Errorf("Expected reflection in alice's namespace with sources, got %+v", mem)
+				}
+			}
+			if count, _ := m.GetMemoryManagerIn(bob).CountByType(memory.MemoryTypeReflection); count != 0 {
+				t.Errorf("Expected no reflections for bob, got %d", count)
+			}
+
+			// 没有新记忆时跳过，不调用LLM
+			reflection, err = reflector.Reflect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflection.Skipped || len(mock.Prompts()) != 1 {
+				t.Errorf("Expected reflection to be skipped without new memories, got %+v", reflection)
+			}
+
+			// 只参考上次反思之后的记忆
+			storeReflectionInputs(t, manager, base.Add(time.Hour),
+				"Alice ran 15km", "Alice ran 18km", "Alice ran 21km", "Alice stretched", "Alice slept early")
+			mock.SetResponses(`{"insights": [{"content": "Alice is steadily increasing her running distance", "sources": [1, 2, 3]}]}`)
+			reflection, err = reflector.Reflect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			prompts = mock.Prompts()
+			if reflection.Considered != 5 || strings.Contains(prompts[1], "10km") {
+				t.Errorf("Expected only the 5 new memories to be considered, got %d", reflection.Considered)
+			}
+			if count, _ := manager.CountByType(memory.MemoryTypeReflection); count != 3 {
+				t.Errorf("Expected 3 reflection memories, got %d", count)
+			}
+		})
+	}
+}
+
+func TestReflectMemoriesBacklogAndDryRun(t *testing.T) {
+	m, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	manager := m.GetMemoryManagerIn(alice)
+	contents := make([]string, 6)
+	for i := range contents {
+		contents[i] = fmt.Sprintf("Alice note %d", i+1)
+	}
+	ids := storeReflectionInputs(t, manager, time.Now().Add(-time.Hour), contents...)
+
+	mock := m.llm.(*llm.MockLLM)
+	response := `{"insights": [{"content": "Alice keeps notes", "sources": [1, 2]}]}`
+
+	// DryRun只生成不写入
+	mock.SetResponses(response)
+	result, err := m.ReflectMemories(ReflectOptions{Namespace: alice, MaxMemories: 3, MinMemories: 3, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Insights) != 1 || result.Insights[0].Sources[0] != ids[0] {
+		t.Errorf("Expected an insight from the oldest memories, got %+v", result)
+	}
+	if count, _ := manager.CountByType(memory.MemoryTypeReflection); count != 0 {
+		t.Errorf("Expected dry run to store nothing, got %d", count)
+	}
+
+	// 超过MaxMemories时先处理最早的，其余留给下一次
+	for round, first := range []string{ids[0], ids[3]} {
+		mock.SetResponses(response)
+		result, err := m.ReflectMemories(ReflectOptions{Namespace: alice, MaxMemories: 3, MinMemories: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Considered != 3 || len(result.Insights) != 1 || result.Insights[0].Sources[0] != first {
+			t.Errorf("Round %d: expected memories starting at %s, got %+v", round+1, first, result)
+		}
+	}
+
+	result, err = m.ReflectMemories(ReflectOptions{Namespace: alice, MinMemories: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Skipped {
+		t.Errorf("Expected the backlog to be exhausted, got %+v", result)
+	}
+}
+
+func TestReflectorCursor(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			m, err := backend.open(t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+			m.memoryManager.SetDedup(memory.DedupOptions{Threshold: 0.95})
+
+			// 三条记忆在同一秒内写入
+			manager := m.GetMemoryManagerIn(alice)
+			second := time.Now().Add(-time.Hour).Truncate(time.Second)
+			for i := 0; i < 3; i++ {
+				err := manager.Store(memory.Memory{Type: memory.MemoryTypeEpisodic, Content: fmt.Sprintf("Alice note %d", i+1), Timestamp: second})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			mock := m.llm.(*llm.MockLLM)
+			opts := memory.ReflectorOptions{MaxMemories: 2, MinMemories: 1}
+
+			// 没有有效洞察时同样推进进度；同一秒内剩下的记忆留给下一次
+			mock.SetResponses(`{"insights": [{"content": "Alice owns a boat", "sources": [42]}]}`)
+			reflection, err := m.GetReflectorIn(alice, opts).Reflect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reflection.Considered != 2 || len(reflection.Insights) != 0 {
+				t.Fatalf("Expected 2 memories considered without insights, got %+v", reflection)
+			}
+
+			mock.SetResponses(`{"insights": [{"content": "Alice keeps notes", "sources": [1]}]}`)
+			reflection, err = m.GetReflectorIn(alice, opts).Reflect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			prompts := mock.Prompts()
+			if reflection.Considered != 1 || !strings.Contains(prompts[len(prompts)-1], "Alice note 3") {
+				t.Fatalf("Expected only the third memory of the boundary second, got %+v", reflection)
+			}
+
+			// 去重合并的洞察时间前移，reflected_through随之前移，进度不回退
+			later := second.Add(10 * time.Minute)
+			if err := manager.Store(memory.Memory{Type: memory.MemoryTypeEpisodic, Content: "Alice note 4", Timestamp: later}); err != nil {
+				t.Fatal(err)
+			}
+			mock.SetResponses(`{"insights": [{"content": "Alice keeps notes", "sources": [1]}]}`)
+			if _, err := m.GetReflectorIn(alice, opts).Reflect(); err != nil {
+				t.Fatal(err)
+			}
+			stored, err := manager.GetByType(memory.MemoryTypeReflection)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored) != 1 || stored[0].Metadata[memory.MetadataReflectedThrough] != later.Format(time.RFC3339) {
+				t.Fatalf("Expected one merged reflection through %s, got %+v", later.Format(time.RFC3339), stored)
+			}
+
+			reflection, err = m.GetReflectorIn(alice, opts).Reflect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflection.Skipped || len(mock.Prompts()) != 3 {
+				t.Errorf("Expected all memories reflected, got %+v", reflection)
+			}
+		})
+	}
+}
+
+func TestReflectorCursorAfterPartialFailure(t *testing.T) {
+	m := newRankingTestMMQ(t)
+
+	manager := m.GetMemoryManagerIn(alice)
+	storeReflectionInputs(t, manager, time.Now().Add(-time.Hour).Truncate(time.Second),
+		"Alice ran 10km", "Alice ran 15km", "Alice bought running shoes")
+
+	// 第二条洞察写入失败
+	db := m.GetStore().DB()
+	_, err := db.Exec(`
+		CREATE TRIGGER fail_second_reflection BEFORE INSERT ON memories
+		WHEN NEW.type = 'reflection' AND (SELECT COUNT(*) FROM memories WHERE type = 'reflection') >= 1
+		BEGIN SELECT RAISE(ABORT, 'disk full'); END
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock := m.llm.(*llm.MockLLM)
+	mock.SetResponses(`{"insights": [
+		{"content": "Alice is training for a race", "sources": [1, 2]},
+		{"content": "Alice invests in running gear", "sources": [3]}
+	]}`)
+	opts := memory.ReflectorOptions{MinMemories: 1}
+	reflection, err := m.GetReflectorIn(alice, opts).Reflect()
+	if err == nil {
+		t.Fatal("Expected error when storing the second insight fails")
+	}
+	if len(reflection.Insights) != 1 {
+		t.Errorf("Expected only the stored insight reported, got %+v", reflection.Insights)
+	}
+
+	// 已写入的洞察推进了进度：重试不会再次参考同一批记忆
+	if _, err := db.Exec("DROP TRIGGER fail_second_reflection"); err != nil {
+		t.Fatal(err)
+	}
+	reflection, err = m.GetReflectorIn(alice, opts).Reflect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflection.Skipped || reflection.Considered != 0 {
+		t.Errorf("Expected the cursor saved after the partial failure, got %+v", reflection)
+	}
+	stored, err := manager.GetByType(memory.MemoryTypeReflection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 {
+		t.Errorf("Expected 1 stored reflection, got %d", len(stored))
+	}
+}
+
+func TestReflectorBackground(t *testing.T) {
+	m, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	storeReflectionInputs(t, m.GetMemoryManagerIn(alice), time.Now().Add(-time.Hour), "Alice moved to Berlin", "Alice started learning German")
+	m.llm.(*llm.MockLLM).SetResponses(`{"insights": [{"content": "Alice is settling in Germany", "sources": [1, 2]}]}`)
+
+	runs := make(chan *memory.Reflection, 10)
+	opts := memory.DefaultReflectorOptions()
+	opts.MinMemories = 2
+	opts.Interval = 10 * time.Millisecond
+	opts.OnRun = func(reflection *memory.Reflection, err error) {
+		if err != nil {
+			t.Error(err)
+		}
+		runs <- reflection
+	}
+
+	reflector := m.GetReflectorIn(alice, opts)
+	reflector.Start()
+	select {
+	case reflection := <-runs:
+		if len(reflection.Insights) != 1 {
+			t.Errorf("Expected one insight from the background run, got %+v", reflection)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a background run")
+	}
+	reflector.Stop()
+
+	if count, _ := m.GetMemoryManagerIn(alice).CountByType(memory.MemoryTypeReflection); count != 1 {
+		t.Errorf("Expected 1 reflection memory, got %d", count)
+	}
+}
@@ -0,0 +1,302 @@
+package mmq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/memory"
+)
+
+func TestRecallReinforcesMemories(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			m, err := backend.open(t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			manager := m.GetMemoryManagerIn(alice)
+			old := time.Now().Add(-60 * 24 * time.Hour)
+			for _, content := range []string{"alice parks her bike at the north gate", "alice parks her car at the south gate"} {
+				err := manager.Store(memory.Memory{Type: memory.MemoryTypeEpisodic, Content: content, Timestamp: old, Importance: 0.5})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opts := memory.DefaultRecallOptions()
+			opts.Mode = memory.RecallModeFTS
+			recalled, err := manager.Recall("bike", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(recalled) != 1 || recalled[0].AccessCount != 1 || recalled[0].LastAccessed == nil {
+				t.Fatalf("Expected the recalled memory to be reinforced, got %+v", recalled)
+			}
+			bike := recalled[0].ID
+
+			stored, err := manager.GetByID(bike)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.AccessCount != 1 || stored.LastAccessed == nil || time.Since(*stored.LastAccessed) > time.Minute {
+				t.Errorf("Expected access statistics to be persisted, got %+v", stored)
+			}
+
+			// NoReinforce不记录召回
+			opts.NoReinforce = true
+			if _, err := manager.Recall("bike", opts); err != nil {
+				t.Fatal(err)
+			}
+			stored, _ = manager.GetByID(bike)
+			if stored.AccessCount != 1 {
+				t.Errorf("Expected NoReinforce to leave access count at 1, got %d", stored.AccessCount)
+			}
+
+			// 被召回过的记忆从召回时间开始衰减，排在同样旧但未被召回的记忆之前
+			recalled, err = manager.Recall("alice parks", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(recalled) != 2 || recalled[0].ID != bike || recalled[0].Relevance <= recalled[1].Relevance {
+				t.Errorf("Expected the reinforced memory to rank first, got %+v", recalled)
+			}
+			for _, mem := range recalled {
+				t.Logf("%s: relevance=%.4g accessed=%d", mem.Content, mem.Relevance, mem.AccessCount)
+			}
+		})
+	}
+}
+
+func TestStrengthModel(t *testing.T) {
+	model := memory.DefaultStrengthModel()
+	now := time.Now()
+	base := memory.Memory{Timestamp: now.Add(-30 * 24 * time.Hour), Importance: 0.5}
+
+	plain := model.Strength(base, now)
+	important := base
+	important.Importance = 1.0
+	reinforced := base
+	reinforced.AccessCount = 3
+	recent := base
+	accessed := now.Add(-time.Hour)
+	recent.LastAccessed = &accessed
+
+	t.Logf("plain=%.3f important=%.3f reinforced=%.3f recent=%.3f",
+		plain, model.Strength(important, now), model.Strength(reinforced, now), model.Strength(recent, now))
+
+	if plain <= 0 || plain >= 1 {
+		t.Errorf("Expected strength in (0, 1), got %f", plain)
+	}
+	if model.Strength(important, now) <= plain {
+		t.Error("Expected importance to slow down forgetting")
+	}
+	if model.Strength(reinforced, now) <= plain {
+		t.Error("Expected recalls to slow down forgetting")
+	}
+	if model.Strength(recent, now) <= plain {
+		t.Error("Expected a recent recall to restore strength")
+	}
+
+	capped := base
+	capped.AccessCount = model.MaxReinforcements + 5
+	limit := base
+	limit.AccessCount = model.MaxReinforcements
+	if model.Stability(capped) != model.Stability(limit) {
+		t.Error("Expected reinforcement to stop growing after MaxReinforcements")
+	}
+}
+
+func TestJanitorForgetsWeakMemories(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			m, err := backend.open(t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			manager := m.GetMemoryManagerIn(alice)
+			now := time.Now()
+			old := now.Add(-365 * 24 * time.Hour)
+			past := now.Add(-time.Hour)
+
+			add := func(mt memory.MemoryType, content string, ts time.Time, importance float64, expires *time.Time) string {
+				t.Helper()
+				if err := manager.Store(memory.Memory{Type: mt, Content: content, Timestamp: ts, Importance: importance, ExpiresAt: expires}); err != nil {
+					t.Fatal(err)
+				}
+				page, err := manager.List(memory.ListOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, mem := range page.Memories {
+					if mem.Content == content {
+						return mem.ID
+					}
+				}
+				t.Fatalf("stored memory %q not found", content)
+				return ""
+			}
+
+			weakEpisode := add(memory.MemoryTypeEpisodic, "went to a forgettable meeting", old, 0.2, nil)
+			usedEpisode := add(memory.MemoryTypeEpisodic, "the launch day of the rocket project", old, 0.2, nil)
+			weakChat := add(memory.MemoryTypeConversation, "User: hi\nAssistant: hello", old, 0.1, nil)
+			freshEpisode := add(memory.MemoryTypeEpisodic, "lunch with the team today", now, 0.2, nil)
+			oldFact := add(memory.MemoryTypeFact, "alice likes rockets", old, 0.2, nil)
+			add(memory.MemoryTypeEpisodic, "a temporary reminder", now, 0.9, &past)
+
+			// 经常被召回的记忆更稳定
+			for i := 0; i < 10; i++ {
+				if err := m.store.TouchMemories([]string{usedEpisode}, now); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// 其他命名空间的记忆不受影响
+			if err := m.GetMemoryManagerIn(bob).Store(memory.Memory{
+				Type: memory.MemoryTypeEpisodic, Content: "bob's old episode", Timestamp: old, Importance: 0.1,
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			opts := memory.DefaultJanitorOptions()
+			opts.Policies = []memory.ForgettingPolicy{
+				{Types: []memory.MemoryType{memory.MemoryTypeEpisodic}, MinStrength: 0.05, MinAge: 24 * time.Hour},
+				{Types: []memory.MemoryType{memory.MemoryTypeConversation}, MinStrength: 0.05, Action: memory.ForgetDelete},
+			}
+			opts.DryRun = true
+			dry, err := m.GetJanitorIn(alice, opts).RunOnce()
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Logf("Dry run: %+v", dry)
+			if dry.Expired != 1 || dry.Archived != 1 || dry.Deleted != 1 || dry.Scanned != 4 {
+				t.Errorf("Unexpected dry run stats: %+v", dry)
+			}
+			if count, _ := manager.Count(); count != 6 {
+				t.Errorf("Expected dry run to keep all 6 memories, got %d", count)
+			}
+
+			opts.DryRun = false
+			stats, err := m.GetJanitorIn(alice, opts).RunOnce()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Expired != 1 || stats.Archived != 1 || stats.Deleted != 1 {
+				t.Errorf("Unexpected stats: %+v", stats)
+			}
+			if len(stats.Forgotten) != 2 || stats.Forgotten[0] != weakEpisode || stats.Forgotten[1] != weakChat {
+				t.Errorf("Expected the weak episode and chat to be forgotten, got %v", stats.Forgotten)
+			}
+
+			for _, id := range []string{usedEpisode, freshEpisode, oldFact} {
+				if _, err := manager.GetByID(id); err != nil {
+					t.Errorf("Expected memory %s to be kept: %v", id, err)
+				}
+			}
+			if count, _ := m.GetMemoryManagerIn(bob).Count(); count != 1 {
+				t.Errorf("Expected bob's memory to be untouched, got %d", count)
+			}
+
+			archived, total, err := manager.ListArchived(0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != 1 || len(archived) != 1 || archived[0].ID != weakEpisode || archived[0].ArchivedAt.IsZero() {
+				t.Fatalf("Expected the weak episode in the archive, got %+v", archived)
+			}
+			if _, total, _ := m.GetMemoryManagerIn(bob).ListArchived(0, 0); total != 0 {
+				t.Errorf("Expected bob to see no archived memories, got %d", total)
+			}
+
+			// 归档的记忆不参与召回，恢复后保留原ID和命名空间
+			recallOpts := memory.DefaultRecallOptions()
+			recallOpts.Mode = memory.RecallModeFTS
+			recalled, err := manager.Recall("forgettable meeting", recallOpts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(recalled) != 0 {
+				t.Errorf("Expected archived memory to be excluded from recall, got %+v", recalled)
+			}
+			if err := m.GetMemoryManagerIn(bob).RestoreArchived(weakEpisode); err == nil {
+				t.Error("Expected bob to be unable to restore alice's memory")
+			}
+			if err := manager.RestoreArchived(weakEpisode); err != nil {
+				t.Fatal(err)
+			}
+			recalled, err = manager.Recall("forgettable meeting", recallOpts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(recalled) != 1 || recalled[0].ID != weakEpisode || recalled[0].Namespace != toMemoryNamespace(alice) {
+				t.Errorf("Expected the restored memory to be recalled, got %+v", recalled)
+			}
+			if _, total, _ := manager.ListArchived(0, 0); total != 0 {
+				t.Errorf("Expected the archive to be empty after restore, got %d", total)
+			}
+
+			// 未指定类型的策略不遗忘会话消息
+			chat := add(memory.MemoryTypeConversation, "User: still there?\nAssistant: yes", old, 0.1, nil)
+			opts.Policies = []memory.ForgettingPolicy{{MinStrength: 1.1}}
+			opts.DryRun = true
+			catchAll, err := m.GetJanitorIn(alice, opts).RunOnce()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(catchAll.Forgotten) != 4 {
+				t.Errorf("Expected every non-conversation memory to be forgotten, got %v", catchAll.Forgotten)
+			}
+			for _, id := range catchAll.Forgotten {
+				if id == chat {
+					t.Error("Expected conversation messages excluded from a policy without types")
+				}
+			}
+		})
+	}
+}
+
+func TestJanitorBackground(t *testing.T) {
+	m, err := NewInMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	past := time.Now().Add(-time.Minute)
+	if err := m.GetMemoryManagerIn(alice).Store(memory.Memory{
+		Type: memory.MemoryTypeEpisodic, Content: "expired note", ExpiresAt: &past,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	runs := make(chan memory.JanitorStats, 10)
+	opts := memory.DefaultJanitorOptions()
+	opts.Interval = 10 * time.Millisecond
+	opts.OnRun = func(stats memory.JanitorStats, err error) {
+		if err != nil {
+			t.Error(err)
+		}
+		runs <- stats
+	}
+
+	janitor := m.GetJanitorIn(alice, opts)
+	janitor.Start()
+	janitor.Start() // 重复启动无效
+	select {
+	case stats := <-runs:
+		if stats.Expired != 1 {
+			t.Errorf("Expected the first background run to remove 1 expired memory, got %+v", stats)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a background run")
+	}
+	janitor.Stop()
+	janitor.Stop()
+
+	if count, _ := m.GetMemoryManagerIn(alice).Count(); count != 0 {
+		t.Errorf("Expected the expired memory to be removed, got %d", count)
+	}
+}
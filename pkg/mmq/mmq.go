@@ -3,6 +3,8 @@ package mmq
 
 import (
 	"fmt"
+	"sync/atomic"
+	"time"
 
 	"github.com/crosszan/modu/pkg/mmq/llm"
 	"github.com/crosszan/modu/pkg/mmq/memory"
@@ -10,14 +12,37 @@ import (
 	"github.com/crosszan/modu/pkg/mmq/store"
 )
 
+// ErrNotSupported 当前存储后端不支持该操作（例如内存后端的导出与备份）
+var ErrNotSupported = store.ErrNotSupported
+
+// ErrRankingProfileNotFound 排序配置不存在（既未保存也不是内置配置）
+var ErrRankingProfileNotFound = store.ErrRankingProfileNotFound
+
+// ErrOutsideNamespace 访问了限定命名空间之外的记忆
+var ErrOutsideNamespace = memory.ErrOutsideNamespace
+
 // MMQ 核心实例
+//
+// 并发保证：MMQ 的所有方法（Close 除外）都可以被多个 goroutine 同时调用。
+//
+// 使用SQLite后端（New、NewWithDB）时，多个进程也可以同时打开同一个数据库文件：
+//   - 文档索引、嵌入生成、记忆写入等写操作在进程内串行化，事务保证原子性
+//   - 搜索、检索、回忆等读操作使用独立的读连接池，WAL 模式下不会被写操作阻塞
+//   - 跨进程写冲突先由 busy_timeout 等待，仍失败时按指数退避重试
+//
+// 其他后端（NewInMemory、NewWithBackend）的并发行为由后端实现决定，内存后端只在进程内共享。
+//
+// Close 应在所有其他调用返回后再调用。
 type MMQ struct {
-	store         *store.Store
+	store         store.Backend
 	llm           llm.LLM
 	embedding     *llm.EmbeddingGenerator
 	retriever     *rag.Retriever
 	memoryManager *memory.Manager
 	cfg           Config
+
+	queryLog  atomic.Bool  // 是否记录查询日志
+	lastPrune atomic.Int64 // 上次清理查询日志的时间（UnixNano）
 }
 
 // New 创建新的MMQ实例
@@ -28,11 +53,44 @@ func New(cfg Config) (*MMQ, error) {
 	}
 
 	// 初始化store
-	st, err := store.New(cfg.DBPath)
+	storeOpts := store.DefaultOptions()
+	storeOpts.BusyTimeout = cfg.BusyTimeout
+	storeOpts.MaxReadConns = cfg.MaxReadConns
+
+	st, err := store.NewWithOptions(cfg.DBPath, storeOpts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create store: %w", err)
 	}
 
+	return newWithBackend(st, cfg), nil
+}
+
+// NewWithBackend 使用指定存储后端创建MMQ实例
+// 可传入store.NewInMemory()或自定义的store.Backend实现，此时cfg.DBPath仅用于Status展示
+func NewWithBackend(backend store.Backend, cfg Config) (*MMQ, error) {
+	if backend == nil {
+		return nil, fmt.Errorf("invalid config: backend is nil")
+	}
+
+	if cfg.DBPath == "" {
+		cfg.DBPath = store.MemoryDBPath
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return newWithBackend(backend, cfg), nil
+}
+
+// NewInMemory 创建纯内存MMQ实例（不依赖SQLite文件，关闭后数据丢弃）
+func NewInMemory() (*MMQ, error) {
+	cfg := DefaultConfig()
+	cfg.DBPath = store.MemoryDBPath
+	return NewWithBackend(store.NewInMemory(), cfg)
+}
+
+// newWithBackend 在存储后端之上组装LLM、检索器和记忆管理器
+func newWithBackend(st store.Backend, cfg Config) *MMQ {
 	// 初始化LLM
 	// 默认使用MockLLM（用于测试和开发）
 	// 生产环境需要使用真实的LlamaCpp实现
@@ -47,15 +105,19 @@ func New(cfg Config) (*MMQ, error) {
 
 	// 创建记忆管理器
 	memoryMgr := memory.NewManager(st, embeddingGen)
+	memoryMgr.SetDedup(memory.DedupOptions{Threshold: cfg.MemoryDedupThreshold})
 
-	return &MMQ{
+	m := &MMQ{
 		store:         st,
 		llm:           llmImpl,
 		embedding:     embeddingGen,
 		retriever:     retriever,
 		memoryManager: memoryMgr,
 		cfg:           cfg,
-	}, nil
+	}
+	m.queryLog.Store(cfg.QueryLog)
+
+	return m
 }
 
 // NewWithDB 使用指定数据库路径快速初始化
@@ -113,6 +175,7 @@ func convertSearchResults(storeResults []store.SearchResult) []SearchResult {
 			Collection: sr.Collection,
 			Path:       sr.Path,
 			Timestamp:  sr.Timestamp,
+			Language:   sr.Language,
 		}
 	}
 	return results
@@ -122,6 +185,8 @@ func convertSearchResults(storeResults []store.SearchResult) []SearchResult {
 
 // RetrieveContext 检索相关上下文
 func (m *MMQ) RetrieveContext(query string, opts RetrieveOptions) ([]Context, error) {
+	start := time.Now()
+
 	// 转换为rag.RetrieveOptions
 	ragOpts := rag.RetrieveOptions{
 		Limit:      opts.Limit,
@@ -129,6 +194,30 @@ func (m *MMQ) RetrieveContext(query string, opts RetrieveOptions) ([]Context, er
 		Collection: opts.Collection,
 		Strategy:   rag.RetrievalStrategy(opts.Strategy),
 		Rerank:     opts.Rerank,
+
+		MMRLambda:      opts.MMRLambda,
+		MaxPerDocument: opts.MaxPerDocument,
+		DedupThreshold: opts.DedupThreshold,
+	}
+
+	profile, err := m.resolveRanking(opts.Profile, opts.Ranking, opts.Collection)
+	if err != nil {
+		return nil, err
+	}
+	ragOpts.Profile = profile
+
+	ragOpts.Fields = opts.Fields
+	ragOpts.Language = opts.Language
+	ragOpts.PreferLanguage = preferredLanguage(query, opts.PreferLanguage)
+
+	// 自动路由
+	if opts.Strategy == StrategyAuto {
+		ragOpts.FirstPassRouting = opts.FirstPassRouting
+		ragContexts, decision, err := m.retriever.RoutedRetrieve(query, ragOpts)
+		if err != nil {
+			return nil, err
+		}
+		return m.recordRetrieve(string(decision.Strategy), query, opts.Collection, start, convertRagContexts(ragContexts)), nil
 	}
 
 	// 调用retriever
@@ -138,7 +227,7 @@ func (m *MMQ) RetrieveContext(query string, opts RetrieveOptions) ([]Context, er
 	}
 
 	// 转换类型
-	return convertRagContexts(ragContexts), nil
+	return m.recordRetrieve(string(opts.Strategy), query, opts.Collection, start, convertRagContexts(ragContexts)), nil
 }
 
 // convertRagContexts 转换rag.Context到mmq.Context
@@ -151,41 +240,91 @@ func convertRagContexts(ragContexts []rag.Context) []Context {
 			Relevance: rc.Relevance,
 			Metadata:  rc.Metadata,
 		}
+		for _, pc := range rc.PathContexts {
+			contexts[i].PathContexts = append(contexts[i].PathContexts, PathContext{Path: pc.Path, Content: pc.Content})
+		}
 	}
 	return contexts
 }
 
 // Search BM25全文搜索（对标QMD的search）
 func (m *MMQ) Search(query string, opts SearchOptions) ([]SearchResult, error) {
-	results, err := m.store.SearchFTS(query, opts.Limit, opts.Collection)
+	start := time.Now()
+
+	profile, err := m.resolveRanking(opts.Profile, opts.Ranking, opts.Collection)
 	if err != nil {
 		return nil, err
 	}
 
-	// 转换类型
-	return convertSearchResults(results), nil
+	lang := preferredLanguage(query, opts.PreferLanguage)
+
+	if profile == nil {
+		results, err := m.store.SearchFTS(query, searchFetchLimit(opts.Limit, lang), documentFilter(opts))
+		if err != nil {
+			return nil, err
+		}
+		results = store.ApplyLanguagePreference(results, lang)
+		if opts.Limit > 0 && len(results) > opts.Limit {
+			results = results[:opts.Limit]
+		}
+
+		// 转换类型
+		return m.recordSearch(QueryKindSearch, "fts", query, opts.Collection, start, convertSearchResults(results)), nil
+	}
+
+	fetchLimit := searchFetchLimit(rankedFetchLimit(opts.Limit, profile), lang)
+	results, err := m.store.SearchFTSWeighted(query, fetchLimit, documentFilter(opts), profile.FieldWeights)
+	if err != nil {
+		return nil, err
+	}
+	results = store.ApplyLanguagePreference(results, lang)
+
+	ranked, err := m.rankResults(query, results, profile, opts.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.recordSearch(QueryKindSearch, "fts", query, opts.Collection, start, convertSearchResults(ranked)), nil
 }
 
 // VectorSearch 向量语义搜索（对标QMD的vsearch）
 // 返回完整文档（文档级别），不是文本块
 func (m *MMQ) VectorSearch(query string, opts SearchOptions) ([]SearchResult, error) {
+	start := time.Now()
+
 	// 生成查询向量
 	queryEmbed, err := m.embedding.Generate(query, true)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
 	}
 
+	profile, err := m.resolveRanking(opts.Profile, opts.Ranking, opts.Collection)
+	if err != nil {
+		return nil, err
+	}
+
+	lang := preferredLanguage(query, opts.PreferLanguage)
+
 	// 文档级向量搜索
-	results, err := m.store.SearchVectorDocuments(query, queryEmbed, opts.Limit, opts.Collection)
+	fetchLimit := searchFetchLimit(rankedFetchLimit(opts.Limit, profile), lang)
+	results, err := m.store.SearchVectorDocuments(query, queryEmbed, fetchLimit, documentFilter(opts))
 	if err != nil {
 		return nil, err
 	}
+	results = store.ApplyLanguagePreference(results, lang)
 
-	return convertSearchResults(results), nil
+	ranked, err := m.rankResults(query, results, profile, opts.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.recordSearch(QueryKindVector, "vector", query, opts.Collection, start, convertSearchResults(ranked)), nil
 }
 
 // HybridSearch 混合搜索
 func (m *MMQ) HybridSearch(query string, opts SearchOptions) ([]SearchResult, error) {
+	start := time.Now()
+
 	// 转换为rag.RetrieveOptions
 	ragOpts := rag.RetrieveOptions{
 		Limit:      opts.Limit,
@@ -195,6 +334,16 @@ func (m *MMQ) HybridSearch(query string, opts SearchOptions) ([]SearchResult, er
 		Rerank:     false, // HybridSearch默认不重排
 	}
 
+	profile, err := m.resolveRanking(opts.Profile, opts.Ranking, opts.Collection)
+	if err != nil {
+		return nil, err
+	}
+	ragOpts.Profile = profile
+
+	ragOpts.Fields = opts.Fields
+	ragOpts.Language = opts.Language
+	ragOpts.PreferLanguage = preferredLanguage(query, opts.PreferLanguage)
+
 	// 调用retriever获取上下文
 	contexts, err := m.retriever.Retrieve(query, ragOpts)
 	if err != nil {
@@ -205,6 +354,7 @@ func (m *MMQ) HybridSearch(query string, opts SearchOptions) ([]SearchResult, er
 	results := make([]SearchResult, len(contexts))
 	for i, ctx := range contexts {
 		results[i] = SearchResult{
+			ID:         getMetadataString(ctx.Metadata, "id"),
 			Score:      ctx.Relevance,
 			Title:      getMetadataString(ctx.Metadata, "title"),
 			Content:    ctx.Text,
@@ -212,10 +362,11 @@ func (m *MMQ) HybridSearch(query string, opts SearchOptions) ([]SearchResult, er
 			Source:     getMetadataString(ctx.Metadata, "source"),
 			Collection: getMetadataString(ctx.Metadata, "collection"),
 			Path:       getMetadataString(ctx.Metadata, "path"),
+			Language:   getMetadataString(ctx.Metadata, "language"),
 		}
 	}
 
-	return results, nil
+	return m.recordSearch(QueryKindHybrid, "hybrid", query, opts.Collection, start, results), nil
 }
 
 // getMetadataString 从元数据中获取字符串值
@@ -234,6 +385,7 @@ func getMetadataString(metadata map[string]interface{}, key string) string {
 func (m *MMQ) StoreMemory(mem Memory) error {
 	memoryMem := memory.Memory{
 		ID:         mem.ID,
+		Namespace:  toMemoryNamespace(mem.Namespace),
 		Type:       memory.MemoryType(mem.Type),
 		Content:    mem.Content,
 		Metadata:   mem.Metadata,
@@ -255,6 +407,10 @@ func (m *MMQ) RecallMemories(query string, opts RecallOptions) ([]Memory, error)
 		DecayHalflife:      opts.DecayHalflife,
 		WeightByImportance: opts.WeightByImportance,
 		MinRelevance:       opts.MinRelevance,
+		Scopes:             toMemoryNamespaces(opts.Scopes),
+		Mode:               memory.RecallMode(opts.Mode),
+		Filter:             toMemoryFilter(opts.Filter),
+		NoReinforce:        opts.NoReinforce,
 	}
 
 	memories, err := m.memoryManager.Recall(query, memOpts)
@@ -269,6 +425,7 @@ func (m *MMQ) RecallMemories(query string, opts RecallOptions) ([]Memory, error)
 func (m *MMQ) UpdateMemory(id string, mem Memory) error {
 	memoryMem := memory.Memory{
 		ID:         mem.ID,
+		Namespace:  toMemoryNamespace(mem.Namespace),
 		Type:       memory.MemoryType(mem.Type),
 		Content:    mem.Content,
 		Metadata:   mem.Metadata,
@@ -295,6 +452,7 @@ func (m *MMQ) GetMemoryByID(id string) (*Memory, error) {
 
 	return &Memory{
 		ID:         mem.ID,
+		Namespace:  fromMemoryNamespace(mem.Namespace),
 		Type:       MemoryType(mem.Type),
 		Content:    mem.Content,
 		Metadata:   mem.Metadata,
@@ -302,6 +460,9 @@ func (m *MMQ) GetMemoryByID(id string) (*Memory, error) {
 		Timestamp:  mem.Timestamp,
 		ExpiresAt:  mem.ExpiresAt,
 		Importance: mem.Importance,
+
+		AccessCount:  mem.AccessCount,
+		LastAccessed: mem.LastAccessed,
 	}, nil
 }
 
@@ -315,6 +476,128 @@ func (m *MMQ) CountMemories() (int, error) {
 	return m.memoryManager.Count()
 }
 
+// CleanupExpiredMemoriesIn 清理命名空间内的过期记忆
+func (m *MMQ) CleanupExpiredMemoriesIn(ns Namespace) (int, error) {
+	return m.GetMemoryManagerIn(ns).CleanupExpired()
+}
+
+// CountMemoriesIn 统计命名空间内的记忆数量
+func (m *MMQ) CountMemoriesIn(ns Namespace) (int, error) {
+	return m.GetMemoryManagerIn(ns).Count()
+}
+
+// DedupeMemories 合并命名空间内已有的近似重复记忆（opts.Namespace为零值时处理全部记忆）
+func (m *MMQ) DedupeMemories(opts DedupOptions) (*DedupStats, error) {
+	stats, err := m.GetMemoryManagerIn(opts.Namespace).Dedupe(memory.DedupOptions{
+		Threshold:       opts.Threshold,
+		ImportanceBoost: opts.ImportanceBoost,
+		DryRun:          opts.DryRun,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &DedupStats{
+		Scanned: stats.Scanned,
+		Merged:  stats.Merged,
+		Kept:    stats.Kept,
+	}, nil
+}
+
+// ReflectMemories 对命名空间内上次反思之后的记忆执行一次反思，洞察写入为反思记忆
+func (m *MMQ) ReflectMemories(opts ReflectOptions) (*ReflectionResult, error) {
+	memOpts := memory.DefaultReflectorOptions()
+	memOpts.MemoryTypes = convertMemoryTypes(opts.MemoryTypes)
+	memOpts.Window = opts.Window
+	memOpts.DryRun = opts.DryRun
+	if opts.MaxMemories > 0 {
+		memOpts.MaxMemories = opts.MaxMemories
+	}
+	if opts.MinMemories > 0 {
+		memOpts.MinMemories = opts.MinMemories
+	}
+	if opts.MaxInsights > 0 {
+		memOpts.MaxInsights = opts.MaxInsights
+	}
+
+	reflection, err := m.GetReflectorIn(opts.Namespace, memOpts).Reflect()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReflectionResult{
+		Considered: reflection.Considered,
+		Insights:   make([]Insight, len(reflection.Insights)),
+		Rejected:   reflection.Rejected,
+		Skipped:    reflection.Skipped,
+	}
+	for i, insight := range reflection.Insights {
+		result.Insights[i] = Insight{
+			Content:    insight.Content,
+			Sources:    insight.Sources,
+			Importance: insight.Importance,
+		}
+	}
+	return result, nil
+}
+
+// ListMemories 按过滤条件分页列出记忆（按时间倒序）
+func (m *MMQ) ListMemories(opts ListMemoriesOptions) (*MemoryPage, error) {
+	page, err := m.memoryManager.List(memory.ListOptions{
+		MemoryTypes: convertMemoryTypes(opts.MemoryTypes),
+		Filter:      toMemoryFilter(opts.Filter),
+		Scopes:      toMemoryNamespaces(opts.Scopes),
+		Limit:       opts.Limit,
+		Offset:      opts.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MemoryPage{
+		Memories: convertToMMQMemories(page.Memories),
+		Total:    page.Total,
+		Offset:   page.Offset,
+	}, nil
+}
+
+// toMemoryFilter 转换过滤条件到memory包类型
+func toMemoryFilter(f MemoryFilter) memory.Filter {
+	return memory.Filter{
+		AnyTags:       f.AnyTags,
+		AllTags:       f.AllTags,
+		Metadata:      f.Metadata,
+		Since:         f.Since,
+		Until:         f.Until,
+		MinImportance: f.MinImportance,
+		MaxImportance: f.MaxImportance,
+		Expiry:        memory.ExpiryState(f.Expiry),
+	}
+}
+
+// toMemoryNamespace 转换命名空间到memory包类型
+func toMemoryNamespace(ns Namespace) memory.Namespace {
+	return memory.Namespace{Tenant: ns.Tenant, User: ns.User, Agent: ns.Agent, Session: ns.Session}
+}
+
+// fromMemoryNamespace 转换命名空间到MMQ类型
+func fromMemoryNamespace(ns memory.Namespace) Namespace {
+	return Namespace{Tenant: ns.Tenant, User: ns.User, Agent: ns.Agent, Session: ns.Session}
+}
+
+// toMemoryNamespaces 转换命名空间列表
+func toMemoryNamespaces(scopes []Namespace) []memory.Namespace {
+	if scopes == nil {
+		return nil
+	}
+
+	converted := make([]memory.Namespace, len(scopes))
+	for i, ns := range scopes {
+		converted[i] = toMemoryNamespace(ns)
+	}
+	return converted
+}
+
 // convertMemoryTypes 转换记忆类型
 func convertMemoryTypes(types []MemoryType) []memory.MemoryType {
 	if types == nil {
@@ -334,6 +617,7 @@ func convertToMMQMemories(memories []memory.Memory) []Memory {
 	for i, mem := range memories {
 		mmqMemories[i] = Memory{
 			ID:         mem.ID,
+			Namespace:  fromMemoryNamespace(mem.Namespace),
 			Type:       MemoryType(mem.Type),
 			Content:    mem.Content,
 			Metadata:   mem.Metadata,
@@ -341,6 +625,9 @@ func convertToMMQMemories(memories []memory.Memory) []Memory {
 			Timestamp:  mem.Timestamp,
 			ExpiresAt:  mem.ExpiresAt,
 			Importance: mem.Importance,
+
+			AccessCount:  mem.AccessCount,
+			LastAccessed: mem.LastAccessed,
 		}
 	}
 	return mmqMemories
@@ -699,8 +986,15 @@ func (m *MMQ) EmbedText(text string) ([]float32, error) {
 	return m.embedding.Generate(text, true)
 }
 
-// GetStore 获取Store实例（用于高级用法）
+// GetStore 获取SQLite Store实例（用于高级用法）
+// 使用NewWithBackend传入其他存储后端时返回nil，此时使用GetBackend
 func (m *MMQ) GetStore() *store.Store {
+	st, _ := m.store.(*store.Store)
+	return st
+}
+
+// GetBackend 获取存储后端（任意store.Backend实现）
+func (m *MMQ) GetBackend() store.Backend {
 	return m.store
 }
 
@@ -713,3 +1007,36 @@ func (m *MMQ) GetEmbedding() *llm.EmbeddingGenerator {
 func (m *MMQ) GetMemoryManager() *memory.Manager {
 	return m.memoryManager
 }
+
+// GetMemoryManagerIn 获取限定在命名空间内的MemoryManager
+// 多个终端用户共用一个数据库时，按用户（和会话）创建管理器，记忆互不可见
+func (m *MMQ) GetMemoryManagerIn(ns Namespace) *memory.Manager {
+	return m.memoryManager.WithNamespace(toMemoryNamespace(ns))
+}
+
+// GetConversationMemoryIn 获取命名空间内的对话记忆
+// 上下文窗口放不下的较早消息由配置的LLM生成滚动摘要
+func (m *MMQ) GetConversationMemoryIn(ns Namespace) *memory.ConversationMemory {
+	conv := memory.NewConversationMemory(m.GetMemoryManagerIn(ns))
+	if m.llm != nil {
+		conv.SetSummarizer(memory.NewLLMSummarizer(m.llm))
+	}
+	return conv
+}
+
+// GetJanitorIn 获取命名空间内的记忆清理器（删除过期记忆，按遗忘策略归档或删除强度过低的记忆）
+func (m *MMQ) GetJanitorIn(ns Namespace, opts memory.JanitorOptions) *memory.Janitor {
+	return memory.NewJanitor(m.GetMemoryManagerIn(ns), opts)
+}
+
+// GetReflectorIn 获取命名空间内的反思器（使用配置的LLM），Start后按opts.Interval定期反思
+func (m *MMQ) GetReflectorIn(ns Namespace, opts memory.ReflectorOptions) *memory.Reflector {
+	return memory.NewReflector(m.GetMemoryManagerIn(ns), m.llm, opts)
+}
+
+// GetExtractorIn 获取命名空间内的事实与偏好抽取器（使用配置的LLM和默认抽取选项）
+func (m *MMQ) GetExtractorIn(ns Namespace) *memory.Extractor {
+	manager := m.GetMemoryManagerIn(ns)
+	return memory.NewExtractor(m.llm, memory.NewFactMemory(manager), memory.NewPreferenceMemory(manager),
+		memory.DefaultExtractorOptions())
+}
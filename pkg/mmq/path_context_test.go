@@ -0,0 +1,126 @@
+package mmq
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/crosszan/modu/pkg/mmq/rag"
+)
+
+func TestRetrievedPathContexts(t *testing.T) {
+	m := newRankingTestMMQ(t)
+
+	m.AddContext("/", "Company knowledge base")
+	m.AddContext("qmd://docs", "Technical documentation")
+	m.AddContext("qmd://docs/api", "REST API reference for the billing service")
+
+	indexDocs(t, m,
+		Document{Collection: "docs", Path: "api/invoices.md", Title: "Invoices", Content: "Create invoices with POST /invoices."},
+		Document{Collection: "docs", Path: "api/refunds.md", Title: "Refunds", Content: "Refund invoices with POST /refunds."},
+		Document{Collection: "docs", Path: "guide.md", Title: "Guide", Content: "Getting started: send invoices monthly."},
+	)
+
+	contexts, err := m.RetrieveContext("invoices", RetrieveOptions{Limit: 10, Strategy: StrategyFTS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contexts) != 3 {
+		t.Fatalf("Expected 3 contexts, got %d", len(contexts))
+	}
+
+	for _, c := range contexts {
+		var paths []string
+		for _, pc := range c.PathContexts {
+			paths = append(paths, pc.Path)
+		}
+		t.Logf("%s: %v", c.Source, paths)
+
+		want := []string{"/", "qmd://docs"}
+		if strings.HasPrefix(c.Source, "docs/api/") {
+			want = append(want, "qmd://docs/api")
+		}
+		if strings.Join(paths, ",") != strings.Join(want, ",") {
+			t.Errorf("%s: expected chain %v, got %v", c.Source, want, paths)
+		}
+	}
+
+	// 构建时每条路径上下文只渲染一次
+	ragContexts := make([]rag.Context, len(contexts))
+	for i, c := range contexts {
+		ragContexts[i] = rag.Context{Text: c.Text, Source: c.Source, Relevance: c.Relevance, Metadata: c.Metadata}
+		for _, pc := range c.PathContexts {
+			ragContexts[i].PathContexts = append(ragContexts[i].PathContexts, rag.PathContext{Path: pc.Path, Content: pc.Content})
+		}
+	}
+
+	for _, format := range []rag.ContextFormat{rag.FormatMarkdown, rag.FormatPlain, rag.FormatXML, rag.FormatJSON} {
+		opts := rag.DefaultContextBuilderOptions()
+		opts.Format = format
+		prompt := rag.NewContextBuilder(opts).BuildPrompt("how do I refund an invoice?", ragContexts, "")
+
+		for _, content := range []string{"Company knowledge base", "Technical documentation", "REST API reference"} {
+			if n := strings.Count(prompt, content); n != 1 {
+				t.Errorf("%s: expected %q rendered once, got %d", format, content, n)
+			}
+		}
+	}
+
+	opts := rag.DefaultContextBuilderOptions()
+	opts.OmitPathContext = true
+	if text := rag.NewContextBuilder(opts).Build(ragContexts); strings.Contains(text, "Technical documentation") {
+		t.Error("Expected path contexts omitted")
+	}
+}
+
+func TestPathContextRankingSignal(t *testing.T) {
+	m := newRankingTestMMQ(t)
+
+	indexDocs(t, m,
+		Document{Collection: "kb", Path: "finance/q1.md", Title: "Q1", Content: "Quarterly overview: revenue grew."},
+		Document{Collection: "kb", Path: "eng/q1.md", Title: "Q1", Content: "Quarterly overview: uptime held."},
+	)
+
+	results, err := m.Search("quarterly overview", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	t.Logf("Without signal: %v", searchPaths(results))
+
+	// 为排在后面的文档所在目录添加描述
+	second := results[1]
+	m.AddContext("qmd://kb/"+path.Dir(second.Path), "Quarterly reports")
+
+	ranking := &RankingProfile{PathContextWeight: 2.0}
+	boosted, err := m.Search("quarterly overview", SearchOptions{Limit: 10, Ranking: ranking})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("With signal: %v", searchPaths(boosted))
+	if boosted[0].Path != second.Path {
+		t.Errorf("Expected described folder first, got %v", searchPaths(boosted))
+	}
+
+	contexts, err := m.RetrieveContext("quarterly overview", RetrieveOptions{Limit: 10, Strategy: StrategyFTS, Ranking: ranking})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contexts[0].Source != "kb/"+second.Path {
+		t.Errorf("Expected described folder first in retrieval, got %s", contexts[0].Source)
+	}
+
+	// 权重随配置保存
+	if err := m.SaveRankingProfile(RankingProfile{Name: "described", PathContextWeight: 2.0}); err != nil {
+		t.Fatal(err)
+	}
+	profile, err := m.GetRankingProfile("described")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.PathContextWeight != 2.0 {
+		t.Errorf("Expected path context weight to round-trip, got %v", profile.PathContextWeight)
+	}
+}
@@ -0,0 +1,53 @@
+package mmq
+
+import (
+	"fmt"
+
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+// Quantization 集合的向量量化方式
+type Quantization string
+
+const (
+	QuantizationNone   Quantization = store.QuantizationNone   // float32原始向量
+	QuantizationInt8   Quantization = store.QuantizationInt8   // int8标量量化，向量存储约为原来的1/4
+	QuantizationBinary Quantization = store.QuantizationBinary // 符号位编码预筛选，保留float32精确打分
+)
+
+// SetCollectionQuantization 设置集合的向量量化方式
+// 量化向量检索时先按汉明距离预筛选候选，再用float32（int8反量化）重新打分。
+// 之后写入的向量按设置量化；设置前已有的向量调用QuantizeEmbeddings转换。
+func (m *MMQ) SetCollectionQuantization(collection string, mode Quantization) error {
+	if err := m.store.SetCollectionQuantization(collection, string(mode)); err != nil {
+		return fmt.Errorf("failed to set quantization: %w", err)
+	}
+	return nil
+}
+
+// GetCollectionQuantization 获取集合的向量量化方式
+func (m *MMQ) GetCollectionQuantization(collection string) (Quantization, error) {
+	mode, err := m.store.GetCollectionQuantization(collection)
+	if err != nil {
+		return QuantizationNone, err
+	}
+	return Quantization(mode), nil
+}
+
+// QuantizeEmbeddings 按集合的量化设置转换已有向量
+// 被多个集合共享的内容只有在所有集合都是int8时才丢弃float32
+func (m *MMQ) QuantizeEmbeddings() (*QuantizeStats, error) {
+	stats, err := m.store.QuantizeEmbeddings()
+	if err != nil {
+		return nil, err
+	}
+
+	return &QuantizeStats{
+		Vectors:     stats.Vectors,
+		Float32:     stats.Float32,
+		Int8:        stats.Int8,
+		Binary:      stats.Binary,
+		BytesBefore: stats.BytesBefore,
+		BytesAfter:  stats.BytesAfter,
+	}, nil
+}
@@ -0,0 +1,316 @@
+package mmq
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+// 量化测试使用聚簇的随机向量：文档 = 簇中心 + 噪声，查询 = 某个文档 + 更小的噪声
+const (
+	quantDocs     = 2000
+	quantDim      = 256
+	quantClusters = 20
+	quantQueries  = 50
+	quantTopK     = 10
+)
+
+type quantFixture struct {
+	backend store.Backend
+	vectors [][]float32 // 文档向量（下标即文档序号）
+	queries [][]float32
+	stats   store.QuantizeStats
+}
+
+func randomVector(rng *rand.Rand, center []float32, noise float64) []float32 {
+	v := make([]float32, quantDim)
+	for i := range v {
+		base := 0.0
+		if center != nil {
+			base = float64(center[i])
+		}
+		v[i] = float32(base + rng.NormFloat64()*noise)
+	}
+	return v
+}
+
+// newQuantFixture 建立一个带随机向量的SQLite存储，并按mode量化
+func newQuantFixture(tb testing.TB, mode string) *quantFixture {
+	tb.Helper()
+
+	m, err := NewWithDB(filepath.Join(tb.TempDir(), "quant.db"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { m.Close() })
+
+	backend := m.GetBackend()
+	if err := backend.CreateCollection("vec", "/tmp/vec", "**/*.md"); err != nil {
+		tb.Fatal(err)
+	}
+
+	rng := rand.New(rand.NewSource(42))
+	centers := make([][]float32, quantClusters)
+	for i := range centers {
+		centers[i] = randomVector(rng, nil, 1)
+	}
+
+	f := &quantFixture{backend: backend}
+	index := make(map[string]int)
+	for i := 0; i < quantDocs; i++ {
+		f.vectors = append(f.vectors, randomVector(rng, centers[i%quantClusters], 0.5))
+		content := fmt.Sprintf("document %d", i)
+		index[content] = i
+		if err := backend.IndexDocument(store.Document{
+			Collection: "vec",
+			Path:       fmt.Sprintf("doc%d.md", i),
+			Title:      fmt.Sprintf("Doc %d", i),
+			Content:    content,
+		}); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	docs, err := backend.GetDocumentsNeedingEmbedding()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for _, doc := range docs {
+		if err := backend.StoreEmbedding(doc.Hash, 0, 0, f.vectors[index[doc.Content]], "random"); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	for i := 0; i < quantQueries; i++ {
+		f.queries = append(f.queries, randomVector(rng, f.vectors[rng.Intn(quantDocs)], 0.2))
+	}
+
+	if err := backend.SetCollectionQuantization("vec", mode); err != nil {
+		tb.Fatal(err)
+	}
+	if f.stats, err = backend.QuantizeEmbeddings(); err != nil {
+		tb.Fatal(err)
+	}
+
+	return f
+}
+
+// exactTopK float32暴力检索的top-k文档路径
+func (f *quantFixture) exactTopK(query []float32, k int) []string {
+	type scored struct {
+		path string
+		sim  float64
+	}
+	all := make([]scored, len(f.vectors))
+	for i, v := range f.vectors {
+		all[i] = scored{fmt.Sprintf("doc%d.md", i), cosineSim(query, v)}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].sim > all[j].sim })
+
+	paths := make([]string, k)
+	for i := range paths {
+		paths[i] = all[i].path
+	}
+	return paths
+}
+
+func cosineSim(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// recall 量化检索top-k与float32精确top-k的平均重合比例
+func (f *quantFixture) recall(tb testing.TB) float64 {
+	tb.Helper()
+
+	hits := 0
+	for _, q := range f.queries {
+		results, err := f.backend.SearchVector("", q, quantTopK, store.DocumentFilter{Collection: "vec"})
+		if err != nil {
+			tb.Fatal(err)
+		}
+		got := make(map[string]bool)
+		for _, r := range results {
+			got[r.Path] = true
+		}
+		for _, path := range f.exactTopK(q, quantTopK) {
+			if got[path] {
+				hits++
+			}
+		}
+	}
+	return float64(hits) / float64(len(f.queries)*quantTopK)
+}
+
+func TestQuantizedVectorRecall(t *testing.T) {
+	minRecall := map[string]float64{
+		store.QuantizationNone:   1.0,
+		store.QuantizationInt8:   0.9,
+		store.QuantizationBinary: 0.8,
+	}
+
+	for _, mode := range []string{store.QuantizationNone, store.QuantizationInt8, store.QuantizationBinary} {
+		name := mode
+		if name == "" {
+			name = "float32"
+		}
+		t.Run(name, func(t *testing.T) {
+			f := newQuantFixture(t, mode)
+			recall := f.recall(t)
+			t.Logf("%s: recall@%d=%.3f, %d vectors, %d -> %d bytes (%.0f bytes/vector)",
+				name, quantTopK, recall, f.stats.Vectors, f.stats.BytesBefore, f.stats.BytesAfter,
+				float64(f.stats.BytesAfter)/float64(f.stats.Vectors))
+
+			if recall < minRecall[mode] {
+				t.Errorf("Expected recall@%d >= %.2f, got %.3f", quantTopK, minRecall[mode], recall)
+			}
+
+			// 文档级向量搜索使用同样的两阶段检索，返回相同的文档
+			filter := store.DocumentFilter{Collection: "vec"}
+			chunks, err := f.backend.SearchVector("", f.queries[0], quantTopK, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			docs, err := f.backend.SearchVectorDocuments("", f.queries[0], quantTopK, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(docs) != len(chunks) {
+				t.Fatalf("Expected %d documents, got %d", len(chunks), len(docs))
+			}
+			for i := range docs {
+				if docs[i].Path != chunks[i].Path || docs[i].Score != chunks[i].Score || docs[i].ID == "" {
+					t.Errorf("Document result %d differs from chunk search: %+v vs %+v", i, docs[i], chunks[i])
+				}
+			}
+		})
+	}
+}
+
+func TestQuantizeEmbeddings(t *testing.T) {
+	m, err := NewWithDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if err := m.CreateCollection("vec", "/tmp/vec", CollectionOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	contents := []string{
+		"Vector databases store embeddings.",
+		"Cosine similarity compares vectors.",
+		"Unrelated text about gardening.",
+	}
+	for i, c := range contents {
+		indexDocs(t, m, Document{Collection: "vec", Path: fmt.Sprintf("doc%d.md", i), Title: fmt.Sprintf("Doc %d", i), Content: c})
+	}
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.SetCollectionQuantization("missing", QuantizationInt8); err == nil {
+		t.Error("Expected error for unknown collection")
+	}
+	if err := m.SetCollectionQuantization("vec", "int4"); err == nil {
+		t.Error("Expected error for unknown quantization")
+	}
+
+	if err := m.SetCollectionQuantization("vec", QuantizationInt8); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := m.QuantizeEmbeddings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("int8: %+v", stats)
+	if stats.Int8 != stats.Vectors || stats.BytesAfter*3 > stats.BytesBefore {
+		t.Errorf("Expected int8 to shrink vector storage to about 1/4, got %+v", stats)
+	}
+
+	results, err := m.VectorSearch(contents[1], SearchOptions{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 || results[0].Path != "doc1.md" || results[0].Score < 0.99 {
+		t.Errorf("Expected doc1.md first with int8 vectors, got %v", searchPaths(results))
+	}
+
+	// 新文档的向量在生成后自动量化
+	indexDocs(t, m, Document{Collection: "vec", Path: "doc3.md", Title: "Doc 3", Content: "Approximate nearest neighbour search."})
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = m.QuantizeEmbeddings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.BytesAfter != stats.BytesBefore {
+		t.Errorf("Expected new embeddings already quantized, got %+v", stats)
+	}
+
+	// 恢复float32
+	if err := m.SetCollectionQuantization("vec", QuantizationNone); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = m.QuantizeEmbeddings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Float32 != stats.Vectors || stats.BytesAfter <= stats.BytesBefore {
+		t.Errorf("Expected vectors restored to float32, got %+v", stats)
+	}
+	mode, err := m.GetCollectionQuantization("vec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode != QuantizationNone {
+		t.Errorf("Expected no quantization, got %q", mode)
+	}
+
+	results, err = m.VectorSearch(contents[1], SearchOptions{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 || results[0].Path != "doc1.md" {
+		t.Errorf("Expected doc1.md first after restoring float32, got %v", searchPaths(results))
+	}
+}
+
+func benchmarkVectorScan(b *testing.B, mode string) {
+	f := newQuantFixture(b, mode)
+	recall := f.recall(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := f.backend.SearchVector("", f.queries[i%len(f.queries)], quantTopK, store.DocumentFilter{Collection: "vec"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(f.stats.BytesAfter)/float64(f.stats.Vectors), "bytes/vector")
+	b.ReportMetric(recall, "recall@10")
+}
+
+func BenchmarkVectorScanFloat32(b *testing.B) {
+	benchmarkVectorScan(b, store.QuantizationNone)
+}
+
+func BenchmarkVectorScanInt8(b *testing.B) {
+	benchmarkVectorScan(b, store.QuantizationInt8)
+}
+
+func BenchmarkVectorScanBinary(b *testing.B) {
+	benchmarkVectorScan(b, store.QuantizationBinary)
+}
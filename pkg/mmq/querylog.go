@@ -0,0 +1,266 @@
+package mmq
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+// 查询日志中的调用类型
+const (
+	QueryKindSearch   = "search"   // Search（BM25）
+	QueryKindVector   = "vsearch"  // VectorSearch
+	QueryKindHybrid   = "hybrid"   // HybridSearch
+	QueryKindRetrieve = "retrieve" // RetrieveContext
+)
+
+const (
+	// queryLogPruneInterval 写入日志时按保留期清理旧日志的最小间隔
+	queryLogPruneInterval = time.Hour
+
+	defaultAnalyticsLimit = 10
+	defaultSlowThreshold  = 500 * time.Millisecond
+)
+
+// SetQueryLogging 开启或关闭查询日志（初始值为Config.QueryLog）
+func (m *MMQ) SetQueryLogging(enabled bool) {
+	m.queryLog.Store(enabled)
+}
+
+// QueryLoggingEnabled 是否正在记录查询日志
+func (m *MMQ) QueryLoggingEnabled() bool {
+	return m.queryLog.Load()
+}
+
+// recordSearch 记录一次搜索，并在结果上标注QueryID
+func (m *MMQ) recordSearch(kind, strategy, query, collection string, start time.Time, results []SearchResult) []SearchResult {
+	if !m.queryLog.Load() {
+		return results
+	}
+
+	logged := make([]store.QueryLogResult, len(results))
+	for i, r := range results {
+		logged[i] = store.QueryLogResult{ID: r.ID, Path: r.Collection + "/" + r.Path, Score: r.Score}
+	}
+
+	id := m.logQuery(kind, strategy, query, collection, start, logged)
+	for i := range results {
+		results[i].QueryID = id
+	}
+	return results
+}
+
+// recordRetrieve 记录一次上下文检索，并在结果上标注QueryID
+func (m *MMQ) recordRetrieve(strategy, query, collection string, start time.Time, contexts []Context) []Context {
+	if !m.queryLog.Load() {
+		return contexts
+	}
+
+	logged := make([]store.QueryLogResult, len(contexts))
+	for i, c := range contexts {
+		logged[i] = store.QueryLogResult{
+			ID:    getMetadataString(c.Metadata, "id"),
+			Path:  c.Source,
+			Score: c.Relevance,
+		}
+	}
+
+	id := m.logQuery(QueryKindRetrieve, strategy, query, collection, start, logged)
+	for i := range contexts {
+		contexts[i].QueryID = id
+	}
+	return contexts
+}
+
+// logQuery 写入查询日志，并定期按保留期清理
+// 日志写入失败不影响查询本身，此时返回空ID
+func (m *MMQ) logQuery(kind, strategy, query, collection string, start time.Time, results []store.QueryLogResult) string {
+	id, err := m.store.LogQuery(store.QueryLogEntry{
+		Timestamp:  start,
+		Kind:       kind,
+		Query:      query,
+		Collection: collection,
+		Strategy:   strategy,
+		Latency:    time.Since(start),
+		Results:    results,
+	})
+	if err != nil {
+		return ""
+	}
+
+	now := time.Now()
+	last := m.lastPrune.Load()
+	if now.UnixNano()-last >= int64(queryLogPruneInterval) && m.lastPrune.CompareAndSwap(last, now.UnixNano()) {
+		m.store.PruneQueryLog(now.Add(-m.cfg.QueryLogRetention))
+	}
+
+	return id
+}
+
+// GetQueryLog 按ID获取查询日志（ID来自结果的QueryID字段）
+func (m *MMQ) GetQueryLog(id string) (*QueryLogEntry, error) {
+	entry, err := m.store.GetQueryLogEntry(id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := convertQueryLogEntry(*entry)
+	return &result, nil
+}
+
+// PruneQueryLog 删除超过保留期（Config.QueryLogRetention）的查询日志
+func (m *MMQ) PruneQueryLog() (int, error) {
+	return m.store.PruneQueryLog(time.Now().Add(-m.cfg.QueryLogRetention))
+}
+
+// convertQueryLogEntry 转换store.QueryLogEntry到mmq.QueryLogEntry
+func convertQueryLogEntry(e store.QueryLogEntry) QueryLogEntry {
+	entry := QueryLogEntry{
+		ID:         e.ID,
+		Timestamp:  e.Timestamp,
+		Kind:       e.Kind,
+		Query:      e.Query,
+		Collection: e.Collection,
+		Strategy:   e.Strategy,
+		Latency:    e.Latency,
+		Results:    make([]QueryLogResult, len(e.Results)),
+	}
+	for i, r := range e.Results {
+		entry.Results[i] = QueryLogResult{ID: r.ID, Path: r.Path, Score: r.Score}
+	}
+	return entry
+}
+
+// QueryAnalytics 基于查询日志统计热门查询、无结果查询、慢查询和从未被检索到的文档
+func (m *MMQ) QueryAnalytics(opts AnalyticsOptions) (*QueryAnalytics, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = defaultAnalyticsLimit
+	}
+	if opts.SlowThreshold <= 0 {
+		opts.SlowThreshold = defaultSlowThreshold
+	}
+	window := opts.Since
+	if window <= 0 {
+		window = m.cfg.QueryLogRetention
+	}
+
+	report := &QueryAnalytics{
+		Since:  time.Now().Add(-window).UTC().Truncate(time.Second),
+		ByKind: make(map[string]int),
+	}
+
+	entries, err := m.store.ListQueryLog(report.Since)
+	if err != nil {
+		return nil, err
+	}
+	report.TotalQueries = len(entries)
+
+	stats := make(map[string]*QueryStat)
+	var order []string
+	var latencies []time.Duration
+	var totalLatency time.Duration
+	retrieved := make(map[string]bool)
+
+	for _, e := range entries {
+		report.ByKind[e.Kind]++
+		latencies = append(latencies, e.Latency)
+		totalLatency += e.Latency
+		if len(e.Results) == 0 {
+			report.ZeroResultCount++
+		}
+		for _, r := range e.Results {
+			retrieved[r.Path] = true
+		}
+
+		key := normalizeQuery(e.Query)
+		st, ok := stats[key]
+		if !ok {
+			st = &QueryStat{Query: key}
+			stats[key] = st
+			order = append(order, key)
+		}
+		st.Count++
+		if len(e.Results) == 0 {
+			st.ZeroResults++
+		}
+		st.AvgResults += float64(len(e.Results))
+		st.AvgLatency += e.Latency
+		st.LastSeen = e.Timestamp
+
+		if e.Latency >= opts.SlowThreshold {
+			report.SlowQueries = append(report.SlowQueries, convertQueryLogEntry(e))
+		}
+	}
+
+	if len(entries) > 0 {
+		report.AvgLatency = totalLatency / time.Duration(len(entries))
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		report.P95Latency = latencies[(len(latencies)*95+99)/100-1]
+	}
+
+	var all []QueryStat
+	for _, key := range order {
+		st := stats[key]
+		st.AvgResults /= float64(st.Count)
+		st.AvgLatency /= time.Duration(st.Count)
+		all = append(all, *st)
+	}
+
+	// 热门查询：按次数，次数相同时最近的优先
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Count != all[j].Count {
+			return all[i].Count > all[j].Count
+		}
+		return all[i].LastSeen.After(all[j].LastSeen)
+	})
+	report.TopQueries = limitStats(all, opts.Limit)
+
+	// 无结果查询：每次都没有返回结果
+	var zero []QueryStat
+	for _, st := range all {
+		if st.ZeroResults == st.Count {
+			zero = append(zero, st)
+		}
+	}
+	report.ZeroResults = limitStats(zero, opts.Limit)
+
+	sort.SliceStable(report.SlowQueries, func(i, j int) bool {
+		return report.SlowQueries[i].Latency > report.SlowQueries[j].Latency
+	})
+	if len(report.SlowQueries) > opts.Limit {
+		report.SlowQueries = report.SlowQueries[:opts.Limit]
+	}
+
+	// 从未被检索到的文档
+	docs, err := m.store.ListDocumentsByPath("", "")
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range docs {
+		path := d.Collection + "/" + d.Path
+		if retrieved[path] {
+			continue
+		}
+		report.NeverRetrievedCount++
+		if len(report.NeverRetrieved) < opts.Limit {
+			report.NeverRetrieved = append(report.NeverRetrieved, path)
+		}
+	}
+
+	return report, nil
+}
+
+// normalizeQuery 统计时忽略大小写和多余空白
+func normalizeQuery(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
+
+// limitStats 截取前limit条
+func limitStats(stats []QueryStat, limit int) []QueryStat {
+	if len(stats) > limit {
+		return stats[:limit]
+	}
+	return stats
+}
@@ -0,0 +1,136 @@
+package mmq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+func TestQueryLogAllKinds(t *testing.T) {
+	m := newRankingTestMMQ(t)
+	m.SetQueryLogging(true)
+
+	indexDocs(t, m,
+		Document{Collection: "kb", Path: "a.md", Title: "A", Content: "Kubernetes deployment guide."},
+		Document{Collection: "kb", Path: "b.md", Title: "B", Content: "Gardening notes for spring."},
+	)
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+
+	search, err := m.Search("kubernetes", SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vector, err := m.VectorSearch("kubernetes", SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hybrid, err := m.HybridSearch("kubernetes", SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contexts, err := m.RetrieveContext("kubernetes", RetrieveOptions{Limit: 5, Strategy: StrategyFTS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auto, err := m.RetrieveContext("kubernetes", RetrieveOptions{Limit: 5, Strategy: StrategyAuto})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		queryID  string
+		kind     string
+		strategy string
+		count    int
+	}{
+		{search[0].QueryID, QueryKindSearch, "fts", len(search)},
+		{vector[0].QueryID, QueryKindVector, "vector", len(vector)},
+		{hybrid[0].QueryID, QueryKindHybrid, "hybrid", len(hybrid)},
+		{contexts[0].QueryID, QueryKindRetrieve, "fts", len(contexts)},
+		{auto[0].QueryID, QueryKindRetrieve, "fts", len(auto)},
+	}
+	for _, c := range cases {
+		entry, err := m.GetQueryLog(c.queryID)
+		if err != nil {
+			t.Fatalf("%s: %v", c.kind, err)
+		}
+		if entry.Kind != c.kind || entry.Strategy != c.strategy || len(entry.Results) != c.count {
+			t.Errorf("Expected %s/%s with %d results, got %s/%s with %d",
+				c.kind, c.strategy, c.count, entry.Kind, entry.Strategy, len(entry.Results))
+		}
+		for _, r := range entry.Results {
+			if r.ID == "" || r.Path == "" {
+				t.Errorf("%s: expected result ID and path, got %+v", c.kind, r)
+			}
+		}
+	}
+
+	// 混合搜索结果同样带有内容哈希ID
+	if hybrid[0].ID == "" {
+		t.Error("Expected hybrid results to carry content hash IDs")
+	}
+}
+
+func TestQueryAnalytics(t *testing.T) {
+	m := newRankingTestMMQ(t)
+	m.SetQueryLogging(true)
+
+	indexDocs(t, m,
+		Document{Collection: "kb", Path: "deploy.md", Title: "Deploy", Content: "Kubernetes deployment guide."},
+		Document{Collection: "kb", Path: "backup.md", Title: "Backup", Content: "Database backup procedure."},
+		Document{Collection: "kb", Path: "orphan.md", Title: "Orphan", Content: "Nobody searches for this page."},
+	)
+
+	for _, q := range []string{"kubernetes", "Kubernetes", "kubernetes  ", "backup", "quantum entanglement", "quantum entanglement"} {
+		if _, err := m.Search(q, SearchOptions{Limit: 5}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 构造一条慢查询
+	if _, err := m.GetBackend().LogQuery(store.QueryLogEntry{
+		Kind:    QueryKindHybrid,
+		Query:   "slow query",
+		Latency: 2 * time.Second,
+		Results: []store.QueryLogResult{{Path: "kb/backup.md", Score: 0.5}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := m.QueryAnalytics(AnalyticsOptions{Since: time.Hour, Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("total=%d zero=%d avg=%v p95=%v by_kind=%v", report.TotalQueries, report.ZeroResultCount,
+		report.AvgLatency, report.P95Latency, report.ByKind)
+
+	if report.TotalQueries != 7 {
+		t.Errorf("Expected 7 queries, got %d", report.TotalQueries)
+	}
+	if report.ByKind[QueryKindSearch] != 6 || report.ByKind[QueryKindHybrid] != 1 {
+		t.Errorf("Unexpected kind counts %v", report.ByKind)
+	}
+
+	// 大小写和空白不同的查询合并统计
+	if len(report.TopQueries) == 0 || report.TopQueries[0].Query != "kubernetes" || report.TopQueries[0].Count != 3 {
+		t.Errorf("Expected kubernetes as top query with 3 hits, got %+v", report.TopQueries)
+	}
+
+	if report.ZeroResultCount != 2 || len(report.ZeroResults) != 1 || report.ZeroResults[0].Query != "quantum entanglement" {
+		t.Errorf("Expected one zero-result query asked twice, got %d %+v", report.ZeroResultCount, report.ZeroResults)
+	}
+
+	if len(report.SlowQueries) != 1 || report.SlowQueries[0].Query != "slow query" {
+		t.Errorf("Expected the slow query, got %+v", report.SlowQueries)
+	}
+	if report.P95Latency != 2*time.Second {
+		t.Errorf("Expected p95 latency to include the slow query, got %v", report.P95Latency)
+	}
+
+	if report.NeverRetrievedCount != 1 || len(report.NeverRetrieved) != 1 || report.NeverRetrieved[0] != "kb/orphan.md" {
+		t.Errorf("Expected orphan.md never retrieved, got %d %v", report.NeverRetrievedCount, report.NeverRetrieved)
+	}
+}
@@ -0,0 +1,330 @@
+package rag
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// minContextTokens 截断后正文至少保留的token数，低于此值的上下文直接丢弃
+	minContextTokens = 16
+
+	// fullText 分配值：完整放入正文
+	fullText = -1
+
+	// ellipsis 截断标记
+	ellipsis = "..."
+)
+
+// buildGreedy 按顺序完整放入上下文，放不下时截断当前上下文填满剩余预算
+func (cb *ContextBuilder) buildGreedy(contexts []Context) BuildResult {
+	alloc := make([]int, len(contexts))
+	sepTokens := cb.tokenizer.Count(cb.separator)
+
+	used, included := 0, 0
+	rendered := make(map[string]bool)
+	for i, ctx := range contexts {
+		ctx = withUnrendered(ctx, rendered)
+
+		cost := 0
+		if included > 0 {
+			cost = sepTokens
+		}
+
+		tokens := cb.tokenizer.Count(cb.formatContext(ctx, included+1))
+		if used+cost+tokens <= cb.maxTokens {
+			alloc[i] = fullText
+			used += cost + tokens
+			included++
+			for _, pc := range ctx.PathContexts {
+				rendered[pc.Path] = true
+			}
+			continue
+		}
+
+		// 预算不足：截断当前上下文，之后的上下文全部丢弃
+		remaining := cb.maxTokens - used - cost - cb.overhead(ctx, included+1)
+		if remaining >= minContextTokens {
+			alloc[i] = remaining
+		}
+		break
+	}
+
+	// 溢出时优先缩减最后放入的上下文
+	return cb.fit(contexts, alloc, func(a, b int) bool { return a > b })
+}
+
+// buildProportional 按相关性比例分配预算
+// 相关性高的上下文优先入选；预算按相关性加权分配，完整放入后剩余的预算再分给其他上下文
+func (cb *ContextBuilder) buildProportional(contexts []Context) BuildResult {
+	n := len(contexts)
+	alloc := make([]int, n)
+	sepTokens := cb.tokenizer.Count(cb.separator)
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return contexts[order[a]].Relevance > contexts[order[b]].Relevance
+	})
+
+	needs := make([]int, n)
+	overheads := make([]int, n)
+	for i, ctx := range contexts {
+		needs[i] = cb.tokenizer.Count(ctx.Text)
+		overheads[i] = cb.overhead(ctx, i+1) + sepTokens
+	}
+
+	excluded := make([]bool, n)
+	for {
+		// 按相关性选择放得下格式开销和最小正文的上下文
+		var selected []int
+		fixed := -sepTokens
+		for _, i := range order {
+			if excluded[i] {
+				continue
+			}
+			if fixed+overheads[i]+minContextTokens*(len(selected)+1) > cb.maxTokens {
+				continue
+			}
+			selected = append(selected, i)
+			fixed += overheads[i]
+		}
+		if len(selected) == 0 {
+			break
+		}
+
+		for i := range alloc {
+			alloc[i] = 0
+		}
+		waterFill(selected, contexts, needs, alloc, cb.maxTokens-fixed)
+
+		// 分配过少的上下文丢弃后重新分配
+		retry := false
+		for _, i := range selected {
+			if alloc[i] != fullText && alloc[i] < minContextTokens {
+				excluded[i] = true
+				retry = true
+			}
+		}
+		if !retry {
+			break
+		}
+	}
+
+	// 溢出时优先缩减相关性最低的上下文
+	rank := make([]int, n)
+	for pos, i := range order {
+		rank[i] = pos
+	}
+	return cb.fit(contexts, alloc, func(a, b int) bool { return rank[a] > rank[b] })
+}
+
+// waterFill 按相关性加权分配available个token
+// 需求小于份额的上下文完整放入，节省的预算继续分给其余上下文
+func waterFill(selected []int, contexts []Context, needs, alloc []int, available int) {
+	remaining := append([]int(nil), selected...)
+
+	for len(remaining) > 0 && available > 0 {
+		totalWeight := 0.0
+		for _, i := range remaining {
+			totalWeight += relevanceWeight(contexts[i])
+		}
+
+		var unsatisfied []int
+		for _, i := range remaining {
+			share := float64(available) * relevanceWeight(contexts[i]) / totalWeight
+			if float64(needs[i]) <= share {
+				alloc[i] = fullText
+				available -= needs[i]
+			} else {
+				unsatisfied = append(unsatisfied, i)
+			}
+		}
+
+		if len(unsatisfied) == len(remaining) {
+			for _, i := range unsatisfied {
+				alloc[i] = int(float64(available) * relevanceWeight(contexts[i]) / totalWeight)
+			}
+			return
+		}
+		remaining = unsatisfied
+	}
+}
+
+// relevanceWeight 分配权重（避免零权重）
+func relevanceWeight(ctx Context) float64 {
+	if ctx.Relevance < 0.01 {
+		return 0.01
+	}
+	return ctx.Relevance
+}
+
+// fit 按分配值组装文本；分词不可加导致溢出时，缩减lowerPriority排序下优先级最低的上下文
+func (cb *ContextBuilder) fit(contexts []Context, alloc []int, lowerPriority func(a, b int) bool) BuildResult {
+	for attempt := 0; attempt < len(contexts)+4; attempt++ {
+		result := cb.assemble(contexts, alloc)
+
+		overflow := cb.tokenizer.Count(result.Text) - cb.maxTokens
+		if overflow <= 0 {
+			return result
+		}
+
+		victim := -1
+		for i := range alloc {
+			if alloc[i] != 0 && (victim == -1 || lowerPriority(i, victim)) {
+				victim = i
+			}
+		}
+		if victim == -1 {
+			return result
+		}
+
+		current := result.Contexts[victim].Tokens
+		if alloc[victim] > 0 && alloc[victim] < current {
+			current = alloc[victim]
+		}
+		if next := current - overflow - 1; next >= minContextTokens {
+			alloc[victim] = next
+		} else {
+			alloc[victim] = 0
+		}
+	}
+
+	return cb.assemble(contexts, alloc)
+}
+
+// assemble 按分配值截断并格式化上下文
+func (cb *ContextBuilder) assemble(contexts []Context, alloc []int) BuildResult {
+	result := BuildResult{MaxTokens: cb.maxTokens, Contexts: make([]ContextUsage, len(contexts))}
+
+	var parts []string
+	rendered := make(map[string]bool) // 已渲染的路径上下文，每条只渲染一次
+	for i, ctx := range contexts {
+		usage := &result.Contexts[i]
+		usage.Source = ctx.Source
+		usage.OriginalTokens = cb.tokenizer.Count(ctx.Text)
+
+		if alloc[i] == 0 {
+			usage.Dropped = true
+			continue
+		}
+
+		if alloc[i] > 0 {
+			ctx.Text, usage.Truncated = cb.truncateToTokens(ctx.Text, alloc[i])
+		}
+		usage.Tokens = cb.tokenizer.Count(ctx.Text)
+
+		ctx = withUnrendered(ctx, rendered)
+		for _, pc := range ctx.PathContexts {
+			rendered[pc.Path] = true
+		}
+
+		parts = append(parts, cb.formatContext(ctx, len(parts)+1))
+	}
+
+	result.Text = strings.Join(parts, cb.separator)
+	return result
+}
+
+// withUnrendered 只保留尚未渲染过的路径上下文
+func withUnrendered(ctx Context, rendered map[string]bool) Context {
+	var chain []PathContext
+	for _, pc := range ctx.PathContexts {
+		if !rendered[pc.Path] {
+			chain = append(chain, pc)
+		}
+	}
+	ctx.PathContexts = chain
+	return ctx
+}
+
+// overhead 上下文格式（标题、来源、分数等）占用的token数
+func (cb *ContextBuilder) overhead(ctx Context, index int) int {
+	ctx.Text = ""
+	return cb.tokenizer.Count(cb.formatContext(ctx, index))
+}
+
+// truncateToTokens 将文本截断到maxTokens以内
+// 优先保留完整句子；第一句就放不下时在单词边界截断
+func (cb *ContextBuilder) truncateToTokens(text string, maxTokens int) (string, bool) {
+	if cb.tokenizer.Count(text) <= maxTokens {
+		return text, false
+	}
+
+	budget := maxTokens - cb.tokenizer.Count(" "+ellipsis)
+	if budget <= 0 {
+		return "", text != ""
+	}
+
+	// 二分查找能放下的最多句子数
+	sentences := splitSentences(text)
+	lo, hi := 0, len(sentences)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if cb.tokenizer.Count(strings.Join(sentences[:mid], "")) <= budget {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	if lo > 0 {
+		return strings.TrimRightFunc(strings.Join(sentences[:lo], ""), unicode.IsSpace) + " " + ellipsis, true
+	}
+
+	// 二分查找能放下的最长前缀
+	runes := []rune(text)
+	lo, hi = 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if cb.tokenizer.Count(string(runes[:mid])) <= budget {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+
+	prefix := string(runes[:lo])
+	if idx := strings.LastIndexFunc(prefix, unicode.IsSpace); idx > len(prefix)*4/5 {
+		prefix = prefix[:idx]
+	}
+
+	return strings.TrimRightFunc(prefix, unicode.IsSpace) + ellipsis, true
+}
+
+// splitSentences 按句子切分（保留标点和其后的空白，拼接后等于原文）
+func splitSentences(text string) []string {
+	var sentences []string
+	runes := []rune(text)
+
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		end := false
+		switch r {
+		case '。', '！', '？', '；', '\n':
+			end = true
+		case '.', '!', '?', ';':
+			end = i+1 == len(runes) || unicode.IsSpace(runes[i+1])
+		}
+		if !end {
+			continue
+		}
+
+		// 句末空白归入当前句
+		for i+1 < len(runes) && unicode.IsSpace(runes[i+1]) {
+			i++
+		}
+		sentences = append(sentences, string(runes[start:i+1]))
+		start = i + 1
+	}
+
+	if start < len(runes) {
+		sentences = append(sentences, string(runes[start:]))
+	}
+
+	return sentences
+}
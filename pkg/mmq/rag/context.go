@@ -3,16 +3,20 @@ package rag
 import (
 	"fmt"
 	"strings"
+
+	"github.com/crosszan/modu/pkg/mmq/llm"
 )
 
 // ContextBuilder 上下文构建器
 type ContextBuilder struct {
-	maxTokens      int
-	includeSource  bool
-	includeScore   bool
-	separator      string
-	contextFormat  ContextFormat
-	tokenEstimator func(string) int
+	maxTokens     int
+	includeSource bool
+	includeScore  bool
+	separator     string
+	contextFormat ContextFormat
+	budget        BudgetStrategy
+	tokenizer     llm.Tokenizer
+	pathContext   bool
 }
 
 // ContextFormat 上下文格式
@@ -25,13 +29,42 @@ const (
 	FormatJSON     ContextFormat = "json"     // JSON格式
 )
 
+// BudgetStrategy token预算分配策略
+type BudgetStrategy string
+
+const (
+	BudgetGreedy       BudgetStrategy = "greedy"       // 按顺序完整放入，最后一个按句子截断填满剩余预算
+	BudgetProportional BudgetStrategy = "proportional" // 按相关性比例分配预算，各上下文按句子截断
+)
+
 // ContextBuilderOptions 构建器选项
 type ContextBuilderOptions struct {
-	MaxTokens     int           // 最大token数
-	IncludeSource bool          // 包含来源信息
-	IncludeScore  bool          // 包含相关性分数
-	Separator     string        // 上下文分隔符
-	Format        ContextFormat // 输出格式
+	MaxTokens     int            // 最大token数
+	IncludeSource bool           // 包含来源信息
+	IncludeScore  bool           // 包含相关性分数
+	Separator     string         // 上下文分隔符
+	Format        ContextFormat  // 输出格式
+	Budget        BudgetStrategy // 预算分配策略（默认greedy）
+	Tokenizer     llm.Tokenizer  // 分词器（nil时使用启发式估算）
+
+	OmitPathContext bool // 不渲染路径上下文链（默认每条路径上下文在输出中只渲染一次）
+}
+
+// ContextUsage 单个上下文的token使用情况
+type ContextUsage struct {
+	Source         string // 来源文档
+	OriginalTokens int    // 原文token数
+	Tokens         int    // 放入的正文token数
+	Truncated      bool   // 是否被截断
+	Dropped        bool   // 是否因预算不足被丢弃
+}
+
+// BuildResult 构建结果及token使用报告
+type BuildResult struct {
+	Text       string         // 构建的上下文文本
+	TokensUsed int            // 文本实际占用的token数（按分词器计数）
+	MaxTokens  int            // 预算
+	Contexts   []ContextUsage // 每个输入上下文的使用情况（与输入顺序一致）
 }
 
 // DefaultContextBuilderOptions 默认选项
@@ -42,47 +75,54 @@ func DefaultContextBuilderOptions() ContextBuilderOptions {
 		IncludeScore:  true,
 		Separator:     "\n\n---\n\n",
 		Format:        FormatMarkdown,
+		Budget:        BudgetGreedy,
 	}
 }
 
 // NewContextBuilder 创建上下文构建器
 func NewContextBuilder(opts ContextBuilderOptions) *ContextBuilder {
+	tokenizer := opts.Tokenizer
+	if tokenizer == nil {
+		tokenizer = llm.HeuristicTokenizer{}
+	}
+
+	budget := opts.Budget
+	if budget == "" {
+		budget = BudgetGreedy
+	}
+
 	return &ContextBuilder{
-		maxTokens:      opts.MaxTokens,
-		includeSource:  opts.IncludeSource,
-		includeScore:   opts.IncludeScore,
-		separator:      opts.Separator,
-		contextFormat:  opts.Format,
-		tokenEstimator: estimateTokens,
+		maxTokens:     opts.MaxTokens,
+		includeSource: opts.IncludeSource,
+		includeScore:  opts.IncludeScore,
+		separator:     opts.Separator,
+		contextFormat: opts.Format,
+		budget:        budget,
+		tokenizer:     tokenizer,
+		pathContext:   !opts.OmitPathContext,
 	}
 }
 
 // Build 构建上下文
 func (cb *ContextBuilder) Build(contexts []Context) string {
+	return cb.BuildWithUsage(contexts).Text
+}
+
+// BuildWithUsage 构建上下文并报告实际使用的token数
+func (cb *ContextBuilder) BuildWithUsage(contexts []Context) BuildResult {
+	result := BuildResult{MaxTokens: cb.maxTokens}
 	if len(contexts) == 0 {
-		return ""
+		return result
 	}
 
-	var parts []string
-	totalTokens := 0
-
-	for i, ctx := range contexts {
-		// 格式化单个上下文
-		formatted := cb.formatContext(ctx, i+1)
-
-		// 估算token数
-		tokens := cb.tokenEstimator(formatted)
-
-		// 检查是否超出限制
-		if totalTokens+tokens > cb.maxTokens {
-			break
-		}
-
-		parts = append(parts, formatted)
-		totalTokens += tokens
+	if cb.budget == BudgetProportional {
+		result = cb.buildProportional(contexts)
+	} else {
+		result = cb.buildGreedy(contexts)
 	}
 
-	return strings.Join(parts, cb.separator)
+	result.TokensUsed = cb.tokenizer.Count(result.Text)
+	return result
 }
 
 // formatContext 格式化单个上下文
@@ -111,6 +151,10 @@ func (cb *ContextBuilder) formatPlain(ctx Context, index int) string {
 		parts = append(parts, fmt.Sprintf("Relevance: %.0f%%", ctx.Relevance*100))
 	}
 
+	for _, pc := range cb.pathContexts(ctx) {
+		parts = append(parts, fmt.Sprintf("Context (%s): %s", pc.Path, pc.Content))
+	}
+
 	parts = append(parts, ctx.Text)
 
 	return strings.Join(parts, "\n")
@@ -139,6 +183,15 @@ func (cb *ContextBuilder) formatMarkdown(ctx Context, index int) string {
 		builder.WriteString("\n")
 	}
 
+	// 路径上下文
+	if chain := cb.pathContexts(ctx); len(chain) > 0 {
+		builder.WriteString("**Context:**\n")
+		for _, pc := range chain {
+			builder.WriteString(fmt.Sprintf("- `%s`: %s\n", pc.Path, pc.Content))
+		}
+		builder.WriteString("\n")
+	}
+
 	// 内容
 	builder.WriteString(ctx.Text)
 
@@ -159,6 +212,10 @@ func (cb *ContextBuilder) formatXML(ctx Context, index int) string {
 		builder.WriteString(fmt.Sprintf("  <relevance>%.4f</relevance>\n", ctx.Relevance))
 	}
 
+	for _, pc := range cb.pathContexts(ctx) {
+		builder.WriteString(fmt.Sprintf("  <path_context path=\"%s\">%s</path_context>\n", escapeXML(pc.Path), escapeXML(pc.Content)))
+	}
+
 	builder.WriteString(fmt.Sprintf("  <text>%s</text>\n", escapeXML(ctx.Text)))
 	builder.WriteString("</context>")
 
@@ -180,16 +237,26 @@ func (cb *ContextBuilder) formatJSON(ctx Context, index int) string {
 		builder.WriteString(fmt.Sprintf("  \"relevance\": %.4f,\n", ctx.Relevance))
 	}
 
+	if chain := cb.pathContexts(ctx); len(chain) > 0 {
+		items := make([]string, len(chain))
+		for i, pc := range chain {
+			items[i] = fmt.Sprintf("{\"path\": \"%s\", \"content\": \"%s\"}", escapeJSON(pc.Path), escapeJSON(pc.Content))
+		}
+		builder.WriteString(fmt.Sprintf("  \"path_contexts\": [%s],\n", strings.Join(items, ", ")))
+	}
+
 	builder.WriteString(fmt.Sprintf("  \"text\": \"%s\"\n", escapeJSON(ctx.Text)))
 	builder.WriteString("}")
 
 	return builder.String()
 }
 
-// estimateTokens 估算token数（简化版本）
-func estimateTokens(text string) int {
-	// 简化估算：4个字符 ≈ 1个token
-	return len(text) / 4
+// pathContexts 需要渲染的路径上下文
+func (cb *ContextBuilder) pathContexts(ctx Context) []PathContext {
+	if !cb.pathContext {
+		return nil
+	}
+	return ctx.PathContexts
 }
 
 // escapeXML 转义XML特殊字符
@@ -236,28 +303,10 @@ func (cb *ContextBuilder) BuildPrompt(query string, contexts []Context, systemPr
 	return builder.String()
 }
 
-// TruncateContext 截断上下文以适应token限制
+// TruncateContext 截断上下文以适应token限制（优先在句子边界截断）
 func (cb *ContextBuilder) TruncateContext(text string, maxTokens int) string {
-	tokens := cb.tokenEstimator(text)
-
-	if tokens <= maxTokens {
-		return text
-	}
-
-	// 简单截断（按字符）
-	maxChars := maxTokens * 4
-	if len(text) <= maxChars {
-		return text
-	}
-
-	// 在单词边界截断
-	truncated := text[:maxChars]
-	lastSpace := strings.LastIndex(truncated, " ")
-	if lastSpace > maxChars-100 {
-		truncated = truncated[:lastSpace]
-	}
-
-	return truncated + "..."
+	truncated, _ := cb.truncateToTokens(text, maxTokens)
+	return truncated
 }
 
 // MergeContexts 合并多个上下文
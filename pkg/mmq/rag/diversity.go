@@ -0,0 +1,270 @@
+package rag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/crosszan/modu/pkg/mmq/internal/vectordb"
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+// diversityEnabled 是否需要多样性重选
+func (opts RetrieveOptions) diversityEnabled() bool {
+	return opts.MMRLambda > 0 || opts.MaxPerDocument > 0 || opts.DedupThreshold > 0
+}
+
+// languageFetchFactor 偏好语言时候选池的额外倍数（其他语言的结果会被降权）
+const languageFetchFactor = 5
+
+// candidateLimit 候选结果数量（启用多样性选择或偏好语言时扩大候选池）
+func (opts RetrieveOptions) candidateLimit() int {
+	limit := opts.Limit * 2
+	if opts.diversityEnabled() {
+		limit = opts.Limit * 4
+	}
+	if opts.PreferLanguage != "" {
+		limit *= languageFetchFactor
+	}
+	return limit
+}
+
+// candidate 多样性选择的候选：文档的一个块
+type candidate struct {
+	result store.SearchResult // Content为块文本，Score为块得分
+	key    string             // 块标识（内容哈希#块序号），内容相同的块标识相同
+	vector []float32          // 块向量，未生成向量时为nil
+	words  map[string]bool    // 块文本的小写词集合（按需计算）
+}
+
+// diversify 将结果展开为块级候选，按顺序执行近重复抑制、每文档数量上限和MMR重选
+//
+// 已生成向量的文档按存储的块展开，每块的得分为文档得分乘以该块与最相关块的查询相似度之比；
+// 尚未生成向量的文档作为一个整体候选。相似度优先使用块向量的余弦相似度，
+// 没有向量时退化为词集合的Jaccard相似度。
+// 每文档数量上限按集合和路径统计块数；不同集合中内容相同的文件由近重复抑制合并。
+func (r *Retriever) diversify(query string, results []store.SearchResult, opts RetrieveOptions) []store.SearchResult {
+	if len(results) == 0 {
+		return results
+	}
+
+	candidates := r.expandChunks(query, results)
+
+	// 1. 近重复抑制：候选已按分数降序，保留每组中得分最高的一条
+	if opts.DedupThreshold > 0 {
+		kept := make([]*candidate, 0, len(candidates))
+		for _, c := range candidates {
+			duplicate := false
+			for _, k := range kept {
+				if similarityBetween(c, k) >= opts.DedupThreshold {
+					duplicate = true
+					break
+				}
+			}
+			if !duplicate {
+				kept = append(kept, c)
+			}
+		}
+		candidates = kept
+	}
+
+	// 2. 每文档数量上限
+	if opts.MaxPerDocument > 0 {
+		counts := make(map[string]int)
+		capped := make([]*candidate, 0, len(candidates))
+		for _, c := range candidates {
+			key := documentKey(c.result)
+			if counts[key] >= opts.MaxPerDocument {
+				continue
+			}
+			counts[key]++
+			capped = append(capped, c)
+		}
+		candidates = capped
+	}
+
+	// 3. MMR：score = λ·相关性 - (1-λ)·与已选结果的最大相似度
+	if opts.MMRLambda > 0 && opts.MMRLambda < 1 {
+		candidates = maximalMarginalRelevance(candidates, opts.MMRLambda, opts.Limit)
+	}
+
+	diversified := make([]store.SearchResult, len(candidates))
+	for i, c := range candidates {
+		diversified[i] = c.result
+	}
+	return diversified
+}
+
+// expandChunks 将文档级结果展开为按得分降序的块级候选
+func (r *Retriever) expandChunks(query string, results []store.SearchResult) []*candidate {
+	var queryVec []float32
+	queried := false
+
+	var candidates []*candidate
+	for _, res := range results {
+		var chunks []store.ChunkEmbedding
+		if res.ID != "" {
+			chunks, _ = r.store.GetChunkEmbeddings(res.ID)
+		}
+		if len(chunks) == 0 {
+			key := res.ID
+			if key == "" {
+				key = documentKey(res)
+			}
+			candidates = append(candidates, &candidate{result: res, key: key})
+			continue
+		}
+
+		if !queried && r.embedding != nil {
+			queryVec, _ = r.embedding.Generate(query, true)
+			queried = true
+		}
+
+		// 块与查询的相似度，得分按最相关块归一化
+		sims := make([]float64, len(chunks))
+		best := 0.0
+		for i, chunk := range chunks {
+			if queryVec == nil {
+				continue
+			}
+			if v, err := vectordb.CosineSim(queryVec, chunk.Embedding); err == nil && v > 0 {
+				sims[i] = v
+			}
+			if sims[i] > best {
+				best = sims[i]
+			}
+		}
+
+		for i, chunk := range chunks {
+			c := &candidate{
+				result: res,
+				key:    fmt.Sprintf("%s#%d", res.ID, chunk.Seq),
+				vector: chunk.Embedding,
+			}
+			c.result.Content = chunkText(res.Content, chunks, i)
+			if best > 0 {
+				c.result.Score = res.Score * sims[i] / best
+			}
+			candidates = append(candidates, c)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].result.Score > candidates[j].result.Score
+	})
+	return candidates
+}
+
+// chunkText 第i块的文本：从块位置到下一块的起始位置（不含与下一块重叠的部分）
+func chunkText(content string, chunks []store.ChunkEmbedding, i int) string {
+	start := chunks[i].Pos
+	if start < 0 || start > len(content) {
+		return content
+	}
+	end := len(content)
+	if i+1 < len(chunks) && chunks[i+1].Pos > start && chunks[i+1].Pos < end {
+		end = chunks[i+1].Pos
+	}
+	return content[start:end]
+}
+
+// maximalMarginalRelevance 贪心选择兼顾相关性和多样性的候选
+// 相关性按候选集中的最高分归一化，使不同检索策略的分数可与相似度比较
+func maximalMarginalRelevance(candidates []*candidate, lambda float64, limit int) []*candidate {
+	if limit <= 0 || limit > len(candidates) {
+		limit = len(candidates)
+	}
+
+	maxScore := 0.0
+	for _, c := range candidates {
+		if c.result.Score > maxScore {
+			maxScore = c.result.Score
+		}
+	}
+	if maxScore <= 0 {
+		maxScore = 1
+	}
+
+	remaining := append([]*candidate(nil), candidates...)
+	selected := make([]*candidate, 0, limit)
+
+	for len(selected) < limit && len(remaining) > 0 {
+		bestIdx := 0
+		bestScore := 0.0
+
+		for i, c := range remaining {
+			maxSim := 0.0
+			for _, s := range selected {
+				if v := similarityBetween(c, s); v > maxSim {
+					maxSim = v
+				}
+			}
+
+			score := lambda*(c.result.Score/maxScore) - (1-lambda)*maxSim
+			if i == 0 || score > bestScore {
+				bestIdx, bestScore = i, score
+			}
+		}
+
+		selected = append(selected, remaining[bestIdx])
+		remaining = append(remaining[:bestIdx], remaining[bestIdx+1:]...)
+	}
+
+	return selected
+}
+
+// documentKey 结果所属文档（集合/路径）
+func documentKey(res store.SearchResult) string {
+	return res.Collection + "/" + res.Path
+}
+
+// similarityBetween 返回两个候选的相似度 [0,1]
+func similarityBetween(a, b *candidate) float64 {
+	if a.key == b.key {
+		return 1.0
+	}
+
+	if a.vector != nil && b.vector != nil {
+		if v, err := vectordb.CosineSim(a.vector, b.vector); err == nil {
+			if v < 0 {
+				v = 0
+			}
+			return v
+		}
+	}
+
+	return jaccard(a.wordSet(), b.wordSet())
+}
+
+// wordSet 候选文本的小写词集合
+func (c *candidate) wordSet() map[string]bool {
+	if c.words != nil {
+		return c.words
+	}
+
+	c.words = make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(c.result.Content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		c.words[w] = true
+	}
+	return c.words
+}
+
+// jaccard 两个集合的Jaccard相似度
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+
+	intersection := 0
+	for w := range a {
+		if b[w] {
+			intersection++
+		}
+	}
+
+	union := len(a) + len(b) - intersection
+	return float64(intersection) / float64(union)
+}
@@ -2,6 +2,7 @@ package rag
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/crosszan/modu/pkg/mmq/llm"
 	"github.com/crosszan/modu/pkg/mmq/store"
@@ -9,13 +10,13 @@ import (
 
 // Retriever RAG检索器
 type Retriever struct {
-	store     *store.Store
+	store     store.Backend
 	llm       llm.LLM
 	embedding *llm.EmbeddingGenerator
 }
 
 // NewRetriever 创建检索器
-func NewRetriever(st *store.Store, llmImpl llm.LLM, embGen *llm.EmbeddingGenerator) *Retriever {
+func NewRetriever(st store.Backend, llmImpl llm.LLM, embGen *llm.EmbeddingGenerator) *Retriever {
 	return &Retriever{
 		store:     st,
 		llm:       llmImpl,
@@ -34,13 +35,30 @@ const (
 
 // RetrieveOptions 检索选项
 type RetrieveOptions struct {
-	Limit      int               // 返回结果数量
-	MinScore   float64           // 最小分数阈值
-	Collection string            // 集合过滤
-	Strategy   RetrievalStrategy // 检索策略
-	Rerank     bool              // 是否重排序
-	RRFWeights []float64         // RRF权重
-	RRFK       int               // RRF参数K
+	Limit      int                   // 返回结果数量
+	MinScore   float64               // 最小分数阈值
+	Collection string                // 集合过滤
+	Strategy   RetrievalStrategy     // 检索策略
+	Rerank     bool                  // 是否重排序
+	RRFWeights []float64             // RRF权重
+	RRFK       int                   // RRF参数K
+	Profile    *store.RankingProfile // 排序配置（字段权重、RRF、时间衰减、集合加权），nil表示默认排序
+
+	// 多样性选择（展开为块级候选，在构建上下文之前应用）
+	MMRLambda      float64 // MMR相关性权重 (0,1)，越小越偏向多样性，0表示不启用
+	MaxPerDocument int     // 每个文档最多返回的块数，0表示不限制
+	DedupThreshold float64 // 近重复相似度阈值 (0,1]，达到阈值只保留得分最高的一条，0表示不启用
+
+	FirstPassRouting bool // 自适应检索时先执行一次BM25检索，按分数分布修正路由
+
+	Fields         map[string]string // 结构化字段过滤（行记录字段全部匹配），在检索查询中应用
+	Language       string            // 只检索该语言的文档，在检索查询中应用
+	PreferLanguage string            // 偏好的文档语言（如"zh"），其他语言的结果降权
+}
+
+// filter 检索查询的文档过滤条件
+func (opts RetrieveOptions) filter() store.DocumentFilter {
+	return store.DocumentFilter{Collection: opts.Collection, Fields: opts.Fields, Language: opts.Language}
 }
 
 // DefaultRetrieveOptions 默认检索选项
@@ -57,10 +75,17 @@ func DefaultRetrieveOptions() RetrieveOptions {
 
 // Context RAG上下文
 type Context struct {
-	Text      string                 // 文本内容
-	Source    string                 // 来源文档
-	Relevance float64                // 相关性分数
-	Metadata  map[string]interface{} // 元数据
+	Text         string                 // 文本内容
+	Source       string                 // 来源文档
+	Relevance    float64                // 相关性分数
+	Metadata     map[string]interface{} // 元数据
+	PathContexts []PathContext          // 继承的路径上下文链（从全局到具体）
+}
+
+// PathContext 路径上下文（全局 /、集合 qmd://collection、目录或文件）
+type PathContext struct {
+	Path    string
+	Content string
 }
 
 // Retrieve 执行检索
@@ -83,6 +108,28 @@ func (r *Retriever) Retrieve(query string, opts RetrieveOptions) ([]Context, err
 		return nil, err
 	}
 
+	// 应用时间衰减和集合加权
+	results = store.ApplyRankingBoosts(results, opts.Profile, time.Now())
+
+	// 路径上下文作为排序信号
+	if opts.Profile != nil && opts.Profile.PathContextWeight > 0 {
+		results, err = store.ApplyPathContextBoost(r.store, query, results, opts.Profile.PathContextWeight)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply path context boost: %w", err)
+		}
+	}
+
+	// 相关性反馈作为排序信号
+	if opts.Profile != nil && opts.Profile.FeedbackWeight > 0 {
+		results, err = store.ApplyFeedbackBoost(r.store, query, results, opts.Profile.FeedbackWeight, opts.Profile.FeedbackHalfLife, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply feedback boost: %w", err)
+		}
+	}
+
+	// 偏好语言
+	results = store.ApplyLanguagePreference(results, opts.PreferLanguage)
+
 	// 过滤低分结果
 	if opts.MinScore > 0 {
 		filtered := make([]store.SearchResult, 0, len(results))
@@ -102,18 +149,26 @@ func (r *Retriever) Retrieve(query string, opts RetrieveOptions) ([]Context, err
 		}
 	}
 
+	// 展开为块级候选后执行近重复抑制、每文档上限和MMR重选
+	if opts.diversityEnabled() {
+		results = r.diversify(query, results, opts)
+	}
+
 	// 限制结果数量
 	if len(results) > opts.Limit {
 		results = results[:opts.Limit]
 	}
 
 	// 转换为Context
-	return r.toContexts(results), nil
+	return r.toContexts(results)
 }
 
 // retrieveFTS BM25全文搜索
 func (r *Retriever) retrieveFTS(query string, opts RetrieveOptions) ([]store.SearchResult, error) {
-	return r.store.SearchFTS(query, opts.Limit*2, opts.Collection)
+	if opts.Profile != nil {
+		return r.store.SearchFTSWeighted(query, opts.candidateLimit(), opts.filter(), opts.Profile.FieldWeights)
+	}
+	return r.store.SearchFTS(query, opts.candidateLimit(), opts.filter())
 }
 
 // retrieveVector 向量语义搜索
@@ -124,7 +179,7 @@ func (r *Retriever) retrieveVector(query string, opts RetrieveOptions) ([]store.
 		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
 	}
 
-	return r.store.SearchVector(query, embedding, opts.Limit*2, opts.Collection)
+	return r.store.SearchVector(query, embedding, opts.candidateLimit(), opts.filter())
 }
 
 // retrieveHybrid 混合搜索
@@ -143,7 +198,16 @@ func (r *Retriever) retrieveHybrid(query string, opts RetrieveOptions) ([]store.
 
 	// 3. RRF融合
 	resultLists := [][]store.SearchResult{ftsResults, vecResults}
-	fused := store.ReciprocalRankFusion(resultLists, opts.RRFWeights, opts.RRFK)
+	weights, k := opts.RRFWeights, opts.RRFK
+	if opts.Profile != nil {
+		if len(opts.Profile.RRFWeights) > 0 {
+			weights = opts.Profile.RRFWeights
+		}
+		if opts.Profile.RRFK > 0 {
+			k = opts.Profile.RRFK
+		}
+	}
+	fused := store.ReciprocalRankFusion(resultLists, weights, k)
 
 	return fused, nil
 }
@@ -190,9 +254,10 @@ func (r *Retriever) rerank(query string, results []store.SearchResult) ([]store.
 	return reranked, nil
 }
 
-// toContexts 转换为Context
-func (r *Retriever) toContexts(results []store.SearchResult) []Context {
+// toContexts 转换为Context，并附带每个文档继承的路径上下文链
+func (r *Retriever) toContexts(results []store.SearchResult) ([]Context, error) {
 	contexts := make([]Context, len(results))
+	chains := make(map[string][]PathContext)
 
 	for i, res := range results {
 		contexts[i] = Context{
@@ -200,37 +265,61 @@ func (r *Retriever) toContexts(results []store.SearchResult) []Context {
 			Source:    fmt.Sprintf("%s/%s", res.Collection, res.Path),
 			Relevance: res.Score,
 			Metadata: map[string]interface{}{
+				"id":         res.ID,
 				"title":      res.Title,
 				"collection": res.Collection,
 				"path":       res.Path,
 				"snippet":    res.Snippet,
 				"source":     res.Source,
 				"timestamp":  res.Timestamp,
+				"language":   res.Language,
 			},
 		}
+
+		target := store.DocumentContextPath(res.Collection, res.Path)
+		chain, ok := chains[target]
+		if !ok {
+			entries, err := r.store.GetContextsForPath(target)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get path contexts: %w", err)
+			}
+			for _, e := range entries {
+				chain = append(chain, PathContext{Path: e.Path, Content: e.Content})
+			}
+			chains[target] = chain
+		}
+		contexts[i].PathContexts = chain
 	}
 
-	return contexts
+	return contexts, nil
 }
 
-// AdaptiveRetrieve 自适应检索（根据查询类型选择策略）
+// AdaptiveRetrieve 自适应检索（根据查询内容选择策略）
 func (r *Retriever) AdaptiveRetrieve(query string, opts RetrieveOptions) ([]Context, error) {
-	// 检测查询类型
-	queryType := detectQueryType(query)
+	contexts, _, err := r.RoutedRetrieve(query, opts)
+	return contexts, err
+}
 
-	switch queryType {
-	case QueryTypeKeyword:
-		// 关键词查询 -> BM25最优
-		opts.Strategy = StrategyFTS
-	case QueryTypeSemantic:
-		// 语义查询 -> 向量最优
-		opts.Strategy = StrategyVector
-	case QueryTypeComplex:
-		// 复杂查询 -> 混合最优
-		opts.Strategy = StrategyHybrid
+// RoutedRetrieve 自适应检索，同时返回路由决策
+// 每个上下文的元数据中记录 route_strategy 和 route_reasons
+func (r *Retriever) RoutedRetrieve(query string, opts RetrieveOptions) ([]Context, RoutingDecision, error) {
+	decision, err := r.Route(query, opts)
+	if err != nil {
+		return nil, decision, err
+	}
+
+	opts.Strategy = decision.Strategy
+	contexts, err := r.Retrieve(query, opts)
+	if err != nil {
+		return nil, decision, err
+	}
+
+	for i := range contexts {
+		contexts[i].Metadata["route_strategy"] = string(decision.Strategy)
+		contexts[i].Metadata["route_reasons"] = decision.Reasons
 	}
 
-	return r.Retrieve(query, opts)
+	return contexts, decision, nil
 }
 
 // QueryType 查询类型
@@ -242,39 +331,16 @@ const (
 	QueryTypeComplex                   // 复杂查询
 )
 
-// detectQueryType 检测查询类型
-func detectQueryType(query string) QueryType {
-	// 简化实现：基于查询长度和复杂度
-	words := len(splitWords(query))
-
-	if words <= 3 {
-		return QueryTypeKeyword
-	} else if words <= 8 {
-		return QueryTypeSemantic
-	} else {
-		return QueryTypeComplex
-	}
-}
-
-// splitWords 简单分词
-func splitWords(text string) []string {
-	var words []string
-	var word []rune
-
-	for _, r := range text {
-		if r == ' ' || r == '\n' || r == '\t' || r == ',' || r == '.' {
-			if len(word) > 0 {
-				words = append(words, string(word))
-				word = nil
-			}
-		} else {
-			word = append(word, r)
-		}
-	}
-
-	if len(word) > 0 {
-		words = append(words, string(word))
+// String 查询类型名称
+func (t QueryType) String() string {
+	switch t {
+	case QueryTypeKeyword:
+		return "keyword"
+	case QueryTypeSemantic:
+		return "semantic"
+	case QueryTypeComplex:
+		return "complex"
+	default:
+		return "unknown"
 	}
-
-	return words
 }
@@ -0,0 +1,262 @@
+package rag
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/crosszan/modu/pkg/mmq/internal/langdetect"
+)
+
+// QueryFeatures 查询特征（路由依据）
+type QueryFeatures struct {
+	Terms         int      // 词项数（拉丁词数 + CJK字符二元组数）
+	Language      string   // 主要语言（ISO 639-1，与文档语言检测一致），无法判断时为空
+	QuotedPhrases []string // 引号内的短语
+	CodeTokens    []string // 标识符、错误码、路径等代码类词项
+	Question      bool     // 是否为疑问句（疑问词或问号）
+}
+
+// FirstPassStats 首轮BM25检索的分数分布
+type FirstPassStats struct {
+	Hits     int     // 命中数
+	TopScore float64 // 最高分
+	Margin   float64 // 第一名领先第二名的相对幅度 (top-second)/top，仅一条命中时为1
+}
+
+// RoutingDecision 路由决策（可用于日志记录）
+type RoutingDecision struct {
+	Strategy  RetrievalStrategy // 选择的检索策略
+	QueryType QueryType         // 查询类型
+	Features  QueryFeatures     // 查询特征
+	FirstPass *FirstPassStats   // 首轮检索统计（未启用时为nil）
+	Reasons   []string          // 决策原因
+}
+
+// String 决策摘要
+func (d RoutingDecision) String() string {
+	return fmt.Sprintf("%s (%s)", d.Strategy, strings.Join(d.Reasons, "; "))
+}
+
+var (
+	// quotedPattern 匹配 "..."、“...”、「...」 中的短语
+	quotedPattern = regexp.MustCompile(`"([^"]+)"|“([^”]+)”|「([^」]+)」`)
+
+	// errorCodePattern 匹配 E1234、ERR-42、HTTP404、0x1F 等错误码
+	errorCodePattern = regexp.MustCompile(`^(?:[A-Z]{1,10}[-_]?[0-9]{2,}|0[xX][0-9a-fA-F]+)$`)
+
+	// camelCasePattern 匹配 camelCase / PascalCase 标识符
+	camelCasePattern = regexp.MustCompile(`^[A-Za-z][a-z0-9]+(?:[A-Z][a-z0-9]*)+$`)
+
+	// englishQuestionWords 英文疑问词（出现在句首时视为疑问句）
+	englishQuestionWords = map[string]bool{
+		"what": true, "why": true, "how": true, "when": true, "where": true,
+		"who": true, "which": true, "explain": true, "describe": true,
+		"does": true, "do": true, "is": true, "are": true, "can": true,
+		"should": true, "could": true, "would": true, "compare": true,
+	}
+
+	// chineseQuestionWords 中文疑问词
+	chineseQuestionWords = []string{
+		"什么", "为什么", "为何", "怎么", "怎样", "如何", "哪", "吗", "呢",
+		"是否", "能否", "可否", "区别", "多少", "谁",
+	}
+)
+
+// AnalyzeQuery 提取查询特征
+func AnalyzeQuery(query string) QueryFeatures {
+	var f QueryFeatures
+
+	// 引号短语
+	for _, m := range quotedPattern.FindAllStringSubmatch(query, -1) {
+		for _, g := range m[1:] {
+			if g = strings.TrimSpace(g); g != "" {
+				f.QuotedPhrases = append(f.QuotedPhrases, g)
+			}
+		}
+	}
+
+	// 语言：与索引时的文档语言检测相同（区分中文、日文、韩文和常见拉丁字母语言）
+	f.Language = langdetect.Detect(query)
+
+	cjk := 0
+	for _, r := range query {
+		if langdetect.IsCJK(r) {
+			cjk++
+		}
+	}
+
+	// 词项：拉丁词按空白切分，CJK按字符二元组计数
+	latinWords := 0
+	for i, token := range strings.Fields(query) {
+		trimmed := strings.Trim(token, "\"'“”「」,.;:!?，。；：！？()[]{}")
+		if trimmed == "" {
+			continue
+		}
+
+		if isCodeToken(trimmed) {
+			f.CodeTokens = append(f.CodeTokens, trimmed)
+		}
+
+		hasLatin := false
+		for _, r := range trimmed {
+			if unicode.IsLetter(r) && !unicode.Is(unicode.Han, r) || unicode.IsDigit(r) {
+				hasLatin = true
+				break
+			}
+		}
+		if hasLatin {
+			latinWords++
+		}
+
+		if i == 0 && englishQuestionWords[strings.ToLower(trimmed)] {
+			f.Question = true
+		}
+	}
+	cjkTerms := 0
+	if cjk > 0 {
+		cjkTerms = (cjk + 1) / 2
+	}
+	f.Terms = latinWords + cjkTerms
+
+	// 疑问：问号或中文疑问词
+	if strings.ContainsAny(query, "?？") {
+		f.Question = true
+	}
+	for _, w := range chineseQuestionWords {
+		if strings.Contains(query, w) {
+			f.Question = true
+			break
+		}
+	}
+
+	return f
+}
+
+// isCodeToken 判断词项是否像代码（标识符、错误码、路径、命令行参数）
+func isCodeToken(token string) bool {
+	if len(token) < 2 {
+		return false
+	}
+
+	switch {
+	case errorCodePattern.MatchString(token):
+		return true
+	case strings.HasPrefix(token, "--") || strings.HasPrefix(token, "-") && len(token) == 2:
+		return true
+	case strings.Contains(token, "()") || strings.Contains(token, "::") || strings.Contains(token, "->"):
+		return true
+	case camelCasePattern.MatchString(token):
+		return true
+	}
+
+	// snake_case、点号/斜杠连接的标识符（foo.bar、pkg/mmq、config.yaml）
+	if strings.ContainsAny(token, "_./") {
+		letters := 0
+		for _, r := range token {
+			if r > unicode.MaxASCII {
+				return false
+			}
+			if unicode.IsLetter(r) {
+				letters++
+			}
+		}
+		return letters > 0 && !strings.HasSuffix(token, ".") && !strings.HasPrefix(token, ".")
+	}
+
+	return false
+}
+
+// routeByFeatures 根据查询特征选择策略
+// 包含CJK文字的查询在双字词全文索引中检索，词面匹配与其他语言同样可靠，
+// 因此按相同规则路由（CJK词项数按双字词计）
+func routeByFeatures(f QueryFeatures) RoutingDecision {
+	d := RoutingDecision{Features: f}
+
+	exact := len(f.QuotedPhrases) > 0 || len(f.CodeTokens) > 0
+
+	switch {
+	case exact && f.Question:
+		// 既要精确匹配标识符，又要理解问题
+		d.Strategy, d.QueryType = StrategyHybrid, QueryTypeComplex
+		d.Reasons = append(d.Reasons, "question with exact tokens")
+	case exact:
+		d.Strategy, d.QueryType = StrategyFTS, QueryTypeKeyword
+		if len(f.QuotedPhrases) > 0 {
+			d.Reasons = append(d.Reasons, "quoted phrase")
+		} else {
+			d.Reasons = append(d.Reasons, "code-like tokens")
+		}
+	case f.Question && f.Terms <= 12:
+		d.Strategy, d.QueryType = StrategyVector, QueryTypeSemantic
+		d.Reasons = append(d.Reasons, "question")
+	case f.Terms <= 2:
+		d.Strategy, d.QueryType = StrategyFTS, QueryTypeKeyword
+		d.Reasons = append(d.Reasons, "short keyword query")
+	default:
+		d.Strategy, d.QueryType = StrategyHybrid, QueryTypeComplex
+		if f.Question {
+			d.Reasons = append(d.Reasons, "long question")
+		} else {
+			d.Reasons = append(d.Reasons, "multi-term query")
+		}
+	}
+
+	return d
+}
+
+// adjustByFirstPass 根据首轮BM25分数分布修正策略
+func adjustByFirstPass(d RoutingDecision, stats FirstPassStats) RoutingDecision {
+	d.FirstPass = &stats
+
+	switch {
+	case stats.Hits == 0 && d.Strategy != StrategyVector:
+		// 没有词面匹配，只能依靠语义
+		d.Strategy, d.QueryType = StrategyVector, QueryTypeSemantic
+		d.Reasons = append(d.Reasons, "no lexical matches")
+	case stats.Hits > 0 && stats.Margin >= 0.5 && d.Strategy == StrategyVector:
+		// 有明显领先的词面匹配，加入BM25
+		d.Strategy, d.QueryType = StrategyHybrid, QueryTypeComplex
+		d.Reasons = append(d.Reasons, "strong lexical match")
+	}
+
+	return d
+}
+
+// firstPassStats 计算首轮BM25检索的分数分布
+func (r *Retriever) firstPassStats(query string, opts RetrieveOptions) (FirstPassStats, error) {
+	results, err := r.store.SearchFTS(query, 10, opts.filter())
+	if err != nil {
+		return FirstPassStats{}, err
+	}
+
+	stats := FirstPassStats{Hits: len(results)}
+	if len(results) == 0 {
+		return stats, nil
+	}
+
+	stats.TopScore = results[0].Score
+	stats.Margin = 1.0
+	if len(results) > 1 && stats.TopScore > 0 {
+		stats.Margin = (stats.TopScore - results[1].Score) / stats.TopScore
+	}
+
+	return stats, nil
+}
+
+// Route 为查询选择检索策略
+// opts.FirstPassRouting 为true时先执行一次BM25检索，并根据分数分布修正决策
+func (r *Retriever) Route(query string, opts RetrieveOptions) (RoutingDecision, error) {
+	d := routeByFeatures(AnalyzeQuery(query))
+
+	if opts.FirstPassRouting {
+		stats, err := r.firstPassStats(query, opts)
+		if err != nil {
+			return d, fmt.Errorf("first pass failed: %w", err)
+		}
+		d = adjustByFirstPass(d, stats)
+	}
+
+	return d, nil
+}
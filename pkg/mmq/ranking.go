@@ -0,0 +1,233 @@
+package mmq
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+// BuiltinRankingProfiles 内置排序配置，无需保存即可按名称使用
+//   - default：默认排序（路径权重最高，FTS与向量等权）
+//   - recent：偏好最近修改的文档（半衰期30天）
+//   - content：偏重正文和标题，适合路径命名不规范的集合
+func BuiltinRankingProfiles() []RankingProfile {
+	return []RankingProfile{
+		{
+			Name:         "content",
+			FieldWeights: FieldWeights{Filepath: 1.0, Title: 3.0, Body: 5.0},
+			RRFWeights:   []float64{1.0, 1.5},
+		},
+		{
+			Name:         "default",
+			FieldWeights: FieldWeights{Filepath: 10.0, Title: 1.0, Body: 1.0},
+			RRFWeights:   []float64{1.0, 1.0},
+			RRFK:         60,
+		},
+		{
+			Name:            "recent",
+			FieldWeights:    FieldWeights{Filepath: 10.0, Title: 1.0, Body: 1.0},
+			RecencyHalfLife: 30 * 24 * time.Hour,
+			RecencyWeight:   1.0,
+		},
+	}
+}
+
+// builtinRankingProfile 按名称查找内置排序配置
+func builtinRankingProfile(name string) (*RankingProfile, bool) {
+	for _, p := range BuiltinRankingProfiles() {
+		if p.Name == name {
+			return &p, true
+		}
+	}
+	return nil, false
+}
+
+// SaveRankingProfile 保存排序配置（同名覆盖，可覆盖内置配置）
+func (m *MMQ) SaveRankingProfile(profile RankingProfile) error {
+	return m.store.SaveRankingProfile(toStoreRankingProfile(profile))
+}
+
+// GetRankingProfile 获取排序配置（已保存的配置优先于内置配置）
+// 仅在配置未保存时回退到内置配置，读取失败或配置损坏时返回错误
+func (m *MMQ) GetRankingProfile(name string) (*RankingProfile, error) {
+	profile, err := m.store.GetRankingProfile(name)
+	if err == nil {
+		result := fromStoreRankingProfile(*profile)
+		return &result, nil
+	}
+	if !errors.Is(err, ErrRankingProfileNotFound) {
+		return nil, err
+	}
+
+	if builtin, ok := builtinRankingProfile(name); ok {
+		return builtin, nil
+	}
+
+	return nil, err
+}
+
+// ListRankingProfiles 列出内置和已保存的排序配置（按名称排序）
+func (m *MMQ) ListRankingProfiles() ([]RankingProfile, error) {
+	stored, err := m.store.ListRankingProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]RankingProfile)
+	for _, p := range BuiltinRankingProfiles() {
+		byName[p.Name] = p
+	}
+	for _, p := range stored {
+		byName[p.Name] = fromStoreRankingProfile(p)
+	}
+
+	profiles := make([]RankingProfile, 0, len(byName))
+	for _, p := range byName {
+		profiles = append(profiles, p)
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+
+	return profiles, nil
+}
+
+// DeleteRankingProfile 删除已保存的排序配置，同时解除集合绑定
+func (m *MMQ) DeleteRankingProfile(name string) error {
+	return m.store.DeleteRankingProfile(name)
+}
+
+// SetCollectionProfile 为集合绑定排序配置，profile为空时解除绑定
+// 未指定Profile和Ranking的查询在过滤该集合时使用绑定的配置
+func (m *MMQ) SetCollectionProfile(collection, profile string) error {
+	if profile != "" {
+		// 内置配置在首次绑定时保存，之后按名称引用
+		if _, err := m.store.GetRankingProfile(profile); err != nil {
+			if !errors.Is(err, ErrRankingProfileNotFound) {
+				return err
+			}
+			builtin, ok := builtinRankingProfile(profile)
+			if !ok {
+				return err
+			}
+			if err := m.SaveRankingProfile(*builtin); err != nil {
+				return err
+			}
+		}
+	}
+
+	return m.store.SetCollectionProfile(collection, profile)
+}
+
+// GetCollectionProfile 获取集合绑定的排序配置名称（未绑定返回空字符串）
+func (m *MMQ) GetCollectionProfile(collection string) (string, error) {
+	return m.store.GetCollectionProfile(collection)
+}
+
+// resolveRanking 确定查询使用的排序配置：单次配置 > 配置名称 > 集合绑定
+// 均未指定时返回nil（默认排序）
+func (m *MMQ) resolveRanking(name string, inline *RankingProfile, collection string) (*store.RankingProfile, error) {
+	if inline != nil {
+		profile := toStoreRankingProfile(*inline)
+		return &profile, nil
+	}
+
+	if name == "" && collection != "" {
+		bound, err := m.store.GetCollectionProfile(collection)
+		if err != nil {
+			return nil, err
+		}
+		name = bound
+	}
+
+	if name == "" {
+		return nil, nil
+	}
+
+	profile, err := m.GetRankingProfile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := toStoreRankingProfile(*profile)
+	return &result, nil
+}
+
+// rankedFetchLimit 需要重新排序时多取候选结果
+func rankedFetchLimit(limit int, profile *store.RankingProfile) int {
+	if profile == nil || (profile.RecencyHalfLife <= 0 && len(profile.CollectionBoosts) == 0 &&
+		profile.PathContextWeight <= 0 && profile.FeedbackWeight <= 0) {
+		return limit
+	}
+	return limit * 2
+}
+
+// rankResults 应用排序配置（时间衰减、集合加权、路径上下文、相关性反馈）并截断到limit
+func (m *MMQ) rankResults(query string, results []store.SearchResult, profile *store.RankingProfile, limit int) ([]store.SearchResult, error) {
+	results = store.ApplyRankingBoosts(results, profile, time.Now())
+
+	if profile != nil && profile.PathContextWeight > 0 {
+		var err error
+		results, err = store.ApplyPathContextBoost(m.store, query, results, profile.PathContextWeight)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if profile != nil && profile.FeedbackWeight > 0 {
+		var err error
+		results, err = store.ApplyFeedbackBoost(m.store, query, results, profile.FeedbackWeight, profile.FeedbackHalfLife, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func toStoreRankingProfile(p RankingProfile) store.RankingProfile {
+	return store.RankingProfile{
+		Name: p.Name,
+		FieldWeights: store.FieldWeights{
+			Filepath: p.FieldWeights.Filepath,
+			Title:    p.FieldWeights.Title,
+			Body:     p.FieldWeights.Body,
+		},
+		RRFWeights:       p.RRFWeights,
+		RRFK:             p.RRFK,
+		RecencyHalfLife:  p.RecencyHalfLife,
+		RecencyWeight:    p.RecencyWeight,
+		CollectionBoosts: p.CollectionBoosts,
+
+		PathContextWeight: p.PathContextWeight,
+
+		FeedbackWeight:   p.FeedbackWeight,
+		FeedbackHalfLife: p.FeedbackHalfLife,
+	}
+}
+
+func fromStoreRankingProfile(p store.RankingProfile) RankingProfile {
+	return RankingProfile{
+		Name: p.Name,
+		FieldWeights: FieldWeights{
+			Filepath: p.FieldWeights.Filepath,
+			Title:    p.FieldWeights.Title,
+			Body:     p.FieldWeights.Body,
+		},
+		RRFWeights:       p.RRFWeights,
+		RRFK:             p.RRFK,
+		RecencyHalfLife:  p.RecencyHalfLife,
+		RecencyWeight:    p.RecencyWeight,
+		CollectionBoosts: p.CollectionBoosts,
+
+		PathContextWeight: p.PathContextWeight,
+
+		FeedbackWeight:   p.FeedbackWeight,
+		FeedbackHalfLife: p.FeedbackHalfLife,
+	}
+}
@@ -0,0 +1,264 @@
+package mmq
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newRankingTestMMQ(t *testing.T) *MMQ {
+	t.Helper()
+
+	m, err := NewWithDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	return m
+}
+
+func TestRankingFieldWeights(t *testing.T) {
+	m := newRankingTestMMQ(t)
+
+	indexDocs(t, m,
+		Document{Collection: "kb", Path: "widget.md", Title: "Overview", Content: "General notes about the product."},
+		Document{Collection: "kb", Path: "notes.md", Title: "Notes", Content: "The widget is configured via the widget panel; widget settings persist."},
+	)
+
+	results, err := m.Search("widget", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Path != "widget.md" {
+		t.Fatalf("Expected path match first with default weights, got %v", searchPaths(results))
+	}
+
+	// 偏重正文
+	results, err = m.Search("widget", SearchOptions{
+		Limit:   10,
+		Ranking: &RankingProfile{FieldWeights: FieldWeights{Filepath: 0.1, Title: 1, Body: 10}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Path != "notes.md" {
+		t.Errorf("Expected body match first with body-heavy weights, got %v", searchPaths(results))
+	}
+
+	// 内置content配置同样偏重正文
+	results, err = m.Search("widget", SearchOptions{Limit: 10, Profile: "content"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Path != "notes.md" {
+		t.Errorf("Expected body match first with content profile, got %v", searchPaths(results))
+	}
+
+	if _, err := m.Search("widget", SearchOptions{Limit: 10, Profile: "missing"}); err == nil {
+		t.Error("Expected error for unknown profile")
+	}
+}
+
+func TestRankingRecencyAndCollectionBoosts(t *testing.T) {
+	m := newRankingTestMMQ(t)
+
+	now := time.Now().UTC()
+	year := now.Add(-365 * 24 * time.Hour)
+	indexDocs(t, m,
+		Document{Collection: "archive", Path: "old.md", Title: "Deploy", Content: "Deploy guide.", ModifiedAt: year},
+		Document{Collection: "current", Path: "new.md", Title: "Deploy", Content: "Deploy guide.", ModifiedAt: now},
+		// 混合检索按内容哈希融合，内容需不同
+		Document{Collection: "archive", Path: "rollback-old.md", Title: "Rollback", Content: "Rollback steps for the old cluster.", ModifiedAt: year},
+		Document{Collection: "current", Path: "rollback-new.md", Title: "Rollback", Content: "Rollback steps for the new cluster.", ModifiedAt: now},
+	)
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+
+	recent := &RankingProfile{RecencyHalfLife: 30 * 24 * time.Hour}
+
+	results, err := m.Search("deploy guide", SearchOptions{Limit: 10, Ranking: recent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Path != "new.md" {
+		t.Fatalf("Expected recent document first, got %v", searchPaths(results))
+	}
+	for _, r := range results {
+		t.Logf("  %s score=%.4f", r.Path, r.Score)
+	}
+	if results[1].Score >= results[0].Score*0.6 {
+		t.Errorf("Expected old document to decay to about half, got %.4f vs %.4f", results[1].Score, results[0].Score)
+	}
+
+	vec, err := m.VectorSearch("Deploy guide.", SearchOptions{Limit: 1, Profile: "recent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vec) != 1 || vec[0].Path != "new.md" {
+		t.Errorf("Expected recent document from vector search, got %v", searchPaths(vec))
+	}
+
+	// 集合加权抵消时间衰减
+	boosted := &RankingProfile{
+		RecencyHalfLife:  30 * 24 * time.Hour,
+		CollectionBoosts: map[string]float64{"archive": 3.0},
+	}
+	results, err = m.Search("deploy guide", SearchOptions{Limit: 10, Ranking: boosted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Path != "old.md" {
+		t.Errorf("Expected boosted collection first, got %v", searchPaths(results))
+	}
+
+	contexts, err := m.RetrieveContext("rollback steps", RetrieveOptions{Limit: 10, Strategy: StrategyHybrid, Profile: "recent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contexts) == 0 || contexts[0].Source != "current/rollback-new.md" {
+		t.Errorf("Expected recent document first in retrieved contexts, got %+v", contexts)
+	}
+
+	boosted.CollectionBoosts["archive"] = 10.0
+	hybrid, err := m.HybridSearch("rollback steps", SearchOptions{Limit: 10, Ranking: boosted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hybrid) == 0 || hybrid[0].Path != "rollback-old.md" {
+		t.Errorf("Expected boosted collection first in hybrid search, got %v", searchPaths(hybrid))
+	}
+}
+
+func TestRankingProfilesPerCollection(t *testing.T) {
+	m := newRankingTestMMQ(t)
+
+	if err := m.CreateCollection("kb", "/tmp/kb", CollectionOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	indexDocs(t, m,
+		Document{Collection: "kb", Path: "widget.md", Title: "Overview", Content: "General notes about the product."},
+		Document{Collection: "kb", Path: "notes.md", Title: "Notes", Content: "The widget is configured via the widget panel; widget settings persist."},
+	)
+
+	err := m.SaveRankingProfile(RankingProfile{
+		Name:         "body",
+		FieldWeights: FieldWeights{Filepath: 0.1, Title: 1, Body: 10},
+		RRFWeights:   []float64{1.0, 0.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := m.GetRankingProfile("body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.FieldWeights.Body != 10 || len(profile.RRFWeights) != 2 {
+		t.Errorf("Profile not round-tripped: %+v", profile)
+	}
+
+	profiles, err := m.ListRankingProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+	t.Logf("Profiles: %v", names)
+	if len(profiles) != 4 {
+		t.Errorf("Expected 3 builtin and 1 saved profile, got %v", names)
+	}
+
+	if err := m.SetCollectionProfile("kb", "body"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetCollectionProfile("missing", "body"); err == nil {
+		t.Error("Expected error binding profile to missing collection")
+	}
+	if err := m.SetCollectionProfile("kb", "missing"); err == nil {
+		t.Error("Expected error binding missing profile")
+	}
+
+	// 过滤集合时使用绑定的配置
+	results, err := m.Search("widget", SearchOptions{Limit: 10, Collection: "kb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Path != "notes.md" {
+		t.Errorf("Expected collection profile to apply, got %v", searchPaths(results))
+	}
+
+	// 显式指定的配置优先
+	results, err = m.Search("widget", SearchOptions{Limit: 10, Collection: "kb", Profile: "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Path != "widget.md" {
+		t.Errorf("Expected explicit profile to override binding, got %v", searchPaths(results))
+	}
+
+	// 绑定随集合改名
+	if err := m.RenameCollection("kb", "docs"); err != nil {
+		t.Fatal(err)
+	}
+	bound, err := m.GetCollectionProfile("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bound != "body" {
+		t.Errorf("Expected binding to follow rename, got %q", bound)
+	}
+
+	// 内置配置可直接绑定
+	if err := m.SetCollectionProfile("docs", "recent"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.DeleteRankingProfile("recent"); err != nil {
+		t.Fatal(err)
+	}
+	bound, err = m.GetCollectionProfile("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bound != "" {
+		t.Errorf("Expected binding removed with profile, got %q", bound)
+	}
+
+	// 删除保存的版本后仍可使用内置配置
+	if _, err := m.GetRankingProfile("recent"); err != nil {
+		t.Errorf("Expected builtin profile to remain available: %v", err)
+	}
+}
+
+func TestRankingProfileErrors(t *testing.T) {
+	m := newRankingTestMMQ(t)
+
+	_, err := m.GetRankingProfile("missing")
+	if !errors.Is(err, ErrRankingProfileNotFound) {
+		t.Errorf("Expected ErrRankingProfileNotFound, got %v", err)
+	}
+
+	// 已保存的内置同名配置损坏时报错，不回退到内置配置
+	if err := m.SaveRankingProfile(RankingProfile{Name: "default", RRFK: 30}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetStore().DB().Exec("UPDATE ranking_profiles SET profile = '{' WHERE name = 'default'"); err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := m.GetRankingProfile("default")
+	if err == nil {
+		t.Fatalf("Expected error for corrupt profile, got %+v", profile)
+	}
+	if errors.Is(err, ErrRankingProfileNotFound) {
+		t.Errorf("Corrupt profile reported as not found: %v", err)
+	}
+	if _, err := m.Search("widget", SearchOptions{Limit: 10, Profile: "default"}); err == nil {
+		t.Error("Expected search with corrupt profile to fail")
+	}
+}
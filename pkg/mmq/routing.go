@@ -0,0 +1,45 @@
+package mmq
+
+import (
+	"github.com/crosszan/modu/pkg/mmq/rag"
+)
+
+// RouteQuery 返回StrategyAuto会为查询选择的检索策略及原因，不执行检索
+// opts.FirstPassRouting 为true时会执行一次BM25检索以参考分数分布
+func (m *MMQ) RouteQuery(query string, opts RetrieveOptions) (RoutingDecision, error) {
+	decision, err := m.retriever.Route(query, rag.RetrieveOptions{
+		Collection:       opts.Collection,
+		FirstPassRouting: opts.FirstPassRouting,
+	})
+	if err != nil {
+		return RoutingDecision{}, err
+	}
+
+	return convertRoutingDecision(decision), nil
+}
+
+// convertRoutingDecision 转换rag.RoutingDecision到mmq.RoutingDecision
+func convertRoutingDecision(d rag.RoutingDecision) RoutingDecision {
+	result := RoutingDecision{
+		Strategy:  RetrievalStrategy(d.Strategy),
+		QueryType: d.QueryType.String(),
+		Features: QueryFeatures{
+			Terms:         d.Features.Terms,
+			Language:      d.Features.Language,
+			QuotedPhrases: d.Features.QuotedPhrases,
+			CodeTokens:    d.Features.CodeTokens,
+			Question:      d.Features.Question,
+		},
+		Reasons: d.Reasons,
+	}
+
+	if d.FirstPass != nil {
+		result.FirstPass = &FirstPassStats{
+			Hits:     d.FirstPass.Hits,
+			TopScore: d.FirstPass.TopScore,
+			Margin:   d.FirstPass.Margin,
+		}
+	}
+
+	return result
+}
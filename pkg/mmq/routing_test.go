@@ -0,0 +1,204 @@
+package mmq
+
+import (
+	"testing"
+)
+
+// 标注查询的类别
+const (
+	routeExact         = "exact"          // 答案取决于精确词项（标识符、错误码、路径、引号短语）
+	routeTopic         = "topic"          // 1-2 个主题词
+	routeQuestion      = "question"       // 需要理解意图、词面未必与文档重合的问题
+	routeExactQuestion = "exact+question" // 带精确词项的问题
+	routeMultiTerm     = "multi-term"     // 多个主题词的组合
+)
+
+// routingQuerySet 独立标注的查询集：查询 -> 可接受的策略
+//
+// 标注按检索需求确定，而不是按路由器的输出回填：
+//   - 精确词项：fts，带有自然语言意图时 hybrid 也可接受
+//   - 问题：vector 或 hybrid
+//   - 主题词：fts（中日韩文字同样有双字词索引），hybrid 也可接受
+//   - 精确词项 + 问题：hybrid
+//   - 多个主题词：hybrid
+var routingQuerySet = []struct {
+	class string
+	query string
+	want  []RetrievalStrategy
+}{
+	{routeExact, "SQLITE_LOCKED", []RetrievalStrategy{StrategyFTS}},
+	{routeExact, "ECONNREFUSED 127.0.0.1", []RetrievalStrategy{StrategyFTS, StrategyHybrid}},
+	{routeExact, "RetrieveOptions", []RetrievalStrategy{StrategyFTS}},
+	{routeExact, "docker-compose.yml", []RetrievalStrategy{StrategyFTS}},
+	{routeExact, "internal/vectordb", []RetrievalStrategy{StrategyFTS}},
+	{routeExact, "--dry-run", []RetrievalStrategy{StrategyFTS}},
+	{routeExact, "\"write-ahead log\"", []RetrievalStrategy{StrategyFTS}},
+	{routeExact, "「滚动摘要」", []RetrievalStrategy{StrategyFTS}},
+	{routeExact, "HTTP502 upstream", []RetrievalStrategy{StrategyFTS, StrategyHybrid}},
+
+	{routeTopic, "postgres", []RetrievalStrategy{StrategyFTS, StrategyHybrid}},
+	{routeTopic, "rate limiting", []RetrievalStrategy{StrategyFTS, StrategyHybrid}},
+	{routeTopic, "备份", []RetrievalStrategy{StrategyFTS, StrategyHybrid}},
+	{routeTopic, "负载均衡", []RetrievalStrategy{StrategyFTS, StrategyHybrid}},
+
+	{routeQuestion, "how can I make search results less repetitive", []RetrievalStrategy{StrategyVector, StrategyHybrid}},
+	{routeQuestion, "what happens when two processes write at the same time?", []RetrievalStrategy{StrategyVector, StrategyHybrid}},
+	{routeQuestion, "is it safe to delete old embeddings", []RetrievalStrategy{StrategyVector, StrategyHybrid}},
+	{routeQuestion, "怎么让检索结果更准确", []RetrievalStrategy{StrategyVector, StrategyHybrid}},
+	{routeQuestion, "为什么导入之后搜索不到文档", []RetrievalStrategy{StrategyVector, StrategyHybrid}},
+	{routeQuestion, "数据库文件可以放在网络磁盘上吗？", []RetrievalStrategy{StrategyVector, StrategyHybrid}},
+
+	{routeExactQuestion, "why is HybridSearch slower than Search?", []RetrievalStrategy{StrategyHybrid}},
+	{routeExactQuestion, "what does --min-score do", []RetrievalStrategy{StrategyHybrid}},
+	{routeExactQuestion, "ERR-4012 是什么意思", []RetrievalStrategy{StrategyHybrid}},
+
+	{routeMultiTerm, "nightly backup retention object storage", []RetrievalStrategy{StrategyHybrid}},
+	{routeMultiTerm, "embedding model upgrade reindex cost", []RetrievalStrategy{StrategyHybrid}},
+	{routeMultiTerm, "多租户 记忆 隔离 会话 清理", []RetrievalStrategy{StrategyHybrid, StrategyFTS}},
+}
+
+// minRoutingAccuracy 各类别的最低路由准确率
+// 含精确词项的查询走错策略会丢失精确匹配，要求全部正确
+var minRoutingAccuracy = map[string]float64{
+	routeExact:         1.0,
+	routeExactQuestion: 1.0,
+	routeTopic:         0.75,
+	routeQuestion:      0.8,
+	routeMultiTerm:     0.65,
+}
+
+func TestQueryRouterLabelledSet(t *testing.T) {
+	m := newRankingTestMMQ(t)
+
+	total := make(map[string]int)
+	correct := make(map[string]int)
+	for _, tc := range routingQuerySet {
+		decision, err := m.RouteQuery(tc.query, RetrieveOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		total[tc.class]++
+		ok := false
+		for _, want := range tc.want {
+			if decision.Strategy == want {
+				ok = true
+				break
+			}
+		}
+		if ok {
+			correct[tc.class]++
+		} else {
+			t.Logf("Misrouted %s query %q: got %s (%v), want one of %v", tc.class, tc.query, decision.Strategy, decision.Reasons, tc.want)
+		}
+	}
+
+	for class, min := range minRoutingAccuracy {
+		if total[class] == 0 {
+			t.Errorf("No labelled queries for class %s", class)
+			continue
+		}
+		accuracy := float64(correct[class]) / float64(total[class])
+		t.Logf("%s: %d/%d (%.0f%%)", class, correct[class], total[class], accuracy*100)
+		if accuracy < min {
+			t.Errorf("Routing accuracy for %s queries %.2f below %.2f", class, accuracy, min)
+		}
+	}
+}
+
+func TestQueryRouterFeatures(t *testing.T) {
+	m := newRankingTestMMQ(t)
+
+	decision, err := m.RouteQuery(`why does "rank fusion" ignore SQLITE_BUSY?`, RetrieveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Decision: %+v", decision)
+
+	f := decision.Features
+	if len(f.QuotedPhrases) != 1 || f.QuotedPhrases[0] != "rank fusion" {
+		t.Errorf("Expected quoted phrase, got %v", f.QuotedPhrases)
+	}
+	if len(f.CodeTokens) != 1 || f.CodeTokens[0] != "SQLITE_BUSY" {
+		t.Errorf("Expected code token, got %v", f.CodeTokens)
+	}
+	if !f.Question || f.Language != "en" {
+		t.Errorf("Expected english question, got %+v", f)
+	}
+	if decision.QueryType != "complex" || len(decision.Reasons) == 0 {
+		t.Errorf("Expected complex query with reasons, got %+v", decision)
+	}
+
+	zh, err := m.RouteQuery("如何配置数据库连接", RetrieveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zh.Features.Language != "zh" || zh.Features.Terms < 4 {
+		t.Errorf("Expected chinese query split into bigram terms, got %+v", zh.Features)
+	}
+
+	// 日文和韩文查询不被当作中文
+	for query, want := range map[string]string{
+		"データベースの接続を設定する方法":   "ja",
+		"데이터베이스 연결을 설정하는 방법": "ko",
+	} {
+		d, err := m.RouteQuery(query, RetrieveOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Features.Language != want {
+			t.Errorf("Expected %s for %q, got %q", want, query, d.Features.Language)
+		}
+	}
+}
+
+func TestQueryRouterFirstPass(t *testing.T) {
+	m := newRankingTestMMQ(t)
+
+	indexDocs(t, m,
+		Document{Collection: "kb", Path: "pool.md", Title: "Pool", Content: "Connection pool sizing: start with twice the number of cores."},
+		Document{Collection: "kb", Path: "backup.md", Title: "Backup", Content: "Nightly backups are stored in object storage."},
+	)
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 无词面匹配的关键词查询改走向量
+	decision, err := m.RouteQuery("kubernetes", RetrieveOptions{FirstPassRouting: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("No match: %s", decision.Strategy)
+	if decision.FirstPass == nil || decision.FirstPass.Hits != 0 || decision.Strategy != StrategyVector {
+		t.Errorf("Expected vector after empty first pass, got %+v", decision)
+	}
+
+	// 有明显词面匹配的问题加入BM25
+	decision, err = m.RouteQuery("how big should the connection pool be?", RetrieveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Strategy != StrategyVector {
+		t.Fatalf("Expected vector without first pass, got %s", decision.Strategy)
+	}
+	decision, err = m.RouteQuery("connection pool?", RetrieveOptions{FirstPassRouting: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Strong match: %+v", decision)
+	if decision.Strategy != StrategyHybrid {
+		t.Errorf("Expected hybrid after strong first pass, got %s", decision.Strategy)
+	}
+
+	// 自动策略的上下文记录路由结果
+	contexts, err := m.RetrieveContext("connection pool", RetrieveOptions{Limit: 5, Strategy: StrategyAuto})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contexts) == 0 {
+		t.Fatal("Expected contexts from auto strategy")
+	}
+	if contexts[0].Metadata["route_strategy"] != string(StrategyFTS) {
+		t.Errorf("Expected route recorded in metadata, got %v", contexts[0].Metadata["route_strategy"])
+	}
+}
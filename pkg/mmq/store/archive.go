@@ -0,0 +1,1068 @@
+package store
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ArchiveVersion 归档格式版本
+const ArchiveVersion = 1
+
+// 归档中各部分的文件名（按写入/导入顺序）
+const (
+	archiveManifest    = "manifest.json"
+	archiveCollections = "collections.jsonl"
+	archiveContexts    = "contexts.jsonl"
+	archiveContent     = "content.jsonl"
+	archiveDocuments   = "documents.jsonl"
+	archiveEmbeddings  = "embeddings.jsonl"
+	archiveMemories    = "memories.jsonl"
+	archiveFacts       = "facts.jsonl"
+	archiveArchived    = "archived_memories.jsonl"
+)
+
+// ArchiveParts 归档包含的数据部分
+type ArchiveParts struct {
+	Collections bool // 集合定义、排序配置及向量量化方式
+	Contexts    bool // 上下文描述
+	Documents   bool // 文档元数据、内容、摘要、相关性反馈及结构化行记录
+	Embeddings  bool // 文档向量（以及记忆向量）
+	Memories    bool // 记忆及会话摘要
+}
+
+// AllArchiveParts 返回包含全部数据的选项
+func AllArchiveParts() ArchiveParts {
+	return ArchiveParts{
+		Collections: true,
+		Contexts:    true,
+		Documents:   true,
+		Embeddings:  true,
+		Memories:    true,
+	}
+}
+
+// ImportOptions 导入选项
+type ImportOptions struct {
+	ArchiveParts
+	// EmbedFunc 为缺少向量的记忆重新生成嵌入（为nil时记忆以空向量导入）
+	EmbedFunc func(text string) ([]float32, error)
+}
+
+// ArchiveManifest 归档清单
+type ArchiveManifest struct {
+	Version   int            `json:"version"`
+	CreatedAt time.Time      `json:"created_at"`
+	Parts     ArchiveParts   `json:"parts"`
+	Counts    map[string]int `json:"counts"`
+}
+
+// ArchiveStats 导出/导入统计（文件名 -> 记录数）
+type ArchiveStats map[string]int
+
+// 归档记录类型
+// 时间字段保持数据库中的原始字符串，向量使用JSON数组以保证跨平台可移植
+
+type archiveCollection struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Mask      string `json:"mask"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type archiveContext struct {
+	Path      string `json:"path"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type archiveContentRecord struct {
+	Hash      string `json:"hash"`
+	Doc       string `json:"doc"`
+	CreatedAt string `json:"created_at"`
+}
+
+type archiveDocument struct {
+	Collection string `json:"collection"`
+	Path       string `json:"path"`
+	Title      string `json:"title"`
+	Hash       string `json:"hash"`
+	CreatedAt  string `json:"created_at"`
+	ModifiedAt string `json:"modified_at"`
+	Active     bool   `json:"active"`
+}
+
+type archiveEmbedding struct {
+	Hash       string    `json:"hash"`
+	Seq        int       `json:"seq"`
+	Pos        int       `json:"pos"`
+	Model      string    `json:"model"`
+	Embedding  []float32 `json:"embedding"`
+	EmbeddedAt string    `json:"embedded_at"`
+}
+
+type archiveMemory struct {
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Content    string                 `json:"content"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Tags       []string               `json:"tags,omitempty"`
+	Timestamp  string                 `json:"timestamp"`
+	ExpiresAt  *string                `json:"expires_at,omitempty"`
+	Importance float64                `json:"importance"`
+	Embedding  []float32              `json:"embedding,omitempty"`
+	Tenant     string                 `json:"tenant,omitempty"`
+	User       string                 `json:"user,omitempty"`
+	Agent      string                 `json:"agent,omitempty"`
+	Session    string                 `json:"session,omitempty"`
+
+	AccessCount  int     `json:"access_count,omitempty"`
+	LastAccessed *string `json:"last_accessed,omitempty"`
+}
+
+// archiveArchivedMemory 归档中的一条被遗忘策略归档的记忆
+type archiveArchivedMemory struct {
+	archiveMemory
+	ArchivedAt string `json:"archived_at"`
+}
+
+// archiveTable 按列原样导出、导入的表（列值为文本或数值）
+// 后续功能新增的表登记在所属部分的表列表中，随该部分一起导出和导入
+type archiveTable struct {
+	name    string                 // 归档中的文件名
+	table   string                 // 表名
+	columns []string               // 导出的列，导入时按主键覆盖
+	rebuild func(tx *sql.Tx) error // 导入后重建派生数据（可选）
+}
+
+// collectionArchiveTables 随集合一起归档的表
+var collectionArchiveTables = []archiveTable{
+	{name: "ranking_profiles.jsonl", table: "ranking_profiles", columns: []string{"name", "profile", "created_at", "updated_at"}},
+	{name: "collection_profiles.jsonl", table: "collection_profiles", columns: []string{"collection", "profile"}},
+	{name: "collection_quantization.jsonl", table: "collection_quantization", columns: []string{"collection", "mode"}},
+}
+
+// documentArchiveTables 随文档一起归档的表
+var documentArchiveTables = []archiveTable{
+	{name: "document_summaries.jsonl", table: "document_summaries", columns: []string{"hash", "summary", "model", "created_at"}, rebuild: rebuildSummaryIndex},
+	{name: "feedback.jsonl", table: "feedback", columns: []string{"query_id", "doc_path", "signal", "weight", "query", "created_at"}},
+	{name: "records.jsonl", table: "records", columns: []string{"collection", "path", "source_path", "row_index", "fields"}},
+	{name: "record_fields.jsonl", table: "record_fields", columns: []string{"collection", "path", "field", "value"}},
+}
+
+// memoryArchiveTables 随记忆一起归档的表
+var memoryArchiveTables = []archiveTable{
+	{name: "conversation_summaries.jsonl", table: "conversation_summaries", columns: []string{
+		"tenant", "user_id", "agent_id", "session_id", "summary", "through_id", "covered", "tokens", "updated_at"}},
+	{name: "reflection_cursors.jsonl", table: "reflection_cursors", columns: []string{
+		"tenant", "user_id", "agent_id", "session_id", "through", "through_ids", "updated_at"}},
+	{name: archiveFacts, table: "facts", columns: []string{
+		"id", "tenant", "user_id", "agent_id", "session_id", "subject", "predicate", "object",
+		"confidence", "sources", "valid_from", "superseded_at", "superseded_by", "updated_at"}},
+	{name: "functional_predicates.jsonl", table: "functional_predicates", columns: []string{
+		"tenant", "user_id", "agent_id", "session_id", "predicate"}},
+}
+
+// archiveTables 返回选中部分附带的表
+func archiveTables(parts ArchiveParts) []archiveTable {
+	var tables []archiveTable
+	if parts.Collections {
+		tables = append(tables, collectionArchiveTables...)
+	}
+	if parts.Documents {
+		tables = append(tables, documentArchiveTables...)
+	}
+	if parts.Memories {
+		tables = append(tables, memoryArchiveTables...)
+	}
+	return tables
+}
+
+// partWriter 导出归档中的一个文件
+type partWriter struct {
+	name  string
+	write func(tx *sql.Tx, enc *json.Encoder) (int, error)
+}
+
+// tableWriter 按列导出表的写入器
+func tableWriter(table archiveTable) partWriter {
+	return partWriter{table.name, func(tx *sql.Tx, enc *json.Encoder) (int, error) {
+		return exportTable(tx, enc, table)
+	}}
+}
+
+// Export 将数据库内容导出为tar归档（manifest.json + 每部分一个JSONL文件）
+func (s *Store) Export(w io.Writer, parts ArchiveParts) (ArchiveStats, error) {
+	var writers []partWriter
+	if parts.Collections {
+		writers = append(writers, partWriter{archiveCollections, s.exportCollections})
+		// 量化方式须在向量之前导入，导入向量时按集合设置编码
+		for _, table := range collectionArchiveTables {
+			writers = append(writers, tableWriter(table))
+		}
+	}
+	if parts.Contexts {
+		writers = append(writers, partWriter{archiveContexts, s.exportContexts})
+	}
+	if parts.Documents {
+		writers = append(writers,
+			partWriter{archiveContent, s.exportContent},
+			partWriter{archiveDocuments, s.exportDocuments})
+	}
+	if parts.Embeddings {
+		writers = append(writers, partWriter{archiveEmbeddings, s.exportEmbeddings})
+	}
+	if parts.Memories {
+		writers = append(writers, partWriter{archiveMemories, func(tx *sql.Tx, enc *json.Encoder) (int, error) {
+			return s.exportMemories(tx, enc, parts.Embeddings)
+		}}, partWriter{archiveArchived, func(tx *sql.Tx, enc *json.Encoder) (int, error) {
+			return s.exportArchivedMemories(tx, enc, parts.Embeddings)
+		}})
+	}
+	if parts.Documents {
+		for _, table := range documentArchiveTables {
+			writers = append(writers, tableWriter(table))
+		}
+	}
+	if parts.Memories {
+		for _, table := range memoryArchiveTables {
+			writers = append(writers, tableWriter(table))
+		}
+	}
+
+	// tar条目需要预先知道大小，先写入临时文件
+	tmpDir, err := os.MkdirTemp("", "mmq-export-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// 所有部分在同一个读事务中导出，得到一致的快照
+	tx, err := s.readDB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin export transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stats := make(ArchiveStats)
+	var files []string
+
+	for _, pw := range writers {
+		tmpPath := path.Join(tmpDir, pw.name)
+		f, err := os.Create(tmpPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temp file: %w", err)
+		}
+
+		bw := bufio.NewWriter(f)
+		count, err := pw.write(tx, json.NewEncoder(bw))
+		if err == nil {
+			err = bw.Flush()
+		}
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", pw.name, err)
+		}
+
+		stats[pw.name] = count
+		files = append(files, pw.name)
+	}
+
+	// 写入tar
+	tw := tar.NewWriter(w)
+
+	manifest, err := json.MarshalIndent(ArchiveManifest{
+		Version:   ArchiveVersion,
+		CreatedAt: time.Now().UTC(),
+		Parts:     parts,
+		Counts:    stats,
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	if err := writeTarEntry(tw, archiveManifest, int64(len(manifest)), bytes.NewReader(manifest)); err != nil {
+		return nil, err
+	}
+
+	for _, name := range files {
+		f, err := os.Open(path.Join(tmpDir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open temp file: %w", err)
+		}
+
+		info, err := f.Stat()
+		if err == nil {
+			err = writeTarEntry(tw, name, info.Size(), f)
+		}
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize archive: %w", err)
+	}
+
+	return stats, nil
+}
+
+// Import 从tar归档导入数据
+// 已存在的记录会被覆盖（集合、上下文、文档按路径，记忆按ID）
+func (s *Store) Import(r io.Reader, opts ImportOptions) (ArchiveStats, error) {
+	tr := tar.NewReader(r)
+	stats := make(ArchiveStats)
+
+	var manifest *ArchiveManifest
+	requantize := false
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		if hdr.Name == archiveManifest {
+			manifest = &ArchiveManifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return stats, fmt.Errorf("invalid manifest: %w", err)
+			}
+			if manifest.Version > ArchiveVersion {
+				return stats, fmt.Errorf("unsupported archive version: %d", manifest.Version)
+			}
+			continue
+		}
+
+		if manifest == nil {
+			return stats, fmt.Errorf("invalid archive: %s must be the first entry", archiveManifest)
+		}
+
+		var count int
+		switch hdr.Name {
+		case archiveCollections:
+			if !opts.Collections {
+				continue
+			}
+			count, err = s.importCollections(tr)
+		case archiveContexts:
+			if !opts.Contexts {
+				continue
+			}
+			count, err = s.importContexts(tr)
+		case archiveContent:
+			if !opts.Documents {
+				continue
+			}
+			count, err = s.importContent(tr)
+		case archiveDocuments:
+			if !opts.Documents {
+				continue
+			}
+			count, err = s.importDocuments(tr)
+		case archiveEmbeddings:
+			if !opts.Embeddings {
+				continue
+			}
+			count, err = s.importEmbeddings(tr)
+		case archiveMemories:
+			if !opts.Memories {
+				continue
+			}
+			count, err = s.importMemories(tr, opts)
+		case archiveArchived:
+			if !opts.Memories {
+				continue
+			}
+			count, err = s.importArchivedMemories(tr, opts)
+		default:
+			table, ok := findArchiveTable(hdr.Name, opts.ArchiveParts)
+			if !ok {
+				// 未选择的部分或未知条目（来自更新的版本），跳过
+				continue
+			}
+			count, err = s.importTable(tr, table)
+			if table.table == "collection_quantization" && stats[archiveEmbeddings] > 0 {
+				requantize = true // 旧归档的量化方式在向量之后
+			}
+		}
+
+		if err != nil {
+			return stats, fmt.Errorf("failed to import %s: %w", hdr.Name, err)
+		}
+		stats[hdr.Name] = count
+	}
+
+	if manifest == nil {
+		return stats, fmt.Errorf("invalid archive: missing %s", archiveManifest)
+	}
+
+	if requantize {
+		if _, err := s.QuantizeEmbeddings(); err != nil {
+			return stats, err
+		}
+	}
+
+	// 旧版本的归档没有事实表：从导入的事实记忆重建知识图谱
+	if _, ok := stats[archiveMemories]; ok {
+		if _, ok := stats[archiveFacts]; !ok {
+			if err := s.backfillFacts(); err != nil {
+				return stats, err
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// Backup 使用SQLite在线备份API将数据库复制到destPath
+// 备份期间数据库仍可正常读写；ctx取消时中止备份
+func (s *Store) Backup(ctx context.Context, destPath string) error {
+	destDB, err := sql.Open("sqlite3", destPath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup destination: %w", err)
+	}
+	defer destDB.Close()
+
+	destConn, err := destDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect backup destination: %w", err)
+	}
+	defer destConn.Close()
+
+	srcConn, err := s.readDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect source database: %w", err)
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(destRaw interface{}) error {
+		return srcConn.Raw(func(srcRaw interface{}) error {
+			dest, ok := destRaw.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected destination driver connection %T", destRaw)
+			}
+			src, ok := srcRaw.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected source driver connection %T", srcRaw)
+			}
+
+			bk, err := dest.Backup("main", src, "main")
+			if err != nil {
+				return fmt.Errorf("failed to start backup: %w", err)
+			}
+
+			// 分步复制，每步之间检查ctx；源库被锁定时Step返回未完成，继续重试
+			for {
+				done, err := bk.Step(backupPagesPerStep)
+				if err != nil {
+					bk.Close()
+					return fmt.Errorf("backup step failed: %w", err)
+				}
+				if done {
+					break
+				}
+
+				select {
+				case <-ctx.Done():
+					bk.Close()
+					return ctx.Err()
+				case <-time.After(backupStepInterval):
+				}
+			}
+
+			if err := bk.Finish(); err != nil {
+				return fmt.Errorf("failed to finish backup: %w", err)
+			}
+			return nil
+		})
+	})
+}
+
+const (
+	// backupPagesPerStep 每次备份步骤复制的页数
+	backupPagesPerStep = 256
+	// backupStepInterval 备份步骤之间的间隔，让出锁给其他读写
+	backupStepInterval = 10 * time.Millisecond
+)
+
+// --- 导出 ---
+
+func (s *Store) exportCollections(tx *sql.Tx, enc *json.Encoder) (int, error) {
+	rows, err := tx.Query(`
+		SELECT name, path, mask, created_at, updated_at
+		FROM collections
+		ORDER BY name
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var rec archiveCollection
+		if err := rows.Scan(&rec.Name, &rec.Path, &rec.Mask, &rec.CreatedAt, &rec.UpdatedAt); err != nil {
+			return count, err
+		}
+		if err := enc.Encode(rec); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, rows.Err()
+}
+
+func (s *Store) exportContexts(tx *sql.Tx, enc *json.Encoder) (int, error) {
+	rows, err := tx.Query(`
+		SELECT path, content, created_at, updated_at
+		FROM contexts
+		ORDER BY path
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var rec archiveContext
+		if err := rows.Scan(&rec.Path, &rec.Content, &rec.CreatedAt, &rec.UpdatedAt); err != nil {
+			return count, err
+		}
+		if err := enc.Encode(rec); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, rows.Err()
+}
+
+func (s *Store) exportContent(tx *sql.Tx, enc *json.Encoder) (int, error) {
+	// 只导出被文档引用的内容
+	rows, err := tx.Query(`
+		SELECT c.hash, c.doc, c.created_at
+		FROM content c
+		WHERE EXISTS (SELECT 1 FROM documents d WHERE d.hash = c.hash)
+		ORDER BY c.hash
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var rec archiveContentRecord
+		if err := rows.Scan(&rec.Hash, &rec.Doc, &rec.CreatedAt); err != nil {
+			return count, err
+		}
+		if err := enc.Encode(rec); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, rows.Err()
+}
+
+func (s *Store) exportDocuments(tx *sql.Tx, enc *json.Encoder) (int, error) {
+	rows, err := tx.Query(`
+		SELECT collection, path, title, hash, created_at, modified_at, active
+		FROM documents
+		ORDER BY collection, path
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var rec archiveDocument
+		if err := rows.Scan(&rec.Collection, &rec.Path, &rec.Title, &rec.Hash,
+			&rec.CreatedAt, &rec.ModifiedAt, &rec.Active); err != nil {
+			return count, err
+		}
+		if err := enc.Encode(rec); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, rows.Err()
+}
+
+func (s *Store) exportEmbeddings(tx *sql.Tx, enc *json.Encoder) (int, error) {
+	rows, err := tx.Query(`
+		SELECT cv.hash, cv.seq, cv.pos, cv.model, cv.embedding, q.int8, COALESCE(q.scale, 0), cv.embedded_at
+		FROM content_vectors cv
+		LEFT JOIN vector_codes q ON q.hash = cv.hash AND q.seq = cv.seq
+		ORDER BY cv.hash, cv.seq
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var rec archiveEmbedding
+		var v storedVector
+		if err := rows.Scan(&rec.Hash, &rec.Seq, &rec.Pos, &rec.Model, &v.blob, &v.int8, &v.scale, &rec.EmbeddedAt); err != nil {
+			return count, err
+		}
+		// 量化向量以还原后的float32导出
+		rec.Embedding = v.float32s()
+		if err := enc.Encode(rec); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, rows.Err()
+}
+
+func (s *Store) exportMemories(tx *sql.Tx, enc *json.Encoder, withEmbeddings bool) (int, error) {
+	rows, err := tx.Query(`
+		SELECT m.id, m.type, m.content, m.metadata, m.tags, m.timestamp, m.expires_at, m.importance, m.embedding,
+			m.tenant, m.user_id, m.agent_id, m.session_id,
+			m.access_count, m.last_accessed
+		FROM memories m
+		ORDER BY m.timestamp
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var rec archiveMemory
+		var metadataJSON, tagsJSON sql.NullString
+		var expiresAt, lastAccessed sql.NullString
+		var blob []byte
+
+		if err := rows.Scan(&rec.ID, &rec.Type, &rec.Content, &metadataJSON, &tagsJSON,
+			&rec.Timestamp, &expiresAt, &rec.Importance, &blob,
+			&rec.Tenant, &rec.User, &rec.Agent, &rec.Session, &rec.AccessCount, &lastAccessed); err != nil {
+			return count, err
+		}
+
+		json.Unmarshal([]byte(metadataJSON.String), &rec.Metadata)
+		json.Unmarshal([]byte(tagsJSON.String), &rec.Tags)
+		if expiresAt.Valid {
+			rec.ExpiresAt = &expiresAt.String
+		}
+		if lastAccessed.Valid {
+			rec.LastAccessed = &lastAccessed.String
+		}
+		if withEmbeddings {
+			rec.Embedding = blobToFloat32(blob)
+		}
+
+		if err := enc.Encode(rec); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, rows.Err()
+}
+
+func (s *Store) exportArchivedMemories(tx *sql.Tx, enc *json.Encoder, withEmbeddings bool) (int, error) {
+	rows, err := tx.Query(`
+		SELECT id, type, content, metadata, tags, timestamp, expires_at, importance, embedding,
+			tenant, user_id, agent_id, session_id, access_count, last_accessed, archived_at
+		FROM archived_memories
+		ORDER BY rowid
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var rec archiveArchivedMemory
+		var metadataJSON, tagsJSON sql.NullString
+		var expiresAt, lastAccessed sql.NullString
+		var blob []byte
+
+		if err := rows.Scan(&rec.ID, &rec.Type, &rec.Content, &metadataJSON, &tagsJSON,
+			&rec.Timestamp, &expiresAt, &rec.Importance, &blob,
+			&rec.Tenant, &rec.User, &rec.Agent, &rec.Session, &rec.AccessCount, &lastAccessed, &rec.ArchivedAt); err != nil {
+			return count, err
+		}
+
+		json.Unmarshal([]byte(metadataJSON.String), &rec.Metadata)
+		json.Unmarshal([]byte(tagsJSON.String), &rec.Tags)
+		if expiresAt.Valid {
+			rec.ExpiresAt = &expiresAt.String
+		}
+		if lastAccessed.Valid {
+			rec.LastAccessed = &lastAccessed.String
+		}
+		if withEmbeddings {
+			rec.Embedding = blobToFloat32(blob)
+		}
+
+		if err := enc.Encode(rec); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, rows.Err()
+}
+
+// exportTable 按列导出表的全部行，每行一个JSON对象
+func exportTable(tx *sql.Tx, enc *json.Encoder, table archiveTable) (int, error) {
+	rows, err := tx.Query(fmt.Sprintf("SELECT %s FROM %s ORDER BY rowid",
+		strings.Join(table.columns, ", "), table.table))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	values := make([]interface{}, len(table.columns))
+	dest := make([]interface{}, len(table.columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return count, err
+		}
+		rec := make(map[string]interface{}, len(table.columns))
+		for i, column := range table.columns {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			rec[column] = values[i]
+		}
+		if err := enc.Encode(rec); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, rows.Err()
+}
+
+// --- 导入 ---
+
+// findArchiveTable 查找选中部分中与归档文件名对应的表
+func findArchiveTable(name string, parts ArchiveParts) (archiveTable, bool) {
+	for _, table := range archiveTables(parts) {
+		if table.name == name {
+			return table, true
+		}
+	}
+	return archiveTable{}, false
+}
+
+// importTable 导入按列归档的表，主键相同的行被覆盖
+func (s *Store) importTable(r io.Reader, table archiveTable) (int, error) {
+	records, err := decodeJSONL[map[string]interface{}](r)
+	if err != nil {
+		return 0, err
+	}
+
+	query := fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (%s)",
+		table.table, strings.Join(table.columns, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(table.columns)), ", "))
+
+	err = s.withTx(func(tx *sql.Tx) error {
+		args := make([]interface{}, len(table.columns))
+		for _, rec := range records {
+			for i, column := range table.columns {
+				args[i] = rec[column]
+			}
+			if _, err := tx.Exec(query, args...); err != nil {
+				return err
+			}
+		}
+		if table.rebuild != nil {
+			return table.rebuild(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(records), nil
+}
+
+// decodeJSONL 解码全部JSONL记录
+func decodeJSONL[T any](r io.Reader) ([]T, error) {
+	var records []T
+	dec := json.NewDecoder(r)
+	for {
+		var rec T
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid record %d: %w", len(records)+1, err)
+		}
+		records = append(records, rec)
+	}
+}
+
+// importJSONL 先解码全部记录，再在单个写事务中逐条调用fn
+// 写事务遇到SQLITE_BUSY时整体重试，输入流只能读取一次，因此不在事务中解码
+func importJSONL[T any](s *Store, r io.Reader, fn func(tx *sql.Tx, rec T) error) (int, error) {
+	records, err := decodeJSONL[T](r)
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.withTx(func(tx *sql.Tx) error {
+		for _, rec := range records {
+			if err := fn(tx, rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(records), nil
+}
+
+func (s *Store) importCollections(r io.Reader) (int, error) {
+	return importJSONL(s, r, func(tx *sql.Tx, rec archiveCollection) error {
+		_, err := tx.Exec(`
+			INSERT INTO collections (name, path, mask, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(name) DO UPDATE SET
+				path = excluded.path,
+				mask = excluded.mask,
+				updated_at = excluded.updated_at
+		`, rec.Name, rec.Path, rec.Mask, rec.CreatedAt, rec.UpdatedAt)
+		return err
+	})
+}
+
+func (s *Store) importContexts(r io.Reader) (int, error) {
+	return importJSONL(s, r, func(tx *sql.Tx, rec archiveContext) error {
+		_, err := tx.Exec(`
+			INSERT INTO contexts (path, content, created_at, updated_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT(path) DO UPDATE SET
+				content = excluded.content,
+				updated_at = excluded.updated_at
+		`, rec.Path, rec.Content, rec.CreatedAt, rec.UpdatedAt)
+		return err
+	})
+}
+
+func (s *Store) importContent(r io.Reader) (int, error) {
+	return importJSONL(s, r, func(tx *sql.Tx, rec archiveContentRecord) error {
+		// 校验哈希，防止损坏的归档破坏内容寻址
+		if computeHash(rec.Doc) != rec.Hash {
+			return fmt.Errorf("content hash mismatch: %s", rec.Hash)
+		}
+		_, err := tx.Exec(
+			"INSERT OR IGNORE INTO content (hash, doc, created_at) VALUES (?, ?, ?)",
+			rec.Hash, rec.Doc, rec.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		return detectLanguage(tx, rec.Hash, rec.Doc)
+	})
+}
+
+func (s *Store) importDocuments(r io.Reader) (int, error) {
+	return importJSONL(s, r, func(tx *sql.Tx, rec archiveDocument) error {
+		_, err := tx.Exec(`
+			INSERT INTO documents (collection, path, title, hash, created_at, modified_at, active)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(collection, path) DO UPDATE SET
+				title = excluded.title,
+				hash = excluded.hash,
+				modified_at = excluded.modified_at,
+				active = excluded.active
+		`, rec.Collection, rec.Path, rec.Title, rec.Hash, rec.CreatedAt, rec.ModifiedAt, rec.Active)
+		if err != nil {
+			return err
+		}
+		return indexCJK(tx, "d.collection = ? AND d.path = ?", rec.Collection, rec.Path)
+	})
+}
+
+func (s *Store) importEmbeddings(r io.Reader) (int, error) {
+	return importJSONL(s, r, func(tx *sql.Tx, rec archiveEmbedding) error {
+		_, err := tx.Exec(`
+			INSERT OR REPLACE INTO content_vectors (hash, seq, pos, embedding, model, embedded_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, rec.Hash, rec.Seq, rec.Pos, float32ToBlob(rec.Embedding), rec.Model, rec.EmbeddedAt)
+		if err != nil {
+			return err
+		}
+		// 导入的是float32向量，按集合设置重新量化
+		if _, err := tx.Exec("DELETE FROM vector_codes WHERE hash = ? AND seq = ?", rec.Hash, rec.Seq); err != nil {
+			return err
+		}
+		target, err := hashQuantization(tx, rec.Hash)
+		if err != nil {
+			return err
+		}
+		_, err = convertVector(tx, rec.Hash, rec.Seq, storedVector{blob: float32ToBlob(rec.Embedding)}, target)
+		return err
+	})
+}
+
+func (s *Store) importMemories(r io.Reader, opts ImportOptions) (int, error) {
+	// 先解码全部记录：重新生成嵌入可能较慢，不在写事务中进行
+	records, err := decodeJSONL[archiveMemory](r)
+	if err != nil {
+		return 0, err
+	}
+	for i := range records {
+		rec := &records[i]
+		if !opts.Embeddings {
+			rec.Embedding = nil
+		}
+		if len(rec.Embedding) == 0 && opts.EmbedFunc != nil {
+			embedding, err := opts.EmbedFunc(rec.Content)
+			if err != nil {
+				return 0, fmt.Errorf("failed to embed memory %s: %w", rec.ID, err)
+			}
+			rec.Embedding = embedding
+		}
+	}
+
+	count := 0
+	err = s.withTx(func(tx *sql.Tx) error {
+		count = 0
+		for _, rec := range records {
+			metadataJSON, err := json.Marshal(rec.Metadata)
+			if err != nil || rec.Metadata == nil {
+				metadataJSON = []byte("{}")
+			}
+			tagsJSON, err := json.Marshal(rec.Tags)
+			if err != nil || rec.Tags == nil {
+				tagsJSON = []byte("[]")
+			}
+
+			// 先删除再插入：REPLACE删除旧行时不触发删除触发器，全文索引键会残留
+			if _, err := tx.Exec("DELETE FROM memories WHERE id = ?", rec.ID); err != nil {
+				return err
+			}
+			ns := memoryNamespace(Namespace{Tenant: rec.Tenant, User: rec.User, Agent: rec.Agent, Session: rec.Session},
+				rec.Type, rec.Metadata)
+			_, err = tx.Exec(`
+				INSERT INTO memories (id, tenant, user_id, agent_id, session_id, type, content, metadata, tags,
+					timestamp, expires_at, importance, embedding, access_count, last_accessed)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`, rec.ID, ns.Tenant, ns.User, ns.Agent, ns.Session, rec.Type, rec.Content, string(metadataJSON), string(tagsJSON),
+				rec.Timestamp, rec.ExpiresAt, rec.Importance, float32ToBlob(rec.Embedding), rec.AccessCount, rec.LastAccessed)
+			if err != nil {
+				return err
+			}
+			if err := indexMemoryFTS(tx, rec.ID); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, nil
+}
+
+func (s *Store) importArchivedMemories(r io.Reader, opts ImportOptions) (int, error) {
+	records, err := decodeJSONL[archiveArchivedMemory](r)
+	if err != nil {
+		return 0, err
+	}
+	for i := range records {
+		rec := &records[i]
+		if !opts.Embeddings {
+			rec.Embedding = nil
+		}
+		if len(rec.Embedding) == 0 && opts.EmbedFunc != nil {
+			embedding, err := opts.EmbedFunc(rec.Content)
+			if err != nil {
+				return 0, fmt.Errorf("failed to embed archived memory %s: %w", rec.ID, err)
+			}
+			rec.Embedding = embedding
+		}
+	}
+
+	count := 0
+	err = s.withTx(func(tx *sql.Tx) error {
+		count = 0
+		for _, rec := range records {
+			metadataJSON, err := json.Marshal(rec.Metadata)
+			if err != nil || rec.Metadata == nil {
+				metadataJSON = []byte("{}")
+			}
+			tagsJSON, err := json.Marshal(rec.Tags)
+			if err != nil || rec.Tags == nil {
+				tagsJSON = []byte("[]")
+			}
+
+			// 同一ID只能是活跃记忆或归档记忆之一
+			if _, err := tx.Exec("DELETE FROM memories WHERE id = ?", rec.ID); err != nil {
+				return err
+			}
+			_, err = tx.Exec(`
+				INSERT OR REPLACE INTO archived_memories (id, tenant, user_id, agent_id, session_id, type, content,
+					metadata, tags, timestamp, expires_at, importance, embedding, access_count, last_accessed, archived_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`, rec.ID, rec.Tenant, rec.User, rec.Agent, rec.Session, rec.Type, rec.Content,
+				string(metadataJSON), string(tagsJSON), rec.Timestamp, rec.ExpiresAt, rec.Importance,
+				float32ToBlob(rec.Embedding), rec.AccessCount, rec.LastAccessed, rec.ArchivedAt)
+			if err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// writeTarEntry 写入一个tar文件条目
+func writeTarEntry(tw *tar.Writer, name string, size int64, r io.Reader) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write archive header %s: %w", name, err)
+	}
+	if _, err := io.Copy(tw, r); err != nil {
+		return fmt.Errorf("failed to write archive entry %s: %w", name, err)
+	}
+	return nil
+}
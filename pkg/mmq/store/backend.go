@@ -0,0 +1,208 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotSupported 后端不支持该操作
+var ErrNotSupported = errors.New("operation not supported by this storage backend")
+
+// DocumentStore 文档存储
+type DocumentStore interface {
+	IndexDocument(doc Document) error
+	GetDocument(id string) (*Document, error)
+	DeleteDocument(id string) error
+	GetStatus() (Status, error)
+	ListDocumentsByPath(collection, path string) ([]DocumentListEntry, error)
+	GetDocumentByPath(filePath string) (*DocumentDetail, error)
+	GetDocumentByID(docID string) (*DocumentDetail, error)
+	GetMultipleDocuments(pattern string, maxBytes int) ([]*DocumentDetail, error)
+}
+
+// EmbeddingStore 文档向量存储
+type EmbeddingStore interface {
+	GetDocumentsNeedingEmbedding() ([]Document, error)
+	StoreEmbedding(hash string, seq int, pos int, embedding []float32, model string) error
+	GetAllEmbeddings(hash string) ([][]float32, error)
+	GetChunkEmbeddings(hash string) ([]ChunkEmbedding, error)
+}
+
+// QuantizationStore 向量量化
+type QuantizationStore interface {
+	SetCollectionQuantization(collection, mode string) error
+	GetCollectionQuantization(collection string) (string, error)
+	QuantizeEmbeddings() (QuantizeStats, error)
+}
+
+// LanguageStore 文档语言（索引时检测）
+type LanguageStore interface {
+	DocumentLanguages(collection string) (map[string]string, error)
+}
+
+// SearchStore 文档检索
+type SearchStore interface {
+	SearchFTS(query string, limit int, filter DocumentFilter) ([]SearchResult, error)
+	SearchFTSWeighted(query string, limit int, filter DocumentFilter, weights FieldWeights) ([]SearchResult, error)
+	SearchVector(query string, embedding []float32, limit int, filter DocumentFilter) ([]SearchResult, error)
+	SearchVectorDocuments(query string, queryEmbed []float32, limit int, filter DocumentFilter) ([]SearchResult, error)
+}
+
+// CollectionStore 集合管理
+type CollectionStore interface {
+	CreateCollection(name, path, mask string) error
+	ListCollections() ([]Collection, error)
+	GetCollection(name string) (*Collection, error)
+	RemoveCollection(name string) error
+	RenameCollection(oldName, newName string) error
+	UpdateCollectionTimestamp(name string) error
+	CollectionExists(name string) (bool, error)
+}
+
+// ProfileStore 排序配置管理
+type ProfileStore interface {
+	SaveRankingProfile(profile RankingProfile) error
+	GetRankingProfile(name string) (*RankingProfile, error)
+	ListRankingProfiles() ([]RankingProfile, error)
+	DeleteRankingProfile(name string) error
+	SetCollectionProfile(collection, profile string) error
+	GetCollectionProfile(collection string) (string, error)
+}
+
+// ContextStore 上下文管理
+type ContextStore interface {
+	AddContext(path, content string) error
+	ListContexts() ([]ContextEntry, error)
+	GetContext(path string) (*ContextEntry, error)
+	RemoveContext(path string) error
+	GetContextsForPath(targetPath string) ([]ContextEntry, error)
+	CheckMissingContexts() ([]string, error)
+	GetAllContextsForDocument(collection, path string) ([]ContextEntry, error)
+}
+
+// SummaryStore 文档摘要存储
+type SummaryStore interface {
+	SaveDocumentSummary(hash, summary, model string) error
+	GetDocumentSummary(hash string) (*DocumentSummary, error)
+	GetDocumentsNeedingSummary(collection string) ([]Document, error)
+	PruneDocumentSummaries() (int, error)
+}
+
+// QueryLogStore 查询日志
+type QueryLogStore interface {
+	LogQuery(entry QueryLogEntry) (string, error)
+	GetQueryLogEntry(id string) (*QueryLogEntry, error)
+	ListQueryLog(since time.Time) ([]QueryLogEntry, error)
+	PruneQueryLog(before time.Time) (int, error)
+}
+
+// FeedbackStore 相关性反馈
+type FeedbackStore interface {
+	SaveFeedback(f Feedback) error
+	ListFeedback(since time.Time) ([]Feedback, error)
+}
+
+// RecordStore 结构化数据的行记录
+type RecordStore interface {
+	SaveRecords(collection, sourcePath string, records []StructuredRecord) error
+	GetRecord(collection, path string) (*StructuredRecord, error)
+	ListRecords(collection, sourcePath string) ([]StructuredRecord, error)
+	FindRecords(collection string, filters map[string]string) ([]StructuredRecord, error)
+}
+
+// MemoryStore 记忆存储
+// scopes为记忆的命名空间范围（多个范围取并集），为空时不限定
+type MemoryStore interface {
+	InsertMemory(ns Namespace, memType, content string, metadata map[string]interface{}, tags []string,
+		timestamp time.Time, expiresAt *time.Time, importance float64, embedding []float32) error
+	SearchMemories(queryEmbedding []float32, limit int, filter MemoryFilter, scopes []Namespace) ([]MemoryResult, error)
+	SearchMemoriesFTS(query string, limit int, filter MemoryFilter, scopes []Namespace) ([]MemoryResult, error)
+	ListMemories(filter MemoryFilter, scopes []Namespace, order MemoryOrder, limit, offset int) ([]MemoryResult, int, error)
+	GetMemoryByID(id string) (*MemoryResult, error)
+	GetMemoriesByType(memType string, scopes []Namespace) ([]MemoryResult, error)
+	GetMemoriesBySession(sessionID string, limit int, scopes []Namespace) ([]MemoryResult, error)
+	GetRecentMemoriesByType(memType string, limit int, scopes []Namespace) ([]MemoryResult, error)
+	GetMemoriesWithEmbeddings(scopes []Namespace) ([]MemoryResult, error)
+	UpdateMemory(id, content string, metadata map[string]interface{}, tags []string,
+		expiresAt *time.Time, importance float64, embedding []float32) error
+	MergeMemories(merges []MemoryMerge) error
+	DeleteMemory(id string) error
+	DeleteMemoriesBySession(sessionID string, scopes []Namespace) (int, error)
+	DeleteExpiredMemories(scopes []Namespace) (int, error)
+	CountMemories(scopes []Namespace) (int, error)
+	CountMemoriesByType(memType string, scopes []Namespace) (int, error)
+	CountMemoriesBySession(sessionID string, scopes []Namespace) (int, error)
+	GetSessionIDs(scopes []Namespace) ([]string, error)
+}
+
+// MemoryLifecycleStore 记忆的召回统计与归档（用于强化和遗忘）
+type MemoryLifecycleStore interface {
+	TouchMemories(ids []string, at time.Time) error
+	ArchiveMemories(ids []string, at time.Time) (int, error)
+	ListArchivedMemories(scopes []Namespace, limit, offset int) ([]ArchivedMemory, int, error)
+	RestoreArchivedMemory(id string) error
+}
+
+// FactStore 知识图谱事实
+type FactStore interface {
+	InsertFact(fact FactRecord) (string, error)
+	UpdateFact(fact FactRecord) error
+	QueryFacts(pattern FactPattern, scopes []Namespace, limit int) ([]FactRecord, error)
+	DeleteFact(id string) error
+	AssertFact(a FactAssertion) (*FactAssertResult, error)
+	SetFunctionalPredicates(ns Namespace, predicates []string) error
+}
+
+// ConversationSummaryStore 会话滚动摘要
+type ConversationSummaryStore interface {
+	SaveConversationSummary(summary ConversationSummary) error
+	GetConversationSummary(ns Namespace) (*ConversationSummary, error)
+	DeleteConversationSummaries(sessionID string, scopes []Namespace) (int, error)
+}
+
+// ReflectionCursorStore 反思进度
+type ReflectionCursorStore interface {
+	SaveReflectionCursor(cursor ReflectionCursor) error
+	GetReflectionCursor(ns Namespace) (*ReflectionCursor, error)
+}
+
+// Backend 存储后端
+// rag、memory和mmq层只依赖该接口：
+// - *Store：SQLite实现（文件或":memory:"临时库）
+// - *InMemoryStore：纯Go内存实现，适合短生命周期的agent和单元测试
+type Backend interface {
+	DocumentStore
+	EmbeddingStore
+	QuantizationStore
+	LanguageStore
+	SearchStore
+	CollectionStore
+	ProfileStore
+	ContextStore
+	SummaryStore
+	QueryLogStore
+	FeedbackStore
+	RecordStore
+	MemoryStore
+	MemoryLifecycleStore
+	ConversationSummaryStore
+	ReflectionCursorStore
+	FactStore
+	Close() error
+}
+
+// Archiver 支持导出、导入和在线备份的后端（可选能力）
+type Archiver interface {
+	Export(w io.Writer, parts ArchiveParts) (ArchiveStats, error)
+	Import(r io.Reader, opts ImportOptions) (ArchiveStats, error)
+	Backup(ctx context.Context, destPath string) error
+}
+
+// 编译期检查
+var (
+	_ Backend  = (*Store)(nil)
+	_ Archiver = (*Store)(nil)
+	_ Backend  = (*InMemoryStore)(nil)
+)
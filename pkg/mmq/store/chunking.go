@@ -2,7 +2,8 @@ package store
 
 import (
 	"strings"
-	"unicode"
+
+	"github.com/crosszan/modu/pkg/mmq/internal/tokens"
 )
 
 // ChunkSize 默认分块大小（字符）
@@ -120,33 +121,10 @@ func findBreakPoint(content string, start, end int) int {
 }
 
 // EstimateTokens 估算文本的token数量
-// 使用简单的启发式规则：平均4个字符≈1个token
+// 启发式规则：CJK字符每个≈1个token，其余文本平均4个字节≈1个token
+// 需要精确计数时使用llm.Tokenizer
 func EstimateTokens(text string) int {
-	// 移除多余的空白
-	text = strings.TrimSpace(text)
-
-	// 统计单词数（对英文）和字符数（对中文）
-	words := 0
-	inWord := false
-
-	for _, r := range text {
-		if unicode.IsSpace(r) {
-			if inWord {
-				words++
-				inWord = false
-			}
-		} else {
-			inWord = true
-		}
-	}
-
-	if inWord {
-		words++
-	}
-
-	// 估算：英文单词数 + 中文字符数/2
-	// 简化为：总字符数 / 4
-	return len(text) / 4
+	return tokens.Estimate(text)
 }
 
 // ChunkWithTokenLimit 按token限制分块
@@ -8,12 +8,12 @@ import (
 
 // Collection 集合信息
 type Collection struct {
-	Name       string
-	Path       string    // 文件系统路径
-	Mask       string    // Glob匹配模式，如 "**/*.md"
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
-	DocCount   int // 文档数量（统计信息）
+	Name      string
+	Path      string // 文件系统路径
+	Mask      string // Glob匹配模式，如 "**/*.md"
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DocCount  int // 文档数量（统计信息）
 }
 
 // CreateCollection 创建集合
@@ -22,7 +22,7 @@ func (s *Store) CreateCollection(name, path, mask string) error {
 
 	// 检查是否已存在
 	var exists int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM collections WHERE name = ?", name).Scan(&exists)
+	err := s.readDB.QueryRow("SELECT COUNT(*) FROM collections WHERE name = ?", name).Scan(&exists)
 	if err != nil {
 		return fmt.Errorf("failed to check collection: %w", err)
 	}
@@ -32,7 +32,7 @@ func (s *Store) CreateCollection(name, path, mask string) error {
 	}
 
 	// 插入集合
-	_, err = s.db.Exec(`
+	_, err = s.exec(`
 		INSERT INTO collections (name, path, mask, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?)
 	`, name, path, mask, now, now)
@@ -46,7 +46,7 @@ func (s *Store) CreateCollection(name, path, mask string) error {
 
 // ListCollections 列出所有集合
 func (s *Store) ListCollections() ([]Collection, error) {
-	rows, err := s.db.Query(`
+	rows, err := s.readDB.Query(`
 		SELECT
 			c.name,
 			c.path,
@@ -96,7 +96,7 @@ func (s *Store) GetCollection(name string) (*Collection, error) {
 	var createdAtStr, updatedAtStr string
 	var docCount sql.NullInt64
 
-	err := s.db.QueryRow(`
+	err := s.readDB.QueryRow(`
 		SELECT
 			c.name,
 			c.path,
@@ -141,31 +141,30 @@ func (s *Store) RemoveCollection(name string) error {
 		return err
 	}
 
-	// 开始事务
-	tx, err := s.db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
+	// 在事务中执行
+	return s.withTx(func(tx *sql.Tx) error {
+		// 删除集合的所有文档（设置为inactive）
+		if _, err := tx.Exec("UPDATE documents SET active = 0 WHERE collection = ?", name); err != nil {
+			return fmt.Errorf("failed to deactivate documents: %w", err)
+		}
 
-	// 删除集合的所有文档（设置为inactive）
-	_, err = tx.Exec("UPDATE documents SET active = 0 WHERE collection = ?", name)
-	if err != nil {
-		return fmt.Errorf("failed to deactivate documents: %w", err)
-	}
+		// 删除集合记录
+		if _, err := tx.Exec("DELETE FROM collections WHERE name = ?", name); err != nil {
+			return fmt.Errorf("failed to delete collection: %w", err)
+		}
 
-	// 删除集合记录
-	_, err = tx.Exec("DELETE FROM collections WHERE name = ?", name)
-	if err != nil {
-		return fmt.Errorf("failed to delete collection: %w", err)
-	}
+		// 解除排序配置绑定
+		if _, err := tx.Exec("DELETE FROM collection_profiles WHERE collection = ?", name); err != nil {
+			return fmt.Errorf("failed to unbind ranking profile: %w", err)
+		}
 
-	// 提交事务
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
+		// 删除量化设置
+		if _, err := tx.Exec("DELETE FROM collection_quantization WHERE collection = ?", name); err != nil {
+			return fmt.Errorf("failed to clear quantization: %w", err)
+		}
 
-	return nil
+		return nil
+	})
 }
 
 // RenameCollection 重命名集合
@@ -178,7 +177,7 @@ func (s *Store) RenameCollection(oldName, newName string) error {
 
 	// 检查新名称是否已存在
 	var exists int
-	err = s.db.QueryRow("SELECT COUNT(*) FROM collections WHERE name = ?", newName).Scan(&exists)
+	err = s.readDB.QueryRow("SELECT COUNT(*) FROM collections WHERE name = ?", newName).Scan(&exists)
 	if err != nil {
 		return fmt.Errorf("failed to check new name: %w", err)
 	}
@@ -187,43 +186,60 @@ func (s *Store) RenameCollection(oldName, newName string) error {
 		return fmt.Errorf("collection '%s' already exists", newName)
 	}
 
-	// 开始事务
-	tx, err := s.db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	// 更新集合名称
+	// 在事务中执行
 	now := time.Now().UTC().Format(time.RFC3339)
-	_, err = tx.Exec(`
-		UPDATE collections
-		SET name = ?, updated_at = ?
-		WHERE name = ?
-	`, newName, now, oldName)
-	if err != nil {
-		return fmt.Errorf("failed to update collection name: %w", err)
-	}
+	return s.withTx(func(tx *sql.Tx) error {
+		// 更新集合名称
+		_, err := tx.Exec(`
+			UPDATE collections
+			SET name = ?, updated_at = ?
+			WHERE name = ?
+		`, newName, now, oldName)
+		if err != nil {
+			return fmt.Errorf("failed to update collection name: %w", err)
+		}
 
-	// 更新所有文档的collection字段
-	_, err = tx.Exec("UPDATE documents SET collection = ? WHERE collection = ?", newName, oldName)
-	if err != nil {
-		return fmt.Errorf("failed to update documents: %w", err)
-	}
+		// 更新所有文档的collection字段
+		_, err = tx.Exec("UPDATE documents SET collection = ? WHERE collection = ?", newName, oldName)
+		if err != nil {
+			return fmt.Errorf("failed to update documents: %w", err)
+		}
+		// CJK索引的filepath包含集合名，需要重建
+		if err := indexCJK(tx, "d.collection = ?", newName); err != nil {
+			return err
+		}
 
-	// 提交事务
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
+		// 排序配置绑定随集合改名
+		_, err = tx.Exec("UPDATE collection_profiles SET collection = ? WHERE collection = ?", newName, oldName)
+		if err != nil {
+			return fmt.Errorf("failed to update collection profile: %w", err)
+		}
 
-	return nil
+		// 量化设置随集合改名
+		_, err = tx.Exec("UPDATE collection_quantization SET collection = ? WHERE collection = ?", newName, oldName)
+		if err != nil {
+			return fmt.Errorf("failed to update quantization: %w", err)
+		}
+
+		// 结构化数据的行记录随集合改名
+		_, err = tx.Exec("UPDATE records SET collection = ? WHERE collection = ?", newName, oldName)
+		if err != nil {
+			return fmt.Errorf("failed to update records: %w", err)
+		}
+		_, err = tx.Exec("UPDATE record_fields SET collection = ? WHERE collection = ?", newName, oldName)
+		if err != nil {
+			return fmt.Errorf("failed to update record fields: %w", err)
+		}
+
+		return nil
+	})
 }
 
 // UpdateCollectionTimestamp 更新集合的更新时间
 func (s *Store) UpdateCollectionTimestamp(name string) error {
 	now := time.Now().UTC().Format(time.RFC3339)
 
-	_, err := s.db.Exec(`
+	_, err := s.exec(`
 		UPDATE collections
 		SET updated_at = ?
 		WHERE name = ?
@@ -238,7 +254,7 @@ func (s *Store) UpdateCollectionTimestamp(name string) error {
 
 // GetCollectionNames 获取所有集合名称列表
 func (s *Store) GetCollectionNames() ([]string, error) {
-	rows, err := s.db.Query("SELECT name FROM collections ORDER BY created_at DESC")
+	rows, err := s.readDB.Query("SELECT name FROM collections ORDER BY created_at DESC")
 	if err != nil {
 		return nil, fmt.Errorf("failed to query collection names: %w", err)
 	}
@@ -259,7 +275,7 @@ func (s *Store) GetCollectionNames() ([]string, error) {
 // CollectionExists 检查集合是否存在
 func (s *Store) CollectionExists(name string) (bool, error) {
 	var exists int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM collections WHERE name = ?", name).Scan(&exists)
+	err := s.readDB.QueryRow("SELECT COUNT(*) FROM collections WHERE name = ?", name).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("failed to check collection: %w", err)
 	}
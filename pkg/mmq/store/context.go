@@ -3,48 +3,33 @@ package store
 import (
 	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
+	"unicode"
 )
 
 // ContextEntry 上下文条目
 type ContextEntry struct {
-	Path      string    // 路径（可以是collection或具体路径）
-	Content   string    // 上下文内容
+	Path      string // 路径（可以是collection或具体路径）
+	Content   string // 上下文内容
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
-// AddContext 添加上下文
+// AddContext 添加上下文（已存在则更新）
 func (s *Store) AddContext(path, content string) error {
 	now := time.Now().UTC().Format(time.RFC3339)
 
-	// 检查是否已存在
-	var exists int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM contexts WHERE path = ?", path).Scan(&exists)
+	_, err := s.exec(`
+		INSERT INTO contexts (path, content, created_at, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET
+			content = excluded.content,
+			updated_at = excluded.updated_at
+	`, path, content, now, now)
 	if err != nil {
-		return fmt.Errorf("failed to check context: %w", err)
-	}
-
-	if exists > 0 {
-		// 更新现有上下文
-		_, err = s.db.Exec(`
-			UPDATE contexts
-			SET content = ?, updated_at = ?
-			WHERE path = ?
-		`, content, now, path)
-		if err != nil {
-			return fmt.Errorf("failed to update context: %w", err)
-		}
-	} else {
-		// 插入新上下文
-		_, err = s.db.Exec(`
-			INSERT INTO contexts (path, content, created_at, updated_at)
-			VALUES (?, ?, ?, ?)
-		`, path, content, now, now)
-		if err != nil {
-			return fmt.Errorf("failed to insert context: %w", err)
-		}
+		return fmt.Errorf("failed to add context: %w", err)
 	}
 
 	return nil
@@ -52,7 +37,7 @@ func (s *Store) AddContext(path, content string) error {
 
 // ListContexts 列出所有上下文
 func (s *Store) ListContexts() ([]ContextEntry, error) {
-	rows, err := s.db.Query(`
+	rows, err := s.readDB.Query(`
 		SELECT path, content, created_at, updated_at
 		FROM contexts
 		ORDER BY path
@@ -86,7 +71,7 @@ func (s *Store) GetContext(path string) (*ContextEntry, error) {
 	var ctx ContextEntry
 	var createdAtStr, updatedAtStr string
 
-	err := s.db.QueryRow(`
+	err := s.readDB.QueryRow(`
 		SELECT path, content, created_at, updated_at
 		FROM contexts
 		WHERE path = ?
@@ -107,7 +92,7 @@ func (s *Store) GetContext(path string) (*ContextEntry, error) {
 
 // RemoveContext 删除上下文
 func (s *Store) RemoveContext(path string) error {
-	result, err := s.db.Exec("DELETE FROM contexts WHERE path = ?", path)
+	result, err := s.exec("DELETE FROM contexts WHERE path = ?", path)
 	if err != nil {
 		return fmt.Errorf("failed to delete context: %w", err)
 	}
@@ -123,7 +108,7 @@ func (s *Store) RemoveContext(path string) error {
 // GetContextsForPath 获取路径的所有相关上下文
 // 支持层级匹配：/ -> qmd://collection -> qmd://collection/path
 func (s *Store) GetContextsForPath(targetPath string) ([]ContextEntry, error) {
-	rows, err := s.db.Query(`
+	rows, err := s.readDB.Query(`
 		SELECT path, content, created_at, updated_at
 		FROM contexts
 		ORDER BY LENGTH(path) ASC
@@ -187,7 +172,7 @@ func (s *Store) CheckMissingContexts() ([]string, error) {
 // ContextExists 检查上下文是否存在
 func (s *Store) ContextExists(path string) (bool, error) {
 	var exists int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM contexts WHERE path = ?", path).Scan(&exists)
+	err := s.readDB.QueryRow("SELECT COUNT(*) FROM contexts WHERE path = ?", path).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("failed to check context: %w", err)
 	}
@@ -232,9 +217,9 @@ func (s *Store) GetAllContextsForDocument(collection, path string) ([]ContextEnt
 
 	// 可能的上下文路径（按优先级从高到低）
 	paths := []string{
-		targetPath,                      // 精确路径
+		targetPath,                          // 精确路径
 		fmt.Sprintf("qmd://%s", collection), // 集合级别
-		"/",                             // 全局
+		"/",                                 // 全局
 	}
 
 	var contexts []ContextEntry
@@ -248,3 +233,92 @@ func (s *Store) GetAllContextsForDocument(collection, path string) ([]ContextEnt
 
 	return contexts, nil
 }
+
+// DocumentContextPath 文档在上下文表中的路径 qmd://collection/path
+func DocumentContextPath(collection, path string) string {
+	return fmt.Sprintf("qmd://%s/%s", collection, path)
+}
+
+// ApplyPathContextBoost 将路径上下文链作为排序信号，并按新分数重新排序
+//
+// 命中率为查询词出现在文档继承的上下文描述（全局、集合、目录、文件）中的比例，
+// 分数调整为 score * (1 + weight*命中率)。
+func ApplyPathContextBoost(cs ContextStore, query string, results []SearchResult, weight float64) ([]SearchResult, error) {
+	if weight <= 0 || len(results) == 0 {
+		return results, nil
+	}
+
+	terms := contextTerms(query)
+	if len(terms) == 0 {
+		return results, nil
+	}
+
+	chains := make(map[string]string)
+	for i := range results {
+		r := &results[i]
+
+		target := DocumentContextPath(r.Collection, r.Path)
+		text, ok := chains[target]
+		if !ok {
+			entries, err := cs.GetContextsForPath(target)
+			if err != nil {
+				return nil, err
+			}
+			var parts []string
+			for _, e := range entries {
+				parts = append(parts, strings.ToLower(e.Content))
+			}
+			text = strings.Join(parts, "\n")
+			chains[target] = text
+		}
+		if text == "" {
+			continue
+		}
+
+		hits := 0
+		for _, term := range terms {
+			if strings.Contains(text, term) {
+				hits++
+			}
+		}
+		r.Score *= 1 + weight*float64(hits)/float64(len(terms))
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	return results, nil
+}
+
+// contextTerms 查询词（小写；连续汉字按二元组切分）
+func contextTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		runes := []rune(word)
+		if unicode.Is(unicode.Han, runes[0]) {
+			if len(runes) == 1 {
+				add(word)
+			}
+			for i := 0; i+1 < len(runes); i++ {
+				add(string(runes[i : i+2]))
+			}
+			continue
+		}
+		if len(runes) >= 2 {
+			add(word)
+		}
+	}
+
+	return terms
+}
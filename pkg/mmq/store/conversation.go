@@ -0,0 +1,82 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConversationSummary 会话的滚动摘要
+// 长会话中较早的消息被压缩为摘要，随新消息增量刷新
+type ConversationSummary struct {
+	Namespace Namespace // 所属命名空间（含会话）
+	Summary   string
+	ThroughID string // 摘要覆盖到的最后一条消息（记忆ID）
+	Covered   int    // 摘要覆盖的消息数
+	Tokens    int    // 摘要的token数
+	UpdatedAt time.Time
+}
+
+// SaveConversationSummary 保存会话的滚动摘要（已存在则覆盖）
+func (s *Store) SaveConversationSummary(summary ConversationSummary) error {
+	if summary.Namespace.Session == "" {
+		return fmt.Errorf("conversation summary requires a session")
+	}
+
+	ns := summary.Namespace
+	_, err := s.exec(`
+		INSERT OR REPLACE INTO conversation_summaries
+			(tenant, user_id, agent_id, session_id, summary, through_id, covered, tokens, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, ns.Tenant, ns.User, ns.Agent, ns.Session, summary.Summary, summary.ThroughID,
+		summary.Covered, summary.Tokens, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to save conversation summary: %w", err)
+	}
+	return nil
+}
+
+// GetConversationSummary 获取命名空间（含会话）的滚动摘要，不存在时返回nil
+func (s *Store) GetConversationSummary(ns Namespace) (*ConversationSummary, error) {
+	summary := &ConversationSummary{Namespace: ns}
+	var updatedAt string
+
+	err := s.readDB.QueryRow(`
+		SELECT summary, through_id, covered, tokens, updated_at
+		FROM conversation_summaries
+		WHERE tenant = ? AND user_id = ? AND agent_id = ? AND session_id = ?
+	`, ns.Tenant, ns.User, ns.Agent, ns.Session).Scan(
+		&summary.Summary, &summary.ThroughID, &summary.Covered, &summary.Tokens, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation summary: %w", err)
+	}
+
+	summary.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+	return summary, nil
+}
+
+// DeleteConversationSummaries 删除范围内指定会话的滚动摘要
+func (s *Store) DeleteConversationSummaries(sessionID string, scopes []Namespace) (int, error) {
+	conds := []string{"n.session_id = ?"}
+	args := []interface{}{sessionID}
+	if clause, scopeArgs := scopeClause("n", scopes); clause != "" {
+		conds = append(conds, clause)
+		args = append(args, scopeArgs...)
+	}
+
+	result, err := s.exec(`
+		DELETE FROM conversation_summaries WHERE rowid IN (
+			SELECT n.rowid FROM conversation_summaries n WHERE `+strings.Join(conds, " AND ")+`
+		)
+	`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete conversation summaries: %w", err)
+	}
+
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
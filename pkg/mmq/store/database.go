@@ -2,9 +2,13 @@ package store
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3" // SQLite driver
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3" // SQLite driver
 )
 
 // schema SQLite数据库schema
@@ -52,6 +56,18 @@ CREATE VIRTUAL TABLE IF NOT EXISTS documents_fts USING fts5(
     tokenize='porter unicode61'
 );
 
+-- CJK全文索引：汉字/假名/谚文切分为双字词（segmentCJK），包含CJK文字的查询路由到这里
+CREATE VIRTUAL TABLE IF NOT EXISTS documents_fts_cjk USING fts5(
+    filepath, title, body,
+    tokenize='porter unicode61'
+);
+
+-- 内容语言（按内容哈希，写入时由langdetect检测）
+CREATE TABLE IF NOT EXISTS content_languages (
+    hash TEXT PRIMARY KEY,
+    language TEXT NOT NULL
+);
+
 -- LLM缓存
 CREATE TABLE IF NOT EXISTS llm_cache (
     hash TEXT PRIMARY KEY,
@@ -59,7 +75,7 @@ CREATE TABLE IF NOT EXISTS llm_cache (
     created_at TEXT NOT NULL
 );
 
--- 记忆存储
+-- 记忆存储（命名空间和召回统计列由migrateMemories补齐旧数据库并建立索引）
 CREATE TABLE IF NOT EXISTS memories (
     id TEXT PRIMARY KEY,
     type TEXT NOT NULL,
@@ -69,7 +85,13 @@ CREATE TABLE IF NOT EXISTS memories (
     timestamp TEXT NOT NULL,
     expires_at TEXT,
     importance REAL NOT NULL DEFAULT 0.5,
-    embedding BLOB
+    embedding BLOB,
+    tenant TEXT NOT NULL DEFAULT '',
+    user_id TEXT NOT NULL DEFAULT '',
+    agent_id TEXT NOT NULL DEFAULT '',
+    session_id TEXT NOT NULL DEFAULT '',
+    access_count INTEGER NOT NULL DEFAULT 0,
+    last_accessed TEXT
 );
 
 -- 记忆索引
@@ -77,6 +99,114 @@ CREATE INDEX IF NOT EXISTS idx_memories_type ON memories(type);
 CREATE INDEX IF NOT EXISTS idx_memories_timestamp ON memories(timestamp DESC);
 CREATE INDEX IF NOT EXISTS idx_memories_expires ON memories(expires_at);
 
+-- 归档的记忆（遗忘策略移出活跃记忆，不参与召回，可恢复）
+CREATE TABLE IF NOT EXISTS archived_memories (
+    id TEXT PRIMARY KEY,
+    tenant TEXT NOT NULL DEFAULT '',
+    user_id TEXT NOT NULL DEFAULT '',
+    agent_id TEXT NOT NULL DEFAULT '',
+    session_id TEXT NOT NULL DEFAULT '',
+    type TEXT NOT NULL,
+    content TEXT NOT NULL,
+    metadata TEXT,
+    tags TEXT,
+    timestamp TEXT NOT NULL,
+    expires_at TEXT,
+    importance REAL NOT NULL DEFAULT 0.5,
+    embedding BLOB,
+    access_count INTEGER NOT NULL DEFAULT 0,
+    last_accessed TEXT,
+    archived_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_archived_memories_owner ON archived_memories(tenant, user_id, agent_id, session_id);
+
+-- 会话的滚动摘要（较早消息的摘要，按命名空间和会话保存）
+CREATE TABLE IF NOT EXISTS conversation_summaries (
+    tenant TEXT NOT NULL DEFAULT '',
+    user_id TEXT NOT NULL DEFAULT '',
+    agent_id TEXT NOT NULL DEFAULT '',
+    session_id TEXT NOT NULL,
+    summary TEXT NOT NULL,
+    through_id TEXT NOT NULL,
+    covered INTEGER NOT NULL,
+    tokens INTEGER NOT NULL DEFAULT 0,
+    updated_at TEXT NOT NULL,
+    PRIMARY KEY (tenant, user_id, agent_id, session_id)
+);
+
+-- 反思进度（按命名空间保存已参考到的最新记忆时间，及该秒内已参考的记忆ID）
+CREATE TABLE IF NOT EXISTS reflection_cursors (
+    tenant TEXT NOT NULL DEFAULT '',
+    user_id TEXT NOT NULL DEFAULT '',
+    agent_id TEXT NOT NULL DEFAULT '',
+    session_id TEXT NOT NULL DEFAULT '',
+    through TEXT NOT NULL,
+    through_ids TEXT NOT NULL DEFAULT '[]',
+    updated_at TEXT NOT NULL,
+    PRIMARY KEY (tenant, user_id, agent_id, session_id)
+);
+
+-- 知识图谱事实（主谓宾三元组，被取代的旧值保留为历史）
+CREATE TABLE IF NOT EXISTS facts (
+    id TEXT PRIMARY KEY,
+    tenant TEXT NOT NULL DEFAULT '',
+    user_id TEXT NOT NULL DEFAULT '',
+    agent_id TEXT NOT NULL DEFAULT '',
+    session_id TEXT NOT NULL DEFAULT '',
+    subject TEXT NOT NULL,
+    predicate TEXT NOT NULL,
+    object TEXT NOT NULL,
+    confidence REAL NOT NULL DEFAULT 0,
+    sources TEXT NOT NULL DEFAULT '{}',
+    valid_from TEXT NOT NULL,
+    superseded_at TEXT,
+    superseded_by TEXT NOT NULL DEFAULT '',
+    updated_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_facts_subject ON facts(subject, predicate);
+CREATE INDEX IF NOT EXISTS idx_facts_predicate ON facts(predicate, object);
+CREATE INDEX IF NOT EXISTS idx_facts_object ON facts(object);
+
+-- 函数型谓词：同一主体只有一个当前值，按命名空间保存
+CREATE TABLE IF NOT EXISTS functional_predicates (
+    tenant TEXT NOT NULL DEFAULT '',
+    user_id TEXT NOT NULL DEFAULT '',
+    agent_id TEXT NOT NULL DEFAULT '',
+    session_id TEXT NOT NULL DEFAULT '',
+    predicate TEXT NOT NULL,
+    PRIMARY KEY (tenant, user_id, agent_id, session_id, predicate)
+);
+
+-- 记忆全文索引的键：memories的主键是TEXT，隐式rowid在VACUUM后可能变化，
+-- 全文索引改用显式的INTEGER键（AUTOINCREMENT，删除后不复用）关联记忆
+CREATE TABLE IF NOT EXISTS memory_fts_keys (
+    fts_key INTEGER PRIMARY KEY AUTOINCREMENT,
+    memory_id TEXT NOT NULL UNIQUE
+);
+
+-- 记忆全文索引（rowid对应memory_fts_keys.fts_key，CJK文字切分为双字词）
+-- 索引行在写入记忆时由Go生成（indexMemoryFTS），旧版本依赖自定义SQL函数的触发器在此移除
+CREATE VIRTUAL TABLE IF NOT EXISTS memories_fts USING fts5(
+    content, tags,
+    tokenize='porter unicode61'
+);
+
+DROP TRIGGER IF EXISTS memories_fts_ai;
+DROP TRIGGER IF EXISTS memories_fts_au;
+DROP TRIGGER IF EXISTS memories_fts_ad;
+
+-- 触发器：内容或标签变更后移除旧的索引行，由indexMemoryFTS重新写入
+CREATE TRIGGER IF NOT EXISTS memories_fts_stale AFTER UPDATE OF content, tags ON memories BEGIN
+    DELETE FROM memories_fts WHERE rowid = (SELECT fts_key FROM memory_fts_keys WHERE memory_id = OLD.id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS memory_fts_keys_ad AFTER DELETE ON memories BEGIN
+    DELETE FROM memories_fts WHERE rowid = (SELECT fts_key FROM memory_fts_keys WHERE memory_id = OLD.id);
+    DELETE FROM memory_fts_keys WHERE memory_id = OLD.id;
+END;
+
 -- 集合管理
 CREATE TABLE IF NOT EXISTS collections (
     name TEXT PRIMARY KEY,
@@ -100,6 +230,104 @@ CREATE TABLE IF NOT EXISTS contexts (
 -- 上下文索引
 CREATE INDEX IF NOT EXISTS idx_contexts_path ON contexts(path);
 
+-- 排序配置
+CREATE TABLE IF NOT EXISTS ranking_profiles (
+    name TEXT PRIMARY KEY,
+    profile TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
+
+-- 集合绑定的排序配置
+CREATE TABLE IF NOT EXISTS collection_profiles (
+    collection TEXT PRIMARY KEY,
+    profile TEXT NOT NULL
+);
+
+-- 文档摘要（按内容哈希存储，内容变化后哈希改变即需重新生成）
+CREATE TABLE IF NOT EXISTS document_summaries (
+    hash TEXT PRIMARY KEY,
+    summary TEXT NOT NULL,
+    model TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+-- 摘要全文索引
+CREATE VIRTUAL TABLE IF NOT EXISTS summaries_fts USING fts5(
+    hash UNINDEXED, summary,
+    tokenize='porter unicode61'
+);
+
+-- 查询日志
+CREATE TABLE IF NOT EXISTS query_log (
+    id TEXT PRIMARY KEY,
+    timestamp TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    query TEXT NOT NULL,
+    collection TEXT NOT NULL DEFAULT '',
+    strategy TEXT NOT NULL DEFAULT '',
+    latency_us INTEGER NOT NULL,
+    result_count INTEGER NOT NULL,
+    results TEXT
+);
+
+-- 查询日志索引
+CREATE INDEX IF NOT EXISTS idx_query_log_timestamp ON query_log(timestamp);
+
+-- 相关性反馈（同一查询、文档和信号只记录一次）
+CREATE TABLE IF NOT EXISTS feedback (
+    query_id TEXT NOT NULL,
+    doc_path TEXT NOT NULL,
+    signal TEXT NOT NULL,
+    weight REAL NOT NULL,
+    query TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    PRIMARY KEY (query_id, doc_path, signal)
+);
+
+-- 反馈索引
+CREATE INDEX IF NOT EXISTS idx_feedback_created ON feedback(created_at);
+
+-- 集合的向量量化方式
+CREATE TABLE IF NOT EXISTS collection_quantization (
+    collection TEXT PRIMARY KEY,
+    mode TEXT NOT NULL
+);
+
+-- 量化向量编码（与content_vectors按hash、seq对应）
+-- int8模式下content_vectors.embedding为空，向量由int8编码和缩放系数还原
+CREATE TABLE IF NOT EXISTS vector_codes (
+    hash TEXT NOT NULL,
+    seq INTEGER NOT NULL,
+    bits BLOB NOT NULL,
+    int8 BLOB,
+    scale REAL NOT NULL DEFAULT 0,
+    PRIMARY KEY (hash, seq)
+);
+
+-- 结构化数据的行记录（CSV行或JSON记录，每行对应一个文档）
+CREATE TABLE IF NOT EXISTS records (
+    collection TEXT NOT NULL,
+    path TEXT NOT NULL,
+    source_path TEXT NOT NULL,
+    row_index INTEGER NOT NULL,
+    fields TEXT NOT NULL,
+    PRIMARY KEY (collection, path)
+);
+
+CREATE INDEX IF NOT EXISTS idx_records_source ON records(collection, source_path);
+
+-- 行记录字段（值已规范化，用于过滤）
+CREATE TABLE IF NOT EXISTS record_fields (
+    collection TEXT NOT NULL,
+    path TEXT NOT NULL,
+    field TEXT NOT NULL,
+    value TEXT NOT NULL,
+    PRIMARY KEY (collection, path, field)
+);
+
+CREATE INDEX IF NOT EXISTS idx_record_fields_value ON record_fields(field, value);
+
 -- 触发器：INSERT时同步FTS
 CREATE TRIGGER IF NOT EXISTS documents_ai AFTER INSERT ON documents
 BEGIN
@@ -122,52 +350,237 @@ CREATE TRIGGER IF NOT EXISTS documents_ad AFTER DELETE ON documents
 BEGIN
     DELETE FROM documents_fts WHERE rowid = OLD.id;
 END;
+
+-- CJK全文索引和内容语言在写入时由Go计算（indexCJK、detectLanguage），
+-- 旧版本依赖自定义SQL函数的触发器在此移除
+DROP TRIGGER IF EXISTS documents_cjk_ai;
+DROP TRIGGER IF EXISTS documents_cjk_au;
+DROP TRIGGER IF EXISTS content_language_ai;
+
+-- 触发器：文档变更后移除旧的CJK索引行，有效文档由indexCJK重新写入
+CREATE TRIGGER IF NOT EXISTS documents_cjk_stale AFTER UPDATE ON documents
+BEGIN
+    DELETE FROM documents_fts_cjk WHERE rowid = OLD.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS documents_cjk_ad AFTER DELETE ON documents
+BEGIN
+    DELETE FROM documents_fts_cjk WHERE rowid = OLD.id;
+END;
 `
 
+// Options Store配置选项
+type Options struct {
+	// BusyTimeout 等待其他连接/进程释放锁的最长时间（SQLite busy_timeout）
+	BusyTimeout time.Duration
+	// MaxReadConns 读连接池大小
+	MaxReadConns int
+	// MaxRetries 写操作遇到SQLITE_BUSY时的最大重试次数
+	MaxRetries int
+	// RetryBackoff 首次重试前的等待时间（之后指数增长）
+	RetryBackoff time.Duration
+}
+
+// DefaultOptions 返回默认Store选项
+func DefaultOptions() Options {
+	return Options{
+		BusyTimeout:  5 * time.Second,
+		MaxReadConns: 4,
+		MaxRetries:   5,
+		RetryBackoff: 50 * time.Millisecond,
+	}
+}
+
 // Store 数据存储
+//
+// Store 可被多个goroutine并发使用：
+// - 写操作走单连接的写池，在进程内串行化，避免同进程内的写锁竞争
+// - 读操作走独立的只读连接池，WAL模式下读写互不阻塞
+// - 跨进程的锁竞争由busy_timeout等待，仍返回SQLITE_BUSY时按指数退避重试
 type Store struct {
-	db     *sql.DB
+	db     *sql.DB // 写连接池（单连接）
+	readDB *sql.DB // 读连接池
 	dbPath string
+	opts   Options
 }
 
 // New 创建新的Store实例
 func New(dbPath string) (*Store, error) {
-	// 打开数据库
-	db, err := sql.Open("sqlite3", dbPath)
+	return NewWithOptions(dbPath, DefaultOptions())
+}
+
+// MemoryDBPath 临时内存数据库路径，Store关闭后数据即丢弃
+const MemoryDBPath = ":memory:"
+
+// NewWithOptions 使用指定选项创建Store实例
+// dbPath为MemoryDBPath时创建临时内存数据库
+func NewWithOptions(dbPath string, opts Options) (*Store, error) {
+	defaults := DefaultOptions()
+	if opts.BusyTimeout <= 0 {
+		opts.BusyTimeout = defaults.BusyTimeout
+	}
+	if opts.MaxReadConns <= 0 {
+		opts.MaxReadConns = defaults.MaxReadConns
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = defaults.RetryBackoff
+	}
+
+	busyMS := opts.BusyTimeout.Milliseconds()
+
+	// 内存数据库使用memdb VFS的命名数据库：同一进程内的读写连接池共享同一份数据，
+	// 按普通文件锁（busy_timeout）协调，读连接持有结果集时写操作不会死锁
+	// memdb不支持WAL，日志模式保持默认
+	ephemeral := dbPath == MemoryDBPath
+	dsn := dbPath
+	if ephemeral {
+		dsn = fmt.Sprintf("file:/mmq-%s?vfs=memdb", uuid.New().String())
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+
+	// 打开写连接
+	// WAL模式 + 外键约束 + busy_timeout，事务以IMMEDIATE方式开启，
+	// 避免读事务升级为写事务时出现无法等待的SQLITE_BUSY
+	db, err := sql.Open("sqlite3", fmt.Sprintf(
+		"%s%s_busy_timeout=%d&_journal_mode=WAL&_foreign_keys=on&_txlock=immediate",
+		dsn, sep, busyMS))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	db.SetMaxOpenConns(1)
 
-	// 启用WAL模式（Write-Ahead Logging）
-	if _, err := db.Exec("PRAGMA journal_mode = WAL"); err != nil {
-		return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
+	// 内存数据库在最后一个连接关闭时释放：保持写连接常驻
+	if ephemeral {
+		db.SetMaxIdleConns(1)
+		db.SetConnMaxLifetime(0)
+		db.SetConnMaxIdleTime(0)
 	}
 
-	// 启用外键约束
-	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
-		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
+	s := &Store{
+		db:     db,
+		dbPath: dbPath,
+		opts:   opts,
 	}
 
-	// 初始化schema
-	if _, err := db.Exec(schema); err != nil {
+	// 初始化schema（多个进程可能同时初始化，遇到锁时重试）
+	err = s.withRetry(func() error {
+		_, err := db.Exec(schema)
+		return err
+	})
+	if err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
-	return &Store{
-		db:     db,
-		dbPath: dbPath,
-	}, nil
+	if err := s.backfillLanguages(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := s.migrateMemories(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := s.backfillMemoryFTS(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := s.backfillFacts(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// 打开只读连接池
+	readDB, err := sql.Open("sqlite3", fmt.Sprintf(
+		"%s%s_busy_timeout=%d&_query_only=1",
+		dsn, sep, busyMS))
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open read pool: %w", err)
+	}
+	readDB.SetMaxOpenConns(opts.MaxReadConns)
+	readDB.SetMaxIdleConns(opts.MaxReadConns)
+	s.readDB = readDB
+
+	return s, nil
 }
 
 // Close 关闭数据库连接
 func (s *Store) Close() error {
+	var firstErr error
+	if s.readDB != nil && s.readDB != s.db {
+		firstErr = s.readDB.Close()
+	}
 	if s.db != nil {
-		return s.db.Close()
+		if err := s.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
 	}
-	return nil
+	return firstErr
 }
 
-// DB 返回底层数据库连接（用于高级操作）
+// DB 返回底层写数据库连接（用于高级操作）
 func (s *Store) DB() *sql.DB {
 	return s.db
 }
+
+// isBusyError 判断错误是否为SQLITE_BUSY/SQLITE_LOCKED
+func isBusyError(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
+
+// withRetry 执行操作，遇到SQLITE_BUSY时按指数退避重试
+func (s *Store) withRetry(op func() error) error {
+	backoff := s.opts.RetryBackoff
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = op()
+		if err == nil || !isBusyError(err) || attempt >= s.opts.MaxRetries {
+			return err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// exec 在写连接上执行语句（带SQLITE_BUSY重试）
+func (s *Store) exec(query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := s.withRetry(func() error {
+		var err error
+		result, err = s.db.Exec(query, args...)
+		return err
+	})
+	return result, err
+}
+
+// withTx 在写事务中执行fn，提交失败或遇到SQLITE_BUSY时整体重试
+func (s *Store) withTx(fn func(tx *sql.Tx) error) error {
+	return s.withRetry(func() error {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+
+		if err := fn(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
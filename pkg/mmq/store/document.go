@@ -13,51 +13,49 @@ func (s *Store) IndexDocument(doc Document) error {
 	// 1. 计算内容哈希
 	hash := computeHash(doc.Content)
 
-	// 2. 检查内容是否已存在
-	var exists bool
-	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM content WHERE hash = ?)", hash).Scan(&exists)
-	if err != nil {
-		return fmt.Errorf("failed to check content existence: %w", err)
+	// 2. 设置时间
+	if doc.CreatedAt.IsZero() {
+		doc.CreatedAt = time.Now().UTC()
+	}
+	if doc.ModifiedAt.IsZero() {
+		doc.ModifiedAt = time.Now().UTC()
 	}
 
-	// 3. 插入内容（如果不存在）
-	if !exists {
-		now := time.Now().UTC().Format(time.RFC3339)
-		_, err = s.db.Exec(
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	// 内容和文档记录在同一事务中写入，避免并发索引时出现半写状态
+	return s.withTx(func(tx *sql.Tx) error {
+		// 3. 插入内容（已存在则忽略）并检测语言
+		_, err := tx.Exec(
 			"INSERT OR IGNORE INTO content (hash, doc, created_at) VALUES (?, ?, ?)",
 			hash, doc.Content, now,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert content: %w", err)
 		}
-	}
-
-	// 4. 插入或更新文档记录
-	if doc.CreatedAt.IsZero() {
-		doc.CreatedAt = time.Now().UTC()
-	}
-	if doc.ModifiedAt.IsZero() {
-		doc.ModifiedAt = time.Now().UTC()
-	}
-
-	// 使用REPLACE确保路径唯一性
-	_, err = s.db.Exec(`
-		INSERT INTO documents (collection, path, title, hash, created_at, modified_at, active)
-		VALUES (?, ?, ?, ?, ?, ?, 1)
-		ON CONFLICT(collection, path) DO UPDATE SET
-			title = excluded.title,
-			hash = excluded.hash,
-			modified_at = excluded.modified_at,
-			active = 1
-	`, doc.Collection, doc.Path, doc.Title, hash,
-	   doc.CreatedAt.Format(time.RFC3339),
-	   doc.ModifiedAt.Format(time.RFC3339))
+		if err := detectLanguage(tx, hash, doc.Content); err != nil {
+			return err
+		}
 
-	if err != nil {
-		return fmt.Errorf("failed to insert document: %w", err)
-	}
+		// 4. 插入或更新文档记录（使用UPSERT确保路径唯一性）
+		_, err = tx.Exec(`
+			INSERT INTO documents (collection, path, title, hash, created_at, modified_at, active)
+			VALUES (?, ?, ?, ?, ?, ?, 1)
+			ON CONFLICT(collection, path) DO UPDATE SET
+				title = excluded.title,
+				hash = excluded.hash,
+				modified_at = excluded.modified_at,
+				active = 1
+		`, doc.Collection, doc.Path, doc.Title, hash,
+			doc.CreatedAt.Format(time.RFC3339),
+			doc.ModifiedAt.Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("failed to insert document: %w", err)
+		}
 
-	return nil
+		// 5. 包含CJK文字的文档写入双字切分索引
+		return indexCJK(tx, "d.collection = ? AND d.path = ?", doc.Collection, doc.Path)
+	})
 }
 
 // GetDocument 获取文档
@@ -74,7 +72,7 @@ func (s *Store) GetDocument(id string) (*Document, error) {
 		LIMIT 1
 	`
 
-	err := s.db.QueryRow(query, id, id, id).Scan(
+	err := s.readDB.QueryRow(query, id, id, id).Scan(
 		&doc.ID, &doc.Collection, &doc.Path, &doc.Title, &doc.Content,
 		&createdAt, &modifiedAt,
 	)
@@ -95,7 +93,7 @@ func (s *Store) GetDocument(id string) (*Document, error) {
 
 // DeleteDocument 删除文档（软删除）
 func (s *Store) DeleteDocument(id string) error {
-	result, err := s.db.Exec(`
+	result, err := s.exec(`
 		UPDATE documents
 		SET active = 0
 		WHERE (id = ? OR hash = ? OR path = ?) AND active = 1
@@ -130,7 +128,7 @@ func (s *Store) ListDocuments(collection string, limit, offset int) ([]Document,
 	query += " ORDER BY d.modified_at DESC LIMIT ? OFFSET ?"
 	args = append(args, limit, offset)
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.readDB.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list documents: %w", err)
 	}
@@ -161,13 +159,13 @@ func (s *Store) GetStatus() (Status, error) {
 	status.DBPath = s.dbPath
 
 	// 统计总文档数
-	err := s.db.QueryRow("SELECT COUNT(*) FROM documents WHERE active = 1").Scan(&status.TotalDocuments)
+	err := s.readDB.QueryRow("SELECT COUNT(*) FROM documents WHERE active = 1").Scan(&status.TotalDocuments)
 	if err != nil {
 		return status, fmt.Errorf("failed to count documents: %w", err)
 	}
 
 	// 统计需要嵌入的文档数
-	err = s.db.QueryRow(`
+	err = s.readDB.QueryRow(`
 		SELECT COUNT(DISTINCT d.hash)
 		FROM documents d
 		LEFT JOIN content_vectors v ON d.hash = v.hash AND v.seq = 0
@@ -178,7 +176,7 @@ func (s *Store) GetStatus() (Status, error) {
 	}
 
 	// 获取集合列表
-	rows, err := s.db.Query("SELECT DISTINCT collection FROM documents WHERE active = 1 ORDER BY collection")
+	rows, err := s.readDB.Query("SELECT DISTINCT collection FROM documents WHERE active = 1 ORDER BY collection")
 	if err != nil {
 		return status, fmt.Errorf("failed to list collections: %w", err)
 	}
@@ -0,0 +1,61 @@
+package store
+
+// DocumentFilter 文档检索的过滤条件，各条件之间为AND关系，零值不过滤
+// 条件在检索查询中应用，不会先取候选再丢弃
+type DocumentFilter struct {
+	Collection string            // 集合
+	Fields     map[string]string // 行记录字段全部匹配（值忽略大小写和首尾空白）
+	Language   string            // 文档内容的语言（ISO 639-1代码）
+}
+
+// clause 生成WHERE条件（documents表别名为d）
+func (f DocumentFilter) clause() ([]string, []interface{}) {
+	var conds []string
+	var args []interface{}
+
+	if f.Collection != "" {
+		conds = append(conds, "d.collection = ?")
+		args = append(args, f.Collection)
+	}
+
+	if f.Language != "" {
+		conds = append(conds, "EXISTS (SELECT 1 FROM content_languages cl WHERE cl.hash = d.hash AND cl.language = ?)")
+		args = append(args, f.Language)
+	}
+
+	for _, field := range sortedFilterFields(f.Fields) {
+		conds = append(conds, `EXISTS (
+			SELECT 1 FROM record_fields rf
+			WHERE rf.collection = d.collection AND rf.path = d.path AND rf.field = ? AND rf.value = ?)`)
+		args = append(args, field, normalizeFieldValue(f.Fields[field]))
+	}
+
+	return conds, args
+}
+
+// where 生成追加到已有WHERE子句后的条件
+func (f DocumentFilter) where() (string, []interface{}) {
+	conds, args := f.clause()
+	sql := ""
+	for _, cond := range conds {
+		sql += " AND " + cond
+	}
+	return sql, args
+}
+
+// matchFields 行记录字段是否满足过滤条件，没有字段条件时总是满足
+func (f DocumentFilter) matchFields(r *StructuredRecord) bool {
+	if len(f.Fields) == 0 {
+		return true
+	}
+	if r == nil {
+		return false
+	}
+	for field, value := range f.Fields {
+		actual, ok := r.Fields[field]
+		if !ok || normalizeFieldValue(actual) != normalizeFieldValue(value) {
+			return false
+		}
+	}
+	return true
+}
@@ -43,7 +43,7 @@ func (s *Store) ListDocumentsByPath(collection, path string) ([]DocumentListEntr
 
 	if collection == "" {
 		// 列出所有文档（按集合分组）
-		rows, err = s.db.Query(`
+		rows, err = s.readDB.Query(`
 			SELECT
 				id,
 				collection,
@@ -58,7 +58,7 @@ func (s *Store) ListDocumentsByPath(collection, path string) ([]DocumentListEntr
 		`)
 	} else if path == "" {
 		// 列出集合下所有文档
-		rows, err = s.db.Query(`
+		rows, err = s.readDB.Query(`
 			SELECT
 				id,
 				collection,
@@ -74,7 +74,7 @@ func (s *Store) ListDocumentsByPath(collection, path string) ([]DocumentListEntr
 	} else {
 		// 列出路径下的文档（前缀匹配）
 		pathPrefix := strings.TrimSuffix(path, "/")
-		rows, err = s.db.Query(`
+		rows, err = s.readDB.Query(`
 			SELECT
 				id,
 				collection,
@@ -140,7 +140,7 @@ func (s *Store) GetDocumentByPath(filePath string) (*DocumentDetail, error) {
 	var createdStr, modifiedStr string
 	var content string
 
-	err := s.db.QueryRow(`
+	err := s.readDB.QueryRow(`
 		SELECT
 			d.id,
 			d.collection,
@@ -196,7 +196,7 @@ func (s *Store) GetDocumentByID(docID string) (*DocumentDetail, error) {
 	var content string
 
 	// 使用 LIKE 匹配前缀
-	err := s.db.QueryRow(`
+	err := s.readDB.QueryRow(`
 		SELECT
 			d.id,
 			d.collection,
@@ -249,10 +249,10 @@ func (s *Store) GetMultipleDocuments(pattern string, maxBytes int) ([]*DocumentD
 		return s.getDocumentsByGlob(pattern, maxBytes)
 	} else if strings.Contains(pattern, ",") {
 		// 逗号分隔列表
-		return s.getDocumentsByList(pattern, maxBytes)
+		return getDocumentsByList(s, pattern, maxBytes)
 	} else {
 		// 单个文件
-		doc, err := s.getDocumentSingle(pattern, maxBytes)
+		doc, err := getDocumentSingle(s, pattern, maxBytes)
 		if err != nil {
 			return nil, err
 		}
@@ -270,7 +270,7 @@ func (s *Store) getDocumentsByGlob(pattern string, maxBytes int) ([]*DocumentDet
 
 	if collection == "" {
 		// 全局匹配
-		rows, err = s.db.Query(`
+		rows, err = s.readDB.Query(`
 			SELECT
 				d.id,
 				d.collection,
@@ -288,7 +288,7 @@ func (s *Store) getDocumentsByGlob(pattern string, maxBytes int) ([]*DocumentDet
 		`)
 	} else {
 		// 集合内匹配
-		rows, err = s.db.Query(`
+		rows, err = s.readDB.Query(`
 			SELECT
 				d.id,
 				d.collection,
@@ -338,17 +338,8 @@ func (s *Store) getDocumentsByGlob(pattern string, maxBytes int) ([]*DocumentDet
 		}
 
 		// Glob匹配
-		matched, _ := filepath.Match(pathPattern, doc.Path)
-		if !matched && pathPattern != "" {
-			// 尝试双星匹配（递归目录）
-			if !strings.Contains(pathPattern, "**") {
-				continue
-			}
-			// 简化的**匹配：移除**并检查后缀
-			simplifiedPattern := strings.ReplaceAll(pathPattern, "**/", "")
-			if !strings.HasSuffix(doc.Path, simplifiedPattern) {
-				continue
-			}
+		if !matchDocumentGlob(pathPattern, doc.Path) {
+			continue
 		}
 
 		doc.CreatedAt, _ = time.Parse(time.RFC3339, createdStr)
@@ -361,8 +352,35 @@ func (s *Store) getDocumentsByGlob(pattern string, maxBytes int) ([]*DocumentDet
 	return docs, nil
 }
 
+// matchDocumentGlob 检查文档路径是否匹配Glob模式（空模式匹配所有文档）
+func matchDocumentGlob(pathPattern, docPath string) bool {
+	if pathPattern == "" {
+		return true
+	}
+
+	matched, _ := filepath.Match(pathPattern, docPath)
+	if matched {
+		return true
+	}
+
+	// 尝试双星匹配（递归目录）
+	if !strings.Contains(pathPattern, "**") {
+		return false
+	}
+
+	// 简化的**匹配：移除**并检查后缀
+	simplifiedPattern := strings.ReplaceAll(pathPattern, "**/", "")
+	return strings.HasSuffix(docPath, simplifiedPattern)
+}
+
+// documentGetter 按路径或docid获取单个文档，批量获取逻辑由各后端共用
+type documentGetter interface {
+	GetDocumentByPath(filePath string) (*DocumentDetail, error)
+	GetDocumentByID(docID string) (*DocumentDetail, error)
+}
+
 // getDocumentsByList 通过逗号分隔列表获取文档
-func (s *Store) getDocumentsByList(list string, maxBytes int) ([]*DocumentDetail, error) {
+func getDocumentsByList(s documentGetter, list string, maxBytes int) ([]*DocumentDetail, error) {
 	items := strings.Split(list, ",")
 	var docs []*DocumentDetail
 
@@ -372,7 +390,7 @@ func (s *Store) getDocumentsByList(list string, maxBytes int) ([]*DocumentDetail
 			continue
 		}
 
-		doc, err := s.getDocumentSingle(item, maxBytes)
+		doc, err := getDocumentSingle(s, item, maxBytes)
 		if err != nil {
 			// 跳过错误，继续处理其他文档
 			continue
@@ -388,7 +406,7 @@ func (s *Store) getDocumentsByList(list string, maxBytes int) ([]*DocumentDetail
 }
 
 // getDocumentSingle 获取单个文档（按路径或docid）
-func (s *Store) getDocumentSingle(identifier string, maxBytes int) (*DocumentDetail, error) {
+func getDocumentSingle(s documentGetter, identifier string, maxBytes int) (*DocumentDetail, error) {
 	// 判断是docid还是路径
 	var doc *DocumentDetail
 	var err error
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"database/sql"
 	"fmt"
 	"time"
 )
@@ -16,7 +17,7 @@ func (s *Store) GetDocumentsNeedingEmbedding() ([]Document, error) {
 		ORDER BY d.modified_at DESC
 	`
 
-	rows, err := s.db.Query(query)
+	rows, err := s.readDB.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query documents: %w", err)
 	}
@@ -42,10 +43,24 @@ func (s *Store) StoreEmbedding(hash string, seq int, pos int, embedding []float3
 
 	now := time.Now().UTC().Format(time.RFC3339)
 
-	_, err := s.db.Exec(`
-		INSERT OR REPLACE INTO content_vectors (hash, seq, pos, embedding, model, embedded_at)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`, hash, seq, pos, blob, model, now)
+	// 新向量按引用该内容的集合设置量化后写入，旧的量化编码随之替换
+	err := s.withTx(func(tx *sql.Tx) error {
+		target, err := hashQuantization(tx, hash)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			INSERT OR REPLACE INTO content_vectors (hash, seq, pos, embedding, model, embedded_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, hash, seq, pos, blob, model, now); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM vector_codes WHERE hash = ? AND seq = ?", hash, seq); err != nil {
+			return err
+		}
+		_, err = convertVector(tx, hash, seq, storedVector{blob: blob}, target)
+		return err
+	})
 
 	if err != nil {
 		return fmt.Errorf("failed to store embedding: %w", err)
@@ -56,26 +71,30 @@ func (s *Store) StoreEmbedding(hash string, seq int, pos int, embedding []float3
 
 // GetEmbedding 获取嵌入向量
 func (s *Store) GetEmbedding(hash string, seq int) ([]float32, error) {
-	var blob []byte
+	var v storedVector
 
-	err := s.db.QueryRow(`
-		SELECT embedding FROM content_vectors
-		WHERE hash = ? AND seq = ?
-	`, hash, seq).Scan(&blob)
+	err := s.readDB.QueryRow(`
+		SELECT cv.embedding, q.int8, COALESCE(q.scale, 0)
+		FROM content_vectors cv
+		LEFT JOIN vector_codes q ON q.hash = cv.hash AND q.seq = cv.seq
+		WHERE cv.hash = ? AND cv.seq = ?
+	`, hash, seq).Scan(&v.blob, &v.int8, &v.scale)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to get embedding: %w", err)
 	}
 
-	return blobToFloat32(blob), nil
+	return v.float32s(), nil
 }
 
 // GetAllEmbeddings 获取文档的所有嵌入向量
 func (s *Store) GetAllEmbeddings(hash string) ([][]float32, error) {
-	rows, err := s.db.Query(`
-		SELECT seq, embedding FROM content_vectors
-		WHERE hash = ?
-		ORDER BY seq
+	rows, err := s.readDB.Query(`
+		SELECT cv.seq, cv.embedding, q.int8, COALESCE(q.scale, 0)
+		FROM content_vectors cv
+		LEFT JOIN vector_codes q ON q.hash = cv.hash AND q.seq = cv.seq
+		WHERE cv.hash = ?
+		ORDER BY cv.seq
 	`, hash)
 
 	if err != nil {
@@ -86,22 +105,56 @@ func (s *Store) GetAllEmbeddings(hash string) ([][]float32, error) {
 	var embeddings [][]float32
 	for rows.Next() {
 		var seq int
-		var blob []byte
+		var v storedVector
 
-		err := rows.Scan(&seq, &blob)
+		err := rows.Scan(&seq, &v.blob, &v.int8, &v.scale)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan embedding: %w", err)
 		}
 
-		embeddings = append(embeddings, blobToFloat32(blob))
+		embeddings = append(embeddings, v.float32s())
 	}
 
 	return embeddings, nil
 }
 
+// GetChunkEmbeddings 获取内容的所有块向量及块位置（按块序号）
+func (s *Store) GetChunkEmbeddings(hash string) ([]ChunkEmbedding, error) {
+	rows, err := s.readDB.Query(`
+		SELECT cv.seq, cv.pos, cv.embedding, q.int8, COALESCE(q.scale, 0)
+		FROM content_vectors cv
+		LEFT JOIN vector_codes q ON q.hash = cv.hash AND q.seq = cv.seq
+		WHERE cv.hash = ?
+		ORDER BY cv.seq
+	`, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []ChunkEmbedding
+	for rows.Next() {
+		var c ChunkEmbedding
+		var v storedVector
+		if err := rows.Scan(&c.Seq, &c.Pos, &v.blob, &v.int8, &v.scale); err != nil {
+			return nil, fmt.Errorf("failed to scan embedding: %w", err)
+		}
+		c.Embedding = v.float32s()
+		chunks = append(chunks, c)
+	}
+
+	return chunks, rows.Err()
+}
+
 // DeleteEmbeddings 删除文档的所有嵌入
 func (s *Store) DeleteEmbeddings(hash string) error {
-	_, err := s.db.Exec("DELETE FROM content_vectors WHERE hash = ?", hash)
+	err := s.withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM content_vectors WHERE hash = ?", hash); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM vector_codes WHERE hash = ?", hash)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to delete embeddings: %w", err)
 	}
@@ -111,7 +164,7 @@ func (s *Store) DeleteEmbeddings(hash string) error {
 // CountEmbeddedDocuments 统计已嵌入的文档数
 func (s *Store) CountEmbeddedDocuments() (int, error) {
 	var count int
-	err := s.db.QueryRow(`
+	err := s.readDB.QueryRow(`
 		SELECT COUNT(DISTINCT hash) FROM content_vectors
 	`).Scan(&count)
 
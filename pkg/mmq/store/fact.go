@@ -0,0 +1,466 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FactRecord 知识图谱中的事实（主谓宾三元组）
+type FactRecord struct {
+	ID           string
+	Namespace    Namespace
+	Subject      string
+	Predicate    string
+	Object       string
+	Confidence   float64            // 各来源综合后的置信度
+	Sources      map[string]float64 // 来源 -> 该来源给出的置信度
+	ValidFrom    time.Time          // 开始生效的时间
+	SupersededAt *time.Time         // 被新值取代的时间，nil表示当前有效
+	SupersededBy string             // 取代它的事实ID
+	UpdatedAt    time.Time
+}
+
+// FactPattern 事实查询模式，各条件之间为AND关系
+// 字段为空或"*"时不限；含"*"时按通配符匹配（如 "Ali*"），否则精确匹配
+type FactPattern struct {
+	Subject       string
+	Predicate     string
+	Object        string
+	Entity        string  // 主体或客体任一端匹配（用于图遍历）
+	MinConfidence float64 // confidence >= MinConfidence
+	History       bool    // 包含已被取代的事实
+}
+
+// fieldClause 生成单个字段的匹配条件
+func fieldClause(column, value string) (string, interface{}, bool) {
+	if value == "" || value == "*" {
+		return "", nil, false
+	}
+	if !strings.Contains(value, "*") {
+		return column + " = ?", value, true
+	}
+
+	// GLOB中只保留*为通配符，?和[按字面匹配
+	var b strings.Builder
+	for _, r := range value {
+		switch r {
+		case '?':
+			b.WriteString("[?]")
+		case '[':
+			b.WriteString("[[]")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return column + " GLOB ?", b.String(), true
+}
+
+// clause 生成WHERE条件（facts表别名为n）
+func (p FactPattern) clause() ([]string, []interface{}) {
+	var conds []string
+	var args []interface{}
+
+	for _, f := range []struct{ column, value string }{
+		{"n.subject", p.Subject},
+		{"n.predicate", p.Predicate},
+		{"n.object", p.Object},
+	} {
+		if cond, arg, ok := fieldClause(f.column, f.value); ok {
+			conds = append(conds, cond)
+			args = append(args, arg)
+		}
+	}
+
+	if cond, arg, ok := fieldClause("n.subject", p.Entity); ok {
+		objCond, _, _ := fieldClause("n.object", p.Entity)
+		conds = append(conds, "("+cond+" OR "+objCond+")")
+		args = append(args, arg, arg)
+	}
+
+	if p.MinConfidence > 0 {
+		conds = append(conds, "n.confidence >= ?")
+		args = append(args, p.MinConfidence)
+	}
+	if !p.History {
+		conds = append(conds, "n.superseded_at IS NULL")
+	}
+
+	return conds, args
+}
+
+// Match 事实是否满足查询模式（与SQL条件语义一致）
+func (p FactPattern) Match(f FactRecord) bool {
+	if !matchField(p.Subject, f.Subject) || !matchField(p.Predicate, f.Predicate) || !matchField(p.Object, f.Object) {
+		return false
+	}
+	if !matchField(p.Entity, f.Subject) && !matchField(p.Entity, f.Object) {
+		return false
+	}
+	if p.MinConfidence > 0 && f.Confidence < p.MinConfidence {
+		return false
+	}
+	return p.History || f.SupersededAt == nil
+}
+
+// matchField 按FactPattern的规则匹配单个字段（*匹配任意字符序列）
+func matchField(pattern, value string) bool {
+	if pattern == "" || pattern == "*" {
+		return true
+	}
+	if !strings.Contains(pattern, "*") {
+		return pattern == value
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+	return strings.HasSuffix(value, last)
+}
+
+// FactAssertion 一次事实断言
+type FactAssertion struct {
+	Namespace  Namespace
+	Subject    string
+	Predicate  string
+	Object     string
+	Source     string
+	Confidence float64
+	At         time.Time // 断言时间，新事实从该时间开始生效
+}
+
+// FactAssertResult 断言结果
+type FactAssertResult struct {
+	Fact       FactRecord   // 断言后的事实（可能已被更新的值取代）
+	Superseded []FactRecord // 被本次断言取代的当前值
+}
+
+// resolveAssertion 根据主体该谓词在同一命名空间的当前值计算断言结果，两种后端共用
+// 同一三元组再次断言时合并来源：每个来源保留最高的置信度，综合置信度为 1-∏(1-c)；
+// 函数型谓词的新值取代其他当前值，断言时间早于某个当前值时，新值直接记为历史。
+// 返回的事实ID为空表示需要插入新事实（isNew），其余情况更新已有事实
+func resolveAssertion(a FactAssertion, current []FactRecord, functional bool) (record FactRecord, isNew bool, superseded []FactRecord) {
+	var existing *FactRecord
+	var others []FactRecord
+	for i := range current {
+		if current[i].Object == a.Object && existing == nil {
+			existing = &current[i]
+		} else {
+			others = append(others, current[i])
+		}
+	}
+
+	// 函数型谓词：找出比本次断言更新的当前值
+	var newer *FactRecord
+	if functional {
+		for i := range others {
+			if others[i].ValidFrom.After(a.At) && (newer == nil || others[i].ValidFrom.After(newer.ValidFrom)) {
+				newer = &others[i]
+			}
+		}
+	}
+
+	if existing != nil {
+		record = *existing
+		record.Sources = copySources(existing.Sources)
+		if record.Sources == nil {
+			record.Sources = make(map[string]float64)
+		}
+		if a.Confidence > record.Sources[a.Source] {
+			record.Sources[a.Source] = a.Confidence
+		}
+		record.Confidence = CombineConfidence(record.Sources)
+	} else {
+		isNew = true
+		record = FactRecord{
+			ID:         uuid.New().String(),
+			Namespace:  a.Namespace,
+			Subject:    a.Subject,
+			Predicate:  a.Predicate,
+			Object:     a.Object,
+			Confidence: a.Confidence,
+			Sources:    map[string]float64{a.Source: a.Confidence},
+			ValidFrom:  a.At,
+		}
+	}
+
+	if newer != nil {
+		supersededAt := newer.ValidFrom
+		record.SupersededAt, record.SupersededBy = &supersededAt, newer.ID
+		return record, isNew, nil
+	}
+
+	if functional {
+		for _, old := range others {
+			supersededAt := a.At
+			old.SupersededAt, old.SupersededBy = &supersededAt, record.ID
+			superseded = append(superseded, old)
+		}
+	}
+	return record, isNew, superseded
+}
+
+// CombineConfidence 综合多个来源的置信度（独立证据的noisy-OR：1-∏(1-c)）
+func CombineConfidence(sources map[string]float64) float64 {
+	disbelief := 1.0
+	for _, c := range sources {
+		disbelief *= 1 - c
+	}
+	return 1 - disbelief
+}
+
+// AssertFact 在同一事务中断言事实：读取当前值和函数型谓词设置，写入或合并事实，并标记被取代的旧值
+func (s *Store) AssertFact(a FactAssertion) (*FactAssertResult, error) {
+	var result *FactAssertResult
+	err := s.withTx(func(tx *sql.Tx) error {
+		ns := a.Namespace
+		rows, err := tx.Query(`
+			SELECT n.id, n.tenant, n.user_id, n.agent_id, n.session_id, n.subject, n.predicate, n.object,
+				n.confidence, n.sources, n.valid_from, n.superseded_at, n.superseded_by, n.updated_at
+			FROM facts n
+			WHERE n.tenant = ? AND n.user_id = ? AND n.agent_id = ? AND n.session_id = ?
+				AND n.subject = ? AND n.predicate = ? AND n.superseded_at IS NULL
+			ORDER BY datetime(n.valid_from), n.rowid
+		`, ns.Tenant, ns.User, ns.Agent, ns.Session, a.Subject, a.Predicate)
+		if err != nil {
+			return fmt.Errorf("failed to query facts: %w", err)
+		}
+		current, err := scanFacts(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		var functional bool
+		err = tx.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM functional_predicates
+				WHERE tenant = ? AND user_id = ? AND agent_id = ? AND session_id = ? AND predicate = ?)
+		`, ns.Tenant, ns.User, ns.Agent, ns.Session, a.Predicate).Scan(&functional)
+		if err != nil {
+			return fmt.Errorf("failed to load functional predicate: %w", err)
+		}
+
+		record, isNew, superseded := resolveAssertion(a, current, functional)
+		if isNew {
+			err = insertFact(tx, record)
+		} else {
+			err = updateFact(tx, record)
+		}
+		if err != nil {
+			return err
+		}
+		for _, old := range superseded {
+			if err := updateFact(tx, old); err != nil {
+				return err
+			}
+		}
+
+		result = &FactAssertResult{Fact: record, Superseded: superseded}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetFunctionalPredicates 将命名空间内的谓词设为函数型（同一主体只有一个当前值）
+func (s *Store) SetFunctionalPredicates(ns Namespace, predicates []string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		for _, p := range predicates {
+			_, err := tx.Exec(`
+				INSERT OR IGNORE INTO functional_predicates (tenant, user_id, agent_id, session_id, predicate)
+				VALUES (?, ?, ?, ?, ?)
+			`, ns.Tenant, ns.User, ns.Agent, ns.Session, p)
+			if err != nil {
+				return fmt.Errorf("failed to save functional predicate: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// InsertFact 插入事实，ID为空时自动生成，返回事实ID
+func (s *Store) InsertFact(fact FactRecord) (string, error) {
+	if fact.ID == "" {
+		fact.ID = uuid.New().String()
+	}
+	err := s.withTx(func(tx *sql.Tx) error {
+		return insertFact(tx, fact)
+	})
+	if err != nil {
+		return "", err
+	}
+	return fact.ID, nil
+}
+
+// UpdateFact 更新事实的置信度、来源和取代状态
+func (s *Store) UpdateFact(fact FactRecord) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		return updateFact(tx, fact)
+	})
+}
+
+func insertFact(tx *sql.Tx, fact FactRecord) error {
+	sourcesJSON, err := marshalSources(fact.Sources)
+	if err != nil {
+		return err
+	}
+
+	ns := fact.Namespace
+	_, err = tx.Exec(`
+		INSERT INTO facts (id, tenant, user_id, agent_id, session_id, subject, predicate, object,
+			confidence, sources, valid_from, superseded_at, superseded_by, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, fact.ID, ns.Tenant, ns.User, ns.Agent, ns.Session, fact.Subject, fact.Predicate, fact.Object,
+		fact.Confidence, sourcesJSON, fact.ValidFrom.Format(time.RFC3339), formatOptionalTime(fact.SupersededAt),
+		fact.SupersededBy, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to insert fact: %w", err)
+	}
+	return nil
+}
+
+func updateFact(tx *sql.Tx, fact FactRecord) error {
+	sourcesJSON, err := marshalSources(fact.Sources)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE facts SET confidence = ?, sources = ?, superseded_at = ?, superseded_by = ?, updated_at = ?
+		WHERE id = ?
+	`, fact.Confidence, sourcesJSON, formatOptionalTime(fact.SupersededAt), fact.SupersededBy,
+		time.Now().Format(time.RFC3339), fact.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update fact: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("fact not found: %s", fact.ID)
+	}
+	return nil
+}
+
+// QueryFacts 按模式查询事实（按生效时间顺序），limit<=0表示不限
+func (s *Store) QueryFacts(pattern FactPattern, scopes []Namespace, limit int) ([]FactRecord, error) {
+	conds, args := pattern.clause()
+	if clause, scopeArgs := scopeClause("n", scopes); clause != "" {
+		conds = append(conds, clause)
+		args = append(args, scopeArgs...)
+	}
+
+	query := `
+		SELECT n.id, n.tenant, n.user_id, n.agent_id, n.session_id, n.subject, n.predicate, n.object,
+			n.confidence, n.sources, n.valid_from, n.superseded_at, n.superseded_by, n.updated_at
+		FROM facts n
+	`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	if limit <= 0 {
+		limit = -1 // SQLite中LIMIT -1表示不限
+	}
+	query += " ORDER BY datetime(n.valid_from), n.rowid LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.readDB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query facts: %w", err)
+	}
+	defer rows.Close()
+
+	return scanFacts(rows)
+}
+
+// scanFacts 扫描事实查询结果（列顺序同QueryFacts）
+func scanFacts(rows *sql.Rows) ([]FactRecord, error) {
+	var facts []FactRecord
+	for rows.Next() {
+		var f FactRecord
+		var sourcesJSON, validFrom, updatedAt string
+		var supersededAt sql.NullString
+		err := rows.Scan(&f.ID, &f.Namespace.Tenant, &f.Namespace.User, &f.Namespace.Agent, &f.Namespace.Session,
+			&f.Subject, &f.Predicate, &f.Object, &f.Confidence, &sourcesJSON, &validFrom, &supersededAt,
+			&f.SupersededBy, &updatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan fact: %w", err)
+		}
+
+		_ = json.Unmarshal([]byte(sourcesJSON), &f.Sources)
+		f.ValidFrom, _ = time.Parse(time.RFC3339, validFrom)
+		f.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+		if supersededAt.Valid {
+			t, _ := time.Parse(time.RFC3339, supersededAt.String)
+			f.SupersededAt = &t
+		}
+		facts = append(facts, f)
+	}
+	return facts, rows.Err()
+}
+
+// DeleteFact 删除事实
+func (s *Store) DeleteFact(id string) error {
+	_, err := s.exec("DELETE FROM facts WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete fact: %w", err)
+	}
+	return nil
+}
+
+// backfillFacts 为旧版本写入的事实记忆建立知识图谱记录
+// 事实ID取metadata.fact_id，旧记忆没有时使用记忆ID
+func (s *Store) backfillFacts() error {
+	_, err := s.db.Exec(`
+		INSERT INTO facts (id, tenant, user_id, agent_id, session_id, subject, predicate, object,
+			confidence, sources, valid_from, updated_at)
+		SELECT COALESCE(json_extract(m.metadata, '$.fact_id'), m.id),
+			m.tenant, m.user_id, m.agent_id, m.session_id,
+			json_extract(m.metadata, '$.subject'), json_extract(m.metadata, '$.predicate'), json_extract(m.metadata, '$.object'),
+			COALESCE(json_extract(m.metadata, '$.confidence'), m.importance),
+			json_object(COALESCE(json_extract(m.metadata, '$.source'), ''), COALESCE(json_extract(m.metadata, '$.confidence'), m.importance)),
+			m.timestamp, m.timestamp
+		FROM memories m
+		WHERE m.type = 'fact'
+			AND json_type(m.metadata, '$.subject') = 'text'
+			AND json_type(m.metadata, '$.predicate') = 'text'
+			AND json_type(m.metadata, '$.object') = 'text'
+			AND NOT EXISTS (SELECT 1 FROM facts f WHERE f.id = COALESCE(json_extract(m.metadata, '$.fact_id'), m.id))
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to backfill facts: %w", err)
+	}
+	return nil
+}
+
+func marshalSources(sources map[string]float64) (string, error) {
+	if sources == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(sources)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal fact sources: %w", err)
+	}
+	return string(data), nil
+}
+
+func formatOptionalTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.Format(time.RFC3339)
+}
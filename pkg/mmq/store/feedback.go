@@ -0,0 +1,139 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultFeedbackHalfLife 反馈衰减的默认半衰期
+const DefaultFeedbackHalfLife = 30 * 24 * time.Hour
+
+// feedbackHorizon 超过该倍数半衰期的反馈（权重不足1/256）不再读取
+const feedbackHorizon = 8
+
+// Feedback 相关性反馈
+type Feedback struct {
+	QueryID   string    // 查询日志ID
+	Query     string    // 查询文本（用于查询词与文档的关联）
+	DocPath   string    // collection/path
+	Signal    string    // 信号类型，如 up、down、used
+	Weight    float64   // 信号权重（负数表示不相关）
+	CreatedAt time.Time // 记录时间
+}
+
+// SaveFeedback 保存反馈（同一查询、文档和信号重复记录时更新时间）
+func (s *Store) SaveFeedback(f Feedback) error {
+	if f.CreatedAt.IsZero() {
+		f.CreatedAt = time.Now()
+	}
+
+	_, err := s.exec(`
+		INSERT INTO feedback (query_id, doc_path, signal, weight, query, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(query_id, doc_path, signal) DO UPDATE SET
+			weight = excluded.weight,
+			created_at = excluded.created_at
+	`, f.QueryID, f.DocPath, f.Signal, f.Weight, f.Query, f.CreatedAt.UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to save feedback: %w", err)
+	}
+
+	return nil
+}
+
+// ListFeedback 列出since之后（含）的反馈，按时间升序
+func (s *Store) ListFeedback(since time.Time) ([]Feedback, error) {
+	rows, err := s.readDB.Query(`
+		SELECT query_id, doc_path, signal, weight, query, created_at
+		FROM feedback
+		WHERE created_at >= ?
+		ORDER BY created_at ASC, rowid ASC
+	`, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feedback: %w", err)
+	}
+	defer rows.Close()
+
+	var feedback []Feedback
+	for rows.Next() {
+		var f Feedback
+		var createdAt string
+		if err := rows.Scan(&f.QueryID, &f.DocPath, &f.Signal, &f.Weight, &f.Query, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan feedback: %w", err)
+		}
+		f.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		feedback = append(feedback, f)
+	}
+
+	return feedback, rows.Err()
+}
+
+// ApplyFeedbackBoost 将相关性反馈作为排序信号，并按新分数重新排序
+//
+// 每条反馈按 0.5^(age/halfLife) 衰减后累加为两部分：
+//   - 文档先验：文档收到的全部反馈（与查询无关）
+//   - 词关联：反馈所属查询的每个词与该文档的关联，按当前查询的词平均
+//
+// 学习信号 s = 0.5*先验 + 词关联，分数调整为 score * (1 + weight*tanh(s))，
+// 正反馈提升、负反馈降低排名，调整幅度不超过weight（乘数不低于0.05）。
+func ApplyFeedbackBoost(fs FeedbackStore, query string, results []SearchResult, weight float64, halfLife time.Duration, now time.Time) ([]SearchResult, error) {
+	if weight <= 0 || len(results) == 0 {
+		return results, nil
+	}
+	if halfLife <= 0 {
+		halfLife = DefaultFeedbackHalfLife
+	}
+
+	feedback, err := fs.ListFeedback(now.Add(-feedbackHorizon * halfLife))
+	if err != nil {
+		return nil, err
+	}
+	if len(feedback) == 0 {
+		return results, nil
+	}
+
+	terms := contextTerms(query)
+	queryTerms := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		queryTerms[t] = true
+	}
+
+	prior := make(map[string]float64)
+	assoc := make(map[string]float64)
+	for _, f := range feedback {
+		age := now.Sub(f.CreatedAt)
+		if age < 0 {
+			age = 0
+		}
+		value := f.Weight * math.Pow(0.5, float64(age)/float64(halfLife))
+
+		prior[f.DocPath] += value
+		if len(terms) == 0 {
+			continue
+		}
+		for _, t := range contextTerms(f.Query) {
+			if queryTerms[t] {
+				assoc[f.DocPath] += value / float64(len(terms))
+			}
+		}
+	}
+
+	for i := range results {
+		r := &results[i]
+		path := r.Collection + "/" + r.Path
+
+		signal := 0.5*prior[path] + assoc[path]
+		if signal == 0 {
+			continue
+		}
+		r.Score *= math.Max(0.05, 1+weight*math.Tanh(signal))
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	return results, nil
+}
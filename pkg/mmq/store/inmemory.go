@@ -0,0 +1,2647 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/crosszan/modu/pkg/mmq/internal/langdetect"
+	"github.com/crosszan/modu/pkg/mmq/internal/vectordb"
+	"github.com/google/uuid"
+)
+
+// InMemoryStore 纯Go内存存储后端
+//
+// 行为与SQLite后端保持一致（内容去重、软删除、BM25全文检索、向量检索、记忆管理），
+// 不依赖cgo和文件系统，进程退出后数据即丢失。适合短生命周期的agent和快速单元测试。
+// 时间精度与SQLite后端相同，为秒。
+type InMemoryStore struct {
+	mu sync.RWMutex
+
+	nextDocID   int
+	content     map[string]*memContent        // hash -> 内容
+	documents   []*memDocument                // 按ID递增
+	vectors     map[string]map[int]*memVector // hash -> seq -> 向量
+	quantize    map[string]string             // collection -> 量化方式
+	collections map[string]*Collection        // name -> 集合
+	contexts    map[string]*ContextEntry      // path -> 上下文
+	summaries   map[string]*memSummary        // hash -> 文档摘要
+	queryLog    []QueryLogEntry               // 按记录顺序
+	feedback    []Feedback                    // 按记录顺序
+	records     map[string]*StructuredRecord  // collection/path -> 行记录
+	profiles    map[string][]byte             // name -> 排序配置JSON
+	bindings    map[string]string             // collection -> 排序配置名称
+	memories    map[string]*memMemory         // id -> 记忆
+	memSeq      int
+	archived    map[string]*memArchived            // id -> 归档记忆
+	convSummary map[Namespace]*ConversationSummary // 命名空间（含会话） -> 滚动摘要
+	facts       []*FactRecord                      // 按写入顺序
+	functional  map[functionalKey]bool             // 函数型谓词
+	reflection  map[Namespace]*ReflectionCursor    // 命名空间 -> 反思进度
+}
+
+type functionalKey struct {
+	ns        Namespace
+	predicate string
+}
+
+type memContent struct {
+	doc      string
+	tokens   []string // body分词结果（FTS）
+	language string   // 检测到的语言
+}
+
+type memDocument struct {
+	id         int
+	collection string
+	path       string
+	title      string
+	hash       string
+	createdAt  time.Time
+	modifiedAt time.Time
+	active     bool
+
+	pathTokens  []string
+	titleTokens []string
+}
+
+type memVector struct {
+	pos       int
+	model     string
+	embedding []float32 // int8量化后为nil
+	bits      []byte    // 符号位编码
+	int8      []byte
+	scale     float64
+}
+
+// stored 转换为量化无关的向量表示
+func (v *memVector) stored() storedVector {
+	return storedVector{vec: v.embedding, bits: v.bits, int8: v.int8, scale: v.scale}
+}
+
+type memSummary struct {
+	summary   string
+	model     string
+	createdAt time.Time
+	tokens    []string // 摘要分词结果（FTS）
+}
+
+type memMemory struct {
+	seq        int // 插入顺序，用于稳定排序
+	id         string
+	namespace  Namespace
+	memType    string
+	content    string
+	metadata   []byte // JSON，与SQLite后端一样按值存储
+	tags       []string
+	timestamp  time.Time
+	expiresAt  *time.Time
+	importance float64
+	embedding  []float32
+
+	accessCount  int
+	lastAccessed *time.Time
+}
+
+// memArchived 归档的记忆
+type memArchived struct {
+	mem        *memMemory
+	archivedAt time.Time
+}
+
+// NewInMemory 创建内存存储后端
+func NewInMemory() *InMemoryStore {
+	return &InMemoryStore{
+		content:     make(map[string]*memContent),
+		vectors:     make(map[string]map[int]*memVector),
+		quantize:    make(map[string]string),
+		collections: make(map[string]*Collection),
+		contexts:    make(map[string]*ContextEntry),
+		summaries:   make(map[string]*memSummary),
+		records:     make(map[string]*StructuredRecord),
+		profiles:    make(map[string][]byte),
+		bindings:    make(map[string]string),
+		memories:    make(map[string]*memMemory),
+		archived:    make(map[string]*memArchived),
+		convSummary: make(map[Namespace]*ConversationSummary),
+		functional:  make(map[functionalKey]bool),
+		reflection:  make(map[Namespace]*ReflectionCursor),
+	}
+}
+
+// Close 释放内存数据
+func (s *InMemoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.content = make(map[string]*memContent)
+	s.documents = nil
+	s.vectors = make(map[string]map[int]*memVector)
+	s.quantize = make(map[string]string)
+	s.collections = make(map[string]*Collection)
+	s.contexts = make(map[string]*ContextEntry)
+	s.summaries = make(map[string]*memSummary)
+	s.queryLog = nil
+	s.feedback = nil
+	s.records = make(map[string]*StructuredRecord)
+	s.profiles = make(map[string][]byte)
+	s.bindings = make(map[string]string)
+	s.memories = make(map[string]*memMemory)
+	s.archived = make(map[string]*memArchived)
+	s.convSummary = make(map[Namespace]*ConversationSummary)
+	s.facts = nil
+	s.functional = make(map[functionalKey]bool)
+	s.reflection = make(map[Namespace]*ReflectionCursor)
+	return nil
+}
+
+// toSeconds 与SQLite后端的RFC3339存储精度保持一致
+func toSeconds(t time.Time) time.Time {
+	return t.Truncate(time.Second)
+}
+
+// --- 文档 ---
+
+// IndexDocument 索引单个文档
+func (s *InMemoryStore) IndexDocument(doc Document) error {
+	hash := computeHash(doc.Content)
+
+	if doc.CreatedAt.IsZero() {
+		doc.CreatedAt = time.Now().UTC()
+	}
+	if doc.ModifiedAt.IsZero() {
+		doc.ModifiedAt = time.Now().UTC()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.content[hash]; !ok {
+		s.content[hash] = &memContent{
+			doc:      doc.Content,
+			tokens:   ftsTokenize(doc.Content),
+			language: langdetect.Detect(doc.Content),
+		}
+	}
+
+	if d := s.findDocument(doc.Collection, doc.Path); d != nil {
+		d.title = doc.Title
+		d.hash = hash
+		d.modifiedAt = toSeconds(doc.ModifiedAt)
+		d.active = true
+		d.titleTokens = ftsTokenize(doc.Title)
+		return nil
+	}
+
+	s.nextDocID++
+	d := &memDocument{
+		id:          s.nextDocID,
+		collection:  doc.Collection,
+		path:        doc.Path,
+		title:       doc.Title,
+		hash:        hash,
+		createdAt:   toSeconds(doc.CreatedAt),
+		modifiedAt:  toSeconds(doc.ModifiedAt),
+		active:      true,
+		titleTokens: ftsTokenize(doc.Title),
+	}
+	d.pathTokens = ftsTokenize(d.collection + "/" + d.path)
+	s.documents = append(s.documents, d)
+
+	return nil
+}
+
+// findDocument 按集合和路径查找文档（含已删除），调用方需持有锁
+func (s *InMemoryStore) findDocument(collection, path string) *memDocument {
+	for _, d := range s.documents {
+		if d.collection == collection && d.path == path {
+			return d
+		}
+	}
+	return nil
+}
+
+// GetDocument 获取文档（支持数字ID、哈希或路径）
+func (s *InMemoryStore) GetDocument(id string) (*Document, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, d := range s.documents {
+		if d.active && d.matchesID(id) {
+			return &Document{
+				ID:         strconv.Itoa(d.id),
+				Collection: d.collection,
+				Path:       d.path,
+				Title:      d.title,
+				Content:    s.content[d.hash].doc,
+				CreatedAt:  d.createdAt,
+				ModifiedAt: d.modifiedAt,
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("document not found: %s", id)
+}
+
+func (d *memDocument) matchesID(id string) bool {
+	return strconv.Itoa(d.id) == id || d.hash == id || d.path == id
+}
+
+// DeleteDocument 删除文档（软删除）
+func (s *InMemoryStore) DeleteDocument(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for _, d := range s.documents {
+		if d.active && d.matchesID(id) {
+			d.active = false
+			deleted++
+		}
+	}
+
+	if deleted == 0 {
+		return fmt.Errorf("document not found: %s", id)
+	}
+
+	return nil
+}
+
+// GetStatus 获取索引状态
+func (s *InMemoryStore) GetStatus() (Status, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := Status{DBPath: MemoryDBPath}
+
+	needs := make(map[string]bool)
+	collections := make(map[string]bool)
+	for _, d := range s.documents {
+		if !d.active {
+			continue
+		}
+		status.TotalDocuments++
+		collections[d.collection] = true
+		if !s.hasEmbedding(d.hash) {
+			needs[d.hash] = true
+		}
+	}
+
+	status.NeedsEmbedding = len(needs)
+	for name := range collections {
+		status.Collections = append(status.Collections, name)
+	}
+	sort.Strings(status.Collections)
+
+	return status, nil
+}
+
+// hasEmbedding 文档内容是否已有首个块的向量，调用方需持有锁
+func (s *InMemoryStore) hasEmbedding(hash string) bool {
+	_, ok := s.vectors[hash][0]
+	return ok
+}
+
+// activeDocuments 返回满足条件的活跃文档，调用方需持有锁
+func (s *InMemoryStore) activeDocuments(filter func(d *memDocument) bool) []*memDocument {
+	var docs []*memDocument
+	for _, d := range s.documents {
+		if d.active && (filter == nil || filter(d)) {
+			docs = append(docs, d)
+		}
+	}
+	return docs
+}
+
+// ListDocumentsByPath 列出集合或路径下的文档
+func (s *InMemoryStore) ListDocumentsByPath(collection, path string) ([]DocumentListEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pathPrefix := strings.TrimSuffix(path, "/")
+	docs := s.activeDocuments(func(d *memDocument) bool {
+		if collection == "" {
+			return true
+		}
+		if d.collection != collection {
+			return false
+		}
+		return path == "" || d.path == pathPrefix || strings.HasPrefix(d.path, pathPrefix+"/")
+	})
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		if docs[i].collection != docs[j].collection {
+			return docs[i].collection < docs[j].collection
+		}
+		return docs[i].path < docs[j].path
+	})
+
+	var entries []DocumentListEntry
+	for _, d := range docs {
+		entries = append(entries, DocumentListEntry{
+			ID:         d.id,
+			DocID:      "#" + getDocid(d.hash),
+			Collection: d.collection,
+			Path:       d.path,
+			Title:      d.title,
+			Hash:       d.hash,
+			CreatedAt:  d.createdAt,
+			ModifiedAt: d.modifiedAt,
+		})
+	}
+
+	return entries, nil
+}
+
+// detail 转换为文档详情，调用方需持有锁
+func (s *InMemoryStore) detail(d *memDocument) *DocumentDetail {
+	return &DocumentDetail{
+		ID:         d.id,
+		DocID:      "#" + getDocid(d.hash),
+		Collection: d.collection,
+		Path:       d.path,
+		Title:      d.title,
+		Content:    s.content[d.hash].doc,
+		Hash:       d.hash,
+		CreatedAt:  d.createdAt,
+		ModifiedAt: d.modifiedAt,
+	}
+}
+
+// GetDocumentByPath 通过路径获取文档
+func (s *InMemoryStore) GetDocumentByPath(filePath string) (*DocumentDetail, error) {
+	collection, path := parseFilePath(filePath)
+	if collection == "" {
+		return nil, fmt.Errorf("invalid file path: %s", filePath)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if d := s.findDocument(collection, path); d != nil && d.active {
+		return s.detail(d), nil
+	}
+
+	return nil, fmt.Errorf("document not found: %s", filePath)
+}
+
+// GetDocumentByID 通过短docid获取文档
+func (s *InMemoryStore) GetDocumentByID(docID string) (*DocumentDetail, error) {
+	docID = strings.TrimPrefix(docID, "#")
+
+	if len(docID) < 6 {
+		return nil, fmt.Errorf("invalid docid: must be at least 6 characters")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, d := range s.documents {
+		if d.active && strings.HasPrefix(d.hash, docID) {
+			return s.detail(d), nil
+		}
+	}
+
+	return nil, fmt.Errorf("document not found: #%s", docID)
+}
+
+// GetMultipleDocuments 批量获取文档（docid列表、路径列表或Glob模式）
+func (s *InMemoryStore) GetMultipleDocuments(pattern string, maxBytes int) ([]*DocumentDetail, error) {
+	if strings.Contains(pattern, "*") || strings.Contains(pattern, "?") {
+		return s.getDocumentsByGlob(pattern, maxBytes)
+	} else if strings.Contains(pattern, ",") {
+		return getDocumentsByList(s, pattern, maxBytes)
+	}
+
+	doc, err := getDocumentSingle(s, pattern, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	return []*DocumentDetail{doc}, nil
+}
+
+// getDocumentsByGlob 通过Glob模式获取文档
+func (s *InMemoryStore) getDocumentsByGlob(pattern string, maxBytes int) ([]*DocumentDetail, error) {
+	collection, pathPattern := parseFilePath(pattern)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	docs := s.activeDocuments(func(d *memDocument) bool {
+		return collection == "" || d.collection == collection
+	})
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		if docs[i].collection != docs[j].collection {
+			return docs[i].collection < docs[j].collection
+		}
+		return docs[i].path < docs[j].path
+	})
+
+	var results []*DocumentDetail
+	for _, d := range docs {
+		// 与SQLite length()一致，按字符数计算大小
+		if maxBytes > 0 && utf8.RuneCountInString(s.content[d.hash].doc) > maxBytes {
+			continue
+		}
+		if !matchDocumentGlob(pathPattern, d.path) {
+			continue
+		}
+		results = append(results, s.detail(d))
+	}
+
+	return results, nil
+}
+
+// --- 向量 ---
+
+// GetDocumentsNeedingEmbedding 获取需要生成嵌入的文档
+func (s *InMemoryStore) GetDocumentsNeedingEmbedding() ([]Document, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	docs := s.activeDocuments(func(d *memDocument) bool {
+		return !s.hasEmbedding(d.hash)
+	})
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docs[i].modifiedAt.After(docs[j].modifiedAt)
+	})
+
+	seen := make(map[string]bool)
+	var results []Document
+	for _, d := range docs {
+		if seen[d.hash] {
+			continue
+		}
+		seen[d.hash] = true
+		results = append(results, Document{Hash: d.hash, Content: s.content[d.hash].doc})
+	}
+
+	return results, nil
+}
+
+// StoreEmbedding 存储嵌入向量
+func (s *InMemoryStore) StoreEmbedding(hash string, seq int, pos int, embedding []float32, model string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.vectors[hash] == nil {
+		s.vectors[hash] = make(map[int]*memVector)
+	}
+	v := &memVector{
+		pos:       pos,
+		model:     model,
+		embedding: append([]float32(nil), embedding...),
+	}
+	v.convert(targetQuantization(s.collectionsOf(hash), s.quantize))
+	s.vectors[hash][seq] = v
+
+	return nil
+}
+
+// GetAllEmbeddings 获取内容的所有块向量（按块序号）
+func (s *InMemoryStore) GetAllEmbeddings(hash string) ([][]float32, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chunks := s.vectors[hash]
+	seqs := make([]int, 0, len(chunks))
+	for seq := range chunks {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+
+	var embeddings [][]float32
+	for _, seq := range seqs {
+		embeddings = append(embeddings, append([]float32(nil), chunks[seq].stored().float32s()...))
+	}
+
+	return embeddings, nil
+}
+
+// GetChunkEmbeddings 获取内容的所有块向量及块位置（按块序号）
+func (s *InMemoryStore) GetChunkEmbeddings(hash string) ([]ChunkEmbedding, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	vectors := s.vectors[hash]
+	seqs := make([]int, 0, len(vectors))
+	for seq := range vectors {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+
+	chunks := make([]ChunkEmbedding, 0, len(seqs))
+	for _, seq := range seqs {
+		v := vectors[seq]
+		chunks = append(chunks, ChunkEmbedding{
+			Seq:       seq,
+			Pos:       v.pos,
+			Embedding: append([]float32(nil), v.stored().float32s()...),
+		})
+	}
+
+	return chunks, nil
+}
+
+// --- 向量量化 ---
+
+// SetCollectionQuantization 设置集合的向量量化方式（mode为空时恢复float32）
+func (s *InMemoryStore) SetCollectionQuantization(collection, mode string) error {
+	if err := validQuantization(mode); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if mode == QuantizationNone {
+		delete(s.quantize, collection)
+		return nil
+	}
+	if _, ok := s.collections[collection]; !ok {
+		return fmt.Errorf("collection '%s' not found", collection)
+	}
+	s.quantize[collection] = mode
+
+	return nil
+}
+
+// GetCollectionQuantization 获取集合的向量量化方式（未设置返回空字符串）
+func (s *InMemoryStore) GetCollectionQuantization(collection string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.quantize[collection], nil
+}
+
+// QuantizeEmbeddings 按集合设置转换已有向量
+func (s *InMemoryStore) QuantizeEmbeddings() (QuantizeStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	collections := s.hashCollections()
+
+	var stats QuantizeStats
+	for hash, chunks := range s.vectors {
+		target := targetQuantization(collections[hash], s.quantize)
+		for _, v := range chunks {
+			stats.Vectors++
+			stats.BytesBefore += v.stored().size()
+			v.convert(target)
+			stats.BytesAfter += v.stored().size()
+
+			switch target {
+			case QuantizationInt8:
+				stats.Int8++
+			case QuantizationBinary:
+				stats.Binary++
+			default:
+				stats.Float32++
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// hashCollections 内容哈希 -> 引用它的集合（仅有效文档），调用方需持有锁
+func (s *InMemoryStore) hashCollections() map[string][]string {
+	collections := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, d := range s.activeDocuments(nil) {
+		key := d.hash + "\x00" + d.collection
+		if !seen[key] {
+			seen[key] = true
+			collections[d.hash] = append(collections[d.hash], d.collection)
+		}
+	}
+	return collections
+}
+
+// collectionsOf 引用内容的集合（仅有效文档），调用方需持有锁
+func (s *InMemoryStore) collectionsOf(hash string) []string {
+	var collections []string
+	for _, d := range s.activeDocuments(func(d *memDocument) bool { return d.hash == hash }) {
+		if !containsString(collections, d.collection) {
+			collections = append(collections, d.collection)
+		}
+	}
+	return collections
+}
+
+// convert 将块向量转换为目标量化方式
+func (v *memVector) convert(target string) {
+	vec := v.stored().float32s()
+	if len(vec) == 0 {
+		return
+	}
+
+	switch target {
+	case QuantizationInt8:
+		if v.embedding != nil {
+			v.int8, v.scale = quantizeInt8(vec)
+			v.bits = vectordb.BinaryCode(vec)
+			v.embedding = nil
+		}
+	case QuantizationBinary:
+		v.embedding = vec
+		v.bits = vectordb.BinaryCode(vec)
+		v.int8, v.scale = nil, 0
+	default:
+		v.embedding = vec
+		v.bits, v.int8, v.scale = nil, nil, 0
+	}
+}
+
+// --- 检索 ---
+
+// SearchFTS 使用BM25全文搜索（默认字段权重）
+func (s *InMemoryStore) SearchFTS(query string, limit int, filter DocumentFilter) ([]SearchResult, error) {
+	return s.SearchFTSWeighted(query, limit, filter, DefaultFieldWeights())
+}
+
+// SearchFTSWeighted 使用指定字段权重的BM25全文搜索
+// 与SQLite FTS5一致：各查询词前缀匹配并以AND连接
+func (s *InMemoryStore) SearchFTSWeighted(query string, limit int, filter DocumentFilter, weights FieldWeights) ([]SearchResult, error) {
+	if weights.IsZero() {
+		weights = DefaultFieldWeights()
+	}
+
+	terms := ftsQueryTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// FTS5统计基于全部已索引行；包含CJK文字的查询与SQLite后端一样只在CJK索引（包含CJK文字的文档）中检索
+	var indexed func(d *memDocument) bool
+	if langdetect.HasCJK(query) {
+		indexed = func(d *memDocument) bool {
+			return langdetect.HasCJK(d.path + d.title + s.content[d.hash].doc)
+		}
+	}
+	all := s.activeDocuments(indexed)
+	if len(all) == 0 {
+		return nil, nil
+	}
+
+	fieldWeights := [3]float64{weights.Filepath, weights.Title, weights.Body}
+	const k1, b = 1.2, 0.75
+
+	totalLen := 0
+	for _, d := range all {
+		totalLen += s.docLength(d)
+	}
+	avgLen := float64(totalLen) / float64(len(all))
+
+	// 每个词出现的文档数（用于IDF）
+	docFreq := make([]int, len(terms))
+	tfs := make(map[*memDocument][][3]int, len(all))
+	for _, d := range all {
+		fields := s.docFields(d)
+		perTerm := make([][3]int, len(terms))
+		for i, term := range terms {
+			found := false
+			for f, tokens := range fields {
+				perTerm[i][f] = countPrefix(tokens, term)
+				if perTerm[i][f] > 0 {
+					found = true
+				}
+			}
+			if found {
+				docFreq[i]++
+			}
+		}
+		tfs[d] = perTerm
+	}
+
+	type scored struct {
+		doc   *memDocument
+		score float64
+	}
+
+	var candidates []scored
+	n := float64(len(all))
+	for _, d := range all {
+		if !s.matchDocument(d, filter) {
+			continue
+		}
+
+		perTerm := tfs[d]
+		score := 0.0
+		matched := true
+		for i := range terms {
+			w := 0.0
+			for f := 0; f < 3; f++ {
+				w += fieldWeights[f] * float64(perTerm[i][f])
+			}
+			if w == 0 {
+				matched = false
+				break
+			}
+
+			idf := math.Log((n - float64(docFreq[i]) + 0.5) / (float64(docFreq[i]) + 0.5))
+			if idf <= 0 {
+				idf = 1e-6
+			}
+			dl := float64(s.docLength(d))
+			score += idf * (w * (k1 + 1)) / (w + k1*(1-b+b*dl/avgLen))
+		}
+
+		if matched {
+			candidates = append(candidates, scored{doc: d, score: score})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	results := make([]SearchResult, 0, len(candidates))
+	for _, c := range candidates {
+		body := s.content[c.doc.hash].doc
+		results = append(results, SearchResult{
+			ID:         c.doc.hash,
+			Score:      normalizeBM25Score(-c.score),
+			Title:      c.doc.title,
+			Content:    body,
+			Snippet:    extractSnippet(body, query, 300),
+			Source:     "fts",
+			Collection: c.doc.collection,
+			Path:       c.doc.path,
+			Timestamp:  c.doc.modifiedAt,
+			Language:   s.content[c.doc.hash].language,
+		})
+	}
+
+	// 文档摘要同样参与全文检索
+	return mergeSummaryHits(results, s.searchSummaries(all, terms, query, limit, filter, weights.Body), limit), nil
+}
+
+// searchSummaries 在文档摘要中执行BM25检索（摘要按正文权重计分），调用方需持有锁
+func (s *InMemoryStore) searchSummaries(all []*memDocument, terms []string, query string, limit int, filter DocumentFilter, bodyWeight float64) []SearchResult {
+	const k1, b = 1.2, 0.75
+
+	// 摘要按内容去重统计
+	var hashes []string
+	seen := make(map[string]bool)
+	totalLen := 0
+	for _, d := range all {
+		if sum, ok := s.summaries[d.hash]; ok && !seen[d.hash] {
+			seen[d.hash] = true
+			hashes = append(hashes, d.hash)
+			totalLen += len(sum.tokens)
+		}
+	}
+	if len(hashes) == 0 || totalLen == 0 {
+		return nil
+	}
+	avgLen := float64(totalLen) / float64(len(hashes))
+
+	docFreq := make([]int, len(terms))
+	for _, h := range hashes {
+		for i, term := range terms {
+			if countPrefix(s.summaries[h].tokens, term) > 0 {
+				docFreq[i]++
+			}
+		}
+	}
+
+	n := float64(len(hashes))
+	scores := make(map[string]float64)
+	for _, h := range hashes {
+		tokens := s.summaries[h].tokens
+		score := 0.0
+		for i, term := range terms {
+			w := bodyWeight * float64(countPrefix(tokens, term))
+			if w == 0 {
+				score = 0
+				break
+			}
+
+			idf := math.Log((n - float64(docFreq[i]) + 0.5) / (float64(docFreq[i]) + 0.5))
+			if idf <= 0 {
+				idf = 1e-6
+			}
+			score += idf * (w * (k1 + 1)) / (w + k1*(1-b+b*float64(len(tokens))/avgLen))
+		}
+		if score > 0 {
+			scores[h] = score
+		}
+	}
+
+	var results []SearchResult
+	for _, d := range all {
+		score, ok := scores[d.hash]
+		if !ok || !s.matchDocument(d, filter) {
+			continue
+		}
+
+		body := s.content[d.hash].doc
+		results = append(results, SearchResult{
+			ID:         d.hash,
+			Score:      normalizeBM25Score(-score),
+			Title:      d.title,
+			Content:    body,
+			Snippet:    extractSnippet(body, query, 300),
+			Source:     "fts",
+			Collection: d.collection,
+			Path:       d.path,
+			Timestamp:  d.modifiedAt,
+			Language:   s.content[d.hash].language,
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
+
+// matchDocument 文档是否满足过滤条件，调用方需持有锁
+func (s *InMemoryStore) matchDocument(d *memDocument, filter DocumentFilter) bool {
+	if filter.Collection != "" && d.collection != filter.Collection {
+		return false
+	}
+	if filter.Language != "" && s.content[d.hash].language != filter.Language {
+		return false
+	}
+	return filter.matchFields(s.records[d.collection+"/"+d.path])
+}
+
+// docFields 返回文档的 filepath/title/body 分词，调用方需持有锁
+func (s *InMemoryStore) docFields(d *memDocument) [3][]string {
+	return [3][]string{d.pathTokens, d.titleTokens, s.content[d.hash].tokens}
+}
+
+// docLength 文档总词数，调用方需持有锁
+func (s *InMemoryStore) docLength(d *memDocument) int {
+	return len(d.pathTokens) + len(d.titleTokens) + len(s.content[d.hash].tokens)
+}
+
+// SearchVector 块级向量搜索，同一文档保留最佳匹配块
+func (s *InMemoryStore) SearchVector(query string, embedding []float32, limit int, filter DocumentFilter) ([]SearchResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type candidate struct {
+		doc      *memDocument
+		distance float64
+	}
+
+	var docs []*memDocument
+	var vectors []storedVector
+	for _, d := range s.activeDocuments(nil) {
+		if !s.matchDocument(d, filter) {
+			continue
+		}
+		for _, v := range s.vectors[d.hash] {
+			docs = append(docs, d)
+			vectors = append(vectors, v.stored())
+		}
+	}
+
+	best := make(map[string]candidate)
+	var order []string
+	for _, i := range selectForRescore(embedding, vectors, rescoreLimit(limit)) {
+		d := docs[i]
+		dist, err := vectordb.CosineDist(embedding, vectors[i].float32s())
+		if err != nil {
+			continue // 跳过维度不匹配的向量
+		}
+		key := d.collection + "/" + d.path
+		existing, ok := best[key]
+		if !ok {
+			order = append(order, key)
+		}
+		if !ok || dist < existing.distance {
+			best[key] = candidate{doc: d, distance: dist}
+		}
+	}
+
+	results := make([]SearchResult, 0, len(best))
+	for _, key := range order {
+		c := best[key]
+		body := s.content[c.doc.hash].doc
+		results = append(results, SearchResult{
+			ID:         c.doc.hash,
+			Score:      1.0 - c.distance,
+			Title:      c.doc.title,
+			Content:    body,
+			Snippet:    extractSnippet(body, query, 300),
+			Source:     "vector",
+			Collection: c.doc.collection,
+			Path:       c.doc.path,
+			Timestamp:  c.doc.modifiedAt,
+			Language:   s.content[c.doc.hash].language,
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// SearchVectorDocuments 文档级向量搜索
+func (s *InMemoryStore) SearchVectorDocuments(query string, queryEmbed []float32, limit int, filter DocumentFilter) ([]SearchResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type scoredDoc struct {
+		doc        *memDocument
+		similarity float64
+	}
+
+	var docs []*memDocument
+	var vectors []storedVector
+	for _, d := range s.activeDocuments(nil) {
+		if !s.matchDocument(d, filter) {
+			continue
+		}
+		for _, v := range s.vectors[d.hash] {
+			docs = append(docs, d)
+			vectors = append(vectors, v.stored())
+		}
+	}
+
+	// 量化向量预筛选：只对入选的块计算相似度
+	best := make(map[*memDocument]float64)
+	var order []*memDocument
+	for _, i := range selectForRescore(queryEmbed, vectors, rescoreLimit(limit)) {
+		d := docs[i]
+		similarity := 1.0 - cosineDist(queryEmbed, vectors[i].float32s())
+		existing, ok := best[d]
+		if !ok {
+			order = append(order, d)
+			best[d] = 0
+		}
+		if similarity > existing {
+			best[d] = similarity
+		}
+	}
+
+	var scored []scoredDoc
+	for _, d := range order {
+		scored = append(scored, scoredDoc{doc: d, similarity: best[d]})
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].similarity > scored[j].similarity
+	})
+
+	if len(scored) > limit {
+		scored = scored[:limit]
+	}
+
+	results := make([]SearchResult, len(scored))
+	for i, sd := range scored {
+		body := s.content[sd.doc.hash].doc
+		results[i] = SearchResult{
+			ID:         strconv.Itoa(sd.doc.id),
+			Title:      sd.doc.title,
+			Content:    body,
+			Snippet:    extractSnippet(body, query, 200),
+			Score:      sd.similarity,
+			Source:     "vector",
+			Collection: sd.doc.collection,
+			Path:       sd.doc.path,
+			Timestamp:  sd.doc.modifiedAt,
+			Language:   s.content[sd.doc.hash].language,
+		}
+	}
+
+	return results, nil
+}
+
+// ftsTokenize 近似FTS5 "porter unicode61" 分词：按非字母数字切分、转小写并做简单词干化
+// CJK文字先切分为双字词（对应documents_fts_cjk）
+func ftsTokenize(text string) []string {
+	words := strings.FieldsFunc(segmentCJK(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	tokens := make([]string, 0, len(words))
+	for _, w := range words {
+		tokens = append(tokens, stemToken(strings.ToLower(w)))
+	}
+	return tokens
+}
+
+// ftsQueryTerms 与buildFTS5Query相同的查询词切分
+func ftsQueryTerms(query string) []string {
+	var terms []string
+	for _, word := range strings.Fields(query) {
+		for _, t := range ftsTokenize(word) {
+			if t != "" {
+				terms = append(terms, t)
+			}
+		}
+	}
+	return terms
+}
+
+// stemToken 简化的英文词干化（去除常见屈折后缀）
+func stemToken(token string) string {
+	for _, suffix := range []string{"ing", "ed", "es", "s"} {
+		if strings.HasSuffix(token, suffix) && utf8.RuneCountInString(token)-len(suffix) >= 3 {
+			return strings.TrimSuffix(token, suffix)
+		}
+	}
+	return token
+}
+
+// countPrefix 统计以prefix开头的词数
+func countPrefix(tokens []string, prefix string) int {
+	count := 0
+	for _, t := range tokens {
+		if strings.HasPrefix(t, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+// --- 语言 ---
+
+// DocumentLanguages 返回有效文档的语言（collection/path -> 语言代码）
+func (s *InMemoryStore) DocumentLanguages(collection string) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	languages := make(map[string]string)
+	for _, d := range s.activeDocuments(nil) {
+		if collection != "" && d.collection != collection {
+			continue
+		}
+		languages[d.collection+"/"+d.path] = s.content[d.hash].language
+	}
+	return languages, nil
+}
+
+// --- 文档摘要 ---
+
+// SaveDocumentSummary 保存文档摘要（已存在则覆盖）
+func (s *InMemoryStore) SaveDocumentSummary(hash, summary, model string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.summaries[hash] = &memSummary{
+		summary:   summary,
+		model:     model,
+		createdAt: toSeconds(time.Now().UTC()),
+		tokens:    ftsTokenize(summary),
+	}
+	return nil
+}
+
+// GetDocumentSummary 获取内容哈希对应的摘要，没有摘要时返回nil
+func (s *InMemoryStore) GetDocumentSummary(hash string) (*DocumentSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sum, ok := s.summaries[hash]
+	if !ok {
+		return nil, nil
+	}
+	return &DocumentSummary{Hash: hash, Summary: sum.summary, Model: sum.model, CreatedAt: sum.createdAt}, nil
+}
+
+// GetDocumentsNeedingSummary 获取还没有摘要的活跃文档（同一内容只返回一次）
+func (s *InMemoryStore) GetDocumentsNeedingSummary(collection string) ([]Document, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	docs := s.activeDocuments(func(d *memDocument) bool {
+		_, ok := s.summaries[d.hash]
+		return !ok && (collection == "" || d.collection == collection)
+	})
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		if docs[i].collection != docs[j].collection {
+			return docs[i].collection < docs[j].collection
+		}
+		return docs[i].path < docs[j].path
+	})
+
+	seen := make(map[string]bool)
+	var results []Document
+	for _, d := range docs {
+		if seen[d.hash] {
+			continue
+		}
+		seen[d.hash] = true
+		results = append(results, Document{
+			Collection: d.collection,
+			Path:       d.path,
+			Title:      d.title,
+			Hash:       d.hash,
+			Content:    s.content[d.hash].doc,
+		})
+	}
+
+	return results, nil
+}
+
+// PruneDocumentSummaries 删除已没有活跃文档引用的摘要
+func (s *InMemoryStore) PruneDocumentSummaries() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	referenced := make(map[string]bool)
+	for _, d := range s.activeDocuments(nil) {
+		referenced[d.hash] = true
+	}
+
+	removed := 0
+	for hash := range s.summaries {
+		if !referenced[hash] {
+			delete(s.summaries, hash)
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// --- 查询日志 ---
+
+// LogQuery 记录一次查询，返回日志ID（entry.ID为空时自动生成）
+func (s *InMemoryStore) LogQuery(entry QueryLogEntry) (string, error) {
+	if entry.ID == "" {
+		entry.ID = uuid.New().String()
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	entry.Timestamp = toSeconds(entry.Timestamp.UTC())
+	entry.Latency = entry.Latency.Truncate(time.Microsecond)
+	entry.Results = append([]QueryLogResult(nil), entry.Results...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queryLog = append(s.queryLog, entry)
+	return entry.ID, nil
+}
+
+// GetQueryLogEntry 按ID获取查询日志
+func (s *InMemoryStore) GetQueryLogEntry(id string) (*QueryLogEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, entry := range s.queryLog {
+		if entry.ID == id {
+			entry.Results = append([]QueryLogResult(nil), entry.Results...)
+			return &entry, nil
+		}
+	}
+	return nil, fmt.Errorf("query not found: %s", id)
+}
+
+// ListQueryLog 列出since之后（含）的查询日志，按时间升序
+func (s *InMemoryStore) ListQueryLog(since time.Time) ([]QueryLogEntry, error) {
+	since = toSeconds(since)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []QueryLogEntry
+	for _, entry := range s.queryLog {
+		if !entry.Timestamp.Before(since) {
+			entry.Results = append([]QueryLogResult(nil), entry.Results...)
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+	return entries, nil
+}
+
+// PruneQueryLog 删除before之前的查询日志，返回删除数量
+func (s *InMemoryStore) PruneQueryLog(before time.Time) (int, error) {
+	before = toSeconds(before)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.queryLog[:0]
+	for _, entry := range s.queryLog {
+		if !entry.Timestamp.Before(before) {
+			kept = append(kept, entry)
+		}
+	}
+	removed := len(s.queryLog) - len(kept)
+	s.queryLog = kept
+
+	return removed, nil
+}
+
+// --- 相关性反馈 ---
+
+// SaveFeedback 保存反馈（同一查询、文档和信号重复记录时更新时间）
+func (s *InMemoryStore) SaveFeedback(f Feedback) error {
+	if f.CreatedAt.IsZero() {
+		f.CreatedAt = time.Now()
+	}
+	f.CreatedAt = toSeconds(f.CreatedAt.UTC())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, existing := range s.feedback {
+		if existing.QueryID == f.QueryID && existing.DocPath == f.DocPath && existing.Signal == f.Signal {
+			s.feedback[i].Weight = f.Weight
+			s.feedback[i].CreatedAt = f.CreatedAt
+			return nil
+		}
+	}
+	s.feedback = append(s.feedback, f)
+	return nil
+}
+
+// ListFeedback 列出since之后（含）的反馈，按时间升序
+func (s *InMemoryStore) ListFeedback(since time.Time) ([]Feedback, error) {
+	since = toSeconds(since)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var feedback []Feedback
+	for _, f := range s.feedback {
+		if !f.CreatedAt.Before(since) {
+			feedback = append(feedback, f)
+		}
+	}
+
+	sort.SliceStable(feedback, func(i, j int) bool {
+		return feedback[i].CreatedAt.Before(feedback[j].CreatedAt)
+	})
+	return feedback, nil
+}
+
+// --- 结构化数据行记录 ---
+
+// SaveRecords 替换文件的全部行记录
+// 同时停用该文件不再存在的行文档，以及此前按整个文件索引的文档
+func (s *InMemoryStore) SaveRecords(collection, sourcePath string, records []StructuredRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keep := make(map[string]bool, len(records))
+	for _, r := range records {
+		keep[r.Path] = true
+	}
+
+	stale := []string{sourcePath}
+	for key, r := range s.records {
+		if r.Collection == collection && r.SourcePath == sourcePath {
+			if !keep[r.Path] {
+				stale = append(stale, r.Path)
+			}
+			delete(s.records, key)
+		}
+	}
+	for _, path := range stale {
+		if d := s.findDocument(collection, path); d != nil {
+			d.active = false
+		}
+	}
+
+	for _, r := range records {
+		r.Collection = collection
+		r.SourcePath = sourcePath
+		r.Fields = copyFields(r.Fields)
+		s.records[collection+"/"+r.Path] = &r
+	}
+
+	return nil
+}
+
+// GetRecord 获取行文档对应的记录，不是行文档时返回nil
+func (s *InMemoryStore) GetRecord(collection, path string) (*StructuredRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.records[collection+"/"+path]
+	if !ok || !s.recordActive(r) {
+		return nil, nil
+	}
+
+	record := *r
+	record.Fields = copyFields(r.Fields)
+	return &record, nil
+}
+
+// ListRecords 列出文件的全部行记录，按行号排序
+func (s *InMemoryStore) ListRecords(collection, sourcePath string) ([]StructuredRecord, error) {
+	return s.filterRecords(func(r *StructuredRecord) bool {
+		return r.Collection == collection && r.SourcePath == sourcePath
+	}), nil
+}
+
+// FindRecords 查找所有字段都匹配的行记录（值忽略大小写和首尾空白）
+// collection为空时搜索全部集合，结果按集合、文件和行号排序
+func (s *InMemoryStore) FindRecords(collection string, filters map[string]string) ([]StructuredRecord, error) {
+	filter := DocumentFilter{Fields: filters}
+	return s.filterRecords(func(r *StructuredRecord) bool {
+		return (collection == "" || r.Collection == collection) && filter.matchFields(r)
+	}), nil
+}
+
+// filterRecords 返回文档仍有效且满足条件的行记录
+func (s *InMemoryStore) filterRecords(match func(r *StructuredRecord) bool) []StructuredRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []StructuredRecord
+	for _, r := range s.records {
+		if s.recordActive(r) && match(r) {
+			record := *r
+			record.Fields = copyFields(r.Fields)
+			records = append(records, record)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Collection != b.Collection {
+			return a.Collection < b.Collection
+		}
+		if a.SourcePath != b.SourcePath {
+			return a.SourcePath < b.SourcePath
+		}
+		return a.Row < b.Row
+	})
+	return records
+}
+
+// recordActive 行记录对应的文档是否有效，调用方需持有锁
+func (s *InMemoryStore) recordActive(r *StructuredRecord) bool {
+	d := s.findDocument(r.Collection, r.Path)
+	return d != nil && d.active
+}
+
+// copyFields 复制字段，避免调用方修改存储的数据
+func copyFields(fields map[string]string) map[string]string {
+	copied := make(map[string]string, len(fields))
+	for k, v := range fields {
+		copied[k] = v
+	}
+	return copied
+}
+
+// --- 集合 ---
+
+// CreateCollection 创建集合
+func (s *InMemoryStore) CreateCollection(name, path, mask string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.collections[name]; ok {
+		return fmt.Errorf("collection '%s' already exists", name)
+	}
+
+	now := toSeconds(time.Now().UTC())
+	s.collections[name] = &Collection{
+		Name:      name,
+		Path:      path,
+		Mask:      mask,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	return nil
+}
+
+// collectionWithCount 返回带文档数的集合副本，调用方需持有锁
+func (s *InMemoryStore) collectionWithCount(c *Collection) Collection {
+	result := *c
+	result.DocCount = len(s.activeDocuments(func(d *memDocument) bool {
+		return d.collection == c.Name
+	}))
+	return result
+}
+
+// sortedCollections 按创建时间倒序排列的集合，调用方需持有锁
+func (s *InMemoryStore) sortedCollections() []*Collection {
+	collections := make([]*Collection, 0, len(s.collections))
+	for _, c := range s.collections {
+		collections = append(collections, c)
+	}
+	sort.Slice(collections, func(i, j int) bool {
+		if !collections[i].CreatedAt.Equal(collections[j].CreatedAt) {
+			return collections[i].CreatedAt.After(collections[j].CreatedAt)
+		}
+		return collections[i].Name < collections[j].Name
+	})
+	return collections
+}
+
+// ListCollections 列出所有集合
+func (s *InMemoryStore) ListCollections() ([]Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var collections []Collection
+	for _, c := range s.sortedCollections() {
+		collections = append(collections, s.collectionWithCount(c))
+	}
+
+	return collections, nil
+}
+
+// GetCollection 获取集合信息
+func (s *InMemoryStore) GetCollection(name string) (*Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.collections[name]
+	if !ok {
+		return nil, fmt.Errorf("collection '%s' not found", name)
+	}
+
+	result := s.collectionWithCount(c)
+	return &result, nil
+}
+
+// RemoveCollection 删除集合（文档软删除）
+func (s *InMemoryStore) RemoveCollection(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.collections[name]; !ok {
+		return fmt.Errorf("collection '%s' not found", name)
+	}
+
+	for _, d := range s.documents {
+		if d.collection == name {
+			d.active = false
+		}
+	}
+	delete(s.collections, name)
+	delete(s.bindings, name)
+	delete(s.quantize, name)
+
+	return nil
+}
+
+// RenameCollection 重命名集合
+func (s *InMemoryStore) RenameCollection(oldName, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.collections[oldName]
+	if !ok {
+		return fmt.Errorf("collection '%s' not found", oldName)
+	}
+	if _, ok := s.collections[newName]; ok {
+		return fmt.Errorf("collection '%s' already exists", newName)
+	}
+
+	c.Name = newName
+	c.UpdatedAt = toSeconds(time.Now().UTC())
+	delete(s.collections, oldName)
+	s.collections[newName] = c
+
+	if profile, ok := s.bindings[oldName]; ok {
+		delete(s.bindings, oldName)
+		s.bindings[newName] = profile
+	}
+	if mode, ok := s.quantize[oldName]; ok {
+		delete(s.quantize, oldName)
+		s.quantize[newName] = mode
+	}
+
+	for _, d := range s.documents {
+		if d.collection == oldName {
+			d.collection = newName
+			d.pathTokens = ftsTokenize(d.collection + "/" + d.path)
+		}
+	}
+
+	for key, r := range s.records {
+		if r.Collection == oldName {
+			delete(s.records, key)
+			r.Collection = newName
+			s.records[newName+"/"+r.Path] = r
+		}
+	}
+
+	return nil
+}
+
+// UpdateCollectionTimestamp 更新集合的更新时间
+func (s *InMemoryStore) UpdateCollectionTimestamp(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.collections[name]; ok {
+		c.UpdatedAt = toSeconds(time.Now().UTC())
+	}
+
+	return nil
+}
+
+// CollectionExists 检查集合是否存在
+func (s *InMemoryStore) CollectionExists(name string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.collections[name]
+	return ok, nil
+}
+
+// --- 排序配置 ---
+
+// SaveRankingProfile 保存排序配置（同名覆盖）
+func (s *InMemoryStore) SaveRankingProfile(profile RankingProfile) error {
+	if profile.Name == "" {
+		return fmt.Errorf("ranking profile name is required")
+	}
+
+	// 与SQLite后端一样按JSON值存储，避免调用方修改共享的map和切片
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ranking profile: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.profiles[profile.Name] = data
+	return nil
+}
+
+// GetRankingProfile 获取排序配置
+func (s *InMemoryStore) GetRankingProfile(name string) (*RankingProfile, error) {
+	s.mu.RLock()
+	data, ok := s.profiles[name]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s'", ErrRankingProfileNotFound, name)
+	}
+
+	var profile RankingProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ranking profile: %w", err)
+	}
+
+	return &profile, nil
+}
+
+// ListRankingProfiles 列出所有排序配置（按名称排序）
+func (s *InMemoryStore) ListRankingProfiles() ([]RankingProfile, error) {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.profiles))
+	for name := range s.profiles {
+		names = append(names, name)
+	}
+	s.mu.RUnlock()
+
+	sort.Strings(names)
+
+	var profiles []RankingProfile
+	for _, name := range names {
+		profile, err := s.GetRankingProfile(name)
+		if err != nil {
+			continue // 并发删除
+		}
+		profiles = append(profiles, *profile)
+	}
+
+	return profiles, nil
+}
+
+// DeleteRankingProfile 删除排序配置，同时解除集合绑定
+func (s *InMemoryStore) DeleteRankingProfile(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.profiles[name]; !ok {
+		return fmt.Errorf("%w: '%s'", ErrRankingProfileNotFound, name)
+	}
+	delete(s.profiles, name)
+
+	for collection, profile := range s.bindings {
+		if profile == name {
+			delete(s.bindings, collection)
+		}
+	}
+
+	return nil
+}
+
+// SetCollectionProfile 为集合绑定排序配置（profile为空时解除绑定）
+func (s *InMemoryStore) SetCollectionProfile(collection, profile string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if profile == "" {
+		delete(s.bindings, collection)
+		return nil
+	}
+
+	if _, ok := s.collections[collection]; !ok {
+		return fmt.Errorf("collection '%s' not found", collection)
+	}
+	if _, ok := s.profiles[profile]; !ok {
+		return fmt.Errorf("%w: '%s'", ErrRankingProfileNotFound, profile)
+	}
+
+	s.bindings[collection] = profile
+	return nil
+}
+
+// GetCollectionProfile 获取集合绑定的排序配置名称（未绑定返回空字符串）
+func (s *InMemoryStore) GetCollectionProfile(collection string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.bindings[collection], nil
+}
+
+// --- 上下文 ---
+
+// AddContext 添加上下文（已存在则更新）
+func (s *InMemoryStore) AddContext(path, content string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := toSeconds(time.Now().UTC())
+	if ctx, ok := s.contexts[path]; ok {
+		ctx.Content = content
+		ctx.UpdatedAt = now
+		return nil
+	}
+
+	s.contexts[path] = &ContextEntry{
+		Path:      path,
+		Content:   content,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	return nil
+}
+
+// sortedContexts 返回按排序函数排列的上下文副本，调用方需持有锁
+func (s *InMemoryStore) sortedContexts(less func(a, b *ContextEntry) bool) []ContextEntry {
+	entries := make([]*ContextEntry, 0, len(s.contexts))
+	for _, ctx := range s.contexts {
+		entries = append(entries, ctx)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return less(entries[i], entries[j])
+	})
+
+	result := make([]ContextEntry, len(entries))
+	for i, ctx := range entries {
+		result[i] = *ctx
+	}
+	return result
+}
+
+// ListContexts 列出所有上下文
+func (s *InMemoryStore) ListContexts() ([]ContextEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sortedContexts(func(a, b *ContextEntry) bool {
+		return a.Path < b.Path
+	}), nil
+}
+
+// GetContext 获取指定路径的上下文
+func (s *InMemoryStore) GetContext(path string) (*ContextEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ctx, ok := s.contexts[path]
+	if !ok {
+		return nil, fmt.Errorf("context not found for path: %s", path)
+	}
+
+	result := *ctx
+	return &result, nil
+}
+
+// RemoveContext 删除上下文
+func (s *InMemoryStore) RemoveContext(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.contexts[path]; !ok {
+		return fmt.Errorf("context not found for path: %s", path)
+	}
+	delete(s.contexts, path)
+
+	return nil
+}
+
+// GetContextsForPath 获取路径的所有相关上下文（从全局到具体）
+func (s *InMemoryStore) GetContextsForPath(targetPath string) ([]ContextEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var contexts []ContextEntry
+	sorted := s.sortedContexts(func(a, b *ContextEntry) bool {
+		if len(a.Path) != len(b.Path) {
+			return len(a.Path) < len(b.Path)
+		}
+		return a.Path < b.Path
+	})
+	for _, ctx := range sorted {
+		if isPathMatch(ctx.Path, targetPath) {
+			contexts = append(contexts, ctx)
+		}
+	}
+
+	return contexts, nil
+}
+
+// CheckMissingContexts 检查缺失上下文的集合和路径
+func (s *InMemoryStore) CheckMissingContexts() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var missing []string
+
+	if _, ok := s.contexts["/"]; !ok {
+		missing = append(missing, "/ (global context)")
+	}
+
+	for _, c := range s.sortedCollections() {
+		collPath := fmt.Sprintf("qmd://%s", c.Name)
+		if _, ok := s.contexts[collPath]; !ok {
+			missing = append(missing, collPath)
+		}
+	}
+
+	return missing, nil
+}
+
+// GetAllContextsForDocument 获取文档的所有相关上下文（按优先级排序）
+func (s *InMemoryStore) GetAllContextsForDocument(collection, path string) ([]ContextEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	paths := []string{
+		fmt.Sprintf("qmd://%s/%s", collection, path),
+		fmt.Sprintf("qmd://%s", collection),
+		"/",
+	}
+
+	var contexts []ContextEntry
+	for _, p := range paths {
+		if ctx, ok := s.contexts[p]; ok {
+			contexts = append(contexts, *ctx)
+		}
+	}
+
+	return contexts, nil
+}
+
+// --- 记忆 ---
+
+// InsertMemory 插入记忆
+func (s *InMemoryStore) InsertMemory(
+	ns Namespace,
+	memType, content string,
+	metadata map[string]interface{},
+	tags []string,
+	timestamp time.Time,
+	expiresAt *time.Time,
+	importance float64,
+	embedding []float32,
+) error {
+	metadataJSON, err := marshalMemoryMetadata(metadata)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.memSeq++
+	mem := &memMemory{
+		seq:        s.memSeq,
+		id:         uuid.New().String(),
+		namespace:  memoryNamespace(ns, memType, metadata),
+		memType:    memType,
+		content:    content,
+		metadata:   metadataJSON,
+		tags:       append([]string(nil), tags...),
+		timestamp:  toSeconds(timestamp),
+		expiresAt:  copyTimeSeconds(expiresAt),
+		importance: importance,
+		embedding:  append([]float32(nil), embedding...),
+	}
+	s.memories[mem.id] = mem
+
+	return nil
+}
+
+// marshalMemoryMetadata 序列化metadata（与SQLite后端一样，读取时重新解码）
+func marshalMemoryMetadata(metadata map[string]interface{}) ([]byte, error) {
+	if metadata == nil {
+		return []byte("{}"), nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	return data, nil
+}
+
+func copyTimeSeconds(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	v := toSeconds(*t)
+	return &v
+}
+
+// result 转换为MemoryResult副本
+func (m *memMemory) result() MemoryResult {
+	var metadata map[string]interface{}
+	json.Unmarshal(m.metadata, &metadata)
+
+	var tags []string
+	if len(m.tags) > 0 {
+		tags = append([]string(nil), m.tags...)
+	} else {
+		tags = []string{}
+	}
+
+	return MemoryResult{
+		ID:         m.id,
+		Namespace:  m.namespace,
+		Type:       m.memType,
+		Content:    m.content,
+		Metadata:   metadata,
+		Tags:       tags,
+		Timestamp:  m.timestamp,
+		ExpiresAt:  copyTimeSeconds(m.expiresAt),
+		Importance: m.importance,
+
+		AccessCount:  m.accessCount,
+		LastAccessed: copyTimeSeconds(m.lastAccessed),
+	}
+}
+
+// inSession 是否属于指定会话
+func (m *memMemory) inSession(sessionID string) bool {
+	return m.memType == "conversation" && m.namespace.Session == sessionID
+}
+
+// in 是否落在任一命名空间范围内
+func (m *memMemory) in(scopes []Namespace) bool {
+	return inScopes(m.namespace, scopes)
+}
+
+// selectMemories 筛选记忆并按时间倒序排列，调用方需持有锁
+func (s *InMemoryStore) selectMemories(filter func(m *memMemory) bool) []*memMemory {
+	var selected []*memMemory
+	for _, m := range s.memories {
+		if filter == nil || filter(m) {
+			selected = append(selected, m)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		if !selected[i].timestamp.Equal(selected[j].timestamp) {
+			return selected[i].timestamp.After(selected[j].timestamp)
+		}
+		return selected[i].seq > selected[j].seq
+	})
+	return selected
+}
+
+func memoryResults(memories []*memMemory, limit int) []MemoryResult {
+	if limit >= 0 && len(memories) > limit {
+		memories = memories[:limit]
+	}
+	results := make([]MemoryResult, 0, len(memories))
+	for _, m := range memories {
+		results = append(results, m.result())
+	}
+	return results
+}
+
+// SearchMemories 向量搜索记忆
+func (s *InMemoryStore) SearchMemories(queryEmbedding []float32, limit int, filter MemoryFilter, scopes []Namespace) ([]MemoryResult, error) {
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type candidate struct {
+		mem      *memMemory
+		distance float64
+	}
+
+	var candidates []candidate
+	for _, m := range s.memories {
+		if !m.in(scopes) || !filter.Match(m.result()) {
+			continue
+		}
+		candidates = append(candidates, candidate{mem: m, distance: cosineDist(queryEmbedding, m.embedding)})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].mem.seq < candidates[j].mem.seq
+	})
+
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	results := make([]MemoryResult, len(candidates))
+	for i, c := range candidates {
+		results[i] = c.mem.result()
+		results[i].Relevance = 1.0 - c.distance
+	}
+
+	return results, nil
+}
+
+// SearchMemoriesFTS BM25全文搜索记忆（内容和标签）
+func (s *InMemoryStore) SearchMemoriesFTS(query string, limit int, filter MemoryFilter, scopes []Namespace) ([]MemoryResult, error) {
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+
+	terms := ftsQueryTerms(query)
+	if len(terms) == 0 {
+		return []MemoryResult{}, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// FTS5统计基于全部已索引的记忆
+	all := s.selectMemories(nil)
+	if len(all) == 0 {
+		return nil, nil
+	}
+
+	const k1, b = 1.2, 0.75
+
+	tokens := make([][]string, len(all))
+	totalLen := 0
+	for i, m := range all {
+		tokens[i] = append(ftsTokenize(m.content), ftsTokenize(strings.Join(m.tags, " "))...)
+		totalLen += len(tokens[i])
+	}
+	avgLen := float64(totalLen) / float64(len(all))
+
+	docFreq := make([]int, len(terms))
+	tfs := make([][]int, len(all))
+	for i := range all {
+		tfs[i] = make([]int, len(terms))
+		for j, term := range terms {
+			tfs[i][j] = countPrefix(tokens[i], term)
+			if tfs[i][j] > 0 {
+				docFreq[j]++
+			}
+		}
+	}
+
+	type candidate struct {
+		mem   *memMemory
+		score float64
+	}
+
+	var candidates []candidate
+	n := float64(len(all))
+	for i, m := range all {
+		if !m.in(scopes) || !filter.Match(m.result()) {
+			continue
+		}
+
+		score := 0.0
+		matched := true
+		for j := range terms {
+			tf := float64(tfs[i][j])
+			if tf == 0 {
+				matched = false
+				break
+			}
+			idf := math.Log((n - float64(docFreq[j]) + 0.5) / (float64(docFreq[j]) + 0.5))
+			if idf <= 0 {
+				idf = 1e-6
+			}
+			dl := float64(len(tokens[i]))
+			score += idf * (tf * (k1 + 1)) / (tf + k1*(1-b+b*dl/avgLen))
+		}
+
+		if matched {
+			candidates = append(candidates, candidate{mem: m, score: score})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	results := make([]MemoryResult, len(candidates))
+	for i, c := range candidates {
+		results[i] = c.mem.result()
+		results[i].Relevance = normalizeBM25Score(-c.score)
+	}
+
+	return results, nil
+}
+
+// ListMemories 按过滤条件分页列出记忆（按时间倒序），同时返回满足条件的总数
+func (s *InMemoryStore) ListMemories(filter MemoryFilter, scopes []Namespace, order MemoryOrder, limit, offset int) ([]MemoryResult, int, error) {
+	if err := filter.validate(); err != nil {
+		return nil, 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	selected := s.selectMemories(func(m *memMemory) bool {
+		return m.in(scopes) && filter.Match(m.result())
+	})
+	total := len(selected)
+	if order == OldestFirst {
+		for i, j := 0, len(selected)-1; i < j; i, j = i+1, j-1 {
+			selected[i], selected[j] = selected[j], selected[i]
+		}
+	}
+
+	if offset > len(selected) {
+		offset = len(selected)
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+	return memoryResults(selected[offset:], limit), total, nil
+}
+
+// GetMemoryByID 根据ID获取记忆
+func (s *InMemoryStore) GetMemoryByID(id string) (*MemoryResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.memories[id]
+	if !ok {
+		return nil, fmt.Errorf("memory not found: %s", id)
+	}
+
+	result := m.result()
+	return &result, nil
+}
+
+// GetMemoriesByType 获取指定类型的所有记忆
+func (s *InMemoryStore) GetMemoriesByType(memType string, scopes []Namespace) ([]MemoryResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return memoryResults(s.selectMemories(func(m *memMemory) bool {
+		return m.memType == memType && m.in(scopes)
+	}), -1), nil
+}
+
+// GetMemoriesBySession 获取指定会话的记忆
+func (s *InMemoryStore) GetMemoriesBySession(sessionID string, limit int, scopes []Namespace) ([]MemoryResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return memoryResults(s.selectMemories(func(m *memMemory) bool {
+		return m.inSession(sessionID) && m.in(scopes)
+	}), limit), nil
+}
+
+// GetRecentMemoriesByType 获取最近的指定类型记忆
+func (s *InMemoryStore) GetRecentMemoriesByType(memType string, limit int, scopes []Namespace) ([]MemoryResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return memoryResults(s.selectMemories(func(m *memMemory) bool {
+		return m.memType == memType && m.in(scopes)
+	}), limit), nil
+}
+
+// GetMemoriesWithEmbeddings 按写入时间顺序获取记忆及其嵌入（用于批量去重）
+func (s *InMemoryStore) GetMemoriesWithEmbeddings(scopes []Namespace) ([]MemoryResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	selected := s.selectMemories(func(m *memMemory) bool {
+		return m.in(scopes)
+	})
+
+	results := make([]MemoryResult, len(selected))
+	for i, m := range selected {
+		// selectMemories按时间倒序，这里反转为正序
+		r := m.result()
+		r.Embedding = append([]float32(nil), m.embedding...)
+		results[len(selected)-1-i] = r
+	}
+
+	return results, nil
+}
+
+// UpdateMemory 更新记忆
+func (s *InMemoryStore) UpdateMemory(
+	id, content string,
+	metadata map[string]interface{},
+	tags []string,
+	expiresAt *time.Time,
+	importance float64,
+	embedding []float32,
+) error {
+	metadataJSON, err := marshalMemoryMetadata(metadata)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.memories[id]
+	if !ok {
+		return nil // 与SQLite UPDATE一致，不存在时不报错
+	}
+
+	m.content = content
+	m.metadata = metadataJSON
+	m.tags = append([]string(nil), tags...)
+	m.expiresAt = copyTimeSeconds(expiresAt)
+	m.importance = importance
+	m.embedding = append([]float32(nil), embedding...)
+
+	return nil
+}
+
+// MergeMemories 在同一把写锁内完成所有合并：更新保留记忆的元数据、标签、时间、过期时间和重要性
+// （内容和嵌入不变），并删除被合并的重复记忆
+func (s *InMemoryStore) MergeMemories(merges []MemoryMerge) error {
+	metadataJSON := make([][]byte, len(merges))
+	for i, merge := range merges {
+		data, err := marshalMemoryMetadata(merge.Metadata)
+		if err != nil {
+			return err
+		}
+		metadataJSON[i] = data
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, merge := range merges {
+		if m, ok := s.memories[merge.ID]; ok { // 与SQLite UPDATE一致，不存在时不报错
+			m.metadata = metadataJSON[i]
+			m.tags = append([]string(nil), merge.Tags...)
+			m.timestamp = toSeconds(merge.Timestamp)
+			m.expiresAt = copyTimeSeconds(merge.ExpiresAt)
+			m.importance = merge.Importance
+		}
+		for _, id := range merge.Duplicates {
+			delete(s.memories, id)
+		}
+	}
+
+	return nil
+}
+
+// DeleteMemory 删除记忆
+func (s *InMemoryStore) DeleteMemory(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.memories, id)
+	return nil
+}
+
+// deleteMemoriesWhere 删除满足条件的记忆并返回数量，调用方需持有写锁
+func (s *InMemoryStore) deleteMemoriesWhere(filter func(m *memMemory) bool) int {
+	count := 0
+	for id, m := range s.memories {
+		if filter(m) {
+			delete(s.memories, id)
+			count++
+		}
+	}
+	return count
+}
+
+// DeleteMemoriesBySession 删除指定会话的记忆
+func (s *InMemoryStore) DeleteMemoriesBySession(sessionID string, scopes []Namespace) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deleteMemoriesWhere(func(m *memMemory) bool {
+		return m.inSession(sessionID) && m.in(scopes)
+	}), nil
+}
+
+// DeleteExpiredMemories 删除过期记忆
+func (s *InMemoryStore) DeleteExpiredMemories(scopes []Namespace) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	return s.deleteMemoriesWhere(func(m *memMemory) bool {
+		return m.expiresAt != nil && m.expiresAt.Before(now) && m.in(scopes)
+	}), nil
+}
+
+// CountMemories 统计记忆总数
+func (s *InMemoryStore) CountMemories(scopes []Namespace) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, m := range s.memories {
+		if m.in(scopes) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CountMemoriesByType 统计指定类型的记忆数量
+func (s *InMemoryStore) CountMemoriesByType(memType string, scopes []Namespace) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.selectMemories(func(m *memMemory) bool {
+		return m.memType == memType && m.in(scopes)
+	})), nil
+}
+
+// CountMemoriesBySession 统计指定会话的记忆数量
+func (s *InMemoryStore) CountMemoriesBySession(sessionID string, scopes []Namespace) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.selectMemories(func(m *memMemory) bool {
+		return m.inSession(sessionID) && m.in(scopes)
+	})), nil
+}
+
+// GetSessionIDs 获取所有会话ID
+func (s *InMemoryStore) GetSessionIDs(scopes []Namespace) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var sessionIDs []string
+	for _, m := range s.selectMemories(nil) {
+		id := m.namespace.Session
+		if m.memType == "conversation" && id != "" && m.in(scopes) && !seen[id] {
+			seen[id] = true
+			sessionIDs = append(sessionIDs, id)
+		}
+	}
+
+	return sessionIDs, nil
+}
+
+// --- 记忆召回统计与归档 ---
+
+// TouchMemories 记录一次召回：召回次数加一，最近召回时间更新为at
+// 不存在的记忆ID被忽略
+func (s *InMemoryStore) TouchMemories(ids []string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	at = toSeconds(at)
+	for _, id := range ids {
+		if m, ok := s.memories[id]; ok {
+			m.accessCount++
+			accessed := at
+			m.lastAccessed = &accessed
+		}
+	}
+	return nil
+}
+
+// ArchiveMemories 将记忆移入归档（保留嵌入和召回统计），返回归档数量
+func (s *InMemoryStore) ArchiveMemories(ids []string, at time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, id := range ids {
+		m, ok := s.memories[id]
+		if !ok {
+			continue
+		}
+		delete(s.memories, id)
+		s.archived[id] = &memArchived{mem: m, archivedAt: toSeconds(at)}
+		count++
+	}
+	return count, nil
+}
+
+// ListArchivedMemories 按归档时间倒序列出范围内的归档记忆，同时返回总数
+// limit<=0表示不限
+func (s *InMemoryStore) ListArchivedMemories(scopes []Namespace, limit, offset int) ([]ArchivedMemory, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var selected []*memArchived
+	for _, a := range s.archived {
+		if a.mem.in(scopes) {
+			selected = append(selected, a)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		if !selected[i].archivedAt.Equal(selected[j].archivedAt) {
+			return selected[i].archivedAt.After(selected[j].archivedAt)
+		}
+		return selected[i].mem.seq > selected[j].mem.seq
+	})
+	total := len(selected)
+
+	if offset > len(selected) {
+		offset = len(selected)
+	}
+	selected = selected[offset:]
+	if limit > 0 && len(selected) > limit {
+		selected = selected[:limit]
+	}
+
+	results := make([]ArchivedMemory, len(selected))
+	for i, a := range selected {
+		results[i] = ArchivedMemory{MemoryResult: a.mem.result(), ArchivedAt: a.archivedAt}
+	}
+	return results, total, nil
+}
+
+// RestoreArchivedMemory 将归档记忆恢复为活跃记忆（保留原ID、命名空间和召回统计）
+func (s *InMemoryStore) RestoreArchivedMemory(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.archived[id]
+	if !ok {
+		return fmt.Errorf("failed to restore archived memory: archived memory not found: %s", id)
+	}
+	delete(s.archived, id)
+	s.memories[id] = a.mem
+	return nil
+}
+
+// --- 会话摘要 ---
+
+// SaveConversationSummary 保存会话的滚动摘要（已存在则覆盖）
+func (s *InMemoryStore) SaveConversationSummary(summary ConversationSummary) error {
+	if summary.Namespace.Session == "" {
+		return fmt.Errorf("conversation summary requires a session")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summary.UpdatedAt = toSeconds(time.Now())
+	s.convSummary[summary.Namespace] = &summary
+	return nil
+}
+
+// GetConversationSummary 获取命名空间（含会话）的滚动摘要，不存在时返回nil
+func (s *InMemoryStore) GetConversationSummary(ns Namespace) (*ConversationSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	summary, ok := s.convSummary[ns]
+	if !ok {
+		return nil, nil
+	}
+	out := *summary
+	return &out, nil
+}
+
+// DeleteConversationSummaries 删除范围内指定会话的滚动摘要
+func (s *InMemoryStore) DeleteConversationSummaries(sessionID string, scopes []Namespace) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for ns := range s.convSummary {
+		if ns.Session == sessionID && inScopes(ns, scopes) {
+			delete(s.convSummary, ns)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// --- 反思进度 ---
+
+// SaveReflectionCursor 保存命名空间的反思进度（已存在则覆盖）
+func (s *InMemoryStore) SaveReflectionCursor(cursor ReflectionCursor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cursor.Through = toSeconds(cursor.Through)
+	cursor.ThroughIDs = append([]string(nil), cursor.ThroughIDs...)
+	cursor.UpdatedAt = toSeconds(time.Now())
+	s.reflection[cursor.Namespace] = &cursor
+	return nil
+}
+
+// GetReflectionCursor 获取命名空间的反思进度，不存在时返回nil
+func (s *InMemoryStore) GetReflectionCursor(ns Namespace) (*ReflectionCursor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cursor, ok := s.reflection[ns]
+	if !ok {
+		return nil, nil
+	}
+	out := *cursor
+	out.ThroughIDs = append([]string(nil), cursor.ThroughIDs...)
+	return &out, nil
+}
+
+// --- 知识图谱事实 ---
+
+// InsertFact 插入事实，ID为空时自动生成，返回事实ID
+func (s *InMemoryStore) InsertFact(fact FactRecord) (string, error) {
+	if fact.ID == "" {
+		fact.ID = uuid.New().String()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, f := range s.facts {
+		if f.ID == fact.ID {
+			return "", fmt.Errorf("failed to insert fact: duplicate id %s", fact.ID)
+		}
+	}
+
+	fact.ValidFrom = toSeconds(fact.ValidFrom)
+	fact.SupersededAt = optionalSeconds(fact.SupersededAt)
+	fact.Sources = copySources(fact.Sources)
+	fact.UpdatedAt = toSeconds(time.Now())
+	s.facts = append(s.facts, &fact)
+	return fact.ID, nil
+}
+
+// UpdateFact 更新事实的置信度、来源和取代状态
+func (s *InMemoryStore) UpdateFact(fact FactRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, f := range s.facts {
+		if f.ID == fact.ID {
+			f.Confidence = fact.Confidence
+			f.Sources = copySources(fact.Sources)
+			f.SupersededAt = optionalSeconds(fact.SupersededAt)
+			f.SupersededBy = fact.SupersededBy
+			f.UpdatedAt = toSeconds(time.Now())
+			return nil
+		}
+	}
+	return fmt.Errorf("fact not found: %s", fact.ID)
+}
+
+// AssertFact 在同一把锁内断言事实：读取当前值和函数型谓词设置，写入或合并事实，并标记被取代的旧值
+func (s *InMemoryStore) AssertFact(a FactAssertion) (*FactAssertResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var current []FactRecord
+	for _, f := range s.facts {
+		if f.Namespace == a.Namespace && f.Subject == a.Subject && f.Predicate == a.Predicate && f.SupersededAt == nil {
+			fact := *f
+			fact.Sources = copySources(f.Sources)
+			current = append(current, fact)
+		}
+	}
+	sort.SliceStable(current, func(i, j int) bool {
+		return current[i].ValidFrom.Before(current[j].ValidFrom)
+	})
+
+	a.At = toSeconds(a.At)
+	record, isNew, superseded := resolveAssertion(a, current, s.functional[functionalKey{a.Namespace, a.Predicate}])
+	now := toSeconds(time.Now())
+	record.UpdatedAt = now
+	if isNew {
+		stored := record
+		stored.Sources = copySources(record.Sources)
+		s.facts = append(s.facts, &stored)
+	}
+	for _, f := range s.facts {
+		for _, update := range append([]FactRecord{record}, superseded...) {
+			if f.ID == update.ID {
+				f.Confidence = update.Confidence
+				f.Sources = copySources(update.Sources)
+				f.SupersededAt = optionalSeconds(update.SupersededAt)
+				f.SupersededBy = update.SupersededBy
+				f.UpdatedAt = now
+			}
+		}
+	}
+	return &FactAssertResult{Fact: record, Superseded: superseded}, nil
+}
+
+// SetFunctionalPredicates 将命名空间内的谓词设为函数型（同一主体只有一个当前值）
+func (s *InMemoryStore) SetFunctionalPredicates(ns Namespace, predicates []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range predicates {
+		s.functional[functionalKey{ns, p}] = true
+	}
+	return nil
+}
+
+// QueryFacts 按模式查询事实（按生效时间顺序），limit<=0表示不限
+func (s *InMemoryStore) QueryFacts(pattern FactPattern, scopes []Namespace, limit int) ([]FactRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var facts []FactRecord
+	for _, f := range s.facts {
+		if pattern.Match(*f) && inScopes(f.Namespace, scopes) {
+			fact := *f
+			fact.Sources = copySources(f.Sources)
+			facts = append(facts, fact)
+		}
+	}
+	sort.SliceStable(facts, func(i, j int) bool {
+		return facts[i].ValidFrom.Before(facts[j].ValidFrom)
+	})
+
+	if limit > 0 && len(facts) > limit {
+		facts = facts[:limit]
+	}
+	return facts, nil
+}
+
+// DeleteFact 删除事实
+func (s *InMemoryStore) DeleteFact(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, f := range s.facts {
+		if f.ID == id {
+			s.facts = append(s.facts[:i], s.facts[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func optionalSeconds(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	truncated := toSeconds(*t)
+	return &truncated
+}
+
+func copySources(sources map[string]float64) map[string]float64 {
+	if sources == nil {
+		return nil
+	}
+	out := make(map[string]float64, len(sources))
+	for k, v := range sources {
+		out[k] = v
+	}
+	return out
+}
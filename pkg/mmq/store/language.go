@@ -0,0 +1,227 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/crosszan/modu/pkg/mmq/internal/langdetect"
+)
+
+// segmentCJK 将连续的汉字/假名/谚文切分为重叠的双字词，其余文本保持不变
+// 例如 "Go语言并发" -> "Go 语言 言并 并发"。unicode61分词器会把整段CJK文字当作一个词，
+// 切分后中文查询才能按词匹配。
+func segmentCJK(text string) string {
+	if !langdetect.HasCJK(text) {
+		return text
+	}
+
+	var b strings.Builder
+	var run []rune
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		b.WriteByte(' ')
+		if len(run) == 1 {
+			b.WriteRune(run[0])
+		}
+		for i := 0; i+1 < len(run); i++ {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(run[i])
+			b.WriteRune(run[i+1])
+		}
+		b.WriteByte(' ')
+		run = run[:0]
+	}
+
+	for _, r := range text {
+		if langdetect.IsCJK(r) {
+			run = append(run, r)
+			continue
+		}
+		flush()
+		b.WriteRune(r)
+	}
+	flush()
+
+	return b.String()
+}
+
+// ftsTable 查询路由：包含CJK文字的查询使用双字切分的索引
+func ftsTable(query string) string {
+	if langdetect.HasCJK(query) {
+		return "documents_fts_cjk"
+	}
+	return "documents_fts"
+}
+
+// detectLanguage 检测新内容的语言，内容哈希已有记录时保持不变
+func detectLanguage(tx *sql.Tx, hash, doc string) error {
+	_, err := tx.Exec(
+		"INSERT OR IGNORE INTO content_languages (hash, language) VALUES (?, ?)",
+		hash, langdetect.Detect(doc),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert content language: %w", err)
+	}
+	return nil
+}
+
+// indexCJK 为满足条件（documents表别名为d）的有效文档写入CJK全文索引
+// 只收录包含CJK文字的文档；文档更新时旧索引行由documents_cjk_stale触发器移除
+func indexCJK(tx *sql.Tx, where string, args ...interface{}) error {
+	rows, err := tx.Query(`
+		SELECT d.id, d.collection, d.path, d.title, c.doc
+		FROM documents d
+		JOIN content c ON c.hash = d.hash
+		WHERE d.active = 1 AND `+where, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query documents for CJK index: %w", err)
+	}
+
+	type cjkDoc struct {
+		id                      int64
+		collection, path, title string
+		body                    string
+	}
+	var docs []cjkDoc
+	for rows.Next() {
+		var d cjkDoc
+		if err := rows.Scan(&d.id, &d.collection, &d.path, &d.title, &d.body); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan document for CJK index: %w", err)
+		}
+		if langdetect.HasCJK(d.path + d.title + d.body) {
+			docs = append(docs, d)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query documents for CJK index: %w", err)
+	}
+
+	for _, d := range docs {
+		if _, err := tx.Exec("DELETE FROM documents_fts_cjk WHERE rowid = ?", d.id); err != nil {
+			return fmt.Errorf("failed to clear CJK index: %w", err)
+		}
+		_, err := tx.Exec(
+			"INSERT INTO documents_fts_cjk (rowid, filepath, title, body) VALUES (?, ?, ?, ?)",
+			d.id, segmentCJK(d.collection+"/"+d.path), segmentCJK(d.title), segmentCJK(d.body),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert CJK index: %w", err)
+		}
+	}
+	return nil
+}
+
+// backfillLanguages 为旧版本写入的内容补充语言和CJK全文索引
+func (s *Store) backfillLanguages() error {
+	var missing int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM content c
+		WHERE NOT EXISTS (SELECT 1 FROM content_languages l WHERE l.hash = c.hash)
+	`).Scan(&missing)
+	if err != nil {
+		return fmt.Errorf("failed to count content without language: %w", err)
+	}
+	if missing == 0 {
+		return nil
+	}
+
+	return s.withTx(func(tx *sql.Tx) error {
+		// 先补CJK索引：语言记录写入后就无法区分哪些文档是旧版本写入的
+		if err := indexCJK(tx, "NOT EXISTS (SELECT 1 FROM content_languages l WHERE l.hash = d.hash)"); err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(`
+			SELECT hash, doc FROM content c
+			WHERE NOT EXISTS (SELECT 1 FROM content_languages l WHERE l.hash = c.hash)
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to query content without language: %w", err)
+		}
+		pending := make(map[string]string)
+		for rows.Next() {
+			var hash, doc string
+			if err := rows.Scan(&hash, &doc); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan content: %w", err)
+			}
+			pending[hash] = doc
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to query content without language: %w", err)
+		}
+
+		for hash, doc := range pending {
+			if err := detectLanguage(tx, hash, doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DocumentLanguages 返回有效文档的语言（collection/path -> 语言代码，无法判断时为空字符串）
+// collection为空时返回全部集合
+func (s *Store) DocumentLanguages(collection string) (map[string]string, error) {
+	query := `
+		SELECT d.collection, d.path, COALESCE(l.language, '')
+		FROM documents d
+		LEFT JOIN content_languages l ON l.hash = d.hash
+		WHERE d.active = 1
+	`
+	var args []interface{}
+	if collection != "" {
+		query += " AND d.collection = ?"
+		args = append(args, collection)
+	}
+
+	rows, err := s.readDB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query languages: %w", err)
+	}
+	defer rows.Close()
+
+	languages := make(map[string]string)
+	for rows.Next() {
+		var coll, path, lang string
+		if err := rows.Scan(&coll, &path, &lang); err != nil {
+			return nil, fmt.Errorf("failed to scan language: %w", err)
+		}
+		languages[coll+"/"+path] = lang
+	}
+	return languages, rows.Err()
+}
+
+// LanguageMismatchPenalty 偏好语言之外的文档分数乘以该系数
+const LanguageMismatchPenalty = 0.5
+
+// ApplyLanguagePreference 偏好语言优先，并按新分数重新排序
+// 其他语言的文档分数乘以LanguageMismatchPenalty；无法判断语言的文档（如纯代码）保持原分数。
+// 文档语言由检索查询一并返回（SearchResult.Language），不再单独查询。
+func ApplyLanguagePreference(results []SearchResult, lang string) []SearchResult {
+	if lang == "" || len(results) == 0 {
+		return results
+	}
+
+	for i := range results {
+		r := &results[i]
+		if r.Language != "" && r.Language != lang {
+			r.Score *= LanguageMismatchPenalty
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	return results
+}
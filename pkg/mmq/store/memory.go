@@ -5,14 +5,91 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// Namespace 记忆的归属：租户、用户、agent和会话
+// 作为查询范围时，非空字段必须完全相同，空字段不限定；零值表示全部记忆
+type Namespace struct {
+	Tenant  string
+	User    string
+	Agent   string
+	Session string
+}
+
+// IsZero 是否未设置任何字段
+func (ns Namespace) IsZero() bool {
+	return ns == Namespace{}
+}
+
+// Contains 作为查询范围时是否包含命名空间为other的记忆
+func (ns Namespace) Contains(other Namespace) bool {
+	return (ns.Tenant == "" || ns.Tenant == other.Tenant) &&
+		(ns.User == "" || ns.User == other.User) &&
+		(ns.Agent == "" || ns.Agent == other.Agent) &&
+		(ns.Session == "" || ns.Session == other.Session)
+}
+
+// inScopes 记忆是否落在任一范围内，scopes为空表示不限定
+func inScopes(ns Namespace, scopes []Namespace) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+	for _, scope := range scopes {
+		if scope.Contains(ns) {
+			return true
+		}
+	}
+	return false
+}
+
+// memoryNamespace 补全写入记忆的命名空间
+// 旧版本把会话ID放在会话记忆的metadata.session_id中，未显式指定会话时沿用
+func memoryNamespace(ns Namespace, memType string, metadata map[string]interface{}) Namespace {
+	if ns.Session == "" && memType == "conversation" {
+		if sessionID, ok := metadata["session_id"].(string); ok {
+			ns.Session = sessionID
+		}
+	}
+	return ns
+}
+
+// scopeClause 生成命名空间范围的WHERE条件（多个范围取并集），不限定时返回空字符串
+// alias为带命名空间列的表（或其别名）
+func scopeClause(alias string, scopes []Namespace) (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+	for _, scope := range scopes {
+		if scope.IsZero() {
+			return "", nil
+		}
+		var conds []string
+		for _, f := range []struct{ column, value string }{
+			{"tenant", scope.Tenant},
+			{"user_id", scope.User},
+			{"agent_id", scope.Agent},
+			{"session_id", scope.Session},
+		} {
+			if f.value != "" {
+				conds = append(conds, alias+"."+f.column+" = ?")
+				args = append(args, f.value)
+			}
+		}
+		clauses = append(clauses, "("+strings.Join(conds, " AND ")+")")
+	}
+	if len(clauses) == 0 {
+		return "", nil
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", args
+}
+
 // MemoryResult 记忆查询结果
 type MemoryResult struct {
 	ID         string
+	Namespace  Namespace
 	Type       string
 	Content    string
 	Metadata   map[string]interface{}
@@ -20,11 +97,16 @@ type MemoryResult struct {
 	Timestamp  time.Time
 	ExpiresAt  *time.Time
 	Importance float64
-	Relevance  float64 // 向量搜索时的相关度
+	Relevance  float64   // 向量搜索时的相关度
+	Embedding  []float32 // 仅GetMemoriesWithEmbeddings填充
+
+	AccessCount  int        // 被召回的次数
+	LastAccessed *time.Time // 最近一次被召回的时间，nil表示从未被召回
 }
 
 // InsertMemory 插入记忆
 func (s *Store) InsertMemory(
+	ns Namespace,
 	memType, content string,
 	metadata map[string]interface{},
 	tags []string,
@@ -66,41 +148,51 @@ func (s *Store) InsertMemory(
 		expiresAtStr = &str
 	}
 
+	ns = memoryNamespace(ns, memType, metadata)
+
 	// 插入数据库
-	_, err := s.db.Exec(`
-		INSERT INTO memories (id, type, content, metadata, tags, timestamp, expires_at, importance, embedding)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, id, memType, content, metadataJSON, tagsJSON, timestamp.Format(time.RFC3339), expiresAtStr, importance, embeddingBlob)
+	return s.withTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			INSERT INTO memories (id, tenant, user_id, agent_id, session_id, type, content, metadata, tags, timestamp, expires_at, importance, embedding)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, id, ns.Tenant, ns.User, ns.Agent, ns.Session, memType, content, metadataJSON, tagsJSON, timestamp.Format(time.RFC3339), expiresAtStr, importance, embeddingBlob)
+		if err != nil {
+			return err
+		}
 
-	return err
+		return indexMemoryFTS(tx, id)
+	})
 }
 
-// SearchMemories 向量搜索记忆
-func (s *Store) SearchMemories(queryEmbedding []float32, limit int, memoryTypes []string) ([]MemoryResult, error) {
-	// 构建类型过滤
-	whereClause := ""
-	args := make([]interface{}, 0)
+// SearchMemories 向量搜索记忆，scopes为空时搜索全部命名空间
+func (s *Store) SearchMemories(queryEmbedding []float32, limit int, filter MemoryFilter, scopes []Namespace) ([]MemoryResult, error) {
+	// 构建过滤条件
+	conds, args, err := filter.clause()
+	if err != nil {
+		return nil, err
+	}
 
-	if len(memoryTypes) > 0 {
-		placeholders := ""
-		for i, mt := range memoryTypes {
-			if i > 0 {
-				placeholders += ", "
-			}
-			placeholders += "?"
-			args = append(args, mt)
-		}
-		whereClause = fmt.Sprintf("WHERE type IN (%s)", placeholders)
+	// 命名空间过滤
+	if clause, scopeArgs := scopeClause("m", scopes); clause != "" {
+		conds = append(conds, clause)
+		args = append(args, scopeArgs...)
+	}
+
+	whereClause := ""
+	if len(conds) > 0 {
+		whereClause = "WHERE " + strings.Join(conds, " AND ")
 	}
 
 	// 查询所有记忆
 	query := fmt.Sprintf(`
-		SELECT id, type, content, metadata, tags, timestamp, expires_at, importance, embedding
-		FROM memories
+		SELECT m.id, m.type, m.content, m.metadata, m.tags, m.timestamp, m.expires_at, m.importance, m.embedding,
+			m.tenant, m.user_id, m.agent_id, m.session_id,
+			m.access_count, m.last_accessed
+		FROM memories m
 		%s
 	`, whereClause)
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.readDB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -119,9 +211,13 @@ func (s *Store) SearchMemories(queryEmbedding []float32, limit int, memoryTypes
 		var expiresAtStr sql.NullString
 		var importance float64
 		var embeddingBlob []byte
+		var ns Namespace
+		var accessCount int
+		var lastAccessed sql.NullString
 
 		err := rows.Scan(&id, &memType, &content, &metadataJSON, &tagsJSON,
-			&timestampStr, &expiresAtStr, &importance, &embeddingBlob)
+			&timestampStr, &expiresAtStr, &importance, &embeddingBlob,
+			&ns.Tenant, &ns.User, &ns.Agent, &ns.Session, &accessCount, &lastAccessed)
 		if err != nil {
 			continue
 		}
@@ -152,6 +248,7 @@ func (s *Store) SearchMemories(queryEmbedding []float32, limit int, memoryTypes
 
 		result := MemoryResult{
 			ID:         id,
+			Namespace:  ns,
 			Type:       memType,
 			Content:    content,
 			Metadata:   metadata,
@@ -160,6 +257,9 @@ func (s *Store) SearchMemories(queryEmbedding []float32, limit int, memoryTypes
 			ExpiresAt:  expiresAt,
 			Importance: importance,
 			Relevance:  1.0 - distance, // 余弦相似度
+
+			AccessCount:  accessCount,
+			LastAccessed: parseOptionalTime(lastAccessed),
 		}
 
 		candidates = append(candidates, candidate{
@@ -190,18 +290,86 @@ func (s *Store) SearchMemories(queryEmbedding []float32, limit int, memoryTypes
 	return results, nil
 }
 
+// SearchMemoriesFTS BM25全文搜索记忆（内容和标签），scopes为空时搜索全部命名空间
+// 与文档全文检索一样，各查询词前缀匹配并以AND连接；Relevance为归一化的BM25分数
+func (s *Store) SearchMemoriesFTS(query string, limit int, filter MemoryFilter, scopes []Namespace) ([]MemoryResult, error) {
+	ftsQuery := buildFTS5Query(segmentCJK(query))
+	if ftsQuery == "" {
+		return []MemoryResult{}, nil
+	}
+
+	filterConds, filterArgs, err := filter.clause()
+	if err != nil {
+		return nil, err
+	}
+	conds := append([]string{"memories_fts MATCH ?"}, filterConds...)
+	args := append([]interface{}{ftsQuery}, filterArgs...)
+
+	if clause, scopeArgs := scopeClause("m", scopes); clause != "" {
+		conds = append(conds, clause)
+		args = append(args, scopeArgs...)
+	}
+	args = append(args, limit)
+
+	rows, err := s.readDB.Query(`
+		SELECT m.id, m.type, m.content, m.metadata, m.tags, m.timestamp, m.expires_at, m.importance,
+			m.tenant, m.user_id, m.agent_id, m.session_id,
+			m.access_count, m.last_accessed, bm25(memories_fts) AS score
+		FROM memories_fts
+		JOIN memory_fts_keys k ON k.fts_key = memories_fts.rowid
+		JOIN memories m ON m.id = k.memory_id
+		WHERE `+strings.Join(conds, " AND ")+`
+		ORDER BY score
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search memories: %w", err)
+	}
+	defer rows.Close()
+
+	var results []MemoryResult
+	for rows.Next() {
+		var r MemoryResult
+		var metadataJSON, tagsJSON, timestampStr string
+		var expiresAtStr, lastAccessed sql.NullString
+		var score float64
+
+		err := rows.Scan(&r.ID, &r.Type, &r.Content, &metadataJSON, &tagsJSON,
+			&timestampStr, &expiresAtStr, &r.Importance,
+			&r.Namespace.Tenant, &r.Namespace.User, &r.Namespace.Agent, &r.Namespace.Session,
+			&r.AccessCount, &lastAccessed, &score)
+		if err != nil {
+			return nil, err
+		}
+
+		json.Unmarshal([]byte(metadataJSON), &r.Metadata)
+		json.Unmarshal([]byte(tagsJSON), &r.Tags)
+		r.Timestamp, _ = time.Parse(time.RFC3339, timestampStr)
+		if expiresAtStr.Valid {
+			t, _ := time.Parse(time.RFC3339, expiresAtStr.String)
+			r.ExpiresAt = &t
+		}
+		r.LastAccessed = parseOptionalTime(lastAccessed)
+		r.Relevance = normalizeBM25Score(score)
+
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
 // GetMemoryByID 根据ID获取记忆
 func (s *Store) GetMemoryByID(id string) (*MemoryResult, error) {
 	var memType, content, metadataJSON, tagsJSON, timestampStr string
 	var expiresAtStr sql.NullString
 	var importance float64
+	var ns Namespace
+	var accessCount int
+	var lastAccessed sql.NullString
 
-	err := s.db.QueryRow(`
-		SELECT id, type, content, metadata, tags, timestamp, expires_at, importance
-		FROM memories
-		WHERE id = ?
-	`, id).Scan(&id, &memType, &content, &metadataJSON, &tagsJSON,
-		&timestampStr, &expiresAtStr, &importance)
+	err := s.readDB.QueryRow(memorySelect+" WHERE m.id = ?", id).Scan(&id, &memType, &content, &metadataJSON, &tagsJSON,
+		&timestampStr, &expiresAtStr, &importance, &ns.Tenant, &ns.User, &ns.Agent, &ns.Session,
+		&accessCount, &lastAccessed)
 
 	if err != nil {
 		return nil, err
@@ -227,6 +395,7 @@ func (s *Store) GetMemoryByID(id string) (*MemoryResult, error) {
 
 	return &MemoryResult{
 		ID:         id,
+		Namespace:  ns,
 		Type:       memType,
 		Content:    content,
 		Metadata:   metadata,
@@ -234,60 +403,76 @@ func (s *Store) GetMemoryByID(id string) (*MemoryResult, error) {
 		Timestamp:  timestamp,
 		ExpiresAt:  expiresAt,
 		Importance: importance,
+
+		AccessCount:  accessCount,
+		LastAccessed: parseOptionalTime(lastAccessed),
 	}, nil
 }
 
 // GetMemoriesByType 获取指定类型的所有记忆
-func (s *Store) GetMemoriesByType(memType string) ([]MemoryResult, error) {
-	rows, err := s.db.Query(`
-		SELECT id, type, content, metadata, tags, timestamp, expires_at, importance
-		FROM memories
-		WHERE type = ?
-		ORDER BY timestamp DESC
-	`, memType)
-
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	return s.scanMemoryResults(rows)
+func (s *Store) GetMemoriesByType(memType string, scopes []Namespace) ([]MemoryResult, error) {
+	return s.queryMemories([]string{"m.type = ?"}, []interface{}{memType}, scopes,
+		"ORDER BY m.timestamp DESC")
 }
 
 // GetMemoriesBySession 获取指定会话的记忆
-func (s *Store) GetMemoriesBySession(sessionID string, limit int) ([]MemoryResult, error) {
-	rows, err := s.db.Query(`
-		SELECT id, type, content, metadata, tags, timestamp, expires_at, importance
-		FROM memories
-		WHERE type = 'conversation' AND json_extract(metadata, '$.session_id') = ?
-		ORDER BY timestamp DESC
-		LIMIT ?
-	`, sessionID, limit)
+func (s *Store) GetMemoriesBySession(sessionID string, limit int, scopes []Namespace) ([]MemoryResult, error) {
+	return s.queryMemories([]string{"m.type = 'conversation'", "m.session_id = ?"}, []interface{}{sessionID}, scopes,
+		"ORDER BY datetime(m.timestamp) DESC, m.rowid DESC LIMIT ?", limit)
+}
 
+// GetMemoriesWithEmbeddings 按写入时间顺序获取记忆及其嵌入（用于批量去重）
+func (s *Store) GetMemoriesWithEmbeddings(scopes []Namespace) ([]MemoryResult, error) {
+	query := `
+		SELECT m.id, m.type, m.content, m.metadata, m.tags, m.timestamp, m.expires_at, m.importance, m.embedding,
+			m.tenant, m.user_id, m.agent_id, m.session_id
+		FROM memories m
+	`
+	clause, args := scopeClause("m", scopes)
+	if clause != "" {
+		query += " WHERE " + clause
+	}
+	query += " ORDER BY m.timestamp, m.rowid"
+
+	rows, err := s.readDB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	return s.scanMemoryResults(rows)
-}
+	var results []MemoryResult
+	for rows.Next() {
+		var r MemoryResult
+		var metadataJSON, tagsJSON, timestampStr string
+		var expiresAtStr sql.NullString
+		var embeddingBlob []byte
 
-// GetRecentMemoriesByType 获取最近的指定类型记忆
-func (s *Store) GetRecentMemoriesByType(memType string, limit int) ([]MemoryResult, error) {
-	rows, err := s.db.Query(`
-		SELECT id, type, content, metadata, tags, timestamp, expires_at, importance
-		FROM memories
-		WHERE type = ?
-		ORDER BY timestamp DESC
-		LIMIT ?
-	`, memType, limit)
+		err := rows.Scan(&r.ID, &r.Type, &r.Content, &metadataJSON, &tagsJSON,
+			&timestampStr, &expiresAtStr, &r.Importance, &embeddingBlob,
+			&r.Namespace.Tenant, &r.Namespace.User, &r.Namespace.Agent, &r.Namespace.Session)
+		if err != nil {
+			return nil, err
+		}
 
-	if err != nil {
-		return nil, err
+		json.Unmarshal([]byte(metadataJSON), &r.Metadata)
+		json.Unmarshal([]byte(tagsJSON), &r.Tags)
+		r.Timestamp, _ = time.Parse(time.RFC3339, timestampStr)
+		if expiresAtStr.Valid {
+			t, _ := time.Parse(time.RFC3339, expiresAtStr.String)
+			r.ExpiresAt = &t
+		}
+		r.Embedding = blobToFloat32(embeddingBlob)
+
+		results = append(results, r)
 	}
-	defer rows.Close()
 
-	return s.scanMemoryResults(rows)
+	return results, rows.Err()
+}
+
+// GetRecentMemoriesByType 获取最近的指定类型记忆
+func (s *Store) GetRecentMemoriesByType(memType string, limit int, scopes []Namespace) ([]MemoryResult, error) {
+	return s.queryMemories([]string{"m.type = ?"}, []interface{}{memType}, scopes,
+		"ORDER BY m.timestamp DESC LIMIT ?", limit)
 }
 
 // UpdateMemory 更新记忆
@@ -329,85 +514,134 @@ func (s *Store) UpdateMemory(
 		expiresAtStr = &str
 	}
 
-	_, err := s.db.Exec(`
-		UPDATE memories
-		SET content = ?, metadata = ?, tags = ?, expires_at = ?, importance = ?, embedding = ?
-		WHERE id = ?
-	`, content, metadataJSON, tagsJSON, expiresAtStr, importance, embeddingBlob, id)
+	return s.withTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			UPDATE memories
+			SET content = ?, metadata = ?, tags = ?, expires_at = ?, importance = ?, embedding = ?
+			WHERE id = ?
+		`, content, metadataJSON, tagsJSON, expiresAtStr, importance, embeddingBlob, id)
+		if err != nil {
+			return err
+		}
 
-	return err
+		return indexMemoryFTS(tx, id)
+	})
+}
+
+// MemoryMerge 一次重复记忆合并：更新保留的记忆，删除被它吸收的重复记忆
+type MemoryMerge struct {
+	ID         string // 保留的记忆
+	Metadata   map[string]interface{}
+	Tags       []string
+	Timestamp  time.Time
+	ExpiresAt  *time.Time
+	Importance float64
+	Duplicates []string // 合并后删除的记忆
+}
+
+// MergeMemories 在同一事务中完成所有合并：更新保留记忆的元数据、标签、时间、过期时间和重要性
+// （内容和嵌入不变），并删除被合并的重复记忆
+func (s *Store) MergeMemories(merges []MemoryMerge) error {
+	type encoded struct {
+		metadata, tags string
+		expiresAt      *string
+	}
+	values := make([]encoded, len(merges))
+	for i, merge := range merges {
+		values[i].metadata = "{}"
+		if merge.Metadata != nil {
+			data, err := json.Marshal(merge.Metadata)
+			if err != nil {
+				return fmt.Errorf("failed to marshal metadata: %w", err)
+			}
+			values[i].metadata = string(data)
+		}
+
+		values[i].tags = "[]"
+		if merge.Tags != nil {
+			data, err := json.Marshal(merge.Tags)
+			if err != nil {
+				return fmt.Errorf("failed to marshal tags: %w", err)
+			}
+			values[i].tags = string(data)
+		}
+
+		if merge.ExpiresAt != nil {
+			str := merge.ExpiresAt.Format(time.RFC3339)
+			values[i].expiresAt = &str
+		}
+	}
+
+	return s.withTx(func(tx *sql.Tx) error {
+		for i, merge := range merges {
+			_, err := tx.Exec(`
+				UPDATE memories
+				SET metadata = ?, tags = ?, timestamp = ?, expires_at = ?, importance = ?
+				WHERE id = ?
+			`, values[i].metadata, values[i].tags, merge.Timestamp.Format(time.RFC3339),
+				values[i].expiresAt, merge.Importance, merge.ID)
+			if err != nil {
+				return fmt.Errorf("failed to merge memory %s: %w", merge.ID, err)
+			}
+			if err := indexMemoryFTS(tx, merge.ID); err != nil {
+				return err
+			}
+
+			for _, id := range merge.Duplicates {
+				if _, err := tx.Exec("DELETE FROM memories WHERE id = ?", id); err != nil {
+					return fmt.Errorf("failed to delete duplicate memory %s: %w", id, err)
+				}
+			}
+		}
+		return nil
+	})
 }
 
 // DeleteMemory 删除记忆
 func (s *Store) DeleteMemory(id string) error {
-	_, err := s.db.Exec("DELETE FROM memories WHERE id = ?", id)
+	_, err := s.exec("DELETE FROM memories WHERE id = ?", id)
 	return err
 }
 
 // DeleteMemoriesBySession 删除指定会话的记忆
-func (s *Store) DeleteMemoriesBySession(sessionID string) (int, error) {
-	result, err := s.db.Exec(`
-		DELETE FROM memories
-		WHERE type = 'conversation' AND json_extract(metadata, '$.session_id') = ?
-	`, sessionID)
-
-	if err != nil {
-		return 0, err
-	}
-
-	count, _ := result.RowsAffected()
-	return int(count), nil
+func (s *Store) DeleteMemoriesBySession(sessionID string, scopes []Namespace) (int, error) {
+	return s.deleteMemories([]string{"m.type = 'conversation'", "m.session_id = ?"}, []interface{}{sessionID}, scopes)
 }
 
 // DeleteExpiredMemories 删除过期记忆
-func (s *Store) DeleteExpiredMemories() (int, error) {
+func (s *Store) DeleteExpiredMemories(scopes []Namespace) (int, error) {
 	now := time.Now().Format(time.RFC3339)
-
-	result, err := s.db.Exec(`
-		DELETE FROM memories
-		WHERE expires_at IS NOT NULL AND expires_at < ?
-	`, now)
-
-	if err != nil {
-		return 0, err
-	}
-
-	count, _ := result.RowsAffected()
-	return int(count), nil
+	return s.deleteMemories([]string{"m.expires_at IS NOT NULL", "m.expires_at < ?"}, []interface{}{now}, scopes)
 }
 
 // CountMemories 统计记忆总数
-func (s *Store) CountMemories() (int, error) {
-	var count int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM memories").Scan(&count)
-	return count, err
+func (s *Store) CountMemories(scopes []Namespace) (int, error) {
+	return s.countMemories(nil, nil, scopes)
 }
 
 // CountMemoriesByType 统计指定类型的记忆数量
-func (s *Store) CountMemoriesByType(memType string) (int, error) {
-	var count int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM memories WHERE type = ?", memType).Scan(&count)
-	return count, err
+func (s *Store) CountMemoriesByType(memType string, scopes []Namespace) (int, error) {
+	return s.countMemories([]string{"m.type = ?"}, []interface{}{memType}, scopes)
 }
 
 // CountMemoriesBySession 统计指定会话的记忆数量
-func (s *Store) CountMemoriesBySession(sessionID string) (int, error) {
-	var count int
-	err := s.db.QueryRow(`
-		SELECT COUNT(*) FROM memories
-		WHERE type = 'conversation' AND json_extract(metadata, '$.session_id') = ?
-	`, sessionID).Scan(&count)
-	return count, err
+func (s *Store) CountMemoriesBySession(sessionID string, scopes []Namespace) (int, error) {
+	return s.countMemories([]string{"m.type = 'conversation'", "m.session_id = ?"}, []interface{}{sessionID}, scopes)
 }
 
 // GetSessionIDs 获取所有会话ID
-func (s *Store) GetSessionIDs() ([]string, error) {
-	rows, err := s.db.Query(`
-		SELECT DISTINCT json_extract(metadata, '$.session_id')
-		FROM memories
-		WHERE type = 'conversation' AND json_extract(metadata, '$.session_id') IS NOT NULL
-	`)
+func (s *Store) GetSessionIDs(scopes []Namespace) ([]string, error) {
+	conds := []string{"m.type = 'conversation'", "m.session_id != ''"}
+	var args []interface{}
+	if clause, scopeArgs := scopeClause("m", scopes); clause != "" {
+		conds = append(conds, clause)
+		args = append(args, scopeArgs...)
+	}
 
+	rows, err := s.readDB.Query(`
+		SELECT DISTINCT m.session_id
+		FROM memories m
+		WHERE `+strings.Join(conds, " AND "), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -425,6 +659,219 @@ func (s *Store) GetSessionIDs() ([]string, error) {
 	return sessionIDs, nil
 }
 
+// indexMemoryFTS 写入记忆的全文索引行（内容和标签切分CJK文字），记忆不存在时忽略
+func indexMemoryFTS(tx *sql.Tx, id string) error {
+	var content, tags string
+	err := tx.QueryRow("SELECT content, COALESCE(tags, '') FROM memories WHERE id = ?", id).Scan(&content, &tags)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load memory for index: %w", err)
+	}
+
+	if _, err := tx.Exec("INSERT OR IGNORE INTO memory_fts_keys (memory_id) VALUES (?)", id); err != nil {
+		return fmt.Errorf("failed to allocate memory index key: %w", err)
+	}
+	var key int64
+	if err := tx.QueryRow("SELECT fts_key FROM memory_fts_keys WHERE memory_id = ?", id).Scan(&key); err != nil {
+		return fmt.Errorf("failed to load memory index key: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM memories_fts WHERE rowid = ?", key); err != nil {
+		return fmt.Errorf("failed to clear memory index: %w", err)
+	}
+	_, err = tx.Exec("INSERT INTO memories_fts (rowid, content, tags) VALUES (?, ?, ?)",
+		key, segmentCJK(content), segmentCJK(tags))
+	if err != nil {
+		return fmt.Errorf("failed to index memory: %w", err)
+	}
+	return nil
+}
+
+// backfillMemoryFTS 为缺少全文索引的记忆建立索引
+// 旧版本的索引行以memories.rowid为键，没有键表记录时整体重建
+func (s *Store) backfillMemoryFTS() error {
+	return s.withTx(func(tx *sql.Tx) error {
+		var keys, indexed int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM memory_fts_keys").Scan(&keys); err != nil {
+			return fmt.Errorf("failed to count memory index keys: %w", err)
+		}
+		if err := tx.QueryRow("SELECT COUNT(*) FROM memories_fts").Scan(&indexed); err != nil {
+			return fmt.Errorf("failed to count memory index: %w", err)
+		}
+		if keys == 0 && indexed > 0 {
+			if _, err := tx.Exec("DELETE FROM memories_fts"); err != nil {
+				return fmt.Errorf("failed to clear legacy memory index: %w", err)
+			}
+		}
+
+		rows, err := tx.Query(`
+			SELECT m.id FROM memories m
+			WHERE NOT EXISTS (
+				SELECT 1 FROM memory_fts_keys k
+				JOIN memories_fts f ON f.rowid = k.fts_key
+				WHERE k.memory_id = m.id
+			)
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to query unindexed memories: %w", err)
+		}
+		var ids []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan memory: %w", err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to query unindexed memories: %w", err)
+		}
+
+		for _, id := range ids {
+			if err := indexMemoryFTS(tx, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// memoryColumns 建表之后新增到memories的列，旧数据库打开时通过ALTER TABLE补齐
+var memoryColumns = []struct{ name, definition string }{
+	{"tenant", "TEXT NOT NULL DEFAULT ''"},
+	{"user_id", "TEXT NOT NULL DEFAULT ''"},
+	{"agent_id", "TEXT NOT NULL DEFAULT ''"},
+	{"session_id", "TEXT NOT NULL DEFAULT ''"},
+	{"access_count", "INTEGER NOT NULL DEFAULT 0"},
+	{"last_accessed", "TEXT"},
+}
+
+// memoryIndexes 依赖新增列的记忆索引（补齐列之后创建）
+const memoryIndexes = `
+CREATE INDEX IF NOT EXISTS idx_memories_owner ON memories(tenant, user_id, agent_id, session_id);
+CREATE INDEX IF NOT EXISTS idx_memories_session ON memories(session_id);
+`
+
+// migrateMemories 为旧数据库的memories表补齐新增列并创建索引
+// 新增会话列时，旧版本会话记忆的会话ID取自metadata.session_id
+func (s *Store) migrateMemories() error {
+	return s.withTx(func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT name FROM pragma_table_info('memories')")
+		if err != nil {
+			return fmt.Errorf("failed to query memory columns: %w", err)
+		}
+		existing := make(map[string]bool)
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan memory column: %w", err)
+			}
+			existing[name] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to query memory columns: %w", err)
+		}
+
+		for _, col := range memoryColumns {
+			if existing[col.name] {
+				continue
+			}
+			if _, err := tx.Exec("ALTER TABLE memories ADD COLUMN " + col.name + " " + col.definition); err != nil {
+				return fmt.Errorf("failed to add memory column %s: %w", col.name, err)
+			}
+		}
+
+		if !existing["session_id"] {
+			_, err := tx.Exec(`
+				UPDATE memories SET session_id = json_extract(metadata, '$.session_id')
+				WHERE type = 'conversation' AND json_type(metadata, '$.session_id') = 'text'
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to migrate memory sessions: %w", err)
+			}
+		}
+
+		if _, err := tx.Exec(memoryIndexes); err != nil {
+			return fmt.Errorf("failed to create memory indexes: %w", err)
+		}
+		return nil
+	})
+}
+
+// memorySelect 记忆查询的列（含命名空间和召回统计），与scanMemoryResults对应
+const memorySelect = `
+	SELECT m.id, m.type, m.content, m.metadata, m.tags, m.timestamp, m.expires_at, m.importance,
+		m.tenant, m.user_id, m.agent_id, m.session_id,
+		m.access_count, m.last_accessed
+	FROM memories m
+`
+
+// queryMemories 按条件查询记忆，conds之间为AND关系
+func (s *Store) queryMemories(conds []string, args []interface{}, scopes []Namespace, suffix string, suffixArgs ...interface{}) ([]MemoryResult, error) {
+	if clause, scopeArgs := scopeClause("m", scopes); clause != "" {
+		conds = append(conds, clause)
+		args = append(args, scopeArgs...)
+	}
+
+	query := memorySelect
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " " + suffix
+	args = append(args, suffixArgs...)
+
+	rows, err := s.readDB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return s.scanMemoryResults(rows)
+}
+
+// countMemories 按条件统计记忆数量
+func (s *Store) countMemories(conds []string, args []interface{}, scopes []Namespace) (int, error) {
+	if clause, scopeArgs := scopeClause("m", scopes); clause != "" {
+		conds = append(conds, clause)
+		args = append(args, scopeArgs...)
+	}
+
+	query := "SELECT COUNT(*) FROM memories m"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var count int
+	err := s.readDB.QueryRow(query, args...).Scan(&count)
+	return count, err
+}
+
+// deleteMemories 按条件删除记忆（全文索引由触发器删除）
+func (s *Store) deleteMemories(conds []string, args []interface{}, scopes []Namespace) (int, error) {
+	if clause, scopeArgs := scopeClause("m", scopes); clause != "" {
+		conds = append(conds, clause)
+		args = append(args, scopeArgs...)
+	}
+
+	result, err := s.exec(fmt.Sprintf(`
+		DELETE FROM memories WHERE id IN (
+			SELECT m.id FROM memories m WHERE %s
+		)
+	`, strings.Join(conds, " AND ")), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	count, _ := result.RowsAffected()
+	return int(count), nil
+}
+
 // scanMemoryResults 扫描记忆查询结果
 func (s *Store) scanMemoryResults(rows *sql.Rows) ([]MemoryResult, error) {
 	var results []MemoryResult
@@ -433,9 +880,13 @@ func (s *Store) scanMemoryResults(rows *sql.Rows) ([]MemoryResult, error) {
 		var id, memType, content, metadataJSON, tagsJSON, timestampStr string
 		var expiresAtStr sql.NullString
 		var importance float64
+		var ns Namespace
+		var accessCount int
+		var lastAccessed sql.NullString
 
 		err := rows.Scan(&id, &memType, &content, &metadataJSON, &tagsJSON,
-			&timestampStr, &expiresAtStr, &importance)
+			&timestampStr, &expiresAtStr, &importance, &ns.Tenant, &ns.User, &ns.Agent, &ns.Session,
+			&accessCount, &lastAccessed)
 		if err != nil {
 			continue
 		}
@@ -460,6 +911,7 @@ func (s *Store) scanMemoryResults(rows *sql.Rows) ([]MemoryResult, error) {
 
 		results = append(results, MemoryResult{
 			ID:         id,
+			Namespace:  ns,
 			Type:       memType,
 			Content:    content,
 			Metadata:   metadata,
@@ -467,6 +919,9 @@ func (s *Store) scanMemoryResults(rows *sql.Rows) ([]MemoryResult, error) {
 			Timestamp:  timestamp,
 			ExpiresAt:  expiresAt,
 			Importance: importance,
+
+			AccessCount:  accessCount,
+			LastAccessed: parseOptionalTime(lastAccessed),
 		})
 	}
 
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ArchivedMemory 被遗忘策略归档的记忆
+type ArchivedMemory struct {
+	MemoryResult
+	ArchivedAt time.Time
+}
+
+// TouchMemories 记录一次召回：召回次数加一，最近召回时间更新为at
+// 不存在的记忆ID被忽略
+func (s *Store) TouchMemories(ids []string, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := []interface{}{at.Format(time.RFC3339)}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	_, err := s.exec(`
+		UPDATE memories SET access_count = access_count + 1, last_accessed = ?
+		WHERE id IN (`+placeholders(len(ids))+`)
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to record memory access: %w", err)
+	}
+	return nil
+}
+
+// ArchiveMemories 将记忆移入归档表（保留嵌入和召回统计），返回归档数量
+func (s *Store) ArchiveMemories(ids []string, at time.Time) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	args := []interface{}{at.Format(time.RFC3339)}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	count := 0
+	err := s.withTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(`
+			INSERT OR REPLACE INTO archived_memories (id, tenant, user_id, agent_id, session_id, type, content,
+				metadata, tags, timestamp, expires_at, importance, embedding, access_count, last_accessed, archived_at)
+			SELECT m.id, m.tenant, m.user_id, m.agent_id, m.session_id,
+				m.type, m.content, m.metadata, m.tags, m.timestamp, m.expires_at, m.importance, m.embedding,
+				m.access_count, m.last_accessed, ?
+			FROM memories m
+			WHERE m.id IN (`+placeholders(len(ids))+`)
+		`, args...)
+		if err != nil {
+			return err
+		}
+		n, _ := result.RowsAffected()
+		count = int(n)
+
+		// 全文索引由触发器删除
+		_, err = tx.Exec("DELETE FROM memories WHERE id IN ("+placeholders(len(ids))+")", args[1:]...)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive memories: %w", err)
+	}
+	return count, nil
+}
+
+// ListArchivedMemories 按归档时间倒序列出范围内的归档记忆，同时返回总数
+// limit<=0表示不限
+func (s *Store) ListArchivedMemories(scopes []Namespace, limit, offset int) ([]ArchivedMemory, int, error) {
+	where := ""
+	clause, args := scopeClause("n", scopes)
+	if clause != "" {
+		where = " WHERE " + clause
+	}
+
+	var total int
+	if err := s.readDB.QueryRow("SELECT COUNT(*) FROM archived_memories n"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count archived memories: %w", err)
+	}
+
+	if limit <= 0 {
+		limit = -1 // SQLite中LIMIT -1表示不限
+	}
+	rows, err := s.readDB.Query(`
+		SELECT n.id, n.tenant, n.user_id, n.agent_id, n.session_id, n.type, n.content, n.metadata, n.tags,
+			n.timestamp, n.expires_at, n.importance, n.access_count, n.last_accessed, n.archived_at
+		FROM archived_memories n`+where+`
+		ORDER BY datetime(n.archived_at) DESC, n.rowid DESC LIMIT ? OFFSET ?
+	`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list archived memories: %w", err)
+	}
+	defer rows.Close()
+
+	var results []ArchivedMemory
+	for rows.Next() {
+		var r ArchivedMemory
+		var metadataJSON, tagsJSON, expiresAt, lastAccessed sql.NullString
+		var timestamp, archivedAt string
+
+		err := rows.Scan(&r.ID, &r.Namespace.Tenant, &r.Namespace.User, &r.Namespace.Agent, &r.Namespace.Session,
+			&r.Type, &r.Content, &metadataJSON, &tagsJSON, &timestamp, &expiresAt, &r.Importance,
+			&r.AccessCount, &lastAccessed, &archivedAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan archived memory: %w", err)
+		}
+
+		json.Unmarshal([]byte(metadataJSON.String), &r.Metadata)
+		json.Unmarshal([]byte(tagsJSON.String), &r.Tags)
+		r.Timestamp, _ = time.Parse(time.RFC3339, timestamp)
+		r.ExpiresAt = parseOptionalTime(expiresAt)
+		r.LastAccessed = parseOptionalTime(lastAccessed)
+		r.ArchivedAt, _ = time.Parse(time.RFC3339, archivedAt)
+		results = append(results, r)
+	}
+
+	return results, total, rows.Err()
+}
+
+// RestoreArchivedMemory 将归档记忆恢复为活跃记忆（保留原ID、命名空间和召回统计）
+func (s *Store) RestoreArchivedMemory(id string) error {
+	err := s.withTx(func(tx *sql.Tx) error {
+		var exists int
+		err := tx.QueryRow("SELECT COUNT(*) FROM archived_memories WHERE id = ?", id).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists == 0 {
+			return fmt.Errorf("archived memory not found: %s", id)
+		}
+
+		// 先删除同ID的活跃记忆：REPLACE删除旧行时不触发删除触发器
+		if _, err := tx.Exec("DELETE FROM memories WHERE id = ?", id); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO memories (id, tenant, user_id, agent_id, session_id, type, content, metadata, tags,
+				timestamp, expires_at, importance, embedding, access_count, last_accessed)
+			SELECT id, tenant, user_id, agent_id, session_id, type, content, metadata, tags,
+				timestamp, expires_at, importance, embedding, access_count, last_accessed
+			FROM archived_memories WHERE id = ?
+		`, id)
+		if err != nil {
+			return err
+		}
+		if err := indexMemoryFTS(tx, id); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("DELETE FROM archived_memories WHERE id = ?", id)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore archived memory: %w", err)
+	}
+	return nil
+}
+
+// parseOptionalTime 解析可为NULL的RFC3339时间列
+func parseOptionalTime(s sql.NullString) *time.Time {
+	if !s.Valid {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, s.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
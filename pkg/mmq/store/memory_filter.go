@@ -0,0 +1,259 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ExpiryState 记忆的过期状态
+type ExpiryState string
+
+const (
+	ExpiryAny     ExpiryState = ""        // 不限
+	ExpiryActive  ExpiryState = "active"  // 未过期（含未设置过期时间）
+	ExpiryExpired ExpiryState = "expired" // 已过期
+)
+
+// MemoryOrder 记忆列表的时间顺序
+type MemoryOrder int
+
+const (
+	NewestFirst MemoryOrder = iota // 按时间倒序（默认）
+	OldestFirst                    // 按时间正序，同一时间按写入顺序
+)
+
+// MemoryFilter 记忆过滤条件，各条件之间为AND关系，零值不过滤
+type MemoryFilter struct {
+	Types         []string               // 记忆类型（任一）
+	AnyTags       []string               // 至少包含其中一个标签
+	AllTags       []string               // 包含全部标签
+	Metadata      map[string]interface{} // metadata中对应键的值相等（按JSON值比较，nil匹配缺失的键）
+	Since         time.Time              // timestamp >= Since
+	Until         time.Time              // timestamp < Until
+	EndsSince     time.Time              // 结束时间 >= EndsSince：结束时间取metadata.end_time，没有时取timestamp（时间段重叠查询）
+	MinImportance float64                // importance >= MinImportance
+	MaxImportance float64                // importance <= MaxImportance，0表示不限
+	Expiry        ExpiryState
+	Namespace     *Namespace // 命名空间完全相同（与查询范围不同，空字段也必须为空），nil不限定
+}
+
+// validate 检查过滤条件是否有效
+func (f MemoryFilter) validate() error {
+	for key, value := range f.Metadata {
+		if strings.Contains(key, `"`) {
+			return fmt.Errorf("invalid metadata key: %s", key)
+		}
+		if _, err := json.Marshal(value); err != nil {
+			return fmt.Errorf("failed to marshal metadata filter %s: %w", key, err)
+		}
+	}
+	switch f.Expiry {
+	case ExpiryAny, ExpiryActive, ExpiryExpired:
+	default:
+		return fmt.Errorf("unknown expiry state: %s", f.Expiry)
+	}
+	return nil
+}
+
+// clause 生成WHERE条件（memories表别名为m）
+// 时间统一用datetime()比较，不受写入时时区偏移的影响
+func (f MemoryFilter) clause() ([]string, []interface{}, error) {
+	if err := f.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	var conds []string
+	var args []interface{}
+
+	if len(f.Types) > 0 {
+		conds = append(conds, "m.type IN ("+placeholders(len(f.Types))+")")
+		for _, t := range f.Types {
+			args = append(args, t)
+		}
+	}
+
+	if len(f.AnyTags) > 0 {
+		conds = append(conds, "EXISTS (SELECT 1 FROM json_each(m.tags) WHERE json_each.value IN ("+placeholders(len(f.AnyTags))+"))")
+		for _, tag := range f.AnyTags {
+			args = append(args, tag)
+		}
+	}
+	for _, tag := range f.AllTags {
+		conds = append(conds, "EXISTS (SELECT 1 FROM json_each(m.tags) WHERE json_each.value = ?)")
+		args = append(args, tag)
+	}
+
+	for key, value := range f.Metadata {
+		data, _ := json.Marshal(value)
+		conds = append(conds, "json_extract(m.metadata, ?) IS json_extract(?, '$')")
+		args = append(args, `$."`+key+`"`, string(data))
+	}
+
+	if !f.Since.IsZero() {
+		conds = append(conds, "datetime(m.timestamp) >= datetime(?)")
+		args = append(args, f.Since.Format(time.RFC3339))
+	}
+	if !f.Until.IsZero() {
+		conds = append(conds, "datetime(m.timestamp) < datetime(?)")
+		args = append(args, f.Until.Format(time.RFC3339))
+	}
+
+	if !f.EndsSince.IsZero() {
+		conds = append(conds, "datetime(COALESCE(json_extract(m.metadata, '$.end_time'), m.timestamp)) >= datetime(?)")
+		args = append(args, f.EndsSince.Format(time.RFC3339))
+	}
+
+	if f.MinImportance > 0 {
+		conds = append(conds, "m.importance >= ?")
+		args = append(args, f.MinImportance)
+	}
+	if f.MaxImportance > 0 {
+		conds = append(conds, "m.importance <= ?")
+		args = append(args, f.MaxImportance)
+	}
+
+	if f.Namespace != nil {
+		conds = append(conds, "m.tenant = ? AND m.user_id = ? AND m.agent_id = ? AND m.session_id = ?")
+		args = append(args, f.Namespace.Tenant, f.Namespace.User, f.Namespace.Agent, f.Namespace.Session)
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	switch f.Expiry {
+	case ExpiryActive:
+		conds = append(conds, "(m.expires_at IS NULL OR datetime(m.expires_at) >= datetime(?))")
+		args = append(args, now)
+	case ExpiryExpired:
+		conds = append(conds, "m.expires_at IS NOT NULL AND datetime(m.expires_at) < datetime(?)")
+		args = append(args, now)
+	}
+
+	return conds, args, nil
+}
+
+// Match 记忆是否满足过滤条件（与SQL条件语义一致）
+func (f MemoryFilter) Match(r MemoryResult) bool {
+	if len(f.Types) > 0 && !containsString(f.Types, r.Type) {
+		return false
+	}
+
+	if len(f.AnyTags) > 0 {
+		found := false
+		for _, tag := range f.AnyTags {
+			if containsString(r.Tags, tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, tag := range f.AllTags {
+		if !containsString(r.Tags, tag) {
+			return false
+		}
+	}
+
+	for key, value := range f.Metadata {
+		if !jsonEqual(r.Metadata[key], value) {
+			return false
+		}
+	}
+
+	if !f.Since.IsZero() && r.Timestamp.Before(f.Since.Truncate(time.Second)) {
+		return false
+	}
+	if !f.Until.IsZero() && !r.Timestamp.Before(f.Until.Truncate(time.Second)) {
+		return false
+	}
+
+	if !f.EndsSince.IsZero() && endOf(r).Before(f.EndsSince.Truncate(time.Second)) {
+		return false
+	}
+
+	if f.MinImportance > 0 && r.Importance < f.MinImportance {
+		return false
+	}
+	if f.MaxImportance > 0 && r.Importance > f.MaxImportance {
+		return false
+	}
+
+	if f.Namespace != nil && r.Namespace != *f.Namespace {
+		return false
+	}
+
+	now := time.Now().Truncate(time.Second)
+	switch f.Expiry {
+	case ExpiryActive:
+		return r.ExpiresAt == nil || !r.ExpiresAt.Before(now)
+	case ExpiryExpired:
+		return r.ExpiresAt != nil && r.ExpiresAt.Before(now)
+	}
+	return true
+}
+
+// endOf 记忆所描述时间段的结束时间（metadata.end_time，没有时为timestamp）
+func endOf(r MemoryResult) time.Time {
+	if end, ok := r.Metadata["end_time"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, end); err == nil {
+			return t
+		}
+	}
+	return r.Timestamp
+}
+
+// ListMemories 按过滤条件和时间顺序分页列出记忆，同时返回满足条件的总数
+func (s *Store) ListMemories(filter MemoryFilter, scopes []Namespace, order MemoryOrder, limit, offset int) ([]MemoryResult, int, error) {
+	conds, args, err := filter.clause()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.countMemories(append([]string(nil), conds...), append([]interface{}(nil), args...), scopes)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count memories: %w", err)
+	}
+
+	if limit <= 0 {
+		limit = -1 // SQLite中LIMIT -1表示不限
+	}
+	orderBy := "ORDER BY datetime(m.timestamp) DESC, m.rowid DESC"
+	if order == OldestFirst {
+		orderBy = "ORDER BY datetime(m.timestamp), m.rowid"
+	}
+	results, err := s.queryMemories(conds, args, scopes, orderBy+" LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list memories: %w", err)
+	}
+
+	return results, total, nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonEqual 按JSON编码比较两个值（数字1与1.0相等）
+func jsonEqual(a, b interface{}) bool {
+	da, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	db, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(da, db)
+}
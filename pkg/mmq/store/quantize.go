@@ -0,0 +1,382 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/crosszan/modu/pkg/mmq/internal/vectordb"
+)
+
+// 向量量化方式（按集合设置）
+const (
+	QuantizationNone   = ""       // float32原始向量
+	QuantizationInt8   = "int8"   // int8标量量化，替代float32存储（约1/4大小）
+	QuantizationBinary = "binary" // 额外保存符号位编码，用于汉明距离预筛选（保留float32）
+)
+
+const (
+	// quantRescoreFactor 量化向量预筛选时保留的候选数量为limit的倍数
+	quantRescoreFactor = 10
+	// quantMinRescore 预筛选至少保留的候选数量
+	quantMinRescore = 100
+)
+
+// QuantizeStats 量化转换统计
+type QuantizeStats struct {
+	Vectors     int   // 处理的向量数
+	Float32     int   // 保持float32的向量数
+	Int8        int   // int8量化的向量数
+	Binary      int   // 带二值编码的向量数
+	BytesBefore int64 // 转换前向量存储大小
+	BytesAfter  int64 // 转换后向量存储大小
+}
+
+// validQuantization 检查量化方式
+func validQuantization(mode string) error {
+	switch mode {
+	case QuantizationNone, QuantizationInt8, QuantizationBinary:
+		return nil
+	}
+	return fmt.Errorf("unknown quantization: %s", mode)
+}
+
+// storedVector 从存储读取的一个块向量（float32或量化编码）
+// 解码推迟到需要打分时，预筛选阶段只读取符号位编码
+type storedVector struct {
+	vec   []float32 // 已解码的向量（内存后端）
+	blob  []byte    // float32 BLOB，int8模式下为空
+	bits  []byte    // 符号位编码，未量化时为空
+	int8  []byte    // int8编码
+	scale float64
+}
+
+// float32s 解码为float32（int8模式下反量化）
+func (v storedVector) float32s() []float32 {
+	if v.vec != nil {
+		return v.vec
+	}
+	if len(v.blob) > 0 {
+		return blobToFloat32(v.blob)
+	}
+	if len(v.int8) > 0 {
+		return vectordb.DequantizeInt8(v.int8, float32(v.scale))
+	}
+	return nil
+}
+
+// size 向量占用的存储字节数
+func (v storedVector) size() int64 {
+	n := int64(len(v.blob) + len(v.bits) + len(v.int8))
+	if v.vec != nil {
+		n += int64(len(v.vec) * 4)
+	}
+	return n
+}
+
+// rescoreLimit 量化向量预筛选保留的候选数量
+func rescoreLimit(limit int) int {
+	if n := limit * quantRescoreFactor; n > quantMinRescore {
+		return n
+	}
+	return quantMinRescore
+}
+
+// selectForRescore 返回需要精确打分的向量下标（保持原顺序）
+// 未量化的向量全部保留；带符号位编码的向量按与查询的汉明距离只保留最近的keep个
+func selectForRescore(query []float32, vectors []storedVector, keep int) []int {
+	var selected []int
+	var coded []int
+	var codes [][]byte
+	for i, v := range vectors {
+		if len(v.bits) == 0 {
+			selected = append(selected, i)
+			continue
+		}
+		coded = append(coded, i)
+		codes = append(codes, v.bits)
+	}
+	if len(coded) == 0 {
+		return selected
+	}
+
+	keepSet := make(map[int]bool, keep)
+	for _, j := range vectordb.NearestByHamming(vectordb.BinaryCode(query), codes, keep) {
+		keepSet[coded[j]] = true
+	}
+
+	selected = selected[:0:0]
+	for i, v := range vectors {
+		if len(v.bits) == 0 || keepSet[i] {
+			selected = append(selected, i)
+		}
+	}
+	return selected
+}
+
+// targetQuantization 内容的量化方式：所有引用它的集合都是int8时才丢弃float32，
+// 任一集合启用量化时保存符号位编码
+func targetQuantization(collections []string, modes map[string]string) string {
+	if len(collections) == 0 {
+		return QuantizationNone
+	}
+
+	allInt8 := true
+	anyQuantized := false
+	for _, c := range collections {
+		mode := modes[c]
+		if mode != QuantizationInt8 {
+			allInt8 = false
+		}
+		if mode != QuantizationNone {
+			anyQuantized = true
+		}
+	}
+
+	switch {
+	case allInt8:
+		return QuantizationInt8
+	case anyQuantized:
+		return QuantizationBinary
+	default:
+		return QuantizationNone
+	}
+}
+
+// SetCollectionQuantization 设置集合的向量量化方式（mode为空时恢复float32）
+// 之后写入的向量按设置量化；已有向量需调用QuantizeEmbeddings转换
+func (s *Store) SetCollectionQuantization(collection, mode string) error {
+	if err := validQuantization(mode); err != nil {
+		return err
+	}
+
+	if mode == QuantizationNone {
+		if _, err := s.exec("DELETE FROM collection_quantization WHERE collection = ?", collection); err != nil {
+			return fmt.Errorf("failed to clear quantization: %w", err)
+		}
+		return nil
+	}
+
+	exists, err := s.CollectionExists(collection)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("collection '%s' not found", collection)
+	}
+
+	_, err = s.exec(`
+		INSERT INTO collection_quantization (collection, mode) VALUES (?, ?)
+		ON CONFLICT(collection) DO UPDATE SET mode = excluded.mode
+	`, collection, mode)
+	if err != nil {
+		return fmt.Errorf("failed to set quantization: %w", err)
+	}
+
+	return nil
+}
+
+// GetCollectionQuantization 获取集合的向量量化方式（未设置返回空字符串）
+func (s *Store) GetCollectionQuantization(collection string) (string, error) {
+	var mode string
+	err := s.readDB.QueryRow("SELECT mode FROM collection_quantization WHERE collection = ?", collection).Scan(&mode)
+	if err == sql.ErrNoRows {
+		return QuantizationNone, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get quantization: %w", err)
+	}
+
+	return mode, nil
+}
+
+// QuantizeEmbeddings 按集合设置转换已有向量
+// 内容被多个集合共享时，只有全部集合都是int8才丢弃float32；
+// 取消量化的内容从int8编码还原float32（有精度损失）。
+func (s *Store) QuantizeEmbeddings() (QuantizeStats, error) {
+	var stats QuantizeStats
+
+	modes, err := s.quantizationModes()
+	if err != nil {
+		return stats, err
+	}
+	collections, err := s.activeHashCollections()
+	if err != nil {
+		return stats, err
+	}
+
+	rows, err := s.readDB.Query(`
+		SELECT cv.hash, cv.seq, cv.embedding, q.bits, q.int8, COALESCE(q.scale, 0)
+		FROM content_vectors cv
+		LEFT JOIN vector_codes q ON q.hash = cv.hash AND q.seq = cv.seq
+	`)
+	if err != nil {
+		return stats, fmt.Errorf("failed to query vectors: %w", err)
+	}
+
+	type row struct {
+		hash string
+		seq  int
+		v    storedVector
+	}
+	var vectors []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.hash, &r.seq, &r.v.blob, &r.v.bits, &r.v.int8, &r.v.scale); err != nil {
+			rows.Close()
+			return stats, fmt.Errorf("failed to scan vector: %w", err)
+		}
+		vectors = append(vectors, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	err = s.withTx(func(tx *sql.Tx) error {
+		for _, r := range vectors {
+			stats.Vectors++
+			stats.BytesBefore += r.v.size()
+
+			target := targetQuantization(collections[r.hash], modes)
+			converted, err := convertVector(tx, r.hash, r.seq, r.v, target)
+			if err != nil {
+				return err
+			}
+			stats.BytesAfter += converted.size()
+
+			switch target {
+			case QuantizationInt8:
+				stats.Int8++
+			case QuantizationBinary:
+				stats.Binary++
+			default:
+				stats.Float32++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to quantize embeddings: %w", err)
+	}
+
+	return stats, nil
+}
+
+// convertVector 将一个块向量转换为目标量化方式并写回，已是目标形式时不写入
+func convertVector(tx *sql.Tx, hash string, seq int, v storedVector, target string) (storedVector, error) {
+	vec := v.float32s()
+	if len(vec) == 0 {
+		return v, nil
+	}
+
+	var out storedVector
+	switch target {
+	case QuantizationInt8:
+		if len(v.blob) == 0 && len(v.int8) > 0 {
+			return v, nil
+		}
+		out.int8, out.scale = quantizeInt8(vec)
+		out.bits = vectordb.BinaryCode(vec)
+	case QuantizationBinary:
+		if len(v.blob) > 0 && len(v.bits) > 0 && len(v.int8) == 0 {
+			return v, nil
+		}
+		out.blob = float32ToBlob(vec)
+		out.bits = vectordb.BinaryCode(vec)
+	default:
+		if len(v.blob) > 0 && len(v.bits) == 0 {
+			return v, nil
+		}
+		out.blob = float32ToBlob(vec)
+	}
+
+	if _, err := tx.Exec("UPDATE content_vectors SET embedding = ? WHERE hash = ? AND seq = ?", out.blob, hash, seq); err != nil {
+		return v, err
+	}
+	if _, err := tx.Exec("DELETE FROM vector_codes WHERE hash = ? AND seq = ?", hash, seq); err != nil {
+		return v, err
+	}
+	if len(out.bits) > 0 {
+		if _, err := tx.Exec(`
+			INSERT INTO vector_codes (hash, seq, bits, int8, scale) VALUES (?, ?, ?, ?, ?)
+		`, hash, seq, out.bits, out.int8, out.scale); err != nil {
+			return v, err
+		}
+	}
+
+	return out, nil
+}
+
+// quantizeInt8 int8量化，缩放系数以float64保存
+func quantizeInt8(vec []float32) ([]byte, float64) {
+	codes, scale := vectordb.QuantizeInt8(vec)
+	return codes, float64(scale)
+}
+
+// quantizationModes 所有集合的量化方式
+func (s *Store) quantizationModes() (map[string]string, error) {
+	rows, err := s.readDB.Query("SELECT collection, mode FROM collection_quantization")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quantization: %w", err)
+	}
+	defer rows.Close()
+
+	modes := make(map[string]string)
+	for rows.Next() {
+		var collection, mode string
+		if err := rows.Scan(&collection, &mode); err != nil {
+			return nil, fmt.Errorf("failed to scan quantization: %w", err)
+		}
+		modes[collection] = mode
+	}
+	return modes, rows.Err()
+}
+
+// hashQuantization 内容应采用的量化方式（按引用它的有效文档所在集合的设置）
+func hashQuantization(tx *sql.Tx, hash string) (string, error) {
+	rows, err := tx.Query(`
+		SELECT DISTINCT d.collection, COALESCE(q.mode, '')
+		FROM documents d
+		LEFT JOIN collection_quantization q ON q.collection = d.collection
+		WHERE d.hash = ? AND d.active = 1
+	`, hash)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var collections []string
+	modes := make(map[string]string)
+	for rows.Next() {
+		var collection, mode string
+		if err := rows.Scan(&collection, &mode); err != nil {
+			return "", err
+		}
+		collections = append(collections, collection)
+		modes[collection] = mode
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	return targetQuantization(collections, modes), nil
+}
+
+// activeHashCollections 内容哈希 -> 引用它的集合（仅有效文档）
+func (s *Store) activeHashCollections() (map[string][]string, error) {
+	rows, err := s.readDB.Query("SELECT DISTINCT hash, collection FROM documents WHERE active = 1")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query documents: %w", err)
+	}
+	defer rows.Close()
+
+	collections := make(map[string][]string)
+	for rows.Next() {
+		var hash, collection string
+		if err := rows.Scan(&hash, &collection); err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+		collections[hash] = append(collections[hash], collection)
+	}
+	return collections, rows.Err()
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QueryLogEntry 查询日志条目
+type QueryLogEntry struct {
+	ID         string
+	Timestamp  time.Time
+	Kind       string // 调用类型：search、vsearch、hybrid、retrieve
+	Query      string
+	Collection string
+	Strategy   string // 实际使用的检索策略
+	Latency    time.Duration
+	Results    []QueryLogResult
+}
+
+// QueryLogResult 查询返回的一条结果
+type QueryLogResult struct {
+	ID    string  `json:"id,omitempty"` // 内容哈希
+	Path  string  `json:"path"`         // collection/path
+	Score float64 `json:"score"`
+}
+
+// LogQuery 记录一次查询，返回日志ID（entry.ID为空时自动生成）
+func (s *Store) LogQuery(entry QueryLogEntry) (string, error) {
+	if entry.ID == "" {
+		entry.ID = uuid.New().String()
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	results, err := json.Marshal(entry.Results)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal results: %w", err)
+	}
+
+	_, err = s.exec(`
+		INSERT INTO query_log (id, timestamp, kind, query, collection, strategy, latency_us, result_count, results)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.ID, entry.Timestamp.UTC().Format(time.RFC3339), entry.Kind, entry.Query,
+		entry.Collection, entry.Strategy, entry.Latency.Microseconds(), len(entry.Results), string(results))
+	if err != nil {
+		return "", fmt.Errorf("failed to log query: %w", err)
+	}
+
+	return entry.ID, nil
+}
+
+// GetQueryLogEntry 按ID获取查询日志
+func (s *Store) GetQueryLogEntry(id string) (*QueryLogEntry, error) {
+	rows, err := s.readDB.Query(`
+		SELECT id, timestamp, kind, query, collection, strategy, latency_us, results
+		FROM query_log
+		WHERE id = ?
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get query log: %w", err)
+	}
+	defer rows.Close()
+
+	entries, err := scanQueryLog(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("query not found: %s", id)
+	}
+
+	return &entries[0], nil
+}
+
+// ListQueryLog 列出since之后（含）的查询日志，按时间升序
+func (s *Store) ListQueryLog(since time.Time) ([]QueryLogEntry, error) {
+	rows, err := s.readDB.Query(`
+		SELECT id, timestamp, kind, query, collection, strategy, latency_us, results
+		FROM query_log
+		WHERE timestamp >= ?
+		ORDER BY timestamp ASC, rowid ASC
+	`, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query log: %w", err)
+	}
+	defer rows.Close()
+
+	return scanQueryLog(rows)
+}
+
+// PruneQueryLog 删除before之前的查询日志，返回删除数量
+func (s *Store) PruneQueryLog(before time.Time) (int, error) {
+	result, err := s.exec("DELETE FROM query_log WHERE timestamp < ?", before.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune query log: %w", err)
+	}
+
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// scanQueryLog 扫描查询日志行
+func scanQueryLog(rows *sql.Rows) ([]QueryLogEntry, error) {
+	var entries []QueryLogEntry
+	for rows.Next() {
+		var entry QueryLogEntry
+		var timestamp string
+		var latencyUs int64
+		var results sql.NullString
+
+		err := rows.Scan(&entry.ID, &timestamp, &entry.Kind, &entry.Query,
+			&entry.Collection, &entry.Strategy, &latencyUs, &results)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan query log: %w", err)
+		}
+
+		entry.Timestamp, _ = time.Parse(time.RFC3339, timestamp)
+		entry.Latency = time.Duration(latencyUs) * time.Microsecond
+		if results.Valid && results.String != "" {
+			if err := json.Unmarshal([]byte(results.String), &entry.Results); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal results: %w", err)
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,223 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// ErrRankingProfileNotFound 排序配置不存在
+var ErrRankingProfileNotFound = errors.New("ranking profile not found")
+
+// FieldWeights BM25字段权重，对应documents_fts的filepath、title、body列
+type FieldWeights struct {
+	Filepath float64 `json:"filepath"`
+	Title    float64 `json:"title"`
+	Body     float64 `json:"body"`
+}
+
+// DefaultFieldWeights 默认字段权重 filepath:title:body = 10:1:1
+func DefaultFieldWeights() FieldWeights {
+	return FieldWeights{Filepath: 10.0, Title: 1.0, Body: 1.0}
+}
+
+// IsZero 是否未设置（未设置时使用默认权重）
+func (w FieldWeights) IsZero() bool {
+	return w.Filepath == 0 && w.Title == 0 && w.Body == 0
+}
+
+// RankingProfile 排序配置
+// 零值字段表示沿用默认行为
+type RankingProfile struct {
+	Name             string             `json:"name"`
+	FieldWeights     FieldWeights       `json:"field_weights"`               // BM25字段权重
+	RRFWeights       []float64          `json:"rrf_weights,omitempty"`       // RRF权重 [fts, vector]
+	RRFK             int                `json:"rrf_k,omitempty"`             // RRF参数K
+	RecencyHalfLife  time.Duration      `json:"recency_half_life,omitempty"` // 按modified_at衰减的半衰期，0表示不启用
+	RecencyWeight    float64            `json:"recency_weight,omitempty"`    // 时间因子权重（启用时默认1.0）
+	CollectionBoosts map[string]float64 `json:"collection_boosts,omitempty"` // 集合分数乘数
+
+	PathContextWeight float64 `json:"path_context_weight,omitempty"` // 路径上下文链命中查询词的加权，0表示不启用
+
+	FeedbackWeight   float64       `json:"feedback_weight,omitempty"`    // 相关性反馈学习到的加权，0表示不启用
+	FeedbackHalfLife time.Duration `json:"feedback_half_life,omitempty"` // 反馈衰减半衰期（默认30天）
+}
+
+// ApplyRankingBoosts 按排序配置应用时间衰减和集合加权，并按新分数重新排序
+//
+// 时间因子：score * (1 + w*0.5^(age/halfLife)) / (1 + w)，
+// 刚修改的文档保持原分数，越旧的文档分数越趋近于 score/(1+w)。
+func ApplyRankingBoosts(results []SearchResult, profile *RankingProfile, now time.Time) []SearchResult {
+	if profile == nil || len(results) == 0 {
+		return results
+	}
+	if profile.RecencyHalfLife <= 0 && len(profile.CollectionBoosts) == 0 {
+		return results
+	}
+
+	weight := profile.RecencyWeight
+	if weight == 0 {
+		weight = 1.0
+	}
+
+	for i := range results {
+		r := &results[i]
+
+		if profile.RecencyHalfLife > 0 && !r.Timestamp.IsZero() {
+			age := now.Sub(r.Timestamp)
+			if age < 0 {
+				age = 0
+			}
+			decay := math.Pow(0.5, float64(age)/float64(profile.RecencyHalfLife))
+			r.Score = r.Score * (1 + weight*decay) / (1 + weight)
+		}
+
+		if boost, ok := profile.CollectionBoosts[r.Collection]; ok {
+			r.Score *= boost
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	return results
+}
+
+// SaveRankingProfile 保存排序配置（同名覆盖）
+func (s *Store) SaveRankingProfile(profile RankingProfile) error {
+	if profile.Name == "" {
+		return fmt.Errorf("ranking profile name is required")
+	}
+
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ranking profile: %w", err)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err = s.exec(`
+		INSERT INTO ranking_profiles (name, profile, created_at, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET profile = excluded.profile, updated_at = excluded.updated_at
+	`, profile.Name, string(data), now, now)
+	if err != nil {
+		return fmt.Errorf("failed to save ranking profile: %w", err)
+	}
+
+	return nil
+}
+
+// GetRankingProfile 获取排序配置
+func (s *Store) GetRankingProfile(name string) (*RankingProfile, error) {
+	var data string
+	err := s.readDB.QueryRow("SELECT profile FROM ranking_profiles WHERE name = ?", name).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: '%s'", ErrRankingProfileNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ranking profile: %w", err)
+	}
+
+	var profile RankingProfile
+	if err := json.Unmarshal([]byte(data), &profile); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ranking profile: %w", err)
+	}
+
+	return &profile, nil
+}
+
+// ListRankingProfiles 列出所有排序配置（按名称排序）
+func (s *Store) ListRankingProfiles() ([]RankingProfile, error) {
+	rows, err := s.readDB.Query("SELECT profile FROM ranking_profiles ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ranking profiles: %w", err)
+	}
+	defer rows.Close()
+
+	var profiles []RankingProfile
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan ranking profile: %w", err)
+		}
+
+		var profile RankingProfile
+		if err := json.Unmarshal([]byte(data), &profile); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal ranking profile: %w", err)
+		}
+		profiles = append(profiles, profile)
+	}
+
+	return profiles, rows.Err()
+}
+
+// DeleteRankingProfile 删除排序配置，同时解除集合绑定
+func (s *Store) DeleteRankingProfile(name string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec("DELETE FROM ranking_profiles WHERE name = ?", name)
+		if err != nil {
+			return fmt.Errorf("failed to delete ranking profile: %w", err)
+		}
+
+		affected, _ := result.RowsAffected()
+		if affected == 0 {
+			return fmt.Errorf("%w: '%s'", ErrRankingProfileNotFound, name)
+		}
+
+		if _, err := tx.Exec("DELETE FROM collection_profiles WHERE profile = ?", name); err != nil {
+			return fmt.Errorf("failed to unbind ranking profile: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// SetCollectionProfile 为集合绑定排序配置（profile为空时解除绑定）
+func (s *Store) SetCollectionProfile(collection, profile string) error {
+	if profile == "" {
+		if _, err := s.exec("DELETE FROM collection_profiles WHERE collection = ?", collection); err != nil {
+			return fmt.Errorf("failed to unbind ranking profile: %w", err)
+		}
+		return nil
+	}
+
+	exists, err := s.CollectionExists(collection)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("collection '%s' not found", collection)
+	}
+	if _, err := s.GetRankingProfile(profile); err != nil {
+		return err
+	}
+
+	_, err = s.exec(`
+		INSERT INTO collection_profiles (collection, profile) VALUES (?, ?)
+		ON CONFLICT(collection) DO UPDATE SET profile = excluded.profile
+	`, collection, profile)
+	if err != nil {
+		return fmt.Errorf("failed to bind ranking profile: %w", err)
+	}
+
+	return nil
+}
+
+// GetCollectionProfile 获取集合绑定的排序配置名称（未绑定返回空字符串）
+func (s *Store) GetCollectionProfile(collection string) (string, error) {
+	var profile string
+	err := s.readDB.QueryRow("SELECT profile FROM collection_profiles WHERE collection = ?", collection).Scan(&profile)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get collection profile: %w", err)
+	}
+
+	return profile, nil
+}
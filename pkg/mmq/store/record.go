@@ -0,0 +1,203 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// StructuredRecord 结构化数据中的一行（CSV行或JSON记录）
+// 每行作为独立文档索引，文档路径为 <文件路径>#<行号>
+type StructuredRecord struct {
+	Collection string
+	Path       string            // 行文档路径，如 faq.csv#3
+	SourcePath string            // 所在文件（集合内相对路径）
+	Row        int               // 文件中的行号（从1开始，CSV/TSV的表头为第1行；JSON数组为元素序号）
+	Fields     map[string]string // 字段值
+}
+
+// RecordPath 行文档路径
+func RecordPath(sourcePath string, row int) string {
+	return fmt.Sprintf("%s#%d", sourcePath, row)
+}
+
+// normalizeFieldValue 字段过滤时忽略大小写和首尾空白
+func normalizeFieldValue(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// SaveRecords 替换文件的全部行记录
+// 同时停用该文件不再存在的行文档，以及此前按整个文件索引的文档
+func (s *Store) SaveRecords(collection, sourcePath string, records []StructuredRecord) error {
+	keep := make(map[string]bool, len(records))
+	for _, r := range records {
+		keep[r.Path] = true
+	}
+
+	err := s.withTx(func(tx *sql.Tx) error {
+		// 停用多余的行文档
+		rows, err := tx.Query("SELECT path FROM records WHERE collection = ? AND source_path = ?", collection, sourcePath)
+		if err != nil {
+			return err
+		}
+		var stale []string
+		for rows.Next() {
+			var path string
+			if err := rows.Scan(&path); err != nil {
+				rows.Close()
+				return err
+			}
+			if !keep[path] {
+				stale = append(stale, path)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		stale = append(stale, sourcePath)
+
+		for _, path := range stale {
+			if _, err := tx.Exec("UPDATE documents SET active = 0 WHERE collection = ? AND path = ?", collection, path); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec(`
+			DELETE FROM record_fields WHERE collection = ? AND path IN
+				(SELECT path FROM records WHERE collection = ? AND source_path = ?)
+		`, collection, collection, sourcePath); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM records WHERE collection = ? AND source_path = ?", collection, sourcePath); err != nil {
+			return err
+		}
+
+		for _, r := range records {
+			fields, err := json.Marshal(r.Fields)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`
+				INSERT INTO records (collection, path, source_path, row_index, fields)
+				VALUES (?, ?, ?, ?, ?)
+			`, collection, r.Path, sourcePath, r.Row, string(fields)); err != nil {
+				return err
+			}
+			for field, value := range r.Fields {
+				if _, err := tx.Exec(`
+					INSERT INTO record_fields (collection, path, field, value)
+					VALUES (?, ?, ?, ?)
+				`, collection, r.Path, field, normalizeFieldValue(value)); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save records: %w", err)
+	}
+
+	return nil
+}
+
+// GetRecord 获取行文档对应的记录，不是行文档时返回nil
+func (s *Store) GetRecord(collection, path string) (*StructuredRecord, error) {
+	rows, err := s.readDB.Query(`
+		SELECT r.collection, r.path, r.source_path, r.row_index, r.fields
+		FROM records r
+		JOIN documents d ON d.collection = r.collection AND d.path = r.path AND d.active = 1
+		WHERE r.collection = ? AND r.path = ?
+	`, collection, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get record: %w", err)
+	}
+	defer rows.Close()
+
+	records, err := scanRecords(rows)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+
+	return &records[0], nil
+}
+
+// ListRecords 列出文件的全部行记录，按行号排序
+func (s *Store) ListRecords(collection, sourcePath string) ([]StructuredRecord, error) {
+	rows, err := s.readDB.Query(`
+		SELECT r.collection, r.path, r.source_path, r.row_index, r.fields
+		FROM records r
+		JOIN documents d ON d.collection = r.collection AND d.path = r.path AND d.active = 1
+		WHERE r.collection = ? AND r.source_path = ?
+		ORDER BY r.row_index
+	`, collection, sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list records: %w", err)
+	}
+	defer rows.Close()
+
+	return scanRecords(rows)
+}
+
+// FindRecords 查找所有字段都匹配的行记录（值忽略大小写和首尾空白）
+// collection为空时搜索全部集合，结果按集合、文件和行号排序
+func (s *Store) FindRecords(collection string, filters map[string]string) ([]StructuredRecord, error) {
+	query := `
+		SELECT r.collection, r.path, r.source_path, r.row_index, r.fields
+		FROM records r
+		JOIN documents d ON d.collection = r.collection AND d.path = r.path AND d.active = 1
+		WHERE 1 = 1`
+	var args []interface{}
+
+	if collection != "" {
+		query += " AND r.collection = ?"
+		args = append(args, collection)
+	}
+	for _, field := range sortedFilterFields(filters) {
+		query += ` AND EXISTS (
+			SELECT 1 FROM record_fields f
+			WHERE f.collection = r.collection AND f.path = r.path AND f.field = ? AND f.value = ?)`
+		args = append(args, field, normalizeFieldValue(filters[field]))
+	}
+	query += " ORDER BY r.collection, r.source_path, r.row_index"
+
+	rows, err := s.readDB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find records: %w", err)
+	}
+	defer rows.Close()
+
+	return scanRecords(rows)
+}
+
+// scanRecords 扫描行记录
+func scanRecords(rows *sql.Rows) ([]StructuredRecord, error) {
+	var records []StructuredRecord
+	for rows.Next() {
+		var r StructuredRecord
+		var fields string
+		if err := rows.Scan(&r.Collection, &r.Path, &r.SourcePath, &r.Row, &fields); err != nil {
+			return nil, fmt.Errorf("failed to scan record: %w", err)
+		}
+		if err := json.Unmarshal([]byte(fields), &r.Fields); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal record fields: %w", err)
+		}
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
+
+// sortedFilterFields 过滤字段按名称排序，保证查询稳定
+func sortedFilterFields(filters map[string]string) []string {
+	fields := make([]string, 0, len(filters))
+	for field := range filters {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
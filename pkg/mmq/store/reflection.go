@@ -0,0 +1,63 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ReflectionCursor 反思进度：命名空间内已参考到的最新记忆
+// 记忆时间精度为秒，同一秒内已参考的记忆单独记录，下次从该秒开始并跳过这些记忆
+type ReflectionCursor struct {
+	Namespace  Namespace
+	Through    time.Time // 已参考的最新记忆时间
+	ThroughIDs []string  // 时间等于Through的已参考记忆ID
+	UpdatedAt  time.Time
+}
+
+// SaveReflectionCursor 保存命名空间的反思进度（已存在则覆盖）
+func (s *Store) SaveReflectionCursor(cursor ReflectionCursor) error {
+	ids := cursor.ThroughIDs
+	if ids == nil {
+		ids = []string{}
+	}
+	idsJSON, err := json.Marshal(ids)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reflection cursor: %w", err)
+	}
+
+	ns := cursor.Namespace
+	_, err = s.exec(`
+		INSERT OR REPLACE INTO reflection_cursors (tenant, user_id, agent_id, session_id, through, through_ids, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, ns.Tenant, ns.User, ns.Agent, ns.Session, cursor.Through.Format(time.RFC3339), string(idsJSON),
+		time.Now().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to save reflection cursor: %w", err)
+	}
+	return nil
+}
+
+// GetReflectionCursor 获取命名空间的反思进度，不存在时返回nil
+func (s *Store) GetReflectionCursor(ns Namespace) (*ReflectionCursor, error) {
+	cursor := &ReflectionCursor{Namespace: ns}
+	var through, idsJSON, updatedAt string
+
+	err := s.readDB.QueryRow(`
+		SELECT through, through_ids, updated_at
+		FROM reflection_cursors
+		WHERE tenant = ? AND user_id = ? AND agent_id = ? AND session_id = ?
+	`, ns.Tenant, ns.User, ns.Agent, ns.Session).Scan(&through, &idsJSON, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reflection cursor: %w", err)
+	}
+
+	cursor.Through, _ = time.Parse(time.RFC3339, through)
+	cursor.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+	_ = json.Unmarshal([]byte(idsJSON), &cursor.ThroughIDs)
+	return cursor, nil
+}
@@ -11,13 +11,23 @@ import (
 	"github.com/crosszan/modu/pkg/mmq/internal/vectordb"
 )
 
-// SearchFTS 使用BM25全文搜索
-func (s *Store) SearchFTS(query string, limit int, collectionFilter string) ([]SearchResult, error) {
-	// 构建FTS查询
-	ftsQuery := buildFTS5Query(query)
+// SearchFTS 使用BM25全文搜索（默认字段权重）
+func (s *Store) SearchFTS(query string, limit int, filter DocumentFilter) ([]SearchResult, error) {
+	return s.SearchFTSWeighted(query, limit, filter, DefaultFieldWeights())
+}
+
+// SearchFTSWeighted 使用指定字段权重的BM25全文搜索
+func (s *Store) SearchFTSWeighted(query string, limit int, filter DocumentFilter, weights FieldWeights) ([]SearchResult, error) {
+	if weights.IsZero() {
+		weights = DefaultFieldWeights()
+	}
+
+	// 构建FTS查询（CJK文字切分为双字词，并路由到对应的索引）
+	ftsQuery := buildFTS5Query(segmentCJK(query))
 	if ftsQuery == "" {
 		return nil, nil
 	}
+	table := ftsTable(query)
 
 	// 构建SQL查询
 	sql := `
@@ -30,25 +40,26 @@ func (s *Store) SearchFTS(query string, limit int, collectionFilter string) ([]S
 			d.path,
 			c.doc as body,
 			d.modified_at,
-			bm25(documents_fts, 10.0, 1.0, 1.0) as bm25_score
-		FROM documents_fts f
+			COALESCE(l.language, ''),
+			bm25(` + table + `, ?, ?, ?) as bm25_score
+		FROM ` + table + ` f
 		JOIN documents d ON d.id = f.rowid
 		JOIN content c ON c.hash = d.hash
-		WHERE documents_fts MATCH ? AND d.active = 1
+		LEFT JOIN content_languages l ON l.hash = d.hash
+		WHERE ` + table + ` MATCH ? AND d.active = 1
 	`
 
-	args := []interface{}{ftsQuery}
+	args := []interface{}{weights.Filepath, weights.Title, weights.Body, ftsQuery}
 
-	if collectionFilter != "" {
-		sql += " AND d.collection = ?"
-		args = append(args, collectionFilter)
-	}
+	where, whereArgs := filter.where()
+	sql += where
+	args = append(args, whereArgs...)
 
 	sql += " ORDER BY bm25_score ASC LIMIT ?"
 	args = append(args, limit)
 
 	// 执行查询
-	rows, err := s.db.Query(sql, args...)
+	rows, err := s.readDB.Query(sql, args...)
 	if err != nil {
 		return nil, fmt.Errorf("FTS query failed: %w", err)
 	}
@@ -64,7 +75,7 @@ func (s *Store) SearchFTS(query string, limit int, collectionFilter string) ([]S
 		err := rows.Scan(
 			&result.ID, &result.Path, &result.Title, &result.ID,
 			&result.Collection, &result.Path, &result.Content,
-			&modifiedAt, &bm25Score,
+			&modifiedAt, &result.Language, &bm25Score,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan result: %w", err)
@@ -81,66 +92,123 @@ func (s *Store) SearchFTS(query string, limit int, collectionFilter string) ([]S
 
 		results = append(results, result)
 	}
+	rows.Close()
 
-	return results, nil
+	// 文档摘要同样参与全文检索
+	summaryHits, err := s.searchSummaries(ftsQuery, query, limit, filter, weights.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeSummaryHits(results, summaryHits, limit), nil
 }
 
 // SearchVector 使用向量相似搜索
-// 注意：这个实现会加载所有向量到内存，适合中小规模数据集（<10000文档）
-func (s *Store) SearchVector(query string, embedding []float32, limit int, collectionFilter string) ([]SearchResult, error) {
-	// 获取所有向量
+// 注意：这个实现会扫描所有向量，适合中小规模数据集（<10000文档）
+// 量化向量先按汉明距离预筛选，只为入选的候选读取并解码向量；正文只为返回的结果读取
+func (s *Store) SearchVector(query string, embedding []float32, limit int, filter DocumentFilter) ([]SearchResult, error) {
+	best, docs, err := s.nearestDocuments(embedding, limit, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// 转换为SearchResult
+	results := make([]SearchResult, 0, len(best))
+	for _, c := range best {
+		doc, ok := docs[c.collection+"/"+c.path]
+		if !ok {
+			continue // 读取期间文档已被删除
+		}
+
+		result := doc
+		result.ID = c.hash
+		result.Score = 1.0 - c.distance // 余弦相似度
+		result.Source = "vector"
+		result.Snippet = extractSnippet(doc.Content, query, 300)
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// vectorCandidate 向量搜索的候选块
+type vectorCandidate struct {
+	hash       string
+	seq        int
+	distance   float64
+	collection string
+	path       string
+}
+
+// nearestDocuments 两阶段向量检索：先扫描块的符号位编码预筛选，再为入选的块读取向量精确打分；
+// 每个文档（集合/路径）保留最佳匹配块，按距离返回前limit个，并只为这些结果读取标题和正文
+func (s *Store) nearestDocuments(embedding []float32, limit int, filter DocumentFilter) ([]vectorCandidate, map[string]SearchResult, error) {
+	// 1. 读取块的符号位编码；没有编码的块总要精确打分，直接读取float32向量
 	sql := `
-		SELECT cv.hash, cv.seq, cv.embedding, d.collection, d.path, d.title, c.doc, d.modified_at
+		SELECT cv.hash, cv.seq, CASE WHEN q.bits IS NULL THEN cv.embedding END, q.bits,
+			d.collection, d.path
 		FROM content_vectors cv
 		JOIN documents d ON d.hash = cv.hash
-		JOIN content c ON c.hash = cv.hash
+		LEFT JOIN vector_codes q ON q.hash = cv.hash AND q.seq = cv.seq
 		WHERE d.active = 1
 	`
 
-	args := []interface{}{}
-	if collectionFilter != "" {
-		sql += " AND d.collection = ?"
-		args = append(args, collectionFilter)
-	}
+	where, args := filter.where()
+	sql += where
 
-	rows, err := s.db.Query(sql, args...)
+	rows, err := s.readDB.Query(sql, args...)
 	if err != nil {
-		return nil, fmt.Errorf("vector query failed: %w", err)
+		return nil, nil, fmt.Errorf("vector query failed: %w", err)
 	}
 	defer rows.Close()
 
-	// 计算所有向量的距离
-	type candidate struct {
-		hash       string
-		seq        int
-		distance   float64
-		collection string
-		path       string
-		title      string
-		body       string
-		modifiedAt string
-	}
-
-	var candidates []candidate
+	var all []vectorCandidate
+	var vectors []storedVector
 
 	for rows.Next() {
-		var c candidate
-		var embeddingBlob []byte
+		var c vectorCandidate
+		var v storedVector
 
-		err := rows.Scan(&c.hash, &c.seq, &embeddingBlob, &c.collection, &c.path, &c.title, &c.body, &c.modifiedAt)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan vector: %w", err)
+		if err := rows.Scan(&c.hash, &c.seq, &v.blob, &v.bits, &c.collection, &c.path); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan vector: %w", err)
 		}
 
-		// 将BLOB转换为float32切片
-		vecEmbedding := blobToFloat32(embeddingBlob)
+		all = append(all, c)
+		vectors = append(vectors, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to scan vector: %w", err)
+	}
+	rows.Close()
+
+	// 2. 预筛选后为入选的量化块读取向量，再计算余弦距离
+	selected := selectForRescore(embedding, vectors, rescoreLimit(limit))
+	var keys []vectorKey
+	for _, i := range selected {
+		if len(vectors[i].bits) > 0 {
+			keys = append(keys, vectorKey{all[i].hash, all[i].seq})
+		}
+	}
+	coded, err := s.loadCodedVectors(keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var candidates []vectorCandidate
+	for _, i := range selected {
+		v := vectors[i]
+		if len(v.bits) > 0 {
+			v = coded[vectorKey{all[i].hash, all[i].seq}]
+		}
 
 		// 计算余弦距离
-		dist, err := vectordb.CosineDist(embedding, vecEmbedding)
+		dist, err := vectordb.CosineDist(embedding, v.float32s())
 		if err != nil {
 			continue // 跳过维度不匹配的向量
 		}
 
+		c := all[i]
 		c.distance = dist
 		candidates = append(candidates, c)
 	}
@@ -150,47 +218,117 @@ func (s *Store) SearchVector(query string, embedding []float32, limit int, colle
 		return candidates[i].distance < candidates[j].distance
 	})
 
-	// 去重：同一文档保留最佳匹配块
-	seen := make(map[string]*candidate)
-	for i := range candidates {
-		c := &candidates[i]
-		if existing, ok := seen[c.hash]; !ok || c.distance < existing.distance {
-			seen[c.hash] = c
+	// 去重：同一文档（集合/路径）保留最佳匹配块（候选已按距离排序，首次出现即最佳）
+	seen := make(map[string]bool)
+	var best []vectorCandidate
+	for _, c := range candidates {
+		key := c.collection + "/" + c.path
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		best = append(best, c)
+		if len(best) >= limit {
+			break
 		}
 	}
 
-	// 转换为SearchResult
-	var results []SearchResult
-	for _, c := range seen {
-		if len(results) >= limit {
-			break
+	// 3. 只为返回的结果读取标题和正文
+	hashes := make([]string, len(best))
+	for i, c := range best {
+		hashes[i] = c.hash
+	}
+	docs, err := s.loadResultDocuments(hashes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return best, docs, nil
+}
+
+// vectorKey 块向量的键（内容哈希和块序号）
+type vectorKey struct {
+	hash string
+	seq  int
+}
+
+// loadCodedVectors 读取块的float32向量或int8编码（量化块预筛选入选后才读取）
+func (s *Store) loadCodedVectors(keys []vectorKey) (map[vectorKey]storedVector, error) {
+	wanted := make(map[vectorKey]bool, len(keys))
+	seen := make(map[string]bool)
+	var hashes []interface{}
+	for _, k := range keys {
+		wanted[k] = true
+		if !seen[k.hash] {
+			seen[k.hash] = true
+			hashes = append(hashes, k.hash)
 		}
+	}
+
+	vectors := make(map[vectorKey]storedVector, len(wanted))
+	if len(hashes) == 0 {
+		return vectors, nil
+	}
 
-		result := SearchResult{
-			ID:         c.hash,
-			Score:      1.0 - c.distance, // 余弦相似度
-			Title:      c.title,
-			Content:    c.body,
-			Source:     "vector",
-			Collection: c.collection,
-			Path:       c.path,
+	rows, err := s.readDB.Query(`
+		SELECT cv.hash, cv.seq, cv.embedding, q.bits, q.int8, COALESCE(q.scale, 0)
+		FROM content_vectors cv
+		LEFT JOIN vector_codes q ON q.hash = cv.hash AND q.seq = cv.seq
+		WHERE cv.hash IN (`+placeholders(len(hashes))+`)
+	`, hashes...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load vectors: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var k vectorKey
+		var v storedVector
+		if err := rows.Scan(&k.hash, &k.seq, &v.blob, &v.bits, &v.int8, &v.scale); err != nil {
+			return nil, fmt.Errorf("failed to scan vector: %w", err)
+		}
+		if wanted[k] {
+			vectors[k] = v
 		}
-		result.Timestamp, _ = time.Parse(time.RFC3339, c.modifiedAt)
-		result.Snippet = extractSnippet(c.body, query, 300)
+	}
 
-		results = append(results, result)
+	return vectors, rows.Err()
+}
+
+// loadResultDocuments 读取引用这些内容的有效文档（collection/path -> 只填充文档字段的结果，ID为文档ID）
+func (s *Store) loadResultDocuments(hashes []string) (map[string]SearchResult, error) {
+	docs := make(map[string]SearchResult)
+	if len(hashes) == 0 {
+		return docs, nil
 	}
 
-	// 按分数排序
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
-	})
+	args := make([]interface{}, len(hashes))
+	for i, h := range hashes {
+		args[i] = h
+	}
 
-	if len(results) > limit {
-		results = results[:limit]
+	rows, err := s.readDB.Query(`
+		SELECT d.id, d.collection, d.path, d.title, d.modified_at, c.doc, COALESCE(l.language, '')
+		FROM documents d
+		JOIN content c ON c.hash = d.hash
+		LEFT JOIN content_languages l ON l.hash = d.hash
+		WHERE d.active = 1 AND d.hash IN (`+placeholders(len(args))+`)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load documents: %w", err)
 	}
+	defer rows.Close()
 
-	return results, nil
+	for rows.Next() {
+		var doc SearchResult
+		var modifiedAt string
+		if err := rows.Scan(&doc.ID, &doc.Collection, &doc.Path, &doc.Title, &modifiedAt, &doc.Content, &doc.Language); err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+		doc.Timestamp, _ = time.Parse(time.RFC3339, modifiedAt)
+		docs[doc.Collection+"/"+doc.Path] = doc
+	}
+
+	return docs, rows.Err()
 }
 
 // ReciprocalRankFusion RRF算法融合多个排序列表
@@ -292,12 +430,12 @@ func buildFTS5Query(query string) string {
 // normalizeBM25Score 将BM25分数转换为[0,1]范围
 func normalizeBM25Score(bm25 float64) float64 {
 	// BM25分数是负数，绝对值越大越相关
-	// 使用 1 / (1 + |score|) 归一化
+	// 使用 |score| / (1 + |score|) 归一化，保证越相关分数越高
 	absScore := -bm25
 	if absScore < 0 {
 		absScore = 0
 	}
-	return 1.0 / (1.0 + absScore)
+	return absScore / (1.0 + absScore)
 }
 
 // extractSnippet 提取包含查询词的片段
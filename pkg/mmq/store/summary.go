@@ -0,0 +1,284 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DocumentSummary 文档摘要（按内容哈希存储）
+type DocumentSummary struct {
+	Hash      string    // 内容哈希
+	Summary   string    // 摘要文本
+	Model     string    // 生成摘要的模型
+	CreatedAt time.Time // 生成时间
+}
+
+// SaveDocumentSummary 保存文档摘要（已存在则覆盖），同时更新摘要全文索引
+func (s *Store) SaveDocumentSummary(hash, summary, model string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	err := s.withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`
+			INSERT INTO document_summaries (hash, summary, model, created_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT(hash) DO UPDATE SET
+				summary = excluded.summary,
+				model = excluded.model,
+				created_at = excluded.created_at
+		`, hash, summary, model, now); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM summaries_fts WHERE hash = ?", hash); err != nil {
+			return err
+		}
+		// 与文档的CJK索引一样按双字切分
+		_, err := tx.Exec("INSERT INTO summaries_fts (hash, summary) VALUES (?, ?)", hash, segmentCJK(summary))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save summary: %w", err)
+	}
+
+	return nil
+}
+
+// rebuildSummaryIndex 按document_summaries重建摘要全文索引
+func rebuildSummaryIndex(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT hash, summary FROM document_summaries")
+	if err != nil {
+		return err
+	}
+	summaries := make(map[string]string)
+	for rows.Next() {
+		var hash, summary string
+		if err := rows.Scan(&hash, &summary); err != nil {
+			rows.Close()
+			return err
+		}
+		summaries[hash] = summary
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM summaries_fts"); err != nil {
+		return err
+	}
+	for hash, summary := range summaries {
+		if _, err := tx.Exec("INSERT INTO summaries_fts (hash, summary) VALUES (?, ?)", hash, segmentCJK(summary)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetDocumentSummary 获取内容哈希对应的摘要，没有摘要时返回nil
+func (s *Store) GetDocumentSummary(hash string) (*DocumentSummary, error) {
+	var summary DocumentSummary
+	var createdAtStr string
+
+	err := s.readDB.QueryRow(`
+		SELECT hash, summary, model, created_at
+		FROM document_summaries
+		WHERE hash = ?
+	`, hash).Scan(&summary.Hash, &summary.Summary, &summary.Model, &createdAtStr)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get summary: %w", err)
+	}
+
+	summary.CreatedAt, _ = time.Parse(time.RFC3339, createdAtStr)
+	return &summary, nil
+}
+
+// GetDocumentsNeedingSummary 获取还没有摘要的活跃文档（同一内容只返回一次）
+// collection为空时检查所有集合
+func (s *Store) GetDocumentsNeedingSummary(collection string) ([]Document, error) {
+	query := `
+		SELECT d.collection, d.path, d.title, d.hash, c.doc
+		FROM documents d
+		JOIN content c ON c.hash = d.hash
+		LEFT JOIN document_summaries ds ON ds.hash = d.hash
+		WHERE d.active = 1 AND ds.hash IS NULL
+	`
+	var args []interface{}
+	if collection != "" {
+		query += " AND d.collection = ?"
+		args = append(args, collection)
+	}
+	query += " ORDER BY d.collection, d.path"
+
+	rows, err := s.readDB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query documents: %w", err)
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	var docs []Document
+	for rows.Next() {
+		var doc Document
+		if err := rows.Scan(&doc.Collection, &doc.Path, &doc.Title, &doc.Hash, &doc.Content); err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+		if seen[doc.Hash] {
+			continue
+		}
+		seen[doc.Hash] = true
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
+
+// PruneDocumentSummaries 删除已没有活跃文档引用的摘要（内容修改或文档删除后遗留）
+func (s *Store) PruneDocumentSummaries() (int, error) {
+	var removed int64
+
+	err := s.withTx(func(tx *sql.Tx) error {
+		orphan := `SELECT hash FROM document_summaries
+			WHERE hash NOT IN (SELECT hash FROM documents WHERE active = 1)`
+
+		if _, err := tx.Exec("DELETE FROM summaries_fts WHERE hash IN (" + orphan + ")"); err != nil {
+			return err
+		}
+		result, err := tx.Exec("DELETE FROM document_summaries WHERE hash IN (" + orphan + ")")
+		if err != nil {
+			return err
+		}
+		removed, _ = result.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune summaries: %w", err)
+	}
+
+	return int(removed), nil
+}
+
+// searchSummaries 在文档摘要中执行BM25检索（摘要按正文权重计分）
+func (s *Store) searchSummaries(ftsQuery, query string, limit int, filter DocumentFilter, bodyWeight float64) ([]SearchResult, error) {
+	sql := `
+		SELECT
+			d.hash,
+			d.title,
+			d.collection,
+			d.path,
+			c.doc,
+			d.modified_at,
+			COALESCE(l.language, ''),
+			bm25(summaries_fts, 0, ?) as bm25_score
+		FROM summaries_fts sf
+		JOIN documents d ON d.hash = sf.hash
+		JOIN content c ON c.hash = d.hash
+		LEFT JOIN content_languages l ON l.hash = d.hash
+		WHERE summaries_fts MATCH ? AND d.active = 1
+	`
+
+	args := []interface{}{bodyWeight, ftsQuery}
+	where, whereArgs := filter.where()
+	sql += where
+	args = append(args, whereArgs...)
+
+	sql += " ORDER BY bm25_score ASC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.readDB.Query(sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("summary FTS query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var result SearchResult
+		var bm25Score float64
+		var modifiedAt string
+
+		err := rows.Scan(
+			&result.ID, &result.Title, &result.Collection, &result.Path,
+			&result.Content, &modifiedAt, &result.Language, &bm25Score,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan result: %w", err)
+		}
+
+		result.Score = normalizeBM25Score(bm25Score)
+		result.Source = "fts"
+		result.Timestamp, _ = time.Parse(time.RFC3339, modifiedAt)
+		result.Snippet = extractSnippet(result.Content, query, 300)
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// mergeSummaryHits 合并正文命中和摘要命中：同一文档保留较高分，按分数重新排序并截断
+// 两者的BM25分数来自不同语料（文档频率、平均长度不同），不能直接比较：
+// 摘要命中先按摘要语料的最高分归一化，再映射到正文命中的分数范围
+func mergeSummaryHits(results, summaryHits []SearchResult, limit int) []SearchResult {
+	if len(summaryHits) == 0 {
+		return results
+	}
+	summaryHits = rescaleScores(summaryHits, results)
+
+	index := make(map[string]int, len(results))
+	for i, r := range results {
+		index[r.Collection+"/"+r.Path] = i
+	}
+
+	for _, hit := range summaryHits {
+		key := hit.Collection + "/" + hit.Path
+		if i, ok := index[key]; ok {
+			if hit.Score > results[i].Score {
+				results[i].Score = hit.Score
+			}
+			continue
+		}
+		index[key] = len(results)
+		results = append(results, hit)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
+
+// rescaleScores 将hits的分数按其最高分归一化后乘以reference的最高分
+// reference为空时（只有一个语料）保持原分数
+func rescaleScores(hits, reference []SearchResult) []SearchResult {
+	hitMax, refMax := maxScore(hits), maxScore(reference)
+	if hitMax <= 0 || refMax <= 0 {
+		return hits
+	}
+
+	scaled := make([]SearchResult, len(hits))
+	for i, hit := range hits {
+		hit.Score = hit.Score / hitMax * refMax
+		scaled[i] = hit
+	}
+	return scaled
+}
+
+func maxScore(results []SearchResult) float64 {
+	best := 0.0
+	for _, r := range results {
+		if r.Score > best {
+			best = r.Score
+		}
+	}
+	return best
+}
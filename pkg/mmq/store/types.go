@@ -26,6 +26,14 @@ type SearchResult struct {
 	Collection string
 	Path       string
 	Timestamp  time.Time
+	Language   string // 文档内容的语言（索引时检测，无法判断时为空）
+}
+
+// ChunkEmbedding 内容的一个块向量
+type ChunkEmbedding struct {
+	Seq       int       // 块序号
+	Pos       int       // 块在原文档中的字符位置
+	Embedding []float32 // 解码后的float32向量
 }
 
 // Status 索引状态
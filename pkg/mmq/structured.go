@@ -0,0 +1,434 @@
+package mmq
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/crosszan/modu/pkg/mmq/store"
+)
+
+// 结构化数据格式
+const (
+	FormatCSV   = "csv"
+	FormatTSV   = "tsv"
+	FormatJSON  = "json"
+	FormatJSONL = "jsonl"
+)
+
+// titleFieldNames 自动选择行标题时优先使用的字段名（不区分大小写）
+var titleFieldNames = []string{"title", "name", "question", "subject", "heading", "标题", "名称", "问题"}
+
+// structuredRow 解析出的一行，keys保持字段顺序
+type structuredRow struct {
+	line   int // 记录在文件中的起始行号（JSON文档为元素序号）
+	keys   []string
+	fields map[string]string
+}
+
+// structuredFormat 按扩展名判断结构化数据格式，不是结构化数据时返回空
+func structuredFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV
+	case ".tsv":
+		return FormatTSV
+	case ".json":
+		return FormatJSON
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	}
+	return ""
+}
+
+// IndexStructuredFile 将CSV/TSV/JSON/JSONL文件按行索引，返回行数
+// 每行（或每条JSON记录）作为独立文档，内容渲染为"字段: 值"文本，
+// 字段保存为可过滤的元数据（SearchOptions.Fields），文档路径为 <文件路径>#<行号>。
+// 重新索引时，文件中已不存在的行文档会被删除。
+func (m *MMQ) IndexStructuredFile(filePath string, opts StructuredOptions) (int, error) {
+	absPath, err := filepath.Abs(expandPath(filePath))
+	if err != nil {
+		return 0, fmt.Errorf("invalid path: %w", err)
+	}
+
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if opts.Path == "" {
+		opts.Path = filepath.Base(absPath)
+	}
+	if opts.Format == "" {
+		opts.Format = structuredFormat(absPath)
+	}
+
+	modTime := time.Now()
+	if info, err := os.Stat(absPath); err == nil {
+		modTime = info.ModTime()
+	}
+
+	return m.indexStructured(data, opts, modTime)
+}
+
+// IndexStructured 将内存中的结构化数据按行索引，返回行数（opts.Path必填）
+func (m *MMQ) IndexStructured(data []byte, opts StructuredOptions) (int, error) {
+	if opts.Format == "" {
+		opts.Format = structuredFormat(opts.Path)
+	}
+	return m.indexStructured(data, opts, time.Now())
+}
+
+// indexStructured 解析并索引每一行，然后替换文件的行记录
+func (m *MMQ) indexStructured(data []byte, opts StructuredOptions, modTime time.Time) (int, error) {
+	if opts.Collection == "" {
+		return 0, fmt.Errorf("collection is required")
+	}
+	if opts.Path == "" {
+		return 0, fmt.Errorf("path is required")
+	}
+
+	rows, err := parseStructured(data, opts.Format)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", opts.Path, err)
+	}
+
+	records := make([]store.StructuredRecord, 0, len(rows))
+	for _, row := range rows {
+		rowNum := row.line
+		path := store.RecordPath(opts.Path, rowNum)
+
+		doc := Document{
+			Collection: opts.Collection,
+			Path:       path,
+			Title:      rowTitle(row, opts.TitleField, opts.Path, rowNum),
+			Content:    renderRow(row),
+			CreatedAt:  modTime,
+			ModifiedAt: modTime,
+		}
+		if err := m.IndexDocument(doc); err != nil {
+			return 0, fmt.Errorf("failed to index row %d: %w", rowNum, err)
+		}
+
+		records = append(records, store.StructuredRecord{
+			Collection: opts.Collection,
+			Path:       path,
+			SourcePath: opts.Path,
+			Row:        rowNum,
+			Fields:     row.fields,
+		})
+	}
+
+	if err := m.store.SaveRecords(opts.Collection, opts.Path, records); err != nil {
+		return 0, err
+	}
+
+	return len(rows), nil
+}
+
+// GetRecord 获取行文档对应的记录（路径格式：collection/path 或 qmd://collection/path）
+func (m *MMQ) GetRecord(docPath string) (*StructuredRecord, error) {
+	collection, path, ok := strings.Cut(strings.TrimPrefix(docPath, "qmd://"), "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid document path: %s", docPath)
+	}
+
+	record, err := m.store.GetRecord(collection, path)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, fmt.Errorf("record not found: %s", docPath)
+	}
+
+	result := convertRecord(*record)
+	return &result, nil
+}
+
+// ListRecords 列出文件的全部行记录，按行号排序
+func (m *MMQ) ListRecords(collection, sourcePath string) ([]StructuredRecord, error) {
+	records, err := m.store.ListRecords(collection, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	return convertRecords(records), nil
+}
+
+// FindRecords 查找所有字段都匹配的行记录（值忽略大小写和首尾空白）
+func (m *MMQ) FindRecords(collection string, fields map[string]string) ([]StructuredRecord, error) {
+	records, err := m.store.FindRecords(collection, fields)
+	if err != nil {
+		return nil, err
+	}
+	return convertRecords(records), nil
+}
+
+// convertRecord 转换store.StructuredRecord到mmq.StructuredRecord
+func convertRecord(r store.StructuredRecord) StructuredRecord {
+	return StructuredRecord{
+		Collection: r.Collection,
+		Path:       r.Path,
+		SourcePath: r.SourcePath,
+		Row:        r.Row,
+		Fields:     r.Fields,
+	}
+}
+
+// convertRecords 批量转换行记录
+func convertRecords(records []store.StructuredRecord) []StructuredRecord {
+	result := make([]StructuredRecord, len(records))
+	for i, r := range records {
+		result[i] = convertRecord(r)
+	}
+	return result
+}
+
+// parseStructured 按格式解析为行
+func parseStructured(data []byte, format string) ([]structuredRow, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	switch format {
+	case FormatCSV:
+		return parseDelimited(data, ',')
+	case FormatTSV:
+		return parseDelimited(data, '\t')
+	case FormatJSON:
+		return parseJSON(data)
+	case FormatJSONL:
+		return parseJSONL(data)
+	default:
+		return nil, fmt.Errorf("unsupported structured format: %q", format)
+	}
+}
+
+// parseDelimited 解析带表头的CSV/TSV，跳过全空行
+func parseDelimited(data []byte, comma rune) ([]structuredRow, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if comma == '\t' {
+		reader.LazyQuotes = true
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	header = columnNames(header)
+
+	var rows []structuredRow
+	for {
+		values, err := reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := structuredRow{line: line, fields: make(map[string]string)}
+		empty := true
+		for i, value := range values {
+			key := fmt.Sprintf("column%d", i+1)
+			if i < len(header) {
+				key = header[i]
+			}
+			value = strings.TrimSpace(value)
+			if value != "" {
+				empty = false
+			}
+			row.keys = append(row.keys, key)
+			row.fields[key] = value
+		}
+		if !empty {
+			rows = append(rows, row)
+		}
+	}
+
+	return rows, nil
+}
+
+// columnNames 规范化表头：空列名和重复列名按列号命名
+func columnNames(header []string) []string {
+	names := make([]string, len(header))
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			name = fmt.Sprintf("column%d", i+1)
+		}
+		seen[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// parseJSON 解析JSON：对象数组中每个元素一行，单个对象为一行
+func parseJSON(data []byte) ([]structuredRow, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	items, ok := value.([]interface{})
+	if !ok {
+		items = []interface{}{value}
+	}
+
+	rows := make([]structuredRow, 0, len(items))
+	for i, item := range items {
+		row := flattenRecord(item)
+		row.line = i + 1
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseJSONL 解析JSON Lines：每个非空行一条记录
+func parseJSONL(data []byte) ([]structuredRow, error) {
+	var rows []structuredRow
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		row := flattenRecord(value)
+		row.line = line
+		rows = append(rows, row)
+	}
+
+	return rows, scanner.Err()
+}
+
+// flattenRecord 将JSON记录展开为字段：嵌套对象用"."连接键名，标量数组用", "连接
+func flattenRecord(value interface{}) structuredRow {
+	row := structuredRow{fields: make(map[string]string)}
+
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		row.keys = []string{"value"}
+		row.fields["value"] = jsonScalar(value)
+		return row
+	}
+
+	flattenObject(&row, "", obj)
+	return row
+}
+
+// flattenObject 递归展开对象（键按名称排序）
+func flattenObject(row *structuredRow, prefix string, obj map[string]interface{}) {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+
+		if nested, ok := obj[k].(map[string]interface{}); ok {
+			flattenObject(row, key, nested)
+			continue
+		}
+
+		row.keys = append(row.keys, key)
+		row.fields[key] = jsonScalar(obj[k])
+	}
+}
+
+// jsonScalar 将JSON值转为字段文本
+func jsonScalar(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				encoded, _ := json.Marshal(v)
+				return string(encoded)
+			}
+			parts = append(parts, jsonScalar(item))
+		}
+		return strings.Join(parts, ", ")
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
+
+// renderRow 将一行渲染为"字段: 值"文本（用于全文检索和嵌入），跳过空值
+func renderRow(row structuredRow) string {
+	var sb strings.Builder
+	for _, key := range row.keys {
+		value := row.fields[key]
+		if value == "" {
+			continue
+		}
+		sb.WriteString(key)
+		sb.WriteString(": ")
+		sb.WriteString(value)
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// rowTitle 行标题：指定字段 > 常见标题字段 > "文件名 #行号"
+func rowTitle(row structuredRow, titleField, sourcePath string, rowNum int) string {
+	if titleField != "" {
+		if value := row.fields[titleField]; value != "" {
+			return value
+		}
+	}
+
+	for _, name := range titleFieldNames {
+		for _, key := range row.keys {
+			if strings.EqualFold(key, name) && row.fields[key] != "" {
+				return row.fields[key]
+			}
+		}
+	}
+
+	return fmt.Sprintf("%s #%d", filepath.Base(sourcePath), rowNum)
+}
@@ -0,0 +1,238 @@
+package mmq
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const faqCSV = `question,answer,category
+How do I reset my password?,"Open Settings, then Security, and choose Reset.",account
+Can I get a refund?,Refunds are issued within 30 days of purchase.,billing
+
+Which payment methods are accepted?,"Visa, Mastercard and PayPal.",billing
+`
+
+func TestIndexDirectoryStructured(t *testing.T) {
+	m := newRankingTestMMQ(t)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"faq.csv":   faqCSV,
+		"notes.md":  "# Notes\n\nPlain markdown stays a single document.",
+		"data.json": `[{"sku": "A1", "name": "Widget", "price": 9.5, "tags": ["blue", "small"], "dims": {"w": 3, "h": 4}}]`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m.IndexDirectory(dir, IndexOptions{Collection: "kb", Mask: "**/*.{md,csv,json}"}); err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := m.ListDocuments("kb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, d := range docs {
+		paths = append(paths, d.Path)
+	}
+	t.Logf("Indexed: %v", paths)
+	want := "data.json#1,faq.csv#2,faq.csv#3,faq.csv#5,notes.md"
+	if strings.Join(paths, ",") != want {
+		t.Fatalf("Expected %s, got %v", want, paths)
+	}
+
+	// 每行渲染为"字段: 值"文本，标题取问题列
+	doc, err := m.GetDocumentByPath("kb/faq.csv#2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title != "How do I reset my password?" {
+		t.Errorf("Expected question as title, got %q", doc.Title)
+	}
+	wantContent := "question: How do I reset my password?\nanswer: Open Settings, then Security, and choose Reset.\ncategory: account"
+	if doc.Content != wantContent {
+		t.Errorf("Unexpected row content:\n%s", doc.Content)
+	}
+
+	// 行文档指回文件和文件中的行号（表头为第1行，空行占行号）
+	record, err := m.GetRecord("kb/faq.csv#5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.SourcePath != "faq.csv" || record.Row != 5 || record.Fields["question"] != "Which payment methods are accepted?" {
+		t.Errorf("Unexpected record %+v", record)
+	}
+
+	// JSON 嵌套对象展开，数组连接
+	product, err := m.GetRecord("kb/data.json#1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("JSON record: %v", product.Fields)
+	if product.Fields["dims.w"] != "3" || product.Fields["tags"] != "blue, small" || product.Fields["price"] != "9.5" {
+		t.Errorf("Unexpected JSON fields %v", product.Fields)
+	}
+
+	if _, err := m.GetRecord("kb/notes.md"); err == nil {
+		t.Error("Expected markdown document to have no record")
+	}
+
+	// 行级检索
+	results, err := m.Search("refund", SearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != "faq.csv#3" {
+		t.Errorf("Expected refund row, got %v", searchPaths(results))
+	}
+}
+
+func TestStructuredFieldFilter(t *testing.T) {
+	m := newRankingTestMMQ(t)
+
+	n, err := m.IndexStructured([]byte(faqCSV), StructuredOptions{Collection: "kb", Path: "faq.csv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("Expected 3 rows, got %d", n)
+	}
+	indexDocs(t, m, Document{Collection: "kb", Path: "guide.md", Title: "Guide", Content: "Billing and account guide."})
+	if err := m.GenerateEmbeddings(); err != nil {
+		t.Fatal(err)
+	}
+
+	billing := map[string]string{"category": " Billing "}
+
+	results, err := m.Search("billing", SearchOptions{Limit: 10, Fields: billing})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("fts: %v", searchPaths(results))
+	if strings.Join(searchPaths(results), ",") != "kb/faq.csv#3,kb/faq.csv#5" &&
+		strings.Join(searchPaths(results), ",") != "kb/faq.csv#5,kb/faq.csv#3" {
+		t.Errorf("Expected only billing rows, got %v", searchPaths(results))
+	}
+
+	ranked, err := m.Search("billing", SearchOptions{Limit: 10, Fields: billing, Ranking: &RankingProfile{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranked) != 2 {
+		t.Errorf("Expected 2 billing rows with ranking profile, got %v", searchPaths(ranked))
+	}
+
+	vector, err := m.VectorSearch("payment", SearchOptions{Limit: 10, Fields: billing})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hybrid, err := m.HybridSearch("payment", SearchOptions{Limit: 10, Fields: billing})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contexts, err := m.RetrieveContext("payment", RetrieveOptions{Limit: 10, Strategy: StrategyHybrid, Fields: billing})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range append(vector, hybrid...) {
+		if !strings.HasPrefix(r.Path, "faq.csv#") || r.Path == "faq.csv#2" {
+			t.Errorf("Unexpected result outside filter: %s", r.Path)
+		}
+	}
+	for _, c := range contexts {
+		if c.Source != "kb/faq.csv#3" && c.Source != "kb/faq.csv#5" {
+			t.Errorf("Unexpected context outside filter: %s", c.Source)
+		}
+	}
+
+	// 没有匹配的字段时不返回结果
+	none, err := m.Search("billing", SearchOptions{Limit: 10, Fields: map[string]string{"category": "shipping"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(none) != 0 {
+		t.Errorf("Expected no results, got %v", searchPaths(none))
+	}
+
+	// 过滤在查询中执行：匹配的行排在大量不匹配的行之后也能返回
+	var inventory strings.Builder
+	inventory.WriteString("name,notes,category\n")
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&inventory, "widget %d,widget widget,common\n", i)
+	}
+	inventory.WriteString("gadget,a long note that mentions a widget only once among many other words,rare\n")
+	if _, err := m.IndexStructured([]byte(inventory.String()), StructuredOptions{Collection: "stock", Path: "items.csv"}); err != nil {
+		t.Fatal(err)
+	}
+	rare := map[string]string{"category": "rare"}
+	for name, opts := range map[string]SearchOptions{
+		"fts":    {Limit: 1, Fields: rare},
+		"ranked": {Limit: 1, Fields: rare, Ranking: &RankingProfile{}},
+	} {
+		results, err := m.Search("widget", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Path != "items.csv#42" {
+			t.Errorf("%s: expected the rare row, got %v", name, searchPaths(results))
+		}
+	}
+	contexts, err = m.RetrieveContext("widget", RetrieveOptions{Limit: 1, Strategy: StrategyFTS, Fields: rare})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contexts) != 1 || contexts[0].Source != "stock/items.csv#42" {
+		t.Errorf("Expected the rare row from retrieval, got %+v", contexts)
+	}
+}
+
+func TestStructuredReindexRemovesStaleRows(t *testing.T) {
+	m := newRankingTestMMQ(t)
+
+	// 之前按整个文件索引的文档会被行文档替换
+	indexDocs(t, m, Document{Collection: "kb", Path: "items.jsonl", Title: "items", Content: "blob"})
+
+	data := "{\"id\": 1, \"name\": \"alpha\"}\n\n{\"id\": 2, \"name\": \"beta\"}\n{\"id\": 3, \"name\": \"gamma\"}\n"
+	if _, err := m.IndexStructured([]byte(data), StructuredOptions{Collection: "kb", Path: "items.jsonl"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetDocumentByPath("kb/items.jsonl"); err == nil {
+		t.Error("Expected whole-file document to be replaced")
+	}
+
+	records, err := m.ListRecords("kb", "items.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[1].Fields["name"] != "beta" || records[1].Row != 3 {
+		t.Fatalf("Unexpected records %+v", records)
+	}
+
+	if _, err := m.IndexStructured([]byte("{\"id\": 1, \"name\": \"alpha\"}\n"), StructuredOptions{Collection: "kb", Path: "items.jsonl"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetDocumentByPath("kb/items.jsonl#4"); err == nil {
+		t.Error("Expected removed row to be deleted")
+	}
+	records, err = m.ListRecords("kb", "items.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Errorf("Expected 1 remaining record, got %+v", records)
+	}
+
+	if _, err := m.IndexStructured([]byte("{not json"), StructuredOptions{Collection: "kb", Path: "bad.jsonl"}); err == nil {
+		t.Error("Expected parse error")
+	}
+	if _, err := m.IndexStructured([]byte("a,b"), StructuredOptions{Collection: "kb", Path: "data.xlsx"}); err == nil {
+		t.Error("Expected unsupported format error")
+	}
+}
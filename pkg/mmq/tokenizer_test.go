@@ -0,0 +1,202 @@
+package mmq
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crosszan/modu/pkg/mmq/llm"
+	"github.com/crosszan/modu/pkg/mmq/rag"
+)
+
+// wordTokenizer 按空白分词计数（用于验证分词器可替换）
+type wordTokenizer struct{}
+
+func (wordTokenizer) Count(text string) int {
+	return len(strings.Fields(text))
+}
+
+// writeTiktokenFile 写入一个最小的tiktoken词表：256个单字节 + 若干合并
+func writeTiktokenFile(t *testing.T, merges ...string) string {
+	t.Helper()
+
+	var builder strings.Builder
+	for b := 0; b < 256; b++ {
+		fmt.Fprintf(&builder, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(b)}), b)
+	}
+	for i, m := range merges {
+		fmt.Fprintf(&builder, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(m)), 256+i)
+	}
+
+	path := filepath.Join(t.TempDir(), "test.tiktoken")
+	if err := os.WriteFile(path, []byte(builder.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBPETokenizer(t *testing.T) {
+	path := writeTiktokenFile(t, "he", "ll", "hell", "hello", " w", "or", " wor", " world")
+
+	tok, err := llm.LoadTiktoken(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.VocabSize() != 264 {
+		t.Errorf("Expected 264 tokens, got %d", tok.VocabSize())
+	}
+
+	ids := tok.Encode("hello world")
+	t.Logf("hello world -> %v", ids)
+	if len(ids) != 2 || ids[0] != 259 || ids[1] != 263 {
+		t.Errorf("Expected [hello, \" world\"], got %v", ids)
+	}
+
+	for _, text := range []string{"hello world", "hello   world\n\nfoo", "数据库 hello!", ""} {
+		ids := tok.Encode(text)
+		if got := tok.Decode(ids); got != text {
+			t.Errorf("Round trip failed: %q -> %q", text, got)
+		}
+		if tok.Count(text) != len(ids) {
+			t.Errorf("Count mismatch for %q: %d vs %d", text, tok.Count(text), len(ids))
+		}
+	}
+
+	// 多个空格时最后一个空格归入下一个词（与tiktoken一致）
+	if got := tok.Encode("hello  world"); len(got) != 3 || got[1] != ' ' || got[2] != 263 {
+		t.Errorf("Expected trailing space to join next word, got %v", got)
+	}
+
+	if _, err := llm.LoadTiktoken(filepath.Join(t.TempDir(), "missing"), ""); err == nil {
+		t.Error("Expected error for missing vocabulary")
+	}
+}
+
+func TestHeuristicTokenizerCJK(t *testing.T) {
+	var tok llm.Tokenizer = llm.HeuristicTokenizer{}
+
+	zh := tok.Count("如何配置数据库连接")
+	en := tok.Count("How to configure the database connection")
+	t.Logf("zh=%d en=%d", zh, en)
+
+	if zh != 9 {
+		t.Errorf("Expected one token per Han character, got %d", zh)
+	}
+	if en < 8 || en > 12 {
+		t.Errorf("Expected about 10 tokens for english text, got %d", en)
+	}
+}
+
+func budgetContexts() []rag.Context {
+	long := func(topic string, n int) string {
+		var sentences []string
+		for i := 1; i <= n; i++ {
+			sentences = append(sentences, fmt.Sprintf("Sentence %d explains %s in some detail here.", i, topic))
+		}
+		return strings.Join(sentences, " ")
+	}
+
+	return []rag.Context{
+		{Text: long("deployment", 20), Source: "ops/deploy.md", Relevance: 0.9},
+		{Text: long("rollback", 20), Source: "ops/rollback.md", Relevance: 0.45},
+		{Text: "Short note about canary releases.", Source: "ops/canary.md", Relevance: 0.3},
+	}
+}
+
+func TestContextBuilderBudget(t *testing.T) {
+	contexts := budgetContexts()
+
+	for _, strategy := range []rag.BudgetStrategy{rag.BudgetGreedy, rag.BudgetProportional} {
+		for _, budget := range []int{40, 120, 300, 1000} {
+			builder := rag.NewContextBuilder(rag.ContextBuilderOptions{
+				MaxTokens:     budget,
+				IncludeSource: true,
+				Separator:     "\n\n---\n\n",
+				Format:        rag.FormatPlain,
+				Budget:        strategy,
+				Tokenizer:     wordTokenizer{},
+			})
+
+			result := builder.BuildWithUsage(contexts)
+			t.Logf("%s/%d: used %d", strategy, budget, result.TokensUsed)
+
+			if result.TokensUsed > budget {
+				t.Errorf("%s/%d: overflowed budget with %d tokens", strategy, budget, result.TokensUsed)
+			}
+			if result.TokensUsed != (wordTokenizer{}).Count(result.Text) {
+				t.Errorf("%s/%d: reported %d tokens for text", strategy, budget, result.TokensUsed)
+			}
+			// 有截断时说明预算不足，应基本填满
+			for _, u := range result.Contexts {
+				if u.Truncated && result.TokensUsed < budget*3/4 {
+					t.Errorf("%s/%d: underfilled budget with %d tokens", strategy, budget, result.TokensUsed)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestContextBuilderProportionalAllocation(t *testing.T) {
+	builder := rag.NewContextBuilder(rag.ContextBuilderOptions{
+		MaxTokens: 150,
+		Separator: "\n\n",
+		Format:    rag.FormatPlain,
+		Budget:    rag.BudgetProportional,
+		Tokenizer: wordTokenizer{},
+	})
+
+	result := builder.BuildWithUsage(budgetContexts())
+	for _, u := range result.Contexts {
+		t.Logf("%s: %d/%d truncated=%v dropped=%v", u.Source, u.Tokens, u.OriginalTokens, u.Truncated, u.Dropped)
+	}
+
+	deploy, rollback, canary := result.Contexts[0], result.Contexts[1], result.Contexts[2]
+	if deploy.Dropped || rollback.Dropped || canary.Dropped {
+		t.Fatal("Expected all contexts to be included")
+	}
+	if canary.Truncated || canary.Tokens != canary.OriginalTokens {
+		t.Errorf("Expected short context kept whole, got %+v", canary)
+	}
+	if !deploy.Truncated || !rollback.Truncated {
+		t.Errorf("Expected long contexts truncated")
+	}
+	if deploy.Tokens <= rollback.Tokens*3/2 {
+		t.Errorf("Expected budget proportional to relevance, got %d vs %d", deploy.Tokens, rollback.Tokens)
+	}
+
+	// 截断落在句子边界
+	for _, part := range strings.Split(result.Text, "\n\n") {
+		if strings.HasSuffix(part, "...") && !strings.HasSuffix(part, "here. ...") {
+			t.Errorf("Expected truncation at sentence boundary, got %q", part[len(part)-20:])
+		}
+	}
+
+	greedy := rag.NewContextBuilder(rag.ContextBuilderOptions{
+		MaxTokens: 150,
+		Separator: "\n\n",
+		Format:    rag.FormatPlain,
+		Tokenizer: wordTokenizer{},
+	}).BuildWithUsage(budgetContexts())
+	if !greedy.Contexts[1].Dropped || !greedy.Contexts[2].Dropped {
+		t.Errorf("Expected greedy build to fill budget with the first context, got %+v", greedy.Contexts)
+	}
+}
+
+func TestTruncateContextSentenceBoundary(t *testing.T) {
+	builder := rag.NewContextBuilder(rag.ContextBuilderOptions{Tokenizer: llm.HeuristicTokenizer{}})
+
+	text := "第一句话说明背景。第二句话描述步骤。第三句话给出结论。"
+	truncated := builder.TruncateContext(text, 14)
+	t.Logf("Truncated: %q", truncated)
+	if truncated != "第一句话说明背景。 ..." {
+		t.Errorf("Expected cut after first sentence, got %q", truncated)
+	}
+
+	if got := builder.TruncateContext(text, 100); got != text {
+		t.Errorf("Expected text within budget unchanged, got %q", got)
+	}
+}
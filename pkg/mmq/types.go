@@ -12,6 +12,8 @@ const (
 	StrategyVector RetrievalStrategy = "vector"
 	// StrategyHybrid 混合搜索+重排（最佳质量）
 	StrategyHybrid RetrievalStrategy = "hybrid"
+	// StrategyAuto 根据查询内容自动选择策略
+	StrategyAuto RetrievalStrategy = "auto"
 )
 
 // MemoryType 记忆类型
@@ -26,6 +28,20 @@ const (
 	MemoryTypePreference MemoryType = "preference"
 	// MemoryTypeEpisodic 情景记忆
 	MemoryTypeEpisodic MemoryType = "episodic"
+	// MemoryTypeReflection 反思记忆（由其他记忆归纳出的高层洞察）
+	MemoryTypeReflection MemoryType = "reflection"
+)
+
+// RecallMode 记忆回忆的检索方式
+type RecallMode string
+
+const (
+	// RecallModeVector 向量相似度（默认）
+	RecallModeVector RecallMode = "vector"
+	// RecallModeFTS BM25全文检索，适合精确的名称、编号等
+	RecallModeFTS RecallMode = "fts"
+	// RecallModeHybrid 全文和向量结果按RRF融合
+	RecallModeHybrid RecallMode = "hybrid"
 )
 
 // SearchResult 搜索结果
@@ -40,19 +56,40 @@ type SearchResult struct {
 	Path       string                 `json:"path"`
 	Metadata   map[string]interface{} `json:"metadata,omitempty"`
 	Timestamp  time.Time              `json:"timestamp"`
+	Language   string                 `json:"language,omitempty"` // 文档内容的语言（无法判断时为空）
+	Index      string                 `json:"index,omitempty"`    // 来源索引（联邦检索时设置）
+	QueryID    string                 `json:"query_id,omitempty"` // 查询日志ID（启用查询日志时设置）
 }
 
 // Context RAG上下文
 type Context struct {
-	Text      string                 `json:"text"`
-	Source    string                 `json:"source"`
-	Relevance float64                `json:"relevance"`
-	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	Text         string                 `json:"text"`
+	Source       string                 `json:"source"`
+	Relevance    float64                `json:"relevance"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	PathContexts []PathContext          `json:"path_contexts,omitempty"` // 继承的路径上下文链（从全局到具体）
+	QueryID      string                 `json:"query_id,omitempty"`      // 查询日志ID（启用查询日志时设置）
+}
+
+// PathContext 路径上下文（全局 /、集合 qmd://collection、目录或文件）
+type PathContext struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// Namespace 记忆的归属：租户、用户、agent和会话
+// 作为检索范围时，非空字段必须相同，空字段不限定
+type Namespace struct {
+	Tenant  string `json:"tenant,omitempty"`
+	User    string `json:"user,omitempty"`
+	Agent   string `json:"agent,omitempty"`
+	Session string `json:"session,omitempty"`
 }
 
 // Memory 记忆
 type Memory struct {
 	ID         string                 `json:"id"`
+	Namespace  Namespace              `json:"namespace"`
 	Type       MemoryType             `json:"type"`
 	Content    string                 `json:"content"`
 	Metadata   map[string]interface{} `json:"metadata,omitempty"`
@@ -60,6 +97,96 @@ type Memory struct {
 	Timestamp  time.Time              `json:"timestamp"`
 	ExpiresAt  *time.Time             `json:"expires_at,omitempty"` // 可选过期时间
 	Importance float64                `json:"importance"`            // 重要性权重 0.0-1.0
+	Index      string                 `json:"index,omitempty"`       // 来源索引（联邦检索时设置）
+
+	AccessCount  int        `json:"access_count,omitempty"`  // 被召回的次数
+	LastAccessed *time.Time `json:"last_accessed,omitempty"` // 最近一次被召回的时间
+}
+
+// ExpiryState 记忆的过期状态
+type ExpiryState string
+
+const (
+	// ExpiryAny 不限
+	ExpiryAny ExpiryState = ""
+	// ExpiryActive 未过期（含未设置过期时间）
+	ExpiryActive ExpiryState = "active"
+	// ExpiryExpired 已过期
+	ExpiryExpired ExpiryState = "expired"
+)
+
+// MemoryFilter 记忆过滤条件，各条件之间为AND关系，零值不过滤
+type MemoryFilter struct {
+	AnyTags       []string               // 至少包含其中一个标签
+	AllTags       []string               // 包含全部标签
+	Metadata      map[string]interface{} // metadata中对应键的值相等
+	Since         time.Time              // 时间不早于Since（如最近7天）
+	Until         time.Time              // 时间早于Until
+	MinImportance float64                // 重要性不低于该值
+	MaxImportance float64                // 重要性不高于该值，0表示不限
+	Expiry        ExpiryState            // 过期状态
+}
+
+// ListMemoriesOptions 分页列出记忆的选项
+type ListMemoriesOptions struct {
+	MemoryTypes []MemoryType // 过滤记忆类型
+	Filter      MemoryFilter // 标签、元数据、时间等过滤
+	Scopes      []Namespace  // 命名空间（取并集），为空时不限定
+	Limit       int          // 每页数量，0表示不限
+	Offset      int          // 跳过的记忆数
+}
+
+// MemoryPage 一页记忆（按时间倒序）
+type MemoryPage struct {
+	Memories []Memory `json:"memories"`
+	Total    int      `json:"total"`  // 满足条件的记忆总数
+	Offset   int      `json:"offset"` // 本页起始位置
+}
+
+// HasMore 之后是否还有记忆
+func (p *MemoryPage) HasMore() bool {
+	return p.Offset+len(p.Memories) < p.Total
+}
+
+// DedupOptions 记忆语义去重选项
+type DedupOptions struct {
+	Threshold       float64   // 余弦相似度达到该值视为重复（同一命名空间、同一类型）
+	ImportanceBoost float64   // 每合并一条增加的重要性（默认0.1）
+	DryRun          bool      // 只统计不修改
+	Namespace       Namespace // 处理的命名空间范围，零值表示全部
+}
+
+// DedupStats 记忆去重统计
+type DedupStats struct {
+	Scanned int `json:"scanned"` // 检查的记忆数（不含对话和情景记忆）
+	Merged  int `json:"merged"`  // 合并后删除的重复记忆数
+	Kept    int `json:"kept"`    // 吸收了重复记忆的记忆数
+}
+
+// ReflectOptions 记忆反思选项
+type ReflectOptions struct {
+	Namespace   Namespace     // 反思的命名空间，洞察写入该命名空间
+	MemoryTypes []MemoryType  // 参考的记忆类型，为空时为对话、事实、偏好和情景记忆
+	Window      time.Duration // 只参考最近这段时间的记忆，0表示不限
+	MaxMemories int           // 每次最多参考的记忆数（默认50）
+	MinMemories int           // 新记忆少于该数时跳过（默认5）
+	MaxInsights int           // 最多生成的洞察数（默认5）
+	DryRun      bool          // 只生成不写入
+}
+
+// Insight 由多条记忆归纳出的高层洞察
+type Insight struct {
+	Content    string   `json:"content"`
+	Sources    []string `json:"sources"` // 来源记忆ID
+	Importance float64  `json:"importance"`
+}
+
+// ReflectionResult 一次反思的结果
+type ReflectionResult struct {
+	Considered int       `json:"considered"`         // 参考的记忆数
+	Insights   []Insight `json:"insights"`           // 写入（DryRun时为将写入）的洞察
+	Rejected   []string  `json:"rejected,omitempty"` // 校验未通过的洞察及原因
+	Skipped    bool      `json:"skipped"`            // 新记忆不足，未调用LLM
 }
 
 // Document 文档
@@ -81,13 +208,34 @@ type RetrieveOptions struct {
 	Collection string            // 集合过滤
 	Strategy   RetrievalStrategy // 检索策略
 	Rerank     bool              // 是否使用LLM重排
+	Profile    string            // 排序配置名称（为空时使用集合绑定的配置）
+	Ranking    *RankingProfile   // 单次查询的排序配置（优先于Profile）
+
+	// 多样性选择
+	MMRLambda      float64 // MMR相关性权重 (0,1)，越小越偏向多样性，0表示不启用
+	MaxPerDocument int     // 每个文档最多返回的块数，0表示不限制
+	DedupThreshold float64 // 近重复相似度阈值 (0,1]，0表示不启用
+
+	FirstPassRouting bool // StrategyAuto时先执行一次BM25检索，按分数分布修正路由
+
+	Fields map[string]string // 结构化字段过滤（全部匹配，忽略大小写），只返回行文档
+
+	Language       string // 语言过滤（如"zh"、"en"），只返回该语言的文档
+	PreferLanguage string // 偏好语言，其他语言的结果降权；LanguageAuto表示使用查询的语言
 }
 
 // SearchOptions 搜索选项
 type SearchOptions struct {
-	Limit      int     // 返回结果数量
-	MinScore   float64 // 最小分数
-	Collection string  // 集合过滤
+	Limit      int             // 返回结果数量
+	MinScore   float64         // 最小分数
+	Collection string          // 集合过滤
+	Profile    string          // 排序配置名称（为空时使用集合绑定的配置）
+	Ranking    *RankingProfile // 单次查询的排序配置（优先于Profile）
+
+	Fields map[string]string // 结构化字段过滤（全部匹配，忽略大小写），只返回行文档
+
+	Language       string // 语言过滤（如"zh"、"en"），只返回该语言的文档
+	PreferLanguage string // 偏好语言，其他语言的结果降权；LanguageAuto表示使用查询的语言
 }
 
 // IndexOptions 索引选项
@@ -95,6 +243,25 @@ type IndexOptions struct {
 	Mask       string // Glob模式，如 "**/*.md"
 	Recursive  bool   // 是否递归
 	Collection string // 集合名称
+	Summarize  bool   // 索引后为新增或内容变化的文档生成摘要
+}
+
+// StructuredOptions 结构化数据（CSV/TSV/JSON/JSONL）索引选项
+type StructuredOptions struct {
+	Collection string // 集合名称
+	Path       string // 文件在集合中的路径（默认为文件名）
+	Format     string // csv、tsv、json、jsonl，为空时按扩展名判断
+	TitleField string // 用作行标题的字段，为空时自动选择
+}
+
+// StructuredRecord 结构化数据中的一行（CSV行或JSON记录）
+// 每行作为独立文档索引，文档路径为 <文件路径>#<行号>
+type StructuredRecord struct {
+	Collection string            `json:"collection"`
+	Path       string            `json:"path"`        // 行文档路径，如 faq.csv#3
+	SourcePath string            `json:"source_path"` // 所在文件
+	Row        int               `json:"row"`         // 文件中的行号（从1开始，CSV/TSV的表头为第1行；JSON数组为元素序号）
+	Fields     map[string]string `json:"fields"`
 }
 
 // Status 索引状态
@@ -114,6 +281,10 @@ type RecallOptions struct {
 	DecayHalflife       time.Duration // 衰减半衰期
 	WeightByImportance  bool         // 是否按重要性加权
 	MinRelevance        float64      // 最小相关度
+	Scopes              []Namespace  // 检索的命名空间（取并集），为空时不限定
+	Mode                RecallMode   // 检索方式，为空时为RecallModeVector
+	Filter              MemoryFilter // 标签、元数据、时间、重要性和过期状态过滤
+	NoReinforce         bool         // 不记录本次召回（不强化被召回的记忆）
 }
 
 // Collection 集合
@@ -165,3 +336,170 @@ type DocumentDetail struct {
 	CreatedAt  time.Time `json:"created_at"`
 	ModifiedAt time.Time `json:"modified_at"`
 }
+
+// ArchiveOptions 导出/导入时包含的数据部分
+type ArchiveOptions struct {
+	Collections bool // 集合定义及排序配置
+	Contexts    bool // 上下文描述
+	Documents   bool // 文档元数据及内容
+	Embeddings  bool // 文档与记忆的向量（体积较大，导入后可重新生成）
+	Memories    bool // 记忆
+}
+
+// DefaultArchiveOptions 默认导出全部数据，但不包括向量
+func DefaultArchiveOptions() ArchiveOptions {
+	return ArchiveOptions{
+		Collections: true,
+		Contexts:    true,
+		Documents:   true,
+		Embeddings:  false,
+		Memories:    true,
+	}
+}
+
+// ArchiveStats 导出/导入统计（归档内文件名 -> 记录数）
+type ArchiveStats map[string]int
+
+// FieldWeights BM25字段权重
+type FieldWeights struct {
+	Filepath float64 `json:"filepath"`
+	Title    float64 `json:"title"`
+	Body     float64 `json:"body"`
+}
+
+// RankingProfile 排序配置
+// 可按名称保存并绑定到集合，也可以随单次查询传入；零值字段沿用默认行为
+type RankingProfile struct {
+	Name             string             `json:"name"`
+	FieldWeights     FieldWeights       `json:"field_weights"`               // BM25字段权重，默认 10:1:1
+	RRFWeights       []float64          `json:"rrf_weights,omitempty"`       // 混合检索RRF权重 [fts, vector]
+	RRFK             int                `json:"rrf_k,omitempty"`             // RRF参数K，默认60
+	RecencyHalfLife  time.Duration      `json:"recency_half_life,omitempty"` // 按修改时间衰减的半衰期，0表示不启用
+	RecencyWeight    float64            `json:"recency_weight,omitempty"`    // 时间因子权重，默认1.0
+	CollectionBoosts map[string]float64 `json:"collection_boosts,omitempty"` // 集合分数乘数
+
+	PathContextWeight float64 `json:"path_context_weight,omitempty"` // 查询词命中路径上下文链时的加权，0表示不启用
+
+	FeedbackWeight   float64       `json:"feedback_weight,omitempty"`    // 相关性反馈学习到的加权，0表示不启用
+	FeedbackHalfLife time.Duration `json:"feedback_half_life,omitempty"` // 反馈衰减半衰期（默认30天）
+}
+
+// QueryFeatures 查询特征
+type QueryFeatures struct {
+	Terms         int      `json:"terms"`                    // 词项数（拉丁词数 + CJK字符二元组数）
+	Language      string   `json:"language,omitempty"`       // 主要语言：zh、en、mixed
+	QuotedPhrases []string `json:"quoted_phrases,omitempty"` // 引号内的短语
+	CodeTokens    []string `json:"code_tokens,omitempty"`    // 标识符、错误码、路径等代码类词项
+	Question      bool     `json:"question"`                 // 是否为疑问句
+}
+
+// FirstPassStats 首轮BM25检索的分数分布
+type FirstPassStats struct {
+	Hits     int     `json:"hits"`      // 命中数
+	TopScore float64 `json:"top_score"` // 最高分
+	Margin   float64 `json:"margin"`    // 第一名领先第二名的相对幅度
+}
+
+// RoutingDecision 自动检索的路由决策
+type RoutingDecision struct {
+	Strategy  RetrievalStrategy `json:"strategy"`             // 选择的检索策略
+	QueryType string            `json:"query_type"`           // keyword、semantic、complex
+	Features  QueryFeatures     `json:"features"`             // 查询特征
+	FirstPass *FirstPassStats   `json:"first_pass,omitempty"` // 首轮检索统计
+	Reasons   []string          `json:"reasons"`              // 决策原因
+}
+
+// GenerateContextsOptions 上下文生成选项
+type GenerateContextsOptions struct {
+	Collection string // 只为该集合生成（为空时处理所有集合）
+	Path       string // 只为集合下的该目录生成（需指定Collection）
+	Folders    bool   // 同时为集合下的每个一级目录生成
+	SampleSize int    // 每个目标采样的文档数（默认5）
+	MaxChars   int    // 每篇采样文档截取的字符数（默认1000）
+	Overwrite  bool   // 覆盖已有上下文（默认跳过）
+	DryRun     bool   // 只生成不保存
+}
+
+// GeneratedContext 生成的上下文
+type GeneratedContext struct {
+	Path    string `json:"path"`              // 上下文路径（qmd://collection[/folder]）
+	Content string `json:"content,omitempty"` // 生成的描述
+	Sampled int    `json:"sampled"`           // 采样文档数
+	Skipped bool   `json:"skipped,omitempty"` // 已有上下文而跳过
+}
+
+// SummarizeOptions 文档摘要生成选项
+type SummarizeOptions struct {
+	Collection string // 只处理该集合（为空时处理所有集合）
+	MaxChars   int    // 输入文档截取的字符数（默认4000）
+	Limit      int    // 本次最多生成的摘要数（0表示不限）
+}
+
+// DocumentSummary 文档摘要
+type DocumentSummary struct {
+	Hash      string    `json:"hash"`    // 内容哈希（内容变化后需重新生成）
+	Summary   string    `json:"summary"` // 摘要文本
+	Model     string    `json:"model"`   // 生成摘要的模型
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// QueryLogEntry 查询日志条目
+type QueryLogEntry struct {
+	ID         string           `json:"id"`
+	Timestamp  time.Time        `json:"timestamp"`
+	Kind       string           `json:"kind"` // search、vsearch、hybrid、retrieve
+	Query      string           `json:"query"`
+	Collection string           `json:"collection,omitempty"`
+	Strategy   string           `json:"strategy"` // 实际使用的检索策略
+	Latency    time.Duration    `json:"latency"`
+	Results    []QueryLogResult `json:"results"`
+}
+
+// QueryLogResult 查询返回的一条结果
+type QueryLogResult struct {
+	ID    string  `json:"id,omitempty"` // 内容哈希
+	Path  string  `json:"path"`         // collection/path
+	Score float64 `json:"score"`
+}
+
+// AnalyticsOptions 查询分析选项
+type AnalyticsOptions struct {
+	Since         time.Duration // 统计最近多长时间的日志（0表示保留期内全部）
+	Limit         int           // 每个列表最多返回的条数（默认10）
+	SlowThreshold time.Duration // 慢查询阈值（默认500ms）
+}
+
+// QueryStat 按查询文本（忽略大小写和多余空白）聚合的统计
+type QueryStat struct {
+	Query       string        `json:"query"`
+	Count       int           `json:"count"`
+	ZeroResults int           `json:"zero_results"` // 无结果次数
+	AvgResults  float64       `json:"avg_results"`
+	AvgLatency  time.Duration `json:"avg_latency"`
+	LastSeen    time.Time     `json:"last_seen"`
+}
+
+// QueryAnalytics 查询分析报告
+type QueryAnalytics struct {
+	Since               time.Time       `json:"since"`
+	TotalQueries        int             `json:"total_queries"`
+	ZeroResultCount     int             `json:"zero_result_count"` // 无结果的查询次数
+	AvgLatency          time.Duration   `json:"avg_latency"`
+	P95Latency          time.Duration   `json:"p95_latency"`
+	ByKind              map[string]int  `json:"by_kind"`
+	TopQueries          []QueryStat     `json:"top_queries"`     // 按次数排序
+	ZeroResults         []QueryStat     `json:"zero_results"`    // 从未返回结果的查询，按次数排序
+	SlowQueries         []QueryLogEntry `json:"slow_queries"`    // 超过阈值的查询，按耗时排序
+	NeverRetrieved      []string        `json:"never_retrieved"` // 从未出现在结果中的文档（collection/path）
+	NeverRetrievedCount int             `json:"never_retrieved_count"`
+}
+
+// QuantizeStats 向量量化转换统计
+type QuantizeStats struct {
+	Vectors     int   `json:"vectors"`      // 处理的向量数
+	Float32     int   `json:"float32"`      // 保持float32的向量数
+	Int8        int   `json:"int8"`         // int8量化的向量数
+	Binary      int   `json:"binary"`       // 带二值编码的向量数
+	BytesBefore int64 `json:"bytes_before"` // 转换前向量存储大小
+	BytesAfter  int64 `json:"bytes_after"`  // 转换后向量存储大小
+}